@@ -5,7 +5,9 @@ import (
 	"errors"
 	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/agusx1211/miclaw/logging"
 	"github.com/agusx1211/miclaw/prompt"
 	"github.com/agusx1211/miclaw/provider"
 	"github.com/agusx1211/miclaw/store"
@@ -16,24 +18,55 @@ type Agent struct {
 	messages          store.MessageStore
 	tools             []tooling.Tool
 	provider          provider.LLMProvider
+	summaryProvider   provider.LLMProvider
 	noToolSleepRounds int
 	active            atomic.Bool
+	stopping          atomic.Bool
 	cancel            context.CancelFunc
 	eventBroker       *Broker[AgentEvent]
 	pending           *InputQueue
+	source            string
+	turnInputs        []string
+	waiters           map[string]*turnWaiter
 	workspace         *prompt.Workspace
 	skills            []prompt.SkillSummary
+	glossary          []prompt.GlossaryEntry
 	memory            string
 	heartbeat         string
 	runtimeInfo       string
 	promptMode        string
-	trace             func(format string, args ...any)
+	trace             func(level logging.Level, format string, args ...any)
+	lastErr           error
+	lastErrAt         time.Time
+	rotation          string
+	location          *time.Location
+	now               func() time.Time
+	streamMessage     func(ctx context.Context, to, text string) error
+	toolCallIDs       string
+	truncatedCalls    string
+	compactThreshold  float64
+	compactKeep       int
+	maxToolRounds     int
+	maxParallelTools  int
+	coalesceWindow    time.Duration
+	toolTimeouts      ToolTimeouts
+	toolPolicies      ToolPolicies
+	generationTimeout time.Duration
+	toolSummary       ToolResultSummary
+	compactStuck      bool
+	costs             *store.CostStore
+	maxThreadCost     float64
+	maxDayCost        float64
 
 	mu sync.Mutex
 }
 
 const defaultNoToolSleepRounds = 16
 
+const defaultMaxToolRounds = 25
+
+const defaultMaxParallelTools = 4
+
 func NewAgent(
 	messages store.MessageStore,
 	toolList []tooling.Tool,
@@ -45,12 +78,19 @@ func NewAgent(
 		tools:             append([]tooling.Tool(nil), toolList...),
 		provider:          prov,
 		noToolSleepRounds: defaultNoToolSleepRounds,
+		maxToolRounds:     defaultMaxToolRounds,
+		maxParallelTools:  defaultMaxParallelTools,
 		eventBroker:       NewBroker[AgentEvent](),
 		pending:           &InputQueue{},
 		workspace:         &prompt.Workspace{},
 		skills:            []prompt.SkillSummary{},
 		promptMode:        "full",
-		trace:             func(string, ...any) {},
+		toolCallIDs:       ToolCallIDsNamespace,
+		truncatedCalls:    TruncatedCallsRetry,
+		compactThreshold:  defaultCompactThreshold,
+		trace:             func(logging.Level, string, ...any) {},
+		location:          time.UTC,
+		now:               time.Now,
 	}
 
 	return a
@@ -62,7 +102,9 @@ func (a *Agent) RunOnce(ctx context.Context, input Input) error {
 		return errors.New("agent is active")
 	}
 	defer a.active.Store(false)
-	a.pending.Push(input)
+	if err := a.pending.Push(input); err != nil {
+		return err
+	}
 	return a.run(ctx)
 }
 
@@ -81,7 +123,23 @@ func (a *Agent) SetSkills(skills []prompt.SkillSummary) {
 	a.skills = skills
 }
 
-func (a *Agent) SetTrace(trace func(format string, args ...any)) {
+func (a *Agent) SetGlossary(entries []prompt.GlossaryEntry) {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.glossary = entries
+}
+
+// AddGlossaryEntry makes a new entry available to the next prompt build.
+func (a *Agent) AddGlossaryEntry(e prompt.GlossaryEntry) {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.glossary = append(a.glossary, e)
+}
+
+// SetTrace sets where the agent's trace lines go, each with its log level.
+func (a *Agent) SetTrace(trace func(level logging.Level, format string, args ...any)) {
 
 	a.trace = trace
 }
@@ -91,15 +149,93 @@ func (a *Agent) SetNoToolSleepRounds(rounds int) {
 	a.noToolSleepRounds = rounds
 }
 
-func (a *Agent) Inject(input Input) {
+// SetMaxToolRounds caps the tool-call rounds of one turn. When the cap is
+// hit the model gets a final round with only the message tool and the turn
+// ends.
+func (a *Agent) SetMaxToolRounds(rounds int) {
+
+	a.maxToolRounds = rounds
+}
+
+// SetMaxParallelTools sets how many tool calls of one assistant message may
+// run at once; 1 runs them in order. Serial tools always run alone.
+func (a *Agent) SetMaxParallelTools(n int) {
+
+	a.maxParallelTools = n
+}
+
+// SetCoalesceWindow makes a woken agent wait d before taking its queued
+// inputs, and merge the inputs of each source into one. Zero disables it.
+func (a *Agent) SetCoalesceWindow(d time.Duration) {
+
+	a.coalesceWindow = d
+}
+
+// SetMessageStream makes the agent deliver the content of message tool calls
+// while they are still being generated: send gets the call's target and each
+// completed paragraph. The tool then sends only the rest. A send error stops
+// streaming that call and leaves its content to the tool. Reply text is the
+// agent's own notes and is never streamed.
+func (a *Agent) SetMessageStream(send func(ctx context.Context, to, text string) error) {
+
+	a.streamMessage = send
+}
+
+// SetQueueLimits bounds the inputs that may wait while the agent is busy.
+func (a *Agent) SetQueueLimits(limits QueueLimits) {
+
+	a.pending.SetLimits(limits)
+}
+
+// SetQueueStore persists queued inputs in s so they survive a restart; see
+// RestoreQueue.
+func (a *Agent) SetQueueStore(s *store.QueueStore) {
+
+	a.pending.SetStore(s)
+}
+
+// RestoreQueue queues the inputs a previous process accepted but never
+// finished, oldest first, and wakes the agent. Inputs whose generation was
+// cut short run again; a user message already stored for one is not stored
+// twice. It returns how many inputs were restored.
+func (a *Agent) RestoreQueue() (int, error) {
 
-	a.pending.Push(input)
+	n, err := a.pending.restore()
+	if err != nil || n == 0 {
+		return n, err
+	}
+	a.startWorker()
+	return n, nil
+}
+
+// Inject queues input and wakes the agent. It returns an error wrapping
+// ErrQueueFull when the queue limits would be exceeded, and ErrShuttingDown
+// after StopAccepting.
+func (a *Agent) Inject(input Input) error {
+
+	if a.stopping.Load() {
+		return ErrShuttingDown
+	}
+	if err := a.pending.Push(input); err != nil {
+		return err
+	}
 	a.startWorker()
+	return nil
+}
+
+// Steer queues input to redirect the running turn: it is added to the
+// thread before the turn's next generation round, ahead of other waiting
+// inputs, instead of after the turn ends. The round in flight is not
+// cancelled. With no turn running it starts one, like Inject.
+func (a *Agent) Steer(input Input) error {
+
+	input.Priority = PrioritySteer
+	return a.Inject(input)
 }
 
 func (a *Agent) startWorker() {
 
-	if !a.active.CompareAndSwap(false, true) {
+	if a.stopping.Load() || !a.active.CompareAndSwap(false, true) {
 		return
 	}
 	ctx, cancel := context.WithCancel(context.Background())
@@ -120,9 +256,20 @@ func (a *Agent) runAsync(ctx context.Context) {
 		}
 	}()
 	a.tracef("wake")
+	if a.coalesceWindow > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(a.coalesceWindow):
+		}
+	}
 	if err := a.run(ctx); err != nil {
-		a.tracef("error=%v", err)
-		a.eventBroker.Publish(AgentEvent{Type: EventError, Error: err})
+		a.traceErrorf("error=%v", err)
+		if !errors.Is(err, context.Canceled) {
+			a.mu.Lock()
+			a.lastErr, a.lastErrAt = err, time.Now()
+			a.mu.Unlock()
+		}
+		a.eventBroker.Publish(AgentEvent{Type: EventError, Source: a.source, Error: err})
 	}
 	a.tracef("sleep")
 }
@@ -144,12 +291,43 @@ func (a *Agent) IsActive() bool {
 	return a.active.Load()
 }
 
+// QueueDepth returns the number of inputs waiting for the agent.
+func (a *Agent) QueueDepth() int {
+
+	return a.pending.Len()
+}
+
+// LastError returns the most recent error that ended a background run, or
+// nil if none has happened. Cancellations are not recorded.
+func (a *Agent) LastError() (error, time.Time) {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.lastErr, a.lastErrAt
+}
+
 func (a *Agent) Events() *Broker[AgentEvent] {
 
 	return a.eventBroker
 }
 
+// PublishToolOutput publishes output a running tool call produced as an
+// EventToolOutput. It does nothing while nobody is subscribed.
+func (a *Agent) PublishToolOutput(callID, toolName, text string) {
+
+	if !a.eventBroker.HasSubscribers() {
+		return
+	}
+	a.eventBroker.Publish(AgentEvent{Type: EventToolOutput, Source: a.source, Text: text, ToolCallID: callID, ToolName: toolName})
+}
+
 func (a *Agent) tracef(format string, args ...any) {
 
-	a.trace(format, args...)
+	a.trace(logging.Debug, format, args...)
+}
+
+// traceErrorf traces a failure, which is kept at the error log level.
+func (a *Agent) traceErrorf(format string, args ...any) {
+
+	a.trace(logging.Error, format, args...)
 }
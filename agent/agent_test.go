@@ -3,18 +3,22 @@ package agent
 import (
 	"context"
 	"errors"
+	"net/http"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/agusx1211/miclaw/model"
 	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/store"
 	"github.com/agusx1211/miclaw/tooling"
 )
 
 type memMessageStore struct {
-	mu   sync.Mutex
-	msgs []*model.Message
+	mu      sync.Mutex
+	msgs    []*model.Message
+	archive map[string][]*model.Message
 }
 
 func (s *memMessageStore) Create(msg *model.Message) error {
@@ -68,6 +72,22 @@ func (s *memMessageStore) Count() (int, error) {
 	return len(s.msgs), nil
 }
 
+func (s *memMessageStore) Archive(period string, msgs []*model.Message) error {
+	s.mu.Lock()
+	if s.archive == nil {
+		s.archive = map[string][]*model.Message{}
+	}
+	s.archive[period] = append(s.archive[period], s.msgs...)
+	s.mu.Unlock()
+	return s.ReplaceAll(msgs)
+}
+
+func (s *memMessageStore) ListArchive(period string) ([]*model.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*model.Message(nil), s.archive[period]...), nil
+}
+
 func (s *memMessageStore) ReplaceAll(msgs []*model.Message) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -79,9 +99,13 @@ func (s *memMessageStore) ReplaceAll(msgs []*model.Message) error {
 	return nil
 }
 
+func (s *memMessageStore) Search(query string, limit int) ([]store.MessageHit, error) {
+	return []store.MessageHit{}, nil
+}
+
 type idleProvider struct{}
 
-func (idleProvider) Stream(context.Context, []model.Message, []provider.ToolDef) <-chan provider.ProviderEvent {
+func (idleProvider) Stream(context.Context, []model.Message, []provider.ToolDef, provider.ToolChoice) <-chan provider.ProviderEvent {
 	ch := make(chan provider.ProviderEvent, 4)
 	ch <- provider.ProviderEvent{Type: provider.EventContentDelta, Delta: "ok"}
 	ch <- provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "sleep-1", ToolName: "sleep"}
@@ -99,7 +123,7 @@ type blockingProvider struct {
 	started chan struct{}
 }
 
-func (p blockingProvider) Stream(ctx context.Context, _ []model.Message, _ []provider.ToolDef) <-chan provider.ProviderEvent {
+func (p blockingProvider) Stream(ctx context.Context, _ []model.Message, _ []provider.ToolDef, _ provider.ToolChoice) <-chan provider.ProviderEvent {
 	ch := make(chan provider.ProviderEvent, 1)
 	go func() {
 		defer close(ch)
@@ -187,6 +211,66 @@ func TestAgentCancel(t *testing.T) {
 	}
 }
 
+func TestAgentLastErrorRecordsFailedRun(t *testing.T) {
+	store := &memMessageStore{}
+	prov := &scriptedProvider{streams: []streamScript{
+		eventStream(provider.ProviderEvent{Type: provider.EventError, Error: errors.New("upstream 502")}),
+	}}
+	a := NewAgent(store, nil, prov)
+	if err, _ := a.LastError(); err != nil {
+		t.Fatalf("new agent has last error: %v", err)
+	}
+	a.Inject(Input{Source: "api", Content: "hello"})
+	deadline := time.Now().Add(time.Second)
+	for {
+		if err, at := a.LastError(); err != nil {
+			if !strings.Contains(err.Error(), "upstream 502") || at.IsZero() {
+				t.Fatalf("unexpected last error: %v at %v", err, at)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("last error was not recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAgentWrapsProviderAuthErrors(t *testing.T) {
+	rejected := &provider.StatusError{Backend: "openrouter", Status: http.StatusUnauthorized, Body: "invalid key"}
+	prov := &scriptedProvider{streams: []streamScript{
+		eventStream(provider.ProviderEvent{Type: provider.EventError, Error: rejected}),
+	}}
+	a := NewAgent(&memMessageStore{}, nil, prov)
+	err := a.RunOnce(context.Background(), Input{Source: "api", Content: "hello"})
+	if !errors.Is(err, ErrProviderAuth) {
+		t.Fatalf("want ErrProviderAuth, got %v", err)
+	}
+	var status *provider.StatusError
+	if !errors.As(err, &status) || status.Status != http.StatusUnauthorized {
+		t.Fatalf("backend error lost: %v", err)
+	}
+	if prov.CallCount() != 1 {
+		t.Fatalf("provider called %d times, want 1", prov.CallCount())
+	}
+}
+
+func TestAgentLastErrorIgnoresCancel(t *testing.T) {
+	store := &memMessageStore{}
+	started := make(chan struct{})
+	a := NewAgent(store, nil, blockingProvider{started: started})
+	a.Inject(Input{Source: "api", Content: "hello"})
+	<-started
+	a.Cancel()
+	deadline := time.Now().Add(time.Second)
+	for a.IsActive() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err, _ := a.LastError(); err != nil {
+		t.Fatalf("cancel recorded as error: %v", err)
+	}
+}
+
 func TestAgentEventsSubscription(t *testing.T) {
 	a, _ := newTestAgent(t)
 	ch, unsub := a.Events().Subscribe()
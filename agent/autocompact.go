@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+// charsPerToken is the rough ratio used to estimate history size without a
+// tokenizer.
+const charsPerToken = 4
+
+const defaultCompactThreshold = 0.8
+
+// SetCompactThreshold sets the fraction of the model's input budget the
+// history may reach before the thread is compacted automatically. The
+// budget comes from the provider's ContextWindow minus MaxOutput; providers
+// that report no context window are never compacted automatically.
+func (a *Agent) SetCompactThreshold(fraction float64) {
+
+	a.compactThreshold = fraction
+}
+
+// estimateTokens approximates the token count of history from its text.
+func estimateTokens(history []model.Message) int {
+
+	chars := 0
+	for _, msg := range history {
+		for _, part := range msg.Parts {
+			switch p := part.(type) {
+			case model.TextPart:
+				chars += len(p.Text)
+			case model.ReasoningPart:
+				chars += len(p.Text)
+			case model.ToolCallPart:
+				chars += len(p.Name) + len(p.Parameters)
+			case model.ToolResultPart:
+				chars += len(p.Content)
+			}
+		}
+	}
+	return chars / charsPerToken
+}
+
+// compactLimit returns the estimated token count at which history is
+// compacted, or 0 when automatic compaction is off.
+func (a *Agent) compactLimit() int {
+
+	info := a.provider.Model()
+	if a.compactThreshold <= 0 || info.ContextWindow <= info.MaxOutput {
+		return 0
+	}
+	return int(float64(info.ContextWindow-info.MaxOutput) * a.compactThreshold)
+}
+
+// autoCompact compacts the thread when history has grown past the limit and
+// returns the history to send. If the compacted history is still over the
+// limit (the summary itself is huge), automatic compaction stays off until
+// the history drops below the limit again, so it cannot loop.
+func (a *Agent) autoCompact(ctx context.Context, msgs []*Message, history []model.Message) ([]model.Message, error) {
+
+	limit := a.compactLimit()
+	tokens := estimateTokens(history)
+	if limit == 0 || tokens < limit {
+		a.compactStuck = false
+		return history, nil
+	}
+	if a.compactStuck || len(msgs) < 2 {
+		return history, nil
+	}
+	a.tracef("auto_compact tokens=%d limit=%d", tokens, limit)
+	a.eventBroker.Publish(AgentEvent{
+		Type:   EventCompaction,
+		Source: a.source,
+		Text:   fmt.Sprintf("Compacting the conversation: about %d tokens of context against a limit of %d.", tokens, limit),
+	})
+	if err := a.Compact(ctx); err != nil {
+		return nil, err
+	}
+	msgs, err := a.messages.List(threadMessageLimit, 0)
+	if err != nil {
+		return nil, err
+	}
+	history = a.buildHistory(msgs)
+	if tokens = estimateTokens(history); tokens >= limit {
+		a.tracef("auto_compact_stuck tokens=%d limit=%d", tokens, limit)
+		a.compactStuck = true
+	}
+	return history, nil
+}
@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+)
+
+func textStream(text string) streamScript {
+	return eventStream(
+		provider.ProviderEvent{Type: provider.EventContentDelta, Delta: text},
+		provider.ProviderEvent{Type: provider.EventComplete},
+	)
+}
+
+func seedLargeThread(t *testing.T, a *Agent) {
+	t.Helper()
+	now := time.Date(2026, 2, 21, 0, 0, 0, 0, time.UTC)
+	big := strings.Repeat("x", 200_000)
+	for i, role := range []model.Role{RoleUser, RoleAssistant} {
+		msg := &Message{ID: string(role), Role: role, Parts: []MessagePart{TextPart{Text: big}}, CreatedAt: now.Add(time.Duration(i) * time.Second)}
+		if err := a.messages.Create(msg); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	history := []model.Message{
+		{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: strings.Repeat("a", 40)}}},
+		{Role: model.RoleAssistant, Parts: []model.MessagePart{
+			model.ReasoningPart{Text: strings.Repeat("b", 20)},
+			model.ToolCallPart{ID: "c1", Name: "read", Parameters: []byte(`{"path":"x"}`)},
+		}},
+		{Role: model.RoleTool, Parts: []model.MessagePart{model.ToolResultPart{ToolCallID: "c1", Content: strings.Repeat("c", 24)}}},
+	}
+	if got := estimateTokens(history); got != (40+20+4+12+24)/4 {
+		t.Fatalf("estimateTokens = %d", got)
+	}
+}
+
+func TestAutoCompactBeforeGeneration(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{
+		streams: []streamScript{textStream("short summary"), textStream("done")},
+		model:   provider.ModelInfo{ContextWindow: 100_000, MaxOutput: 1_000},
+	}
+	a := NewAgent(s.MessageStore(), nil, p)
+	a.SetNoToolSleepRounds(1)
+	seedLargeThread(t, a)
+	events, unsub := a.Events().Subscribe()
+	defer unsub()
+
+	if err := a.RunOnce(context.Background(), Input{Source: "signal:dm:u1", Content: "next"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	ev := waitEvent(t, events)
+	if ev.Type != EventCompaction || ev.Source != "signal:dm:u1" || ev.Text == "" {
+		t.Fatalf("want compaction event first, got %+v", ev)
+	}
+	if ev = waitEvent(t, events); ev.Type != EventCompact {
+		t.Fatalf("want compact event, got %q", ev.Type)
+	}
+	if p.CallCount() != 2 {
+		t.Fatalf("want summary and reply calls, got %d", p.CallCount())
+	}
+	if got := estimateTokens(p.seenMessages[1]); got > 10_000 {
+		t.Fatalf("reply was generated from uncompacted history (%d tokens)", got)
+	}
+	msgs := listMessages(t, s)
+	if len(msgs) != 2 {
+		t.Fatalf("want summary and reply after compaction, got %d messages", len(msgs))
+	}
+	if msgs[0].Role != RoleUser || msgs[1].Role != RoleAssistant || msgs[1].CreatedAt.Before(msgs[0].CreatedAt) {
+		t.Fatalf("reply must sort after the summary: %s@%s, %s@%s", msgs[0].Role, msgs[0].CreatedAt, msgs[1].Role, msgs[1].CreatedAt)
+	}
+	for _, msg := range msgs {
+		text := textPart(msg)
+		if msg.Role == RoleUser && !strings.HasPrefix(text, "short summary") {
+			t.Fatalf("unexpected summary: %q", text)
+		}
+		if msg.Role == RoleUser && !strings.Contains(text, "Last request from user was: [signal:dm:u1] next") {
+			t.Fatalf("summary lost the pending request: %q", text)
+		}
+		if msg.Role == RoleAssistant && text != "done" {
+			t.Fatalf("unexpected reply: %q", text)
+		}
+	}
+}
+
+func TestAutoCompactDoesNotLoopOnHugeSummary(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{
+		streams: []streamScript{textStream(strings.Repeat("s", 400_000)), textStream("one"), textStream("two")},
+		model:   provider.ModelInfo{ContextWindow: 100_000, MaxOutput: 1_000},
+	}
+	a := NewAgent(s.MessageStore(), nil, p)
+	a.SetNoToolSleepRounds(2)
+	seedLargeThread(t, a)
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "next"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if p.CallCount() != 3 {
+		t.Fatalf("want one compaction and two replies, got %d calls", p.CallCount())
+	}
+	if !a.compactStuck {
+		t.Fatal("want automatic compaction held off after an oversized summary")
+	}
+}
+
+func TestAutoCompactOffWithoutContextWindow(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{
+		streams: []streamScript{textStream("done")},
+		model:   provider.ModelInfo{MaxOutput: 1_000},
+	}
+	a := NewAgent(s.MessageStore(), nil, p)
+	a.SetNoToolSleepRounds(1)
+	seedLargeThread(t, a)
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "next"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if p.CallCount() != 1 || len(listMessages(t, s)) != 4 {
+		t.Fatalf("want no compaction, got %d calls", p.CallCount())
+	}
+}
@@ -1,17 +1,38 @@
 package agent
 
-import "sync"
+import (
+	"slices"
+	"sync"
+)
 
 type Broker[T any] struct {
-	mu   sync.Mutex
-	subs []chan T
+	mu     sync.Mutex
+	subs   []chan T
+	queues []*queuedSub[T]
+	// sink, when set, receives every event synchronously, before the
+	// subscribers and whether or not there are any. It runs outside mu, so
+	// a slow write does not hold up Subscribe or other publishers.
+	sink func(T)
 }
 
 func NewBroker[T any]() *Broker[T] {
 	return &Broker[T]{}
 }
 
+// SetSink makes Publish hand every event to fn; nil removes it.
+func (b *Broker[T]) SetSink(fn func(T)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sink = fn
+}
+
 func (b *Broker[T]) Publish(event T) {
+	b.mu.Lock()
+	sink := b.sink
+	b.mu.Unlock()
+	if sink != nil {
+		sink(event)
+	}
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	for _, sub := range b.subs {
@@ -20,6 +41,18 @@ func (b *Broker[T]) Publish(event T) {
 		default:
 		}
 	}
+	for _, q := range b.queues {
+		q.push(event)
+	}
+}
+
+// HasSubscribers reports whether any channel subscriber is attached, so
+// publishers can skip building streaming events nobody will read. The sink
+// does not count: events it must see are published unconditionally.
+func (b *Broker[T]) HasSubscribers() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)+len(b.queues) > 0
 }
 
 func (b *Broker[T]) Subscribe() (<-chan T, func()) {
@@ -43,3 +76,78 @@ func (b *Broker[T]) Subscribe() (<-chan T, func()) {
 		b.mu.Unlock()
 	}
 }
+
+// SubscribeQueued returns a channel that receives every event keep accepts,
+// in order. Unlike Subscribe it never drops one: events wait in an unbounded
+// queue until read, so keep should accept only what the reader needs. After
+// the returned func is called the channel delivers what was already queued
+// and then closes; read it to the end.
+func (b *Broker[T]) SubscribeQueued(keep func(T) bool) (<-chan T, func()) {
+	q := &queuedSub[T]{keep: keep, wake: make(chan struct{}, 1)}
+	out := make(chan T)
+	go q.pump(out)
+	b.mu.Lock()
+	b.queues = append(b.queues, q)
+	b.mu.Unlock()
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			b.queues = slices.DeleteFunc(b.queues, func(v *queuedSub[T]) bool { return v == q })
+			b.mu.Unlock()
+			q.stop()
+		})
+	}
+}
+
+// queuedSub buffers the events of one SubscribeQueued reader.
+type queuedSub[T any] struct {
+	keep    func(T) bool
+	mu      sync.Mutex
+	items   []T
+	stopped bool
+	wake    chan struct{}
+}
+
+func (q *queuedSub[T]) push(event T) {
+	if !q.keep(event) {
+		return
+	}
+	q.mu.Lock()
+	q.items = append(q.items, event)
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *queuedSub[T]) stop() {
+	q.mu.Lock()
+	q.stopped = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *queuedSub[T]) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pump hands queued events to out until stop, then delivers the rest and
+// closes out.
+func (q *queuedSub[T]) pump(out chan<- T) {
+	defer close(out)
+	for {
+		q.mu.Lock()
+		items, stopped := q.items, q.stopped
+		q.items = nil
+		q.mu.Unlock()
+		for _, event := range items {
+			out <- event
+		}
+		if stopped {
+			return
+		}
+		<-q.wake
+	}
+}
@@ -186,3 +186,73 @@ func TestBrokerConcurrentPublishSubscribe(t *testing.T) {
 		t.Fatalf("expected at least one delivered event")
 	}
 }
+
+func TestBrokerHasSubscribers(t *testing.T) {
+	b := NewBroker[int]()
+	if b.HasSubscribers() {
+		t.Fatal("new broker reports subscribers")
+	}
+	_, unsub := b.Subscribe()
+	if !b.HasSubscribers() {
+		t.Fatal("subscriber not reported")
+	}
+	unsub()
+	if b.HasSubscribers() {
+		t.Fatal("subscriber still reported after unsubscribe")
+	}
+}
+
+func TestBrokerSinkRunsOutsideLock(t *testing.T) {
+	b := NewBroker[int]()
+	ch, unsub := b.Subscribe()
+	defer unsub()
+	b.SetSink(func(v int) {
+		if v == 1 && !b.HasSubscribers() {
+			t.Error("subscriber not reported from the sink")
+		}
+		if v == 1 {
+			b.Publish(2)
+		}
+	})
+	done := make(chan struct{})
+	go func() {
+		b.Publish(1)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish from the sink deadlocked")
+	}
+	if got := []int{<-ch, <-ch}; got[0] != 2 || got[1] != 1 {
+		t.Fatalf("delivered = %v", got)
+	}
+}
+
+func TestBrokerSubscribeQueuedNeverDrops(t *testing.T) {
+	b := NewBroker[int]()
+	ch, unsub := b.SubscribeQueued(func(v int) bool { return v%2 == 0 })
+	if !b.HasSubscribers() {
+		t.Fatal("queued subscriber not reported")
+	}
+	for i := range 1000 {
+		b.Publish(i)
+	}
+	unsub()
+	b.Publish(1000)
+	var got []int
+	for v := range ch {
+		got = append(got, v)
+	}
+	if len(got) != 500 || got[0] != 0 || got[499] != 998 {
+		t.Fatalf("delivered %d events: %v...", len(got), got[:min(len(got), 5)])
+	}
+	for i, v := range got {
+		if v != 2*i {
+			t.Fatalf("event %d = %d, out of order", i, v)
+		}
+	}
+	if b.HasSubscribers() {
+		t.Fatal("queued subscriber still reported after unsubscribe")
+	}
+}
@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/store"
+)
+
+// ErrBudgetExceeded is wrapped by the error that stops a turn when a cost
+// limit has been reached.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// SetCostLimits records the cost of every generation in costs and stops a
+// turn before the provider is called once the thread's or today's total
+// reaches its limit. A limit of 0 is off; costs are recorded regardless.
+func (a *Agent) SetCostLimits(costs *store.CostStore, perThread, perDay float64) {
+
+	a.costs = costs
+	a.maxThreadCost = perThread
+	a.maxDayCost = perDay
+}
+
+// UnlockBudget clears today's cost total, lifting the daily limit until the
+// day's spending reaches it again.
+func (a *Agent) UnlockBudget() error {
+
+	return a.costs.Reset(a.costDay())
+}
+
+// ResetThreadCost clears the thread's cost total; call it when the thread
+// is cleared. Rotation resets it on its own.
+func (a *Agent) ResetThreadCost() error {
+
+	return a.costs.Reset(store.CostThread)
+}
+
+// costDay is the key of today's total, a date in the rotation timezone.
+func (a *Agent) costDay() string {
+
+	return a.now().In(a.location).Format("2006-01-02")
+}
+
+func (a *Agent) checkBudget() error {
+
+	if a.costs == nil {
+		return nil
+	}
+	limits := []struct {
+		key, field string
+		max        float64
+	}{
+		{store.CostThread, "limits.max_cost_per_thread", a.maxThreadCost},
+		{a.costDay(), "limits.max_cost_per_day", a.maxDayCost},
+	}
+	for _, l := range limits {
+		if l.max <= 0 {
+			continue
+		}
+		spent, err := a.costs.Total(l.key)
+		if err != nil {
+			return fmt.Errorf("read cost total: %v", err)
+		}
+		if spent >= l.max {
+			return fmt.Errorf("%w: spent $%.2f of %s $%.2f", ErrBudgetExceeded, spent, l.field, l.max)
+		}
+	}
+	return nil
+}
+
+func (a *Agent) recordCost(usage *provider.UsageInfo) error {
+
+	if a.costs == nil || usage == nil || usage.Cost <= 0 {
+		return nil
+	}
+	if err := a.costs.Add(usage.Cost, a.costDay(), store.CostThread); err != nil {
+		return fmt.Errorf("record cost: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/store"
+)
+
+func openTestCosts(t *testing.T) *store.CostStore {
+	t.Helper()
+	s, err := store.OpenSQLite(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s.Costs()
+}
+
+// pricedReply answers with text and 1000 prompt tokens, which cost $0.40
+// at the scripted model's rate.
+func pricedReply(text string) streamScript {
+	return eventStream(
+		provider.ProviderEvent{Type: provider.EventContentDelta, Delta: text},
+		provider.ProviderEvent{Type: provider.EventComplete, Usage: &provider.UsageInfo{PromptTokens: 1000}},
+	)
+}
+
+func TestAgentStopsTurnWhenDailyBudgetIsSpent(t *testing.T) {
+	costs := openTestCosts(t)
+	prov := &scriptedProvider{
+		model:   provider.ModelInfo{ID: "m", CostPerInputToken: 0.0004},
+		streams: []streamScript{pricedReply("one"), pricedReply("two"), pricedReply("three")},
+	}
+	a := NewAgent(&memMessageStore{}, nil, prov)
+	a.SetNoToolSleepRounds(1)
+	a.now = func() time.Time { return time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC) }
+	a.SetCostLimits(costs, 0, 0.75)
+
+	for _, msg := range []string{"a", "b"} {
+		if err := a.RunOnce(context.Background(), Input{Source: "api", Content: msg}); err != nil {
+			t.Fatalf("run %s: %v", msg, err)
+		}
+	}
+	if spent, _ := costs.Total("2026-10-16"); spent != 0.8 {
+		t.Fatalf("day total = %v, want 0.8", spent)
+	}
+	if spent, _ := costs.Total(store.CostThread); spent != 0.8 {
+		t.Fatalf("thread total = %v, want 0.8", spent)
+	}
+	err := a.RunOnce(context.Background(), Input{Source: "api", Content: "c"})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+	if prov.CallCount() != 2 {
+		t.Fatalf("provider called %d times, want 2", prov.CallCount())
+	}
+
+	if err := a.UnlockBudget(); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "d"}); err != nil {
+		t.Fatalf("run after unlock: %v", err)
+	}
+}
+
+func TestAgentThreadBudgetResets(t *testing.T) {
+	costs := openTestCosts(t)
+	prov := &scriptedProvider{
+		model:   provider.ModelInfo{ID: "m", CostPerInputToken: 0.0004},
+		streams: []streamScript{pricedReply("one"), pricedReply("two")},
+	}
+	a := NewAgent(&memMessageStore{}, nil, prov)
+	a.SetNoToolSleepRounds(1)
+	a.SetCostLimits(costs, 0.4, 0)
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "a"}); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	err := a.RunOnce(context.Background(), Input{Source: "api", Content: "b"})
+	if !errors.Is(err, ErrBudgetExceeded) || err.Error() != "budget exceeded: spent $0.40 of limits.max_cost_per_thread $0.40" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := a.ResetThreadCost(); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "c"}); err != nil {
+		t.Fatalf("run after reset: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package agent
+
+// Tool-call id modes. Some providers reuse ids such as "call_1" on every
+// turn, so by default ids are namespaced with the assistant message that
+// issued them before they are stored, run, and replayed.
+const (
+	ToolCallIDsNamespace = "namespace"
+	ToolCallIDsProvider  = "provider"
+)
+
+// toolCallIDPrefixLen is how much of the assistant message id prefixes each
+// call id; 8 hex digits keep ids short for providers that cap their length.
+const toolCallIDPrefixLen = 8
+
+// SetToolCallIDs selects how tool-call ids from the provider are stored:
+// ToolCallIDsNamespace (the default) or ToolCallIDsProvider to keep them as
+// sent.
+func (a *Agent) SetToolCallIDs(mode string) {
+
+	a.toolCallIDs = mode
+}
+
+// namespaceToolCalls prefixes every call id with the start of messageID, so
+// ids repeated across rounds stay unique in the thread. Results are built
+// from the returned calls, so each result keeps pointing at its own call.
+func namespaceToolCalls(messageID string, calls []ToolCallPart) []ToolCallPart {
+
+	prefix := messageID[:min(len(messageID), toolCallIDPrefixLen)] + "_"
+	out := make([]ToolCallPart, len(calls))
+	for i, call := range calls {
+		call.ID = prefix + call.ID
+		out[i] = call
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+// argsTool returns its own parameters, so each result shows which call it
+// answers.
+type argsTool struct{}
+
+func (argsTool) Name() string { return "args" }
+
+func (argsTool) Description() string { return "echo parameters" }
+
+func (argsTool) Parameters() tooling.JSONSchema { return tooling.JSONSchema{Type: "object"} }
+
+func (argsTool) Run(_ context.Context, call model.ToolCallPart) (tooling.ToolResult, error) {
+	return tooling.ToolResult{Content: string(call.Parameters)}, nil
+}
+
+func reusedIDStream(name, args string) streamScript {
+	return eventStream(
+		provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "call_1", ToolName: name},
+		provider.ProviderEvent{Type: provider.EventToolUseDelta, ToolCallID: "call_1", Delta: args},
+		provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: "call_1"},
+		provider.ProviderEvent{Type: provider.EventComplete},
+	)
+}
+
+func runReusedIDRounds(t *testing.T, mode string) []*model.Message {
+	t.Helper()
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{
+		reusedIDStream("args", `{"n":1}`),
+		reusedIDStream("args", `{"n":2}`),
+		reusedIDStream("sleep", `{}`),
+	}}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{argsTool{}, &sleepTool{}}, p)
+	a.SetToolCallIDs(mode)
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "go"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	return listMessages(t, s)
+}
+
+func TestNamespacedToolCallIDsMapResultsAcrossRounds(t *testing.T) {
+	msgs := runReusedIDRounds(t, ToolCallIDsNamespace)
+	seen := map[string]bool{}
+	for i, msg := range msgs {
+		if msg.Role != model.RoleAssistant {
+			continue
+		}
+		call := msg.Parts[len(msg.Parts)-1].(model.ToolCallPart)
+		if seen[call.ID] {
+			t.Fatalf("tool-call id %q reused", call.ID)
+		}
+		seen[call.ID] = true
+		if call.ID != msg.ID[:toolCallIDPrefixLen]+"_call_1" {
+			t.Fatalf("call id %q not namespaced with message %q", call.ID, msg.ID)
+		}
+		result := msgs[i+1].Parts[0].(model.ToolResultPart)
+		if result.ToolCallID != call.ID {
+			t.Fatalf("result %q answers %q, want %q", result.Content, result.ToolCallID, call.ID)
+		}
+		if call.Name == "args" && !jsonEqual(result.Content, string(call.Parameters)) {
+			t.Fatalf("result %q does not belong to call %s", result.Content, call.Parameters)
+		}
+	}
+	if len(seen) != 3 {
+		t.Fatalf("want 3 tool calls, got %d", len(seen))
+	}
+}
+
+func TestProviderToolCallIDsAreKept(t *testing.T) {
+	for _, msg := range runReusedIDRounds(t, ToolCallIDsProvider) {
+		for _, part := range msg.Parts {
+			if call, ok := part.(model.ToolCallPart); ok && call.ID != "call_1" {
+				t.Fatalf("call id rewritten to %q", call.ID)
+			}
+		}
+	}
+}
+
+func jsonEqual(a, b string) bool {
+	var x, y any
+	if json.Unmarshal([]byte(a), &x) != nil || json.Unmarshal([]byte(b), &y) != nil {
+		return false
+	}
+	xs, _ := json.Marshal(x)
+	ys, _ := json.Marshal(y)
+	return string(xs) == string(ys)
+}
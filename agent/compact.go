@@ -2,9 +2,11 @@ package agent
 
 import (
 	"context"
+	"slices"
 	"time"
 
 	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
 	"github.com/google/uuid"
 )
 
@@ -18,14 +20,102 @@ const compactPrompt = `Provide a detailed but concise summary of our conversatio
 7. Immediate Next Step
 Be precise with technical details, file names, and code.`
 
+// MetadataPinned is the message metadata key that, set to "true", keeps a
+// message through compaction. Input metadata is copied onto the stored user
+// message, so an input can arrive pinned.
+const MetadataPinned = "pinned"
+
+// SetCompactKeepMessages sets how many of the newest messages compaction
+// keeps verbatim after the summary; 0 summarizes the whole thread.
+func (a *Agent) SetCompactKeepMessages(n int) {
+
+	a.compactKeep = n
+}
+
+// Compact replaces the thread with a summary of it, followed by the pinned
+// messages and the newest compactKeep messages, unchanged and in order.
+// Only the messages before that tail are summarized; with nothing before
+// it the thread is left alone.
 func (a *Agent) Compact(ctx context.Context) error {
 	msgs, err := a.messages.List(threadMessageLimit, 0)
 	if err != nil {
 		return err
 	}
 	cleaned := cleanHistory(msgs)
+	start := compactTailStart(cleaned, a.compactKeep)
+	if start == 0 && a.compactKeep > 0 {
+		return nil
+	}
+	kept := append(pinnedMessages(cleaned[:start]), cleaned[start:]...)
+	last := findLastUserMessage(cleaned)
+	note := lastUserText(last)
+	if slices.ContainsFunc(kept, func(m *Message) bool { return m.ID == last.ID }) {
+		note = ""
+	}
+	summaryMsg, err := a.summarizeMessages(ctx, cleaned[:start], note)
+	if err != nil {
+		return err
+	}
+	// The thread is ordered by time, so the summary must predate what it
+	// is followed by.
+	if len(kept) > 0 {
+		summaryMsg.CreatedAt = kept[0].CreatedAt.Add(-time.Second)
+	}
+	if err := a.messages.ReplaceAll(append([]*Message{summaryMsg}, kept...)); err != nil {
+		return err
+	}
+	a.eventBroker.Publish(AgentEvent{Type: EventCompact})
+	return nil
+}
+
+// compactTailStart returns the index of the first of the newest keep
+// messages, moved back past tool results so none is kept without its call.
+func compactTailStart(msgs []*Message, keep int) int {
+	start := max(len(msgs)-keep, 0)
+	for start > 0 && start < len(msgs) && msgs[start].Role == RoleTool {
+		start--
+	}
+	return start
+}
+
+// pinnedMessages returns the pinned messages of msgs. An assistant message
+// and the tool results after it are kept or dropped together, so pinning
+// either keeps the call and its results.
+func pinnedMessages(msgs []*Message) []*Message {
+	var out []*Message
+	for i := 0; i < len(msgs); {
+		j := i + 1
+		for j < len(msgs) && msgs[j].Role == RoleTool {
+			j++
+		}
+		if slices.ContainsFunc(msgs[i:j], isPinned) {
+			out = append(out, msgs[i:j]...)
+		}
+		i = j
+	}
+	return out
+}
+
+func isPinned(msg *Message) bool {
+	return msg.Metadata[MetadataPinned] == "true"
+}
+
+// summaryMessage asks the summary provider to summarize the thread with the
+// compaction prompt and returns the summary as a user message.
+func (a *Agent) summaryMessage(ctx context.Context) (*Message, error) {
+	msgs, err := a.messages.List(threadMessageLimit, 0)
+	if err != nil {
+		return nil, err
+	}
+	cleaned := cleanHistory(msgs)
+	return a.summarizeMessages(ctx, cleaned, lastUserText(findLastUserMessage(cleaned)))
+}
+
+// summarizeMessages summarizes msgs with the compaction prompt. A non-empty
+// lastUser is appended to the summary as the last request from the user.
+func (a *Agent) summarizeMessages(ctx context.Context, msgs []*Message, lastUser string) (*Message, error) {
 	history := append(
-		flattenMessages(cleaned),
+		flattenMessages(msgs),
 		model.Message{
 			ID:        uuid.NewString(),
 			Role:      model.RoleUser,
@@ -33,23 +123,35 @@ func (a *Agent) Compact(ctx context.Context) error {
 			CreatedAt: time.Now().UTC(),
 		},
 	)
-	summary, _, _, _, err := a.collectStream(ctx, history, nil)
+	summary, _, _, _, err := a.collectStream(ctx, a.summarizer(), history, nil, provider.ToolChoiceNone, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	summaryMsg := &Message{
-		ID:   uuid.NewString(),
-		Role: RoleUser,
-		Parts: []MessagePart{TextPart{
-			Text: summary + "\n\nLast request from user was: " + lastUserText(findLastUserMessage(cleaned)),
-		}},
-		CreatedAt: time.Now().UTC(),
+	if lastUser != "" {
+		summary += "\n\nLast request from user was: " + lastUser
 	}
-	if err := a.messages.ReplaceAll([]*Message{summaryMsg}); err != nil {
-		return err
+	return &Message{
+		ID:        uuid.NewString(),
+		Role:      RoleUser,
+		Parts:     []MessagePart{TextPart{Text: summary}},
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// SetSummaryProvider sets the provider that writes compaction and rotation
+// summaries and summarizes oversized tool results, usually a cheaper model
+// than the one holding the conversation. nil uses the agent's provider.
+func (a *Agent) SetSummaryProvider(p provider.LLMProvider) {
+
+	a.summaryProvider = p
+}
+
+func (a *Agent) summarizer() provider.LLMProvider {
+
+	if a.summaryProvider != nil {
+		return a.summaryProvider
 	}
-	a.eventBroker.Publish(AgentEvent{Type: EventCompact})
-	return nil
+	return a.provider
 }
 
 func lastUserText(msg model.Message) string {
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/store"
 )
 
 func TestCleanHistoryFillsMissingToolResponses(t *testing.T) {
@@ -100,6 +101,9 @@ func TestCompactSummarizesAndReplaces(t *testing.T) {
 	if err := a.Compact(context.Background()); err != nil {
 		t.Fatalf("compact: %v", err)
 	}
+	if p.seenChoices[0] != provider.ToolChoiceNone {
+		t.Fatalf("compaction tool choice = %q, want none", p.seenChoices[0])
+	}
 
 	msgs, err := s.Messages.List(10, 0)
 	if err != nil {
@@ -116,6 +120,32 @@ func TestCompactSummarizesAndReplaces(t *testing.T) {
 	}
 }
 
+func TestCompactUsesSummaryProvider(t *testing.T) {
+	s := openAgentStore(t)
+	now := time.Date(2026, 2, 21, 0, 0, 0, 0, time.UTC)
+	if err := s.Messages.Create(&Message{ID: "u1", Role: RoleUser, Parts: []MessagePart{TextPart{Text: "first request"}}, CreatedAt: now}); err != nil {
+		t.Fatalf("create message: %v", err)
+	}
+	main := &scriptedProvider{model: provider.ModelInfo{ID: "main", CostPerInputToken: 0.0004}}
+	cheap := &scriptedProvider{
+		model:   provider.ModelInfo{ID: "cheap", CostPerInputToken: 0.0001},
+		streams: []streamScript{pricedReply("summary")},
+	}
+	costs := openTestCosts(t)
+	a := NewAgent(s.MessageStore(), nil, main)
+	a.SetCostLimits(costs, 0, 0)
+	a.SetSummaryProvider(cheap)
+	if err := a.Compact(context.Background()); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if main.CallCount() != 0 || cheap.CallCount() != 1 {
+		t.Fatalf("calls main=%d cheap=%d, want 0 and 1", main.CallCount(), cheap.CallCount())
+	}
+	if spent, _ := costs.Total(store.CostThread); spent != 0.1 {
+		t.Fatalf("summary cost = %v, want 0.1 at the summary model's price", spent)
+	}
+}
+
 func TestCompactPreservesLastUserIntent(t *testing.T) {
 	s := openAgentStore(t)
 	now := time.Date(2026, 2, 21, 0, 0, 0, 0, time.UTC)
@@ -176,6 +206,46 @@ func TestCompactPublishesEvent(t *testing.T) {
 	}
 }
 
+func TestCompactKeepsTailAndPinnedMessages(t *testing.T) {
+	s := openAgentStore(t)
+	now := time.Date(2026, 2, 21, 0, 0, 0, 0, time.UTC)
+	thread := []*Message{
+		{ID: "u1", Role: RoleUser, Parts: []MessagePart{TextPart{Text: "deploy key is in vault/prod"}}, Metadata: map[string]string{MetadataPinned: "true"}},
+		{ID: "a1", Role: RoleAssistant, Parts: []MessagePart{TextPart{Text: "noted"}}},
+		{ID: "u2", Role: RoleUser, Parts: []MessagePart{TextPart{Text: "old chatter"}}},
+		{ID: "a2", Role: RoleAssistant, Parts: []MessagePart{TextPart{Text: "ok"}}},
+		{ID: "u3", Role: RoleUser, Parts: []MessagePart{TextPart{Text: "run the build"}}},
+		{ID: "a3", Role: RoleAssistant, Parts: []MessagePart{ToolCallPart{ID: "c1", Name: "exec", Parameters: []byte(`{}`)}}},
+		{ID: "t1", Role: RoleTool, Parts: []MessagePart{ToolResultPart{ToolCallID: "c1", Content: "build ok"}}},
+		{ID: "a4", Role: RoleAssistant, Parts: []MessagePart{TextPart{Text: "done"}}},
+	}
+	for i, msg := range thread {
+		msg.CreatedAt = now.Add(time.Duration(i) * time.Minute)
+		if err := s.Messages.Create(msg); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+	}
+	p := &scriptedProvider{streams: []streamScript{textStream("summary")}}
+	a := NewAgent(s.MessageStore(), nil, p)
+	// Keeping 2 would start at the tool result, so its call is kept too.
+	a.SetCompactKeepMessages(2)
+	if err := a.Compact(context.Background()); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+
+	var ids []string
+	for _, msg := range listMessages(t, s)[1:] {
+		ids = append(ids, msg.ID)
+	}
+	if got := strings.Join(ids, ","); got != "u1,a3,t1,a4" {
+		t.Fatalf("kept messages = %s, want u1,a3,t1,a4", got)
+	}
+	summarized := p.seenMessages[0]
+	if n := len(summarized); n != 6 || summarized[4].ID != "u3" {
+		t.Fatalf("summary input = %d messages, want u1..u3 and the prompt", n)
+	}
+}
+
 func waitEvent(t *testing.T, ch <-chan AgentEvent) AgentEvent {
 	t.Helper()
 	select {
@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"strings"
+	"time"
+)
+
+// deltaInterval bounds EventDelta publishing to about 10 events per second.
+var deltaInterval = 100 * time.Millisecond
+
+// deltaCoalescer buffers content deltas and publishes them as EventDelta at
+// most once per deltaInterval. The final flush publishes whatever is left,
+// so the published texts of one stream concatenate to the streamed text.
+// With typ set to EventThinking it does the same for reasoning deltas.
+type deltaCoalescer struct {
+	broker *Broker[AgentEvent]
+	typ    AgentEventType
+	buf    strings.Builder
+	last   time.Time
+}
+
+func newDeltaCoalescer(broker *Broker[AgentEvent]) *deltaCoalescer {
+	return &deltaCoalescer{broker: broker, typ: EventDelta}
+}
+
+func (d *deltaCoalescer) add(text string) {
+	d.buf.WriteString(text)
+	if time.Since(d.last) >= deltaInterval {
+		d.flush()
+	}
+}
+
+func (d *deltaCoalescer) flush() {
+	d.last = time.Now()
+	if d.buf.Len() == 0 {
+		return
+	}
+	d.broker.Publish(AgentEvent{Type: d.typ, Text: d.buf.String()})
+	d.buf.Reset()
+}
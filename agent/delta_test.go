@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+func slowDeltaStream(deltas []string, gap time.Duration) streamScript {
+	return func(context.Context, []model.Message, []provider.ToolDef) <-chan provider.ProviderEvent {
+		ch := make(chan provider.ProviderEvent)
+		go func() {
+			defer close(ch)
+			for _, d := range deltas {
+				ch <- provider.ProviderEvent{Type: provider.EventContentDelta, Delta: d}
+				time.Sleep(gap)
+			}
+			ch <- provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "call-sleep", ToolName: "sleep"}
+			ch <- provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: "call-sleep"}
+			ch <- provider.ProviderEvent{Type: provider.EventComplete}
+		}()
+		return ch
+	}
+}
+
+func TestRunPublishesCoalescedDeltasInOrder(t *testing.T) {
+	old := deltaInterval
+	deltaInterval = 20 * time.Millisecond
+	t.Cleanup(func() { deltaInterval = old })
+
+	deltas := make([]string, 40)
+	for i := range deltas {
+		deltas[i] = string(rune('a' + i%26))
+	}
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{slowDeltaStream(deltas, 2*time.Millisecond)}}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{&sleepTool{}}, p)
+	events, unsub := a.Events().Subscribe()
+	defer unsub()
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "go"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	var got []string
+	for len(events) > 0 {
+		ev := <-events
+		if ev.Type == EventDelta {
+			got = append(got, ev.Text)
+		}
+	}
+	want := strings.Join(deltas, "")
+	if strings.Join(got, "") != want {
+		t.Fatalf("deltas do not sum to final text: got %q want %q", strings.Join(got, ""), want)
+	}
+	if len(got) < 2 || len(got) >= len(deltas) {
+		t.Fatalf("want coalesced deltas, got %d events for %d provider deltas", len(got), len(deltas))
+	}
+	msgs := listMessages(t, s)
+	if textPart(msgs[1]) != want {
+		t.Fatalf("stored reply %q, want %q", textPart(msgs[1]), want)
+	}
+}
+
+func TestRunSkipsDeltasWithoutSubscribers(t *testing.T) {
+	s := openAgentStore(t)
+	var a *Agent
+	var events <-chan AgentEvent
+	p := &scriptedProvider{streams: []streamScript{
+		func(ctx context.Context, msgs []model.Message, defs []provider.ToolDef) <-chan provider.ProviderEvent {
+			// Subscribe after the agent checked for subscribers, so any
+			// delta seen here would have been built for nobody.
+			var unsub func()
+			events, unsub = a.Events().Subscribe()
+			t.Cleanup(unsub)
+			return slowDeltaStream([]string{"hel", "lo"}, 0)(ctx, msgs, defs)
+		},
+	}}
+	a = NewAgent(s.MessageStore(), []tooling.Tool{&sleepTool{}}, p)
+	if a.Events().HasSubscribers() {
+		t.Fatal("fresh broker reports subscribers")
+	}
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "go"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	for len(events) > 0 {
+		if ev := <-events; ev.Type == EventDelta {
+			t.Fatalf("unexpected delta event: %#v", ev)
+		}
+	}
+	if textPart(listMessages(t, s)[1]) != "hello" {
+		t.Fatal("final reply was not stored")
+	}
+}
+
+func TestRunPublishesToolAndThinkingEvents(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{
+		eventStream(
+			provider.ProviderEvent{Type: provider.EventThinkingDelta, Delta: "check the disk"},
+			provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "c1", ToolName: "echo"},
+			provider.ProviderEvent{Type: provider.EventToolUseDelta, ToolCallID: "c1", Delta: `{"path":"/"}`},
+			provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: "c1"},
+			provider.ProviderEvent{Type: provider.EventComplete},
+		),
+		textStream("done"),
+	}}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{&echoTool{}}, p)
+	a.SetNoToolSleepRounds(1)
+	a.SetToolCallIDs(ToolCallIDsProvider)
+	events, unsub := a.Events().Subscribe()
+	defer unsub()
+
+	if err := a.RunOnce(context.Background(), Input{Source: "webhook:ci", Content: "disk?"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	var got []AgentEvent
+	for len(events) > 0 {
+		ev := <-events
+		switch ev.Type {
+		case EventThinking, EventToolStart, EventToolResult:
+			got = append(got, ev)
+		}
+	}
+	if len(got) != 3 || got[0].Type != EventThinking || got[0].Text != "check the disk" {
+		t.Fatalf("unexpected events: %+v", got)
+	}
+	start, result := got[1], got[2]
+	if start.Type != EventToolStart || start.ToolCallID != "c1" || start.ToolName != "echo" || string(start.Params) != `{"path":"/"}` || start.Source != "webhook:ci" {
+		t.Fatalf("unexpected start event: %+v", start)
+	}
+	if result.Type != EventToolResult || result.ToolCallID != "c1" || result.Text != "tool-ok" || result.IsError || result.DurationMs < 0 {
+		t.Fatalf("unexpected result event: %+v", result)
+	}
+}
@@ -0,0 +1,57 @@
+package agent
+
+import "github.com/agusx1211/miclaw/store"
+
+// eventLogContentLimit caps the content kept for one logged event.
+const eventLogContentLimit = 2000
+
+// SetEventLog records the agent's events in events as they are published,
+// so an event fired while nobody is subscribed still leaves a trace.
+// Streaming fragments are left out: deltas, thinking and tool
+// output repeat text that the response and tool result events carry whole.
+// After each write the log is cut back to the newest keep events; keep <= 0
+// keeps them all. A failed write is traced and does not stop the turn; nil
+// turns the log off.
+func (a *Agent) SetEventLog(events *store.EventStore, keep int) {
+
+	if events == nil {
+		a.eventBroker.SetSink(nil)
+		return
+	}
+	a.eventBroker.SetSink(func(ev AgentEvent) {
+		switch ev.Type {
+		case EventDelta, EventThinking, EventToolOutput:
+			return
+		}
+		rec := store.EventRecord{Type: string(ev.Type), Source: ev.Source, Content: eventLogContent(ev), CreatedAt: a.now().UTC()}
+		if err := events.Add(rec); err != nil {
+			a.traceErrorf("event_log_error=%v", err)
+			return
+		}
+		if keep <= 0 {
+			return
+		}
+		if err := events.Prune(keep); err != nil {
+			a.traceErrorf("event_log_prune_error=%v", err)
+		}
+	})
+}
+
+// eventLogContent is what the log keeps of ev: its error, the call for a
+// tool start, the tool name and result for a tool result, and otherwise its
+// text.
+func eventLogContent(ev AgentEvent) string {
+
+	var s string
+	switch {
+	case ev.Error != nil:
+		s = ev.Error.Error()
+	case ev.Type == EventToolStart:
+		s = ev.ToolName + " " + string(ev.Params)
+	case ev.Type == EventToolResult:
+		s = ev.ToolName + ": " + ev.Text
+	default:
+		s = ev.Text
+	}
+	return clipMiddle(s, eventLogContentLimit)
+}
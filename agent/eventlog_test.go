@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+func TestEventLogRecordsGenerationAndToolCall(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{echoCallStream("call0"), textStream("all done")}}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{&echoTool{}}, p)
+	a.SetNoToolSleepRounds(1)
+	a.SetEventLog(s.Events(), 0)
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "echo something"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	events, err := s.Events().Tail(20)
+	if err != nil {
+		t.Fatalf("tail: %v", err)
+	}
+	var types []string
+	for _, ev := range events {
+		types = append(types, ev.Type)
+		if ev.CreatedAt.IsZero() {
+			t.Fatalf("event without a time: %+v", ev)
+		}
+	}
+	if got, want := strings.Join(types, ","), "tool_start,tool_result,response,turn_end"; got != want {
+		t.Fatalf("logged events = %s, want %s", got, want)
+	}
+	if !strings.HasPrefix(events[0].Content, "echo ") || !strings.HasPrefix(events[1].Content, "echo: ") {
+		t.Fatalf("tool events lack the tool name: %+v", events[:2])
+	}
+	if events[2].Content != "all done" || events[2].Source != "api" {
+		t.Fatalf("unexpected response event: %+v", events[2])
+	}
+}
+
+func TestEventLogKeepsNewestRows(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{echoCallStream("call0"), textStream("all done")}}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{&echoTool{}}, p)
+	a.SetNoToolSleepRounds(1)
+	a.SetEventLog(s.Events(), 2)
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "echo something"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	events, err := s.Events().Tail(20)
+	if err != nil {
+		t.Fatalf("tail: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != "response" || events[1].Type != "turn_end" {
+		t.Fatalf("kept events = %+v", events)
+	}
+}
+
+func TestEventLogContentIsClipped(t *testing.T) {
+	got := eventLogContent(AgentEvent{Type: EventResponse, Text: strings.Repeat("x", 3*eventLogContentLimit)})
+	if len(got) > eventLogContentLimit+64 || !strings.Contains(got, "characters omitted") {
+		t.Fatalf("content not clipped: %d bytes", len(got))
+	}
+}
@@ -1,14 +1,51 @@
 package agent
 
+import (
+	"encoding/json"
+
+	"github.com/agusx1211/miclaw/provider"
+)
+
 type AgentEventType string
 
 const (
-	EventError   AgentEventType = "error"
-	EventCompact AgentEventType = "compact"
+	EventError    AgentEventType = "error"
+	EventCompact  AgentEventType = "compact"
+	EventDelta    AgentEventType = "delta"
+	EventResponse AgentEventType = "response"
+	// EventCompaction is published before the thread is compacted
+	// automatically because it neared the context limit.
+	EventCompaction AgentEventType = "compaction"
+	// EventToolStart is published as a tool call starts running, with its
+	// ToolCallID, ToolName and Params.
+	EventToolStart AgentEventType = "tool_start"
+	// EventToolResult is published when a tool call returns, with its
+	// DurationMs and IsError; Text holds the result.
+	EventToolResult AgentEventType = "tool_result"
+	// EventToolOutput carries output a running tool call produced, in Text,
+	// before its EventToolResult; exec publishes it for long commands.
+	EventToolOutput AgentEventType = "tool_output"
+	// EventThinking carries reasoning deltas, coalesced like EventDelta.
+	EventThinking AgentEventType = "thinking"
+	// EventTurnEnd is published when a turn ends, with the inputs it
+	// handled in InputIDs and the error that stopped it, if any.
+	EventTurnEnd AgentEventType = "turn_end"
 )
 
 type AgentEvent struct {
 	Type   AgentEventType
 	Error  error
 	Source string
+	Text   string
+	Usage  *provider.UsageInfo
+	// InputIDs lists the inputs of the current turn on EventResponse and
+	// EventTurnEnd.
+	InputIDs []string
+	// Tool call fields, set on EventToolStart and EventToolResult;
+	// EventToolOutput sets ToolCallID and ToolName.
+	ToolCallID string
+	ToolName   string
+	Params     json.RawMessage
+	DurationMs int64
+	IsError    bool
 }
@@ -2,14 +2,18 @@ package agent
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/agusx1211/miclaw/model"
 	"github.com/agusx1211/miclaw/prompt"
 	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/store"
 	"github.com/agusx1211/miclaw/tooling"
 	"github.com/google/uuid"
 )
@@ -17,27 +21,57 @@ import (
 const threadMessageLimit = 1_000_000
 const traceTextLimit = 180
 
+// ErrProviderAuth is wrapped, along with the backend's own error, by the
+// error that ends a turn because the provider rejected its credentials.
+// Retrying cannot help until the key is fixed.
+var ErrProviderAuth = errors.New("the model provider rejected its API key")
+
 type toolCallState struct {
 	id   string
 	name string
 	args strings.Builder
+	// streamed is the start of a message call's content already delivered
+	// while it streamed; stopped ends the streaming after a failed send.
+	streamed string
+	stopped  bool
 }
 
-func (a *Agent) run(ctx context.Context) error {
-	pending := a.pending.Drain()
-	if len(pending) == 0 {
+// run takes the highest-priority lane of pending inputs and generates until
+// the agent sleeps. Inputs arriving mid-turn join it when their priority is
+// at least the turn's; lower ones wait for the next turn.
+func (a *Agent) run(ctx context.Context) (err error) {
+	lane, ok := a.pending.top()
+	if !ok {
 		return nil
 	}
-	a.tracef("pending=%d", len(pending))
-	if err := a.injectInputs(pending); err != nil {
+	taken := a.pending.drainFrom(lane)
+	a.turnInputs = nil
+	defer func() {
+		a.finishInputs(taken)
+		a.endTurnWaits(taken, err)
+		a.eventBroker.Publish(AgentEvent{Type: EventTurnEnd, Source: a.source, InputIDs: inputIDs(taken), Error: err})
+	}()
+	turnCtx, cancel, timedOut := a.withGenerationTimeout(ctx)
+	defer cancel()
+	return timedOut(a.turn(turnCtx, lane, &taken))
+}
+
+// turn injects *taken and runs generation and tool rounds, appending the
+// inputs it picks up along the way to *taken.
+func (a *Agent) turn(ctx context.Context, lane Priority, taken *[]Input) error {
+	if err := a.rotateIfDue(ctx); err != nil {
+		a.traceErrorf("rotate_error=%v", err)
+	}
+	a.tracef("pending=%d", len(*taken))
+	if err := a.injectInputs(*taken); err != nil {
 		return err
 	}
-	noToolRounds := 0
+	noToolRounds, toolRounds := 0, 0
 	for {
 		if err := ctx.Err(); err != nil {
 			return err
 		}
-		shouldSleep, hadToolCalls, err := a.streamAndHandle(ctx, a.tools)
+		shouldSleep, hadToolCalls, err := a.streamAndHandle(ctx, a.turnTools(*taken), provider.ToolChoiceAuto)
 		if err != nil {
 			return err
 		}
@@ -46,6 +80,11 @@ func (a *Agent) run(ctx context.Context) error {
 		}
 		if hadToolCalls {
 			noToolRounds = 0
+			toolRounds++
+			a.tracef("tool_round=%d max=%d", toolRounds, a.maxToolRounds)
+			if toolRounds >= a.maxToolRounds {
+				return a.finishToolRounds(ctx, toolRounds, a.turnTools(*taken))
+			}
 		} else {
 			noToolRounds++
 			if noToolRounds >= a.noToolSleepRounds {
@@ -53,7 +92,11 @@ func (a *Agent) run(ctx context.Context) error {
 				return nil
 			}
 		}
-		if more := a.pending.Drain(); len(more) > 0 {
+		if a.stopping.Load() {
+			continue
+		}
+		if more := a.pending.drainFrom(lane); len(more) > 0 {
+			*taken = append(*taken, more...)
 			if err := a.injectInputs(more); err != nil {
 				return err
 			}
@@ -61,21 +104,95 @@ func (a *Agent) run(ctx context.Context) error {
 	}
 }
 
+// finishToolRounds ends a turn that hit the tool-round cap. It tells the
+// model why and runs one last generation that must call the message tool, so
+// the user still gets an answer; tools are never run past that round.
+func (a *Agent) finishToolRounds(ctx context.Context, rounds int, toolList []tooling.Tool) error {
+
+	a.tracef("tool_round_limit rounds=%d", rounds)
+	note := newUserMessage(formatInput(Input{
+		Source:  "system",
+		Content: fmt.Sprintf("Tool round limit reached (%d rounds this turn); no other tools will run. Use the message tool to tell the user where things stand.", rounds),
+	}))
+	if err := a.messages.Create(note); err != nil {
+		return err
+	}
+	_, _, err := a.streamAndHandle(ctx, finalRoundTools(toolList), provider.ToolChoice("message"))
+	return err
+}
+
+// finalRoundTools keeps only the message tool, the one way to reach the user.
+func finalRoundTools(toolList []tooling.Tool) []tooling.Tool {
+
+	out := make([]tooling.Tool, 0, 1)
+	for _, t := range toolList {
+		if t.Name() == "message" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
 func (a *Agent) injectInputs(inputs []Input) error {
+	if err := a.pending.setState(inputs, store.QueueRunning); err != nil {
+		return err
+	}
 	for _, input := range inputs {
-		source := strings.TrimSpace(input.Source)
-		if source == "" {
-			source = "unknown"
-		}
-		a.tracef("in source=%s msg=%q", source, compactTraceText(input.Content))
-		msg := newUserMessage(formatInput(input))
-		if err := a.messages.Create(msg); err != nil {
+		a.turnInputs = append(a.turnInputs, input.ID)
+	}
+	if a.coalesceWindow > 0 {
+		inputs = coalesce(inputs)
+	}
+	for _, input := range inputs {
+		if err := a.injectInput(input); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// injectInput stores input as a user message under the input's ID. A
+// restored input whose generation was cut short may already have its
+// message; it is not stored again.
+func (a *Agent) injectInput(input Input) error {
+	source := strings.TrimSpace(input.Source)
+	if source == "" {
+		source = "unknown"
+	}
+	a.tracef("in source=%s msg=%q", source, compactTraceText(input.Content))
+	a.source = source
+	if input.restored {
+		_, err := a.messages.Get(input.ID)
+		if err == nil {
+			a.tracef("in_dedupe id=%s", input.ID)
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+	msg := newUserMessage(formatInput(input))
+	msg.ID = input.ID
+	msg.Metadata = input.Metadata
+	return a.messages.Create(msg)
+}
+
+func inputIDs(inputs []Input) []string {
+
+	ids := make([]string, len(inputs))
+	for i, in := range inputs {
+		ids[i] = in.ID
+	}
+	return ids
+}
+
+// finishInputs marks inputs done once their generation has ended.
+func (a *Agent) finishInputs(inputs []Input) {
+	if err := a.pending.setState(inputs, store.QueueDone); err != nil {
+		a.traceErrorf("queue_error=%v", err)
+	}
+}
+
 func formatInput(input Input) string {
 	source := strings.TrimSpace(input.Source)
 	content := strings.TrimSpace(input.Content)
@@ -101,17 +218,28 @@ func newUserMessage(content string) *Message {
 	return msg
 }
 
-func (a *Agent) streamAndHandle(ctx context.Context, toolList []tooling.Tool) (bool, bool, error) {
+func (a *Agent) streamAndHandle(ctx context.Context, toolList []tooling.Tool, choice provider.ToolChoice) (bool, bool, error) {
 	msgs, err := a.messages.List(threadMessageLimit, 0)
 	if err != nil {
 		return false, false, err
 	}
+	history, err := a.autoCompact(ctx, msgs, a.buildHistory(msgs))
+	if err != nil {
+		return false, false, err
+	}
+	// Stamped after compaction so the reply sorts after the summary that
+	// replaced the thread.
 	assistant := &Message{ID: uuid.NewString(), Role: RoleAssistant, CreatedAt: time.Now().UTC()}
-	history := a.buildHistory(msgs)
-	text, reasoning, calls, _, err := a.collectStream(ctx, history, toProviderDefs(toolList))
+	text, reasoning, calls, usage, err := a.collectCalls(ctx, history, toProviderDefs(toolList), choice)
 	if err != nil {
+		if ctx.Err() != nil {
+			a.storePartial(assistant, text, reasoning)
+		}
 		return false, false, err
 	}
+	if a.toolCallIDs == ToolCallIDsNamespace {
+		calls = namespaceToolCalls(assistant.ID, calls)
+	}
 	if reasoning != "" {
 		a.tracef("think=%q", compactTraceText(reasoning))
 	}
@@ -125,12 +253,18 @@ func (a *Agent) streamAndHandle(ctx context.Context, toolList []tooling.Tool) (b
 	if err := a.messages.Create(assistant); err != nil {
 		return false, false, err
 	}
+	if text != "" {
+		a.eventBroker.Publish(AgentEvent{Type: EventResponse, Source: a.source, Text: text, Usage: usage, InputIDs: a.turnInputs})
+	}
 	if len(calls) == 0 {
 		return false, false, nil
 	}
 	shouldSleep := hasToolCall(calls, "sleep")
 
-	toolMsg, err := runTools(ctx, toolList, calls)
+	toolMsg, err := runTools(ctx, toolList, calls, a.maxParallelTools, a.toolTimeouts, a.toolEvents())
+	if err == nil {
+		a.summarizeToolResults(ctx, calls, toolMsg)
+	}
 	if toolMsg != nil {
 		if err := a.messages.Create(toolMsg); err != nil {
 			return false, true, err
@@ -143,39 +277,128 @@ func (a *Agent) streamAndHandle(ctx context.Context, toolList []tooling.Tool) (b
 	return shouldSleep, true, nil
 }
 
-func (a *Agent) collectStream(ctx context.Context, history []model.Message, defs []provider.ToolDef) (string, string, []ToolCallPart, *provider.UsageInfo, error) {
+// storePartial keeps the text a cancelled generation streamed before it
+// stopped, marked with a "cancelled" FinishPart. It publishes no response
+// event, since the reply never finished.
+func (a *Agent) storePartial(assistant *Message, text, reasoning string) {
+
+	if text == "" && reasoning == "" {
+		return
+	}
+	assistant.Parts = append(buildAssistantParts(text, reasoning, nil), FinishPart{Reason: "cancelled"})
+	if err := a.messages.Create(assistant); err != nil {
+		a.traceErrorf("partial_store_error=%v", err)
+		return
+	}
+	a.tracef("partial chars=%d", len(text))
+}
+
+// replyDeltas returns a coalescer for reply text, or nil when nobody is
+// subscribed to the event broker.
+func (a *Agent) replyDeltas() *deltaCoalescer {
+
+	if !a.eventBroker.HasSubscribers() {
+		return nil
+	}
+	return newDeltaCoalescer(a.eventBroker)
+}
+
+// toolEvents returns the publisher runTools uses for tool events. It is
+// never nil, so the event log sees tool calls even with no subscriber.
+func (a *Agent) toolEvents() func(AgentEvent) {
+
+	return func(ev AgentEvent) {
+		ev.Source = a.source
+		a.eventBroker.Publish(ev)
+	}
+}
+
+// collectStream drains one stream from p, offering defs under choice. When
+// deltas is non-nil, content deltas are also published through it as they
+// arrive, and whatever it still holds is published when the stream ends,
+// even on error or cancellation; reasoning deltas are published the same
+// way as EventThinking.
+// When ctx is cancelled it returns the text and reasoning received so far
+// along with ctx's error.
+func (a *Agent) collectStream(ctx context.Context, p provider.LLMProvider, history []model.Message, defs []provider.ToolDef, choice provider.ToolChoice, deltas *deltaCoalescer) (string, string, []ToolCallPart, *provider.UsageInfo, error) {
 
 	text := &strings.Builder{}
 	reasoning := &strings.Builder{}
 	calls := map[string]*toolCallState{}
 	order := make([]string, 0, 4)
 	var usage *provider.UsageInfo
-	for event := range a.provider.Stream(ctx, history, defs) {
+	stream := a.streamsMessages(defs)
+	var thinking *deltaCoalescer
+	if deltas != nil {
+		defer deltas.flush()
+		thinking = &deltaCoalescer{broker: a.eventBroker, typ: EventThinking}
+		defer thinking.flush()
+	}
+	if err := a.checkBudget(); err != nil {
+		return "", "", nil, nil, err
+	}
+	for event := range p.Stream(ctx, history, defs, choice) {
 		switch event.Type {
 		case provider.EventContentDelta:
 			text.WriteString(event.Delta)
+			if deltas != nil {
+				deltas.add(event.Delta)
+			}
 		case provider.EventThinkingDelta:
 			reasoning.WriteString(event.Delta)
-		case provider.EventToolUseStart:
+			if thinking != nil {
+				thinking.add(event.Delta)
+			}
+		case provider.EventToolUseStart, provider.EventToolUseStop:
 			applyToolEvent(calls, &order, event, false)
 		case provider.EventToolUseDelta:
-			applyToolEvent(calls, &order, event, true)
-		case provider.EventToolUseStop:
-			applyToolEvent(calls, &order, event, false)
+			st := applyToolEvent(calls, &order, event, true)
+			if stream {
+				a.streamParagraphs(ctx, st)
+			}
 		case provider.EventComplete:
-			usage = event.Usage
+			usage = a.completedUsage(p, event)
 		case provider.EventError:
-			return "", "", nil, nil, event.Error
+			err := streamError(ctx, event.Error)
+			if ctx.Err() != nil {
+				return text.String(), reasoning.String(), nil, nil, err
+			}
+			return "", "", nil, nil, err
 		}
 	}
 	if err := ctx.Err(); err != nil {
+		return text.String(), reasoning.String(), nil, nil, err
+	}
+	if err := a.recordCost(usage); err != nil {
 		return "", "", nil, nil, err
 	}
 
 	return text.String(), reasoning.String(), finalizeToolCalls(order, calls), usage, nil
 }
 
-func applyToolEvent(calls map[string]*toolCallState, order *[]string, event provider.ProviderEvent, addDelta bool) {
+func (a *Agent) completedUsage(p provider.LLMProvider, event provider.ProviderEvent) *provider.UsageInfo {
+
+	if event.Backend != "" {
+		a.tracef("served_by backend=%s", event.Backend)
+	}
+	if event.Usage != nil {
+		event.Usage.Cost = p.Model().Cost(*event.Usage)
+	}
+	return event.Usage
+}
+
+func streamError(ctx context.Context, err error) error {
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if provider.IsAuthError(err) {
+		return fmt.Errorf("%w: %w", ErrProviderAuth, err)
+	}
+	return err
+}
+
+func applyToolEvent(calls map[string]*toolCallState, order *[]string, event provider.ProviderEvent, addDelta bool) *toolCallState {
 
 	st := getToolCallState(calls, order, event.ToolCallID)
 	if event.ToolName != "" {
@@ -184,6 +407,7 @@ func applyToolEvent(calls map[string]*toolCallState, order *[]string, event prov
 	if addDelta && event.Delta != "" {
 		st.args.WriteString(event.Delta)
 	}
+	return st
 }
 
 func getToolCallState(calls map[string]*toolCallState, order *[]string, id string) *toolCallState {
@@ -211,7 +435,7 @@ func finalizeToolCalls(order []string, calls map[string]*toolCallState) []ToolCa
 		if raw == "" {
 			raw = "{}"
 		}
-		out = append(out, ToolCallPart{ID: state.id, Name: state.name, Parameters: json.RawMessage(raw)})
+		out = append(out, ToolCallPart{ID: state.id, Name: state.name, Parameters: json.RawMessage(raw), Streamed: state.streamed})
 	}
 	return out
 }
@@ -231,30 +455,75 @@ func buildAssistantParts(text, reasoning string, calls []ToolCallPart) []Message
 	return parts
 }
 
-func runTools(ctx context.Context, toolList []tooling.Tool, calls []ToolCallPart) (*Message, error) {
-
-	parts := make([]MessagePart, 0, len(calls))
-	for i, call := range calls {
-		if err := ctx.Err(); err != nil {
-			parts = appendCancelled(parts, calls[i:])
-			return newToolMessage(parts), err
+// runTools runs calls, up to workers at a time, and returns their results in
+// call order. A call of a serial tool runs alone: it waits for running calls
+// to finish and later calls wait for it. Once ctx is cancelled, calls not yet
+// started and calls that were still running get a "Cancelled" result. When
+// publish is non-nil it gets an EventToolStart and an EventToolResult for
+// every call that runs.
+func runTools(ctx context.Context, toolList []tooling.Tool, calls []ToolCallPart, workers int, timeouts ToolTimeouts, publish func(AgentEvent)) (*Message, error) {
+
+	parts := make([]MessagePart, len(calls))
+	sem := make(chan struct{}, max(workers, 1))
+	var wg sync.WaitGroup
+	run := func(i int) {
+		defer func() { <-sem }()
+		call := calls[i]
+		begin := time.Now()
+		if publish != nil {
+			publish(AgentEvent{Type: EventToolStart, ToolCallID: call.ID, ToolName: call.Name, Params: call.Parameters})
 		}
-		result := runTool(ctx, toolList, call)
-		if err := ctx.Err(); err != nil {
-			parts = append(parts, cancelledPart(call))
-			parts = appendCancelled(parts, calls[i+1:])
-			return newToolMessage(parts), err
+		result := runTool(ctx, toolList, call, timeouts)
+		if ctx.Err() != nil {
+			result = cancelledPart(call)
+		}
+		parts[i] = result
+		if publish != nil {
+			publish(AgentEvent{
+				Type:       EventToolResult,
+				Text:       result.Content,
+				ToolCallID: call.ID,
+				ToolName:   call.Name,
+				Params:     call.Parameters,
+				DurationMs: time.Since(begin).Milliseconds(),
+				IsError:    result.IsError,
+			})
+		}
+	}
+	started := 0
+	for ; started < len(calls); started++ {
+		serial := workers <= 1 || isSerialCall(toolList, calls[started])
+		if serial {
+			wg.Wait()
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
 		}
-		parts = append(parts, result)
+		if serial {
+			run(started)
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			run(i)
+		}(started)
 	}
-	return newToolMessage(parts), nil
+	wg.Wait()
+	for i := started; i < len(calls); i++ {
+		parts[i] = cancelledPart(calls[i])
+	}
+	return newToolMessage(parts), ctx.Err()
 }
 
-func appendCancelled(parts []MessagePart, calls []ToolCallPart) []MessagePart {
-	for _, call := range calls {
-		parts = append(parts, cancelledPart(call))
-	}
-	return parts
+func isSerialCall(toolList []tooling.Tool, call ToolCallPart) bool {
+
+	tool := findTool(toolList, call.Name)
+	return tool != nil && tooling.IsSerial(tool)
 }
 
 func cancelledPart(call ToolCallPart) ToolResultPart {
@@ -265,12 +534,17 @@ func newToolMessage(parts []MessagePart) *Message {
 	return &Message{ID: uuid.NewString(), Role: RoleTool, Parts: parts, CreatedAt: time.Now().UTC()}
 }
 
-func runTool(ctx context.Context, toolList []tooling.Tool, call ToolCallPart) ToolResultPart {
+func runTool(ctx context.Context, toolList []tooling.Tool, call ToolCallPart, timeouts ToolTimeouts) ToolResultPart {
 
 	tool := findTool(toolList, call.Name)
 	if tool == nil {
 		return ToolResultPart{ToolCallID: call.ID, Content: fmt.Sprintf("tool not found: %s", call.Name), IsError: true}
 	}
+	return runWithTimeout(ctx, tool, call, timeouts.limit(call.Name))
+}
+
+func callTool(ctx context.Context, tool tooling.Tool, call ToolCallPart) ToolResultPart {
+
 	result, err := tool.Run(ctx, model.ToolCallPart(call))
 	if err != nil {
 		return ToolResultPart{ToolCallID: call.ID, Content: err.Error(), IsError: true}
@@ -347,20 +621,26 @@ func compactTraceText(raw string) string {
 
 func (a *Agent) buildHistory(messages []*Message) []model.Message {
 
-	out := []model.Message{a.systemMessage()}
+	out := []model.Message{a.systemMessage(latestUserText(messages))}
 	return append(out, flattenMessages(messages)...)
 }
 
-func (a *Agent) systemMessage() model.Message {
+// systemMessage builds the system prompt; latest is the newest user input,
+// used to pick the glossary entries worth injecting.
+func (a *Agent) systemMessage(latest string) model.Message {
 
 	mode := a.promptMode
 	if mode == "" {
 		mode = "full"
 	}
+	a.mu.Lock()
+	glossary := prompt.MatchGlossary(a.glossary, latest)
+	a.mu.Unlock()
 	txt := prompt.BuildSystemPrompt(prompt.SystemPromptParams{
 		Mode:         mode,
 		Workspace:    a.workspace,
 		Skills:       a.skills,
+		Glossary:     glossary,
 		MemoryRecall: a.memory,
 		DateTime:     time.Now().UTC(),
 		Heartbeat:    a.heartbeat,
@@ -376,6 +656,21 @@ func (a *Agent) systemMessage() model.Message {
 	return msg
 }
 
+func latestUserText(messages []*Message) string {
+
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != RoleUser {
+			continue
+		}
+		for _, part := range messages[i].Parts {
+			if text, ok := part.(TextPart); ok {
+				return text.Text
+			}
+		}
+	}
+	return ""
+}
+
 func flattenMessages(messages []*Message) []model.Message {
 
 	out := make([]model.Message, 0, len(messages))
@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/prompt"
 	"github.com/agusx1211/miclaw/provider"
 	"github.com/agusx1211/miclaw/store"
 	"github.com/agusx1211/miclaw/tooling"
@@ -20,17 +22,19 @@ type scriptedProvider struct {
 	streams      []streamScript
 	seenMessages [][]model.Message
 	seenTools    [][]provider.ToolDef
+	seenChoices  []provider.ToolChoice
 	model        provider.ModelInfo
 }
 
 type streamScript func(context.Context, []model.Message, []provider.ToolDef) <-chan provider.ProviderEvent
 
-func (p *scriptedProvider) Stream(ctx context.Context, msgs []model.Message, defs []provider.ToolDef) <-chan provider.ProviderEvent {
+func (p *scriptedProvider) Stream(ctx context.Context, msgs []model.Message, defs []provider.ToolDef, choice provider.ToolChoice) <-chan provider.ProviderEvent {
 	p.mu.Lock()
 	idx := p.calls
 	p.calls++
 	p.seenMessages = append(p.seenMessages, append([]model.Message(nil), msgs...))
 	p.seenTools = append(p.seenTools, append([]provider.ToolDef(nil), defs...))
+	p.seenChoices = append(p.seenChoices, choice)
 	script := p.streams[idx]
 	p.mu.Unlock()
 	return script(ctx, msgs, defs)
@@ -229,6 +233,25 @@ func TestRunStoresPrefixedInputAndAssistantReply(t *testing.T) {
 	}
 }
 
+func TestRunStoresInputMetadataOnUserMessage(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{textStream("hi Alice")}}
+	a := NewAgent(s.MessageStore(), nil, p)
+	a.SetNoToolSleepRounds(1)
+
+	meta := map[string]string{"source_name": "Alice", "group_id": "g1"}
+	if err := a.RunOnce(context.Background(), Input{Source: "signal:group:g1", Content: "hi", Metadata: meta}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	msgs := listMessages(t, s)
+	if msgs[0].Role != model.RoleUser || msgs[0].Metadata["source_name"] != "Alice" || msgs[0].Metadata["group_id"] != "g1" {
+		t.Fatalf("user message metadata not stored: %#v", msgs[0])
+	}
+	if msgs[1].Metadata != nil {
+		t.Fatalf("assistant message got metadata: %#v", msgs[1].Metadata)
+	}
+}
+
 func TestRunInjectsNewInputBetweenToolRounds(t *testing.T) {
 	s := openAgentStore(t)
 	p := &scriptedProvider{
@@ -353,6 +376,73 @@ func TestRunOnceCancellation(t *testing.T) {
 	}
 }
 
+func TestCancelledGenerationStoresPartialReply(t *testing.T) {
+	s := openAgentStore(t)
+	streamed := make(chan struct{})
+	p := &scriptedProvider{streams: []streamScript{func(ctx context.Context, _ []model.Message, _ []provider.ToolDef) <-chan provider.ProviderEvent {
+		ch := make(chan provider.ProviderEvent, 2)
+		go func() {
+			defer close(ch)
+			ch <- provider.ProviderEvent{Type: provider.EventContentDelta, Delta: "The disk is at "}
+			ch <- provider.ProviderEvent{Type: provider.EventContentDelta, Delta: "42%"}
+			close(streamed)
+			<-ctx.Done()
+			ch <- provider.ProviderEvent{Type: provider.EventError, Error: ctx.Err()}
+		}()
+		return ch
+	}}}
+	a := NewAgent(s.MessageStore(), nil, p)
+	events, unsub := a.Events().Subscribe()
+	defer unsub()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-streamed
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	if err := a.RunOnce(ctx, Input{Source: "api", Content: "disk?"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+	msgs := listMessages(t, s)
+	if len(msgs) != 2 || msgs[1].Role != model.RoleAssistant {
+		t.Fatalf("partial reply not stored: %#v", msgs)
+	}
+	if len(msgs[1].Parts) != 2 || textPart(msgs[1]) != "The disk is at 42%" {
+		t.Fatalf("unexpected partial parts: %#v", msgs[1].Parts)
+	}
+	if fin, ok := msgs[1].Parts[1].(model.FinishPart); !ok || fin.Reason != "cancelled" {
+		t.Fatalf("partial reply not marked cancelled: %#v", msgs[1].Parts[1])
+	}
+	for len(events) > 0 {
+		if ev := <-events; ev.Type == EventResponse {
+			t.Fatalf("response event published for a partial reply: %#v", ev)
+		}
+	}
+}
+
+func TestCancelledGenerationWithoutTextStoresNothing(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{func(ctx context.Context, _ []model.Message, _ []provider.ToolDef) <-chan provider.ProviderEvent {
+		ch := make(chan provider.ProviderEvent)
+		go func() {
+			defer close(ch)
+			<-ctx.Done()
+		}()
+		return ch
+	}}}
+	a := NewAgent(s.MessageStore(), nil, p)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := a.RunOnce(ctx, Input{Source: "api", Content: "disk?"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if msgs := listMessages(t, s); len(msgs) != 1 {
+		t.Fatalf("expected only the input, got %d messages", len(msgs))
+	}
+}
+
 func TestRunToolsCancellationMarksRemaining(t *testing.T) {
 	tool := &cancellationTool{started: make(chan struct{})}
 	calls := []ToolCallPart{
@@ -365,7 +455,7 @@ func TestRunToolsCancellationMarksRemaining(t *testing.T) {
 		<-tool.started
 		cancel()
 	}()
-	msg, err := runTools(ctx, []tooling.Tool{tool}, calls)
+	msg, err := runTools(ctx, []tooling.Tool{tool}, calls, 1, ToolTimeouts{}, nil)
 	if !errors.Is(err, context.Canceled) {
 		t.Fatalf("expected context canceled, got %v", err)
 	}
@@ -388,3 +478,70 @@ func TestRunToolsCancellationMarksRemaining(t *testing.T) {
 		t.Fatalf("unexpected third part: %#v", p3)
 	}
 }
+
+func TestRunPublishesResponseEvent(t *testing.T) {
+	s := openAgentStore(t)
+	usage := &provider.UsageInfo{PromptTokens: 5, CompletionTokens: 2}
+	p := &scriptedProvider{
+		streams: []streamScript{
+			eventStream(
+				provider.ProviderEvent{Type: provider.EventContentDelta, Delta: "done"},
+				provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "call-sleep", ToolName: "sleep"},
+				provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: "call-sleep"},
+				provider.ProviderEvent{Type: provider.EventComplete, Usage: usage},
+			),
+		},
+		model: provider.ModelInfo{CostPerInputToken: 0.5, CostPerOutputToken: 2},
+	}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{&sleepTool{}}, p)
+	events, unsub := a.Events().Subscribe()
+	defer unsub()
+
+	if err := a.RunOnce(context.Background(), Input{Source: "webhook:ci", Content: "build"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	for len(events) > 0 {
+		ev := <-events
+		if ev.Type != EventResponse {
+			continue
+		}
+		if ev.Source != "webhook:ci" || ev.Text != "done" || ev.Usage != usage || ev.Usage.Cost != 6.5 {
+			t.Fatalf("unexpected response event: %#v", ev)
+		}
+		return
+	}
+	t.Fatal("no response event published")
+}
+
+func TestRunInjectsGlossaryOnlyForMatchingInput(t *testing.T) {
+	sleepRound := eventStream(
+		provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "call-sleep", ToolName: "sleep"},
+		provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: "call-sleep"},
+		provider.ProviderEvent{Type: provider.EventComplete},
+	)
+	p := &scriptedProvider{streams: []streamScript{sleepRound, sleepRound, sleepRound}}
+	a := NewAgent(openAgentStore(t).MessageStore(), []tooling.Tool{&sleepTool{}}, p)
+	a.SetGlossary([]prompt.GlossaryEntry{{Term: "expediente", Rendering: "case file"}})
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "hola"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "mirá el expediente"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	a.AddGlossaryEntry(prompt.GlossaryEntry{Term: "ANSES", Rendering: "social security agency"})
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "llamó ANSES"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+
+	system := func(i int) string { return p.seenMessages[i][0].Parts[0].(model.TextPart).Text }
+	if strings.Contains(system(0), "## Glossary") {
+		t.Fatalf("glossary injected without a matching term:\n%s", system(0))
+	}
+	if !strings.Contains(system(1), "- expediente → case file") {
+		t.Fatalf("matched term missing from prompt:\n%s", system(1))
+	}
+	if !strings.Contains(system(2), "- ANSES → social security agency") || strings.Contains(system(2), "expediente →") {
+		t.Fatalf("want only the added entry:\n%s", system(2))
+	}
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/agusx1211/miclaw/provider"
+)
+
+// streamsMessages reports whether message calls offered in defs are
+// delivered while they stream.
+func (a *Agent) streamsMessages(defs []provider.ToolDef) bool {
+
+	return a.streamMessage != nil && slices.ContainsFunc(defs, func(d provider.ToolDef) bool { return d.Name == "message" })
+}
+
+// streamParagraphs sends the paragraphs (text ending in a blank line) that
+// st's content completed since the last delta, once st is a message call
+// whose target is known. The tail of the content is left to the tool, so a
+// call cut off mid-stream never delivers half a paragraph.
+func (a *Agent) streamParagraphs(ctx context.Context, st *toolCallState) {
+
+	if st.name != "message" || st.stopped {
+		return
+	}
+	to, content, ok := partialMessage(st.args.String())
+	if !ok {
+		return
+	}
+	for {
+		i := strings.Index(content[len(st.streamed):], "\n\n")
+		if i < 0 {
+			return
+		}
+		end := len(st.streamed) + i
+		if para := strings.TrimSpace(content[len(st.streamed):end]); para != "" {
+			if err := a.streamMessage(ctx, to, para); err != nil {
+				a.tracef("message_stream_stopped to=%s err=%v", to, err)
+				st.stopped = true
+				return
+			}
+		}
+		st.streamed = content[:end+2]
+	}
+}
+
+// partialMessage decodes the target and the content so far from message call
+// arguments that may still be streaming. ok is false unless the arguments are
+// complete or end inside the content string after a complete target, as they
+// do while a model that writes "to" first is generating the content.
+func partialMessage(args string) (to, content string, ok bool) {
+
+	var v struct{ To, Content string }
+	if json.Unmarshal([]byte(args), &v) == nil {
+		return v.To, v.Content, v.To != ""
+	}
+	if json.Unmarshal([]byte(args+`\u0000"}`), &v) != nil {
+		return "", "", false
+	}
+	content, ok = strings.CutSuffix(v.Content, "\x00")
+	return v.To, content, ok && v.To != ""
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+type messageStub struct{ *echoTool }
+
+func (messageStub) Name() string { return "message" }
+
+func messageCallStream(deltas ...string) streamScript {
+	events := []provider.ProviderEvent{
+		{Type: provider.EventContentDelta, Delta: "Planning the answer.\n\n"},
+		{Type: provider.EventToolUseStart, ToolCallID: "m", ToolName: "message"},
+	}
+	for _, d := range deltas {
+		events = append(events, provider.ProviderEvent{Type: provider.EventToolUseDelta, ToolCallID: "m", Delta: d})
+	}
+	return eventStream(append(events, provider.ProviderEvent{Type: provider.EventComplete})...)
+}
+
+func TestMessageStreamDeliversParagraphsWhileGenerating(t *testing.T) {
+	s := openAgentStore(t)
+	var mu sync.Mutex
+	var sent []string
+	during := -1
+	p := &scriptedProvider{streams: []streamScript{
+		func(context.Context, []model.Message, []provider.ToolDef) <-chan provider.ProviderEvent {
+			ch := make(chan provider.ProviderEvent)
+			go func() {
+				defer close(ch)
+				ch <- provider.ProviderEvent{Type: provider.EventContentDelta, Delta: "Planning the answer.\n\n"}
+				ch <- provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "m", ToolName: "message"}
+				for _, d := range []string{`{"to":"signal:dm:u1","content":"First para`, `graph.\n\nSecond`, ` one.\n\nTa`, `il"}`} {
+					ch <- provider.ProviderEvent{Type: provider.EventToolUseDelta, ToolCallID: "m", Delta: d}
+				}
+				mu.Lock()
+				during = len(sent)
+				mu.Unlock()
+				ch <- provider.ProviderEvent{Type: provider.EventComplete}
+			}()
+			return ch
+		},
+		reusedIDStream("sleep", `{}`),
+	}}
+	msg := &echoTool{}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{messageStub{msg}, &sleepTool{}}, p)
+	a.SetMessageStream(func(_ context.Context, to, text string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, to+" "+text)
+		return nil
+	})
+
+	if err := a.RunOnce(context.Background(), Input{Source: "signal:dm:u1", Content: "go"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	want := []string{"signal:dm:u1 First paragraph.", "signal:dm:u1 Second one."}
+	if !reflect.DeepEqual(sent, want) || during != 2 {
+		t.Fatalf("streamed %q (%d before the stream ended), want %q", sent, during, want)
+	}
+	calls := msg.Calls()
+	if len(calls) != 1 || calls[0].Streamed != "First paragraph.\n\nSecond one.\n\n" || !strings.Contains(string(calls[0].Parameters), "Tail") {
+		t.Fatalf("unexpected message call: %+v", calls)
+	}
+}
+
+func TestMessageStreamStopsAfterSendError(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{
+		messageCallStream(`{"to":"webhook:ci","content":"One.\n\nTwo.\n\n`, `Three."}`),
+		reusedIDStream("sleep", `{}`),
+	}}
+	msg := &echoTool{}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{messageStub{msg}, &sleepTool{}}, p)
+	sends := 0
+	a.SetMessageStream(func(context.Context, string, string) error {
+		sends++
+		return errors.New("not streamed")
+	})
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "go"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if calls := msg.Calls(); sends != 1 || len(calls) != 1 || calls[0].Streamed != "" {
+		t.Fatalf("want one failed send and the whole content left to the tool, sends=%d calls=%+v", sends, calls)
+	}
+}
+
+func TestMessageStreamNeedsTheMessageTool(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{
+		messageCallStream(`{"to":"signal:dm:u1","content":"One.\n\nTwo."}`),
+		reusedIDStream("sleep", `{}`),
+	}}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{&sleepTool{}}, p)
+	a.SetMessageStream(func(context.Context, string, string) error {
+		t.Fatal("streamed a call to a tool that was not offered")
+		return nil
+	})
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "go"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+}
+
+func TestPartialMessage(t *testing.T) {
+	cases := []struct {
+		args, to, content string
+		ok                bool
+	}{
+		{`{"to":"signal:dm:u1","content":"a\n\nb`, "signal:dm:u1", "a\n\nb", true},
+		{`{"to":"signal:dm:u1","content":"say \"hi`, "signal:dm:u1", `say "hi`, true},
+		{`{"to":"sig`, "", "", false},
+		{`{"content":"a","to":"sig`, "", "", false},
+		{`{"to":"x","content":"a\u00`, "", "", false},
+		{`{"to":"x","content":"done"}`, "x", "done", true},
+	}
+	for _, tc := range cases {
+		to, content, ok := partialMessage(tc.args)
+		if ok != tc.ok || (ok && (to != tc.to || content != tc.content)) {
+			t.Fatalf("partialMessage(%q) = %q, %q, %v", tc.args, to, content, ok)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+// trackedTool records how many of its calls run at once. Calls wait on
+// release, when set, before returning their call ID.
+type trackedTool struct {
+	name    string
+	serial  bool
+	release chan struct{}
+	running atomic.Int32
+	peak    atomic.Int32
+	runs    atomic.Int32
+	mu      sync.Mutex
+	overlap bool
+	others  *atomic.Int32
+}
+
+func (t *trackedTool) Name() string { return t.name }
+
+func (t *trackedTool) Description() string { return "tracked tool" }
+
+func (t *trackedTool) Parameters() tooling.JSONSchema { return tooling.JSONSchema{Type: "object"} }
+
+func (t *trackedTool) Serial() bool { return t.serial }
+
+func (t *trackedTool) Run(ctx context.Context, call model.ToolCallPart) (tooling.ToolResult, error) {
+	t.runs.Add(1)
+	n := t.running.Add(1)
+	defer t.running.Add(-1)
+	t.others.Add(1)
+	defer t.others.Add(-1)
+	if t.serial && t.others.Load() > 1 {
+		t.mu.Lock()
+		t.overlap = true
+		t.mu.Unlock()
+	}
+	for p := t.peak.Load(); n > p && !t.peak.CompareAndSwap(p, n); p = t.peak.Load() {
+	}
+	if t.release != nil {
+		select {
+		case <-t.release:
+		case <-ctx.Done():
+			return tooling.ToolResult{}, ctx.Err()
+		}
+	} else {
+		time.Sleep(5 * time.Millisecond)
+	}
+	return tooling.ToolResult{Content: call.ID}, nil
+}
+
+func TestRunToolsRunsCallsInParallelInOrder(t *testing.T) {
+	var active atomic.Int32
+	read := &trackedTool{name: "read", release: make(chan struct{}), others: &active}
+	calls := []ToolCallPart{{ID: "c1", Name: "read"}, {ID: "c2", Name: "read"}, {ID: "c3", Name: "read"}}
+	go func() {
+		for read.running.Load() < 3 {
+			time.Sleep(time.Millisecond)
+		}
+		close(read.release)
+	}()
+	done := make(chan *Message, 1)
+	go func() {
+		msg, err := runTools(context.Background(), []tooling.Tool{read}, calls, 4, ToolTimeouts{}, nil)
+		if err != nil {
+			t.Errorf("run tools: %v", err)
+		}
+		done <- msg
+	}()
+	select {
+	case msg := <-done:
+		for i, part := range msg.Parts {
+			if got := part.(model.ToolResultPart); got.ToolCallID != calls[i].ID || got.Content != calls[i].ID {
+				t.Fatalf("part %d = %#v, want result of %s", i, got, calls[i].ID)
+			}
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("calls did not run concurrently")
+	}
+}
+
+func TestRunToolsHonoursWorkerLimitAndSerialTools(t *testing.T) {
+	var active atomic.Int32
+	read := &trackedTool{name: "read", others: &active}
+	write := &trackedTool{name: "write", serial: true, others: &active}
+	calls := []ToolCallPart{
+		{ID: "c1", Name: "read"}, {ID: "c2", Name: "read"}, {ID: "c3", Name: "read"},
+		{ID: "c4", Name: "write"}, {ID: "c5", Name: "read"}, {ID: "c6", Name: "write"},
+	}
+	msg, err := runTools(context.Background(), []tooling.Tool{read, write}, calls, 2, ToolTimeouts{}, nil)
+	if err != nil {
+		t.Fatalf("run tools: %v", err)
+	}
+	if read.peak.Load() > 2 {
+		t.Fatalf("read peaked at %d concurrent calls, limit 2", read.peak.Load())
+	}
+	if write.overlap {
+		t.Fatal("serial tool overlapped with another call")
+	}
+	for i, part := range msg.Parts {
+		if part.(model.ToolResultPart).Content != calls[i].ID {
+			t.Fatalf("results out of order: %#v", msg.Parts)
+		}
+	}
+}
+
+func TestRunToolsParallelCancellation(t *testing.T) {
+	var active atomic.Int32
+	slow := &trackedTool{name: "slow", release: make(chan struct{}), others: &active}
+	calls := []ToolCallPart{{ID: "c1", Name: "slow"}, {ID: "c2", Name: "slow"}, {ID: "c3", Name: "slow"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for slow.running.Load() < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	msg, err := runTools(ctx, []tooling.Tool{slow}, calls, 2, ToolTimeouts{}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+	if slow.runs.Load() != 2 {
+		t.Fatalf("unstarted call ran: %d runs", slow.runs.Load())
+	}
+	for i, part := range msg.Parts {
+		got := part.(model.ToolResultPart)
+		if got.ToolCallID != calls[i].ID || got.Content != "Cancelled" || !got.IsError {
+			t.Fatalf("part %d = %#v, want Cancelled", i, got)
+		}
+	}
+}
@@ -1,36 +1,246 @@
 package agent
 
-import "sync"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agusx1211/miclaw/store"
+	"github.com/google/uuid"
+)
+
+// ErrQueueFull is returned when accepting an input would exceed the queue
+// limits. Callers match it with errors.Is.
+var ErrQueueFull = errors.New("input queue is full")
+
+// Priority is the queue lane of an input. A turn starts with the inputs of
+// the highest waiting lane; inputs of one lane keep their arrival order.
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+	// PrioritySteer is above every other lane, so a running turn takes a
+	// steering input whatever lane it started from. See Agent.Steer.
+	PrioritySteer Priority = 2
+)
 
 type Input struct {
+	// ID identifies the input across restarts and becomes the ID of the user
+	// message it produces. Push assigns one when it is empty.
+	ID       string
 	Source   string
 	Content  string
 	Metadata map[string]string
+	Priority Priority
+	// restored marks an input loaded from the store after a restart.
+	restored bool
+}
+
+// QueueLimits bounds the pending queue. Zero values mean no limit. Sources
+// overrides MaxPerSource for sources starting with a key; the longest key
+// wins and its limit counts every pending input sharing that prefix.
+type QueueLimits struct {
+	MaxDepth     int
+	MaxPerSource int
+	Sources      map[string]int
 }
 
 type InputQueue struct {
-	mu    sync.Mutex
-	items []Input
+	mu     sync.Mutex
+	items  []Input
+	limits QueueLimits
+	// store persists accepted inputs; nil keeps the queue in memory only.
+	store *store.QueueStore
+}
+
+func (q *InputQueue) SetLimits(limits QueueLimits) {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.limits = limits
+}
+
+func (q *InputQueue) SetStore(s *store.QueueStore) {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.store = s
 }
 
-func (q *InputQueue) Push(input Input) {
+func (q *InputQueue) Push(input Input) error {
 
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	if err := q.admit(input.Source); err != nil {
+		return err
+	}
+	if input.ID == "" {
+		input.ID = uuid.NewString()
+	}
+	if q.store != nil {
+		err := q.store.Add(store.QueuedInput{
+			ID:        input.ID,
+			Session:   input.Metadata["session_id"],
+			Source:    input.Source,
+			Content:   input.Content,
+			Metadata:  input.Metadata,
+			CreatedAt: time.Now().UTC(),
+			Priority:  int(input.Priority),
+		})
+		if err != nil {
+			return fmt.Errorf("persist input: %w", err)
+		}
+	}
 	q.items = append(q.items, input)
+	return nil
+}
+
+// restore appends the persisted inputs that were never finished, oldest
+// first, without applying the limits again. It returns how many it queued.
+func (q *InputQueue) restore() (int, error) {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.store == nil {
+		return 0, nil
+	}
+	saved, err := q.store.Unfinished()
+	if err != nil {
+		return 0, err
+	}
+	for _, in := range saved {
+		q.items = append(q.items, Input{ID: in.ID, Source: in.Source, Content: in.Content, Metadata: in.Metadata, Priority: Priority(in.Priority), restored: true})
+	}
+	return len(saved), nil
+}
+
+// setState records the state of inputs in the store, if there is one.
+func (q *InputQueue) setState(inputs []Input, state string) error {
+
+	q.mu.Lock()
+	s := q.store
+	q.mu.Unlock()
+	if s == nil {
+		return nil
+	}
+	ids := make([]string, len(inputs))
+	for i, in := range inputs {
+		ids[i] = in.ID
+	}
+	return s.SetState(ids, state)
+}
+
+func (q *InputQueue) admit(source string) error {
+
+	if q.limits.MaxDepth > 0 && len(q.items) >= q.limits.MaxDepth {
+		return fmt.Errorf("%w: %d pending, max_depth %d", ErrQueueFull, len(q.items), q.limits.MaxDepth)
+	}
+	key, limit := q.limits.sourceLimit(source)
+	if limit <= 0 {
+		return nil
+	}
+	n := 0
+	for _, item := range q.items {
+		if item.Source == source || (key != "" && strings.HasPrefix(item.Source, key)) {
+			n++
+		}
+	}
+	if n >= limit {
+		return fmt.Errorf("%w: %d pending from %s, limit %d", ErrQueueFull, n, source, limit)
+	}
+	return nil
+}
+
+// sourceLimit returns the matching Sources key ("" when none) and the limit
+// that applies to source.
+func (l QueueLimits) sourceLimit(source string) (string, int) {
+
+	key, limit := "", l.MaxPerSource
+	for k, v := range l.Sources {
+		if strings.HasPrefix(source, k) && len(k) > len(key) {
+			key, limit = k, v
+		}
+	}
+	return key, limit
 }
 
+// Drain takes every pending input, highest priority first.
 func (q *InputQueue) Drain() []Input {
 
+	return q.drainFrom(math.MinInt)
+}
+
+// drainFrom takes the pending inputs of priority min or higher, highest
+// first, and leaves the rest queued.
+func (q *InputQueue) drainFrom(min Priority) []Input {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []Input
+	kept := q.items[:0]
+	for _, in := range q.items {
+		if in.Priority >= min {
+			out = append(out, in)
+		} else {
+			kept = append(kept, in)
+		}
+	}
+	clear(q.items[len(kept):])
+	q.items = kept
+	slices.SortStableFunc(out, func(a, b Input) int { return int(b.Priority) - int(a.Priority) })
+
+	return out
+}
+
+// top returns the highest priority among pending inputs; ok is false when
+// the queue is empty.
+func (q *InputQueue) top() (p Priority, ok bool) {
+
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	out := make([]Input, len(q.items))
-	copy(out, q.items)
-	q.items = q.items[:0]
+	for i, in := range q.items {
+		if i == 0 || in.Priority > p {
+			p = in.Priority
+		}
+	}
+	return p, len(q.items) > 0
+}
 
+// coalesce merges the inputs of each sender into one, joining their contents
+// with newlines. A sender is the source plus the source_uuid metadata, so
+// messages from different members of one group stay apart. Merged inputs
+// keep the position, ID and metadata of their sender's first input.
+func coalesce(inputs []Input) []Input {
+
+	out := make([]Input, 0, len(inputs))
+	at := map[string]int{}
+	for _, in := range inputs {
+		key := in.Source + "\x00" + in.Metadata["source_uuid"]
+		i, ok := at[key]
+		if !ok {
+			at[key] = len(out)
+			out = append(out, in)
+			continue
+		}
+		out[i].Content += "\n" + in.Content
+	}
 	return out
 }
 
+// snapshot returns a copy of the pending inputs in arrival order.
+func (q *InputQueue) snapshot() []Input {
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.items)
+}
+
 func (q *InputQueue) Len() int {
 
 	q.mu.Lock()
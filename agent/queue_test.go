@@ -1,8 +1,19 @@
 package agent
 
 import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/store"
+	"github.com/agusx1211/miclaw/tooling"
 )
 
 func TestInputQueueDrainOrder(t *testing.T) {
@@ -19,6 +30,32 @@ func TestInputQueueDrainOrder(t *testing.T) {
 	}
 }
 
+func TestInputQueueDrainsByPriorityKeepingArrivalOrder(t *testing.T) {
+	q := &InputQueue{}
+	q.Push(Input{Source: "cron", Content: "tick", Priority: PriorityLow})
+	q.Push(Input{Source: "webhook:ci", Content: "one"})
+	q.Push(Input{Source: "signal:dm:+1", Content: "hi", Priority: PriorityHigh})
+	q.Push(Input{Source: "webhook:ci", Content: "two"})
+	q.Push(Input{Source: "signal:dm:+2", Content: "hey", Priority: PriorityHigh})
+
+	if p, ok := q.top(); !ok || p != PriorityHigh {
+		t.Fatalf("top = %v %v, want high", p, ok)
+	}
+	var got []string
+	for _, in := range q.drainFrom(PriorityNormal) {
+		got = append(got, in.Content)
+	}
+	if want := []string{"hi", "hey", "one", "two"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("drained %q, want %q", got, want)
+	}
+	if rest := q.Drain(); len(rest) != 1 || rest[0].Content != "tick" {
+		t.Fatalf("low input not left queued: %#v", rest)
+	}
+	if _, ok := q.top(); ok {
+		t.Fatal("top reported an empty queue as non-empty")
+	}
+}
+
 func TestInputQueueDrainClearsQueue(t *testing.T) {
 	q := &InputQueue{}
 	q.Push(Input{Source: "a", Content: "one"})
@@ -52,3 +89,331 @@ func TestInputQueueConcurrentPushAndDrain(t *testing.T) {
 		t.Fatalf("expected 800 items, got %d", len(items))
 	}
 }
+
+func TestInputQueueRejectsPastMaxDepth(t *testing.T) {
+	q := &InputQueue{}
+	q.SetLimits(QueueLimits{MaxDepth: 2})
+	for _, src := range []string{"a", "b"} {
+		if err := q.Push(Input{Source: src}); err != nil {
+			t.Fatalf("push %s: %v", src, err)
+		}
+	}
+	if err := q.Push(Input{Source: "c"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("want ErrQueueFull, got %v", err)
+	}
+	q.Drain()
+	if err := q.Push(Input{Source: "c"}); err != nil {
+		t.Fatalf("push after drain: %v", err)
+	}
+}
+
+func TestInputQueueRejectsPastPerSourceLimit(t *testing.T) {
+	q := &InputQueue{}
+	q.SetLimits(QueueLimits{MaxDepth: 10, MaxPerSource: 1})
+	if err := q.Push(Input{Source: "signal:dm:a"}); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if err := q.Push(Input{Source: "signal:dm:a"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("want ErrQueueFull, got %v", err)
+	}
+	if err := q.Push(Input{Source: "signal:dm:b"}); err != nil {
+		t.Fatalf("other source rejected: %v", err)
+	}
+}
+
+func TestInputQueueSourceOverrideCountsPrefix(t *testing.T) {
+	q := &InputQueue{}
+	q.SetLimits(QueueLimits{MaxDepth: 10, MaxPerSource: 5, Sources: map[string]int{"webhook:": 2, "webhook:vip": 4}})
+	for _, src := range []string{"webhook:a", "webhook:b"} {
+		if err := q.Push(Input{Source: src}); err != nil {
+			t.Fatalf("push %s: %v", src, err)
+		}
+	}
+	if err := q.Push(Input{Source: "webhook:c"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("want prefix limit, got %v", err)
+	}
+	if err := q.Push(Input{Source: "webhook:vip"}); err != nil {
+		t.Fatalf("longer key should win: %v", err)
+	}
+	if err := q.Push(Input{Source: "cron"}); err != nil {
+		t.Fatalf("unrelated source rejected: %v", err)
+	}
+}
+
+func TestAgentPersistsQueuedInputsUntilDone(t *testing.T) {
+	s := openAgentStore(t)
+	a := NewAgent(s.MessageStore(), []tooling.Tool{sleepStubTool{}}, idleProvider{})
+	a.SetQueueStore(s.Queue())
+	if err := a.pending.Push(Input{Source: "signal:dm:+1", Content: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := s.Queue().Unfinished()
+	if err != nil || len(saved) != 1 || saved[0].State != store.QueuePending || saved[0].Content != "first" {
+		t.Fatalf("expected 1 pending input, got %+v (%v)", saved, err)
+	}
+
+	if err := a.RunOnce(context.Background(), Input{Source: "signal:dm:+1", Content: "second"}); err != nil {
+		t.Fatal(err)
+	}
+	saved, err = s.Queue().Unfinished()
+	if err != nil || len(saved) != 0 {
+		t.Fatalf("expected inputs marked done, got %+v (%v)", saved, err)
+	}
+	if got := userMessageIDs(t, s); len(got) != 2 || got[0] == "" {
+		t.Fatalf("expected 2 user messages keyed by input id, got %v", got)
+	}
+}
+
+func TestAgentRestoreQueueRerunsUnfinishedInputs(t *testing.T) {
+	s := openAgentStore(t)
+	q := s.Queue()
+	for _, id := range []string{"in-1", "in-2", "in-3"} {
+		if err := q.Add(store.QueuedInput{ID: id, Source: "signal:dm:+1", Content: "msg " + id, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The process died while in-1 was being generated: its user message is
+	// already in the thread.
+	if err := q.SetState([]string{"in-1"}, store.QueueRunning); err != nil {
+		t.Fatal(err)
+	}
+	msg := newUserMessage(formatInput(Input{Source: "signal:dm:+1", Content: "msg in-1"}))
+	msg.ID = "in-1"
+	if err := s.Messages.Create(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAgent(s.MessageStore(), []tooling.Tool{sleepStubTool{}}, idleProvider{})
+	a.SetQueueStore(q)
+	n, err := a.RestoreQueue()
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 restored inputs, got %d (%v)", n, err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for (a.IsActive() || a.QueueDepth() > 0) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := userMessageIDs(t, s); !reflect.DeepEqual(got, []string{"in-1", "in-2", "in-3"}) {
+		t.Fatalf("expected each input stored once in order, got %v", got)
+	}
+	saved, err := q.Unfinished()
+	if err != nil || len(saved) != 0 {
+		t.Fatalf("expected restored inputs marked done, got %+v (%v)", saved, err)
+	}
+}
+
+func TestAgentRunsHighPriorityInputBeforeQueuedLowOne(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{textStream("on it"), textStream("tick handled")}}
+	a := NewAgent(s.MessageStore(), nil, p)
+	a.SetNoToolSleepRounds(1)
+	a.SetQueueStore(s.Queue())
+
+	if err := a.pending.Push(Input{Source: "cron", Content: "nightly backup check", Priority: PriorityLow}); err != nil {
+		t.Fatalf("push low: %v", err)
+	}
+	if err := a.RunOnce(context.Background(), Input{Source: "signal:dm:+1", Content: "call me", Priority: PriorityHigh}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	var first []string
+	for _, m := range p.seenMessages[0] {
+		first = append(first, compactText(&m))
+	}
+	if seen := strings.Join(first, "\n"); !strings.Contains(seen, "call me") || strings.Contains(seen, "nightly backup") {
+		t.Fatalf("first generation should see only the high input, got %q", seen)
+	}
+	if a.QueueDepth() != 1 {
+		t.Fatalf("low input should still be queued, depth %d", a.QueueDepth())
+	}
+	saved, err := s.Queue().Unfinished()
+	if err != nil || len(saved) != 1 || saved[0].Priority != int(PriorityLow) {
+		t.Fatalf("queued low input not persisted with its priority: %+v (%v)", saved, err)
+	}
+
+	if err := a.run(context.Background()); err != nil {
+		t.Fatalf("second turn: %v", err)
+	}
+	second := p.seenMessages[1]
+	if got := compactText(&second[len(second)-1]); !strings.Contains(got, "nightly backup") {
+		t.Fatalf("second turn should pick up the low input, got %q", got)
+	}
+}
+
+func TestAgentSteerReachesRunningToolLoop(t *testing.T) {
+	s := openAgentStore(t)
+	var a *Agent
+	steer := func(ctx context.Context, msgs []model.Message, defs []provider.ToolDef) <-chan provider.ProviderEvent {
+		if err := a.Steer(Input{Source: "api", Content: "stop and run the tests instead"}); err != nil {
+			t.Errorf("steer: %v", err)
+		}
+		if err := a.Inject(Input{Source: "cron", Content: "nightly backup check"}); err != nil {
+			t.Errorf("inject: %v", err)
+		}
+		return echoCallStream("call0")(ctx, msgs, defs)
+	}
+	p := &scriptedProvider{streams: []streamScript{steer, echoCallStream("call1"), textStream("running tests")}}
+	a = NewAgent(s.MessageStore(), []tooling.Tool{&echoTool{}}, p)
+	a.SetNoToolSleepRounds(1)
+
+	if err := a.RunOnce(context.Background(), Input{Source: "signal:dm:+1", Content: "refactor the parser", Priority: PriorityHigh}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if p.CallCount() != 3 {
+		t.Fatalf("provider calls = %d, want 3", p.CallCount())
+	}
+	second := p.seenMessages[1]
+	if got := compactText(&second[len(second)-1]); !strings.Contains(got, "stop and run the tests instead") {
+		t.Fatalf("steer should reach the round after the tool call, got %q", got)
+	}
+	for _, m := range second {
+		if strings.Contains(compactText(&m), "nightly backup") {
+			t.Fatal("normal input joined a high priority turn")
+		}
+	}
+	if a.QueueDepth() != 1 {
+		t.Fatalf("normal input should still be queued, depth %d", a.QueueDepth())
+	}
+}
+
+func userMessageIDs(t *testing.T, s *store.SQLiteStore) []string {
+	t.Helper()
+	var ids []string
+	for _, m := range listMessages(t, s) {
+		if m.Role == model.RoleUser {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids
+}
+
+func TestCoalesceKeepsGroupMembersApart(t *testing.T) {
+	alice := map[string]string{"source_uuid": "u-alice"}
+	bob := map[string]string{"source_uuid": "u-bob"}
+	got := coalesce([]Input{
+		{ID: "1", Source: "signal:group:g1", Content: "lunch?", Metadata: alice},
+		{ID: "2", Source: "signal:group:g1", Content: "I'm out today", Metadata: bob},
+		{ID: "3", Source: "signal:group:g1", Content: "at noon", Metadata: alice},
+	})
+	want := []Input{
+		{ID: "1", Source: "signal:group:g1", Content: "lunch?\nat noon", Metadata: alice},
+		{ID: "2", Source: "signal:group:g1", Content: "I'm out today", Metadata: bob},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected coalesced inputs: %+v", got)
+	}
+}
+
+func TestCoalesceMergesOnlySameSource(t *testing.T) {
+	got := coalesce([]Input{
+		{ID: "1", Source: "signal:dm:+1", Content: "so"},
+		{ID: "2", Source: "webhook:ci", Content: "build failed"},
+		{ID: "3", Source: "signal:dm:+1", Content: "about tomorrow"},
+		{ID: "4", Source: "signal:dm:+1", Content: "can we move it?"},
+	})
+	want := []Input{
+		{ID: "1", Source: "signal:dm:+1", Content: "so\nabout tomorrow\ncan we move it?"},
+		{ID: "2", Source: "webhook:ci", Content: "build failed"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected coalesced inputs: %+v", got)
+	}
+}
+
+// gatedProvider holds its first stream until release is closed, then ends
+// every turn with the sleep tool.
+type gatedProvider struct {
+	started chan struct{}
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (p *gatedProvider) Stream(context.Context, []model.Message, []provider.ToolDef, provider.ToolChoice) <-chan provider.ProviderEvent {
+	ch := make(chan provider.ProviderEvent, 3)
+	first := p.calls.Add(1) == 1
+	go func() {
+		defer close(ch)
+		if first {
+			close(p.started)
+			<-p.release
+		}
+		ch <- provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "sleep-1", ToolName: "sleep"}
+		ch <- provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: "sleep-1"}
+		ch <- provider.ProviderEvent{Type: provider.EventComplete}
+	}()
+	return ch
+}
+
+func (*gatedProvider) Model() provider.ModelInfo {
+	return provider.ModelInfo{ID: "stub", Name: "stub-model"}
+}
+
+func userTexts(t *testing.T, ms *memMessageStore) []string {
+	t.Helper()
+	msgs, err := ms.List(100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, m := range msgs {
+		if m.Role == model.RoleUser {
+			out = append(out, textPart(m))
+		}
+	}
+	return out
+}
+
+func waitIdle(t *testing.T, a *Agent) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for (a.IsActive() || a.QueueDepth() > 0) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if a.IsActive() {
+		t.Fatal("agent still active")
+	}
+}
+
+func TestAgentCoalescesInputsWithinWindow(t *testing.T) {
+	ms := &memMessageStore{}
+	p := &gatedProvider{started: make(chan struct{}), release: make(chan struct{})}
+	close(p.release)
+	a := NewAgent(ms, []tooling.Tool{sleepStubTool{}}, p)
+	a.SetCoalesceWindow(100 * time.Millisecond)
+	for _, in := range []Input{
+		{Source: "signal:dm:+1", Content: "so"},
+		{Source: "webhook:ci", Content: "build failed"},
+		{Source: "signal:dm:+1", Content: "about tomorrow"},
+	} {
+		if err := a.Inject(in); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitIdle(t, a)
+	want := []string{"[signal:dm:+1] so\nabout tomorrow", "[webhook:ci] build failed"}
+	if got := userTexts(t, ms); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected user messages: %q", got)
+	}
+	if p.calls.Load() != 1 {
+		t.Fatalf("expected one generation, got %d", p.calls.Load())
+	}
+}
+
+func TestAgentCoalesceStartsNewBatchDuringGeneration(t *testing.T) {
+	ms := &memMessageStore{}
+	p := &gatedProvider{started: make(chan struct{}), release: make(chan struct{})}
+	a := NewAgent(ms, []tooling.Tool{sleepStubTool{}}, p)
+	a.SetCoalesceWindow(20 * time.Millisecond)
+	if err := a.Inject(Input{Source: "signal:dm:+1", Content: "one"}); err != nil {
+		t.Fatal(err)
+	}
+	<-p.started
+	if err := a.Inject(Input{Source: "signal:dm:+1", Content: "two"}); err != nil {
+		t.Fatal(err)
+	}
+	close(p.release)
+	waitIdle(t, a)
+	want := []string{"[signal:dm:+1] one", "[signal:dm:+1] two"}
+	if got := userTexts(t, ms); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected separate batches, got %q", got)
+	}
+}
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Rotation periods accepted by SetRotation.
+const (
+	RotateDaily   = "daily"
+	RotateWeekly  = "weekly"
+	RotateMonthly = "monthly"
+)
+
+// SetRotation starts a fresh thread at each calendar period boundary in loc.
+// The old thread is archived under its period key and replaced by a summary.
+// An empty period disables rotation.
+func (a *Agent) SetRotation(period string, loc *time.Location) {
+
+	a.rotation = period
+	a.location = loc
+}
+
+// PeriodKey names the calendar period containing t, e.g. "2026-02-14",
+// "2026-W07", or "2026-02". Keys of one period kind sort chronologically.
+func PeriodKey(period string, t time.Time) string {
+
+	switch period {
+	case RotateDaily:
+		return t.Format("2006-01-02")
+	case RotateWeekly:
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", y, w)
+	}
+	return t.Format("2006-01")
+}
+
+// rotateIfDue archives the thread when its newest message belongs to an
+// earlier period than now, carrying a compaction summary into the new thread.
+func (a *Agent) rotateIfDue(ctx context.Context) error {
+
+	if a.rotation == "" {
+		return nil
+	}
+	n, err := a.messages.Count()
+	if err != nil || n == 0 {
+		return err
+	}
+	last, err := a.messages.List(1, n-1)
+	if err != nil || len(last) == 0 {
+		return err
+	}
+	prev := PeriodKey(a.rotation, last[0].CreatedAt.In(a.location))
+	now := a.now()
+	if prev >= PeriodKey(a.rotation, now.In(a.location)) {
+		return nil
+	}
+	summary, err := a.summaryMessage(ctx)
+	if err != nil {
+		return err
+	}
+	text := summary.Parts[0].(TextPart).Text
+	summary.Parts = []MessagePart{TextPart{Text: "Summary of the previous period (" + prev + "):\n\n" + text}}
+	summary.CreatedAt = now.UTC()
+	if err := a.messages.Archive(prev, []*Message{summary}); err != nil {
+		return err
+	}
+	if a.costs != nil {
+		if err := a.ResetThreadCost(); err != nil {
+			return err
+		}
+	}
+	a.tracef("rotate period=%s", prev)
+	return nil
+}
@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+func TestPeriodKey(t *testing.T) {
+	at := time.Date(2026, 2, 14, 23, 30, 0, 0, time.UTC)
+	cases := map[string]string{RotateDaily: "2026-02-14", RotateWeekly: "2026-W07", RotateMonthly: "2026-02"}
+	for period, want := range cases {
+		if got := PeriodKey(period, at); got != want {
+			t.Fatalf("PeriodKey(%s)=%q want %q", period, got, want)
+		}
+	}
+}
+
+func rotationProvider() *scriptedProvider {
+	return &scriptedProvider{streams: []streamScript{
+		eventStream(
+			provider.ProviderEvent{Type: provider.EventContentDelta, Delta: "january recap"},
+			provider.ProviderEvent{Type: provider.EventComplete},
+		),
+		eventStream(
+			provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "call-sleep", ToolName: "sleep"},
+			provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: "call-sleep"},
+			provider.ProviderEvent{Type: provider.EventComplete},
+		),
+	}}
+}
+
+func TestRunRotatesThreadAcrossPeriodBoundary(t *testing.T) {
+	s := openAgentStore(t)
+	madrid := time.FixedZone("CET", 3600)
+	// 23:30 UTC on Jan 31 is already Feb 1 in CET, so the boundary is
+	// crossed only when the clock passes midnight local time.
+	old := time.Date(2026, 1, 31, 22, 30, 0, 0, time.UTC)
+	for i, id := range []string{"u1", "a1"} {
+		role := RoleUser
+		if id == "a1" {
+			role = RoleAssistant
+		}
+		at := old.Add(time.Duration(i) * time.Minute)
+		if err := s.Messages.Create(&Message{ID: id, Role: role, Parts: []MessagePart{TextPart{Text: id}}, CreatedAt: at}); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+	}
+	p := rotationProvider()
+	a := NewAgent(s.MessageStore(), []tooling.Tool{&sleepTool{}}, p)
+	a.SetRotation(RotateMonthly, madrid)
+	a.now = func() time.Time { return time.Date(2026, 1, 31, 23, 30, 0, 0, time.UTC) }
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "hello february"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	archived, err := s.Messages.ListArchive("2026-01")
+	if err != nil {
+		t.Fatalf("list archive: %v", err)
+	}
+	if len(archived) != 2 || archived[0].ID != "u1" || archived[1].ID != "a1" {
+		t.Fatalf("old thread not archived intact: %#v", archived)
+	}
+	msgs := listMessages(t, s)
+	if len(msgs) < 2 {
+		t.Fatalf("expected summary and new input, got %d messages", len(msgs))
+	}
+	if got := compactText(msgs[0]); !strings.HasPrefix(got, "Summary of the previous period (2026-01):") || !strings.Contains(got, "january recap") {
+		t.Fatalf("new thread does not open with the summary: %q", got)
+	}
+	if got := compactText(msgs[1]); got != "[api] hello february" {
+		t.Fatalf("unexpected first input after rotation: %q", got)
+	}
+}
+
+func TestRunKeepsThreadWithinPeriod(t *testing.T) {
+	s := openAgentStore(t)
+	madrid := time.FixedZone("CET", 3600)
+	old := time.Date(2026, 1, 31, 22, 30, 0, 0, time.UTC)
+	if err := s.Messages.Create(&Message{ID: "u1", Role: RoleUser, Parts: []MessagePart{TextPart{Text: "u1"}}, CreatedAt: old}); err != nil {
+		t.Fatalf("create message: %v", err)
+	}
+	p := rotationProvider()
+	p.streams = p.streams[1:]
+	a := NewAgent(s.MessageStore(), []tooling.Tool{&sleepTool{}}, p)
+	a.SetRotation(RotateMonthly, madrid)
+	a.now = func() time.Time { return time.Date(2026, 1, 31, 22, 50, 0, 0, time.UTC) }
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "still january"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if archived, _ := s.Messages.ListArchive("2026-01"); len(archived) != 0 {
+		t.Fatalf("rotated within the period: %#v", archived)
+	}
+	if msgs := listMessages(t, s); compactText(msgs[0]) != "u1" {
+		t.Fatalf("thread was replaced: %q", compactText(msgs[0]))
+	}
+}
@@ -0,0 +1,21 @@
+package agent
+
+import "errors"
+
+// ErrShuttingDown is returned by Inject once StopAccepting has been called.
+var ErrShuttingDown = errors.New("agent is shutting down")
+
+// StopAccepting makes Inject refuse new input and keeps the agent from
+// starting another turn. The running turn, if any, goes on but no longer
+// picks up queued input between rounds; what is left stays queued, and
+// persisted when a queue store is set.
+func (a *Agent) StopAccepting() {
+
+	a.stopping.Store(true)
+}
+
+// Pending returns a copy of the inputs still waiting for the agent.
+func (a *Agent) Pending() []Input {
+
+	return a.pending.snapshot()
+}
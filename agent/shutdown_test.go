@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+func TestStopAcceptingFinishesTurnAndKeepsQueue(t *testing.T) {
+	ms := &memMessageStore{}
+	p := &gatedProvider{started: make(chan struct{}), release: make(chan struct{})}
+	a := NewAgent(ms, []tooling.Tool{sleepStubTool{}}, p)
+	if err := a.Inject(Input{Source: "signal:dm:+1", Content: "one"}); err != nil {
+		t.Fatal(err)
+	}
+	<-p.started
+	if err := a.Inject(Input{Source: "signal:dm:+1", Content: "two"}); err != nil {
+		t.Fatal(err)
+	}
+	a.StopAccepting()
+	if err := a.Inject(Input{Source: "signal:dm:+1", Content: "three"}); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("inject after stop = %v, want ErrShuttingDown", err)
+	}
+	close(p.release)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for a.IsActive() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if a.IsActive() {
+		t.Fatal("agent started another turn after StopAccepting")
+	}
+	if got := userTexts(t, ms); !reflect.DeepEqual(got, []string{"[signal:dm:+1] one"}) {
+		t.Fatalf("expected only the running turn's input, got %q", got)
+	}
+	pending := a.Pending()
+	if len(pending) != 1 || pending[0].Content != "two" {
+		t.Fatalf("expected the queued input to stay pending, got %+v", pending)
+	}
+}
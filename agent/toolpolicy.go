@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+// ToolPolicy limits the tools a turn offers the model. Enabled, when not
+// empty, keeps only the named tools; Disabled then removes tools by name.
+type ToolPolicy struct {
+	Enabled  []string
+	Disabled []string
+}
+
+func (p ToolPolicy) allows(name string) bool {
+
+	if len(p.Enabled) > 0 && !slices.Contains(p.Enabled, name) {
+		return false
+	}
+	return !slices.Contains(p.Disabled, name)
+}
+
+// ToolPolicies holds the policy applied to every turn and per-source ones.
+// Sources keys are a source or source prefix (e.g. "signal:dm:"); the
+// longest key matching an input's source applies to it.
+type ToolPolicies struct {
+	Default ToolPolicy
+	Sources map[string]ToolPolicy
+}
+
+func (p ToolPolicies) sourcePolicy(source string) (ToolPolicy, bool) {
+
+	key, found := "", false
+	for k := range p.Sources {
+		if strings.HasPrefix(source, k) && (!found || len(k) > len(key)) {
+			key, found = k, true
+		}
+	}
+	return p.Sources[key], found
+}
+
+// SetToolPolicies restricts the tools offered in each turn. A tool outside
+// the policies is left out of the provider's tool list, and a call to it is
+// answered as an unknown tool.
+func (a *Agent) SetToolPolicies(p ToolPolicies) {
+
+	a.toolPolicies = p
+}
+
+// turnTools returns the agent's tools allowed for a turn with these inputs.
+// A turn mixing sources gets only the tools every one of them allows.
+func (a *Agent) turnTools(inputs []Input) []tooling.Tool {
+
+	policies := []ToolPolicy{a.toolPolicies.Default}
+	for _, in := range inputs {
+		if p, ok := a.toolPolicies.sourcePolicy(in.Source); ok {
+			policies = append(policies, p)
+		}
+	}
+	out := make([]tooling.Tool, 0, len(a.tools))
+	for _, t := range a.tools {
+		if slices.ContainsFunc(policies, func(p ToolPolicy) bool { return !p.allows(t.Name()) }) {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
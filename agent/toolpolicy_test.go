@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+func defNames(toolList []tooling.Tool) []string {
+	var names []string
+	for _, def := range toProviderDefs(toolList) {
+		names = append(names, def.Name)
+	}
+	return names
+}
+
+func TestTurnToolsAppliesDefaultAndSourcePolicies(t *testing.T) {
+	s := openAgentStore(t)
+	a := NewAgent(s.MessageStore(), []tooling.Tool{&echoTool{}, &sleepTool{}, &cancellationTool{}}, &scriptedProvider{})
+	a.SetToolPolicies(ToolPolicies{
+		Default: ToolPolicy{Disabled: []string{"slow"}},
+		Sources: map[string]ToolPolicy{
+			"signal:":       {Enabled: []string{"echo", "sleep"}},
+			"signal:dm:":    {Disabled: []string{"echo"}},
+			"signal:group:": {Enabled: []string{"echo"}},
+		},
+	})
+	cases := []struct {
+		sources []string
+		want    string
+	}{
+		{[]string{"webhook:jobs"}, "echo,sleep"},
+		{[]string{"signal:dm:abc"}, "sleep"},
+		{[]string{"signal:group:xyz"}, "echo"},
+		{[]string{"webhook:jobs", "signal:dm:abc"}, "sleep"},
+	}
+	for _, tc := range cases {
+		var inputs []Input
+		for _, src := range tc.sources {
+			inputs = append(inputs, Input{Source: src})
+		}
+		if got := strings.Join(defNames(a.turnTools(inputs)), ","); got != tc.want {
+			t.Fatalf("sources %v: got tools %q, want %q", tc.sources, got, tc.want)
+		}
+	}
+}
+
+func TestDeniedToolIsHiddenAndNotRun(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{echoCallStream("c1"), textStream("ok")}}
+	echo := &echoTool{}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{echo, &sleepTool{}}, p)
+	a.SetNoToolSleepRounds(1)
+	a.SetToolPolicies(ToolPolicies{Sources: map[string]ToolPolicy{"signal:dm:": {Disabled: []string{"echo"}}}})
+
+	if err := a.RunOnce(context.Background(), Input{Source: "signal:dm:abc", Content: "run echo"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	for _, def := range p.seenTools[0] {
+		if def.Name == "echo" {
+			t.Fatalf("denied tool offered to the provider: %#v", p.seenTools[0])
+		}
+	}
+	if len(echo.Calls()) != 0 {
+		t.Fatalf("denied tool ran: %#v", echo.Calls())
+	}
+	for _, msg := range listMessages(t, s) {
+		for _, part := range msg.Parts {
+			if r, ok := part.(model.ToolResultPart); ok && r.IsError && r.Content == "tool not found: echo" {
+				return
+			}
+		}
+	}
+	t.Fatal("denied call was not answered with tool not found")
+}
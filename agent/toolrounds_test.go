@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/logging"
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+type messageTool struct{ echoTool }
+
+func (t *messageTool) Name() string { return "message" }
+
+func echoCallStream(id string) streamScript {
+	return eventStream(
+		provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: id, ToolName: "echo"},
+		provider.ProviderEvent{Type: provider.EventToolUseDelta, ToolCallID: id, Delta: `{}`},
+		provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: id},
+		provider.ProviderEvent{Type: provider.EventComplete},
+	)
+}
+
+func TestRunStopsAtMaxToolRounds(t *testing.T) {
+	s := openAgentStore(t)
+	streams := make([]streamScript, 0, 4)
+	for i := range 4 {
+		streams = append(streams, echoCallStream(fmt.Sprintf("call%d", i)))
+	}
+	p := &scriptedProvider{streams: streams}
+	echo := &echoTool{}
+	msg := &messageTool{}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{echo, msg}, p)
+	a.SetMaxToolRounds(3)
+	var trace []string
+	a.SetTrace(func(_ logging.Level, format string, args ...any) { trace = append(trace, fmt.Sprintf(format, args...)) })
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "loop forever"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if p.CallCount() != 4 {
+		t.Fatalf("want 3 tool rounds and a final round, got %d provider calls", p.CallCount())
+	}
+	if len(echo.Calls()) != 3 {
+		t.Fatalf("echo ran %d times, want 3", len(echo.Calls()))
+	}
+	final := p.seenTools[3]
+	if len(final) != 1 || final[0].Name != "message" {
+		t.Fatalf("final round tools = %+v, want only message", final)
+	}
+	if p.seenChoices[3] != "message" {
+		t.Fatalf("final round tool choice = %q, want message", p.seenChoices[3])
+	}
+	if !strings.Contains(strings.Join(trace, "\n"), "tool_round=3 max=3") {
+		t.Fatalf("trace lacks round count: %q", trace)
+	}
+	note := findMessageWithText(listMessages(t, s), "Tool round limit reached (3 rounds")
+	if note == nil || note.Role != model.RoleUser {
+		t.Fatal("missing tool round limit note")
+	}
+}
+
+func findMessageWithText(msgs []*model.Message, substr string) *model.Message {
+	for _, msg := range msgs {
+		if strings.Contains(textPart(msg), substr) {
+			return msg
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/google/uuid"
+)
+
+const toolSummaryPrompt = `The tool output below is too long to keep in the conversation. Summarize it for the assistant that requested it. Keep every error, warning, failing test, file path, and number that matters, and say whether the command looks successful. Reply with the summary only.`
+
+// toolSummaryInputMax caps how much of one tool result is sent to the
+// summarizer; longer output keeps its head and tail, where build logs put
+// what matters.
+const toolSummaryInputMax = 200_000
+
+// ToolResultSummary replaces tool results longer than Over characters with
+// a summary written by the agent's summary provider. The full text is saved
+// under Dir and the summary points to it. Over 0 turns summarizing off.
+type ToolResultSummary struct {
+	Over int
+	Dir  string
+}
+
+// SetToolResultSummary sets how oversized tool results are summarized
+// before they are stored and sent to the provider.
+func (a *Agent) SetToolResultSummary(s ToolResultSummary) {
+
+	a.toolSummary = s
+}
+
+// summarizeToolResults rewrites each oversized result in msg in place. A
+// result that cannot be saved or summarized is kept as it was.
+func (a *Agent) summarizeToolResults(ctx context.Context, calls []ToolCallPart, msg *Message) {
+
+	if a.toolSummary.Over <= 0 {
+		return
+	}
+	for i, part := range msg.Parts {
+		result, ok := part.(ToolResultPart)
+		if !ok || len(result.Content) <= a.toolSummary.Over {
+			continue
+		}
+		summarized, err := a.summarizeToolResult(ctx, callName(calls, result.ToolCallID), result)
+		if err != nil {
+			a.traceErrorf("tool_summary id=%s err=%q", result.ToolCallID, err.Error())
+			continue
+		}
+		msg.Parts[i] = summarized
+	}
+}
+
+func (a *Agent) summarizeToolResult(ctx context.Context, name string, result ToolResultPart) (ToolResultPart, error) {
+
+	if err := os.MkdirAll(a.toolSummary.Dir, 0o755); err != nil {
+		return result, err
+	}
+	path := filepath.Join(a.toolSummary.Dir, uuid.NewString()+".txt")
+	if err := os.WriteFile(path, []byte(result.Content), 0o644); err != nil {
+		return result, err
+	}
+	summary, err := a.summarize(ctx, fmt.Sprintf("%s\n\nTool: %s\n\n%s", toolSummaryPrompt, name, clipMiddle(result.Content, toolSummaryInputMax)))
+	if err != nil {
+		return result, err
+	}
+	a.tracef("tool_summary id=%s chars=%d path=%s", result.ToolCallID, len(result.Content), path)
+	result.Content = fmt.Sprintf("[output of %d characters summarized; full text saved to %s]\n%s", len(result.Content), path, summary)
+	return result, nil
+}
+
+// summarize runs one tool-less generation on the summary provider and
+// returns its text. Its cost counts toward the spending limits.
+func (a *Agent) summarize(ctx context.Context, request string) (string, error) {
+
+	p := a.summarizer()
+	history := []model.Message{{
+		ID:        uuid.NewString(),
+		Role:      model.RoleUser,
+		Parts:     []model.MessagePart{model.TextPart{Text: request}},
+		CreatedAt: time.Now().UTC(),
+	}}
+	text, _, _, _, err := a.collectStream(ctx, p, history, nil, provider.ToolChoiceNone, nil)
+	if err != nil {
+		return "", err
+	}
+	out := strings.TrimSpace(text)
+	if out == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return out, nil
+}
+
+// clipMiddle keeps the first and last limit/2 bytes of s.
+func clipMiddle(s string, limit int) string {
+
+	if len(s) <= limit {
+		return s
+	}
+	half := limit / 2
+	return s[:half] + fmt.Sprintf("\n[... %d characters omitted ...]\n", len(s)-2*half) + s[len(s)-half:]
+}
+
+func callName(calls []ToolCallPart, id string) string {
+
+	for _, c := range calls {
+		if c.ID == id {
+			return c.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+// logTool returns a fixed output, like a build printing its log.
+type logTool struct {
+	echoTool
+	output string
+}
+
+func (t *logTool) Run(context.Context, model.ToolCallPart) (tooling.ToolResult, error) {
+	return tooling.ToolResult{Content: t.output}, nil
+}
+
+func TestOversizedToolResultIsSummarizedBeforeNextCall(t *testing.T) {
+	s := openAgentStore(t)
+	full := strings.Repeat("compiling pkg\n", 500) + "error: undefined: foo\n"
+	p := &scriptedProvider{streams: []streamScript{
+		echoCallStream("c1"),
+		textStream("Build failed: undefined: foo."),
+		textStream("the build is broken"),
+	}}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{&logTool{output: full}}, p)
+	a.SetNoToolSleepRounds(1)
+	dir := t.TempDir()
+	a.SetToolResultSummary(ToolResultSummary{Over: 1000, Dir: dir})
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "build it"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if p.CallCount() != 3 {
+		t.Fatalf("provider calls = %d, want 3", p.CallCount())
+	}
+	request := p.seenMessages[1][0].Parts[0].(model.TextPart).Text
+	if !strings.Contains(request, "Tool: echo") || !strings.Contains(request, "error: undefined: foo") {
+		t.Fatalf("summarizer did not get the tool output: %q", request[:200])
+	}
+
+	var sent model.ToolResultPart
+	for _, msg := range p.seenMessages[2] {
+		for _, part := range msg.Parts {
+			if r, ok := part.(model.ToolResultPart); ok {
+				sent = r
+			}
+		}
+	}
+	if strings.Contains(sent.Content, "compiling pkg") || !strings.HasSuffix(sent.Content, "Build failed: undefined: foo.") {
+		t.Fatalf("next call got unsummarized result: %q", sent.Content)
+	}
+	path := regexp.MustCompile(`saved to (\S+)\]`).FindStringSubmatch(sent.Content)
+	if path == nil || !strings.HasPrefix(path[1], dir) {
+		t.Fatalf("summary has no pointer to the full text: %q", sent.Content)
+	}
+	saved, err := os.ReadFile(path[1])
+	if err != nil || string(saved) != full {
+		t.Fatalf("full text not saved: %v", err)
+	}
+
+	for _, msg := range listMessages(t, s) {
+		for _, part := range msg.Parts {
+			if r, ok := part.(model.ToolResultPart); ok && r.Content != sent.Content {
+				t.Fatalf("stored result differs from the one sent: %q", r.Content)
+			}
+		}
+	}
+}
+
+func TestSmallToolResultIsNotSummarized(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{echoCallStream("c1"), textStream("done")}}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{&logTool{output: "ok"}}, p)
+	a.SetNoToolSleepRounds(1)
+	a.SetToolResultSummary(ToolResultSummary{Over: 1000, Dir: t.TempDir()})
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "build it"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if p.CallCount() != 2 {
+		t.Fatalf("provider calls = %d, want 2", p.CallCount())
+	}
+}
+
+func TestClipMiddleKeepsHeadAndTail(t *testing.T) {
+	got := clipMiddle("aaaa"+strings.Repeat("x", 100)+"bbbb", 8)
+	if !strings.HasPrefix(got, "aaaa") || !strings.HasSuffix(got, "bbbb") || !strings.Contains(got, "100 characters omitted") {
+		t.Fatalf("unexpected clip: %q", got)
+	}
+}
@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+// ToolTimeouts bounds how long a single tool call may run. PerTool overrides
+// Default by tool name. Zero values mean no limit.
+type ToolTimeouts struct {
+	Default time.Duration
+	PerTool map[string]time.Duration
+}
+
+func (t ToolTimeouts) limit(name string) time.Duration {
+
+	if d, ok := t.PerTool[name]; ok {
+		return d
+	}
+	return t.Default
+}
+
+// SetToolTimeouts sets the deadline applied to each tool call. A call that
+// runs past it is abandoned and reported to the model as a timed-out error;
+// the generation itself keeps going.
+func (a *Agent) SetToolTimeouts(timeouts ToolTimeouts) {
+
+	a.toolTimeouts = timeouts
+}
+
+// runWithTimeout runs tool under limit. When the deadline passes first it
+// returns a timeout result without waiting for the tool, so a tool that
+// ignores its context cannot stall the agent. Cancellation of ctx itself
+// still waits for the tool to return.
+func runWithTimeout(ctx context.Context, tool tooling.Tool, call ToolCallPart, limit time.Duration) ToolResultPart {
+
+	if limit <= 0 {
+		return callTool(ctx, tool, call)
+	}
+	runCtx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+	done := make(chan ToolResultPart, 1)
+	go func() { done <- callTool(runCtx, tool, call) }()
+	select {
+	case part := <-done:
+		if ctx.Err() == nil && runCtx.Err() == context.DeadlineExceeded {
+			return timedOutPart(call, limit)
+		}
+		return part
+	case <-runCtx.Done():
+		if ctx.Err() != nil {
+			return <-done
+		}
+		return timedOutPart(call, limit)
+	}
+}
+
+func timedOutPart(call ToolCallPart, limit time.Duration) ToolResultPart {
+
+	return ToolResultPart{
+		ToolCallID: call.ID,
+		Content:    fmt.Sprintf("tool %s timed out after %s", call.Name, limit),
+		IsError:    true,
+	}
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+// hangTool never returns on its own and ignores its context, like a stuck
+// network call.
+type hangTool struct {
+	echoTool
+	block chan struct{}
+}
+
+func (t *hangTool) Run(context.Context, model.ToolCallPart) (tooling.ToolResult, error) {
+	<-t.block
+	return tooling.ToolResult{Content: "late"}, nil
+}
+
+func TestToolTimeoutsLimit(t *testing.T) {
+	timeouts := ToolTimeouts{Default: time.Minute, PerTool: map[string]time.Duration{"fetch": time.Second}}
+	if got := timeouts.limit("fetch"); got != time.Second {
+		t.Fatalf("fetch limit = %s", got)
+	}
+	if got := timeouts.limit("read"); got != time.Minute {
+		t.Fatalf("read limit = %s", got)
+	}
+	if got := (ToolTimeouts{}).limit("read"); got != 0 {
+		t.Fatalf("zero timeouts limit = %s", got)
+	}
+}
+
+func TestRunToolsTimesOutHungToolOnly(t *testing.T) {
+	hung := &hangTool{block: make(chan struct{})}
+	defer close(hung.block)
+	var active atomic.Int32
+	read := &trackedTool{name: "read", others: &active}
+	calls := []ToolCallPart{{ID: "c1", Name: "echo"}, {ID: "c2", Name: "read"}}
+	timeouts := ToolTimeouts{Default: time.Minute, PerTool: map[string]time.Duration{"echo": 20 * time.Millisecond}}
+	ctx := context.Background()
+
+	start := time.Now()
+	msg, err := runTools(ctx, []tooling.Tool{hung, read}, calls, 2, timeouts, nil)
+	if err != nil || ctx.Err() != nil {
+		t.Fatalf("timeout leaked into the turn: %v %v", err, ctx.Err())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("hung tool stalled the agent for %s", elapsed)
+	}
+	got := msg.Parts[0].(model.ToolResultPart)
+	if !got.IsError || got.Content != "tool echo timed out after 20ms" {
+		t.Fatalf("unexpected timeout result: %#v", got)
+	}
+	if ok := msg.Parts[1].(model.ToolResultPart); ok.IsError || ok.Content != "c2" {
+		t.Fatalf("other call affected by timeout: %#v", ok)
+	}
+}
+
+func TestToolTimeoutHonouredByContextAwareTool(t *testing.T) {
+	slow := &trackedTool{name: "slow", release: make(chan struct{}), others: new(atomic.Int32)}
+	part := runWithTimeout(context.Background(), slow, ToolCallPart{ID: "c1", Name: "slow"}, 10*time.Millisecond)
+	if !part.IsError || !strings.Contains(part.Content, "timed out after 10ms") {
+		t.Fatalf("unexpected result: %#v", part)
+	}
+}
+
+func TestAgentContinuesAfterToolTimeout(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{echoCallStream("c1"), textStream("moving on")}}
+	hung := &hangTool{block: make(chan struct{})}
+	defer close(hung.block)
+	a := NewAgent(s.MessageStore(), []tooling.Tool{hung}, p)
+	a.SetNoToolSleepRounds(1)
+	a.SetToolTimeouts(ToolTimeouts{Default: 20 * time.Millisecond})
+
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "fetch it"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if p.CallCount() != 2 {
+		t.Fatalf("generation did not continue after the timeout: %d calls", p.CallCount())
+	}
+	for _, msg := range listMessages(t, s) {
+		for _, part := range msg.Parts {
+			if r, ok := part.(model.ToolResultPart); ok && strings.HasSuffix(r.ToolCallID, "c1") && r.IsError && strings.Contains(r.Content, "timed out") {
+				return
+			}
+		}
+	}
+	t.Fatal("timeout result not stored")
+}
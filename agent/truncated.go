@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/google/uuid"
+)
+
+// Truncated tool-call modes. A stream can end while a tool call's arguments
+// are still arriving, usually because the reply hit the token limit. Such a
+// call is never run; by default the generation is retried once, with twice
+// the output token limit and a note asking for shorter arguments, and
+// "error" fails the turn instead.
+const (
+	TruncatedCallsRetry = "retry"
+	TruncatedCallsError = "error"
+)
+
+var errTruncatedToolCall = errors.New("tool call arguments cut off at stream end")
+
+// SetTruncatedCalls selects how a tool call with incomplete arguments is
+// handled: TruncatedCallsRetry (the default) or TruncatedCallsError.
+func (a *Agent) SetTruncatedCalls(mode string) {
+
+	a.truncatedCalls = mode
+}
+
+// truncatedRetryTokens is how much the output token limit is multiplied by
+// for the retry of a truncated tool call.
+const truncatedRetryTokens = 2
+
+// collectCalls runs collectStream and checks the tool calls it returns. When
+// one was cut off mid-arguments and retries are on, the stream is run once
+// more with a higher output limit and a note telling the model why its call
+// was dropped. The text and reasoning of the first stream were already
+// published, so they are kept: the retry sees them as its own earlier
+// output and continues after them, and only the cut-off call is discarded.
+// The partial text of a cancelled stream is passed on with the error.
+func (a *Agent) collectCalls(ctx context.Context, history []model.Message, defs []provider.ToolDef, choice provider.ToolChoice) (string, string, []ToolCallPart, *provider.UsageInfo, error) {
+
+	text, reasoning, calls, usage, err := a.collectStream(ctx, a.provider, history, defs, choice, a.replyDeltas())
+	if err != nil {
+		return text, reasoning, nil, nil, err
+	}
+	cut := truncatedCall(calls)
+	if cut == nil {
+		return text, reasoning, calls, usage, nil
+	}
+	a.tracef("tool_call_truncated id=%s name=%s", cut.ID, cut.Name)
+	if a.truncatedCalls != TruncatedCallsRetry {
+		return "", "", nil, nil, fmt.Errorf("%s (%s): %w", cut.Name, cut.ID, errTruncatedToolCall)
+	}
+	retry := history[:len(history):len(history)]
+	if text != "" || reasoning != "" {
+		retry = append(retry, Message{ID: uuid.NewString(), Role: RoleAssistant, Parts: buildAssistantParts(text, reasoning, nil), CreatedAt: time.Now().UTC()})
+	}
+	content := fmt.Sprintf("Your %s call was cut off before its arguments were complete, so it was not run. Call it again with shorter arguments, splitting large content across calls.", cut.Name)
+	if cut.Streamed != "" {
+		content += fmt.Sprintf(" These paragraphs of it were already delivered, so do not send them again:\n\n%s", strings.TrimSpace(cut.Streamed))
+	}
+	note := newUserMessage(formatInput(Input{Source: "system", Content: content}))
+	retry = append(retry, *note)
+	limit := provider.WithMaxTokens(ctx, truncatedRetryTokens*a.provider.Model().MaxOutput)
+	more, moreReasoning, calls, moreUsage, err := a.collectStream(limit, a.provider, retry, defs, choice, a.replyDeltas())
+	text, reasoning = text+more, reasoning+moreReasoning
+	if err != nil {
+		return text, reasoning, nil, nil, err
+	}
+	if cut := truncatedCall(calls); cut != nil {
+		return "", "", nil, nil, fmt.Errorf("%s (%s) after retry: %w", cut.Name, cut.ID, errTruncatedToolCall)
+	}
+	return text, reasoning, calls, addUsage(usage, moreUsage), nil
+}
+
+// addUsage sums the usage of two requests; either may be nil.
+func addUsage(a, b *provider.UsageInfo) *provider.UsageInfo {
+
+	if a == nil || b == nil {
+		if a == nil {
+			return b
+		}
+		return a
+	}
+	return &provider.UsageInfo{
+		PromptTokens:     a.PromptTokens + b.PromptTokens,
+		CompletionTokens: a.CompletionTokens + b.CompletionTokens,
+		CacheReadTokens:  a.CacheReadTokens + b.CacheReadTokens,
+		CacheWriteTokens: a.CacheWriteTokens + b.CacheWriteTokens,
+		Cost:             a.Cost + b.Cost,
+	}
+}
+
+// truncatedCall returns the first call whose arguments are incomplete JSON,
+// or nil when every call is complete.
+func truncatedCall(calls []ToolCallPart) *ToolCallPart {
+
+	for i := range calls {
+		if incompleteJSON(string(calls[i].Parameters)) {
+			return &calls[i]
+		}
+	}
+	return nil
+}
+
+// incompleteJSON reports whether raw stops inside a string or with objects
+// or arrays left open, as arguments cut off mid-delta do.
+func incompleteJSON(raw string) bool {
+
+	depth := 0
+	inString, escaped := false, false
+	for _, r := range strings.TrimSpace(raw) {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && r == '\\':
+			escaped = true
+		case r == '"':
+			inString = !inString
+		case inString:
+		case r == '{' || r == '[':
+			depth++
+		case r == '}' || r == ']':
+			depth--
+		}
+	}
+	return inString || depth > 0
+}
@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+func truncatedStream() streamScript {
+	return eventStream(
+		provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "call_1", ToolName: "echo"},
+		provider.ProviderEvent{Type: provider.EventToolUseDelta, ToolCallID: "call_1", Delta: `{"path":"notes.md",`},
+		provider.ProviderEvent{Type: provider.EventToolUseDelta, ToolCallID: "call_1", Delta: `"content":"a long {`},
+		provider.ProviderEvent{Type: provider.EventComplete},
+	)
+}
+
+func TestIncompleteJSON(t *testing.T) {
+	cases := map[string]bool{
+		`{}`:                     false,
+		`{"a":"}{"}`:             false,
+		`{"a":["x",{"b":"\""}]}`: false,
+		`{"a":`:                  true,
+		`{"a":"unterminated`:     true,
+		`{"a":"esc\"`:            true,
+		`{"a":[1,2`:              true,
+	}
+	for raw, want := range cases {
+		if got := incompleteJSON(raw); got != want {
+			t.Fatalf("incompleteJSON(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestTruncatedToolCallRetriesWithNote(t *testing.T) {
+	s := openAgentStore(t)
+	echo := &echoTool{}
+	p := &scriptedProvider{streams: []streamScript{
+		truncatedStream(),
+		reusedIDStream("echo", `{"path":"notes.md"}`),
+		reusedIDStream("sleep", `{}`),
+	}}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{echo, &sleepTool{}}, p)
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "write it"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	calls := echo.Calls()
+	if len(calls) != 1 || string(calls[0].Parameters) != `{"path":"notes.md"}` {
+		t.Fatalf("expected only the complete call to run, got %+v", calls)
+	}
+	retry := p.seenMessages[1]
+	note := retry[len(retry)-1]
+	if note.Role != model.RoleUser || !strings.Contains(textPart(&note), "echo call was cut off") {
+		t.Fatalf("expected retry to carry a cut-off note, got %+v", note)
+	}
+	for _, msg := range listMessages(t, s) {
+		for _, part := range msg.Parts {
+			if c, ok := part.(model.ToolCallPart); ok && strings.Contains(string(c.Parameters), "a long") {
+				t.Fatalf("truncated call was stored: %+v", c)
+			}
+		}
+	}
+}
+
+func TestTruncatedToolCallKeepsPublishedText(t *testing.T) {
+	s := openAgentStore(t)
+	echo := &echoTool{}
+	first := eventStream(
+		provider.ProviderEvent{Type: provider.EventContentDelta, Delta: "Writing it. "},
+		provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "call_1", ToolName: "echo"},
+		provider.ProviderEvent{Type: provider.EventToolUseDelta, ToolCallID: "call_1", Delta: `{"path":`},
+		provider.ProviderEvent{Type: provider.EventComplete},
+	)
+	retry := eventStream(
+		provider.ProviderEvent{Type: provider.EventContentDelta, Delta: "Shorter now."},
+		provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "call_2", ToolName: "echo"},
+		provider.ProviderEvent{Type: provider.EventToolUseDelta, ToolCallID: "call_2", Delta: `{"path":"notes.md"}`},
+		provider.ProviderEvent{Type: provider.EventComplete},
+	)
+	p := &scriptedProvider{streams: []streamScript{first, retry, reusedIDStream("sleep", `{}`)}}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{echo, &sleepTool{}}, p)
+	if err := a.RunOnce(context.Background(), Input{Source: "api", Content: "write it"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	seen := p.seenMessages[1]
+	partial := seen[len(seen)-2]
+	if partial.Role != model.RoleAssistant || textPart(&partial) != "Writing it. " {
+		t.Fatalf("expected retry to see the published text, got %+v", partial)
+	}
+	var stored string
+	for _, msg := range listMessages(t, s) {
+		if msg.Role == model.RoleAssistant && len(msg.Parts) > 0 {
+			if text := textPart(msg); text != "" {
+				stored = text
+				break
+			}
+		}
+	}
+	if stored != "Writing it. Shorter now." {
+		t.Fatalf("expected both attempts' text to be stored, got %q", stored)
+	}
+}
+
+func TestTruncatedToolCallErrorMode(t *testing.T) {
+	s := openAgentStore(t)
+	echo := &echoTool{}
+	p := &scriptedProvider{streams: []streamScript{truncatedStream()}}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{echo, &sleepTool{}}, p)
+	a.SetTruncatedCalls(TruncatedCallsError)
+	err := a.RunOnce(context.Background(), Input{Source: "api", Content: "write it"})
+	if !errors.Is(err, errTruncatedToolCall) || !strings.Contains(err.Error(), "echo (call_1)") {
+		t.Fatalf("expected truncated call error, got %v", err)
+	}
+	if len(echo.Calls()) != 0 || p.CallCount() != 1 {
+		t.Fatalf("expected no tool run and no retry, calls=%d streams=%d", len(echo.Calls()), p.CallCount())
+	}
+}
+
+func TestTruncatedToolCallFailsAfterOneRetry(t *testing.T) {
+	s := openAgentStore(t)
+	echo := &echoTool{}
+	p := &scriptedProvider{streams: []streamScript{truncatedStream(), truncatedStream()}}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{echo, &sleepTool{}}, p)
+	err := a.RunOnce(context.Background(), Input{Source: "api", Content: "write it"})
+	if !errors.Is(err, errTruncatedToolCall) || !strings.Contains(err.Error(), "after retry") {
+		t.Fatalf("expected truncated call error after retry, got %v", err)
+	}
+	if len(echo.Calls()) != 0 {
+		t.Fatalf("truncated call ran: %+v", echo.Calls())
+	}
+}
@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrGenerationTimeout is wrapped by the error that ends a turn which ran
+// past its generation timeout. Callers match it with errors.Is.
+var ErrGenerationTimeout = errors.New("generation timed out")
+
+// SetGenerationTimeout caps how long one turn may run, from taking its
+// inputs through every tool round. A turn that runs longer is cancelled and
+// ends with an error wrapping ErrGenerationTimeout. Zero means no limit.
+func (a *Agent) SetGenerationTimeout(d time.Duration) {
+
+	a.generationTimeout = d
+}
+
+// withGenerationTimeout returns ctx bounded by the generation timeout and a
+// function that rewrites the turn's error when that deadline, rather than
+// the caller, cut it short.
+func (a *Agent) withGenerationTimeout(ctx context.Context) (context.Context, context.CancelFunc, func(error) error) {
+
+	if a.generationTimeout <= 0 {
+		return ctx, func() {}, func(err error) error { return err }
+	}
+	turnCtx, cancel := context.WithTimeout(ctx, a.generationTimeout)
+	wrap := func(err error) error {
+		if err == nil || ctx.Err() != nil || !errors.Is(turnCtx.Err(), context.DeadlineExceeded) {
+			return err
+		}
+		a.tracef("generation_timeout after=%s", a.generationTimeout)
+		return fmt.Errorf("%w after %s", ErrGenerationTimeout, a.generationTimeout)
+	}
+	return turnCtx, cancel, wrap
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+// slowTool takes a fixed time unless its context ends first.
+type slowTool struct {
+	echoTool
+	delay time.Duration
+}
+
+func (t *slowTool) Run(ctx context.Context, call model.ToolCallPart) (tooling.ToolResult, error) {
+	select {
+	case <-time.After(t.delay):
+		return tooling.ToolResult{Content: call.ID}, nil
+	case <-ctx.Done():
+		return tooling.ToolResult{}, ctx.Err()
+	}
+}
+
+func TestGenerationTimeoutCutsOffTurn(t *testing.T) {
+	s := openAgentStore(t)
+	streams := make([]streamScript, 0, 20)
+	for i := range 20 {
+		streams = append(streams, echoCallStream(fmt.Sprintf("call%d", i)))
+	}
+	p := &scriptedProvider{streams: streams}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{&slowTool{delay: 40 * time.Millisecond}}, p)
+	a.SetGenerationTimeout(150 * time.Millisecond)
+	events, unsub := a.Events().Subscribe()
+	defer unsub()
+
+	start := time.Now()
+	if err := a.Inject(Input{Source: "api", Content: "keep going"}); err != nil {
+		t.Fatalf("inject: %v", err)
+	}
+	var got AgentEvent
+	for got.Type != EventError {
+		select {
+		case got = <-events:
+		case <-time.After(2 * time.Second):
+			t.Fatal("turn was not cut off")
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("turn ran for %s past a 150ms timeout", elapsed)
+	}
+	if !errors.Is(got.Error, ErrGenerationTimeout) || got.Error.Error() != "generation timed out after 150ms" {
+		t.Fatalf("unexpected error event: %v", got.Error)
+	}
+	deadline := time.Now().Add(time.Second)
+	for a.IsActive() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if a.IsActive() {
+		t.Fatal("agent still active after the timeout")
+	}
+	if err, _ := a.LastError(); !errors.Is(err, ErrGenerationTimeout) {
+		t.Fatalf("last error = %v", err)
+	}
+	if calls := p.CallCount(); calls < 2 || calls > 6 {
+		t.Fatalf("provider calls = %d, want the turn stopped after a few rounds", calls)
+	}
+}
+
+func TestCancelIsNotReportedAsGenerationTimeout(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{echoCallStream("c1")}}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{&slowTool{delay: time.Minute}}, p)
+	a.SetGenerationTimeout(time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := a.RunOnce(ctx, Input{Source: "api", Content: "go"})
+	if err == nil || errors.Is(err, ErrGenerationTimeout) {
+		t.Fatalf("caller's deadline reported as %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package agent
+
+import "strings"
+
+// TurnResult is how the turn that handled an input ended: the text sent to
+// the input's source with Reply during that turn, and the error that ended
+// it.
+type TurnResult struct {
+	Reply string
+	Err   error
+}
+
+type turnWaiter struct {
+	source string
+	done   chan TurnResult
+	reply  []string
+}
+
+// AwaitTurn returns a channel that receives the result of the turn that
+// handles in, once that turn ends. Unlike the event stream it never drops
+// the result. Call it before the input is queued, and call cancel when no
+// longer waiting.
+func (a *Agent) AwaitTurn(in Input) (<-chan TurnResult, func()) {
+
+	w := &turnWaiter{source: in.Source, done: make(chan TurnResult, 1)}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.waiters == nil {
+		a.waiters = map[string]*turnWaiter{}
+	}
+	a.waiters[in.ID] = w
+	return w.done, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.waiters[in.ID] == w {
+			delete(a.waiters, in.ID)
+		}
+	}
+}
+
+// Reply adds text to the reply of every waited-on input of the running turn
+// that came from source. It reports whether any was waiting.
+func (a *Agent) Reply(source, text string) bool {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ok := false
+	for _, id := range a.turnInputs {
+		if w, found := a.waiters[id]; found && w.source == source {
+			w.reply = append(w.reply, text)
+			ok = true
+		}
+	}
+	return ok
+}
+
+// endTurnWaits delivers the turn's result to the waiters of inputs.
+func (a *Agent) endTurnWaits(inputs []Input, err error) {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, in := range inputs {
+		if w, ok := a.waiters[in.ID]; ok {
+			w.done <- TurnResult{Reply: strings.Join(w.reply, "\n\n"), Err: err}
+			delete(a.waiters, in.ID)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+func TestAwaitTurnDeliversReplyWhenEventsAreDropped(t *testing.T) {
+	s := openAgentStore(t)
+	p := &scriptedProvider{streams: []streamScript{
+		textStream("private notes"),
+		reusedIDStream("echo", `{}`),
+		reusedIDStream("sleep", `{}`),
+	}}
+	reply := &echoTool{}
+	a := NewAgent(s.MessageStore(), []tooling.Tool{reply, &sleepTool{}}, p)
+	other := true
+	reply.runFn = func(context.Context) {
+		a.Reply("webhook:deploy", "deployed v2")
+		other = a.Reply("webhook:other", "lost")
+	}
+	// A subscriber that never reads fills its buffer, so the broker drops
+	// the events of this turn.
+	_, unsub := a.Events().Subscribe()
+	defer unsub()
+	for range 64 {
+		a.Events().Publish(AgentEvent{Type: EventDelta, Text: "x"})
+	}
+	in := Input{ID: "in-1", Source: "webhook:deploy", Content: "ship it"}
+	done, cancel := a.AwaitTurn(in)
+	defer cancel()
+
+	if err := a.RunOnce(context.Background(), in); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	if other {
+		t.Fatal("a reply to a source nobody waits on was accepted")
+	}
+	select {
+	case res := <-done:
+		if res.Err != nil || res.Reply != "deployed v2" {
+			t.Fatalf("unexpected turn result: %+v", res)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("turn result was not delivered")
+	}
+}
+
+func TestAwaitTurnCancelStopsWaiting(t *testing.T) {
+	s := openAgentStore(t)
+	a := NewAgent(s.MessageStore(), nil, &scriptedProvider{streams: []streamScript{textStream("ok")}})
+	a.SetNoToolSleepRounds(1)
+	in := Input{ID: "in-1", Source: "api", Content: "hi"}
+	done, cancel := a.AwaitTurn(in)
+	cancel()
+
+	if err := a.RunOnce(context.Background(), in); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	select {
+	case res := <-done:
+		t.Fatalf("cancelled wait received %+v", res)
+	default:
+	}
+	if len(a.waiters) != 0 {
+		t.Fatalf("waiters left behind: %v", a.waiters)
+	}
+}
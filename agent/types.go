@@ -20,6 +20,7 @@ type ToolCallPart = model.ToolCallPart
 type ToolResultPart = model.ToolResultPart
 type FinishPart = model.FinishPart
 type BinaryPart = model.BinaryPart
+type ImageURLPart = model.ImageURLPart
 
 type ToolCall = model.ToolCall
 type ToolResult = model.ToolResult
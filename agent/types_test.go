@@ -59,6 +59,14 @@ func assertPartEqual(t *testing.T, want, got MessagePart) {
 		if w.MimeType != g.MimeType || !reflect.DeepEqual(w.Data, g.Data) {
 			t.Fatalf("binary part mismatch: %v != %v", w, g)
 		}
+	case ImageURLPart:
+		g, ok := got.(ImageURLPart)
+		if !ok {
+			t.Fatalf("part type mismatch: expected ImageURLPart, got %T", got)
+		}
+		if !reflect.DeepEqual(w, g) {
+			t.Fatalf("image url part mismatch: %v != %v", w, g)
+		}
 	default:
 		t.Fatalf("unknown part type %T", want)
 	}
@@ -145,6 +153,31 @@ func TestMessagePartJSONRoundTripBinary(t *testing.T) {
 	assertPartEqual(t, want, got.Parts[0])
 }
 
+func TestMessagePartJSONRoundTripImageURL(t *testing.T) {
+	want := ImageURLPart{URL: "https://example.com/cat.png", MimeType: "image/png", Data: []byte("png")}
+	msg := Message{
+		ID:        "m7",
+		Role:      RoleUser,
+		CreatedAt: time.Date(2026, 2, 20, 12, 0, 0, 0, time.UTC),
+		Parts:     []MessagePart{want},
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if !strings.Contains(string(b), `"type":"image_url"`) {
+		t.Fatalf("missing type discriminator for image url part")
+	}
+	var got Message
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if len(got.Parts) != 1 {
+		t.Fatalf("expected one part, got %d", len(got.Parts))
+	}
+	assertPartEqual(t, want, got.Parts[0])
+}
+
 func TestMessagePartJSONRoundTripReasoning(t *testing.T) {
 	want := ReasoningPart{Text: "thinking step"}
 	msg := Message{
@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"os"
@@ -20,6 +19,8 @@ import (
 	"time"
 
 	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/logging"
+	"github.com/agusx1211/miclaw/tools"
 )
 
 const (
@@ -42,12 +43,13 @@ func (e *exitCodeError) Error() string {
 }
 
 type hostExecRequest struct {
-	Command     string   `json:"command"`
-	Args        []string `json:"args,omitempty"`
-	WorkingDir  string   `json:"working_dir,omitempty"`
-	Input       string   `json:"input,omitempty"`
-	TimeoutSec  int      `json:"timeout_sec,omitempty"`
-	ContainerID string   `json:"container_id,omitempty"`
+	Command     string            `json:"command"`
+	Args        []string          `json:"args,omitempty"`
+	WorkingDir  string            `json:"working_dir,omitempty"`
+	Input       string            `json:"input,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	TimeoutSec  int               `json:"timeout_sec,omitempty"`
+	ContainerID string            `json:"container_id,omitempty"`
 }
 
 type hostExecResponse struct {
@@ -71,12 +73,15 @@ type hostCommandServerConfig struct {
 	SocketPath string
 	Workspace  string
 	Allowed    []string
-	Mounts     []config.Mount
-	HostUser   string
+	// Env lists, by command, the variables a request may set.
+	Env      map[string][]string
+	Mounts   []config.Mount
+	HostUser string
 }
 
 type hostCommandHandler struct {
 	allowed   map[string]bool
+	env       map[string]map[string]bool
 	mounts    []hostPathMount
 	workspace string
 	hostUser  string
@@ -108,6 +113,7 @@ func startHostCommandServer(cfg hostCommandServerConfig) (*hostCommandServer, er
 	}
 	handler := hostCommandHandler{
 		allowed:   hostAllowedSet(cfg.Allowed),
+		env:       hostEnvSets(cfg.Env),
 		mounts:    mounts,
 		workspace: cfg.Workspace,
 		hostUser:  cfg.HostUser,
@@ -122,7 +128,7 @@ func startHostCommandServer(cfg hostCommandServerConfig) (*hostCommandServer, er
 func serveHostCommandServer(server *http.Server, ln net.Listener) {
 	err := server.Serve(ln)
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
-		log.Printf("[sandbox] host command server error: %v", err)
+		logging.Errorf("[sandbox] host command server error: %v", err)
 	}
 }
 
@@ -157,6 +163,14 @@ func hostAllowedSet(commands []string) map[string]bool {
 	return allowed
 }
 
+func hostEnvSets(env map[string][]string) map[string]map[string]bool {
+	sets := map[string]map[string]bool{}
+	for command, names := range env {
+		sets[command] = hostAllowedSet(names)
+	}
+	return sets
+}
+
 func normalizeHostPathMounts(workspace string, mounts []config.Mount) ([]hostPathMount, error) {
 	out := []hostPathMount{{container: cleanContainerPath(workspace), host: workspace}}
 	for _, mount := range mounts {
@@ -197,6 +211,12 @@ func (h hostCommandHandler) handleExecute(w http.ResponseWriter, r *http.Request
 		writeHostError(w, http.StatusForbidden, fmt.Sprintf("host command %q is not allowed", req.Command))
 		return
 	}
+	for name := range req.Env {
+		if !h.env[req.Command][name] {
+			writeHostError(w, http.StatusForbidden, fmt.Sprintf("env %q is not allowed for host command %q", name, req.Command))
+			return
+		}
+	}
 	hostDir, err := h.resolveWorkingDir(req.WorkingDir)
 	if err != nil {
 		writeHostError(w, http.StatusBadRequest, err.Error())
@@ -207,7 +227,7 @@ func (h hostCommandHandler) handleExecute(w http.ResponseWriter, r *http.Request
 		writeHostError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	log.Printf(
+	logging.Infof(
 		"[sandbox] host command container=%s user=%s cmd=%s args=%d exit=%d",
 		req.ContainerID, h.hostUser, req.Command, len(req.Args), resp.ExitCode,
 	)
@@ -269,6 +289,9 @@ func runHostCommand(ctx context.Context, req hostExecRequest, hostDir string) (h
 		command.Stdin = strings.NewReader(req.Input)
 	}
 	command.Env = hostCommandEnv()
+	for name, value := range req.Env {
+		command.Env = append(command.Env, name+"="+value)
+	}
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
 	command.Stdout = &stdout
@@ -313,6 +336,7 @@ func runHostExecClientWithInput(args []string, stdin io.Reader, stdinTTY bool, s
 	req := hostExecRequest{
 		Command:    args[0],
 		Args:       args[1:],
+		Env:        forwardedEnv(),
 		TimeoutSec: hostExecTimeout(),
 	}
 	if !stdinTTY {
@@ -354,6 +378,24 @@ func runHostExecClientWithInput(args []string, stdin io.Reader, stdinTTY bool, s
 	return nil
 }
 
+// forwardedEnv collects the variables named in tools.HostExecForwardEnv.
+func forwardedEnv() map[string]string {
+	var env map[string]string
+	for _, name := range strings.Split(os.Getenv(tools.HostExecForwardEnv), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			if env == nil {
+				env = map[string]string{}
+			}
+			env[name] = value
+		}
+	}
+	return env
+}
+
 func hostExecTimeout() int {
 	raw := strings.TrimSpace(os.Getenv(hostExecTimeoutEnv))
 	if raw == "" {
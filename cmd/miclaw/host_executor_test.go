@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/tools"
 )
 
 func TestHostCommandServerRejectsDisallowedCommand(t *testing.T) {
@@ -147,6 +148,74 @@ func TestHostCommandServerForwardsInputToCommand(t *testing.T) {
 	}
 }
 
+func TestHostCommandServerAppliesRequestEnv(t *testing.T) {
+	root := t.TempDir()
+	sock := filepath.Join(root, "host-executor.sock")
+	srv, err := startHostCommandServer(hostCommandServerConfig{
+		SocketPath: sock,
+		Workspace:  root,
+		Allowed:    []string{"printenv"},
+		Env:        map[string][]string{"printenv": {"DEPLOY_TOKEN"}},
+		Mounts: []config.Mount{
+			{Host: root, Container: "/workspace", Mode: "rw"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("start host command server: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := srv.Close(); err != nil {
+			t.Fatalf("close host command server: %v", err)
+		}
+	})
+
+	status, body, err := hostExecHTTPCall(sock, hostExecRequest{
+		Command:    "printenv",
+		Args:       []string{"DEPLOY_TOKEN"},
+		WorkingDir: "/workspace",
+		TimeoutSec: 5,
+		Env:        map[string]string{"DEPLOY_TOKEN": "amber"},
+	})
+	if err != nil {
+		t.Fatalf("proxy call failed: %v", err)
+	}
+	var resp hostExecResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil || status != http.StatusOK {
+		t.Fatalf("status = %d, body=%q", status, body)
+	}
+	if resp.Stdout != "amber\n" {
+		t.Fatalf("stdout = %q", resp.Stdout)
+	}
+
+	for _, name := range []string{"BASH_ENV", "GIT_SSH_COMMAND", "LD_PRELOAD", "PATH"} {
+		status, body, err = hostExecHTTPCall(sock, hostExecRequest{
+			Command:    "printenv",
+			WorkingDir: "/workspace",
+			Env:        map[string]string{name: "/tmp/x"},
+		})
+		if err != nil {
+			t.Fatalf("proxy call failed: %v", err)
+		}
+		if status != http.StatusForbidden || !strings.Contains(body, "not allowed") {
+			t.Fatalf("%s: status = %d, body=%q", name, status, body)
+		}
+	}
+}
+
+func TestForwardedEnvReadsNamedVariables(t *testing.T) {
+	t.Setenv("DEPLOY_TOKEN", "amber")
+	t.Setenv("REGION", "")
+	t.Setenv(tools.HostExecForwardEnv, "DEPLOY_TOKEN, REGION,UNSET_VAR_FOR_TEST")
+	got := forwardedEnv()
+	if len(got) != 2 || got["DEPLOY_TOKEN"] != "amber" || got["REGION"] != "" {
+		t.Fatalf("forwarded env = %#v", got)
+	}
+	t.Setenv(tools.HostExecForwardEnv, "")
+	if got := forwardedEnv(); got != nil {
+		t.Fatalf("expected no forwarded env, got %#v", got)
+	}
+}
+
 func TestRunHostExecClientStreamsAndReturnsExitCode(t *testing.T) {
 	root := t.TempDir()
 	sock := filepath.Join(root, "host-executor.sock")
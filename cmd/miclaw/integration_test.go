@@ -5,7 +5,6 @@ package main
 import (
 	"bufio"
 	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,7 +13,6 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"sync"
 	"testing"
 	"time"
 
@@ -76,24 +74,14 @@ func TestIntegrationWebhookToAgentResponse(t *testing.T) {
 		Workspace: workspace,
 		StatePath: statePath,
 	})
-	deps, err := initRuntime(cfgPath)
+	rt, bridge, err := initRuntime(cfgPath)
 	if err != nil {
 		t.Fatalf("init runtime: %v", err)
 	}
-
-	ctx, cancel := context.WithCancel(context.Background())
-	errCh := make(chan error, 2)
-	var wg sync.WaitGroup
 	var stderr bytes.Buffer
-	startMemorySync(ctx, deps, &stderr)
-	if err := startScheduler(ctx, deps); err != nil {
-		t.Fatalf("start scheduler: %v", err)
-	}
-	startSignalPipeline(ctx, deps, &wg, errCh)
-	startWebhookServer(ctx, deps, &wg, errCh)
 	waitForWebhookReady(t, "http://"+listen+"/health")
 
-	events, unsubscribe := deps.agent.Events().Subscribe()
+	events, unsubscribe := rt.Events().Subscribe()
 	defer unsubscribe()
 	res, err := http.Post("http://"+listen+"/test", "text/plain", strings.NewReader("What is 2+2?"))
 	if err != nil {
@@ -103,12 +91,12 @@ func TestIntegrationWebhookToAgentResponse(t *testing.T) {
 	if res.StatusCode != http.StatusAccepted {
 		t.Fatalf("post status = %d", res.StatusCode)
 	}
-	msg := waitForResponseEvent(t, events, errCh, "webhook:test", 60*time.Second)
+	msg := waitForResponseEvent(t, events, rt.Err(), "webhook:test", 60*time.Second)
 	if strings.TrimSpace(messageText(msg)) == "" {
 		t.Fatal("agent response text is empty")
 	}
 
-	shutdown(deps, cancel, &wg, &stderr)
+	shutdown(rt, bridge, &stderr)
 	if !strings.Contains(stderr.String(), "shutdown complete") {
 		t.Fatalf("stderr = %q", stderr.String())
 	}
@@ -126,20 +114,18 @@ func TestIntegrationGracefulShutdown(t *testing.T) {
 		Workspace: workspace,
 		StatePath: filepath.Join(root, "state"),
 	})
-	deps, err := initRuntime(cfgPath)
+	rt, bridge, err := initRuntime(cfgPath)
 	if err != nil {
 		t.Fatalf("init runtime: %v", err)
 	}
-	if _, err := deps.sqlStore.SessionStore().List(1, 0); err != nil {
-		t.Fatalf("list sessions before shutdown: %v", err)
+	if _, err := rt.Messages().List(1, 0); err != nil {
+		t.Fatalf("list messages before shutdown: %v", err)
 	}
 
-	_, cancel := context.WithCancel(context.Background())
-	var wg sync.WaitGroup
 	var stderr bytes.Buffer
-	shutdown(deps, cancel, &wg, &stderr)
-	if _, err := deps.sqlStore.SessionStore().List(1, 0); err == nil {
-		t.Fatal("expected list sessions to fail after shutdown")
+	shutdown(rt, bridge, &stderr)
+	if _, err := rt.Messages().List(1, 0); err == nil {
+		t.Fatal("expected list messages to fail after shutdown")
 	}
 	if !strings.Contains(stderr.String(), "shutdown complete") {
 		t.Fatalf("stderr = %q", stderr.String())
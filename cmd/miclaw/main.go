@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -12,37 +11,15 @@ import (
 	"path/filepath"
 	"runtime/debug"
 	"strings"
-	"sync"
 	"syscall"
-	"time"
 
-	"github.com/agusx1211/miclaw/agent"
+	"github.com/agusx1211/miclaw"
 	"github.com/agusx1211/miclaw/config"
-	"github.com/agusx1211/miclaw/memory"
-	"github.com/agusx1211/miclaw/prompt"
-	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/logging"
 	"github.com/agusx1211/miclaw/setup"
-	signalpipe "github.com/agusx1211/miclaw/signal"
-	"github.com/agusx1211/miclaw/store"
 	"github.com/agusx1211/miclaw/tools"
-	"github.com/agusx1211/miclaw/webhook"
 )
 
-const runtimeLogTextLimit = 180
-const typingKeepaliveInterval = 8 * time.Second
-
-type runtimeDeps struct {
-	cfg         *config.Config
-	sqlStore    *store.SQLiteStore
-	memStore    *memory.Store
-	embedClient *memory.EmbedClient
-	scheduler   *tools.Scheduler
-	agent       *agent.Agent
-	signal      *signalpipe.Client
-	typing      *typingState
-	bridge      *sandboxBridge
-}
-
 type cliFlags struct {
 	configPath     string
 	showVersion    bool
@@ -50,6 +27,10 @@ type cliFlags struct {
 	toolCall       string
 	hostExecClient bool
 	hostExecArgs   []string
+	exportThread   bool
+	importThread   bool
+	period         string
+	tailEvents     int
 }
 
 func main() {
@@ -97,8 +78,18 @@ func run(args []string, stdout, stderr io.Writer) error {
 	if flags.setup {
 		return setup.Run(configPath, os.Stdin, stdout)
 	}
+	if flags.exportThread || flags.importThread {
+		return runThreadTransfer(flags, configPath, os.Stdin, stdout, stderr)
+	}
+	if flags.tailEvents > 0 {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return err
+		}
+		return miclaw.TailEvents(cfg, flags.tailEvents, stdout)
+	}
 
-	deps, err := initRuntime(configPath)
+	rt, bridge, err := initRuntime(configPath)
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) || !stdinIsTTY() {
 			return err
@@ -107,27 +98,16 @@ func run(args []string, stdout, stderr io.Writer) error {
 		if err := setup.Run(configPath, os.Stdin, stdout); err != nil {
 			return err
 		}
-		deps, err = initRuntime(configPath)
+		rt, bridge, err = initRuntime(configPath)
 		if err != nil {
 			return err
 		}
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	errCh := make(chan error, 2)
-	var wg sync.WaitGroup
-
-	startMemorySync(ctx, deps, stderr)
-	if err := startScheduler(ctx, deps); err != nil {
-		return err
-	}
-	startSignalPipeline(ctx, deps, &wg, errCh)
-	startWebhookServer(ctx, deps, &wg, errCh)
-
+	cfg := rt.Config()
 	fmt.Fprintf(stderr, "%s\n", versionString())
-	fmt.Fprintf(stderr, "workspace=%s state=%s backend=%s model=%s\n", deps.cfg.Workspace, deps.cfg.StatePath, deps.cfg.Provider.Backend, deps.cfg.Provider.Model)
-	log.Printf("[trace] compact runtime tracing enabled")
+	fmt.Fprintf(stderr, "workspace=%s state=%s backend=%s model=%s\n", cfg.Workspace, cfg.StatePath, cfg.Provider.Backend, cfg.Provider.Model)
+	logging.Infof("[trace] compact runtime tracing enabled")
 
 	sigCh := make(chan os.Signal, 2)
 	osSignal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -136,11 +116,11 @@ func run(args []string, stdout, stderr io.Writer) error {
 	case sig := <-sigCh:
 		fmt.Fprintf(stderr, "received %s, shutting down\n", sig.String())
 		stopForced := watchSecondSignal(sigCh, stderr)
-		shutdown(deps, cancel, &wg, stderr)
+		shutdown(rt, bridge, stderr)
 		stopForced()
 		return nil
-	case err := <-errCh:
-		shutdown(deps, cancel, &wg, stderr)
+	case err := <-rt.Err():
+		shutdown(rt, bridge, stderr)
 		return err
 	}
 }
@@ -155,9 +135,19 @@ func parseFlags(args []string) (cliFlags, error) {
 	configureRun := fs.Bool("configure", false, "run setup/configuration TUI and exit")
 	toolCall := fs.String("tool-call", "", "internal: execute one tool call and exit")
 	hostExecClient := fs.Bool("host-exec-client", false, "internal: run a host command through sandbox proxy")
+	exportThread := fs.Bool("export-thread", false, "write the thread as JSON to stdout and exit")
+	importThread := fs.Bool("import-thread", false, "read a thread exported with -export-thread from stdin into the empty thread and exit")
+	period := fs.String("period", "", "with -export-thread, export the thread archived under this rotation period")
+	tailEvents := fs.Int("tail-events", 0, "print the newest N events of the store's event log and exit")
 	if err := fs.Parse(args); err != nil {
 		return cliFlags{}, err
 	}
+	if *exportThread && *importThread {
+		return cliFlags{}, fmt.Errorf("-export-thread and -import-thread cannot be combined")
+	}
+	if *tailEvents < 0 {
+		return cliFlags{}, fmt.Errorf("-tail-events must not be negative")
+	}
 	hostExecArgs := fs.Args()
 	if !*hostExecClient && len(hostExecArgs) != 0 {
 		return cliFlags{}, fmt.Errorf("unexpected positional arguments")
@@ -169,546 +159,61 @@ func parseFlags(args []string) (cliFlags, error) {
 		toolCall:       *toolCall,
 		hostExecClient: *hostExecClient,
 		hostExecArgs:   hostExecArgs,
+		exportThread:   *exportThread,
+		importThread:   *importThread,
+		period:         *period,
+		tailEvents:     *tailEvents,
 	}, nil
 }
 
-func initRuntime(configPath string) (*runtimeDeps, error) {
+// runThreadTransfer exports the thread to stdout or imports it from stdin,
+// using the store named by the config, without starting the runtime.
+func runThreadTransfer(flags cliFlags, configPath string, stdin io.Reader, stdout, stderr io.Writer) error {
 
-	path, err := expandHome(configPath)
+	cfg, err := config.Load(configPath)
 	if err != nil {
-		return nil, err
-	}
-	cfg, err := config.Load(path)
-	if err != nil {
-		return nil, err
-	}
-	var bridge *sandboxBridge
-	if cfg.Sandbox.Enabled && !isSandboxChild() {
-		bridge, err = startSandboxBridge(cfg)
-		if err != nil {
-			return nil, err
-		}
-	}
-	cleanupBridge := bridge != nil
-	if bridge != nil {
-		defer func() {
-			if cleanupBridge {
-				_ = bridge.Close()
-			}
-		}()
-	}
-	if err := ensureRuntimePaths(cfg); err != nil {
-		return nil, err
-	}
-	sqlStore, memStore, embedClient, err := openStores(cfg)
-	if err != nil {
-		return nil, err
-	}
-	workspace, skills, err := loadPromptData(cfg.Workspace)
-	if err != nil {
-		return nil, err
-	}
-	var prov provider.LLMProvider
-	switch cfg.Provider.Backend {
-	case "openrouter":
-		prov = provider.NewOpenRouter(cfg.Provider)
-	case "lmstudio":
-		prov = provider.NewLMStudio(cfg.Provider)
-	case "codex":
-		prov = provider.NewCodex(cfg.Provider)
-	default:
-		log.Fatalf("unsupported provider backend %q", cfg.Provider.Backend)
-	}
-	scheduler, err := tools.NewScheduler(filepath.Join(cfg.StatePath, "cron.sqlite"))
-	if err != nil {
-		return nil, err
-	}
-	var signalClient *signalpipe.Client
-	if cfg.Signal.Enabled {
-		baseURL := fmt.Sprintf("http://%s:%d", cfg.Signal.HTTPHost, cfg.Signal.HTTPPort)
-		signalClient = signalpipe.NewClient(baseURL, cfg.Signal.Account)
-	}
-	typing := newTypingState()
-	sendMessage := func(ctx context.Context, to, content string) error {
-		if signalClient == nil {
-			return fmt.Errorf("signal is disabled")
-		}
-		typing.Clear(to)
-		return sendSignalMessage(ctx, signalClient, cfg.Signal, to, content)
-	}
-	var ag *agent.Agent
-	toolList := tools.MainAgentTools(tools.MainToolDeps{
-		Sandbox:     cfg.Sandbox,
-		Memory:      memStore,
-		Embed:       embedClient,
-		Scheduler:   scheduler,
-		SendMessage: sendMessage,
-	})
-	if bridge != nil {
-		toolList = wrapToolsWithSandboxBridge(toolList, bridge)
-	}
-	ag = agent.NewAgent(sqlStore.Messages, toolList, prov)
-	ag.SetNoToolSleepRounds(cfg.NoToolSleepRounds)
-	ag.SetWorkspace(workspace)
-	ag.SetSkills(skills)
-	ag.SetTrace(func(format string, args ...any) {
-		if signalClient != nil {
-			switch format {
-			case "wake":
-				if err := typing.StartAuto(func(callCtx context.Context, target string) error {
-					return sendSignalTyping(callCtx, signalClient, target)
-				}); err != nil {
-					log.Printf("[signal] typing_auto_error err=%v", err)
-				}
-			case "sleep":
-				if err := typing.StopAll(func(callCtx context.Context, target string) error {
-					return sendSignalTypingStop(callCtx, signalClient, target)
-				}); err != nil {
-					log.Printf("[signal] typing_auto_error err=%v", err)
-				}
-			}
-		}
-		log.Printf("[agent] "+format, args...)
-	})
-
-	cleanupBridge = false
-	return &runtimeDeps{
-		cfg:         cfg,
-		sqlStore:    sqlStore,
-		memStore:    memStore,
-		embedClient: embedClient,
-		scheduler:   scheduler,
-		agent:       ag,
-		signal:      signalClient,
-		typing:      typing,
-		bridge:      bridge,
-	}, nil
-}
-
-func ensureRuntimePaths(cfg *config.Config) error {
-	return os.MkdirAll(cfg.Workspace, 0o755)
-}
-
-func openStores(cfg *config.Config) (*store.SQLiteStore, *memory.Store, *memory.EmbedClient, error) {
-
-	if err := os.MkdirAll(cfg.StatePath, 0o755); err != nil {
-		return nil, nil, nil, err
-	}
-	sqlStore, err := store.OpenSQLite(filepath.Join(cfg.StatePath, "sessions.sqlite"))
-	if err != nil {
-		return nil, nil, nil, err
-	}
-	if !cfg.Memory.Enabled {
-		return sqlStore, nil, nil, nil
+		return err
 	}
-	if err := os.MkdirAll(filepath.Join(cfg.StatePath, "memory"), 0o755); err != nil {
-		return nil, nil, nil, err
+	if flags.exportThread {
+		return miclaw.ExportThread(cfg, flags.period, stdout)
 	}
-	memStore, err := memory.Open(filepath.Join(cfg.StatePath, "memory", "agent.sqlite"))
+	n, err := miclaw.ImportThread(cfg, stdin)
 	if err != nil {
-		return nil, nil, nil, err
+		return err
 	}
-	embedClient := memory.NewEmbedClient(cfg.Memory.EmbeddingURL, cfg.Memory.EmbeddingAPIKey, cfg.Memory.EmbeddingModel)
-	return sqlStore, memStore, embedClient, nil
+	fmt.Fprintf(stderr, "imported %d messages\n", n)
+	return nil
 }
 
-func loadPromptData(workspacePath string) (*prompt.Workspace, []prompt.SkillSummary, error) {
+func initRuntime(configPath string) (*miclaw.Runtime, *sandboxBridge, error) {
 
-	workspace, err := prompt.LoadWorkspace(workspacePath)
+	path, err := expandHome(configPath)
 	if err != nil {
 		return nil, nil, err
 	}
-	skills, err := prompt.LoadSkills(workspacePath)
+	cfg, err := config.Load(path)
 	if err != nil {
 		return nil, nil, err
 	}
-	return workspace, skills, nil
-}
-
-func startMemorySync(ctx context.Context, deps *runtimeDeps, stderr io.Writer) {
-
-	if !deps.cfg.Memory.Enabled {
-		return
-	}
-	indexer := memory.NewIndexer(deps.memStore, deps.embedClient)
-	go func() {
-		if err := indexer.Sync(ctx, deps.cfg.Workspace); err != nil {
-			fmt.Fprintf(stderr, "memory sync error: %v\n", err)
-		}
-	}()
-}
-
-func startScheduler(ctx context.Context, deps *runtimeDeps) error {
-
-	if _, err := deps.scheduler.ListJobs(); err != nil {
-		return err
-	}
-	deps.scheduler.Start(ctx, func(source, content string) {
-		if isHeartbeatPrompt(content) && deps.agent.IsActive() {
-			log.Printf("[cron] skip source=%s active=true msg=%q", source, compactRuntimeText(content))
-			return
-		}
-		log.Printf("[cron] in source=%s msg=%q", source, compactRuntimeText(content))
-		deps.agent.Inject(agent.Input{Source: source, Content: content})
-	})
-	return nil
-}
-
-func startSignalPipeline(ctx context.Context, deps *runtimeDeps, wg *sync.WaitGroup, errCh chan<- error) {
-
-	if !deps.cfg.Signal.Enabled {
-		return
-	}
-	pipeline := signalpipe.NewPipeline(
-		deps.signal,
-		deps.cfg.Signal,
-		func(source, content string, metadata map[string]string) {
-			log.Printf("[signal] in source=%s msg=%q", source, compactRuntimeText(content))
-			if handleSignalCommand(ctx, deps, source, content) {
-				return
-			}
-			deps.typing.SetAutoTarget(source)
-			if deps.agent.IsActive() {
-				if err := deps.typing.StartAuto(func(callCtx context.Context, target string) error {
-					return sendSignalTyping(callCtx, deps.signal, target)
-				}); err != nil {
-					log.Printf("[signal] typing_auto_error err=%v", err)
-				}
-			}
-			deps.agent.Inject(agent.Input{Source: source, Content: content, Metadata: metadata})
-		},
-	)
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for {
-			err := pipeline.Start(ctx)
-			if err == nil || errors.Is(err, context.Canceled) {
-				return
-			}
-			if strings.Contains(err.Error(), "signal events stream closed") {
-				log.Printf("[signal] pipeline closed; retrying in 1s")
-				select {
-				case <-ctx.Done():
-					return
-				case <-time.After(time.Second):
-					continue
-				}
-			}
-			errCh <- fmt.Errorf("signal pipeline: %v", err)
-			return
-		}
-	}()
-}
-
-func parseSignalCommand(content string) string {
-	switch strings.ToLower(strings.TrimSpace(content)) {
-	case "/new":
-		return "/new"
-	case "/compact":
-		return "/compact"
-	default:
-		return ""
-	}
-}
-
-func handleSignalCommand(ctx context.Context, deps *runtimeDeps, source, content string) bool {
-	switch parseSignalCommand(content) {
-	case "":
-		return false
-	case "/new":
-		deps.agent.Cancel()
-		deadline := time.Now().Add(3 * time.Second)
-		for deps.agent.IsActive() && time.Now().Before(deadline) {
-			time.Sleep(10 * time.Millisecond)
-		}
-		if deps.agent.IsActive() {
-			_ = sendSignalMessage(ctx, deps.signal, deps.cfg.Signal, source, "agent is busy; try /new again in a few seconds")
-			return true
-		}
-		_ = deps.typing.StopAll(func(callCtx context.Context, target string) error {
-			return sendSignalTypingStop(callCtx, deps.signal, target)
-		})
-		if err := deps.sqlStore.MessageStore().DeleteAll(); err != nil {
-			log.Printf("[signal] command=/new err=%v", err)
-			_ = sendSignalMessage(ctx, deps.signal, deps.cfg.Signal, source, "failed to reset thread")
-			return true
-		}
-		_ = sendSignalMessage(ctx, deps.signal, deps.cfg.Signal, source, "thread reset")
-		return true
-	case "/compact":
-		if deps.agent.IsActive() {
-			_ = sendSignalMessage(ctx, deps.signal, deps.cfg.Signal, source, "agent is busy; try /compact again in a few seconds")
-			return true
-		}
-		_ = sendSignalMessage(ctx, deps.signal, deps.cfg.Signal, source, "compacting context")
-		go func() {
-			compactCtx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-			defer cancel()
-			if err := deps.agent.Compact(compactCtx); err != nil {
-				log.Printf("[signal] command=/compact err=%v", err)
-				_ = sendSignalMessage(context.Background(), deps.signal, deps.cfg.Signal, source, "compaction failed")
-				return
-			}
-			_ = sendSignalMessage(context.Background(), deps.signal, deps.cfg.Signal, source, "compaction complete")
-		}()
-		return true
-	default:
-		return false
-	}
-}
-
-func startWebhookServer(ctx context.Context, deps *runtimeDeps, wg *sync.WaitGroup, errCh chan<- error) {
-
-	if !deps.cfg.Webhook.Enabled {
-		return
-	}
-	srv := webhook.New(deps.cfg.Webhook, func(source, content string, metadata map[string]string) {
-		log.Printf("[webhook] in source=%s msg=%q", source, compactRuntimeText(content))
-		deps.agent.Inject(agent.Input{Source: source, Content: content, Metadata: metadata})
-	})
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := srv.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
-			errCh <- fmt.Errorf("webhook server: %v", err)
-		}
-	}()
-}
-
-func sendSignalMessage(ctx context.Context, client *signalpipe.Client, cfg config.SignalConfig, to, content string) error {
-	log.Printf("[signal] out to=%s msg=%q", to, compactRuntimeText(content))
-	kind, target, err := parseSignalTarget(to)
-	if err != nil {
-		return err
-	}
-	text, styles := signalpipe.MarkdownToSignal(content)
-	limit := cfg.TextChunkLimit
-	if limit <= 0 {
-		limit = len(text)
-	}
-	if kind == "group" {
-		for _, chunk := range signalpipe.ChunkText(text, limit) {
-			if err := client.SendGroup(ctx, target, chunk, styles); err != nil {
-				log.Printf("[signal] out_error to=%s err=%v", to, err)
-				return err
-			}
+	opts := miclaw.Options{Signal: cfg.Signal.Enabled, Webhook: cfg.Webhook.Enabled}
+	var bridge *sandboxBridge
+	if cfg.Sandbox.Enabled && !isSandboxChild() {
+		bridge, err = startSandboxBridge(cfg)
+		if err != nil {
+			return nil, nil, err
 		}
-		log.Printf("[signal] out_ok to=%s", to)
-		return nil
-	}
-	for _, chunk := range signalpipe.ChunkText(text, limit) {
-		if err := client.Send(ctx, target, chunk, styles); err != nil {
-			log.Printf("[signal] out_error to=%s err=%v", to, err)
-			return err
+		opts.WrapTools = func(toolList []tools.Tool) []tools.Tool {
+			return wrapToolsWithSandboxBridge(toolList, bridge)
 		}
 	}
-	log.Printf("[signal] out_ok to=%s", to)
-	return nil
-}
-
-func sendSignalTyping(ctx context.Context, client *signalpipe.Client, to string) error {
-	log.Printf("[signal] typing to=%s", to)
-	kind, target, err := parseSignalTarget(to)
+	rt, err := miclaw.New(cfg, opts)
 	if err != nil {
-		return err
-	}
-	if kind != "dm" {
-		return fmt.Errorf("typing target must be signal:dm:<recipient>")
-	}
-	if err := client.SendTyping(ctx, target); err != nil {
-		log.Printf("[signal] typing_error to=%s err=%v", to, err)
-		return err
-	}
-	log.Printf("[signal] typing_ok to=%s", to)
-	return nil
-}
-
-func sendSignalTypingStop(ctx context.Context, client *signalpipe.Client, to string) error {
-	log.Printf("[signal] typing_stop to=%s", to)
-	kind, target, err := parseSignalTarget(to)
-	if err != nil {
-		return err
-	}
-	if kind != "dm" {
-		return fmt.Errorf("typing target must be signal:dm:<recipient>")
-	}
-	if err := client.SendTypingStop(ctx, target); err != nil {
-		log.Printf("[signal] typing_stop_error to=%s err=%v", to, err)
-		return err
-	}
-	log.Printf("[signal] typing_stop_ok to=%s", to)
-	return nil
-}
-
-func parseSignalTarget(to string) (string, string, error) {
-	parts := strings.SplitN(to, ":", 3)
-	if len(parts) != 3 || parts[0] != "signal" || strings.TrimSpace(parts[2]) == "" {
-		return "", "", fmt.Errorf("invalid signal target %q", to)
-	}
-	if parts[1] != "dm" && parts[1] != "group" {
-		return "", "", fmt.Errorf("invalid signal target %q", to)
-	}
-	return parts[1], strings.TrimSpace(parts[2]), nil
-}
-
-type typingState struct {
-	mu          sync.Mutex
-	active      map[string]chan struct{}
-	autoTarget  string
-	autoPending bool
-}
-
-func newTypingState() *typingState {
-	return &typingState{active: map[string]chan struct{}{}}
-}
-
-func (s *typingState) Start(to string, duration time.Duration, send func(context.Context, string) error) error {
-	if err := send(context.Background(), to); err != nil {
-		return err
-	}
-	stop := make(chan struct{})
-	s.mu.Lock()
-	if prev, ok := s.active[to]; ok {
-		close(prev)
-	}
-	s.active[to] = stop
-	s.mu.Unlock()
-	go func() {
-		ticker := time.NewTicker(typingKeepaliveInterval)
-		defer ticker.Stop()
-		var timeout *time.Timer
-		var timeoutCh <-chan time.Time
-		if duration > 0 {
-			timeout = time.NewTimer(duration)
-			timeoutCh = timeout.C
-		}
-		if timeout != nil {
-			defer timeout.Stop()
-		}
-		defer func() {
-			s.mu.Lock()
-			if s.active[to] == stop {
-				delete(s.active, to)
-			}
-			s.mu.Unlock()
-		}()
-		for {
-			select {
-			case <-stop:
-				return
-			case <-timeoutCh:
-				return
-			case <-ticker.C:
-				s.mu.Lock()
-				live := s.active[to] == stop
-				s.mu.Unlock()
-				if !live {
-					return
-				}
-				_ = send(context.Background(), to)
-			}
-		}
-	}()
-	return nil
-}
-
-func (s *typingState) Clear(to string) {
-	stop, ok := s.take(to)
-	if ok {
-		close(stop)
-	}
-}
-
-func (s *typingState) ClearAll() {
-	stops := s.takeAll()
-	for _, stop := range stops {
-		close(stop)
-	}
-}
-
-func (s *typingState) Stop(to string, stopTyping func(context.Context, string) error) error {
-	s.Clear(to)
-	return stopTyping(context.Background(), to)
-}
-
-func (s *typingState) StopAll(stopTyping func(context.Context, string) error) error {
-	targets := s.activeTargets()
-	s.ClearAll()
-	for _, to := range targets {
-		if err := stopTyping(context.Background(), to); err != nil {
-			return err
+		if bridge != nil {
+			_ = bridge.Close()
 		}
+		return nil, nil, err
 	}
-	return nil
-}
-
-func (s *typingState) take(to string) (chan struct{}, bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	stop, ok := s.active[to]
-	if ok {
-		delete(s.active, to)
-	}
-	return stop, ok
-}
-
-func (s *typingState) takeAll() []chan struct{} {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	stops := make([]chan struct{}, 0, len(s.active))
-	for to, stop := range s.active {
-		delete(s.active, to)
-		stops = append(stops, stop)
-	}
-	return stops
-}
-
-func (s *typingState) activeTargets() []string {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	targets := make([]string, 0, len(s.active))
-	for to := range s.active {
-		targets = append(targets, to)
-	}
-	return targets
-}
-
-func (s *typingState) SetAutoTarget(source string) {
-	kind, id, err := parseSignalTarget(source)
-	if err != nil || kind != "dm" {
-		return
-	}
-	s.mu.Lock()
-	s.autoTarget = "signal:dm:" + id
-	s.autoPending = true
-	s.mu.Unlock()
-}
-
-func (s *typingState) StartAuto(send func(context.Context, string) error) error {
-	s.mu.Lock()
-	to := s.autoTarget
-	pending := s.autoPending
-	s.autoPending = false
-	s.mu.Unlock()
-	if to == "" || !pending {
-		return nil
-	}
-	return s.Start(to, 30*time.Second, send)
-}
-
-func isHeartbeatPrompt(content string) bool {
-	v := strings.ToLower(content)
-	return strings.Contains(v, "heartbeat") || strings.Contains(v, "health check")
-}
-
-func compactRuntimeText(raw string) string {
-
-	clean := strings.Join(strings.Fields(strings.TrimSpace(raw)), " ")
-	if len(clean) <= runtimeLogTextLimit {
-		return clean
-	}
-	return clean[:runtimeLogTextLimit-3] + "..."
+	return rt, bridge, nil
 }
 
 func versionString() string {
@@ -4,24 +4,17 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"reflect"
 	"strings"
-	"sync"
-	"sync/atomic"
 	"testing"
 	"time"
-	"unsafe"
 
-	"github.com/agusx1211/miclaw/agent"
 	"github.com/agusx1211/miclaw/config"
 	"github.com/agusx1211/miclaw/model"
-	"github.com/agusx1211/miclaw/provider"
-	"github.com/agusx1211/miclaw/signal"
 	"github.com/agusx1211/miclaw/store"
-	"github.com/agusx1211/miclaw/tools"
 )
 
 func TestVersionFlag(t *testing.T) {
@@ -98,68 +91,127 @@ func TestHostExecClientFlagParsing(t *testing.T) {
 	}
 }
 
-func TestBuild(t *testing.T) {
+func TestThreadTransferFlagParsing(t *testing.T) {
 	t.Parallel()
 
-	cmd := exec.Command("go", "build", "./cmd/miclaw")
-	cmd.Dir = filepath.Join("..", "..")
-	out, err := cmd.CombinedOutput()
+	flags, err := parseFlags([]string{"--export-thread", "--period", "2026-02"})
 	if err != nil {
-		t.Fatalf("go build ./cmd/miclaw failed: %v\n%s", err, out)
+		t.Fatalf("parse flags: %v", err)
+	}
+	if !flags.exportThread || flags.importThread || flags.period != "2026-02" {
+		t.Fatalf("unexpected flags: %+v", flags)
+	}
+	if _, err := parseFlags([]string{"--export-thread", "--import-thread"}); err == nil {
+		t.Fatal("expected error combining export and import")
 	}
 }
 
-func TestStartSchedulerInjectsCronMessage(t *testing.T) {
+func writeTransferConfig(t *testing.T) (string, string) {
+	t.Helper()
 	root := t.TempDir()
-	scheduler, err := tools.NewScheduler(filepath.Join(root, "cron.db"))
-	if err != nil {
-		t.Fatalf("new scheduler: %v", err)
+	cfg := config.Default()
+	cfg.Provider = config.ProviderConfig{Backend: "lmstudio", Model: "test-model"}
+	cfg.Workspace = filepath.Join(root, "workspace")
+	cfg.StatePath = filepath.Join(root, "state")
+	path := filepath.Join(root, "config.json")
+	if err := config.Save(path, cfg); err != nil {
+		t.Fatalf("save config: %v", err)
 	}
-	t.Cleanup(func() {
-		if err := scheduler.Close(); err != nil {
-			t.Fatalf("close scheduler: %v", err)
-		}
-	})
+	return path, cfg.StatePath
+}
 
-	sqlStore, err := store.OpenSQLite(filepath.Join(root, "messages.db"))
-	if err != nil {
-		t.Fatalf("open sqlite: %v", err)
+func TestExportThreadThenImportIntoAnotherState(t *testing.T) {
+	srcCfg, srcState := writeTransferConfig(t)
+	if err := os.MkdirAll(srcState, 0o755); err != nil {
+		t.Fatal(err)
 	}
-	t.Cleanup(func() {
-		if err := sqlStore.Close(); err != nil {
-			t.Fatalf("close sqlite: %v", err)
+	src, err := store.OpenSQLite(filepath.Join(srcState, "sessions.sqlite"))
+	if err != nil {
+		t.Fatalf("open source store: %v", err)
+	}
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, text := range []string{"hello", "hi there"} {
+		msg := &model.Message{ID: text, Role: model.RoleUser, CreatedAt: at.Add(time.Duration(i) * time.Second),
+			Parts: []model.MessagePart{model.TextPart{Text: text}}}
+		if err := src.Messages.Create(msg); err != nil {
+			t.Fatalf("create: %v", err)
 		}
-	})
-
-	var now atomic.Int64
-	base := time.Date(2026, 2, 21, 10, 0, 0, 0, time.UTC)
-	now.Store(base.UnixNano())
-	setSchedulerField(scheduler, "tick", 10*time.Millisecond)
-	setSchedulerField(scheduler, "now", func() time.Time { return time.Unix(0, now.Load()).UTC() })
+	}
+	if err := src.Close(); err != nil {
+		t.Fatal(err)
+	}
 
-	ag := agent.NewAgent(sqlStore.MessageStore(), nil, cronStubProvider{})
-	t.Cleanup(ag.Cancel)
+	var bundle, stderr bytes.Buffer
+	if err := run([]string{"--config", srcCfg, "--export-thread"}, &bundle, &stderr); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	dstCfg, dstState := writeTransferConfig(t)
+	flags, err := parseFlags([]string{"--config", dstCfg, "--import-thread"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runThreadTransfer(flags, dstCfg, &bundle, io.Discard, &stderr); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if !strings.Contains(stderr.String(), "imported 2 messages") {
+		t.Fatalf("unexpected import output: %q", stderr.String())
+	}
+	dst, err := store.OpenSQLite(filepath.Join(dstState, "sessions.sqlite"))
+	if err != nil {
+		t.Fatalf("open destination store: %v", err)
+	}
+	defer dst.Close()
+	got, err := dst.Messages.List(10, 0)
+	if err != nil || len(got) != 2 || got[1].ID != "hi there" || !got[1].CreatedAt.Equal(at.Add(time.Second)) {
+		t.Fatalf("unexpected imported thread: %v (%v)", got, err)
+	}
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	deps := &runtimeDeps{scheduler: scheduler, agent: ag}
-	if err := startScheduler(ctx, deps); err != nil {
-		t.Fatalf("start scheduler: %v", err)
+func TestTailEventsPrintsNewestEvents(t *testing.T) {
+	cfgPath, state := writeTransferConfig(t)
+	if err := os.MkdirAll(state, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.OpenSQLite(filepath.Join(state, "sessions.sqlite"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, ev := range []store.EventRecord{
+		{Type: "tool_start", Source: "api", Content: `echo {"text":"hi"}`},
+		{Type: "tool_result", Source: "api", Content: "echo: hi"},
+		{Type: "response", Source: "api", Content: "done,\nsee above"},
+	} {
+		ev.CreatedAt = at.Add(time.Duration(i) * time.Second)
+		if err := s.Events().Add(ev); err != nil {
+			t.Fatalf("add event: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
 	}
-	t.Cleanup(scheduler.Stop)
 
-	if _, err := scheduler.AddJob("*/1 * * * *", "ping"); err != nil {
-		t.Fatalf("add job: %v", err)
+	var out bytes.Buffer
+	if err := run([]string{"--config", cfgPath, "--tail-events", "2"}, &out, io.Discard); err != nil {
+		t.Fatalf("tail events: %v", err)
+	}
+	want := "2026-03-01T09:00:01Z tool_result api echo: hi\n2026-03-01T09:00:02Z response api done, see above\n"
+	if out.String() != want {
+		t.Fatalf("tail output = %q, want %q", out.String(), want)
 	}
-	now.Store(base.Add(time.Minute).UnixNano())
+	if _, err := parseFlags([]string{"--tail-events", "-1"}); err == nil {
+		t.Fatal("expected error for a negative -tail-events")
+	}
+}
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
 
-	waitMessageCount(t, sqlStore.MessageStore(), 3, 2*time.Second)
-	msgs, err := sqlStore.MessageStore().List(10, 0)
+	cmd := exec.Command("go", "build", "./cmd/miclaw")
+	cmd.Dir = filepath.Join("..", "..")
+	out, err := cmd.CombinedOutput()
 	if err != nil {
-		t.Fatalf("list messages: %v", err)
-	}
-	if textPart(msgs[0]) != "[cron] ping" {
-		t.Fatalf("unexpected first message: %#v", msgs[0])
+		t.Fatalf("go build ./cmd/miclaw failed: %v\n%s", err, out)
 	}
 }
 
@@ -181,17 +233,14 @@ func TestInitRuntimeCreatesMissingWorkspace(t *testing.T) {
 	if _, err := os.Stat(workspace); !errors.Is(err, os.ErrNotExist) {
 		t.Fatalf("expected missing workspace before init, got: %v", err)
 	}
-	deps, err := initRuntime(cfgPath)
+	rt, _, err := initRuntime(cfgPath)
 	if err != nil {
 		t.Fatalf("init runtime: %v", err)
 	}
 	t.Cleanup(func() {
-		deps.agent.Cancel()
-		_ = deps.scheduler.Close()
-		if deps.memStore != nil {
-			_ = deps.memStore.Close()
+		if err := rt.Shutdown(context.Background()); err != nil {
+			t.Fatalf("shutdown: %v", err)
 		}
-		_ = deps.sqlStore.Close()
 	})
 	info, err := os.Stat(workspace)
 	if err != nil {
@@ -310,6 +359,37 @@ func TestBuildSandboxBridgeRunArgsDeduplicatesIdenticalMounts(t *testing.T) {
 	}
 }
 
+func TestBuildSandboxBridgeRunArgsResourceLimits(t *testing.T) {
+	root := t.TempDir()
+	cfg := config.Default()
+	cfg.Provider = config.ProviderConfig{
+		Backend: "lmstudio",
+		Model:   "test-model",
+	}
+	cfg.Sandbox.Enabled = true
+	cfg.Workspace = filepath.Join(root, "workspace")
+	cfg.StatePath = filepath.Join(root, "state")
+	args, err := buildSandboxBridgeRunArgs(filepath.Join(root, "miclaw"), &cfg)
+	if err != nil {
+		t.Fatalf("build sandbox bridge args: %v", err)
+	}
+	for _, a := range args {
+		if strings.HasPrefix(a, "--memory") || strings.HasPrefix(a, "--cpus") {
+			t.Fatalf("unexpected resource limit %q in %q", a, args)
+		}
+	}
+
+	cfg.Sandbox.MemoryLimit = "512m"
+	cfg.Sandbox.CPULimit = "1.5"
+	args, err = buildSandboxBridgeRunArgs(filepath.Join(root, "miclaw"), &cfg)
+	if err != nil {
+		t.Fatalf("build sandbox bridge args: %v", err)
+	}
+	if !containsArg(args, "--memory=512m") || !containsArg(args, "--cpus=1.5") {
+		t.Fatalf("missing resource limits in %q", args)
+	}
+}
+
 func TestBuildSandboxBridgeRunArgsAddsHostCommandBridge(t *testing.T) {
 	root := t.TempDir()
 	exePath := filepath.Join(root, "miclaw")
@@ -375,359 +455,6 @@ func TestBuildSandboxBridgeRunArgsAddsHostCommandBridge(t *testing.T) {
 	}
 }
 
-func TestSendSignalMessageRoutesDMAndGroup(t *testing.T) {
-	cfg := config.SignalConfig{TextChunkLimit: 0}
-	c := signal.NewClient("http://127.0.0.1:1", "+10000000000")
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
-	defer cancel()
-	if err := sendSignalMessage(ctx, c, cfg, "signal:dm:user-1", "hello"); err == nil {
-		t.Fatal("expected send failure on fake client")
-	}
-	if err := sendSignalMessage(ctx, c, cfg, "signal:group:group-1", "hello"); err == nil {
-		t.Fatal("expected send failure on fake client")
-	}
-	if err := sendSignalMessage(ctx, c, cfg, "invalid", "hello"); err == nil {
-		t.Fatal("expected invalid target error")
-	}
-}
-
-func TestParseSignalTarget(t *testing.T) {
-	tests := []struct {
-		in      string
-		wantK   string
-		wantID  string
-		wantErr bool
-	}{
-		{in: "signal:dm:user-1", wantK: "dm", wantID: "user-1"},
-		{in: "signal:group:group-1", wantK: "group", wantID: "group-1"},
-		{in: "signal:dm:", wantErr: true},
-		{in: "signal:foo:bar", wantErr: true},
-		{in: "bad", wantErr: true},
-	}
-	for _, tt := range tests {
-		kind, id, err := parseSignalTarget(tt.in)
-		if tt.wantErr {
-			if err == nil {
-				t.Fatalf("parseSignalTarget(%q): expected error", tt.in)
-			}
-			continue
-		}
-		if err != nil {
-			t.Fatalf("parseSignalTarget(%q): %v", tt.in, err)
-		}
-		if kind != tt.wantK || id != tt.wantID {
-			t.Fatalf("parseSignalTarget(%q) = (%q, %q), want (%q, %q)", tt.in, kind, id, tt.wantK, tt.wantID)
-		}
-	}
-}
-
-func TestParseSignalCommand(t *testing.T) {
-	tests := []struct {
-		in   string
-		want string
-	}{
-		{in: "/new", want: "/new"},
-		{in: "  /compact  ", want: "/compact"},
-		{in: "/NEW", want: "/new"},
-		{in: "/noop", want: ""},
-		{in: "hello", want: ""},
-	}
-	for _, tt := range tests {
-		got := parseSignalCommand(tt.in)
-		if got != tt.want {
-			t.Fatalf("parseSignalCommand(%q) = %q, want %q", tt.in, got, tt.want)
-		}
-	}
-}
-
-func TestTypingStateClearStopsTypingLoop(t *testing.T) {
-	st := newTypingState()
-	var mu sync.Mutex
-	calls := 0
-	send := func(context.Context, string) error {
-		mu.Lock()
-		calls++
-		mu.Unlock()
-		return nil
-	}
-	if err := st.Start("signal:dm:user-1", 500*time.Millisecond, send); err != nil {
-		t.Fatalf("start typing: %v", err)
-	}
-	deadline := time.Now().Add(200 * time.Millisecond)
-	for {
-		mu.Lock()
-		n := calls
-		mu.Unlock()
-		if n > 0 {
-			break
-		}
-		if time.Now().After(deadline) {
-			t.Fatal("typing did not send initial indicator")
-		}
-		time.Sleep(5 * time.Millisecond)
-	}
-	st.Clear("signal:dm:user-1")
-	mu.Lock()
-	afterClear := calls
-	mu.Unlock()
-	time.Sleep(100 * time.Millisecond)
-	mu.Lock()
-	afterWait := calls
-	mu.Unlock()
-	if afterWait != afterClear {
-		t.Fatalf("typing calls increased after clear: %d -> %d", afterClear, afterWait)
-	}
-}
-
-func TestTypingStateTimeoutRemovesActiveEntry(t *testing.T) {
-	st := newTypingState()
-	send := func(context.Context, string) error { return nil }
-	if err := st.Start("signal:dm:user-1", 60*time.Millisecond, send); err != nil {
-		t.Fatalf("start typing: %v", err)
-	}
-	time.Sleep(120 * time.Millisecond)
-	st.mu.Lock()
-	_, ok := st.active["signal:dm:user-1"]
-	st.mu.Unlock()
-	if ok {
-		t.Fatal("typing entry should expire after timeout")
-	}
-}
-
-func TestTypingStateNoTimeoutKeepsEntryUntilClear(t *testing.T) {
-	st := newTypingState()
-	send := func(context.Context, string) error { return nil }
-	if err := st.Start("signal:dm:user-1", 0, send); err != nil {
-		t.Fatalf("start typing: %v", err)
-	}
-	time.Sleep(120 * time.Millisecond)
-	st.mu.Lock()
-	_, ok := st.active["signal:dm:user-1"]
-	st.mu.Unlock()
-	if !ok {
-		t.Fatal("typing entry should stay active without timeout")
-	}
-	st.Clear("signal:dm:user-1")
-}
-
-func TestTypingStateAutoStartUsesLatestSignalDMSource(t *testing.T) {
-	st := newTypingState()
-	st.SetAutoTarget("signal:group:abc")
-	st.SetAutoTarget("signal:dm:user-1")
-	var mu sync.Mutex
-	calls := 0
-	lastTo := ""
-	send := func(_ context.Context, to string) error {
-		mu.Lock()
-		calls++
-		lastTo = to
-		mu.Unlock()
-		return nil
-	}
-	if err := st.StartAuto(send); err != nil {
-		t.Fatalf("start auto typing: %v", err)
-	}
-	deadline := time.Now().Add(200 * time.Millisecond)
-	for {
-		mu.Lock()
-		n := calls
-		to := lastTo
-		mu.Unlock()
-		if n > 0 {
-			if to != "signal:dm:user-1" {
-				t.Fatalf("auto typing target = %q", to)
-			}
-			break
-		}
-		if time.Now().After(deadline) {
-			t.Fatal("auto typing did not start")
-		}
-		time.Sleep(5 * time.Millisecond)
-	}
-	st.ClearAll()
-}
-
-func TestTypingStateAutoStartRunsOncePerSignalTargetSet(t *testing.T) {
-	st := newTypingState()
-	st.SetAutoTarget("signal:dm:user-1")
-	calls := 0
-	send := func(context.Context, string) error {
-		calls++
-		return nil
-	}
-	if err := st.StartAuto(send); err != nil {
-		t.Fatalf("start auto typing: %v", err)
-	}
-	if err := st.StartAuto(send); err != nil {
-		t.Fatalf("start auto typing second call: %v", err)
-	}
-	if calls != 1 {
-		t.Fatalf("auto typing calls = %d", calls)
-	}
-	st.SetAutoTarget("signal:dm:user-1")
-	if err := st.StartAuto(send); err != nil {
-		t.Fatalf("start auto typing after reset: %v", err)
-	}
-	if calls != 2 {
-		t.Fatalf("auto typing calls after reset = %d", calls)
-	}
-	st.ClearAll()
-}
-
-func TestTypingStateClearAllStopsAllTargets(t *testing.T) {
-	st := newTypingState()
-	var mu sync.Mutex
-	calls := 0
-	send := func(context.Context, string) error {
-		mu.Lock()
-		calls++
-		mu.Unlock()
-		return nil
-	}
-	if err := st.Start("signal:dm:user-1", 0, send); err != nil {
-		t.Fatalf("start typing user-1: %v", err)
-	}
-	if err := st.Start("signal:dm:user-2", 0, send); err != nil {
-		t.Fatalf("start typing user-2: %v", err)
-	}
-	st.ClearAll()
-	mu.Lock()
-	afterClear := calls
-	mu.Unlock()
-	time.Sleep(100 * time.Millisecond)
-	mu.Lock()
-	afterWait := calls
-	mu.Unlock()
-	if afterWait != afterClear {
-		t.Fatalf("typing calls increased after clear all: %d -> %d", afterClear, afterWait)
-	}
-	st.mu.Lock()
-	n := len(st.active)
-	st.mu.Unlock()
-	if n != 0 {
-		t.Fatalf("active entries = %d", n)
-	}
-}
-
-func TestTypingStateStopCallsStopCallback(t *testing.T) {
-	st := newTypingState()
-	send := func(context.Context, string) error { return nil }
-	stopped := ""
-	stop := func(_ context.Context, to string) error {
-		stopped = to
-		return nil
-	}
-	if err := st.Start("signal:dm:user-1", 0, send); err != nil {
-		t.Fatalf("start typing: %v", err)
-	}
-	if err := st.Stop("signal:dm:user-1", stop); err != nil {
-		t.Fatalf("stop typing: %v", err)
-	}
-	if stopped != "signal:dm:user-1" {
-		t.Fatalf("stopped target = %q", stopped)
-	}
-	st.mu.Lock()
-	_, ok := st.active["signal:dm:user-1"]
-	st.mu.Unlock()
-	if ok {
-		t.Fatal("typing entry should be removed after stop")
-	}
-}
-
-func TestTypingStateStopAllCallsStopCallback(t *testing.T) {
-	st := newTypingState()
-	send := func(context.Context, string) error { return nil }
-	stopped := map[string]int{}
-	stop := func(_ context.Context, to string) error {
-		stopped[to]++
-		return nil
-	}
-	if err := st.Start("signal:dm:user-1", 0, send); err != nil {
-		t.Fatalf("start typing user-1: %v", err)
-	}
-	if err := st.Start("signal:dm:user-2", 0, send); err != nil {
-		t.Fatalf("start typing user-2: %v", err)
-	}
-	if err := st.StopAll(stop); err != nil {
-		t.Fatalf("stop all typing: %v", err)
-	}
-	if stopped["signal:dm:user-1"] != 1 || stopped["signal:dm:user-2"] != 1 {
-		t.Fatalf("stop callbacks = %#v", stopped)
-	}
-	st.mu.Lock()
-	n := len(st.active)
-	st.mu.Unlock()
-	if n != 0 {
-		t.Fatalf("active entries = %d", n)
-	}
-}
-
-func TestIsHeartbeatPrompt(t *testing.T) {
-	cases := []struct {
-		in   string
-		want bool
-	}{
-		{"health check", true},
-		{"HEARTBEAT please", true},
-		{"daily report", false},
-	}
-	for _, c := range cases {
-		if got := isHeartbeatPrompt(c.in); got != c.want {
-			t.Fatalf("isHeartbeatPrompt(%q)=%v want %v", c.in, got, c.want)
-		}
-	}
-}
-
-type cronStubProvider struct{}
-
-func (cronStubProvider) Stream(context.Context, []model.Message, []provider.ToolDef) <-chan provider.ProviderEvent {
-	ch := make(chan provider.ProviderEvent, 4)
-	ch <- provider.ProviderEvent{Type: provider.EventContentDelta, Delta: "ok"}
-	ch <- provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "sleep-1", ToolName: "sleep"}
-	ch <- provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: "sleep-1"}
-	ch <- provider.ProviderEvent{Type: provider.EventComplete}
-	close(ch)
-	return ch
-}
-
-func (cronStubProvider) Model() provider.ModelInfo {
-	return provider.ModelInfo{}
-}
-
-func setSchedulerField(s *tools.Scheduler, field string, value any) {
-	v := reflect.ValueOf(s).Elem().FieldByName(field)
-	reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem().Set(reflect.ValueOf(value))
-}
-
-func waitMessageCount(t *testing.T, ms store.MessageStore, want int, timeout time.Duration) {
-	t.Helper()
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		n, err := ms.Count()
-		if err != nil {
-			t.Fatalf("count messages: %v", err)
-		}
-		if n >= want {
-			return
-		}
-		time.Sleep(10 * time.Millisecond)
-	}
-	n, err := ms.Count()
-	if err != nil {
-		t.Fatalf("count messages: %v", err)
-	}
-	t.Fatalf("timed out waiting for %d messages (got %d)", want, n)
-}
-
-func textPart(msg *model.Message) string {
-	for _, part := range msg.Parts {
-		if txt, ok := part.(model.TextPart); ok {
-			return txt.Text
-		}
-	}
-	return ""
-}
-
 func containsArg(args []string, want string) bool {
 	for _, arg := range args {
 		if arg == want {
@@ -6,20 +6,25 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/logging"
 	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/tooling"
 	"github.com/agusx1211/miclaw/tools"
 )
 
 type sandboxBridge struct {
 	containerID string
 	hostServer  *hostCommandServer
+	// secrets is the host path of the exec secrets file, which is not
+	// mounted in the container; env_from is resolved before dispatch.
+	secrets string
 }
 
 type sandboxProxyTool struct {
@@ -27,11 +32,15 @@ type sandboxProxyTool struct {
 	bridge *sandboxBridge
 }
 
+// hostProcessTools manage the host-side exec registry, which is empty when
+// exec runs inside the sandbox, so they are not exposed.
+var hostProcessTools = map[string]bool{"process": true, "bg_list": true, "bg_kill": true}
+
 func wrapToolsWithSandboxBridge(toolList []tools.Tool, bridge *sandboxBridge) []tools.Tool {
 	bridgeable := tools.BridgeableToolNames()
 	out := make([]tools.Tool, 0, len(toolList))
 	for _, t := range toolList {
-		if t.Name() == "process" {
+		if hostProcessTools[t.Name()] {
 			continue
 		}
 		if !bridgeable[t.Name()] {
@@ -55,6 +64,10 @@ func (t sandboxProxyTool) Parameters() tools.JSONSchema {
 	return t.base.Parameters()
 }
 
+func (t sandboxProxyTool) Serial() bool {
+	return tooling.IsSerial(t.base)
+}
+
 func (t sandboxProxyTool) Run(ctx context.Context, call model.ToolCallPart) (tools.ToolResult, error) {
 	return t.bridge.RunTool(ctx, call)
 }
@@ -86,7 +99,7 @@ func startSandboxBridge(cfg *config.Config) (*sandboxBridge, error) {
 			}
 		}()
 	}
-	log.Printf(
+	logging.Infof(
 		"[sandbox] tool bridge enabled network=%s mounts=%d host_commands=%d image=%s",
 		sandboxNetwork(cfg), len(cfg.Sandbox.Mounts), len(cfg.Sandbox.HostCommands), sandboxRuntimeImage,
 	)
@@ -99,9 +112,13 @@ func startSandboxBridge(cfg *config.Config) (*sandboxBridge, error) {
 	if err != nil {
 		return nil, fmt.Errorf("sandbox bridge returned invalid container id: %v\n%s", err, strings.TrimSpace(string(out)))
 	}
-	log.Printf("[sandbox] bridge started id=%s", shortContainerID(id))
+	logging.Infof("[sandbox] bridge started id=%s", shortContainerID(id))
 	closeHostServer = false
-	return &sandboxBridge{containerID: id, hostServer: hostServer}, nil
+	return &sandboxBridge{
+		containerID: id,
+		hostServer:  hostServer,
+		secrets:     filepath.Join(cfg.StatePath, tools.SecretsFile),
+	}, nil
 }
 
 func startSandboxHostCommandServer(cfg *config.Config) (*hostCommandServer, error) {
@@ -132,6 +149,7 @@ func startSandboxHostCommandServer(cfg *config.Config) (*hostCommandServer, erro
 		SocketPath: sandboxHostExecutorSocketPath(stateHostPath),
 		Workspace:  workspaceHostPath,
 		Allowed:    cfg.Sandbox.HostCommands,
+		Env:        cfg.Sandbox.HostCommandEnv,
 		Mounts:     mounts,
 		HostUser:   cfg.Sandbox.HostUser,
 	})
@@ -182,8 +200,33 @@ func buildSandboxBridgeRunArgs(exePath string, cfg *config.Config) ([]string, er
 		"-e", sandboxChildEnv + "=1",
 		"--label", "miclaw.sandbox_bridge=1",
 	}
-	for _, env := range bridgeEnv {
-		args = append(args, "-e", env)
+	args = append(args, sandboxLimitArgs(cfg.Sandbox)...)
+	args = append(args, sandboxMountArgs(mounts, bridgeEnv)...)
+	args = append(
+		args,
+		"--entrypoint", "sh",
+		sandboxRuntimeImage,
+		"-c",
+		"trap 'exit 0' TERM INT; while :; do sleep 3600; done",
+	)
+	return args, nil
+}
+
+func sandboxLimitArgs(s config.SandboxConfig) []string {
+	var args []string
+	if s.MemoryLimit != "" {
+		args = append(args, "--memory="+s.MemoryLimit)
+	}
+	if s.CPULimit != "" {
+		args = append(args, "--cpus="+s.CPULimit)
+	}
+	return args
+}
+
+func sandboxMountArgs(mounts []config.Mount, env []string) []string {
+	var args []string
+	for _, e := range env {
+		args = append(args, "-e", e)
 	}
 	seen := map[string]bool{}
 	for _, m := range mounts {
@@ -194,14 +237,7 @@ func buildSandboxBridgeRunArgs(exePath string, cfg *config.Config) ([]string, er
 		seen[key] = true
 		args = append(args, "--mount", dockerBindMount(m))
 	}
-	args = append(
-		args,
-		"--entrypoint", "sh",
-		sandboxRuntimeImage,
-		"-c",
-		"trap 'exit 0' TERM INT; while :; do sleep 3600; done",
-	)
-	return args, nil
+	return args
 }
 
 func (b *sandboxBridge) RunTool(ctx context.Context, call model.ToolCallPart) (tools.ToolResult, error) {
@@ -214,7 +250,15 @@ func (b *sandboxBridge) RunTool(ctx context.Context, call model.ToolCallPart) (t
 			Content: "sandbox bridge does not support exec background mode",
 		}, nil
 	}
-	log.Printf("[sandbox] tool dispatch name=%s container=%s", call.Name, shortContainerID(b.containerID))
+	var secrets map[string]string
+	if call.Name == "exec" {
+		params, resolved, err := tools.ResolveExecSecrets(b.secrets, call.Parameters)
+		if err != nil {
+			return tools.ToolResult{IsError: true, Content: err.Error()}, nil
+		}
+		call.Parameters, secrets = params, resolved
+	}
+	logging.Debugf("[sandbox] tool dispatch name=%s container=%s", call.Name, shortContainerID(b.containerID))
 	raw, err := json.Marshal(call)
 	if err != nil {
 		return tools.ToolResult{IsError: true, Content: fmt.Sprintf("marshal tool call: %v", err)}, nil
@@ -249,6 +293,7 @@ func (b *sandboxBridge) RunTool(ctx context.Context, call model.ToolCallPart) (t
 		}
 		return tools.ToolResult{IsError: true, Content: msg}, nil
 	}
+	result.Content = tools.RedactSecrets(result.Content, secrets)
 	return result, nil
 }
 
@@ -268,7 +313,7 @@ func (b *sandboxBridge) Close() error {
 				firstErr = fmt.Errorf("stop sandbox bridge %s: %v\n%s", shortContainerID(id), err, text)
 			}
 		} else {
-			log.Printf("[sandbox] bridge stopped id=%s", shortContainerID(id))
+			logging.Infof("[sandbox] bridge stopped id=%s", shortContainerID(id))
 		}
 	}
 	if b.hostServer != nil {
@@ -29,6 +29,8 @@ func TestWrapToolsWithSandboxBridgeWrapsBridgeableAndDropsProcess(t *testing.T)
 	toolList := []tools.Tool{
 		bridgeStubTool{name: "read"},
 		bridgeStubTool{name: "process"},
+		bridgeStubTool{name: "bg_list"},
+		bridgeStubTool{name: "bg_kill"},
 		bridgeStubTool{name: "message"},
 	}
 	got := wrapToolsWithSandboxBridge(toolList, bridge)
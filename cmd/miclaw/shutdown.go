@@ -5,67 +5,32 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"sync"
 	"time"
 
-	"github.com/agusx1211/miclaw/agent"
-	"github.com/agusx1211/miclaw/memory"
-	"github.com/agusx1211/miclaw/store"
-	"github.com/agusx1211/miclaw/tools"
+	"github.com/agusx1211/miclaw"
 )
 
 var (
-	shutdownTimeout      = 30 * time.Second
-	shutdownPollInterval = 10 * time.Millisecond
-	shutdownExit         = os.Exit
-
-	shutdownAgentCancel    = func(a *agent.Agent) { a.Cancel() }
-	shutdownAgentIsActive  = func(a *agent.Agent) bool { return a.IsActive() }
-	shutdownSchedulerStop  = func(s *tools.Scheduler) { s.Stop() }
-	shutdownSchedulerClose = func(s *tools.Scheduler) error {
-		return s.Close()
-	}
-	shutdownMemStoreClose = func(s *memory.Store) error {
-		return s.Close()
-	}
-	shutdownSQLStoreClose = func(s *store.SQLiteStore) error {
-		return s.Close()
-	}
+	// shutdownTimeout is how long the drain may take after the runtime's
+	// grace period before the process exits anyway.
+	shutdownTimeout = 10 * time.Second
+	shutdownExit    = os.Exit
 )
 
-func shutdown(deps *runtimeDeps, cancel context.CancelFunc, wg *sync.WaitGroup, stderr io.Writer) {
+func shutdown(rt *miclaw.Runtime, bridge *sandboxBridge, stderr io.Writer) {
 
-	done := make(chan struct{})
-	timer := time.AfterFunc(shutdownTimeout, func() {
+	grace := time.Duration(rt.Config().Shutdown.GraceSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), grace+shutdownTimeout)
+	defer cancel()
+	if err := rt.Shutdown(ctx); err != nil {
 		fmt.Fprintln(stderr, "shutdown timeout, forcing exit")
 		shutdownExit(1)
-	})
-	go func() {
-		shutdownRun(deps, cancel, wg, stderr)
-		close(done)
-	}()
-	<-done
-	timer.Stop()
-	fmt.Fprintln(stderr, "shutdown complete")
-}
-
-func shutdownRun(deps *runtimeDeps, cancel context.CancelFunc, wg *sync.WaitGroup, stderr io.Writer) {
-
-	shutdownAgentCancel(deps.agent)
-	shutdownSchedulerStop(deps.scheduler)
-	cancel()
-	wg.Wait()
-	for shutdownAgentIsActive(deps.agent) {
-		time.Sleep(shutdownPollInterval)
-	}
-	_ = shutdownSchedulerClose(deps.scheduler)
-	if deps.memStore != nil {
-		_ = shutdownMemStoreClose(deps.memStore)
+		return
 	}
-	_ = shutdownSQLStoreClose(deps.sqlStore)
-	if deps.bridge != nil {
-		_ = deps.bridge.Close()
+	if bridge != nil {
+		_ = bridge.Close()
 	}
+	fmt.Fprintln(stderr, "shutdown complete")
 }
 
 func watchSecondSignal(sigCh <-chan os.Signal, stderr io.Writer) func() {
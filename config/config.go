@@ -2,14 +2,80 @@ package config
 
 // Config is the complete runtime configuration loaded from one JSON file.
 type Config struct {
-	Provider          ProviderConfig `json:"provider"`
-	Signal            SignalConfig   `json:"signal"`
-	Webhook           WebhookConfig  `json:"webhook"`
-	Sandbox           SandboxConfig  `json:"sandbox"`
-	Memory            MemoryConfig   `json:"memory"`
-	Workspace         string         `json:"workspace"`
-	StatePath         string         `json:"state_path"`
-	NoToolSleepRounds int            `json:"no_tool_sleep_rounds"`
+	Provider          ProviderConfig  `json:"provider"`
+	Signal            SignalConfig    `json:"signal"`
+	Webhook           WebhookConfig   `json:"webhook"`
+	Sandbox           SandboxConfig   `json:"sandbox"`
+	Memory            MemoryConfig    `json:"memory"`
+	Tools             ToolsConfig     `json:"tools"`
+	Agent             AgentConfig     `json:"agent"`
+	Store             StoreConfig     `json:"store"`
+	Limits            LimitsConfig    `json:"limits"`
+	Heartbeat         HeartbeatConfig `json:"heartbeat"`
+	Shutdown          ShutdownConfig  `json:"shutdown"`
+	Workspace         string          `json:"workspace"`
+	StatePath         string          `json:"state_path"`
+	NoToolSleepRounds int             `json:"no_tool_sleep_rounds"`
+	// LogLevel is the starting log verbosity: debug, info or error.
+	LogLevel string `json:"log_level"`
+}
+
+// HeartbeatConfig schedules a liveness prompt injected with source
+// "heartbeat". It is skipped while the agent is already busy.
+type HeartbeatConfig struct {
+	Enabled bool `json:"enabled"`
+	// Schedule is a five-field cron expression in tools.cron_timezone.
+	Schedule string `json:"schedule"`
+	Prompt   string `json:"prompt"`
+}
+
+// ShutdownConfig bounds how long a shutdown waits for the running turn.
+type ShutdownConfig struct {
+	// GraceSeconds is how long the current generation may keep running,
+	// with new input refused, before it is cancelled.
+	GraceSeconds int `json:"grace_seconds"`
+}
+
+// StoreConfig selects where the thread and the input queue are kept.
+type StoreConfig struct {
+	// Backend is "sqlite" for sessions.sqlite under state_path, or
+	// "postgres" to share the thread between instances.
+	Backend string `json:"backend"`
+	// PostgresDSN is the connection string used when Backend is "postgres".
+	PostgresDSN string `json:"postgres_dsn"`
+	// SQLite tunes the connections to sessions.sqlite.
+	SQLite SQLiteConfig `json:"sqlite"`
+	// EventLog records agent events in the store's events table, read back
+	// with -tail-events.
+	EventLog bool `json:"event_log"`
+	// EventLogMaxRows is how many of the newest events the log keeps;
+	// older rows are deleted as new ones are written.
+	EventLogMaxRows int `json:"event_log_max_rows"`
+}
+
+// SQLiteConfig sets the pragmas run on every connection to sessions.sqlite
+// and how many connections may be open at once.
+type SQLiteConfig struct {
+	// JournalMode is the journal_mode pragma: wal, delete, truncate or
+	// persist.
+	JournalMode string `json:"journal_mode"`
+	// BusyTimeoutMS is how long a write waits for a lock held by another
+	// connection or process before failing with "database is locked".
+	BusyTimeoutMS int `json:"busy_timeout_ms"`
+	// Synchronous is the synchronous pragma: off, normal or full.
+	Synchronous  string `json:"synchronous"`
+	MaxOpenConns int    `json:"max_open_conns"`
+}
+
+// LimitsConfig caps spending on generations, in dollars at the provider's
+// configured prices; 0 turns a limit off.
+type LimitsConfig struct {
+	// MaxCostPerThread caps the cost of the current thread, counted since
+	// the last /new or rotation.
+	MaxCostPerThread float64 `json:"max_cost_per_thread"`
+	// MaxCostPerDay caps the cost of one calendar day in the agent's
+	// rotation timezone. /unlock resets the day's total.
+	MaxCostPerDay float64 `json:"max_cost_per_day"`
 }
 
 type ProviderConfig struct {
@@ -20,6 +86,51 @@ type ProviderConfig struct {
 	MaxTokens      int    `json:"max_tokens"`
 	ThinkingEffort string `json:"thinking_effort"`
 	Store          bool   `json:"store"`
+	SendReasoning  bool   `json:"send_reasoning"`
+	// APIStyle picks the wire format: "chat" for /chat/completions or
+	// "responses" for /responses; empty keeps the backend's default.
+	APIStyle string `json:"api_style"`
+	// MediaURLs is "pass" to send image and document URLs upstream as they
+	// are, or "download" to fetch them and send the bytes; empty keeps the
+	// backend's default.
+	MediaURLs string `json:"media_urls"`
+	// ContextWindow is the model's context size in tokens; 0 leaves it
+	// unknown and turns automatic compaction off.
+	ContextWindow int `json:"context_window"`
+	// InputCostPerMTok is the price of one million input tokens, in dollars,
+	// used for cost estimates; 0 means unknown.
+	InputCostPerMTok float64 `json:"input_cost_per_mtok"`
+	// OutputCostPerMTok and CacheReadCostPerMTok price output tokens and
+	// prompt tokens read from the cache the same way. On OpenRouter, prices
+	// left at 0 are taken from its model list.
+	OutputCostPerMTok    float64 `json:"output_cost_per_mtok"`
+	CacheReadCostPerMTok float64 `json:"cache_read_cost_per_mtok"`
+	// SummaryModel is a cheaper model on the same backend that writes
+	// compaction, rotation and tool result summaries; empty uses Model. It
+	// is priced at SummaryInputCostPerMTok and SummaryOutputCostPerMTok,
+	// never at the main model's prices; on OpenRouter, prices left at 0
+	// are taken from its model list.
+	SummaryModel             string  `json:"summary_model"`
+	SummaryInputCostPerMTok  float64 `json:"summary_input_cost_per_mtok"`
+	SummaryOutputCostPerMTok float64 `json:"summary_output_cost_per_mtok"`
+	// RetryAttempts caps tries, counting the first, when a request fails
+	// with 500, 502, 503, 504 or a connection error; 0 uses 4. Rate limits
+	// are retried separately.
+	RetryAttempts int `json:"retry_attempts"`
+	// RetryBaseMillis is the first backoff delay, doubled on each retry
+	// unless the response sets Retry-After; 0 uses one second.
+	RetryBaseMillis int `json:"retry_base_ms"`
+	// StreamIdleTimeoutSeconds fails a response stream that sends nothing
+	// for this long, so a hung upstream cannot stall a turn; 0 uses 120.
+	StreamIdleTimeoutSeconds int `json:"stream_idle_timeout_seconds"`
+	// Temperature, TopP and Stop are sent only when set, so unset values
+	// keep the backend's own defaults.
+	Temperature *float64 `json:"temperature"`
+	TopP        *float64 `json:"top_p"`
+	Stop        []string `json:"stop"`
+	// Fallbacks are tried in order when a request fails with a server,
+	// rate-limit, timeout or connection error before any output arrives.
+	Fallbacks []ProviderConfig `json:"fallbacks"`
 }
 
 type SignalConfig struct {
@@ -34,19 +145,195 @@ type SignalConfig struct {
 	Allowlist      []string `json:"allowlist"`
 	TextChunkLimit int      `json:"text_chunk_limit"`
 	MediaMaxMB     int      `json:"media_max_mb"`
+	// StreamResponses delivers message tool calls to Signal targets
+	// paragraph by paragraph while they are generated.
+	StreamResponses bool `json:"stream_responses"`
+	// Preprocess rewrites inbound message text, step by step, before it
+	// reaches the agent.
+	Preprocess []PreprocessStep `json:"preprocess"`
+	// CommandWaitSeconds is how long /new, /purge and /compact wait for the agent to
+	// go idle, or for another of those commands to finish, before replying
+	// that the agent is busy.
+	CommandWaitSeconds int `json:"command_wait_seconds"`
+	// GroupMentionRequired ignores group messages that neither @mention the
+	// account nor reply to one of its messages, outside GroupOpenHours.
+	GroupMentionRequired bool `json:"group_mention_required"`
+	// GroupOpenHours is a daily "HH:MM-HH:MM" window, in GroupTimezone (an
+	// IANA name; empty means UTC), during which every group message is
+	// taken; empty requires mentions all day. "22:00-02:00" spans midnight.
+	GroupOpenHours string `json:"group_open_hours"`
+	GroupTimezone  string `json:"group_timezone"`
+}
+
+// PreprocessStep is one inbound text transform. Kind "wake_word" strips the
+// first of Words found at the start of the message (case-insensitive, with
+// any punctuation and spaces after it); "trim" trims surrounding whitespace.
+type PreprocessStep struct {
+	Kind  string   `json:"kind"`
+	Words []string `json:"words"`
 }
 
 type WebhookConfig struct {
-	Enabled bool         `json:"enabled"`
-	Listen  string       `json:"listen"`
-	Hooks   []WebhookDef `json:"hooks"`
+	Enabled   bool                  `json:"enabled"`
+	Listen    string                `json:"listen"`
+	AuthToken string                `json:"auth_token"`
+	Hooks     []WebhookDef          `json:"hooks"`
+	Outbound  OutboundWebhookConfig `json:"outbound"`
+	// HealthEnabled serves GET /healthz on the listener.
+	HealthEnabled bool `json:"health_enabled"`
+	// SignalDownSeconds is how long the Signal stream may be disconnected
+	// before /healthz reports 503.
+	SignalDownSeconds int `json:"signal_down_seconds"`
+	// MaxBodyBytes caps inbound request bodies; larger ones get 413.
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+	// MaxInFlight caps hook requests handled at once across all hooks;
+	// extra ones get 429.
+	MaxInFlight int `json:"max_in_flight"`
 }
 
 type WebhookDef struct {
-	ID     string `json:"id"`
-	Path   string `json:"path"`
-	Secret string `json:"secret"`
-	Format string `json:"format"`
+	ID              string `json:"id"`
+	Path            string `json:"path"`
+	Secret          string `json:"secret"`
+	SignatureHeader string `json:"signature_header"`
+	AuthToken       string `json:"auth_token"`
+	Format          string `json:"format"`
+	ContentTemplate string `json:"content_template"`
+	ContentPath     string `json:"content_path"`
+	// SessionID replaces the hook ID in the input source ("webhook:<id>"),
+	// so several hooks can share one. A value containing "{{" is a template
+	// over the JSON payload.
+	SessionID string `json:"session_id"`
+	// Metadata is merged into the metadata of every input from this hook.
+	Metadata map[string]string `json:"metadata"`
+	// MetadataFields are dotted paths into a JSON payload whose values are
+	// added to the input's metadata, keyed by the path.
+	MetadataFields []string `json:"metadata_fields"`
+	// Sync holds the request open until the agent's turn for it ends and
+	// answers with the assistant's final text instead of 202.
+	Sync bool `json:"sync"`
+	// SyncTimeoutSeconds bounds how long a sync request waits before it is
+	// answered with 504.
+	SyncTimeoutSeconds int `json:"sync_timeout_seconds"`
+	// MaxRequestsPerMinute rate-limits the hook, allowing bursts of up to a
+	// minute's worth; 0 is unlimited.
+	MaxRequestsPerMinute int `json:"max_requests_per_minute"`
+}
+
+// OutboundWebhookConfig posts agent events to URL. It is active whenever URL
+// is set, independent of the inbound webhook server.
+type OutboundWebhookConfig struct {
+	URL        string   `json:"url"`
+	Token      string   `json:"token"`
+	Events     []string `json:"events"`
+	MaxRetries int      `json:"max_retries"`
+	QueueSize  int      `json:"queue_size"`
+}
+
+type AgentConfig struct {
+	// StartupPrompt is injected once per boot, ahead of any inbound input.
+	StartupPrompt string `json:"startup_prompt"`
+	// Queue caps how much input may wait while the agent is busy.
+	Queue QueueConfig `json:"queue"`
+	// Rotation starts a fresh thread every calendar period.
+	Rotation RotationConfig `json:"rotation"`
+	// ToolCallIDs is "namespace" to prefix provider tool-call ids with the
+	// assistant message id, keeping them unique when a provider reuses ids
+	// across turns, or "provider" to store them unchanged.
+	ToolCallIDs string `json:"tool_call_ids"`
+	// TruncatedToolCalls is "retry" to rerun a generation once when a tool
+	// call's arguments were cut off at stream end, or "error" to fail the
+	// turn. The cut-off call is never run.
+	TruncatedToolCalls string `json:"truncated_tool_calls"`
+	// CompactThreshold is the fraction of the input budget
+	// (provider.context_window minus provider.max_tokens) the history may
+	// reach before the thread is compacted automatically.
+	CompactThreshold float64 `json:"compact_threshold"`
+	// CompactKeepMessages is how many of the newest messages compaction
+	// keeps verbatim after the summary; 0 summarizes everything.
+	CompactKeepMessages int `json:"compact_keep_messages"`
+	// MaxToolRounds caps the tool-call rounds of one turn; past it the model
+	// gets one last round with only the message tool.
+	MaxToolRounds int `json:"max_tool_rounds"`
+	// MaxParallelTools is how many tool calls from one assistant message may
+	// run at once; 1 runs them one after another.
+	MaxParallelTools int `json:"max_parallel_tools"`
+	// CoalesceWindowMS is how long the agent waits after waking before it
+	// takes its queued inputs, merging those from the same source into one;
+	// 0 turns coalescing off.
+	CoalesceWindowMS int `json:"coalesce_window_ms"`
+	// GenerationTimeoutMS caps how long one turn may run, tool rounds
+	// included, before it is cancelled; 0 means no limit.
+	GenerationTimeoutMS int `json:"generation_timeout_ms"`
+}
+
+// RotationConfig archives the thread at each period boundary ("daily",
+// "weekly", or "monthly"; empty disables it), evaluated in Timezone (an IANA
+// name; empty means UTC).
+type RotationConfig struct {
+	Period   string `json:"period"`
+	Timezone string `json:"timezone"`
+}
+
+// QueueConfig bounds the pending input queue. Sources maps a source or
+// source prefix (e.g. "webhook:" or "webhook:alerts") to its own per-source
+// limit, overriding MaxPerSource.
+type QueueConfig struct {
+	MaxDepth     int            `json:"max_depth"`
+	MaxPerSource int            `json:"max_per_source"`
+	Sources      map[string]int `json:"sources"`
+}
+
+// ToolsConfig turns optional agent tools on and bounds how they run.
+type ToolsConfig struct {
+	Fetch bool `json:"fetch"`
+	// Concurrency caps parallel calls per tool name, e.g. {"fetch": 2}.
+	Concurrency map[string]int `json:"concurrency"`
+	// CronRefreshSeconds is how often the scheduler re-reads cron jobs from
+	// its database, picking up jobs added outside the running process.
+	CronRefreshSeconds int `json:"cron_refresh_seconds"`
+	// CronTimezone is the IANA zone cron expressions are evaluated in when a
+	// job names none; empty means UTC.
+	CronTimezone string `json:"cron_timezone"`
+	// DefaultTimeoutSeconds bounds every tool call; Timeouts overrides it by
+	// tool name, e.g. {"exec": 3600, "fetch": 60}.
+	DefaultTimeoutSeconds int            `json:"default_timeout_seconds"`
+	Timeouts              map[string]int `json:"timeouts"`
+	// MaxFilesPerOp caps how many entries one bulk operation, such as a
+	// recursive delete, may touch unless the call sets force.
+	MaxFilesPerOp int `json:"max_files_per_op"`
+	// Snapshot bounds the workspace snapshots taken by the snapshot tool.
+	Snapshot SnapshotConfig `json:"snapshot"`
+	// AutoSummarizeOver replaces tool results longer than this many
+	// characters with a summary and saves the full text under
+	// <workspace>/tool-results; 0 keeps results as they are. The summaries
+	// are written by provider.summary_model.
+	AutoSummarizeOver int `json:"auto_summarize_over"`
+	// Enabled, when set, keeps only the named tools and Disabled removes
+	// tools by name. Sources narrows them further for inputs whose source
+	// starts with a key, e.g. {"signal:dm:": {"disabled": ["exec"]}}; the
+	// longest matching key applies.
+	Enabled  []string              `json:"enabled"`
+	Disabled []string              `json:"disabled"`
+	Sources  map[string]ToolPolicy `json:"sources"`
+}
+
+// ToolPolicy is a per-source tool filter with the same meaning as
+// tools.enabled and tools.disabled.
+type ToolPolicy struct {
+	Enabled  []string `json:"enabled"`
+	Disabled []string `json:"disabled"`
+}
+
+// SnapshotConfig bounds workspace snapshots, which are kept under
+// state_path/snapshots.
+type SnapshotConfig struct {
+	// MaxMB refuses a snapshot when the workspace is larger than this.
+	MaxMB int `json:"max_mb"`
+	// Keep is how many snapshots are kept; older ones are deleted.
+	Keep int `json:"keep"`
+	// Auto takes a snapshot before every recursive delete.
+	Auto bool `json:"auto"`
 }
 
 type SandboxConfig struct {
@@ -55,6 +342,15 @@ type SandboxConfig struct {
 	Mounts       []Mount  `json:"mounts"`
 	HostUser     string   `json:"host_user"`
 	HostCommands []string `json:"host_commands"`
+	// HostCommandEnv lists, by host command, the variables an exec call in
+	// the sandbox may pass to it. Any other variable is refused.
+	HostCommandEnv map[string][]string `json:"host_command_env"`
+	// MemoryLimit caps the container's memory, in docker's --memory form
+	// ("512m", "2g"); empty leaves it unlimited.
+	MemoryLimit string `json:"memory_limit"`
+	// CPULimit caps the container's CPUs, in docker's --cpus form ("1.5");
+	// empty leaves it unlimited.
+	CPULimit string `json:"cpu_limit"`
 }
 
 type Mount struct {
@@ -71,4 +367,24 @@ type MemoryConfig struct {
 	MinScore        float64 `json:"min_score"`
 	DefaultResults  int     `json:"default_results"`
 	Citations       string  `json:"citations"`
+	// VectorWeight and FTSWeight weigh the normalized vector and full-text
+	// scores in memory_search's fused ranking.
+	VectorWeight float64 `json:"vector_weight"`
+	FTSWeight    float64 `json:"fts_weight"`
+	// ChunkSizeTokens and ChunkOverlapTokens set how files are split for
+	// indexing, in approximate tokens (four characters each).
+	ChunkSizeTokens    int `json:"chunk_size_tokens"`
+	ChunkOverlapTokens int `json:"chunk_overlap_tokens"`
+	// EmbeddingBatchSize caps how many chunks are embedded per request.
+	EmbeddingBatchSize int `json:"embedding_batch_size"`
+	// EmbedTimeoutSeconds cuts off one embedding request, which is retried
+	// EmbedRetries times with a backoff starting at EmbedBackoffMS. After
+	// EmbedBreakerThreshold failed calls in a row, embedding fails fast for
+	// EmbedBreakerCooldownSeconds. Zero keeps a default; -1 turns the
+	// timeout, retries or breaker off.
+	EmbedTimeoutSeconds         int `json:"embed_timeout_seconds"`
+	EmbedRetries                int `json:"embed_retries"`
+	EmbedBackoffMS              int `json:"embed_backoff_ms"`
+	EmbedBreakerThreshold       int `json:"embed_breaker_threshold"`
+	EmbedBreakerCooldownSeconds int `json:"embed_breaker_cooldown_seconds"`
 }
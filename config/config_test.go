@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -140,11 +141,24 @@ func TestLoadAppliesDefaults(t *testing.T) {
 	if c.Provider.BaseURL != defaultLMStudioURL {
 		t.Fatalf("unexpected provider base url: %q", c.Provider.BaseURL)
 	}
+	if !c.Provider.SendReasoning {
+		t.Fatal("expected provider.send_reasoning to default to true")
+	}
 	if c.Signal.TextChunkLimit != defaultTextChunkLimit || c.Signal.MediaMaxMB != defaultMediaMaxMB {
 		t.Fatalf("unexpected signal defaults: %d %d", c.Signal.TextChunkLimit, c.Signal.MediaMaxMB)
 	}
-	if c.Webhook.Listen != defaultWebhookListen {
-		t.Fatalf("unexpected webhook listen default: %q", c.Webhook.Listen)
+	if c.Signal.CommandWaitSeconds != defaultCommandWaitSecs {
+		t.Fatalf("unexpected command_wait_seconds default: %d", c.Signal.CommandWaitSeconds)
+	}
+	if c.Webhook.Listen != defaultWebhookListen || c.Webhook.SignalDownSeconds != defaultSignalDownSeconds || c.Webhook.MaxBodyBytes != defaultMaxBodyBytes || c.Webhook.MaxInFlight != defaultMaxInFlight {
+		t.Fatalf("unexpected webhook defaults: %+v", c.Webhook)
+	}
+	o := c.Webhook.Outbound
+	if len(o.Events) != 1 || o.Events[0] != "response" || o.MaxRetries != defaultOutboundRetries || o.QueueSize != defaultOutboundQueueSize {
+		t.Fatalf("unexpected outbound webhook defaults: %+v", o)
+	}
+	if q := c.Agent.Queue; q.MaxDepth != defaultQueueMaxDepth || q.MaxPerSource != defaultQueueMaxPerSource {
+		t.Fatalf("unexpected queue defaults: %+v", q)
 	}
 	if c.Sandbox.HostUser != defaultHostUser {
 		t.Fatalf("unexpected sandbox host user default: %q", c.Sandbox.HostUser)
@@ -152,9 +166,116 @@ func TestLoadAppliesDefaults(t *testing.T) {
 	if c.Memory.MinScore != defaultMinScore || c.Memory.DefaultResults != defaultResults || c.Memory.Citations != defaultCitations {
 		t.Fatalf("unexpected memory defaults: %v %d %q", c.Memory.MinScore, c.Memory.DefaultResults, c.Memory.Citations)
 	}
+	if c.Memory.VectorWeight != defaultVectorWeight || c.Memory.FTSWeight != defaultFTSWeight {
+		t.Fatalf("unexpected memory weights: %v %v", c.Memory.VectorWeight, c.Memory.FTSWeight)
+	}
+	if c.Memory.ChunkSizeTokens != defaultChunkSizeTokens || c.Memory.ChunkOverlapTokens != defaultChunkOverlapToks {
+		t.Fatalf("unexpected chunking defaults: %d %d", c.Memory.ChunkSizeTokens, c.Memory.ChunkOverlapTokens)
+	}
+	if c.Memory.EmbeddingBatchSize != defaultEmbedBatchSize {
+		t.Fatalf("unexpected embedding_batch_size default: %d", c.Memory.EmbeddingBatchSize)
+	}
+	if c.Memory.EmbedTimeoutSeconds != defaultEmbedTimeoutSecs || c.Memory.EmbedRetries != defaultEmbedRetries || c.Memory.EmbedBreakerThreshold != defaultEmbedBreakerFails {
+		t.Fatalf("unexpected embed limits: %+v", c.Memory)
+	}
 	if c.NoToolSleepRounds != defaultNoToolSleepRounds {
 		t.Fatalf("unexpected no_tool_sleep_rounds default: %d", c.NoToolSleepRounds)
 	}
+	if c.Agent.ToolCallIDs != "namespace" {
+		t.Fatalf("unexpected tool_call_ids default: %q", c.Agent.ToolCallIDs)
+	}
+	if c.Store.Backend != "sqlite" {
+		t.Fatalf("unexpected store.backend default: %q", c.Store.Backend)
+	}
+	if c.Store.EventLogMaxRows != 100000 {
+		t.Fatalf("unexpected store.event_log_max_rows default: %d", c.Store.EventLogMaxRows)
+	}
+	if sq := c.Store.SQLite; sq.JournalMode != "wal" || sq.BusyTimeoutMS != 5000 || sq.Synchronous != "normal" || sq.MaxOpenConns != 1 {
+		t.Fatalf("unexpected store.sqlite defaults: %+v", sq)
+	}
+	if c.Agent.TruncatedToolCalls != "retry" {
+		t.Fatalf("unexpected truncated_tool_calls default: %q", c.Agent.TruncatedToolCalls)
+	}
+	if c.Tools.CronRefreshSeconds != defaultCronRefreshSecs {
+		t.Fatalf("unexpected cron_refresh_seconds default: %d", c.Tools.CronRefreshSeconds)
+	}
+	if c.Agent.CompactThreshold != defaultCompactThreshold || c.Provider.ContextWindow != 0 {
+		t.Fatalf("unexpected compaction defaults: %v %d", c.Agent.CompactThreshold, c.Provider.ContextWindow)
+	}
+	if c.Agent.MaxToolRounds != defaultMaxToolRounds {
+		t.Fatalf("unexpected max_tool_rounds default: %d", c.Agent.MaxToolRounds)
+	}
+	if c.Agent.MaxParallelTools != defaultMaxParallelTools {
+		t.Fatalf("unexpected max_parallel_tools default: %d", c.Agent.MaxParallelTools)
+	}
+	if c.Tools.MaxFilesPerOp != defaultMaxFilesPerOp {
+		t.Fatalf("unexpected max_files_per_op default: %d", c.Tools.MaxFilesPerOp)
+	}
+	if c.Tools.Snapshot != (SnapshotConfig{MaxMB: defaultSnapshotMaxMB, Keep: defaultSnapshotKeep}) {
+		t.Fatalf("unexpected snapshot defaults: %+v", c.Tools.Snapshot)
+	}
+	if c.Tools.DefaultTimeoutSeconds != defaultToolTimeoutSecs || len(c.Tools.Timeouts) != 0 {
+		t.Fatalf("unexpected tool timeout defaults: %d %v", c.Tools.DefaultTimeoutSeconds, c.Tools.Timeouts)
+	}
+}
+
+func TestLoadAppliesFallbackDefaults(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {
+			"backend": "openrouter",
+			"api_key": "k",
+			"model": "remote-model",
+			"fallbacks": [
+				{"backend": "lmstudio", "model": "local-model"},
+				{"backend": "lmstudio", "model": "other", "send_reasoning": false}
+			]
+		}
+	}`)
+
+	c, err := Load(p)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	fb := c.Provider.Fallbacks
+	if len(fb) != 2 || fb[0].BaseURL != defaultLMStudioURL || fb[0].MaxTokens != defaultMaxTokens || fb[0].APIKey != "lmstudio" {
+		t.Fatalf("fallback defaults not applied: %+v", fb)
+	}
+	if !fb[0].SendReasoning || fb[1].SendReasoning {
+		t.Fatalf("unexpected fallback send_reasoning: %v %v", fb[0].SendReasoning, fb[1].SendReasoning)
+	}
+}
+
+func TestLoadValidatesFallbacks(t *testing.T) {
+	cases := map[string]string{
+		`[{"backend": "nope", "model": "m"}]`:                                                           "provider.fallbacks[0]: provider.backend",
+		`[{"backend": "lmstudio", "model": "m"}, {"backend": "openrouter", "model": "m"}]`:              "provider.fallbacks[1]: provider.api_key",
+		`[{"backend": "lmstudio", "model": "m", "fallbacks": [{"backend": "lmstudio", "model": "x"}]}]`: "provider.fallbacks[0].fallbacks",
+	}
+	for fallbacks, want := range cases {
+		p := writeConfigFile(t, `{"provider": {"backend": "lmstudio", "model": "m", "fallbacks": `+fallbacks+`}}`)
+		_, err := Load(p)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %s error, got: %v", fallbacks, want, err)
+		}
+	}
+}
+
+func TestLoadKeepsExplicitSendReasoningFalse(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {
+			"backend": "lmstudio",
+			"model": "local-model",
+			"send_reasoning": false
+		}
+	}`)
+
+	c, err := Load(p)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Provider.SendReasoning {
+		t.Fatal("expected provider.send_reasoning to stay false")
+	}
 }
 
 func TestLoadRejectsInvalidBackend(t *testing.T) {
@@ -174,6 +295,53 @@ func TestLoadRejectsInvalidBackend(t *testing.T) {
 	}
 }
 
+func TestLoadRejectsNegativeCommandWait(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"signal": {"enabled": true, "account": "+15550001111", "command_wait_seconds": -1}
+	}`)
+	_, err := Load(p)
+	if err == nil || !strings.Contains(err.Error(), "signal.command_wait_seconds") {
+		t.Fatalf("expected command_wait_seconds error, got: %v", err)
+	}
+}
+
+func TestLoadValidatesGroupOpenHours(t *testing.T) {
+	cases := map[string]string{
+		`"group_open_hours": "9am-5pm"`:     "signal.group_open_hours",
+		`"group_open_hours": "09:00-09:00"`: "signal.group_open_hours",
+		`"group_timezone": "Mars/Olympus"`:  "signal.group_timezone",
+	}
+	for field, want := range cases {
+		p := writeConfigFile(t, `{
+			"provider": {"backend": "lmstudio", "model": "m"},
+			"signal": {"enabled": true, "account": "+15550001111", `+field+`}
+		}`)
+		_, err := Load(p)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %s error, got: %v", field, want, err)
+		}
+	}
+}
+
+func TestLoadValidatesSignalPreprocess(t *testing.T) {
+	cases := map[string]string{
+		`[{"kind": "translate"}]`:                   "signal.preprocess[0].kind",
+		`[{"kind": "trim"}, {"kind": "wake_word"}]`: "signal.preprocess[1].words",
+		`[{"kind": "wake_word", "words": [" "]}]`:   "signal.preprocess[0].words",
+	}
+	for steps, want := range cases {
+		p := writeConfigFile(t, `{
+			"provider": {"backend": "lmstudio", "model": "m"},
+			"signal": {"enabled": true, "account": "+15550001111", "preprocess": `+steps+`}
+		}`)
+		_, err := Load(p)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %s error, got: %v", steps, want, err)
+		}
+	}
+}
+
 func TestLoadRejectsInvalidSignalE164(t *testing.T) {
 	p := writeConfigFile(t, `{
 		"provider": {
@@ -239,6 +407,42 @@ func TestLoadRejectsMissingRequiredFieldsWhenEnabled(t *testing.T) {
 	}
 }
 
+func TestLoadValidatesMemoryChunking(t *testing.T) {
+	cases := map[string]string{
+		`"chunk_size_tokens": -5`:                               "memory.chunk_size_tokens",
+		`"chunk_overlap_tokens": -1`:                            "memory.chunk_overlap_tokens",
+		`"chunk_size_tokens": 100, "chunk_overlap_tokens": 100`: "memory.chunk_overlap_tokens",
+		`"embedding_batch_size": -2`:                            "memory.embedding_batch_size",
+		`"embed_timeout_seconds": -2`:                           "memory.embed_timeout_seconds",
+		`"embed_retries": -2`:                                   "memory.embed_retries",
+		`"embed_breaker_cooldown_seconds": -1`:                  "memory.embed_breaker_cooldown_seconds",
+	}
+	for chunking, want := range cases {
+		p := writeConfigFile(t, `{
+			"provider": {"backend": "lmstudio", "model": "m"},
+			"memory": {"enabled": true, "embedding_url": "http://127.0.0.1:1234/v1", "embedding_model": "e", `+chunking+`}
+		}`)
+		_, err := Load(p)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %s error, got: %v", chunking, want, err)
+		}
+	}
+}
+
+func TestLoadKeepsEmbedLimitsTurnedOff(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"memory": {"enabled": true, "embedding_url": "http://127.0.0.1:1234/v1", "embedding_model": "e", "embed_timeout_seconds": -1, "embed_retries": -1, "embed_breaker_threshold": -1}
+	}`)
+	c, err := Load(p)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if c.Memory.EmbedTimeoutSeconds != -1 || c.Memory.EmbedRetries != -1 || c.Memory.EmbedBreakerThreshold != -1 {
+		t.Fatalf("expected -1 to be kept, got %+v", c.Memory)
+	}
+}
+
 func TestLoadRejectsInvalidNoToolSleepRounds(t *testing.T) {
 	p := writeConfigFile(t, `{
 		"provider": {
@@ -302,6 +506,152 @@ func TestLoadAcceptsValidThinkingEffort(t *testing.T) {
 	}
 }
 
+func TestLoadAnthropicBackend(t *testing.T) {
+	c, err := Load(writeConfigFile(t, `{"provider": {"backend": "anthropic", "api_key": "sk-ant-test", "model": "claude-sonnet-4-5"}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Provider.BaseURL != defaultAnthropicURL {
+		t.Fatalf("unexpected base_url: %q", c.Provider.BaseURL)
+	}
+	_, err = Load(writeConfigFile(t, `{"provider": {"backend": "anthropic", "model": "claude-sonnet-4-5"}}`))
+	if err == nil || !strings.Contains(err.Error(), "provider.api_key") {
+		t.Fatalf("expected provider.api_key error, got: %v", err)
+	}
+}
+
+func TestLoadOllamaBackendNeedsNoKey(t *testing.T) {
+	c, err := Load(writeConfigFile(t, `{"provider": {"backend": "ollama", "model": "qwen3:8b"}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Provider.BaseURL != defaultOllamaURL || c.Provider.APIKey != "" {
+		t.Fatalf("unexpected provider defaults: %#v", c.Provider)
+	}
+}
+
+func TestLoadValidatesLogLevel(t *testing.T) {
+	c, err := Load(writeConfigFile(t, `{"provider": {"backend": "lmstudio", "model": "m"}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.LogLevel != defaultLogLevel {
+		t.Fatalf("unexpected log_level default: %q", c.LogLevel)
+	}
+	_, err = Load(writeConfigFile(t, `{"provider": {"backend": "lmstudio", "model": "m"}, "log_level": "trace"}`))
+	if err == nil || !strings.Contains(err.Error(), "log_level") {
+		t.Fatalf("expected log_level error, got: %v", err)
+	}
+}
+
+func TestLoadValidatesAPIStyle(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {
+			"backend": "lmstudio",
+			"model": "local-model",
+			"api_style": "responses"
+		}
+	}`)
+	c, err := Load(p)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Provider.APIStyle != "responses" {
+		t.Fatalf("unexpected api_style: %q", c.Provider.APIStyle)
+	}
+
+	p = writeConfigFile(t, `{
+		"provider": {
+			"backend": "lmstudio",
+			"model": "local-model",
+			"api_style": "completions"
+		}
+	}`)
+	_, err = Load(p)
+	if err == nil || !strings.Contains(err.Error(), "provider.api_style") {
+		t.Fatalf("expected provider.api_style error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsNegativeCostLimits(t *testing.T) {
+	for _, field := range []string{"max_cost_per_thread", "max_cost_per_day"} {
+		_, err := Load(writeConfigFile(t, `{"provider": {"backend": "lmstudio", "model": "m"}, "limits": {"`+field+`": -1}}`))
+		if err == nil || !strings.Contains(err.Error(), "limits."+field) {
+			t.Fatalf("expected limits.%s error, got: %v", field, err)
+		}
+	}
+	c, err := Load(writeConfigFile(t, `{"provider": {"backend": "lmstudio", "model": "m"}, "limits": {"max_cost_per_day": 5}}`))
+	if err != nil || c.Limits.MaxCostPerDay != 5 || c.Limits.MaxCostPerThread != 0 {
+		t.Fatalf("unexpected limits: %#v (%v)", c.Limits, err)
+	}
+}
+
+func TestLoadValidatesMediaURLs(t *testing.T) {
+	c, err := Load(writeConfigFile(t, `{"provider": {"backend": "lmstudio", "model": "m", "media_urls": "pass"}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Provider.MediaURLs != "pass" {
+		t.Fatalf("unexpected media_urls: %q", c.Provider.MediaURLs)
+	}
+	_, err = Load(writeConfigFile(t, `{"provider": {"backend": "lmstudio", "model": "m", "media_urls": "inline"}}`))
+	if err == nil || !strings.Contains(err.Error(), "provider.media_urls") {
+		t.Fatalf("expected provider.media_urls error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsNegativeProviderRetry(t *testing.T) {
+	for _, field := range []string{"retry_attempts", "retry_base_ms", "stream_idle_timeout_seconds"} {
+		_, err := Load(writeConfigFile(t, `{"provider": {"backend": "lmstudio", "model": "m", "`+field+`": -1}}`))
+		if err == nil || !strings.Contains(err.Error(), "provider."+field) {
+			t.Fatalf("expected provider.%s error, got: %v", field, err)
+		}
+	}
+}
+
+func TestLoadValidatesSamplingSettings(t *testing.T) {
+	c, err := Load(writeConfigFile(t, `{"provider": {"backend": "lmstudio", "model": "m", "temperature": 0, "top_p": 0.9, "stop": ["</done>"]}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Provider.Temperature == nil || *c.Provider.Temperature != 0 || *c.Provider.TopP != 0.9 || len(c.Provider.Stop) != 1 {
+		t.Fatalf("unexpected sampling settings: %#v", c.Provider)
+	}
+	for field, bad := range map[string]string{
+		"temperature": `"temperature": 2.5`,
+		"top_p":       `"top_p": 0`,
+		"stop":        `"stop": ["a", ""]`,
+	} {
+		_, err := Load(writeConfigFile(t, `{"provider": {"backend": "lmstudio", "model": "m", `+bad+`}}`))
+		if err == nil || !strings.Contains(err.Error(), "provider."+field) {
+			t.Fatalf("expected provider.%s error, got: %v", field, err)
+		}
+	}
+}
+
+func TestLoadValidatesToolPolicies(t *testing.T) {
+	c, err := Load(writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"tools": {"disabled": ["fetch"], "sources": {"signal:dm:": {"disabled": ["exec"]}}}
+	}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Tools.Disabled[0] != "fetch" || c.Tools.Sources["signal:dm:"].Disabled[0] != "exec" {
+		t.Fatalf("unexpected tool policies: %+v", c.Tools)
+	}
+	for want, tools := range map[string]string{
+		"tools.enabled":                     `{"enabled": [""]}`,
+		"tools.sources keys":                `{"sources": {"": {"disabled": ["exec"]}}}`,
+		`tools.sources["signal:"].disabled`: `{"sources": {"signal:": {"disabled": [""]}}}`,
+	} {
+		_, err := Load(writeConfigFile(t, `{"provider": {"backend": "lmstudio", "model": "m"}, "tools": `+tools+`}`))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %s error, got: %v", want, err)
+		}
+	}
+}
+
 func TestLoadRejectsInvalidThinkingEffort(t *testing.T) {
 	p := writeConfigFile(t, `{
 		"provider": {
@@ -321,6 +671,29 @@ func TestLoadRejectsInvalidThinkingEffort(t *testing.T) {
 	}
 }
 
+func TestLoadValidatesSandboxResourceLimits(t *testing.T) {
+	load := func(limits string) error {
+		_, err := Load(writeConfigFile(t, `{
+			"provider": {"backend": "lmstudio", "model": "m"},
+			"sandbox": {"enabled": true, `+limits+`}
+		}`))
+		return err
+	}
+	if err := load(`"memory_limit": "512m", "cpu_limit": "1.5"`); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	for limits, field := range map[string]string{
+		`"memory_limit": "lots"`:  "sandbox.memory_limit",
+		`"memory_limit": "512mb"`: "sandbox.memory_limit",
+		`"cpu_limit": "two"`:      "sandbox.cpu_limit",
+		`"cpu_limit": "0"`:        "sandbox.cpu_limit",
+	} {
+		if err := load(limits); err == nil || !strings.Contains(err.Error(), field) {
+			t.Fatalf("expected %s error for %s, got: %v", field, limits, err)
+		}
+	}
+}
+
 func TestLoadAcceptsSandboxHostCommandsWithoutKeyPath(t *testing.T) {
 	p := writeConfigFile(t, `{
 		"provider": {
@@ -362,3 +735,532 @@ func TestLoadRejectsSandboxHostCommandsWithSpaces(t *testing.T) {
 		t.Fatalf("expected sandbox.host_commands[0] error, got: %v", err)
 	}
 }
+
+func TestLoadValidatesSandboxHostCommandEnv(t *testing.T) {
+	for env, want := range map[string]string{
+		`{"gh": ["GH_TOKEN"]}`:   "",
+		`{"curl": ["TOKEN"]}`:    "not in sandbox.host_commands",
+		`{"gh": ["LD_PRELOAD"]}`: "cannot be forwarded",
+		`{"gh": ["A=B"]}`:        "not a variable name",
+	} {
+		_, err := Load(writeConfigFile(t, `{
+			"provider": {"backend": "lmstudio", "model": "m"},
+			"sandbox": {"enabled": true, "host_commands": ["gh"], "host_command_env": `+env+`}
+		}`))
+		if want == "" && err != nil {
+			t.Fatalf("%s: %v", env, err)
+		}
+		if want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Fatalf("%s: expected %q error, got: %v", env, want, err)
+		}
+	}
+}
+
+func TestLoadRejectsWebhookContentTemplateThatDoesNotParse(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {
+			"backend": "lmstudio",
+			"model": "m"
+		},
+		"webhook": {
+			"enabled": true,
+			"hooks": [
+				{"id": "x", "path": "/hook", "format": "json", "content_template": "{{.status"}
+			]
+		}
+	}`)
+
+	_, err := Load(p)
+	if err == nil {
+		t.Fatal("expected content_template validation error")
+	}
+	if !strings.Contains(err.Error(), "webhook.hooks[0].content_template") {
+		t.Fatalf("expected content_template error, got: %v", err)
+	}
+}
+
+func TestLoadValidatesWebhookMetadataFields(t *testing.T) {
+	for _, hook := range []string{
+		`{"id": "x", "path": "/hook", "format": "text", "metadata_fields": ["status"]}`,
+		`{"id": "x", "path": "/hook", "format": "json", "metadata_fields": ["alerts..status"]}`,
+	} {
+		_, err := Load(writeConfigFile(t, `{
+			"provider": {"backend": "lmstudio", "model": "m"},
+			"webhook": {"enabled": true, "listen": "127.0.0.1:9090", "hooks": [`+hook+`]}
+		}`))
+		if err == nil || !strings.Contains(err.Error(), "webhook.hooks[0].metadata_fields") {
+			t.Fatalf("expected metadata_fields error for %s, got: %v", hook, err)
+		}
+	}
+	c, err := Load(writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"webhook": {"enabled": true, "listen": "127.0.0.1:9090", "hooks": [{"id": "x", "path": "/hook", "format": "json", "metadata_fields": ["alerts.0.status"]}]}
+	}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if got := c.Webhook.Hooks[0].MetadataFields; len(got) != 1 || got[0] != "alerts.0.status" {
+		t.Fatalf("unexpected metadata_fields: %#v", got)
+	}
+}
+
+func TestLoadRejectsWebhookContentPathWithTextFormat(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {
+			"backend": "lmstudio",
+			"model": "m"
+		},
+		"webhook": {
+			"enabled": true,
+			"hooks": [
+				{"id": "x", "path": "/hook", "format": "text", "content_path": "a.b"}
+			]
+		}
+	}`)
+
+	_, err := Load(p)
+	if err == nil {
+		t.Fatal("expected content_path validation error")
+	}
+	if !strings.Contains(err.Error(), "require format json") {
+		t.Fatalf("expected format error, got: %v", err)
+	}
+}
+
+func TestLoadAcceptsOutboundWebhookWithoutInboundServer(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {
+			"backend": "lmstudio",
+			"model": "m"
+		},
+		"webhook": {
+			"outbound": {"url": "https://example.com/miclaw", "token": "t", "events": ["response", "error"]}
+		}
+	}`)
+
+	c, err := Load(p)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if c.Webhook.Enabled || c.Webhook.Outbound.URL != "https://example.com/miclaw" {
+		t.Fatalf("unexpected webhook config: %+v", c.Webhook)
+	}
+}
+
+func TestLoadWebhookHooksOptionalWithHealth(t *testing.T) {
+	cfg := func(health bool) string {
+		return fmt.Sprintf(`{
+			"provider": {"backend": "lmstudio", "model": "m"},
+			"webhook": {"enabled": true, "health_enabled": %t}
+		}`, health)
+	}
+	if _, err := Load(writeConfigFile(t, cfg(false))); err == nil || !strings.Contains(err.Error(), "webhook.hooks is required") {
+		t.Fatalf("expected hooks error, got: %v", err)
+	}
+	c, err := Load(writeConfigFile(t, cfg(true)))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !c.Webhook.HealthEnabled || len(c.Webhook.Hooks) != 0 {
+		t.Fatalf("unexpected webhook config: %+v", c.Webhook)
+	}
+}
+
+func TestLoadRejectsNegativeWebhookMaxBodyBytes(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"webhook": {"enabled": true, "health_enabled": true, "max_body_bytes": -1}
+	}`)
+	_, err := Load(p)
+	if err == nil || !strings.Contains(err.Error(), "webhook.max_body_bytes") {
+		t.Fatalf("expected max_body_bytes error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsNegativeWebhookLimits(t *testing.T) {
+	for field, webhook := range map[string]string{
+		"webhook.max_in_flight":                    `{"enabled": true, "health_enabled": true, "max_in_flight": -1}`,
+		"webhook.hooks[0].max_requests_per_minute": `{"enabled": true, "hooks": [{"id": "x", "path": "/x", "max_requests_per_minute": -5}]}`,
+	} {
+		_, err := Load(writeConfigFile(t, `{"provider": {"backend": "lmstudio", "model": "m"}, "webhook": `+webhook+`}`))
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Fatalf("expected %s error, got: %v", field, err)
+		}
+	}
+}
+
+func TestLoadWebhookSignatureHeader(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"webhook": {"enabled": true, "hooks": [
+			{"id": "a", "path": "/a", "secret": "s"},
+			{"id": "b", "path": "/b", "secret": "s", "signature_header": "X-Hub-Signature-256"},
+			{"id": "c", "path": "/c", "secret": "s", "signature_header": " "}
+		]}
+	}`)
+	c, err := Load(p)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if c.Webhook.Hooks[0].SignatureHeader != "X-Webhook-Signature" || c.Webhook.Hooks[1].SignatureHeader != "X-Hub-Signature-256" || c.Webhook.Hooks[2].SignatureHeader != "X-Webhook-Signature" {
+		t.Fatalf("unexpected signature headers: %+v", c.Webhook.Hooks)
+	}
+
+	cases := map[string]string{
+		`{"id": "a", "path": "/a", "signature_header": "X-Sig"}`: "signature_header requires secret",
+	}
+	for hook, want := range cases {
+		p := writeConfigFile(t, `{
+			"provider": {"backend": "lmstudio", "model": "m"},
+			"webhook": {"enabled": true, "hooks": [`+hook+`]}
+		}`)
+		if _, err := Load(p); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %q error, got: %v", hook, want, err)
+		}
+	}
+}
+
+func TestLoadWebhookSyncTimeout(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"webhook": {"enabled": true, "hooks": [
+			{"id": "a", "path": "/a", "sync": true},
+			{"id": "b", "path": "/b"}
+		]}
+	}`)
+	c, err := Load(p)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if c.Webhook.Hooks[0].SyncTimeoutSeconds != 60 || c.Webhook.Hooks[1].SyncTimeoutSeconds != 0 {
+		t.Fatalf("unexpected sync timeouts: %+v", c.Webhook.Hooks)
+	}
+	p = writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"webhook": {"enabled": true, "hooks": [{"id": "a", "path": "/a", "sync": true, "sync_timeout_seconds": -1}]}
+	}`)
+	if _, err := Load(p); err == nil || !strings.Contains(err.Error(), "webhook.hooks[0].sync_timeout_seconds") {
+		t.Fatalf("expected sync_timeout_seconds error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsInvalidOutboundWebhook(t *testing.T) {
+	cases := map[string]string{
+		`{"url": "ftp://example.com"}`:                       "webhook.outbound.url",
+		`{"url": "http://example.com", "events": ["delta"]}`: "webhook.outbound.events",
+		`{"url": "http://example.com", "max_retries": -1}`:   "webhook.outbound.max_retries",
+		`{"url": "http://example.com", "queue_size": -5}`:    "webhook.outbound.queue_size",
+	}
+	for outbound, want := range cases {
+		p := writeConfigFile(t, `{
+			"provider": {"backend": "lmstudio", "model": "m"},
+			"webhook": {"outbound": `+outbound+`}
+		}`)
+		_, err := Load(p)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %s error, got: %v", outbound, want, err)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidQueueLimits(t *testing.T) {
+	cases := map[string]string{
+		`{"max_depth": -1}`:            "agent.queue.max_depth",
+		`{"max_per_source": -1}`:       "agent.queue.max_per_source",
+		`{"sources": {"webhook:": 0}}`: "agent.queue.sources",
+		`{"sources": {" ": 3}}`:        "agent.queue.sources",
+	}
+	for queue, want := range cases {
+		p := writeConfigFile(t, `{
+			"provider": {"backend": "lmstudio", "model": "m"},
+			"agent": {"queue": `+queue+`}
+		}`)
+		_, err := Load(p)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %s error, got: %v", queue, want, err)
+		}
+	}
+}
+
+func TestLoadRejectsNonPositiveToolConcurrency(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"tools": {"concurrency": {"fetch": 0}}
+	}`)
+	_, err := Load(p)
+	if err == nil || !strings.Contains(err.Error(), `tools.concurrency["fetch"]`) {
+		t.Fatalf("expected concurrency error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsNegativeCronRefresh(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"tools": {"cron_refresh_seconds": -5}
+	}`)
+	_, err := Load(p)
+	if err == nil || !strings.Contains(err.Error(), "tools.cron_refresh_seconds") {
+		t.Fatalf("expected cron refresh error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsNegativeMaxToolRounds(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"agent": {"max_tool_rounds": -1}
+	}`)
+	_, err := Load(p)
+	if err == nil || !strings.Contains(err.Error(), "agent.max_tool_rounds") {
+		t.Fatalf("expected max_tool_rounds error, got: %v", err)
+	}
+}
+
+func TestLoadValidatesToolTimeouts(t *testing.T) {
+	cases := map[string]string{
+		`{"default_timeout_seconds": -1}`: "tools.default_timeout_seconds",
+		`{"timeouts": {"fetch": 0}}`:      `tools.timeouts["fetch"]`,
+		`{"timeouts": {"exec": -30}}`:     `tools.timeouts["exec"]`,
+	}
+	for tools, want := range cases {
+		p := writeConfigFile(t, `{
+			"provider": {"backend": "lmstudio", "model": "m"},
+			"tools": `+tools+`
+		}`)
+		_, err := Load(p)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %s error, got: %v", tools, want, err)
+		}
+	}
+}
+
+func TestLoadRejectsNegativeMaxParallelTools(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"agent": {"max_parallel_tools": -2}
+	}`)
+	_, err := Load(p)
+	if err == nil || !strings.Contains(err.Error(), "agent.max_parallel_tools") {
+		t.Fatalf("expected max_parallel_tools error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsNegativeSnapshotLimits(t *testing.T) {
+	for field, want := range map[string]string{"max_mb": "tools.snapshot.max_mb", "keep": "tools.snapshot.keep"} {
+		p := writeConfigFile(t, `{
+			"provider": {"backend": "lmstudio", "model": "m"},
+			"tools": {"snapshot": {"`+field+`": -1}}
+		}`)
+		_, err := Load(p)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %s error, got: %v", want, err)
+		}
+	}
+}
+
+func TestLoadRejectsNegativeAutoSummarizeOver(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"tools": {"auto_summarize_over": -1}
+	}`)
+	_, err := Load(p)
+	if err == nil || !strings.Contains(err.Error(), "tools.auto_summarize_over") {
+		t.Fatalf("expected auto_summarize_over error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsNegativeCoalesceWindow(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"agent": {"coalesce_window_ms": -1}
+	}`)
+	_, err := Load(p)
+	if err == nil || !strings.Contains(err.Error(), "agent.coalesce_window_ms") {
+		t.Fatalf("expected coalesce_window_ms error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsNegativeGenerationTimeout(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"agent": {"generation_timeout_ms": -1}
+	}`)
+	_, err := Load(p)
+	if err == nil || !strings.Contains(err.Error(), "agent.generation_timeout_ms") {
+		t.Fatalf("expected generation_timeout_ms error, got: %v", err)
+	}
+}
+
+func TestLoadShutdownGraceDefaultAndValidation(t *testing.T) {
+	c, err := Load(writeConfigFile(t, `{"provider": {"backend": "lmstudio", "model": "m"}}`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if c.Shutdown.GraceSeconds != 20 {
+		t.Fatalf("shutdown.grace_seconds = %d, want 20", c.Shutdown.GraceSeconds)
+	}
+	_, err = Load(writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"shutdown": {"grace_seconds": -1}
+	}`))
+	if err == nil || !strings.Contains(err.Error(), "shutdown.grace_seconds") {
+		t.Fatalf("expected shutdown.grace_seconds error, got: %v", err)
+	}
+}
+
+func TestLoadHeartbeatDefaultsAndSchedule(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"heartbeat": {"enabled": true}
+	}`)
+	c, err := Load(p)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if c.Heartbeat.Schedule != defaultHeartbeatSchedule || c.Heartbeat.Prompt != defaultHeartbeatPrompt {
+		t.Fatalf("unexpected heartbeat defaults: %+v", c.Heartbeat)
+	}
+
+	p = writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"heartbeat": {"enabled": true, "schedule": "every 5 minutes"}
+	}`)
+	if _, err := Load(p); err == nil || !strings.Contains(err.Error(), "heartbeat.schedule") {
+		t.Fatalf("expected heartbeat.schedule error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsUnknownToolCallIDs(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"agent": {"tool_call_ids": "random"}
+	}`)
+	_, err := Load(p)
+	if err == nil || !strings.Contains(err.Error(), "agent.tool_call_ids") {
+		t.Fatalf("expected tool_call_ids error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsUnknownTruncatedToolCalls(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"agent": {"truncated_tool_calls": "ignore"}
+	}`)
+	_, err := Load(p)
+	if err == nil || !strings.Contains(err.Error(), "agent.truncated_tool_calls") {
+		t.Fatalf("expected truncated_tool_calls error, got: %v", err)
+	}
+}
+
+func TestLoadValidatesStore(t *testing.T) {
+	cases := map[string]string{
+		`{"backend": "mysql"}`:                 "store.backend",
+		`{"backend": "postgres"}`:              "store.postgres_dsn",
+		`{"sqlite": {"journal_mode": "wall"}}`: "store.sqlite.journal_mode",
+		`{"sqlite": {"synchronous": "extra"}}`: "store.sqlite.synchronous",
+		`{"sqlite": {"busy_timeout_ms": -1}}`:  "store.sqlite.busy_timeout_ms",
+		`{"sqlite": {"max_open_conns": -1}}`:   "store.sqlite.max_open_conns",
+		`{"event_log_max_rows": -1}`:           "store.event_log_max_rows",
+	}
+	for store, want := range cases {
+		p := writeConfigFile(t, `{
+			"provider": {"backend": "lmstudio", "model": "m"},
+			"store": `+store+`
+		}`)
+		_, err := Load(p)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("store %s: expected %s error, got: %v", store, want, err)
+		}
+	}
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"store": {"backend": "postgres", "postgres_dsn": "postgres://miclaw@db/miclaw"}
+	}`)
+	if _, err := Load(p); err != nil {
+		t.Fatalf("postgres store rejected: %v", err)
+	}
+}
+
+func TestLoadValidatesCompaction(t *testing.T) {
+	cases := map[string]string{
+		`"provider": {"backend": "lmstudio", "model": "m", "context_window": 4096, "max_tokens": 8192}`: "provider.context_window",
+		`"provider": {"backend": "lmstudio", "model": "m"}, "agent": {"compact_threshold": 1.5}`:        "agent.compact_threshold",
+		`"provider": {"backend": "lmstudio", "model": "m"}, "agent": {"compact_threshold": -0.1}`:       "agent.compact_threshold",
+		`"provider": {"backend": "lmstudio", "model": "m"}, "agent": {"compact_keep_messages": -1}`:     "agent.compact_keep_messages",
+	}
+	for body, want := range cases {
+		_, err := Load(writeConfigFile(t, "{"+body+"}"))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %s error, got: %v", body, want, err)
+		}
+	}
+}
+
+func TestLoadValidatesRotation(t *testing.T) {
+	cases := map[string]string{
+		`{"period": "hourly"}`:                         "agent.rotation.period",
+		`{"period": "daily", "timezone": "Nowhere/X"}`: "agent.rotation.timezone",
+	}
+	for rotation, want := range cases {
+		p := writeConfigFile(t, `{
+			"provider": {"backend": "lmstudio", "model": "m"},
+			"agent": {"rotation": `+rotation+`}
+		}`)
+		_, err := Load(p)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %s error, got: %v", rotation, want, err)
+		}
+	}
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"agent": {"rotation": {"period": "monthly", "timezone": "UTC"}}
+	}`)
+	if _, err := Load(p); err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+}
+
+func TestLoadRejectsSessionTemplateOnTextHook(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"webhook": {"enabled": true, "hooks": [
+			{"id": "a", "path": "/a", "format": "text", "session_id": "x-{{.id}}"}
+		]}
+	}`)
+	_, err := Load(p)
+	if err == nil || !strings.Contains(err.Error(), "webhook.hooks[0].session_id") {
+		t.Fatalf("expected session_id error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsSummaryModelOnFallback(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m", "fallbacks": [{"backend": "lmstudio", "model": "f", "summary_model": "s"}]}
+	}`)
+	_, err := Load(p)
+	if err == nil || !strings.Contains(err.Error(), "provider.fallbacks[0].summary_model") {
+		t.Fatalf("expected fallback summary_model error, got: %v", err)
+	}
+}
+
+func TestLoadRejectsNegativeSummaryCost(t *testing.T) {
+	for _, field := range []string{"summary_input_cost_per_mtok", "summary_output_cost_per_mtok"} {
+		p := writeConfigFile(t, `{
+			"provider": {"backend": "lmstudio", "model": "m", "summary_model": "s", "`+field+`": -1}
+		}`)
+		_, err := Load(p)
+		if err == nil || !strings.Contains(err.Error(), "provider."+field) {
+			t.Fatalf("expected %s error, got: %v", field, err)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownCronTimezone(t *testing.T) {
+	p := writeConfigFile(t, `{
+		"provider": {"backend": "lmstudio", "model": "m"},
+		"tools": {"cron_timezone": "Mars/Olympus"}
+	}`)
+	_, err := Load(p)
+	if err == nil || !strings.Contains(err.Error(), "tools.cron_timezone") {
+		t.Fatalf("expected cron_timezone error, got: %v", err)
+	}
+}
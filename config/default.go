@@ -1,7 +1,28 @@
 package config
 
+import "encoding/json"
+
 func Default() Config {
-	var c Config
+	c := seedConfig()
 	applyDefaults(&c)
 	return c
 }
+
+// seedConfig returns a Config holding the defaults that cannot be applied
+// after decoding: booleans that default to true, so an absent key keeps the
+// default and an explicit false still wins.
+func seedConfig() Config {
+	return Config{Provider: ProviderConfig{SendReasoning: defaultSendReasoning}}
+}
+
+// UnmarshalJSON seeds SendReasoning before decoding so that fallback
+// providers, which are decoded into fresh values, default to true as well.
+func (p *ProviderConfig) UnmarshalJSON(b []byte) error {
+	type plain ProviderConfig
+	v := plain{SendReasoning: defaultSendReasoning}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*p = ProviderConfig(v)
+	return nil
+}
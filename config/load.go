@@ -3,19 +3,29 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 )
 
 const (
 	defaultWorkspace         = "~/.miclaw/workspace"
 	defaultStatePath         = "~/.miclaw/state"
 	defaultNoToolSleepRounds = 16
+	defaultLogLevel          = "debug"
 	defaultLMStudioURL       = "http://127.0.0.1:1234/v1"
 	defaultOpenRouterURL     = "https://openrouter.ai/api/v1"
 	defaultCodexURL          = "https://api.openai.com/v1"
+	defaultAnthropicURL      = "https://api.anthropic.com/v1"
+	defaultOllamaURL         = "http://127.0.0.1:11434"
 	defaultMaxTokens         = 8192
+	defaultSendReasoning     = true
 	defaultSignalHTTPHost    = "127.0.0.1"
 	defaultSignalHTTPPort    = 8080
 	defaultSignalCLIPath     = "signal-cli"
@@ -24,11 +34,51 @@ const (
 	defaultTextChunkLimit    = 4000
 	defaultMediaMaxMB        = 8
 	defaultWebhookListen     = "127.0.0.1:9090"
+	defaultSignatureHeader   = "X-Webhook-Signature"
+	defaultSyncTimeoutSecs   = 60
+	defaultOutboundRetries   = 3
+	defaultOutboundQueueSize = 100
+	defaultSignalDownSeconds = 60
+	defaultCommandWaitSecs   = 3
+	defaultMaxBodyBytes      = 1 << 20
+	defaultMaxInFlight       = 32
+	defaultQueueMaxDepth     = 100
+	defaultQueueMaxPerSource = 20
+	defaultCronRefreshSecs   = 30
+	defaultToolTimeoutSecs   = 1800
+	defaultMaxFilesPerOp     = 1000
+	defaultSnapshotMaxMB     = 100
+	defaultSnapshotKeep      = 5
+	defaultToolCallIDs       = "namespace"
+	defaultTruncatedCalls    = "retry"
+	defaultStoreBackend      = "sqlite"
+	defaultJournalMode       = "wal"
+	defaultBusyTimeoutMS     = 5000
+	defaultSynchronous       = "normal"
+	defaultMaxOpenConns      = 1
+	defaultCompactThreshold  = 0.8
+	defaultMaxToolRounds     = 25
+	defaultEventLogMaxRows   = 100000
+	defaultMaxParallelTools  = 4
 	defaultSandboxNetwork    = "none"
 	defaultHostUser          = "pipo-runner"
 	defaultMinScore          = 0.35
 	defaultResults           = 6
+	defaultVectorWeight      = 0.7
+	defaultFTSWeight         = 0.3
+	defaultChunkSizeTokens   = 500
+	defaultChunkOverlapToks  = 25
+	defaultEmbedBatchSize    = 64
+	defaultEmbedTimeoutSecs  = 30
+	defaultEmbedRetries      = 2
+	defaultEmbedBackoffMS    = 500
+	defaultEmbedBreakerFails = 5
+	defaultEmbedCooldownSecs = 60
 	defaultCitations         = "auto"
+	defaultHeartbeatSchedule = "*/30 * * * *"
+	defaultHeartbeatPrompt   = "heartbeat check"
+	defaultShutdownGraceSecs = 20
+	maxStopSequences         = 4
 )
 
 func Load(path string) (*Config, error) {
@@ -37,7 +87,7 @@ func Load(path string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("read config %q: %w", path, err)
 	}
-	var c Config
+	c := seedConfig()
 	if err := json.Unmarshal(b, &c); err != nil {
 		return nil, fmt.Errorf("parse config %q: %w", path, err)
 	}
@@ -61,6 +111,41 @@ func applyDefaults(c *Config) {
 	applyWebhookDefaults(&c.Webhook)
 	applySandboxDefaults(&c.Sandbox)
 	applyMemoryDefaults(&c.Memory)
+	applyQueueDefaults(&c.Agent.Queue)
+	applyHeartbeatDefaults(&c.Heartbeat)
+	if c.Shutdown.GraceSeconds == 0 {
+		c.Shutdown.GraceSeconds = defaultShutdownGraceSecs
+	}
+	if c.Agent.ToolCallIDs == "" {
+		c.Agent.ToolCallIDs = defaultToolCallIDs
+	}
+	if c.Agent.TruncatedToolCalls == "" {
+		c.Agent.TruncatedToolCalls = defaultTruncatedCalls
+	}
+	if c.Tools.CronRefreshSeconds == 0 {
+		c.Tools.CronRefreshSeconds = defaultCronRefreshSecs
+	}
+	if c.Tools.DefaultTimeoutSeconds == 0 {
+		c.Tools.DefaultTimeoutSeconds = defaultToolTimeoutSecs
+	}
+	if c.Tools.MaxFilesPerOp == 0 {
+		c.Tools.MaxFilesPerOp = defaultMaxFilesPerOp
+	}
+	if c.Tools.Snapshot.MaxMB == 0 {
+		c.Tools.Snapshot.MaxMB = defaultSnapshotMaxMB
+	}
+	if c.Tools.Snapshot.Keep == 0 {
+		c.Tools.Snapshot.Keep = defaultSnapshotKeep
+	}
+	if c.Agent.CompactThreshold == 0 {
+		c.Agent.CompactThreshold = defaultCompactThreshold
+	}
+	if c.Agent.MaxToolRounds == 0 {
+		c.Agent.MaxToolRounds = defaultMaxToolRounds
+	}
+	if c.Agent.MaxParallelTools == 0 {
+		c.Agent.MaxParallelTools = defaultMaxParallelTools
+	}
 
 }
 
@@ -75,9 +160,35 @@ func applyCoreDefaults(c *Config) {
 	if c.NoToolSleepRounds == 0 {
 		c.NoToolSleepRounds = defaultNoToolSleepRounds
 	}
+	if c.LogLevel == "" {
+		c.LogLevel = defaultLogLevel
+	}
+	if c.Store.Backend == "" {
+		c.Store.Backend = defaultStoreBackend
+	}
+	if c.Store.EventLogMaxRows == 0 {
+		c.Store.EventLogMaxRows = defaultEventLogMaxRows
+	}
+	applySQLiteDefaults(&c.Store.SQLite)
 
 }
 
+func applySQLiteDefaults(s *SQLiteConfig) {
+
+	if s.JournalMode == "" {
+		s.JournalMode = defaultJournalMode
+	}
+	if s.BusyTimeoutMS == 0 {
+		s.BusyTimeoutMS = defaultBusyTimeoutMS
+	}
+	if s.Synchronous == "" {
+		s.Synchronous = defaultSynchronous
+	}
+	if s.MaxOpenConns == 0 {
+		s.MaxOpenConns = defaultMaxOpenConns
+	}
+}
+
 func applyProviderDefaults(p *ProviderConfig) {
 
 	if p.BaseURL == "" {
@@ -88,6 +199,10 @@ func applyProviderDefaults(p *ProviderConfig) {
 			p.BaseURL = defaultOpenRouterURL
 		case "codex":
 			p.BaseURL = defaultCodexURL
+		case "anthropic":
+			p.BaseURL = defaultAnthropicURL
+		case "ollama":
+			p.BaseURL = defaultOllamaURL
 		}
 	}
 	if p.MaxTokens == 0 {
@@ -96,6 +211,9 @@ func applyProviderDefaults(p *ProviderConfig) {
 	if p.Backend == "lmstudio" && p.APIKey == "" {
 		p.APIKey = "lmstudio"
 	}
+	for i := range p.Fallbacks {
+		applyProviderDefaults(&p.Fallbacks[i])
+	}
 
 }
 
@@ -122,6 +240,9 @@ func applySignalDefaults(s *SignalConfig) {
 	if s.MediaMaxMB == 0 {
 		s.MediaMaxMB = defaultMediaMaxMB
 	}
+	if s.CommandWaitSeconds == 0 {
+		s.CommandWaitSeconds = defaultCommandWaitSecs
+	}
 
 }
 
@@ -134,6 +255,41 @@ func applyWebhookDefaults(w *WebhookConfig) {
 		if w.Hooks[i].Format == "" {
 			w.Hooks[i].Format = "text"
 		}
+		if w.Hooks[i].Secret != "" && strings.TrimSpace(w.Hooks[i].SignatureHeader) == "" {
+			w.Hooks[i].SignatureHeader = defaultSignatureHeader
+		}
+		if w.Hooks[i].Sync && w.Hooks[i].SyncTimeoutSeconds == 0 {
+			w.Hooks[i].SyncTimeoutSeconds = defaultSyncTimeoutSecs
+		}
+	}
+	if len(w.Outbound.Events) == 0 {
+		w.Outbound.Events = []string{"response"}
+	}
+	if w.Outbound.MaxRetries == 0 {
+		w.Outbound.MaxRetries = defaultOutboundRetries
+	}
+	if w.Outbound.QueueSize == 0 {
+		w.Outbound.QueueSize = defaultOutboundQueueSize
+	}
+	if w.SignalDownSeconds == 0 {
+		w.SignalDownSeconds = defaultSignalDownSeconds
+	}
+	if w.MaxBodyBytes == 0 {
+		w.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if w.MaxInFlight == 0 {
+		w.MaxInFlight = defaultMaxInFlight
+	}
+
+}
+
+func applyQueueDefaults(q *QueueConfig) {
+
+	if q.MaxDepth == 0 {
+		q.MaxDepth = defaultQueueMaxDepth
+	}
+	if q.MaxPerSource == 0 {
+		q.MaxPerSource = defaultQueueMaxPerSource
 	}
 
 }
@@ -165,6 +321,36 @@ func applyMemoryDefaults(m *MemoryConfig) {
 	if m.Citations == "" {
 		m.Citations = defaultCitations
 	}
+	if m.VectorWeight == 0 {
+		m.VectorWeight = defaultVectorWeight
+	}
+	if m.FTSWeight == 0 {
+		m.FTSWeight = defaultFTSWeight
+	}
+	if m.ChunkSizeTokens == 0 {
+		m.ChunkSizeTokens = defaultChunkSizeTokens
+	}
+	if m.ChunkOverlapTokens == 0 {
+		m.ChunkOverlapTokens = defaultChunkOverlapToks
+	}
+	if m.EmbeddingBatchSize == 0 {
+		m.EmbeddingBatchSize = defaultEmbedBatchSize
+	}
+	if m.EmbedTimeoutSeconds == 0 {
+		m.EmbedTimeoutSeconds = defaultEmbedTimeoutSecs
+	}
+	if m.EmbedRetries == 0 {
+		m.EmbedRetries = defaultEmbedRetries
+	}
+	if m.EmbedBackoffMS == 0 {
+		m.EmbedBackoffMS = defaultEmbedBackoffMS
+	}
+	if m.EmbedBreakerThreshold == 0 {
+		m.EmbedBreakerThreshold = defaultEmbedBreakerFails
+	}
+	if m.EmbedBreakerCooldownSeconds == 0 {
+		m.EmbedBreakerCooldownSeconds = defaultEmbedCooldownSecs
+	}
 
 }
 
@@ -210,31 +396,59 @@ func validate(c Config) error {
 	if err := validateProvider(c.Provider); err != nil {
 		return err
 	}
+	if err := validateFallbacks(c.Provider.Fallbacks); err != nil {
+		return err
+	}
 	if err := validateSignal(c.Signal); err != nil {
 		return err
 	}
 	if err := validateWebhooks(c.Webhook); err != nil {
 		return err
 	}
+	if err := validateOutboundWebhook(c.Webhook.Outbound); err != nil {
+		return err
+	}
 	if err := validateSandbox(c.Sandbox); err != nil {
 		return err
 	}
 	if err := validateMemory(c.Memory); err != nil {
 		return err
 	}
+	if c.Shutdown.GraceSeconds < 0 {
+		return fmt.Errorf("shutdown.grace_seconds must not be negative")
+	}
+	if err := validateHeartbeat(c.Heartbeat); err != nil {
+		return err
+	}
+	if err := validateAgent(c.Agent); err != nil {
+		return err
+	}
+	if err := validateTools(c.Tools); err != nil {
+		return err
+	}
+	if err := validateStore(c.Store); err != nil {
+		return err
+	}
+	if c.Limits.MaxCostPerThread < 0 {
+		return fmt.Errorf("limits.max_cost_per_thread must not be negative")
+	}
+	if c.Limits.MaxCostPerDay < 0 {
+		return fmt.Errorf("limits.max_cost_per_day must not be negative")
+	}
 	if c.NoToolSleepRounds <= 0 {
 		return fmt.Errorf("no_tool_sleep_rounds must be greater than zero")
 	}
+	if c.LogLevel != "debug" && c.LogLevel != "info" && c.LogLevel != "error" {
+		return fmt.Errorf("log_level must be one of debug, info, error")
+	}
 
 	return nil
 }
 
 func validateProvider(p ProviderConfig) error {
-	v := map[string]bool{"lmstudio": true, "openrouter": true, "codex": true}
-	e := map[string]bool{"low": true, "medium": true, "high": true}
 
-	if !v[p.Backend] {
-		return fmt.Errorf("provider.backend must be one of lmstudio, openrouter, codex")
+	if err := validateBackend(p); err != nil {
+		return err
 	}
 	if p.BaseURL == "" {
 		return fmt.Errorf("provider.base_url is required")
@@ -245,12 +459,161 @@ func validateProvider(p ProviderConfig) error {
 	if p.MaxTokens <= 0 {
 		return fmt.Errorf("provider.max_tokens must be greater than zero")
 	}
-	if (p.Backend == "openrouter" || p.Backend == "codex") && p.APIKey == "" {
-		return fmt.Errorf("provider.api_key is required for backend %q", p.Backend)
+	if p.ContextWindow != 0 && p.ContextWindow <= p.MaxTokens {
+		return fmt.Errorf("provider.context_window must be greater than provider.max_tokens")
+	}
+	if err := validateProviderCosts(p); err != nil {
+		return err
 	}
-	if p.ThinkingEffort != "" && !e[p.ThinkingEffort] {
+	if p.ThinkingEffort != "" && p.ThinkingEffort != "low" && p.ThinkingEffort != "medium" && p.ThinkingEffort != "high" {
 		return fmt.Errorf("provider.thinking_effort must be one of low, medium, high")
 	}
+	if p.MediaURLs != "" && p.MediaURLs != "pass" && p.MediaURLs != "download" {
+		return fmt.Errorf("provider.media_urls must be one of pass, download")
+	}
+	if p.RetryAttempts < 0 {
+		return fmt.Errorf("provider.retry_attempts must not be negative")
+	}
+	if p.RetryBaseMillis < 0 {
+		return fmt.Errorf("provider.retry_base_ms must not be negative")
+	}
+	if p.StreamIdleTimeoutSeconds < 0 {
+		return fmt.Errorf("provider.stream_idle_timeout_seconds must not be negative")
+	}
+	return validateSampling(p)
+}
+
+func validateBackend(p ProviderConfig) error {
+
+	switch p.Backend {
+	case "lmstudio", "ollama":
+	case "openrouter", "codex", "anthropic":
+		if p.APIKey == "" {
+			return fmt.Errorf("provider.api_key is required for backend %q", p.Backend)
+		}
+	default:
+		return fmt.Errorf("provider.backend must be one of lmstudio, openrouter, codex, anthropic, ollama")
+	}
+	if p.APIStyle != "" && p.APIStyle != "chat" && p.APIStyle != "responses" {
+		return fmt.Errorf("provider.api_style must be one of chat, responses")
+	}
+	return nil
+}
+
+func validateFallbacks(fallbacks []ProviderConfig) error {
+
+	for i, fb := range fallbacks {
+		if len(fb.Fallbacks) > 0 {
+			return fmt.Errorf("provider.fallbacks[%d].fallbacks is not supported", i)
+		}
+		if fb.SummaryModel != "" {
+			return fmt.Errorf("provider.fallbacks[%d].summary_model is not supported", i)
+		}
+		if err := validateProvider(fb); err != nil {
+			return fmt.Errorf("provider.fallbacks[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func validateProviderCosts(p ProviderConfig) error {
+
+	costs := []struct {
+		field string
+		value float64
+	}{
+		{"input_cost_per_mtok", p.InputCostPerMTok},
+		{"output_cost_per_mtok", p.OutputCostPerMTok},
+		{"cache_read_cost_per_mtok", p.CacheReadCostPerMTok},
+		{"summary_input_cost_per_mtok", p.SummaryInputCostPerMTok},
+		{"summary_output_cost_per_mtok", p.SummaryOutputCostPerMTok},
+	}
+	for _, c := range costs {
+		if c.value < 0 {
+			return fmt.Errorf("provider.%s must not be negative", c.field)
+		}
+	}
+	return nil
+}
+
+func validateSampling(p ProviderConfig) error {
+
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return fmt.Errorf("provider.temperature must be between 0 and 2")
+	}
+	if p.TopP != nil && (*p.TopP <= 0 || *p.TopP > 1) {
+		return fmt.Errorf("provider.top_p must be greater than 0 and at most 1")
+	}
+	if len(p.Stop) > maxStopSequences {
+		return fmt.Errorf("provider.stop must have at most %d entries", maxStopSequences)
+	}
+	for _, s := range p.Stop {
+		if s == "" {
+			return fmt.Errorf("provider.stop entries must not be empty")
+		}
+	}
+	return nil
+}
+
+func validateTools(t ToolsConfig) error {
+
+	for name, limit := range t.Concurrency {
+		if limit <= 0 {
+			return fmt.Errorf("tools.concurrency[%q] must be greater than zero", name)
+		}
+	}
+	if t.CronRefreshSeconds <= 0 {
+		return fmt.Errorf("tools.cron_refresh_seconds must be greater than zero")
+	}
+	if _, err := time.LoadLocation(t.CronTimezone); err != nil {
+		return fmt.Errorf("tools.cron_timezone: %v", err)
+	}
+	if t.MaxFilesPerOp <= 0 {
+		return fmt.Errorf("tools.max_files_per_op must be greater than zero")
+	}
+	if t.Snapshot.MaxMB <= 0 {
+		return fmt.Errorf("tools.snapshot.max_mb must be greater than zero")
+	}
+	if t.Snapshot.Keep <= 0 {
+		return fmt.Errorf("tools.snapshot.keep must be greater than zero")
+	}
+	if t.DefaultTimeoutSeconds <= 0 {
+		return fmt.Errorf("tools.default_timeout_seconds must be greater than zero")
+	}
+	if t.AutoSummarizeOver < 0 {
+		return fmt.Errorf("tools.auto_summarize_over must not be negative")
+	}
+	for name, secs := range t.Timeouts {
+		if secs <= 0 {
+			return fmt.Errorf("tools.timeouts[%q] must be greater than zero", name)
+		}
+	}
+	if err := validateToolPolicy("tools", ToolPolicy{Enabled: t.Enabled, Disabled: t.Disabled}); err != nil {
+		return err
+	}
+	for source, p := range t.Sources {
+		if source == "" {
+			return fmt.Errorf("tools.sources keys must not be empty")
+		}
+		if err := validateToolPolicy(fmt.Sprintf("tools.sources[%q]", source), p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateToolPolicy(field string, p ToolPolicy) error {
+
+	for _, name := range p.Enabled {
+		if name == "" {
+			return fmt.Errorf("%s.enabled entries must not be empty", field)
+		}
+	}
+	for _, name := range p.Disabled {
+		if name == "" {
+			return fmt.Errorf("%s.disabled entries must not be empty", field)
+		}
+	}
 	return nil
 }
 
@@ -286,11 +649,66 @@ func validateSignal(s SignalConfig) error {
 	if s.TextChunkLimit <= 0 || s.MediaMaxMB <= 0 {
 		return fmt.Errorf("signal.text_chunk_limit and signal.media_max_mb must be greater than zero")
 	}
+	if s.CommandWaitSeconds <= 0 {
+		return fmt.Errorf("signal.command_wait_seconds must be greater than zero")
+	}
+	if _, _, err := ParseDailyWindow(s.GroupOpenHours); err != nil {
+		return fmt.Errorf("signal.group_open_hours: %v", err)
+	}
+	if _, err := time.LoadLocation(s.GroupTimezone); err != nil {
+		return fmt.Errorf("signal.group_timezone: %v", err)
+	}
+	return validatePreprocess(s.Preprocess)
+}
+
+// ParseDailyWindow parses an "HH:MM-HH:MM" window into its start and end as
+// offsets from midnight. An empty window parses as two zero offsets.
+func ParseDailyWindow(window string) (time.Duration, time.Duration, error) {
+
+	if window == "" {
+		return 0, 0, nil
+	}
+	from, to, ok := strings.Cut(window, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%q must look like 09:00-18:00", window)
+	}
+	start, err := time.Parse("15:04", strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q must look like 09:00-18:00", window)
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(to))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q must look like 09:00-18:00", window)
+	}
+	if start.Equal(end) {
+		return 0, 0, fmt.Errorf("%q is empty", window)
+	}
+	midnight := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+	return start.Sub(midnight), end.Sub(midnight), nil
+}
+
+func validatePreprocess(steps []PreprocessStep) error {
+
+	for i, step := range steps {
+		switch step.Kind {
+		case "trim":
+		case "wake_word":
+			if len(step.Words) == 0 {
+				return fmt.Errorf("signal.preprocess[%d].words is required for kind wake_word", i)
+			}
+			for _, w := range step.Words {
+				if strings.TrimSpace(w) == "" {
+					return fmt.Errorf("signal.preprocess[%d].words must not contain empty entries", i)
+				}
+			}
+		default:
+			return fmt.Errorf("signal.preprocess[%d].kind must be one of wake_word, trim", i)
+		}
+	}
 	return nil
 }
 
 func validateWebhooks(w WebhookConfig) error {
-	v := map[string]bool{"text": true, "json": true}
 
 	if !w.Enabled {
 		return nil
@@ -298,26 +716,228 @@ func validateWebhooks(w WebhookConfig) error {
 	if w.Listen == "" {
 		return fmt.Errorf("webhook.listen is required when webhook.enabled=true")
 	}
-	if len(w.Hooks) == 0 {
+	if len(w.Hooks) == 0 && !w.HealthEnabled {
 		return fmt.Errorf("webhook.hooks is required when webhook.enabled=true")
 	}
+	if w.SignalDownSeconds <= 0 {
+		return fmt.Errorf("webhook.signal_down_seconds must be greater than zero")
+	}
+	if w.MaxBodyBytes <= 0 {
+		return fmt.Errorf("webhook.max_body_bytes must be greater than zero")
+	}
+	if w.MaxInFlight <= 0 {
+		return fmt.Errorf("webhook.max_in_flight must be greater than zero")
+	}
 	for i, h := range w.Hooks {
-		if h.ID == "" {
-			return fmt.Errorf("webhook.hooks[%d].id is required", i)
+		if err := validateHook(i, h); err != nil {
+			return err
 		}
-		if h.Path == "" {
-			return fmt.Errorf("webhook.hooks[%d].path is required", i)
+	}
+	return nil
+}
+
+func validateHook(i int, h WebhookDef) error {
+
+	if h.ID == "" {
+		return fmt.Errorf("webhook.hooks[%d].id is required", i)
+	}
+	if h.Path == "" {
+		return fmt.Errorf("webhook.hooks[%d].path is required", i)
+	}
+	if !strings.HasPrefix(h.Path, "/") {
+		return fmt.Errorf("webhook.hooks[%d].path must start with /", i)
+	}
+	if h.Format != "text" && h.Format != "json" {
+		return fmt.Errorf("webhook.hooks[%d].format must be text or json", i)
+	}
+	if h.SignatureHeader != "" && h.Secret == "" {
+		return fmt.Errorf("webhook.hooks[%d].signature_header requires secret", i)
+	}
+	if h.SyncTimeoutSeconds < 0 {
+		return fmt.Errorf("webhook.hooks[%d].sync_timeout_seconds must not be negative", i)
+	}
+	if h.MaxRequestsPerMinute < 0 {
+		return fmt.Errorf("webhook.hooks[%d].max_requests_per_minute must not be negative", i)
+	}
+	if (h.ContentTemplate != "" || h.ContentPath != "") && h.Format != "json" {
+		return fmt.Errorf("webhook.hooks[%d].content_template and content_path require format json", i)
+	}
+	if h.ContentTemplate != "" && h.ContentPath != "" {
+		return fmt.Errorf("webhook.hooks[%d] must set only one of content_template and content_path", i)
+	}
+	if _, err := template.New("content").Parse(h.ContentTemplate); err != nil {
+		return fmt.Errorf("webhook.hooks[%d].content_template: %v", i, err)
+	}
+	if err := validateHookSession(i, h); err != nil {
+		return err
+	}
+	if err := validateHookMetadataFields(i, h); err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateHookSession(i int, h WebhookDef) error {
+
+	if !strings.Contains(h.SessionID, "{{") {
+		return nil
+	}
+	if h.Format != "json" {
+		return fmt.Errorf("webhook.hooks[%d].session_id templates require format json", i)
+	}
+	if _, err := template.New("session").Parse(h.SessionID); err != nil {
+		return fmt.Errorf("webhook.hooks[%d].session_id: %v", i, err)
+	}
+	return nil
+}
+
+func validateHookMetadataFields(i int, h WebhookDef) error {
+
+	if len(h.MetadataFields) > 0 && h.Format != "json" {
+		return fmt.Errorf("webhook.hooks[%d].metadata_fields require format json", i)
+	}
+	for j, f := range h.MetadataFields {
+		if f == "" || slices.Contains(strings.Split(f, "."), "") {
+			return fmt.Errorf("webhook.hooks[%d].metadata_fields[%d] must be a dotted path like alerts.0.status", i, j)
+		}
+	}
+	return nil
+}
+
+func validateOutboundWebhook(o OutboundWebhookConfig) error {
+	v := map[string]bool{"response": true, "error": true, "compact": true, "compaction": true, "tool_start": true, "tool_output": true, "tool_result": true, "thinking": true}
+
+	if o.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(o.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook.outbound.url must be an http or https URL")
+	}
+	for _, e := range o.Events {
+		if !v[e] {
+			return fmt.Errorf("webhook.outbound.events must contain only response, error, compact, compaction, tool_start, tool_output, tool_result, thinking")
+		}
+	}
+	if o.MaxRetries < 0 {
+		return fmt.Errorf("webhook.outbound.max_retries must not be negative")
+	}
+	if o.QueueSize <= 0 {
+		return fmt.Errorf("webhook.outbound.queue_size must be greater than zero")
+	}
+	return nil
+}
+
+func validateStore(s StoreConfig) error {
+
+	if s.EventLogMaxRows < 0 {
+		return fmt.Errorf("store.event_log_max_rows must not be negative")
+	}
+	switch s.Backend {
+	case "sqlite":
+		return validateSQLite(s.SQLite)
+	case "postgres":
+		if strings.TrimSpace(s.PostgresDSN) == "" {
+			return fmt.Errorf("store.postgres_dsn is required when store.backend is postgres")
 		}
-		if !strings.HasPrefix(h.Path, "/") {
-			return fmt.Errorf("webhook.hooks[%d].path must start with /", i)
+		return nil
+	}
+	return fmt.Errorf("store.backend must be sqlite or postgres")
+}
+
+func validateSQLite(s SQLiteConfig) error {
+
+	switch s.JournalMode {
+	case "wal", "delete", "truncate", "persist":
+	default:
+		return fmt.Errorf("store.sqlite.journal_mode must be one of wal, delete, truncate, persist")
+	}
+	switch s.Synchronous {
+	case "off", "normal", "full":
+	default:
+		return fmt.Errorf("store.sqlite.synchronous must be one of off, normal, full")
+	}
+	if s.BusyTimeoutMS <= 0 {
+		return fmt.Errorf("store.sqlite.busy_timeout_ms must be greater than zero")
+	}
+	if s.MaxOpenConns <= 0 {
+		return fmt.Errorf("store.sqlite.max_open_conns must be greater than zero")
+	}
+	return nil
+}
+
+func validateAgent(a AgentConfig) error {
+
+	if err := validateQueue(a.Queue); err != nil {
+		return err
+	}
+	if err := validateRotation(a.Rotation); err != nil {
+		return err
+	}
+	if a.ToolCallIDs != "namespace" && a.ToolCallIDs != "provider" {
+		return fmt.Errorf("agent.tool_call_ids must be namespace or provider")
+	}
+	if a.TruncatedToolCalls != "retry" && a.TruncatedToolCalls != "error" {
+		return fmt.Errorf("agent.truncated_tool_calls must be retry or error")
+	}
+	if a.CompactThreshold <= 0 || a.CompactThreshold > 1 {
+		return fmt.Errorf("agent.compact_threshold must be greater than zero and at most 1")
+	}
+	if a.CompactKeepMessages < 0 {
+		return fmt.Errorf("agent.compact_keep_messages must not be negative")
+	}
+	if a.MaxToolRounds <= 0 {
+		return fmt.Errorf("agent.max_tool_rounds must be greater than zero")
+	}
+	if a.MaxParallelTools <= 0 {
+		return fmt.Errorf("agent.max_parallel_tools must be greater than zero")
+	}
+	if a.CoalesceWindowMS < 0 {
+		return fmt.Errorf("agent.coalesce_window_ms must not be negative")
+	}
+	if a.GenerationTimeoutMS < 0 {
+		return fmt.Errorf("agent.generation_timeout_ms must not be negative")
+	}
+	return nil
+}
+
+func validateQueue(q QueueConfig) error {
+
+	if q.MaxDepth <= 0 {
+		return fmt.Errorf("agent.queue.max_depth must be greater than zero")
+	}
+	if q.MaxPerSource <= 0 {
+		return fmt.Errorf("agent.queue.max_per_source must be greater than zero")
+	}
+	for source, limit := range q.Sources {
+		if strings.TrimSpace(source) == "" {
+			return fmt.Errorf("agent.queue.sources keys must not be empty")
 		}
-		if !v[h.Format] {
-			return fmt.Errorf("webhook.hooks[%d].format must be text or json", i)
+		if limit <= 0 {
+			return fmt.Errorf("agent.queue.sources[%q] must be greater than zero", source)
 		}
 	}
 	return nil
 }
 
+func validateRotation(r RotationConfig) error {
+
+	v := map[string]bool{"": true, "daily": true, "weekly": true, "monthly": true}
+	if !v[r.Period] {
+		return fmt.Errorf("agent.rotation.period must be one of daily, weekly, monthly")
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return fmt.Errorf("agent.rotation.timezone: %v", err)
+	}
+	return nil
+}
+
+// memoryLimitPattern matches docker's --memory sizes: a byte count with an
+// optional b, k, m or g unit.
+var memoryLimitPattern = regexp.MustCompile(`^[1-9][0-9]*[bkmgBKMG]?$`)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 func validateSandbox(s SandboxConfig) error {
 	v := map[string]bool{"ro": true, "rw": true}
 
@@ -349,6 +969,30 @@ func validateSandbox(s SandboxConfig) error {
 			return fmt.Errorf("sandbox.host_commands[%d] must be a single command name", i)
 		}
 	}
+	for cmd, names := range s.HostCommandEnv {
+		if !slices.Contains(s.HostCommands, cmd) {
+			return fmt.Errorf("sandbox.host_command_env: %q is not in sandbox.host_commands", cmd)
+		}
+		for _, name := range names {
+			if !envNamePattern.MatchString(name) {
+				return fmt.Errorf("sandbox.host_command_env[%q]: %q is not a variable name", cmd, name)
+			}
+			// The host always sets these, and the loader ones pick the code
+			// the command runs.
+			if name == "PATH" || name == "HOME" || name == "USER" || strings.HasPrefix(name, "LD_") || strings.HasPrefix(name, "DYLD_") {
+				return fmt.Errorf("sandbox.host_command_env[%q]: %s cannot be forwarded", cmd, name)
+			}
+		}
+	}
+	if s.MemoryLimit != "" && !memoryLimitPattern.MatchString(s.MemoryLimit) {
+		return fmt.Errorf("sandbox.memory_limit must be a size like 512m or 2g")
+	}
+	if s.CPULimit != "" {
+		cpus, err := strconv.ParseFloat(s.CPULimit, 64)
+		if err != nil || cpus <= 0 {
+			return fmt.Errorf("sandbox.cpu_limit must be a positive number like 1.5")
+		}
+	}
 	return nil
 }
 
@@ -373,5 +1017,57 @@ func validateMemory(m MemoryConfig) error {
 	if !v[m.Citations] {
 		return fmt.Errorf("memory.citations must be one of on, off, auto")
 	}
+	if m.VectorWeight < 0 || m.FTSWeight < 0 {
+		return fmt.Errorf("memory.vector_weight and memory.fts_weight must not be negative")
+	}
+	if m.ChunkSizeTokens <= 0 {
+		return fmt.Errorf("memory.chunk_size_tokens must be greater than zero")
+	}
+	if m.ChunkOverlapTokens < 0 || m.ChunkOverlapTokens >= m.ChunkSizeTokens {
+		return fmt.Errorf("memory.chunk_overlap_tokens must be at least zero and less than memory.chunk_size_tokens")
+	}
+	if m.EmbeddingBatchSize <= 0 {
+		return fmt.Errorf("memory.embedding_batch_size must be greater than zero")
+	}
+	for name, v := range map[string]int{
+		"embed_timeout_seconds":   m.EmbedTimeoutSeconds,
+		"embed_retries":           m.EmbedRetries,
+		"embed_breaker_threshold": m.EmbedBreakerThreshold,
+	} {
+		if v < -1 {
+			return fmt.Errorf("memory.%s must be -1 (off) or more", name)
+		}
+	}
+	for name, v := range map[string]int{
+		"embed_backoff_ms":               m.EmbedBackoffMS,
+		"embed_breaker_cooldown_seconds": m.EmbedBreakerCooldownSeconds,
+	} {
+		if v < 0 {
+			return fmt.Errorf("memory.%s must not be negative", name)
+		}
+	}
+	return nil
+}
+
+func applyHeartbeatDefaults(h *HeartbeatConfig) {
+
+	if !h.Enabled {
+		return
+	}
+	if h.Schedule == "" {
+		h.Schedule = defaultHeartbeatSchedule
+	}
+	if strings.TrimSpace(h.Prompt) == "" {
+		h.Prompt = defaultHeartbeatPrompt
+	}
+}
+
+// validateHeartbeat checks the shape of heartbeat.schedule; the runtime
+// parses the fields themselves when it registers the job.
+func validateHeartbeat(h HeartbeatConfig) error {
+
+	if h.Enabled && len(strings.Fields(h.Schedule)) != 5 {
+		return fmt.Errorf("heartbeat.schedule must be a five-field cron expression")
+	}
 	return nil
 }
@@ -18,7 +18,7 @@ func TestSaveRoundTrip(t *testing.T) {
 	if err != nil {
 		t.Fatalf("load config: %v", err)
 	}
-	if loaded.Provider.Backend != "lmstudio" || loaded.Provider.Model != "qwen2.5" {
+	if loaded.Provider.Backend != "lmstudio" || loaded.Provider.Model != "qwen2.5" || !loaded.Provider.SendReasoning {
 		t.Fatalf("unexpected provider: %#v", loaded.Provider)
 	}
 }
@@ -0,0 +1,37 @@
+package miclaw
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+)
+
+// TailEvents writes the newest n events of the configured store's event
+// log to w, oldest first, one line each: time, type, source and content
+// with its whitespace collapsed.
+func TailEvents(cfg *config.Config, n int, w io.Writer) error {
+
+	s, err := openCLIStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	events, err := s.Events().Tail(n)
+	if err != nil {
+		return err
+	}
+	for _, ev := range events {
+		source := ev.Source
+		if source == "" {
+			source = "-"
+		}
+		content := strings.Join(strings.Fields(ev.Content), " ")
+		if _, err := fmt.Fprintf(w, "%s %s %s %s\n", ev.CreatedAt.Format(time.RFC3339), ev.Type, source, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
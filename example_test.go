@@ -0,0 +1,68 @@
+package miclaw_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/agusx1211/miclaw"
+	"github.com/agusx1211/miclaw/agent"
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+)
+
+// cannedProvider answers every turn with a fixed reply and then calls the
+// sleep tool so the agent goes idle.
+type cannedProvider struct{}
+
+func (cannedProvider) Stream(context.Context, []model.Message, []provider.ToolDef, provider.ToolChoice) <-chan provider.ProviderEvent {
+	ch := make(chan provider.ProviderEvent, 4)
+	ch <- provider.ProviderEvent{Type: provider.EventContentDelta, Delta: "2+2 is 4."}
+	ch <- provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "call-1", ToolName: "sleep"}
+	ch <- provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: "call-1"}
+	ch <- provider.ProviderEvent{Type: provider.EventComplete}
+	close(ch)
+	return ch
+}
+
+func (cannedProvider) Model() provider.ModelInfo {
+	return provider.ModelInfo{ID: "canned"}
+}
+
+func ExampleNew() {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	root, err := os.MkdirTemp("", "miclaw-example")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(root)
+
+	cfg := config.Default()
+	cfg.Provider = config.ProviderConfig{Backend: "lmstudio", Model: "canned"}
+	cfg.Workspace = filepath.Join(root, "workspace")
+	cfg.StatePath = filepath.Join(root, "state")
+	rt, err := miclaw.New(&cfg, miclaw.Options{Provider: cannedProvider{}})
+	if err != nil {
+		panic(err)
+	}
+	defer rt.Shutdown(context.Background())
+
+	if err := rt.RunOnce(context.Background(), agent.Input{Source: "app", Content: "What is 2+2?"}); err != nil {
+		panic(err)
+	}
+	msgs, err := rt.Messages().List(10, 0)
+	if err != nil {
+		panic(err)
+	}
+	for _, msg := range msgs[:2] {
+		fmt.Printf("%s: %s\n", msg.Role, msg.Parts[0].(model.TextPart).Text)
+	}
+	// Output:
+	// user: [app] What is 2+2?
+	// assistant: 2+2 is 4.
+}
@@ -4,6 +4,7 @@ go 1.25.7
 
 require (
 	github.com/google/uuid v1.6.0
+	github.com/lib/pq v1.12.3
 	modernc.org/sqlite v1.46.1
 )
 
@@ -0,0 +1,49 @@
+package miclaw
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/webhook"
+)
+
+// health reports the state served on /healthz. The runtime is unavailable
+// once the Signal stream has been down longer than webhook.signal_down_seconds.
+func (r *Runtime) health() webhook.Health {
+
+	h := webhook.Health{
+		Status:        webhook.HealthOK,
+		UptimeSeconds: int64(time.Since(r.startedAt).Seconds()),
+		AgentActive:   r.agent.IsActive(),
+		QueueDepth:    r.agent.QueueDepth(),
+	}
+	if err, at := r.agent.LastError(); providerErrorSummary(err) != "" {
+		h.LastProviderError = providerErrorSummary(err)
+		h.LastProviderErrorAt = &at
+	}
+	if r.signal == nil {
+		return h
+	}
+	connected, since := r.signal.StreamStatus()
+	h.Signal = &webhook.SignalHealth{Connected: connected, Since: since}
+	threshold := time.Duration(r.cfg.Webhook.SignalDownSeconds) * time.Second
+	if !connected && time.Since(since) > threshold {
+		h.Status = webhook.HealthUnavailable
+	}
+	return h
+}
+
+// providerErrorSummary names the backend and HTTP status of a failed provider
+// request, leaving out the response body, which may echo the API key. It is
+// empty for errors that are not provider responses, such as a spent budget
+// or a generation timeout.
+func providerErrorSummary(err error) string {
+
+	var status *provider.StatusError
+	if !errors.As(err, &status) {
+		return ""
+	}
+	return fmt.Sprintf("%s: status %d", status.Backend, status.Status)
+}
@@ -0,0 +1,102 @@
+package miclaw
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/agent"
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/provider"
+	signalpipe "github.com/agusx1211/miclaw/signal"
+	"github.com/agusx1211/miclaw/webhook"
+)
+
+func getHealth(t *testing.T, url string) (int, webhook.Health) {
+	t.Helper()
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("get healthz: %v", err)
+	}
+	defer res.Body.Close()
+	var h webhook.Health
+	if err := json.NewDecoder(res.Body).Decode(&h); err != nil {
+		t.Fatalf("decode healthz: %v", err)
+	}
+	return res.StatusCode, h
+}
+
+func TestHealthzServedWithoutHooks(t *testing.T) {
+	listen := reserveListenAddr(t)
+	cfg := testConfig(t)
+	cfg.Webhook.Listen = listen
+	cfg.Webhook.HealthEnabled = true
+	newTestRuntime(t, cfg, Options{Provider: scriptedProvider{}, Webhook: true})
+	waitHTTP(t, "http://"+listen+"/health")
+
+	code, h := getHealth(t, "http://"+listen+"/healthz")
+	if code != http.StatusOK || h.Status != webhook.HealthOK {
+		t.Fatalf("code=%d health=%+v", code, h)
+	}
+	if h.Signal != nil || h.AgentActive || h.QueueDepth != 0 || h.LastProviderError != "" {
+		t.Fatalf("unexpected health: %+v", h)
+	}
+}
+
+func TestHealthReportsSignalStreamDown(t *testing.T) {
+	hold := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		<-hold
+	}))
+	defer srv.Close()
+	cfg := testConfig(t)
+	cfg.Webhook = config.WebhookConfig{SignalDownSeconds: 1}
+	rt := newTestRuntime(t, cfg, Options{Provider: scriptedProvider{}})
+	rt.signal = signalpipe.NewClient(srv.URL, "+10000000000")
+
+	if h := rt.health(); h.Status != webhook.HealthOK || h.Signal == nil || h.Signal.Connected {
+		t.Fatalf("want ok within threshold before connecting, got %+v", h)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range rt.signal.Listen(t.Context()) {
+		}
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for h := rt.health(); !h.Signal.Connected; h = rt.health() {
+		if time.Now().After(deadline) {
+			t.Fatal("signal stream never connected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(hold)
+	srv.CloseClientConnections()
+	<-done
+	if h := rt.health(); h.Status != webhook.HealthOK || h.Signal.Connected {
+		t.Fatalf("want ok right after disconnect, got %+v", h)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if h := rt.health(); h.Status != webhook.HealthUnavailable {
+		t.Fatalf("want unavailable after threshold, got %+v", h)
+	}
+}
+
+func TestProviderErrorSummaryHidesBody(t *testing.T) {
+	cases := map[error]string{
+		fmt.Errorf("%w: %w", agent.ErrProviderAuth, &provider.StatusError{Backend: "openrouter", Status: 401, Body: "invalid key sk-or-123"}): "openrouter: status 401",
+		fmt.Errorf("%w: spent $1.00", agent.ErrBudgetExceeded):                                                                                "",
+		agent.ErrGenerationTimeout: "",
+		nil:                        "",
+	}
+	for err, want := range cases {
+		if got := providerErrorSummary(err); got != want {
+			t.Fatalf("providerErrorSummary(%v) = %q, want %q", err, got, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package logging filters the standard logger by level. Every log line in
+// miclaw states its level explicitly, and one process-wide setting decides
+// which levels are written.
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is a log verbosity, from most to least verbose. At Debug everything
+// is logged, including the agent trace and typing updates; Info keeps
+// inputs, replies and lifecycle lines; Error keeps only failures.
+type Level int32
+
+const (
+	Debug Level = iota
+	Info
+	Error
+)
+
+var levelNames = []string{"debug", "info", "error"}
+
+// level is process-wide, like the standard logger it filters.
+var level atomic.Int32
+
+// ParseLevel returns the level named debug, info or error.
+func ParseLevel(name string) (Level, error) {
+
+	for i, n := range levelNames {
+		if n == strings.ToLower(strings.TrimSpace(name)) {
+			return Level(i), nil
+		}
+	}
+	return Debug, fmt.Errorf("log level must be one of %s", strings.Join(levelNames, ", "))
+}
+
+// SetLevel changes the verbosity for every later log line.
+func SetLevel(name string) error {
+
+	l, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Store(int32(l))
+	return nil
+}
+
+// LevelName returns the current verbosity.
+func LevelName() string {
+
+	return levelNames[level.Load()]
+}
+
+// Printf logs through the standard logger when l is at or above the current
+// level.
+func Printf(l Level, format string, args ...any) {
+
+	if int32(l) < level.Load() {
+		return
+	}
+	log.Output(2, fmt.Sprintf(format, args...))
+}
+
+func Debugf(format string, args ...any) {
+
+	Printf(Debug, format, args...)
+}
+
+func Infof(format string, args ...any) {
+
+	Printf(Info, format, args...)
+}
+
+func Errorf(format string, args ...any) {
+
+	Printf(Error, format, args...)
+}
@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPrintfFiltersBelowLevel(t *testing.T) {
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	t.Cleanup(func() { _ = SetLevel("debug") })
+
+	if err := SetLevel("INFO"); err != nil {
+		t.Fatalf("set level: %v", err)
+	}
+	Debugf("[sandbox] tool dispatch name=%s", "exec")
+	Infof("[webhook] busy hook=%s", "h1")
+	Errorf("[cron] reload failed: %v", "boom")
+	got := logs.String()
+	if strings.Contains(got, "tool dispatch") || !strings.Contains(got, "busy hook=h1") || !strings.Contains(got, "reload failed: boom") {
+		t.Fatalf("info level kept the wrong lines: %q", got)
+	}
+	if LevelName() != "info" {
+		t.Fatalf("unexpected level %q", LevelName())
+	}
+	if err := SetLevel("verbose"); err == nil || LevelName() != "info" {
+		t.Fatalf("invalid level accepted: %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+package miclaw
+
+import "github.com/agusx1211/miclaw/logging"
+
+// SetLogLevel changes the log verbosity for every later log line, in every
+// package.
+func (r *Runtime) SetLogLevel(name string) error {
+
+	return logging.SetLevel(name)
+}
+
+// LogLevel returns the current log verbosity.
+func (r *Runtime) LogLevel() string {
+
+	return logging.LevelName()
+}
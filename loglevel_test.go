@@ -0,0 +1,68 @@
+package miclaw
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/logging"
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/tools"
+)
+
+func runLogLevelTool(t *testing.T, rt *Runtime, params map[string]any) tools.ToolResult {
+	t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("marshal params: %v", err)
+	}
+	for _, tl := range rt.mainTools(Options{}, provider.ModelInfo{}) {
+		if tl.Name() != "log_level" {
+			continue
+		}
+		res, err := tl.Run(context.Background(), model.ToolCallPart{Name: "log_level", Parameters: raw})
+		if err != nil {
+			t.Fatalf("run log_level: %v", err)
+		}
+		return res
+	}
+	t.Fatal("log_level tool missing")
+	return tools.ToolResult{}
+}
+
+func TestLogLevelToolFiltersLaterLogLines(t *testing.T) {
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	rt := newTestRuntime(t, testConfig(t), Options{Provider: scriptedProvider{reply: "pong"}})
+	t.Cleanup(func() { _ = rt.SetLogLevel("debug") })
+
+	if res := runLogLevelTool(t, rt, map[string]any{}); res.Content != "log level: debug" {
+		t.Fatalf("unexpected current level: %q", res.Content)
+	}
+	if res := runLogLevelTool(t, rt, map[string]any{"level": "error"}); res.IsError || res.Content != "log level: error (was debug)" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	logs.Reset()
+	rt.trace(logging.Debug, "in source=%s", "test")
+	rt.trace(logging.Error, "rotate_error=%v", "boom")
+	logging.Infof("[signal] in source=%s", "test")
+	logging.Errorf("[signal] out_error to=%s", "test")
+	if got := logs.String(); strings.Contains(got, "[agent] in") || strings.Contains(got, "[signal] in") || !strings.Contains(got, "out_error") || !strings.Contains(got, "rotate_error=boom") {
+		t.Fatalf("error level kept the wrong lines: %q", got)
+	}
+
+	runLogLevelTool(t, rt, map[string]any{"level": "debug"})
+	logs.Reset()
+	rt.trace(logging.Debug, "in source=%s", "test")
+	if !strings.Contains(logs.String(), "[agent] in source=test") {
+		t.Fatalf("debug level dropped the agent trace: %q", logs.String())
+	}
+	if res := runLogLevelTool(t, rt, map[string]any{"level": "verbose"}); !res.IsError || rt.LogLevel() != "debug" {
+		t.Fatalf("invalid level accepted: %+v", res)
+	}
+}
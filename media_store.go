@@ -0,0 +1,49 @@
+package miclaw
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/store"
+)
+
+// mediaDownloadTimeout bounds fetching the media of one stored message.
+const mediaDownloadTimeout = time.Minute
+
+// mediaStore fits the attachments of each message once, as it is stored,
+// so the providers do not decode and downscale them on every request. With
+// download set, media URLs are fetched then too and their bytes kept.
+type mediaStore struct {
+	store.MessageStore
+	download bool
+	client   *http.Client
+}
+
+func newMediaStore(messages store.MessageStore, download bool) mediaStore {
+
+	return mediaStore{MessageStore: messages, download: download, client: &http.Client{}}
+}
+
+func (m mediaStore) Create(msg *model.Message) error {
+
+	msg.Parts = m.prepare(msg.Parts)
+	return m.MessageStore.Create(msg)
+}
+
+func (m mediaStore) ReplaceAll(msgs []*model.Message) error {
+
+	for _, msg := range msgs {
+		msg.Parts = m.prepare(msg.Parts)
+	}
+	return m.MessageStore.ReplaceAll(msgs)
+}
+
+func (m mediaStore) prepare(parts []model.MessagePart) []model.MessagePart {
+
+	ctx, cancel := context.WithTimeout(context.Background(), mediaDownloadTimeout)
+	defer cancel()
+	return provider.PrepareMedia(ctx, m.client, parts, m.download)
+}
@@ -0,0 +1,55 @@
+package miclaw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+func TestMessagesFitAttachmentsWhenStored(t *testing.T) {
+	rt := newTestRuntime(t, testConfig(t), Options{Provider: scriptedProvider{reply: "ok"}})
+	msg := &model.Message{ID: "m1", Role: model.RoleUser, CreatedAt: time.Now().UTC(), Parts: []model.MessagePart{
+		model.TextPart{Text: "see attached"},
+		model.BinaryPart{MimeType: "image/webp", Data: make([]byte, 6<<20)},
+	}}
+	if err := rt.Messages().Create(msg); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	stored, err := rt.Messages().Get("m1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	note, ok := stored.Parts[1].(model.TextPart)
+	if !ok || !strings.HasPrefix(note.Text, "[image/webp attachment omitted:") {
+		t.Fatalf("oversized attachment stored as is: %T", stored.Parts[1])
+	}
+}
+
+func TestMessagesDownloadMediaWhenStored(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("png"))
+	}))
+	defer srv.Close()
+	cfg := testConfig(t)
+	cfg.Provider.MediaURLs = "download"
+	rt := newTestRuntime(t, cfg, Options{Provider: scriptedProvider{reply: "ok"}})
+	msg := &model.Message{ID: "m1", Role: model.RoleUser, CreatedAt: time.Now().UTC(), Parts: []model.MessagePart{
+		model.ImageURLPart{URL: srv.URL + "/cat.png"},
+	}}
+	if err := rt.Messages().Create(msg); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	stored, err := rt.Messages().Get("m1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	u, ok := stored.Parts[0].(model.ImageURLPart)
+	if !ok || u.URL != srv.URL+"/cat.png" || u.MimeType != "image/png" || string(u.Data) != "png" {
+		t.Fatalf("stored part = %#v", stored.Parts[0])
+	}
+}
@@ -4,28 +4,184 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 )
 
+// defaultBatchSize is how many texts go into one embedding request unless
+// SetBatchSize says otherwise.
+const defaultBatchSize = 64
+
+// ErrEmbedCircuitOpen is returned without contacting the endpoint while the
+// circuit breaker is open. Callers match it with errors.Is.
+var ErrEmbedCircuitOpen = errors.New("embedding endpoint unavailable")
+
+// EmbedLimits bounds how long Embed waits on a slow or failing endpoint.
+// Each request is cut off after Timeout and retried up to Retries times,
+// waiting Backoff and then twice as long before each retry. After
+// BreakerThreshold calls in a row fail, Embed fails fast with
+// ErrEmbedCircuitOpen for BreakerCooldown, then lets one call through to
+// probe the endpoint. Zero values turn the timeout, retries or breaker off.
+type EmbedLimits struct {
+	Timeout          time.Duration
+	Retries          int
+	Backoff          time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
 type EmbedClient struct {
-	baseURL string
-	apiKey  string
-	model   string
-	client  *http.Client
+	baseURL   string
+	apiKey    string
+	model     string
+	batchSize int
+	client    *http.Client
+	limits    EmbedLimits
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
 }
 
 func NewEmbedClient(baseURL, apiKey, model string) *EmbedClient {
 	return &EmbedClient{
-		baseURL: strings.TrimRight(baseURL, "/"),
-		apiKey:  apiKey,
-		model:   model,
-		client:  &http.Client{},
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		apiKey:    apiKey,
+		model:     model,
+		batchSize: defaultBatchSize,
+		client:    &http.Client{},
+		now:       time.Now,
+	}
+}
+
+// SetLimits sets the request timeout, retries and circuit breaker.
+func (c *EmbedClient) SetLimits(l EmbedLimits) {
+	c.limits = l
+}
+
+// SetBatchSize caps how many texts are sent per embedding request. A value
+// of zero or less keeps the default.
+func (c *EmbedClient) SetBatchSize(n int) {
+	if n <= 0 {
+		return
 	}
+	c.batchSize = n
 }
 
+// Embed returns one vector per text, in order, splitting texts into
+// requests of at most the batch size.
 func (c *EmbedClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := c.allow(); err != nil {
+		return nil, err
+	}
+	out, err := c.embed(ctx, texts)
+	if ctx.Err() == nil {
+		c.record(err)
+	}
+	return out, err
+}
+
+func (c *EmbedClient) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += c.batchSize {
+		batch := texts[start:min(start+c.batchSize, len(texts))]
+		vecs, err := c.embedWithRetry(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(vecs) != len(batch) {
+			return nil, fmt.Errorf("embedding count mismatch: sent %d texts, got %d vectors", len(batch), len(vecs))
+		}
+		out = append(out, vecs...)
+	}
+	return out, nil
+}
+
+// allow fails fast while the breaker is open. Once the cooldown has passed
+// the breaker stays armed, so a failed probe reopens it at once.
+func (c *EmbedClient) allow() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.openUntil.IsZero() || !c.now().Before(c.openUntil) {
+		return nil
+	}
+	return fmt.Errorf("%w: %d failures in a row, retrying after %s", ErrEmbedCircuitOpen, c.failures, c.openUntil.Format(time.RFC3339))
+}
+
+func (c *EmbedClient) record(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.failures, c.openUntil = 0, time.Time{}
+		return
+	}
+	c.failures++
+	if c.limits.BreakerThreshold > 0 && c.failures >= c.limits.BreakerThreshold {
+		c.openUntil = c.now().Add(c.limits.BreakerCooldown)
+	}
+}
+
+// embedWithRetry sends one batch, retrying timeouts, connection errors,
+// 429 and 5xx responses.
+func (c *EmbedClient) embedWithRetry(ctx context.Context, texts []string) ([][]float32, error) {
+	wait := c.limits.Backoff
+	for attempt := 0; ; attempt++ {
+		vecs, err := c.embedOnce(ctx, texts)
+		if err == nil || attempt >= c.limits.Retries || ctx.Err() != nil || !retryableEmbedError(err) {
+			return vecs, err
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+		wait *= 2
+	}
+}
+
+func (c *EmbedClient) embedOnce(ctx context.Context, texts []string) ([][]float32, error) {
+	if c.limits.Timeout <= 0 {
+		return c.embedBatch(ctx, texts)
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, c.limits.Timeout)
+	defer cancel()
+	vecs, err := c.embedBatch(reqCtx, texts)
+	if err != nil && ctx.Err() == nil && errors.Is(reqCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("embedding request timed out after %s: %w", c.limits.Timeout, context.DeadlineExceeded)
+	}
+	return vecs, err
+}
+
+// embedStatusError is a non-2xx response from the embedding endpoint.
+type embedStatusError struct {
+	code int
+}
+
+func (e *embedStatusError) Error() string {
+	return fmt.Sprintf("embedding status %d", e.code)
+}
+
+// retryableEmbedError reports whether a failed request may succeed if sent
+// again: timeouts, transport errors, 429 and 5xx. A response that does not
+// decode would come back the same way.
+func retryableEmbedError(err error) bool {
+	var status *embedStatusError
+	if errors.As(err, &status) {
+		return status.code == http.StatusTooManyRequests || status.code >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (c *EmbedClient) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
 	body, err := json.Marshal(struct {
 		Model string   `json:"model"`
 		Input []string `json:"input"`
@@ -47,7 +203,7 @@ func (c *EmbedClient) Embed(ctx context.Context, texts []string) ([][]float32, e
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= http.StatusMultipleChoices {
-		return nil, fmt.Errorf("embedding status %d", resp.StatusCode)
+		return nil, &embedStatusError{code: resp.StatusCode}
 	}
 	var parsed struct {
 		Data []struct {
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyEmbedServer fails the first fail requests with status, sleeping
+// delay first when status is 0, then answers with one-element vectors.
+func flakyEmbedServer(t *testing.T, fail int32, status int, delay time.Duration) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if n <= fail {
+			if status == 0 {
+				select {
+				case <-time.After(delay):
+				case <-r.Context().Done():
+				}
+				return
+			}
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[{"embedding":[1]}]}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestEmbedRetriesServerErrors(t *testing.T) {
+	srv, calls := flakyEmbedServer(t, 2, http.StatusBadGateway, 0)
+	c := NewEmbedClient(srv.URL, "", "m")
+	c.SetLimits(EmbedLimits{Retries: 2, Backoff: time.Millisecond})
+
+	vecs, err := c.Embed(context.Background(), []string{"x"})
+	if err != nil || len(vecs) != 1 {
+		t.Fatalf("embed after retries: %v %v", vecs, err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("requests = %d, want 3", calls.Load())
+	}
+}
+
+func TestEmbedDoesNotRetryClientErrors(t *testing.T) {
+	srv, calls := flakyEmbedServer(t, 5, http.StatusUnauthorized, 0)
+	c := NewEmbedClient(srv.URL, "", "m")
+	c.SetLimits(EmbedLimits{Retries: 3, Backoff: time.Millisecond})
+
+	if _, err := c.Embed(context.Background(), []string{"x"}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected 401 error, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("requests = %d, want 1", calls.Load())
+	}
+}
+
+func TestEmbedDoesNotRetryMalformedResponses(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"data":[`))
+	}))
+	t.Cleanup(srv.Close)
+	c := NewEmbedClient(srv.URL, "", "m")
+	c.SetLimits(EmbedLimits{Retries: 3, Backoff: time.Millisecond})
+
+	if _, err := c.Embed(context.Background(), []string{"x"}); err == nil {
+		t.Fatal("expected decode error")
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("requests = %d, want 1", calls.Load())
+	}
+}
+
+func TestEmbedTimesOutSlowRequestAndRetries(t *testing.T) {
+	srv, calls := flakyEmbedServer(t, 1, 0, 300*time.Millisecond)
+	c := NewEmbedClient(srv.URL, "", "m")
+	c.SetLimits(EmbedLimits{Timeout: 50 * time.Millisecond, Retries: 1, Backoff: time.Millisecond})
+
+	start := time.Now()
+	if _, err := c.Embed(context.Background(), []string{"x"}); err != nil {
+		t.Fatalf("embed after timeout: %v", err)
+	}
+	if time.Since(start) > 250*time.Millisecond || calls.Load() != 2 {
+		t.Fatalf("requests = %d after %s, want a timed-out request then a retry", calls.Load(), time.Since(start))
+	}
+
+	c.SetLimits(EmbedLimits{Timeout: 50 * time.Millisecond})
+	srv2, _ := flakyEmbedServer(t, 1, 0, 300*time.Millisecond)
+	c.baseURL = srv2.URL
+	if _, err := c.Embed(context.Background(), []string{"x"}); err == nil || !strings.Contains(err.Error(), "timed out after 50ms") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+}
+
+func TestEmbedBreakerOpensAndProbesAfterCooldown(t *testing.T) {
+	srv, calls := flakyEmbedServer(t, 2, http.StatusServiceUnavailable, 0)
+	c := NewEmbedClient(srv.URL, "", "m")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	c.SetLimits(EmbedLimits{BreakerThreshold: 2, BreakerCooldown: time.Minute})
+
+	for range 2 {
+		if _, err := c.Embed(context.Background(), []string{"x"}); err == nil || errors.Is(err, ErrEmbedCircuitOpen) {
+			t.Fatalf("expected endpoint error, got %v", err)
+		}
+	}
+	_, err := c.Embed(context.Background(), []string{"x"})
+	if !errors.Is(err, ErrEmbedCircuitOpen) || !strings.Contains(err.Error(), "2 failures in a row") {
+		t.Fatalf("expected open circuit, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("open circuit reached the endpoint: %d requests", calls.Load())
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := c.Embed(context.Background(), []string{"x"}); err != nil {
+		t.Fatalf("probe after cooldown: %v", err)
+	}
+	if _, err := c.Embed(context.Background(), []string{"x"}); err != nil {
+		t.Fatalf("closed circuit: %v", err)
+	}
+}
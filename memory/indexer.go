@@ -14,18 +14,36 @@ import (
 const (
 	chunkSize    = 2000
 	chunkOverlap = 100
+	// charsPerToken converts configured token counts into the character
+	// counts the splitter works in.
+	charsPerToken = 4
 )
 
 type Indexer struct {
-	store       *Store
-	embedClient *EmbedClient
+	store        *Store
+	embedClient  *EmbedClient
+	chunkSize    int
+	chunkOverlap int
 }
 
 func NewIndexer(store *Store, embedClient *EmbedClient) *Indexer {
-	return &Indexer{store: store, embedClient: embedClient}
+	return &Indexer{store: store, embedClient: embedClient, chunkSize: chunkSize, chunkOverlap: chunkOverlap}
+}
+
+// SetChunkTokens sets the chunk size and the overlap carried into the next
+// chunk, in approximate tokens. A zero size keeps the default chunking.
+func (i *Indexer) SetChunkTokens(size, overlap int) {
+	if size <= 0 {
+		return
+	}
+	i.chunkSize = size * charsPerToken
+	i.chunkOverlap = overlap * charsPerToken
 }
 
 func (i *Indexer) Sync(ctx context.Context, workspacePath string) error {
+	if err := i.useModel(); err != nil {
+		return err
+	}
 	seen, err := i.syncWorkspace(ctx, workspacePath)
 	if err != nil {
 		return err
@@ -33,8 +51,42 @@ func (i *Indexer) Sync(ctx context.Context, workspacePath string) error {
 	return i.removeMissing(seen)
 }
 
+// embedModelKey is the meta key holding the embedding model the cache was
+// last filled with.
+const embedModelKey = "embed_model"
+
+// useModel drops cached embeddings of other models when the embedding model
+// changed since the last sync, since their vectors are not comparable.
+func (i *Indexer) useModel() error {
+	model := i.embedClient.model
+	prev, err := i.store.GetMeta(embedModelKey)
+	if err != nil || prev == model {
+		return err
+	}
+	if err := i.store.PruneEmbedCache(model); err != nil {
+		return err
+	}
+	return i.store.SetMeta(embedModelKey, model)
+}
+
+// pendingFile is a changed file whose new chunks are waiting for
+// embeddings before they are written to the store.
+type pendingFile struct {
+	file    File
+	chunks  []Chunk
+	missing []int
+	// stored maps the IDs of the chunks already stored for the file to
+	// their hashes.
+	stored map[string]string
+}
+
+// syncWorkspace indexes the changed files under root. Chunks that need
+// embeddings are queued across files and embedded once a full batch is
+// waiting, so a sync of many small files does not pay one request per file.
 func (i *Indexer) syncWorkspace(ctx context.Context, root string) (map[string]bool, error) {
 	seen := map[string]bool{}
+	var queue []*pendingFile
+	queued := 0
 	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -46,66 +98,154 @@ func (i *Indexer) syncWorkspace(ctx context.Context, root string) (map[string]bo
 		if err != nil {
 			return err
 		}
-		rel, err := i.syncFile(ctx, root, path, info)
+		rel, p, err := i.planFile(root, path, info)
 		if err != nil {
 			return err
 		}
 		seen[rel] = true
-		return nil
+		if p == nil {
+			return nil
+		}
+		queue = append(queue, p)
+		queued += len(p.missing)
+		if queued < i.embedClient.batchSize {
+			return nil
+		}
+		err = i.flush(ctx, queue)
+		queue, queued = nil, 0
+		return err
 	})
 	if err != nil {
 		return nil, err
 	}
-	return seen, nil
+	return seen, i.flush(ctx, queue)
 }
 
 func (i *Indexer) syncFile(ctx context.Context, root, absPath string, info fs.FileInfo) (string, error) {
+	rel, p, err := i.planFile(root, absPath, info)
+	if err != nil || p == nil {
+		return rel, err
+	}
+	return rel, i.flush(ctx, []*pendingFile{p})
+}
+
+// planFile reads absPath and, when its content changed since the last sync,
+// returns the chunks to store for it. It returns a nil pendingFile for an
+// unchanged file.
+func (i *Indexer) planFile(root, absPath string, info fs.FileInfo) (string, *pendingFile, error) {
 	rel, err := filepath.Rel(root, absPath)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	rel = filepath.ToSlash(rel)
 	content, err := os.ReadFile(absPath)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	hash := sha256Hex(content)
 	f, err := i.store.GetFile(rel)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	if f != nil && f.Hash == hash {
-		return rel, nil
+		return rel, nil, nil
 	}
-	if err := i.reindexFile(ctx, rel, content); err != nil {
-		return "", err
+	p, err := i.planChunks(rel, content)
+	if err != nil {
+		return "", nil, err
 	}
-	return rel, i.store.PutFile(File{Path: rel, Hash: hash, Mtime: info.ModTime(), Size: info.Size()})
+	p.file = File{Path: rel, Hash: hash, Mtime: info.ModTime(), Size: info.Size()}
+	return rel, p, nil
 }
 
-func (i *Indexer) reindexFile(ctx context.Context, path string, content []byte) error {
-	if err := i.store.DeleteChunksByPath(path); err != nil {
-		return err
+// planChunks splits content into chunks for path. A chunk whose text is
+// already stored for path keeps its embedding, even if it moved; only new or
+// edited chunks are marked missing.
+func (i *Indexer) planChunks(path string, content []byte) (*pendingFile, error) {
+	old, err := i.store.ListChunksByPath(path)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string][]float32, len(old))
+	p := &pendingFile{stored: make(map[string]string, len(old))}
+	for _, c := range old {
+		known[c.Hash] = c.Embedding
+		p.stored[c.ID] = c.Hash
+	}
+	texts := chunkText(string(content), i.chunkSize, i.chunkOverlap)
+	p.chunks = make([]Chunk, len(texts))
+	for n, text := range texts {
+		hash := sha256Hex([]byte(text))
+		p.chunks[n] = Chunk{ID: fmt.Sprintf("%s:%d", path, n), Path: path, StartLine: n, EndLine: n, Hash: hash, Text: text}
+		vec, ok := known[hash]
+		if !ok {
+			p.missing = append(p.missing, n)
+		}
+		p.chunks[n].Embedding = vec
+	}
+	return p, nil
+}
+
+// flush fills the missing embeddings of files, from the embedding cache
+// where it can and otherwise in one Embed call, which splits them into
+// batch-sized requests, and then stores each file.
+func (i *Indexer) flush(ctx context.Context, files []*pendingFile) error {
+	var texts []string
+	var targets []*Chunk
+	for _, p := range files {
+		for _, n := range p.missing {
+			c := &p.chunks[n]
+			vec, err := i.store.GetCachedEmbedding(c.Hash, i.embedClient.model)
+			if err != nil {
+				return err
+			}
+			if vec != nil {
+				c.Embedding = vec
+				continue
+			}
+			texts = append(texts, c.Text)
+			targets = append(targets, c)
+		}
 	}
-	texts := ChunkText(string(content))
-	vecs := make([][]float32, len(texts))
 	if len(texts) > 0 {
-		var err error
-		vecs, err = i.embedClient.Embed(ctx, texts)
+		vecs, err := i.embedClient.Embed(ctx, texts)
 		if err != nil {
 			return err
 		}
-		if len(vecs) != len(texts) {
-			return fmt.Errorf("embedding count mismatch")
+		for n, c := range targets {
+			c.Embedding = vecs[n]
+			if err := i.store.PutCachedEmbedding(c.Hash, i.embedClient.model, c.Embedding); err != nil {
+				return err
+			}
+		}
+	}
+	for _, p := range files {
+		if err := i.storeFile(p); err != nil {
+			return err
 		}
 	}
-	for n := range texts {
-		c := Chunk{ID: fmt.Sprintf("%s:%d", path, n), Path: path, StartLine: n, EndLine: n, Hash: sha256Hex([]byte(texts[n])), Text: texts[n], Embedding: vecs[n]}
+	return nil
+}
+
+// storeFile writes the new or edited chunks of p, deletes the chunks left
+// over past the new end of the file, and records the file.
+func (i *Indexer) storeFile(p *pendingFile) error {
+	for _, c := range p.chunks {
+		hash, ok := p.stored[c.ID]
+		delete(p.stored, c.ID)
+		if ok && hash == c.Hash {
+			continue
+		}
 		if err := i.store.PutChunk(c); err != nil {
 			return err
 		}
 	}
-	return nil
+	for id := range p.stored {
+		if err := i.store.DeleteChunk(id); err != nil {
+			return err
+		}
+	}
+	return i.store.PutFile(p.file)
 }
 
 func (i *Indexer) removeMissing(seen map[string]bool) error {
@@ -127,11 +267,20 @@ func (i *Indexer) removeMissing(seen map[string]bool) error {
 	return nil
 }
 
+// ChunkText splits content with the default chunk size and overlap.
 func ChunkText(content string) []string {
+	return chunkText(content, chunkSize, chunkOverlap)
+}
+
+// chunkText splits content into chunks of at most size characters, packing
+// whole paragraphs, then whole sentences, and hard-splitting only a sentence
+// longer than size. Each chunk after the first starts with the last overlap
+// characters of the chunk before it.
+func chunkText(content string, size, overlap int) []string {
 	if content == "" {
 		return nil
 	}
-	return addChunkOverlap(splitByParagraph(content, chunkSize), chunkOverlap)
+	return addChunkOverlap(splitByParagraph(content, size), overlap)
 }
 
 func splitByParagraph(content string, limit int) []string {
@@ -144,7 +293,7 @@ func splitByParagraph(content string, limit int) []string {
 				cur = p
 				continue
 			}
-			out = append(out, splitFixed(p, limit)...)
+			out = append(out, splitBySentence(p, limit)...)
 			continue
 		}
 		next := cur + "\n\n" + p
@@ -157,7 +306,7 @@ func splitByParagraph(content string, limit int) []string {
 			cur = p
 			continue
 		}
-		out = append(out, splitFixed(p, limit)...)
+		out = append(out, splitBySentence(p, limit)...)
 		cur = ""
 	}
 	if cur != "" {
@@ -166,6 +315,58 @@ func splitByParagraph(content string, limit int) []string {
 	return out
 }
 
+// splitBySentence packs whole sentences of a paragraph longer than limit
+// into pieces of at most limit characters.
+func splitBySentence(p string, limit int) []string {
+	var out []string
+	cur := ""
+	for _, s := range sentences(p) {
+		if len(cur)+len(s) <= limit {
+			cur += s
+			continue
+		}
+		if cur != "" {
+			out = append(out, cur)
+		}
+		cur = s
+		if len(s) > limit {
+			pieces := splitFixed(s, limit)
+			out = append(out, pieces[:len(pieces)-1]...)
+			cur = pieces[len(pieces)-1]
+		}
+	}
+	if cur != "" {
+		out = append(out, cur)
+	}
+	return out
+}
+
+// sentences splits p after each '.', '!' or '?' followed by whitespace,
+// keeping the whitespace with the sentence so the pieces join back into p.
+func sentences(p string) []string {
+	var out []string
+	start := 0
+	for i := 0; i < len(p)-1; i++ {
+		if !strings.ContainsRune(".!?", rune(p[i])) || !isSpace(p[i+1]) {
+			continue
+		}
+		end := i + 1
+		for end < len(p) && isSpace(p[end]) {
+			end++
+		}
+		out = append(out, p[start:end])
+		start, i = end, end-1
+	}
+	if start < len(p) {
+		out = append(out, p[start:])
+	}
+	return out
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\t'
+}
+
 func splitFixed(s string, limit int) []string {
 	if s == "" {
 		return nil
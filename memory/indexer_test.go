@@ -3,11 +3,14 @@ package memory
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 )
@@ -69,6 +72,59 @@ func TestChunkOverlap(t *testing.T) {
 	}
 }
 
+func TestChunkSplitsLongParagraphOnSentences(t *testing.T) {
+	para := "One two three. Four five six! Seven eight nine? Ten eleven twelve."
+	chunks := chunkText(para, 32, 0)
+	want := []string{"One two three. Four five six! ", "Seven eight nine? ", "Ten eleven twelve."}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Fatalf("chunks = %q, want %q", chunks, want)
+	}
+	long := "Short. " + strings.Repeat("y", 50)
+	if got := chunkText(long, 20, 0); len(got) != 4 || got[0] != "Short. " || len(got[1]) != 20 {
+		t.Fatalf("unexpected hard split: %q", got)
+	}
+}
+
+func TestChunkOverlapAppearsInAdjacentChunks(t *testing.T) {
+	p1 := strings.Repeat("alpha ", 30) + "end-of-first."
+	p2 := strings.Repeat("beta ", 30) + "end-of-second."
+	p3 := strings.Repeat("gamma ", 30) + "end-of-third."
+	chunks := chunkText(p1+"\n\n"+p2+"\n\n"+p3, 200, 20)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d: %q", len(chunks), chunks)
+	}
+	for i := 1; i < len(chunks); i++ {
+		tail := chunks[i-1][len(chunks[i-1])-20:]
+		if !strings.HasPrefix(chunks[i], tail) {
+			t.Fatalf("chunk %d does not start with the previous chunk's tail %q: %q", i, tail, chunks[i][:40])
+		}
+	}
+	if !strings.HasPrefix(chunks[1], " alpha end-of-first.") || !strings.HasPrefix(chunks[2], " beta end-of-second.") {
+		t.Fatalf("unexpected overlap: %q / %q", chunks[1][:20], chunks[2][:20])
+	}
+}
+
+func TestSyncUsesConfiguredChunkTokens(t *testing.T) {
+	s := openTestStore(t)
+	srv, _ := newEmbedServer(t)
+	defer srv.Close()
+
+	idx := NewIndexer(s, NewEmbedClient(srv.URL, "", "test-model"))
+	idx.SetChunkTokens(50, 5)
+	dir := t.TempDir()
+	writeFile(t, dir, "a.md", strings.Repeat("a", 150)+"\n\n"+strings.Repeat("b", 150))
+	if err := idx.Sync(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := s.ListChunksByPath("a.md")
+	if err != nil || len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks of 200 chars, got %d (%v)", len(chunks), err)
+	}
+	if chunks[1].Text != strings.Repeat("a", 20)+strings.Repeat("b", 150) {
+		t.Fatalf("unexpected second chunk %q", chunks[1].Text)
+	}
+}
+
 func TestSyncNewFiles(t *testing.T) {
 	s := openTestStore(t)
 	srv, calls := newEmbedServer(t)
@@ -81,8 +137,8 @@ func TestSyncNewFiles(t *testing.T) {
 	if err := idx.Sync(context.Background(), dir); err != nil {
 		t.Fatal(err)
 	}
-	if calls.Load() != 2 {
-		t.Fatalf("expected 2 embed calls, got %d", calls.Load())
+	if calls.Load() != 1 {
+		t.Fatalf("expected both files embedded in 1 call, got %d", calls.Load())
 	}
 	files, err := s.ListFiles()
 	if err != nil {
@@ -158,6 +214,194 @@ func TestSyncChangedReindexed(t *testing.T) {
 	}
 }
 
+func TestSyncReembedsOnlyEditedChunk(t *testing.T) {
+	s := openTestStore(t)
+	var mu sync.Mutex
+	var embedded []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		embedded = append(embedded, req.Input...)
+		mu.Unlock()
+		data := make([]map[string]any, len(req.Input))
+		for i, s := range req.Input {
+			data[i] = map[string]any{"embedding": []float32{float32(len(s)), float32(s[0])}}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer srv.Close()
+
+	idx := NewIndexer(s, NewEmbedClient(srv.URL, "", "test-model"))
+	dir := t.TempDir()
+	a, b, c := strings.Repeat("a", 1500), strings.Repeat("b", 1500), strings.Repeat("c", 1500)
+	writeFile(t, dir, "doc.md", a+"\n\n"+b+"\n\n"+c)
+	if err := idx.Sync(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	before, err := s.ListChunksByPath("doc.md")
+	if err != nil || len(before) != 3 {
+		t.Fatalf("expected 3 chunks, got %d (%v)", len(before), err)
+	}
+
+	edited := b[:700] + " EDITED " + b[708:]
+	embedded = nil
+	writeFile(t, dir, "doc.md", a+"\n\n"+edited+"\n\n"+c)
+	if err := idx.Sync(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	after, err := s.ListChunksByPath("doc.md")
+	if err != nil || len(after) != 3 {
+		t.Fatalf("expected 3 chunks, got %d (%v)", len(after), err)
+	}
+	if len(embedded) != 1 || embedded[0] != after[1].Text || !strings.Contains(after[1].Text, "EDITED") {
+		t.Fatalf("expected only the edited chunk re-embedded, got %d texts", len(embedded))
+	}
+	if !reflect.DeepEqual(after[0].Embedding, before[0].Embedding) || !reflect.DeepEqual(after[2].Embedding, before[2].Embedding) {
+		t.Fatal("unchanged chunks lost their embeddings")
+	}
+
+	embedded = nil
+	writeFile(t, dir, "doc.md", a+"\n\n"+edited)
+	if err := idx.Sync(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(embedded) != 0 {
+		t.Fatalf("dropping a paragraph re-embedded %d chunks", len(embedded))
+	}
+	if gone, err := s.GetChunk("doc.md:2"); err != nil || gone != nil {
+		t.Fatalf("expected removed chunk deleted, got %v (%v)", gone, err)
+	}
+	if hits, err := s.SearchFTS("EDITED", 5); err != nil || len(hits) != 1 || hits[0].ID != "doc.md:1" {
+		t.Fatalf("fts out of sync: %v (%v)", hits, err)
+	}
+}
+
+func TestSyncBatchesEmbeddingsAcrossFiles(t *testing.T) {
+	s := openTestStore(t)
+	srv, calls, sizes := newLengthEmbedServer(t)
+	defer srv.Close()
+
+	client := NewEmbedClient(srv.URL, "", "test-model")
+	client.SetBatchSize(4)
+	idx := NewIndexer(s, client)
+	dir := t.TempDir()
+	const files = 10
+	for n := range files {
+		writeFile(t, dir, fmt.Sprintf("note%02d.md", n), strings.Repeat("x", n+1))
+	}
+	if err := idx.Sync(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if want := (files + 3) / 4; int(calls.Load()) != want {
+		t.Fatalf("expected %d embed calls, got %d", want, calls.Load())
+	}
+	for _, n := range *sizes {
+		if n > 4 {
+			t.Fatalf("request carried %d texts, batch size is 4", n)
+		}
+	}
+	for n := range files {
+		c, err := s.GetChunk(fmt.Sprintf("note%02d.md:0", n))
+		if err != nil || c == nil {
+			t.Fatalf("missing chunk for note%02d.md (%v)", n, err)
+		}
+		if c.Embedding[0] != float32(len(c.Text)) {
+			t.Fatalf("note%02d.md got the vector of another chunk: %v", n, c.Embedding)
+		}
+	}
+}
+
+func TestEmbedClientSplitsOversizedBatch(t *testing.T) {
+	srv, calls, sizes := newLengthEmbedServer(t)
+	defer srv.Close()
+
+	c := NewEmbedClient(srv.URL, "", "m")
+	c.SetBatchSize(3)
+	texts := []string{"a", "bb", "ccc", "dddd", "eeeee", "ffffff", "ggggggg"}
+	vecs, err := c.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 || !reflect.DeepEqual(*sizes, []int{3, 3, 1}) {
+		t.Fatalf("expected requests of 3, 3 and 1 texts, got %v", *sizes)
+	}
+	for k, v := range vecs {
+		if v[0] != float32(len(texts[k])) {
+			t.Fatalf("vector %d out of order: %v", k, v)
+		}
+	}
+}
+
+func TestSyncServesReindexFromEmbedCache(t *testing.T) {
+	s := openTestStore(t)
+	srv, calls := newEmbedServer(t)
+	defer srv.Close()
+
+	idx := NewIndexer(s, NewEmbedClient(srv.URL, "", "test-model"))
+	dir := t.TempDir()
+	writeFile(t, dir, "a.md", "alpha\n\nbeta")
+	writeFile(t, dir, "b.md", "gamma")
+	if err := idx.Sync(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	before := calls.Load()
+	files, err := s.ListFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if err := s.DeleteChunksByPath(f.Path); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteFile(f.Path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Sync(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != before {
+		t.Fatalf("expected cache to serve the re-index, got %d new embed calls", calls.Load()-before)
+	}
+	c, err := s.GetChunk("b.md:0")
+	if err != nil || c == nil || len(c.Embedding) == 0 {
+		t.Fatalf("expected re-indexed chunk with embedding, got %+v (%v)", c, err)
+	}
+}
+
+func TestSyncEmbedCacheInvalidatedOnModelChange(t *testing.T) {
+	s := openTestStore(t)
+	srv, calls := newEmbedServer(t)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "a.md", "alpha")
+	if err := NewIndexer(s, NewEmbedClient(srv.URL, "", "old-model")).Sync(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteChunksByPath("a.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteFile("a.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewIndexer(s, NewEmbedClient(srv.URL, "", "new-model")).Sync(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected new model to re-embed, got %d calls", calls.Load())
+	}
+	old, err := s.GetCachedEmbedding(sha256Hex([]byte("alpha")), "old-model")
+	if err != nil || old != nil {
+		t.Fatalf("expected old model cache pruned, got %v (%v)", old, err)
+	}
+}
+
 func TestSyncDeletedRemoved(t *testing.T) {
 	s := openTestStore(t)
 	srv, _ := newEmbedServer(t)
@@ -267,3 +511,68 @@ func writeFile(t *testing.T, root, rel, content string) {
 		t.Fatal(err)
 	}
 }
+
+func TestRememberWritesAndIndexesNote(t *testing.T) {
+	s := openTestStore(t)
+	srv, calls := newEmbedServer(t)
+	defer srv.Close()
+
+	idx := NewIndexer(s, NewEmbedClient(srv.URL, "", "test-model"))
+	dir := t.TempDir()
+	id, created, err := idx.Remember(context.Background(), dir, "user prefers tea", []string{"prefs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created || !strings.HasPrefix(id, NotesDir+"/") || !strings.HasSuffix(id, ".md:0") {
+		t.Fatalf("unexpected note id %q created=%v", id, created)
+	}
+	chunk, err := s.GetChunk(id)
+	if err != nil || chunk == nil {
+		t.Fatalf("chunk %q not stored: %v", id, err)
+	}
+	if chunk.Text != "tags: prefs\n\nuser prefers tea\n" {
+		t.Fatalf("unexpected chunk text %q", chunk.Text)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(chunk.Path)))
+	if err != nil || string(raw) != chunk.Text {
+		t.Fatalf("note file out of sync: %q %v", raw, err)
+	}
+
+	again, created, err := idx.Remember(context.Background(), dir, "user prefers tea", nil)
+	if err != nil || again != id || created {
+		t.Fatalf("repeat remember = %q created=%v err=%v", again, created, err)
+	}
+	if err := idx.Sync(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected one embed call across remember and sync, got %d", calls.Load())
+	}
+}
+
+// newLengthEmbedServer embeds each text as [len(text)] so tests can check
+// vectors land on the right chunk, and records the size of every request.
+func newLengthEmbedServer(t *testing.T) (*httptest.Server, *atomic.Int32, *[]int) {
+	t.Helper()
+	calls := &atomic.Int32{}
+	var mu sync.Mutex
+	sizes := &[]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		*sizes = append(*sizes, len(req.Input))
+		mu.Unlock()
+		data := make([]map[string]any, len(req.Input))
+		for i, s := range req.Input {
+			data[i] = map[string]any{"embedding": []float32{float32(len(s))}}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	return srv, calls, sizes
+}
@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/agusx1211/miclaw/config"
 	"github.com/agusx1211/miclaw/memory"
 	"github.com/agusx1211/miclaw/model"
 	"github.com/agusx1211/miclaw/tools"
@@ -173,7 +174,7 @@ func listFileHashes(t *testing.T, s *memory.Store) map[string]string {
 
 func runMemorySearchTool(t *testing.T, store *memory.Store, embed *memory.EmbedClient, query string, limit int) string {
 	t.Helper()
-	tool := tools.MemorySearchTool(store, embed)
+	tool := tools.MemorySearchTool(store, embed, config.MemoryConfig{})
 	raw, err := json.Marshal(map[string]any{"query": query, "limit": limit, "min_score": 0.0})
 	if err != nil {
 		t.Fatalf("marshal query: %v", err)
@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"database/sql"
+	"time"
+)
+
+// KBEntry is one curated knowledge base entry: an answer stored under an
+// exact key, kept apart from the indexed workspace files.
+type KBEntry struct {
+	Key       string
+	Value     string
+	UpdatedAt time.Time
+}
+
+// PutKB stores value under key, replacing any earlier value.
+func (s *Store) PutKB(key, value string) error {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	_, err := s.db.Exec(
+		`INSERT INTO kb (key, value, updated_at) VALUES (?, ?, ?)
+		 ON CONFLICT(key) DO UPDATE SET value = ?, updated_at = ?`,
+		key, value, now, value, now,
+	)
+	return err
+}
+
+// GetKB returns the entry stored under exactly key, or nil when there is
+// none.
+func (s *Store) GetKB(key string) (*KBEntry, error) {
+	var e KBEntry
+	var updated string
+	err := s.db.QueryRow(`SELECT key, value, updated_at FROM kb WHERE key = ?`, key).Scan(&e.Key, &e.Value, &updated)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if e.UpdatedAt, err = time.Parse(time.RFC3339Nano, updated); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// SearchKB runs an FTS5 query over entry keys and values, best match first.
+func (s *Store) SearchKB(query string, limit int) ([]KBEntry, error) {
+	rows, err := s.db.Query(
+		`SELECT k.key, k.value, k.updated_at
+		 FROM kb_fts f JOIN kb k ON f.rowid = k.rowid
+		 WHERE kb_fts MATCH ? ORDER BY rank LIMIT ?`,
+		query, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []KBEntry
+	for rows.Next() {
+		var e KBEntry
+		var updated string
+		if err := rows.Scan(&e.Key, &e.Value, &updated); err != nil {
+			return nil, err
+		}
+		if e.UpdatedAt, err = time.Parse(time.RFC3339Nano, updated); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,50 @@
+package memory
+
+import "testing"
+
+func TestKBGetByExactKey(t *testing.T) {
+	s := openTestStore(t)
+	if err := s.PutKB("wifi password", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutKB("wifi password", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	e, err := s.GetKB("wifi password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e == nil || e.Value != "correct horse" || e.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	if e, err := s.GetKB("wifi"); err != nil || e != nil {
+		t.Fatalf("partial key matched: %+v, %v", e, err)
+	}
+}
+
+func TestKBSearchMatchesKeysAndValues(t *testing.T) {
+	s := openTestStore(t)
+	s.PutKB("office address", "Calle Falsa 123, Springfield")
+	s.PutKB("dentist", "Dr. Molar, Tuesdays at 10")
+	s.PutKB("wifi password", "hunter2")
+
+	got, err := s.SearchKB(`"springfield"`, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Key != "office address" {
+		t.Fatalf("value search: %+v", got)
+	}
+	got, err = s.SearchKB(`"dentist" OR "wifi"`, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("key search: %+v", got)
+	}
+
+	s.PutKB("office address", "moved to Shelbyville")
+	if got, _ := s.SearchKB(`"springfield"`, 10); len(got) != 0 {
+		t.Fatalf("search found the replaced value: %+v", got)
+	}
+}
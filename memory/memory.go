@@ -76,10 +76,25 @@ var memorySchemaSQL = []string{
 		updated_at DATETIME
 	)`,
 	`CREATE INDEX IF NOT EXISTS idx_chunks_path ON chunks(path)`,
+	`CREATE TABLE IF NOT EXISTS embed_cache (
+		hash TEXT,
+		model TEXT,
+		vector BLOB,
+		PRIMARY KEY (hash, model)
+	)`,
 	`CREATE VIRTUAL TABLE IF NOT EXISTS fts USING fts5(id UNINDEXED, text, content=chunks, content_rowid=rowid)`,
 	ftsTriggerInsert,
 	ftsTriggerDelete,
 	ftsTriggerUpdate,
+	`CREATE TABLE IF NOT EXISTS kb (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at DATETIME
+	)`,
+	`CREATE VIRTUAL TABLE IF NOT EXISTS kb_fts USING fts5(key, value, content=kb, content_rowid=rowid)`,
+	kbTriggerInsert,
+	kbTriggerDelete,
+	kbTriggerUpdate,
 }
 
 const ftsTriggerInsert = `CREATE TRIGGER IF NOT EXISTS chunks_ai AFTER INSERT ON chunks BEGIN
@@ -94,3 +109,16 @@ const ftsTriggerUpdate = `CREATE TRIGGER IF NOT EXISTS chunks_au AFTER UPDATE ON
 	INSERT INTO fts(fts, rowid, id, text) VALUES ('delete', old.rowid, old.id, old.text);
 	INSERT INTO fts(rowid, id, text) VALUES (new.rowid, new.id, new.text);
 END`
+
+const kbTriggerInsert = `CREATE TRIGGER IF NOT EXISTS kb_ai AFTER INSERT ON kb BEGIN
+	INSERT INTO kb_fts(rowid, key, value) VALUES (new.rowid, new.key, new.value);
+END`
+
+const kbTriggerDelete = `CREATE TRIGGER IF NOT EXISTS kb_ad AFTER DELETE ON kb BEGIN
+	INSERT INTO kb_fts(kb_fts, rowid, key, value) VALUES ('delete', old.rowid, old.key, old.value);
+END`
+
+const kbTriggerUpdate = `CREATE TRIGGER IF NOT EXISTS kb_au AFTER UPDATE ON kb BEGIN
+	INSERT INTO kb_fts(kb_fts, rowid, key, value) VALUES ('delete', old.rowid, old.key, old.value);
+	INSERT INTO kb_fts(rowid, key, value) VALUES (new.rowid, new.key, new.value);
+END`
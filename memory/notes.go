@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NotesDir holds notes written by Remember, relative to the workspace.
+const NotesDir = "memory/notes"
+
+// Remember saves text as a markdown note under NotesDir in root and indexes
+// it at once, so it is searchable before the next Sync and Sync finds it
+// unchanged. Notes are named by the hash of their text: remembering the same
+// text again writes and embeds nothing. It returns the ID of the note's first
+// chunk and whether the note is new.
+func (i *Indexer) Remember(ctx context.Context, root, text string, tags []string) (string, bool, error) {
+	rel := NotesDir + "/" + sha256Hex([]byte(text))[:16] + ".md"
+	abs := filepath.Join(root, filepath.FromSlash(rel))
+	created := false
+	if _, err := os.Stat(abs); errors.Is(err, fs.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			return "", false, err
+		}
+		if err := os.WriteFile(abs, []byte(renderNote(text, tags)), 0o644); err != nil {
+			return "", false, err
+		}
+		created = true
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := i.syncFile(ctx, root, abs, info); err != nil {
+		return "", false, err
+	}
+	return rel + ":0", created, nil
+}
+
+func renderNote(text string, tags []string) string {
+	if len(tags) == 0 {
+		return text + "\n"
+	}
+	return "tags: " + strings.Join(tags, ", ") + "\n\n" + text + "\n"
+}
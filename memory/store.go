@@ -111,6 +111,11 @@ func (s *Store) GetChunk(id string) (*Chunk, error) {
 	return &c, nil
 }
 
+func (s *Store) DeleteChunk(id string) error {
+	_, err := s.db.Exec(`DELETE FROM chunks WHERE id = ?`, id)
+	return err
+}
+
 func (s *Store) DeleteChunksByPath(path string) error {
 	_, err := s.db.Exec(`DELETE FROM chunks WHERE path = ?`, path)
 	return err
@@ -202,6 +207,37 @@ func (s *Store) SearchVector(queryVec []float32, limit int) ([]SearchResult, err
 	return out, nil
 }
 
+// GetCachedEmbedding returns the embedding model produced for the text with
+// the given sha256 hash, or nil when none is cached.
+func (s *Store) GetCachedEmbedding(hash, model string) ([]float32, error) {
+	var vec []byte
+	err := s.db.QueryRow(
+		`SELECT vector FROM embed_cache WHERE hash = ? AND model = ?`, hash, model,
+	).Scan(&vec)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeEmbedding(vec), nil
+}
+
+func (s *Store) PutCachedEmbedding(hash, model string, vec []float32) error {
+	_, err := s.db.Exec(
+		`INSERT INTO embed_cache (hash, model, vector) VALUES (?, ?, ?)
+		 ON CONFLICT(hash, model) DO UPDATE SET vector = ?`,
+		hash, model, encodeEmbedding(vec), encodeEmbedding(vec),
+	)
+	return err
+}
+
+// PruneEmbedCache drops the cached embeddings of every model but model.
+func (s *Store) PruneEmbedCache(model string) error {
+	_, err := s.db.Exec(`DELETE FROM embed_cache WHERE model != ?`, model)
+	return err
+}
+
 func cosineSimilarity(a, b []float32) float64 {
 	if len(a) == 0 || len(b) == 0 {
 		return 0
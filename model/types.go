@@ -20,6 +20,9 @@ type Message struct {
 	Role      Role          `json:"role"`
 	Parts     []MessagePart `json:"parts"`
 	CreatedAt time.Time     `json:"created_at"`
+	// Metadata records where a user message came from, such as the Signal
+	// sender's name and group.
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 type MessagePart interface {
@@ -42,6 +45,9 @@ type ToolCallPart struct {
 	ID         string          `json:"id"`
 	Name       string          `json:"name"`
 	Parameters json.RawMessage `json:"parameters"`
+	// Streamed is the start of a message call's content that was delivered
+	// while the call streamed, for the tool to skip. It is not stored.
+	Streamed string `json:"-"`
 }
 
 func (ToolCallPart) partTag() string { return "tool_call" }
@@ -67,6 +73,18 @@ type BinaryPart struct {
 
 func (BinaryPart) partTag() string { return "binary" }
 
+// ImageURLPart points at an image, or a document when MimeType says so, by
+// URL. Providers that accept URLs pass it through; the others send it as a
+// BinaryPart, using Data when the content was downloaded as the message was
+// stored and downloading it otherwise.
+type ImageURLPart struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	Data     []byte `json:"data,omitempty"`
+}
+
+func (ImageURLPart) partTag() string { return "image_url" }
+
 type ToolCall = ToolCallPart
 type ToolResult = ToolResultPart
 
@@ -75,6 +93,7 @@ type messageJSON struct {
 	Role      Role              `json:"role"`
 	Parts     []json.RawMessage `json:"parts"`
 	CreatedAt time.Time         `json:"created_at"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
 }
 
 func (m Message) MarshalJSON() ([]byte, error) {
@@ -83,6 +102,7 @@ func (m Message) MarshalJSON() ([]byte, error) {
 		Role:      m.Role,
 		Parts:     make([]json.RawMessage, 0, len(m.Parts)),
 		CreatedAt: m.CreatedAt,
+		Metadata:  m.Metadata,
 	}
 	for _, p := range m.Parts {
 		raw, err := marshalPart(p)
@@ -112,6 +132,7 @@ func (m *Message) UnmarshalJSON(b []byte) error {
 		Role:      w.Role,
 		Parts:     parts,
 		CreatedAt: w.CreatedAt,
+		Metadata:  w.Metadata,
 	}
 	return nil
 }
@@ -149,6 +170,11 @@ func marshalPart(p MessagePart) (json.RawMessage, error) {
 			Type string `json:"type"`
 			BinaryPart
 		}{typ, v})
+	case ImageURLPart:
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			ImageURLPart
+		}{typ, v})
 	}
 	panic(fmt.Sprintf("unknown message part type: %T", p))
 }
@@ -202,6 +228,12 @@ func unmarshalPart(raw json.RawMessage) (MessagePart, error) {
 			return nil, err
 		}
 		return p, nil
+	case "image_url":
+		var p ImageURLPart
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return nil, err
+		}
+		return p, nil
 	default:
 		return nil, fmt.Errorf("unknown message part type: %s", pt.Type)
 	}
@@ -0,0 +1,36 @@
+package miclaw
+
+import "sync"
+
+const overloadedReply = "I'm overloaded right now, please try again later."
+
+const shuttingDownReply = "I'm shutting down and could not take that message. Please send it again once I'm back."
+
+// overloadNotices remembers which sources were already told the agent is
+// overloaded, so each gets one reply until its input is accepted again.
+type overloadNotices struct {
+	mu   sync.Mutex
+	sent map[string]bool
+}
+
+// first reports whether source has not been notified yet and marks it.
+func (n *overloadNotices) first(source string) bool {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.sent[source] {
+		return false
+	}
+	if n.sent == nil {
+		n.sent = map[string]bool{}
+	}
+	n.sent[source] = true
+	return true
+}
+
+func (n *overloadNotices) clear(source string) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.sent, source)
+}
@@ -0,0 +1,175 @@
+package miclaw
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/agent"
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+	signalpipe "github.com/agusx1211/miclaw/signal"
+)
+
+// gatedProvider blocks every stream until release is closed, keeping the
+// agent busy so inputs pile up in the queue.
+type gatedProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newGatedProvider() gatedProvider {
+	return gatedProvider{started: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (p gatedProvider) Stream(ctx context.Context, _ []model.Message, _ []provider.ToolDef, _ provider.ToolChoice) <-chan provider.ProviderEvent {
+	ch := make(chan provider.ProviderEvent, 3)
+	go func() {
+		defer close(ch)
+		select {
+		case p.started <- struct{}{}:
+		default:
+		}
+		select {
+		case <-p.release:
+		case <-ctx.Done():
+			return
+		}
+		ch <- provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "sleep-1", ToolName: "sleep"}
+		ch <- provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: "sleep-1"}
+		ch <- provider.ProviderEvent{Type: provider.EventComplete}
+	}()
+	return ch
+}
+
+func (gatedProvider) Model() provider.ModelInfo {
+	return provider.ModelInfo{}
+}
+
+func newBusyRuntime(t *testing.T, cfg *config.Config, opts Options) (*Runtime, gatedProvider) {
+	t.Helper()
+	prov := newGatedProvider()
+	opts.Provider = prov
+	rt := newTestRuntime(t, cfg, opts)
+	if err := rt.Inject(agent.Input{Source: "test", Content: "busy"}); err != nil {
+		t.Fatalf("inject: %v", err)
+	}
+	select {
+	case <-prov.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("agent did not start")
+	}
+	return rt, prov
+}
+
+func waitIdle(t *testing.T, rt *Runtime) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for rt.Agent().IsActive() {
+		if time.Now().After(deadline) {
+			t.Fatal("agent did not go idle")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQueueFullRejectsWebhookWith429(t *testing.T) {
+	listen := reserveListenAddr(t)
+	cfg := testConfig(t)
+	cfg.Agent.Queue = config.QueueConfig{MaxDepth: 3, MaxPerSource: 2}
+	cfg.Webhook = config.WebhookConfig{Listen: listen, Hooks: []config.WebhookDef{
+		{ID: "a", Path: "/a", Format: "text"},
+		{ID: "b", Path: "/b", Format: "text"},
+	}}
+	rt, prov := newBusyRuntime(t, cfg, Options{Webhook: true})
+	waitHTTP(t, "http://"+listen+"/health")
+	post := func(path string) int {
+		res, err := http.Post("http://"+listen+path, "text/plain", strings.NewReader("spam"))
+		if err != nil {
+			t.Fatalf("post %s: %v", path, err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	for i, want := range []int{http.StatusAccepted, http.StatusAccepted, http.StatusTooManyRequests} {
+		if got := post("/a"); got != want {
+			t.Fatalf("post %d to /a: status=%d want %d", i, got, want)
+		}
+	}
+	if got := post("/b"); got != http.StatusAccepted {
+		t.Fatalf("per-source limit leaked to /b: status=%d", got)
+	}
+	if got := post("/b"); got != http.StatusTooManyRequests {
+		t.Fatalf("global limit not enforced: status=%d", got)
+	}
+
+	close(prov.release)
+	waitIdle(t, rt)
+	if got := post("/a"); got != http.StatusAccepted {
+		t.Fatalf("queue did not resume after drain: status=%d", got)
+	}
+}
+
+func TestQueueFullRepliesToSignalOnce(t *testing.T) {
+	var sends atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(b), `"method":"send"`) {
+			sends.Add(1)
+		}
+	}))
+	defer srv.Close()
+	cfg := testConfig(t)
+	cfg.Agent.Queue = config.QueueConfig{MaxDepth: 10, MaxPerSource: 1}
+	rt, prov := newBusyRuntime(t, cfg, Options{})
+	rt.signal = signalpipe.NewClient(srv.URL, "+10000000000")
+	ctx := context.Background()
+
+	rt.handleSignalInput(ctx, "signal:dm:u1", "one", nil)
+	rt.handleSignalInput(ctx, "signal:dm:u1", "two", nil)
+	rt.handleSignalInput(ctx, "signal:dm:u1", "three", nil)
+	if got := sends.Load(); got != 1 {
+		t.Fatalf("want one overload reply, got %d", got)
+	}
+	rt.handleSignalInput(ctx, "signal:dm:u2", "hi", nil)
+	if got := sends.Load(); got != 1 {
+		t.Fatalf("other sender was rejected: sends=%d", got)
+	}
+
+	close(prov.release)
+	waitIdle(t, rt)
+	if err := rt.Inject(agent.Input{Source: "signal:dm:u1", Content: "again"}); err != nil {
+		t.Fatalf("queue did not resume after drain: %v", err)
+	}
+}
+
+func TestQueueFullDropsCronSilently(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Agent.Queue = config.QueueConfig{MaxDepth: 1, MaxPerSource: 1}
+	rt, prov := newBusyRuntime(t, cfg, Options{})
+
+	rt.handleCronInput("cron", "daily report")
+	rt.handleCronInput("cron", "daily report")
+	rt.handleCronInput("cron", "weekly report")
+	if got := rt.CronDropped(); got != 2 {
+		t.Fatalf("want 2 dropped cron inputs, got %d", got)
+	}
+	if err := rt.Inject(agent.Input{Source: "test", Content: "x"}); !errors.Is(err, agent.ErrQueueFull) {
+		t.Fatalf("want ErrQueueFull, got %v", err)
+	}
+
+	close(prov.release)
+	waitIdle(t, rt)
+	rt.handleCronInput("cron", "daily report")
+	if got := rt.CronDropped(); got != 2 {
+		t.Fatalf("cron dropped after drain: %d", got)
+	}
+}
@@ -9,7 +9,8 @@ type SystemPromptParams struct {
 	Mode         string // "full" or "minimal"
 	Workspace    *Workspace
 	Skills       []SkillSummary
-	MemoryRecall string // pre-formatted memory context
+	Glossary     []GlossaryEntry // entries matched against the latest input
+	MemoryRecall string          // pre-formatted memory context
 	DateTime     time.Time
 	Heartbeat    string // HEARTBEAT.md content
 	RuntimeInfo  string // version, uptime, etc.
@@ -43,6 +44,7 @@ func buildFullSections(params SystemPromptParams) []promptSection {
 		{name: "Tool Call Style", content: toolCallStyleSection()},
 		{name: "Safety", content: safetySection()},
 		{name: "Skills", content: skillsSection(params.Skills)},
+		{name: "Glossary", content: glossarySection(params.Glossary)},
 		{name: "Memory Recall", content: strings.TrimSpace(params.MemoryRecall)},
 		{name: "Workspace", content: strings.TrimSpace(params.Workspace.User)},
 		{name: "Date/Time", content: dateTimeSection(params.DateTime)},
@@ -102,6 +104,7 @@ func messagingSection() string {
 - Sending a message with the message tool does not end your turn; keep going until all work is done.
 - When all work is complete, call the sleep tool to let the runtime sleep until new input arrives.
 - The user will only receive messages sent over the message tool.
+- To answer a webhook caller that waits for a reply, send to its source tag (for example: webhook:deploy).
 - Source tags are included inline (for example: [signal:dm:user-1], [webhook:deploy], [cron:heartbeat]).`)
 
 	return out
@@ -139,6 +142,22 @@ func skillsSection(skills []SkillSummary) string {
 	return out
 }
 
+func glossarySection(entries []GlossaryEntry) string {
+
+	if len(entries) == 0 {
+		return ""
+	}
+
+	lines := make([]string, 0, len(entries)+1)
+	lines = append(lines, "Use these renderings for terms in the latest input:")
+	for _, e := range entries {
+		lines = append(lines, "- "+e.Term+" → "+e.Rendering)
+	}
+	out := strings.Join(lines, "\n")
+
+	return out
+}
+
 func dateTimeSection(dt time.Time) string {
 
 	if dt.IsZero() {
@@ -0,0 +1,128 @@
+package prompt
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// GlossaryFile holds one "- term → rendering" line per entry.
+	GlossaryFile       = "glossary.md"
+	maxGlossaryMatches = 20
+)
+
+type GlossaryEntry struct {
+	Term      string
+	Rendering string
+}
+
+// LoadGlossary reads glossary.md from the workspace. Lines that are not
+// "- term → rendering" (or "- term: rendering") list items are ignored, so
+// the file can carry headings and prose.
+func LoadGlossary(workspacePath string) ([]GlossaryEntry, error) {
+	if workspacePath == "" {
+		panic("workspace path is required")
+	}
+
+	f, err := os.Open(filepath.Join(workspacePath, GlossaryFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", GlossaryFile, err)
+	}
+	defer f.Close()
+
+	var entries []GlossaryEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if e, ok := parseGlossaryLine(sc.Text()); ok {
+			entries = append(entries, e)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", GlossaryFile, err)
+	}
+	return entries, nil
+}
+
+func parseGlossaryLine(line string) (GlossaryEntry, bool) {
+	line = strings.TrimSpace(line)
+	item, ok := strings.CutPrefix(line, "- ")
+	if !ok {
+		item, ok = strings.CutPrefix(line, "* ")
+	}
+	if !ok {
+		return GlossaryEntry{}, false
+	}
+	term, rendering, ok := strings.Cut(item, "→")
+	if !ok {
+		term, rendering, ok = strings.Cut(item, ":")
+	}
+	term = strings.TrimSpace(term)
+	rendering = strings.TrimSpace(rendering)
+	if !ok || term == "" || rendering == "" {
+		return GlossaryEntry{}, false
+	}
+	return GlossaryEntry{Term: term, Rendering: rendering}, true
+}
+
+// AppendGlossary adds one entry line to glossary.md, creating it if needed.
+func AppendGlossary(workspacePath string, e GlossaryEntry) error {
+	f, err := os.OpenFile(filepath.Join(workspacePath, GlossaryFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "- %s → %s\n", e.Term, e.Rendering); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// MatchGlossary returns the entries whose term appears in text as a whole
+// word, case-insensitively, in glossary order and capped at
+// maxGlossaryMatches.
+func MatchGlossary(entries []GlossaryEntry, text string) []GlossaryEntry {
+	if len(entries) == 0 || text == "" {
+		return nil
+	}
+	lower := strings.ToLower(text)
+	var out []GlossaryEntry
+	for _, e := range entries {
+		if !containsWord(lower, strings.ToLower(e.Term)) {
+			continue
+		}
+		out = append(out, e)
+		if len(out) == maxGlossaryMatches {
+			break
+		}
+	}
+	return out
+}
+
+func containsWord(text, word string) bool {
+	for start := 0; ; {
+		i := strings.Index(text[start:], word)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(word)
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
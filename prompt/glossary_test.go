@@ -0,0 +1,101 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadGlossaryParsesListItems(t *testing.T) {
+	t.Parallel()
+	d := t.TempDir()
+	body := "# Glossary\n\nSome prose.\n- expediente → case file (keep Spanish)\n* ANSES: Argentine social security agency\n- broken line\n-no space: x\n"
+	if err := os.WriteFile(filepath.Join(d, GlossaryFile), []byte(body), 0o600); err != nil {
+		t.Fatalf("write glossary: %v", err)
+	}
+
+	got, err := LoadGlossary(d)
+	if err != nil {
+		t.Fatalf("LoadGlossary failed: %v", err)
+	}
+	want := []GlossaryEntry{
+		{Term: "expediente", Rendering: "case file (keep Spanish)"},
+		{Term: "ANSES", Rendering: "Argentine social security agency"},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("unexpected entries: %+v", got)
+	}
+}
+
+func TestLoadGlossaryMissingFile(t *testing.T) {
+	t.Parallel()
+	got, err := LoadGlossary(t.TempDir())
+	if err != nil || len(got) != 0 {
+		t.Fatalf("want empty glossary, got %+v err=%v", got, err)
+	}
+}
+
+func TestMatchGlossarySelectsMentionedTerms(t *testing.T) {
+	t.Parallel()
+	entries := []GlossaryEntry{
+		{Term: "expediente", Rendering: "case file"},
+		{Term: "ANSES", Rendering: "social security agency"},
+		{Term: "CUIL", Rendering: "labor ID"},
+	}
+
+	got := MatchGlossary(entries, "Revisá el Expediente que mandó anses.")
+	if len(got) != 2 || got[0].Term != "expediente" || got[1].Term != "ANSES" {
+		t.Fatalf("unexpected matches: %+v", got)
+	}
+	if got := MatchGlossary(entries, "nothing relevant here"); len(got) != 0 {
+		t.Fatalf("want no matches, got %+v", got)
+	}
+	if got := MatchGlossary(entries, "expedientes y CUILes"); len(got) != 0 {
+		t.Fatalf("word boundary not enforced: %+v", got)
+	}
+}
+
+func TestMatchGlossaryCapsMatches(t *testing.T) {
+	t.Parallel()
+	entries := make([]GlossaryEntry, 0, maxGlossaryMatches+5)
+	words := make([]string, 0, maxGlossaryMatches+5)
+	for i := range maxGlossaryMatches + 5 {
+		term := fmt.Sprintf("term%d", i)
+		entries = append(entries, GlossaryEntry{Term: term, Rendering: "r"})
+		words = append(words, term)
+	}
+	if got := MatchGlossary(entries, strings.Join(words, " ")); len(got) != maxGlossaryMatches {
+		t.Fatalf("want %d matches, got %d", maxGlossaryMatches, len(got))
+	}
+}
+
+func TestAppendGlossaryRoundTrips(t *testing.T) {
+	t.Parallel()
+	d := t.TempDir()
+	for _, e := range []GlossaryEntry{{"expediente", "case file"}, {"ANSES", "agency"}} {
+		if err := AppendGlossary(d, e); err != nil {
+			t.Fatalf("AppendGlossary failed: %v", err)
+		}
+	}
+	got, err := LoadGlossary(d)
+	if err != nil {
+		t.Fatalf("LoadGlossary failed: %v", err)
+	}
+	if len(got) != 2 || got[1] != (GlossaryEntry{"ANSES", "agency"}) {
+		t.Fatalf("unexpected entries: %+v", got)
+	}
+}
+
+func TestBuildSystemPromptGlossarySection(t *testing.T) {
+	t.Parallel()
+	params := SystemPromptParams{Mode: "full", Workspace: &Workspace{}}
+	if strings.Contains(BuildSystemPrompt(params), "## Glossary") {
+		t.Fatal("glossary section rendered without entries")
+	}
+	params.Glossary = []GlossaryEntry{{Term: "expediente", Rendering: "case file"}}
+	if got := BuildSystemPrompt(params); !strings.Contains(got, "## Glossary") || !strings.Contains(got, "- expediente → case file") {
+		t.Fatalf("missing glossary section:\n%s", got)
+	}
+}
@@ -0,0 +1,442 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+)
+
+const (
+	anthropicDefaultBaseURL = "https://api.anthropic.com/v1"
+	anthropicVersion        = "2023-06-01"
+)
+
+// Anthropic speaks the Messages API directly. The system prompt goes in the
+// system field, tool calls and results become tool_use and tool_result
+// blocks, and reasoning is never sent back, since the API only accepts
+// thinking blocks it signed itself.
+type Anthropic struct {
+	baseURL   string
+	apiKey    string
+	model     string
+	maxTokens int
+	window    int
+	prices    modelPrices
+	mediaURLs bool
+	sampling  sampling
+	retry     retryPolicy
+	idle      time.Duration
+	client    *http.Client
+}
+
+type anthropicRequest struct {
+	Model       string               `json:"model"`
+	MaxTokens   int                  `json:"max_tokens"`
+	System      string               `json:"system,omitempty"`
+	Messages    []anthropicMessage   `json:"messages"`
+	Tools       []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+	Stream      bool                 `json:"stream"`
+	Temperature *float64             `json:"temperature,omitempty"`
+	TopP        *float64             `json:"top_p,omitempty"`
+	// Anthropic names the stop list stop_sequences.
+	StopSequences []string `json:"stop_sequences,omitempty"`
+}
+
+// anthropicToolChoice is Anthropic's tool_choice, where required is "any"
+// and a forced tool is "tool" with its name.
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type      string           `json:"type"`
+	Text      string           `json:"text,omitempty"`
+	ID        string           `json:"id,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	Input     json.RawMessage  `json:"input,omitempty"`
+	ToolUseID string           `json:"tool_use_id,omitempty"`
+	Content   string           `json:"content,omitempty"`
+	IsError   bool             `json:"is_error,omitempty"`
+	Source    *anthropicSource `json:"source,omitempty"`
+}
+
+type anthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicEvent struct {
+	Type         string                 `json:"type"`
+	Index        int                    `json:"index"`
+	Message      *anthropicEventMessage `json:"message"`
+	ContentBlock *anthropicBlock        `json:"content_block"`
+	Delta        *anthropicDelta        `json:"delta"`
+	Usage        *anthropicUsage        `json:"usage"`
+	Error        *anthropicError        `json:"error"`
+}
+
+type anthropicEventMessage struct {
+	Usage *anthropicUsage `json:"usage"`
+}
+
+type anthropicDelta struct {
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	Thinking    string `json:"thinking"`
+	PartialJSON string `json:"partial_json"`
+	StopReason  string `json:"stop_reason"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+type anthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+func NewAnthropic(cfg config.ProviderConfig) *Anthropic {
+
+	base := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if base == "" {
+		base = anthropicDefaultBaseURL
+	}
+	maxTokens := cfg.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	return &Anthropic{
+		baseURL:   base,
+		apiKey:    cfg.APIKey,
+		model:     cfg.Model,
+		maxTokens: maxTokens,
+		window:    cfg.ContextWindow,
+		prices:    configPrices(cfg),
+		mediaURLs: passMediaURLs(cfg.MediaURLs, true),
+		sampling:  samplingFromConfig(cfg),
+		retry:     newRetryPolicy(cfg),
+		idle:      streamIdleTimeout(cfg),
+		client:    &http.Client{},
+	}
+}
+
+func (a *Anthropic) Model() ModelInfo {
+
+	return a.prices.modelInfo(ModelInfo{
+		ID:            a.model,
+		Name:          a.model,
+		ContextWindow: a.window,
+		MaxOutput:     a.maxTokens,
+	})
+}
+
+func (a *Anthropic) Stream(ctx context.Context, messages []model.Message, tools []ToolDef, choice ToolChoice) <-chan ProviderEvent {
+
+	out := make(chan ProviderEvent, 16)
+	go a.stream(ctx, messages, tools, choice, out)
+	return out
+}
+
+func (a *Anthropic) stream(ctx context.Context, messages []model.Message, tools []ToolDef, choice ToolChoice, out chan<- ProviderEvent) {
+
+	defer close(out)
+	messages, err := resolveMediaURLs(ctx, a.client, messages, a.mediaURLs)
+	if err != nil {
+		out <- errorEvent(err)
+		return
+	}
+	choice = effectiveToolChoice("anthropic", choice, tools)
+	payload, err := marshalAnthropicRequest(a.model, requestMaxTokens(ctx, a.maxTokens), a.sampling, outgoingHistory(messages, false), tools, choice)
+	if err != nil {
+		out <- errorEvent(err)
+		return
+	}
+	resp, err := withRetry(ctx, a.retry, func() (*http.Response, error) {
+		return a.doPost(ctx, payload)
+	})
+	if err != nil {
+		out <- errorEvent(err)
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		out <- errorEvent(readStatusError("anthropic", resp))
+		return
+	}
+	parseAnthropicSSE(watchIdle(resp.Body, a.idle), out)
+}
+
+func (a *Anthropic) doPost(ctx context.Context, payload []byte) (*http.Response, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/messages", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	return a.client.Do(req)
+}
+
+func marshalAnthropicRequest(modelID string, maxTokens int, sp sampling, messages []model.Message, tools []ToolDef, choice ToolChoice) ([]byte, error) {
+
+	system := ""
+	if len(messages) > 0 && strings.HasPrefix(messages[0].ID, "system-") {
+		system = strings.TrimSpace(messageTextForResponses(messages[0]))
+		messages = messages[1:]
+	}
+	body := anthropicRequest{
+		Model:         modelID,
+		MaxTokens:     maxTokens,
+		System:        system,
+		Messages:      encodeAnthropicMessages(messages),
+		Tools:         encodeAnthropicTools(tools),
+		ToolChoice:    encodeAnthropicToolChoice(choice),
+		Stream:        true,
+		Temperature:   sp.Temperature,
+		TopP:          sp.TopP,
+		StopSequences: sp.Stop,
+	}
+	return json.Marshal(body)
+}
+
+func encodeAnthropicToolChoice(choice ToolChoice) *anthropicToolChoice {
+
+	switch choice {
+	case ToolChoiceAuto:
+		return nil
+	case ToolChoiceNone:
+		return &anthropicToolChoice{Type: "none"}
+	case ToolChoiceRequired:
+		return &anthropicToolChoice{Type: "any"}
+	}
+	return &anthropicToolChoice{Type: "tool", Name: choice.Tool()}
+}
+
+// encodeAnthropicMessages maps the thread to user and assistant turns. Tool
+// results travel in user turns, and consecutive turns of one role are merged
+// because the API requires them to alternate.
+func encodeAnthropicMessages(messages []model.Message) []anthropicMessage {
+
+	out := make([]anthropicMessage, 0, len(messages))
+	for _, m := range messages {
+		role := "user"
+		if m.Role == model.RoleAssistant {
+			role = "assistant"
+		}
+		blocks := make([]anthropicBlock, 0, len(m.Parts))
+		for _, p := range m.Parts {
+			if b, ok := encodeAnthropicPart(p); ok {
+				blocks = append(blocks, b)
+			}
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Content = append(out[n-1].Content, blocks...)
+			continue
+		}
+		out = append(out, anthropicMessage{Role: role, Content: blocks})
+	}
+	return out
+}
+
+func encodeAnthropicPart(part model.MessagePart) (anthropicBlock, bool) {
+
+	switch p := part.(type) {
+	case model.TextPart:
+		return anthropicBlock{Type: "text", Text: p.Text}, strings.TrimSpace(p.Text) != ""
+	case model.ToolCallPart:
+		input := p.Parameters
+		if len(bytes.TrimSpace(input)) == 0 {
+			input = json.RawMessage("{}")
+		}
+		return anthropicBlock{Type: "tool_use", ID: p.ID, Name: p.Name, Input: input}, true
+	case model.ToolResultPart:
+		return anthropicBlock{Type: "tool_result", ToolUseID: p.ToolCallID, Content: p.Content, IsError: p.IsError}, true
+	case model.BinaryPart, model.ImageURLPart:
+		if !sendableMedia(p) {
+			return anthropicBlock{Type: "text", Text: omittedMedia(mediaType(p), "not supported by this backend")}, true
+		}
+		return encodeAnthropicMedia(p), true
+	case model.ReasoningPart, model.FinishPart:
+		return anthropicBlock{}, false
+	default:
+		panic(fmt.Sprintf("unknown message part type: %T", part))
+	}
+}
+
+// encodeAnthropicMedia turns a binary or URL part into an image block, or a
+// document block for a PDF.
+func encodeAnthropicMedia(part model.MessagePart) anthropicBlock {
+
+	kind := "document"
+	var src anthropicSource
+	switch p := part.(type) {
+	case model.BinaryPart:
+		src = anthropicSource{Type: "base64", MediaType: p.MimeType, Data: base64.StdEncoding.EncodeToString(p.Data)}
+		if isImageType(p.MimeType) {
+			kind = "image"
+		}
+	case model.ImageURLPart:
+		src = anthropicSource{Type: "url", URL: p.URL}
+		if p.MimeType == "" || isImageType(p.MimeType) {
+			kind = "image"
+		}
+	}
+	return anthropicBlock{Type: kind, Source: &src}
+}
+
+func encodeAnthropicTools(tools []ToolDef) []anthropicTool {
+
+	out := make([]anthropicTool, 0, len(tools))
+	for _, t := range tools {
+		out = append(out, anthropicTool{Name: t.Name, Description: t.Description, InputSchema: t.Parameters})
+	}
+	return out
+}
+
+// parseAnthropicSSE translates the Messages stream into provider events:
+// text and thinking deltas as they arrive, a tool call's start, argument
+// deltas and stop around its content block, and one EventComplete with the
+// usage reported by message_start and message_delta.
+func parseAnthropicSSE(body io.ReadCloser, out chan<- ProviderEvent) {
+
+	defer body.Close()
+	s := bufio.NewScanner(body)
+	s.Buffer(make([]byte, 0, 64*1024), 8*1024*1024)
+	tools := map[int]toolState{}
+	usage := &anthropicUsage{}
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var ev anthropicEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &ev); err != nil {
+			continue
+		}
+		if done := emitAnthropicEvent(ev, tools, usage, out); done {
+			return
+		}
+	}
+	if err := s.Err(); err != nil {
+		out <- errorEvent(err)
+		return
+	}
+	out <- errorEvent(fmt.Errorf("anthropic stream ended before message_stop"))
+}
+
+func emitAnthropicEvent(ev anthropicEvent, tools map[int]toolState, usage *anthropicUsage, out chan<- ProviderEvent) bool {
+
+	switch ev.Type {
+	case "message_start":
+		if ev.Message != nil {
+			mergeAnthropicUsage(usage, ev.Message.Usage)
+		}
+	case "content_block_start":
+		if ev.ContentBlock != nil && ev.ContentBlock.Type == "tool_use" {
+			st := toolState{id: ev.ContentBlock.ID, name: ev.ContentBlock.Name}
+			tools[ev.Index] = st
+			out <- ProviderEvent{Type: EventToolUseStart, ToolCallID: st.id, ToolName: st.name}
+		}
+	case "content_block_delta":
+		emitAnthropicDelta(ev, tools, out)
+	case "content_block_stop":
+		if st, ok := tools[ev.Index]; ok {
+			out <- ProviderEvent{Type: EventToolUseStop, ToolCallID: st.id, ToolName: st.name}
+			delete(tools, ev.Index)
+		}
+	case "message_delta":
+		mergeAnthropicUsage(usage, ev.Usage)
+	case "message_stop":
+		out <- ProviderEvent{Type: EventComplete, Usage: anthropicUsageInfo(*usage)}
+		return true
+	case "error":
+		msg := "anthropic stream error"
+		if ev.Error != nil && ev.Error.Message != "" {
+			msg = ev.Error.Type + ": " + ev.Error.Message
+		}
+		out <- errorEvent(&responseError{message: msg})
+		return true
+	}
+	return false
+}
+
+func emitAnthropicDelta(ev anthropicEvent, tools map[int]toolState, out chan<- ProviderEvent) {
+
+	if ev.Delta == nil {
+		return
+	}
+	switch ev.Delta.Type {
+	case "text_delta":
+		if ev.Delta.Text != "" {
+			out <- ProviderEvent{Type: EventContentDelta, Delta: ev.Delta.Text}
+		}
+	case "thinking_delta":
+		if ev.Delta.Thinking != "" {
+			out <- ProviderEvent{Type: EventThinkingDelta, Delta: ev.Delta.Thinking}
+		}
+	case "input_json_delta":
+		st, ok := tools[ev.Index]
+		if ok && ev.Delta.PartialJSON != "" {
+			out <- ProviderEvent{Type: EventToolUseDelta, ToolCallID: st.id, ToolName: st.name, Delta: ev.Delta.PartialJSON}
+		}
+	}
+}
+
+// mergeAnthropicUsage keeps the highest count seen for each field, since
+// message_delta repeats the running totals of message_start.
+func mergeAnthropicUsage(usage *anthropicUsage, u *anthropicUsage) {
+
+	if u == nil {
+		return
+	}
+	usage.InputTokens = max(usage.InputTokens, u.InputTokens)
+	usage.OutputTokens = max(usage.OutputTokens, u.OutputTokens)
+	usage.CacheCreationInputTokens = max(usage.CacheCreationInputTokens, u.CacheCreationInputTokens)
+	usage.CacheReadInputTokens = max(usage.CacheReadInputTokens, u.CacheReadInputTokens)
+}
+
+// anthropicUsageInfo converts u. The API counts cached prompt tokens apart
+// from input_tokens; PromptTokens is their sum, so it measures the whole
+// prompt as the other backends do.
+func anthropicUsageInfo(u anthropicUsage) *UsageInfo {
+
+	return &UsageInfo{
+		PromptTokens:     u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens,
+		CompletionTokens: u.OutputTokens,
+		CacheReadTokens:  u.CacheReadInputTokens,
+		CacheWriteTokens: u.CacheCreationInputTokens,
+	}
+}
@@ -0,0 +1,210 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+)
+
+func anthropicServer(t *testing.T, got *anthropicRequest, header *http.Header, events ...string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/messages" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("read body: %v", err)
+		}
+		if got != nil {
+			if err := json.Unmarshal(b, got); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+		}
+		if header != nil {
+			*header = r.Header.Clone()
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range events {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", ev)
+		}
+	}))
+}
+
+func anthropicProvider(baseURL string) *Anthropic {
+	return NewAnthropic(config.ProviderConfig{
+		Backend:   "anthropic",
+		BaseURL:   baseURL,
+		APIKey:    "sk-ant-test",
+		Model:     "claude-test",
+		MaxTokens: 512,
+	})
+}
+
+func TestAnthropicStreamTextAndUsage(t *testing.T) {
+	var req anthropicRequest
+	var header http.Header
+	srv := anthropicServer(t, &req, &header,
+		`{"type":"message_start","message":{"usage":{"input_tokens":10,"cache_read_input_tokens":90,"cache_creation_input_tokens":5,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"hel"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"lo"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+		`{"type":"message_stop"}`,
+	)
+	defer srv.Close()
+
+	msgs := []model.Message{
+		{ID: "system-prompt", Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "be brief"}}},
+		{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hello"}}},
+	}
+	ev := collectProviderEvents(t, anthropicProvider(srv.URL).Stream(context.Background(), msgs, nil, ToolChoiceAuto))
+	if len(ev) != 4 {
+		t.Fatalf("expected 4 events, got %#v", ev)
+	}
+	if ev[0].Type != EventThinkingDelta || ev[0].Delta != "hmm" {
+		t.Fatalf("unexpected thinking event: %#v", ev[0])
+	}
+	if ev[1].Delta != "hel" || ev[2].Delta != "lo" {
+		t.Fatalf("unexpected content events: %#v %#v", ev[1], ev[2])
+	}
+	u := ev[3].Usage
+	if ev[3].Type != EventComplete || u == nil {
+		t.Fatalf("unexpected completion event: %#v", ev[3])
+	}
+	if u.PromptTokens != 105 || u.CompletionTokens != 7 || u.CacheReadTokens != 90 || u.CacheWriteTokens != 5 {
+		t.Fatalf("unexpected usage: %#v", u)
+	}
+	if req.Model != "claude-test" || req.MaxTokens != 512 || !req.Stream || req.System != "be brief" {
+		t.Fatalf("unexpected request envelope: %#v", req)
+	}
+	if len(req.Messages) != 1 || req.Messages[0].Role != "user" || req.Messages[0].Content[0].Text != "hello" {
+		t.Fatalf("unexpected messages: %#v", req.Messages)
+	}
+	if header.Get("x-api-key") != "sk-ant-test" || header.Get("anthropic-version") != anthropicVersion {
+		t.Fatalf("unexpected headers: %#v", header)
+	}
+}
+
+func TestAnthropicStreamToolCall(t *testing.T) {
+	var req anthropicRequest
+	srv := anthropicServer(t, &req, nil,
+		`{"type":"message_start","message":{"usage":{"input_tokens":3}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"read","input":{}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"a.txt\"}"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_stop"}`,
+	)
+	defer srv.Close()
+
+	tools := []ToolDef{{Name: "read", Description: "read a file", Parameters: json.RawMessage(`{"type":"object"}`)}}
+	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "read a.txt"}}}}
+	ev := collectProviderEvents(t, anthropicProvider(srv.URL).Stream(context.Background(), msgs, tools, ToolChoiceAuto))
+	if len(ev) != 5 {
+		t.Fatalf("expected 5 events, got %#v", ev)
+	}
+	if ev[0].Type != EventToolUseStart || ev[0].ToolCallID != "toolu_1" || ev[0].ToolName != "read" {
+		t.Fatalf("unexpected start event: %#v", ev[0])
+	}
+	if args := ev[1].Delta + ev[2].Delta; args != `{"path":"a.txt"}` {
+		t.Fatalf("unexpected arguments: %q", args)
+	}
+	if ev[3].Type != EventToolUseStop || ev[4].Type != EventComplete {
+		t.Fatalf("unexpected closing events: %#v %#v", ev[3], ev[4])
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Name != "read" || string(req.Tools[0].InputSchema) != `{"type":"object"}` {
+		t.Fatalf("unexpected tools: %#v", req.Tools)
+	}
+}
+
+func TestAnthropicEncodesToolRoundTrip(t *testing.T) {
+	msgs := []model.Message{
+		{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "list files"}}},
+		{Role: model.RoleAssistant, Parts: []model.MessagePart{
+			model.ReasoningPart{Text: "use ls"},
+			model.TextPart{Text: "  "},
+			model.ToolCallPart{ID: "abcd1234_c1", Name: "ls"},
+		}},
+		{Role: model.RoleTool, Parts: []model.MessagePart{model.ToolResultPart{ToolCallID: "abcd1234_c1", Content: "no such dir", IsError: true}}},
+		{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "try again"}}},
+	}
+	got := encodeAnthropicMessages(msgs)
+	if len(got) != 3 || got[0].Role != "user" || got[1].Role != "assistant" || got[2].Role != "user" {
+		t.Fatalf("unexpected turns: %#v", got)
+	}
+	if len(got[1].Content) != 1 || got[1].Content[0].Type != "tool_use" || string(got[1].Content[0].Input) != "{}" {
+		t.Fatalf("unexpected assistant turn: %#v", got[1].Content)
+	}
+	res := got[2].Content
+	if len(res) != 2 || res[0].Type != "tool_result" || res[0].ToolUseID != "abcd1234_c1" || !res[0].IsError {
+		t.Fatalf("unexpected tool result turn: %#v", res)
+	}
+	if res[1].Type != "text" || res[1].Text != "try again" {
+		t.Fatalf("user text not merged after the tool result: %#v", res)
+	}
+}
+
+func TestAnthropicEncodesMedia(t *testing.T) {
+	img := encodeAnthropicMedia(model.BinaryPart{MimeType: "image/png", Data: []byte{1, 2}})
+	if img.Type != "image" || img.Source.Type != "base64" || img.Source.Data != "AQI=" {
+		t.Fatalf("unexpected image block: %#v", img)
+	}
+	doc := encodeAnthropicMedia(model.ImageURLPart{URL: "https://example.com/a.pdf", MimeType: "application/pdf"})
+	if doc.Type != "document" || doc.Source.Type != "url" || doc.Source.URL != "https://example.com/a.pdf" {
+		t.Fatalf("unexpected document block: %#v", doc)
+	}
+}
+
+func TestAnthropicStreamErrorEvent(t *testing.T) {
+	srv := anthropicServer(t, nil, nil,
+		`{"type":"message_start","message":{"usage":{"input_tokens":3}}}`,
+		`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+	)
+	defer srv.Close()
+
+	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hello"}}}}
+	ev := collectProviderEvents(t, anthropicProvider(srv.URL).Stream(context.Background(), msgs, nil, ToolChoiceAuto))
+	if len(ev) != 1 || ev[0].Type != EventError || !strings.Contains(ev[0].Error.Error(), "overloaded_error: Overloaded") {
+		t.Fatalf("unexpected events: %#v", ev)
+	}
+}
+
+func TestAnthropicStreamEndsWithoutStop(t *testing.T) {
+	srv := anthropicServer(t, nil, nil,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"partial"}}`,
+	)
+	defer srv.Close()
+
+	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hello"}}}}
+	ev := collectProviderEvents(t, anthropicProvider(srv.URL).Stream(context.Background(), msgs, nil, ToolChoiceAuto))
+	if len(ev) != 2 || ev[1].Type != EventError || !strings.Contains(ev[1].Error.Error(), "before message_stop") {
+		t.Fatalf("unexpected events: %#v", ev)
+	}
+}
+
+func TestAnthropicStreamStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+	}))
+	defer srv.Close()
+
+	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hello"}}}}
+	ev := collectProviderEvents(t, anthropicProvider(srv.URL).Stream(context.Background(), msgs, nil, ToolChoiceAuto))
+	if len(ev) != 1 || ev[0].Type != EventError || !strings.Contains(ev[0].Error.Error(), "anthropic") {
+		t.Fatalf("unexpected events: %#v", ev)
+	}
+}
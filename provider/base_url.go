@@ -8,6 +8,10 @@ func DefaultBaseURL(backend string) string {
 		return openRouterDefaultBaseURL
 	case "codex":
 		return codexDefaultBaseURL
+	case "anthropic":
+		return anthropicDefaultBaseURL
+	case "ollama":
+		return ollamaDefaultBaseURL
 	default:
 		return ""
 	}
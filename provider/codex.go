@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/agusx1211/miclaw/config"
 	"github.com/agusx1211/miclaw/model"
@@ -21,8 +22,15 @@ type Codex struct {
 	useResponses   bool
 	model          string
 	maxTokens      int
+	window         int
+	prices         modelPrices
 	thinkingEffort string
 	store          bool
+	reasoning      bool
+	mediaURLs      bool
+	sampling       sampling
+	retry          retryPolicy
+	idle           time.Duration
 	client         *http.Client
 }
 
@@ -34,6 +42,8 @@ type codexRequest struct {
 	MaxOutputTokens int                 `json:"max_output_tokens"`
 	Store           bool                `json:"store"`
 	Reasoning       *codexReasoning     `json:"reasoning,omitempty"`
+	ToolChoice      any                 `json:"tool_choice,omitempty"`
+	sampling
 }
 
 type codexReasoning struct {
@@ -54,7 +64,7 @@ func NewCodex(cfg config.ProviderConfig) *Codex {
 	if accountID != "" && strings.HasPrefix(base, codexDefaultBaseURL) {
 		base = codexChatGPTBaseURL
 	}
-	useResponses := strings.Contains(base, "/backend-api/codex")
+	useResponses := responsesStyle(cfg.APIStyle, strings.Contains(base, "/backend-api/codex"))
 	if base == "" {
 		base = codexDefaultBaseURL
 	}
@@ -69,8 +79,15 @@ func NewCodex(cfg config.ProviderConfig) *Codex {
 		useResponses:   useResponses,
 		model:          cfg.Model,
 		maxTokens:      maxTokens,
+		window:         cfg.ContextWindow,
+		prices:         configPrices(cfg),
 		thinkingEffort: strings.TrimSpace(cfg.ThinkingEffort),
 		store:          cfg.Store,
+		reasoning:      cfg.SendReasoning,
+		mediaURLs:      passMediaURLs(cfg.MediaURLs, true),
+		sampling:       samplingFromConfig(cfg),
+		retry:          newRetryPolicy(cfg),
+		idle:           streamIdleTimeout(cfg),
 		client:         &http.Client{},
 	}
 
@@ -79,27 +96,33 @@ func NewCodex(cfg config.ProviderConfig) *Codex {
 
 func (c *Codex) Model() ModelInfo {
 
-	info := ModelInfo{
-		ID:        c.model,
-		Name:      c.model,
-		MaxOutput: c.maxTokens,
-	}
+	info := c.prices.modelInfo(ModelInfo{
+		ID:            c.model,
+		Name:          c.model,
+		ContextWindow: c.window,
+		MaxOutput:     c.maxTokens,
+	})
 
 	return info
 }
 
-func (c *Codex) Stream(ctx context.Context, messages []model.Message, tools []ToolDef) <-chan ProviderEvent {
+func (c *Codex) Stream(ctx context.Context, messages []model.Message, tools []ToolDef, choice ToolChoice) <-chan ProviderEvent {
 
 	out := make(chan ProviderEvent, 16)
 
-	go c.stream(ctx, messages, tools, out)
+	go c.stream(ctx, messages, tools, choice, out)
 	return out
 }
 
-func (c *Codex) stream(ctx context.Context, messages []model.Message, tools []ToolDef, out chan<- ProviderEvent) {
+func (c *Codex) stream(ctx context.Context, messages []model.Message, tools []ToolDef, choice ToolChoice, out chan<- ProviderEvent) {
 
 	defer close(out)
-	payload, path, err := c.marshalRequest(messages, tools)
+	messages, err := resolveMediaURLs(ctx, c.client, messages, c.mediaURLs)
+	if err != nil {
+		out <- errorEvent(err)
+		return
+	}
+	payload, path, err := c.marshalRequest(requestMaxTokens(ctx, c.maxTokens), outgoingHistory(messages, c.reasoning), tools, effectiveToolChoice("codex", choice, tools))
 	if err != nil {
 		out <- errorEvent(err)
 		return
@@ -113,21 +136,22 @@ func (c *Codex) stream(ctx context.Context, messages []model.Message, tools []To
 		out <- errorEvent(readStatusError("codex", resp))
 		return
 	}
-	for e := range parseSSEStream(resp.Body) {
+	for e := range parseSSEStream(watchIdle(resp.Body, c.idle)) {
 		out <- normalizeCodexToolCallID(e)
 	}
 }
 
-func (c *Codex) marshalRequest(messages []model.Message, tools []ToolDef) ([]byte, string, error) {
+func (c *Codex) marshalRequest(maxTokens int, messages []model.Message, tools []ToolDef, choice ToolChoice) ([]byte, string, error) {
 	if c.useResponses {
-		payload, err := marshalCodexResponsesRequest(c.model, c.thinkingEffort, messages, tools)
+		// The ChatGPT backend rejects max_output_tokens.
+		payload, err := marshalCodexResponsesRequest(c.model, 0, c.thinkingEffort, c.sampling, messages, tools, choice)
 		return payload, "/responses", err
 	}
-	payload, err := marshalCodexRequest(c.model, c.maxTokens, c.thinkingEffort, c.store, messages, tools)
+	payload, err := marshalCodexRequest(c.model, maxTokens, c.thinkingEffort, c.store, c.sampling, messages, tools, choice)
 	return payload, "/chat/completions", err
 }
 
-func marshalCodexRequest(modelID string, maxTokens int, effort string, store bool, messages []model.Message, tools []ToolDef) ([]byte, error) {
+func marshalCodexRequest(modelID string, maxTokens int, effort string, store bool, sp sampling, messages []model.Message, tools []ToolDef, choice ToolChoice) ([]byte, error) {
 
 	body := codexRequest{
 		Model:           modelID,
@@ -136,6 +160,8 @@ func marshalCodexRequest(modelID string, maxTokens int, effort string, store boo
 		Stream:          true,
 		MaxOutputTokens: maxTokens,
 		Store:           store,
+		ToolChoice:      chatToolChoice(choice),
+		sampling:        sp,
 	}
 	if effort != "" {
 		body.Reasoning = &codexReasoning{Effort: effort}
@@ -146,7 +172,7 @@ func marshalCodexRequest(modelID string, maxTokens int, effort string, store boo
 
 func (c *Codex) postPath(ctx context.Context, path string, payload []byte) (*http.Response, error) {
 
-	return withRetry(ctx, 0, func() (*http.Response, error) {
+	return withRetry(ctx, c.retry, func() (*http.Response, error) {
 
 		return c.doPostPath(ctx, path, payload)
 	})
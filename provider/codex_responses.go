@@ -13,26 +13,34 @@ type codexResponsesRequest struct {
 	Instructions      string               `json:"instructions"`
 	Input             []codexResponseInput `json:"input"`
 	Tools             []codexResponseTool  `json:"tools,omitempty"`
-	ToolChoice        string               `json:"tool_choice"`
+	ToolChoice        any                  `json:"tool_choice"`
 	ParallelToolCalls bool                 `json:"parallel_tool_calls"`
 	Stream            bool                 `json:"stream"`
 	Store             bool                 `json:"store"`
 	Reasoning         *codexReasoning      `json:"reasoning,omitempty"`
+	MaxOutputTokens   int                  `json:"max_output_tokens,omitempty"`
+	// The responses API takes temperature and top_p but has no stop field.
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
 }
 
 type codexResponseInput struct {
-	Type      string              `json:"type"`
-	Role      string              `json:"role,omitempty"`
-	Content   []codexResponseText `json:"content,omitempty"`
-	CallID    string              `json:"call_id,omitempty"`
-	Name      string              `json:"name,omitempty"`
-	Arguments string              `json:"arguments,omitempty"`
-	Output    *string             `json:"output,omitempty"`
+	Type      string                 `json:"type"`
+	Role      string                 `json:"role,omitempty"`
+	Content   []codexResponseContent `json:"content,omitempty"`
+	CallID    string                 `json:"call_id,omitempty"`
+	Name      string                 `json:"name,omitempty"`
+	Arguments string                 `json:"arguments,omitempty"`
+	Output    *string                `json:"output,omitempty"`
 }
 
-type codexResponseText struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+type codexResponseContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	FileURL  string `json:"file_url,omitempty"`
+	FileData string `json:"file_data,omitempty"`
 }
 
 type codexResponseTool struct {
@@ -42,11 +50,50 @@ type codexResponseTool struct {
 	Parameters  json.RawMessage `json:"parameters"`
 }
 
+// responsesStyle resolves provider.api_style: "responses" and "chat" force
+// a wire format, anything else keeps the backend default.
+func responsesStyle(style string, def bool) bool {
+	switch style {
+	case "responses":
+		return true
+	case "chat":
+		return false
+	}
+	return def
+}
+
+// marshalStyledRequest encodes messages for the chat-completions or the
+// responses API and returns the path the payload must be posted to. effort
+// is sent as the reasoning effort on the responses API only; chat
+// completions have no such field.
+func marshalStyledRequest(
+	responses bool,
+	modelID string,
+	maxTokens int,
+	effort string,
+	sp sampling,
+	messages []model.Message,
+	tools []ToolDef,
+	choice ToolChoice,
+) ([]byte, string, error) {
+	if responses {
+		payload, err := marshalCodexResponsesRequest(modelID, maxTokens, effort, sp, messages, tools, choice)
+		return payload, "/responses", err
+	}
+	payload, err := marshalRequest(modelID, maxTokens, sp, messages, tools, choice)
+	return payload, "/chat/completions", err
+}
+
+// marshalCodexResponsesRequest encodes a responses-API request. A zero
+// maxTokens leaves max_output_tokens out.
 func marshalCodexResponsesRequest(
 	modelID string,
+	maxTokens int,
 	effort string,
+	sp sampling,
 	messages []model.Message,
 	tools []ToolDef,
+	choice ToolChoice,
 ) ([]byte, error) {
 	instructions, inputMessages := codexResponseInstructions(messages)
 	req := codexResponsesRequest{
@@ -54,10 +101,13 @@ func marshalCodexResponsesRequest(
 		Instructions:      instructions,
 		Input:             encodeResponsesInput(inputMessages),
 		Tools:             encodeResponsesTools(tools),
-		ToolChoice:        "auto",
+		ToolChoice:        responsesToolChoice(choice),
 		ParallelToolCalls: true,
 		Stream:            true,
 		Store:             false,
+		Temperature:       sp.Temperature,
+		TopP:              sp.TopP,
+		MaxOutputTokens:   maxTokens,
 	}
 	if isCodexResponsesEffort(effort) {
 		req.Reasoning = &codexReasoning{Effort: effort}
@@ -65,6 +115,23 @@ func marshalCodexResponsesRequest(
 	return json.Marshal(req)
 }
 
+// responsesFunctionChoice is the responses-API tool_choice that forces one
+// function.
+type responsesFunctionChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+func responsesToolChoice(choice ToolChoice) any {
+	switch choice {
+	case ToolChoiceAuto:
+		return "auto"
+	case ToolChoiceNone, ToolChoiceRequired:
+		return string(choice)
+	}
+	return responsesFunctionChoice{Type: "function", Name: choice.Tool()}
+}
+
 func codexResponseInstructions(messages []model.Message) (string, []model.Message) {
 	if len(messages) == 0 {
 		return "You are a helpful assistant.", nil
@@ -98,16 +165,11 @@ func encodeResponsesInput(messages []model.Message) []codexResponseInput {
 
 func encodeResponsesMessage(msg model.Message) []codexResponseInput {
 	out := make([]codexResponseInput, 0, len(msg.Parts)+1)
-	text := messageTextForResponses(msg)
-	if text != "" && (msg.Role == model.RoleUser || msg.Role == model.RoleAssistant) {
-		contentType := "input_text"
-		if msg.Role == model.RoleAssistant {
-			contentType = "output_text"
-		}
+	if content := responsesContent(msg); len(content) > 0 && (msg.Role == model.RoleUser || msg.Role == model.RoleAssistant) {
 		out = append(out, codexResponseInput{
 			Type:    "message",
 			Role:    string(msg.Role),
-			Content: []codexResponseText{{Type: contentType, Text: text}},
+			Content: content,
 		})
 	}
 	for _, part := range msg.Parts {
@@ -134,6 +196,46 @@ func encodeResponsesMessage(msg model.Message) []codexResponseInput {
 	return out
 }
 
+// responsesContent returns the message text followed by its images and
+// documents.
+func responsesContent(msg model.Message) []codexResponseContent {
+	var out []codexResponseContent
+	if text := messageTextForResponses(msg); text != "" {
+		contentType := "input_text"
+		if msg.Role == model.RoleAssistant {
+			contentType = "output_text"
+		}
+		out = append(out, codexResponseContent{Type: contentType, Text: text})
+	}
+	for _, part := range msg.Parts {
+		switch part.(type) {
+		case model.BinaryPart, model.ImageURLPart:
+			if !sendableMedia(part) {
+				out = append(out, codexResponseContent{Type: "input_text", Text: omittedMedia(mediaType(part), "not supported by this backend")})
+				continue
+			}
+			out = append(out, encodeResponsesMedia(part))
+		}
+	}
+	return out
+}
+
+// encodeResponsesMedia turns a binary or URL part into an input_image block,
+// or an input_file block for documents.
+func encodeResponsesMedia(part model.MessagePart) codexResponseContent {
+	url, mimeType := mediaSource(part)
+	if isImageType(mimeType) {
+		return codexResponseContent{Type: "input_image", ImageURL: url}
+	}
+	c := codexResponseContent{Type: "input_file", Filename: mediaFilename(url, mimeType)}
+	if strings.HasPrefix(url, "data:") {
+		c.FileData = url
+	} else {
+		c.FileURL = url
+	}
+	return c
+}
+
 func stringRef(v string) *string {
 	return &v
 }
@@ -49,7 +49,7 @@ func TestMarshalCodexResponsesRequest(t *testing.T) {
 	tools := []ToolDef{
 		{Name: "read", Description: "Read a file", Parameters: json.RawMessage(`{"type":"object"}`)},
 	}
-	b, err := marshalCodexResponsesRequest("gpt-5.2-codex", "medium", msgs, tools)
+	b, err := marshalCodexResponsesRequest("gpt-5.2-codex", 0, "medium", sampling{}, msgs, tools, ToolChoiceAuto)
 	if err != nil {
 		t.Fatalf("marshal request: %v", err)
 	}
@@ -100,7 +100,7 @@ func TestMarshalCodexResponsesRequestDefaultInstructions(t *testing.T) {
 	msgs := []model.Message{
 		{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hello"}}},
 	}
-	b, err := marshalCodexResponsesRequest("gpt-5.2-codex", "none", msgs, nil)
+	b, err := marshalCodexResponsesRequest("gpt-5.2-codex", 0, "none", sampling{}, msgs, nil, ToolChoiceAuto)
 	if err != nil {
 		t.Fatalf("marshal request: %v", err)
 	}
@@ -121,7 +121,7 @@ func TestMarshalCodexResponsesRequestKeepsEmptyToolOutput(t *testing.T) {
 		{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hello"}}},
 		{Role: model.RoleTool, Parts: []model.MessagePart{model.ToolResultPart{ToolCallID: "call_1", Content: ""}}},
 	}
-	b, err := marshalCodexResponsesRequest("gpt-5.3-codex", "medium", msgs, nil)
+	b, err := marshalCodexResponsesRequest("gpt-5.3-codex", 0, "medium", sampling{}, msgs, nil, ToolChoiceAuto)
 	if err != nil {
 		t.Fatalf("marshal request: %v", err)
 	}
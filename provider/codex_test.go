@@ -40,7 +40,7 @@ func TestCodexStreamSuccessContent(t *testing.T) {
 
 	p := codexProvider(srv.URL, "sk-codex-test", false, "")
 	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hello"}}}}
-	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	if len(ev) != 3 {
 		t.Fatalf("expected 3 events, got %d", len(ev))
 	}
@@ -75,7 +75,7 @@ func TestCodexStreamThinkingDelta(t *testing.T) {
 
 	p := codexProvider(srv.URL, "sk-codex-test", false, "")
 	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "think"}}}}
-	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	if len(ev) != 3 {
 		t.Fatalf("expected 3 events, got %d", len(ev))
 	}
@@ -103,7 +103,7 @@ func TestCodexStreamDualToolCallID(t *testing.T) {
 
 	p := codexProvider(srv.URL, "sk-codex-tool", false, "")
 	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "read file"}}}}
-	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	if len(ev) != 4 {
 		t.Fatalf("expected 4 events, got %d", len(ev))
 	}
@@ -131,7 +131,7 @@ func TestCodexRequestIncludesStore(t *testing.T) {
 
 	p := codexProvider(srv.URL, "sk-codex-test", true, "")
 	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "ping"}}}}
-	_ = collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	_ = collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	req := c.firstRequest()
 	if !req.Store {
 		t.Fatalf("expected store=true in request, got %#v", req)
@@ -148,7 +148,7 @@ func TestCodexRequestIncludesReasoningEffort(t *testing.T) {
 
 	p := codexProvider(srv.URL, "sk-codex-test", false, "medium")
 	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "ping"}}}}
-	_ = collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	_ = collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	req := c.firstRequest()
 	if req.Reasoning == nil || req.Reasoning.Effort != "medium" {
 		t.Fatalf("expected reasoning effort in request, got %#v", req.Reasoning)
@@ -189,7 +189,7 @@ func TestCodexStreamChatgptResponsesOAuth(t *testing.T) {
 	}
 	p := NewCodex(cfg)
 	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hello"}}}}
-	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	if len(ev) != 2 {
 		t.Fatalf("expected 2 events, got %d", len(ev))
 	}
@@ -228,6 +228,25 @@ func TestCodexStreamChatgptResponsesOAuth(t *testing.T) {
 	}
 }
 
+func TestCodexAPIStyleOverridesBaseURL(t *testing.T) {
+	paths := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "ping"}}}}
+	chat := NewCodex(config.ProviderConfig{BaseURL: srv.URL + "/backend-api/codex", Model: "m", APIStyle: "chat"})
+	_ = collectProviderEvents(t, chat.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
+	responses := NewCodex(config.ProviderConfig{BaseURL: srv.URL, Model: "m", APIStyle: "responses"})
+	_ = collectProviderEvents(t, responses.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
+	if len(paths) != 2 || paths[0] != "/backend-api/codex/chat/completions" || paths[1] != "/responses" {
+		t.Fatalf("unexpected paths: %v", paths)
+	}
+}
+
 func TestCodexStreamStatusErrorPrefix(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
@@ -237,7 +256,7 @@ func TestCodexStreamStatusErrorPrefix(t *testing.T) {
 
 	p := codexProvider(srv.URL, "sk-codex-test", false, "")
 	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hello"}}}}
-	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	if len(ev) != 1 || ev[0].Type != EventError || ev[0].Error == nil {
 		t.Fatalf("unexpected events: %#v", ev)
 	}
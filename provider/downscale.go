@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+// mediaImageMinSide is the smallest long side fitMedia will shrink to before
+// giving up on an image.
+const mediaImageMinSide = 256
+
+// mediaImageMaxPixels caps the size of an image fitMedia will decode; a
+// small file can still declare dimensions that take gigabytes to decode.
+const mediaImageMaxPixels = 50_000_000
+
+// fitMedia returns part as it is when it is small enough to send inline. A
+// larger PNG, JPEG or GIF is re-encoded as a JPEG no larger than
+// mediaImageMaxSide on either side, halving until it fits. Anything that
+// cannot be shrunk enough, or whose header declares more than
+// mediaImageMaxPixels, becomes a text note, so one attachment cannot blow
+// up every later request in the thread.
+func fitMedia(part model.BinaryPart) model.MessagePart {
+
+	if len(part.Data) <= mediaInlineMaxBytes {
+		return part
+	}
+	note := model.TextPart{Text: omittedMedia(part.MimeType, fmt.Sprintf("%d bytes, over the %d byte limit", len(part.Data), mediaInlineMaxBytes))}
+	if !isImageType(part.MimeType) {
+		return note
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(part.Data))
+	if err != nil {
+		return note
+	}
+	if cfg.Width*cfg.Height > mediaImageMaxPixels {
+		return model.TextPart{Text: omittedMedia(part.MimeType, fmt.Sprintf("%dx%d pixels, over the %d pixel limit", cfg.Width, cfg.Height, mediaImageMaxPixels))}
+	}
+	img, _, err := image.Decode(bytes.NewReader(part.Data))
+	if err != nil {
+		return note
+	}
+	for side := mediaImageMaxSide; side >= mediaImageMinSide; side /= 2 {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, downscale(img, side), &jpeg.Options{Quality: 85}); err != nil {
+			return note
+		}
+		if buf.Len() <= mediaInlineMaxBytes {
+			return model.BinaryPart{MimeType: "image/jpeg", Data: buf.Bytes()}
+		}
+	}
+	return note
+}
+
+// downscale draws img onto a white background, shrunk so neither side is
+// over maxSide. Each output pixel is the average of the source pixels it
+// covers.
+func downscale(img image.Image, maxSide int) *image.RGBA {
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if w > maxSide || h > maxSide {
+		if w >= h {
+			dw, dh = maxSide, max(1, h*maxSide/w)
+		} else {
+			dw, dh = max(1, w*maxSide/h), maxSide
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		for x := range dw {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			var sum [4]uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, bl, a := img.At(sx, sy).RGBA()
+					sum[0], sum[1], sum[2], sum[3] = sum[0]+uint64(r), sum[1]+uint64(g), sum[2]+uint64(bl), sum[3]+uint64(a)
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			// Colors are alpha-premultiplied, so adding the missing
+			// coverage composites the pixel over white.
+			white := 0xffff - sum[3]/n
+			i := dst.PixOffset(x, y)
+			for c := range 3 {
+				dst.Pix[i+c] = uint8((sum[c]/n + white) >> 8)
+			}
+			dst.Pix[i+3] = 0xff
+		}
+	}
+	return dst
+}
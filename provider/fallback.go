@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+
+	"github.com/agusx1211/miclaw/logging"
+	"github.com/agusx1211/miclaw/model"
+)
+
+// FallbackBackend is one entry of a failover chain. Name tags the events it
+// serves.
+type FallbackBackend struct {
+	Name     string
+	Provider LLMProvider
+}
+
+// Fallback sends each request to its first backend and re-issues it on the
+// next one when a backend fails with a retryable error before producing any
+// output. Once a backend has streamed an event, its errors are passed on:
+// re-issuing then would repeat output already delivered.
+type Fallback struct {
+	backends []FallbackBackend
+	mu       sync.Mutex
+	serving  int
+}
+
+func NewFallback(backends ...FallbackBackend) *Fallback {
+	return &Fallback{backends: backends}
+}
+
+// Model returns the model of the backend that served the latest request, so
+// pricing and limits follow a failover.
+func (f *Fallback) Model() ModelInfo {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.backends[f.serving].Provider.Model()
+}
+
+func (f *Fallback) Stream(ctx context.Context, messages []model.Message, tools []ToolDef, choice ToolChoice) <-chan ProviderEvent {
+	out := make(chan ProviderEvent, 16)
+	go f.stream(ctx, messages, tools, choice, out)
+	return out
+}
+
+func (f *Fallback) stream(ctx context.Context, messages []model.Message, tools []ToolDef, choice ToolChoice, out chan<- ProviderEvent) {
+
+	defer close(out)
+	for i, b := range f.backends {
+		last := i == len(f.backends)-1
+		started, failover := false, false
+		for ev := range b.Provider.Stream(ctx, messages, tools, choice) {
+			if !started && !last && ev.Type == EventError && ctx.Err() == nil && retryable(ev.Error) {
+				logging.Infof("[provider] failover from=%s to=%s err=%v", b.Name, f.backends[i+1].Name, ev.Error)
+				failover = true
+				continue
+			}
+			if !started {
+				f.setServing(i)
+				started = true
+			}
+			ev.Backend = b.Name
+			out <- ev
+		}
+		if !failover {
+			return
+		}
+	}
+}
+
+func (f *Fallback) setServing(i int) {
+
+	f.mu.Lock()
+	f.serving = i
+	f.mu.Unlock()
+}
+
+// retryable reports whether err is worth re-issuing on another backend:
+// status errors marked Retryable, timeouts, stalled streams and failed
+// connections. Client errors such as 400 or 401 would fail the same way
+// anywhere.
+func retryable(err error) bool {
+
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Retryable
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ErrStreamStalled) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,195 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+// scriptedBackend replays events and counts the requests it receives.
+type scriptedBackend struct {
+	events []ProviderEvent
+	info   ModelInfo
+	calls  atomic.Int32
+}
+
+func (b *scriptedBackend) Stream(context.Context, []model.Message, []ToolDef, ToolChoice) <-chan ProviderEvent {
+	b.calls.Add(1)
+	out := make(chan ProviderEvent, len(b.events))
+	for _, ev := range b.events {
+		out <- ev
+	}
+	close(out)
+	return out
+}
+
+func (b *scriptedBackend) Model() ModelInfo { return b.info }
+
+func failing(err error) *scriptedBackend {
+	return &scriptedBackend{events: []ProviderEvent{errorEvent(err)}, info: ModelInfo{ID: "primary", CostPerInputToken: 1}}
+}
+
+func serving(text string) *scriptedBackend {
+	return &scriptedBackend{
+		events: []ProviderEvent{{Type: EventContentDelta, Delta: text}, {Type: EventComplete, Usage: &UsageInfo{PromptTokens: 10}}},
+		info:   ModelInfo{ID: "secondary", CostPerInputToken: 0.5},
+	}
+}
+
+func drainFallback(t *testing.T, f *Fallback) []ProviderEvent {
+	t.Helper()
+	return collectProviderEvents(t, f.Stream(context.Background(), nil, nil, ToolChoiceAuto))
+}
+
+func TestFallbackFailsOverOnServerError(t *testing.T) {
+	primary := failing(&StatusError{Backend: "openrouter", Status: http.StatusServiceUnavailable, Retryable: true})
+	secondary := serving("hi")
+	f := NewFallback(FallbackBackend{Name: "openrouter/m", Provider: primary}, FallbackBackend{Name: "lmstudio/q", Provider: secondary})
+
+	ev := drainFallback(t, f)
+	if len(ev) != 2 || ev[0].Delta != "hi" || ev[1].Type != EventComplete {
+		t.Fatalf("unexpected events: %#v", ev)
+	}
+	for _, e := range ev {
+		if e.Backend != "lmstudio/q" {
+			t.Fatalf("event not tagged with serving backend: %#v", e)
+		}
+	}
+	if got := f.Model(); got.ID != "secondary" || got.Cost(*ev[1].Usage) != 5 {
+		t.Fatalf("model does not follow the serving backend: %+v", got)
+	}
+	if primary.calls.Load() != 1 || secondary.calls.Load() != 1 {
+		t.Fatalf("calls primary=%d secondary=%d", primary.calls.Load(), secondary.calls.Load())
+	}
+
+	primary.events = serving("back").events
+	drainFallback(t, f)
+	if f.Model().ID != "primary" {
+		t.Fatal("recovered primary not used for the next request")
+	}
+}
+
+func TestFallbackKeepsClientErrors(t *testing.T) {
+	primary := failing(&StatusError{Backend: "openrouter", Status: http.StatusUnauthorized, Body: "bad key"})
+	secondary := serving("hi")
+	f := NewFallback(FallbackBackend{Name: "a", Provider: primary}, FallbackBackend{Name: "b", Provider: secondary})
+
+	ev := drainFallback(t, f)
+	if len(ev) != 1 || ev[0].Type != EventError || ev[0].Backend != "a" {
+		t.Fatalf("unexpected events: %#v", ev)
+	}
+	if secondary.calls.Load() != 0 {
+		t.Fatal("client error was re-issued on the fallback")
+	}
+}
+
+func TestFallbackDoesNotRetryAfterOutput(t *testing.T) {
+	primary := &scriptedBackend{events: []ProviderEvent{
+		{Type: EventContentDelta, Delta: "partial"},
+		errorEvent(&StatusError{Backend: "openrouter", Status: http.StatusBadGateway, Retryable: true}),
+	}}
+	secondary := serving("hi")
+	f := NewFallback(FallbackBackend{Name: "a", Provider: primary}, FallbackBackend{Name: "b", Provider: secondary})
+
+	ev := drainFallback(t, f)
+	if len(ev) != 2 || ev[1].Type != EventError || secondary.calls.Load() != 0 {
+		t.Fatalf("mid-stream error re-issued: %#v", ev)
+	}
+}
+
+func TestFallbackReturnsLastBackendError(t *testing.T) {
+	f := NewFallback(
+		FallbackBackend{Name: "a", Provider: failing(&StatusError{Status: 500, Retryable: true})},
+		FallbackBackend{Name: "b", Provider: failing(&StatusError{Status: 502, Retryable: true})},
+	)
+	ev := drainFallback(t, f)
+	var status *StatusError
+	if len(ev) != 1 || !errors.As(ev[0].Error, &status) || status.Status != 502 || ev[0].Backend != "b" {
+		t.Fatalf("unexpected events: %#v", ev)
+	}
+}
+
+func TestFallbackFailsOverWhenPrimaryIsDown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := "http://" + ln.Addr().String()
+	ln.Close()
+	c := &streamCapture{}
+	srv := lmStudioServer(t, c, func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"local\"}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+	defer srv.Close()
+
+	f := NewFallback(
+		FallbackBackend{Name: "remote", Provider: lmStudioProvider(down, "k")},
+		FallbackBackend{Name: "local", Provider: lmStudioProvider(srv.URL, "k")},
+	)
+	ev := drainFallback(t, f)
+	if len(ev) == 0 || ev[0].Delta != "local" || ev[0].Backend != "local" {
+		t.Fatalf("unexpected events: %#v", ev)
+	}
+}
+
+func TestReadStatusErrorClassifiesStatus(t *testing.T) {
+	cases := []struct {
+		status                      int
+		retryable, limited, badAuth bool
+	}{
+		{http.StatusTooManyRequests, true, true, false},
+		{529, true, true, false},
+		{http.StatusBadGateway, true, false, false},
+		{http.StatusRequestTimeout, true, false, false},
+		{http.StatusUnauthorized, false, false, true},
+		{http.StatusForbidden, false, false, true},
+		{http.StatusBadRequest, false, false, false},
+	}
+	for _, tc := range cases {
+		resp := &http.Response{StatusCode: tc.status, Body: io.NopCloser(strings.NewReader(" nope \n"))}
+		err := fmt.Errorf("stream: %w", readStatusError("openrouter", resp))
+		var status *StatusError
+		if !errors.As(err, &status) || status.Retryable != tc.retryable || IsRateLimited(err) != tc.limited || IsAuthError(err) != tc.badAuth {
+			t.Fatalf("status %d: got %+v, rate limited %v, auth %v", tc.status, status, IsRateLimited(err), IsAuthError(err))
+		}
+		if want := fmt.Sprintf("openrouter stream failed: status %d: nope", tc.status); status.Error() != want {
+			t.Fatalf("Error() = %q, want %q", status.Error(), want)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&StatusError{Status: 500, Retryable: true}, true},
+		{&StatusError{Status: 503, Retryable: true}, true},
+		{&StatusError{Status: 429, Retryable: true}, true},
+		{&StatusError{Status: 501}, false},
+		{&StatusError{Status: 400}, false},
+		{&StatusError{Status: 401}, false},
+		{fmt.Errorf("post: %w", refused), true},
+		{context.DeadlineExceeded, true},
+		{fmt.Errorf("%w: no data for 2m0s", ErrStreamStalled), true},
+		{context.Canceled, false},
+		{&responseError{message: "invalid prompt"}, false},
+	}
+	for _, tc := range cases {
+		if got := retryable(tc.err); got != tc.want {
+			t.Fatalf("retryable(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -61,7 +61,7 @@ func TestIntegrationSimplePrompt(t *testing.T) {
 			model.TextPart{Text: "What is 2+2? Reply with only the number."},
 		}},
 	}
-	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	contentDeltaCount := 0
 	completeCount := 0
 	text := ""
@@ -108,7 +108,7 @@ func TestIntegrationToolCall(t *testing.T) {
 			model.TextPart{Text: "What is the weather in Paris? Use the get_weather tool."},
 		}},
 	}
-	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, tools))
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, tools, ToolChoiceAuto))
 	var toolStart, toolStop, complete int
 	toolName := ""
 	for _, e := range ev {
@@ -143,7 +143,7 @@ func TestIntegrationStreamingDeltas(t *testing.T) {
 			model.TextPart{Text: "Count from 1 to 10, one number per line."},
 		}},
 	}
-	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	contentDeltaCount := 0
 	for _, e := range ev {
 		if e.Type == EventContentDelta {
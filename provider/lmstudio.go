@@ -5,6 +5,7 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/agusx1211/miclaw/config"
 	"github.com/agusx1211/miclaw/model"
@@ -17,6 +18,16 @@ type LMStudio struct {
 	apiKey    string
 	model     string
 	maxTokens int
+	window    int
+	prices    modelPrices
+	reasoning bool
+	responses bool
+	// effort is the reasoning effort sent with the responses API.
+	effort    string
+	mediaURLs bool
+	sampling  sampling
+	retry     retryPolicy
+	idle      time.Duration
 	client    *http.Client
 }
 
@@ -34,34 +45,52 @@ func NewLMStudio(cfg config.ProviderConfig) *LMStudio {
 		apiKey:    cfg.APIKey,
 		model:     cfg.Model,
 		maxTokens: maxTokens,
+		window:    cfg.ContextWindow,
+		prices:    configPrices(cfg),
+		reasoning: cfg.SendReasoning,
+		responses: responsesStyle(cfg.APIStyle, false),
+		effort:    strings.TrimSpace(cfg.ThinkingEffort),
+		mediaURLs: passMediaURLs(cfg.MediaURLs, false),
+		sampling:  samplingFromConfig(cfg),
+		retry:     newRetryPolicy(cfg),
+		idle:      streamIdleTimeout(cfg),
 		client:    &http.Client{},
 	}
 }
 
 func (l *LMStudio) Model() ModelInfo {
-	return ModelInfo{
-		ID:                 l.model,
-		Name:               l.model,
-		MaxOutput:          l.maxTokens,
-		CostPerInputToken:  0,
-		CostPerOutputToken: 0,
-	}
+	return l.prices.modelInfo(ModelInfo{
+		ID:            l.model,
+		Name:          l.model,
+		ContextWindow: l.window,
+		MaxOutput:     l.maxTokens,
+	})
 }
 
-func (l *LMStudio) Stream(ctx context.Context, messages []model.Message, tools []ToolDef) <-chan ProviderEvent {
+func (l *LMStudio) Stream(ctx context.Context, messages []model.Message, tools []ToolDef, choice ToolChoice) <-chan ProviderEvent {
 	out := make(chan ProviderEvent, 16)
-	go l.stream(ctx, messages, tools, out)
+	go l.stream(ctx, messages, tools, choice, out)
 	return out
 }
 
-func (l *LMStudio) stream(ctx context.Context, messages []model.Message, tools []ToolDef, out chan<- ProviderEvent) {
+func (l *LMStudio) stream(ctx context.Context, messages []model.Message, tools []ToolDef, choice ToolChoice, out chan<- ProviderEvent) {
 	defer close(out)
-	payload, err := marshalRequest(l.model, l.maxTokens, messages, tools)
+	messages, err := resolveMediaURLs(ctx, l.client, messages, l.mediaURLs)
+	if err != nil {
+		out <- errorEvent(err)
+		return
+	}
+	// LM Studio takes none, auto and required but cannot force one tool.
+	choice = effectiveToolChoice("lmstudio", choice, tools)
+	if choice.Tool() != "" {
+		choice = unsupportedToolChoice("lmstudio", choice)
+	}
+	payload, path, err := marshalStyledRequest(l.responses, l.model, requestMaxTokens(ctx, l.maxTokens), l.effort, l.sampling, outgoingHistory(messages, l.reasoning), tools, choice)
 	if err != nil {
 		out <- errorEvent(err)
 		return
 	}
-	resp, err := l.post(ctx, payload)
+	resp, err := l.post(ctx, path, payload)
 	if err != nil {
 		out <- errorEvent(err)
 		return
@@ -70,19 +99,19 @@ func (l *LMStudio) stream(ctx context.Context, messages []model.Message, tools [
 		out <- errorEvent(readStatusError("lmstudio", resp))
 		return
 	}
-	for e := range parseSSEStream(resp.Body) {
+	for e := range parseSSEStream(watchIdle(resp.Body, l.idle)) {
 		out <- e
 	}
 }
 
-func (l *LMStudio) post(ctx context.Context, payload []byte) (*http.Response, error) {
-	return withRetry(ctx, 0, func() (*http.Response, error) {
-		return l.doPost(ctx, payload)
+func (l *LMStudio) post(ctx context.Context, path string, payload []byte) (*http.Response, error) {
+	return withRetry(ctx, l.retry, func() (*http.Response, error) {
+		return l.doPost(ctx, path, payload)
 	})
 }
 
-func (l *LMStudio) doPost(ctx context.Context, payload []byte) (*http.Response, error) {
-	u := l.baseURL + "/chat/completions"
+func (l *LMStudio) doPost(ctx context.Context, path string, payload []byte) (*http.Response, error) {
+	u := l.baseURL + path
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(payload))
 	if err != nil {
 		return nil, err
@@ -38,10 +38,11 @@ func lmStudioServer(t *testing.T, capture *streamCapture, fn func(http.ResponseW
 
 func lmStudioProvider(baseURL, apiKey string) *LMStudio {
 	cfg := config.ProviderConfig{
-		BaseURL:   baseURL,
-		APIKey:    apiKey,
-		Model:     "qwen2.5",
-		MaxTokens: 128,
+		BaseURL:         baseURL,
+		APIKey:          apiKey,
+		Model:           "qwen2.5",
+		MaxTokens:       128,
+		RetryBaseMillis: 1,
 	}
 	return NewLMStudio(cfg)
 }
@@ -59,7 +60,7 @@ func TestLMStudioStreamSuccessContent(t *testing.T) {
 
 	p := lmStudioProvider(srv.URL, "lmstudio")
 	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "say hi"}}}}
-	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	if len(ev) != 3 {
 		t.Fatalf("expected 3 events, got %d", len(ev))
 	}
@@ -96,7 +97,7 @@ func TestLMStudioStreamToolCalls(t *testing.T) {
 		Description: "read a file",
 		Parameters:  json.RawMessage(`{"type":"object","properties":{"path":{"type":"string"}}, "required":["path"]}`),
 	}}
-	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, tools))
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, tools, ToolChoiceAuto))
 	if len(ev) != 4 {
 		t.Fatalf("expected 4 events, got %d", len(ev))
 	}
@@ -114,6 +115,70 @@ func TestLMStudioStreamToolCalls(t *testing.T) {
 	}
 }
 
+func TestLMStudioResponsesAPIStyle(t *testing.T) {
+	gotPath := ""
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("read body: %v", err)
+		}
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"type\":\"response.output_text.delta\",\"delta\":\"ok\"}\n\n")
+		fmt.Fprint(w, "data: {\"type\":\"response.completed\",\"response\":{\"usage\":{\"input_tokens\":1,\"output_tokens\":1}}}\n\n")
+	}))
+	defer srv.Close()
+
+	cfg := config.ProviderConfig{
+		BaseURL:        srv.URL,
+		Model:          "qwen2.5",
+		MaxTokens:      128,
+		APIStyle:       "responses",
+		ThinkingEffort: "high",
+	}
+	p := NewLMStudio(cfg)
+	msgs := []model.Message{
+		{ID: "system-1", Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "be brief"}}},
+		{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "say hi"}}},
+	}
+	tools := []ToolDef{{Name: "read", Description: "read a file", Parameters: json.RawMessage(`{"type":"object"}`)}}
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, tools, ToolChoiceAuto))
+	if len(ev) != 2 || ev[0].Delta != "ok" || ev[1].Type != EventComplete {
+		t.Fatalf("unexpected events: %#v", ev)
+	}
+	if gotPath != "/responses" {
+		t.Fatalf("unexpected path: %q", gotPath)
+	}
+	if _, ok := body["messages"]; ok {
+		t.Fatalf("responses request must not carry messages: %#v", body)
+	}
+	if body["instructions"] != "be brief" || body["model"] != "qwen2.5" || body["stream"] != true {
+		t.Fatalf("unexpected request body: %#v", body)
+	}
+	if body["max_output_tokens"] != float64(128) {
+		t.Fatalf("max_output_tokens = %#v", body["max_output_tokens"])
+	}
+	if r, _ := body["reasoning"].(map[string]any); r["effort"] != "high" {
+		t.Fatalf("reasoning = %#v", body["reasoning"])
+	}
+	input, ok := body["input"].([]any)
+	if !ok || len(input) != 1 {
+		t.Fatalf("unexpected input: %#v", body["input"])
+	}
+	item := input[0].(map[string]any)
+	if item["type"] != "message" || item["role"] != "user" {
+		t.Fatalf("unexpected input item: %#v", item)
+	}
+	tl, ok := body["tools"].([]any)
+	if !ok || len(tl) != 1 || tl[0].(map[string]any)["name"] != "read" {
+		t.Fatalf("unexpected tools: %#v", body["tools"])
+	}
+}
+
 func TestLMStudioStreamConnectionError(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		t.Fatalf("handler must not be called")
@@ -122,7 +187,7 @@ func TestLMStudioStreamConnectionError(t *testing.T) {
 
 	p := lmStudioProvider(srv.URL, "lmstudio")
 	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "ping"}}}}
-	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	if len(ev) != 1 || ev[0].Type != EventError {
 		t.Fatalf("expected 1 error event, got %#v", ev)
 	}
@@ -149,7 +214,7 @@ func TestLMStudioStreamStatusErrorPrefix(t *testing.T) {
 
 	p := lmStudioProvider(srv.URL, "lmstudio")
 	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "ping"}}}}
-	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	if len(ev) != 1 || ev[0].Type != EventError || ev[0].Error == nil {
 		t.Fatalf("unexpected events: %#v", ev)
 	}
@@ -157,3 +222,17 @@ func TestLMStudioStreamStatusErrorPrefix(t *testing.T) {
 		t.Fatalf("unexpected error: %v", ev[0].Error)
 	}
 }
+
+func TestModelReportsConfiguredWindowAndInputCost(t *testing.T) {
+	cfg := config.ProviderConfig{Model: "m", APIKey: "k", ContextWindow: 32000, InputCostPerMTok: 2.5}
+	for name, p := range map[string]LLMProvider{
+		"lmstudio":   NewLMStudio(cfg),
+		"openrouter": NewOpenRouter(cfg),
+		"codex":      NewCodex(cfg),
+	} {
+		m := p.Model()
+		if m.ContextWindow != 32000 || m.CostPerInputToken != 2.5/1_000_000 {
+			t.Fatalf("%s: unexpected model info %#v", name, m)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package provider
+
+import "context"
+
+type maxTokensKey struct{}
+
+// WithMaxTokens returns a context whose requests may produce up to n output
+// tokens, where that is more than provider.max_tokens. The agent uses it to
+// retry a reply that was cut off at the limit.
+func WithMaxTokens(ctx context.Context, n int) context.Context {
+
+	return context.WithValue(ctx, maxTokensKey{}, n)
+}
+
+// requestMaxTokens returns the output token limit of a request made with
+// ctx: def, or the larger limit set by WithMaxTokens.
+func requestMaxTokens(ctx context.Context, def int) int {
+
+	if n, ok := ctx.Value(maxTokensKey{}).(int); ok && n > def {
+		return n
+	}
+	return def
+}
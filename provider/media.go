@@ -0,0 +1,226 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+)
+
+// mediaDownloadMaxBytes caps one downloaded image or document.
+const mediaDownloadMaxBytes = 20 << 20
+
+// mediaInlineMaxBytes caps one image or document sent inline. Larger images
+// are downscaled to fit, and anything that still does not is replaced by a
+// note. It matches the strictest per-image limit among the backends.
+const mediaInlineMaxBytes = 5 << 20
+
+// mediaImageMaxSide is the longest side, in pixels, of a downscaled image.
+const mediaImageMaxSide = 2048
+
+// passMediaURLs resolves provider.media_urls: "pass" sends image and
+// document URLs upstream as they are, "download" fetches them first, and
+// anything else keeps the backend default.
+func passMediaURLs(style string, def bool) bool {
+	switch style {
+	case "pass":
+		return true
+	case "download":
+		return false
+	}
+	return def
+}
+
+// resolveMediaURLs returns messages as they should be sent upstream. Unless
+// pass is true, every ImageURLPart is replaced by a BinaryPart of its stored
+// Data, or of a fresh download when it has none, for backends that do not
+// take URLs. Binary parts over
+// mediaInlineMaxBytes are shrunk or dropped by fitMedia either way.
+func resolveMediaURLs(ctx context.Context, client *http.Client, messages []model.Message, pass bool) ([]model.Message, error) {
+
+	out := make([]model.Message, len(messages))
+	for i, m := range messages {
+		parts := make([]model.MessagePart, 0, len(m.Parts))
+		for _, p := range m.Parts {
+			if u, ok := p.(model.ImageURLPart); ok && !pass {
+				b := model.BinaryPart{MimeType: u.MimeType, Data: u.Data}
+				if len(b.Data) == 0 {
+					var err error
+					if b, err = downloadMedia(ctx, client, u); err != nil {
+						return nil, err
+					}
+				}
+				p = b
+			}
+			if b, ok := p.(model.BinaryPart); ok {
+				p = fitMedia(b)
+			}
+			parts = append(parts, p)
+		}
+		m.Parts = parts
+		out[i] = m
+	}
+
+	return out, nil
+}
+
+// PrepareMedia returns parts with every binary attachment fitted to be sent
+// inline, as resolveMediaURLs would. With download set, ImageURLParts are
+// also fetched and keep the fitted bytes in Data; a failed download is left
+// for the provider to retry and report. Callers storing a message run it
+// once, so the providers do not download, decode and downscale the same
+// media on every request.
+func PrepareMedia(ctx context.Context, client *http.Client, parts []model.MessagePart, download bool) []model.MessagePart {
+
+	out := make([]model.MessagePart, len(parts))
+	for i, p := range parts {
+		switch v := p.(type) {
+		case model.BinaryPart:
+			p = fitMedia(v)
+		case model.ImageURLPart:
+			if download && len(v.Data) == 0 {
+				p = prefetchMedia(ctx, client, v)
+			}
+		}
+		out[i] = p
+	}
+	return out
+}
+
+// prefetchMedia downloads part and keeps the fitted bytes in it. When the
+// download fails or the content does not fit inline, part is returned as it
+// was.
+func prefetchMedia(ctx context.Context, client *http.Client, part model.ImageURLPart) model.ImageURLPart {
+
+	b, err := downloadMedia(ctx, client, part)
+	if err != nil {
+		return part
+	}
+	fitted, ok := fitMedia(b).(model.BinaryPart)
+	if !ok {
+		return part
+	}
+	part.MimeType, part.Data = fitted.MimeType, fitted.Data
+	return part
+}
+
+// DownloadsMedia reports whether the backend of cfg, or any of its
+// fallbacks, sends image and document URLs as downloaded data.
+func DownloadsMedia(cfg config.ProviderConfig) bool {
+
+	pass := passMediaURLs(cfg.MediaURLs, cfg.Backend != "lmstudio")
+	if !pass || cfg.Backend == "ollama" {
+		return true
+	}
+	for _, fb := range cfg.Fallbacks {
+		if DownloadsMedia(fb) {
+			return true
+		}
+	}
+	return false
+}
+
+func downloadMedia(ctx context.Context, client *http.Client, part model.ImageURLPart) (model.BinaryPart, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, part.URL, nil)
+	if err != nil {
+		return model.BinaryPart{}, fmt.Errorf("download %s: %v", part.URL, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return model.BinaryPart{}, fmt.Errorf("download %s: %v", part.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return model.BinaryPart{}, fmt.Errorf("download %s: status %d", part.URL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, mediaDownloadMaxBytes+1))
+	if err != nil {
+		return model.BinaryPart{}, fmt.Errorf("download %s: %v", part.URL, err)
+	}
+	if len(data) > mediaDownloadMaxBytes {
+		return model.BinaryPart{}, fmt.Errorf("download %s: over %d bytes", part.URL, mediaDownloadMaxBytes)
+	}
+	mimeType := part.MimeType
+	if mimeType == "" {
+		mimeType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	}
+
+	return model.BinaryPart{MimeType: mimeType, Data: data}, nil
+}
+
+// mediaSource returns the URL a part is sent as, a data URL for binary
+// data, with its MIME type. URL parts without a type are taken as images.
+func mediaSource(part model.MessagePart) (string, string) {
+
+	switch p := part.(type) {
+	case model.BinaryPart:
+		return "data:" + p.MimeType + ";base64," + base64.StdEncoding.EncodeToString(p.Data), p.MimeType
+	case model.ImageURLPart:
+		return p.URL, mediaType(p)
+	}
+	panic(fmt.Sprintf("not a media part: %T", part))
+}
+
+// mediaType returns the MIME type of a binary or URL part, taking URL parts
+// without one as images.
+func mediaType(part model.MessagePart) string {
+
+	switch p := part.(type) {
+	case model.BinaryPart:
+		return p.MimeType
+	case model.ImageURLPart:
+		if p.MimeType == "" {
+			return "image/*"
+		}
+		return p.MimeType
+	}
+	panic(fmt.Sprintf("not a media part: %T", part))
+}
+
+// sendableMedia reports whether a media part is an image or a document the
+// backends accept; other attachments are sent as an omittedMedia note.
+func sendableMedia(part model.MessagePart) bool {
+
+	t := mediaType(part)
+	return isImageType(t) || isDocumentType(t)
+}
+
+func isImageType(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "image/")
+}
+
+// isDocumentType reports whether a non-image type can be sent as a document.
+// PDF is the one type every backend with document input accepts.
+func isDocumentType(mimeType string) bool {
+	return mimeType == "application/pdf"
+}
+
+// omittedMedia is the text sent in place of an attachment a backend cannot
+// take.
+func omittedMedia(mimeType, reason string) string {
+	return fmt.Sprintf("[%s attachment omitted: %s]", mimeType, reason)
+}
+
+// mediaFilename names a document for backends that require a filename: the
+// last path element of its URL, or "document" with an extension for its
+// type.
+func mediaFilename(url, mimeType string) string {
+
+	if !strings.HasPrefix(url, "data:") {
+		if base := path.Base(strings.SplitN(url, "?", 2)[0]); base != "." && base != "/" {
+			return base
+		}
+	}
+	if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+		return "document" + exts[0]
+	}
+	return "document"
+}
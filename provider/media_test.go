@@ -0,0 +1,291 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+)
+
+func mediaMessages(parts ...model.MessagePart) []model.Message {
+	return []model.Message{{Role: model.RoleUser, Parts: append([]model.MessagePart{model.TextPart{Text: "what is this?"}}, parts...)}}
+}
+
+func TestChatEncodesImageURLPartAsImageURLBlock(t *testing.T) {
+	msgs := mediaMessages(
+		model.ImageURLPart{URL: "https://example.com/cat.png"},
+		model.ImageURLPart{URL: "https://example.com/files/report.pdf?v=2", MimeType: "application/pdf"},
+	)
+	resolved, err := resolveMediaURLs(context.Background(), http.DefaultClient, msgs, passMediaURLs("", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := marshalRequest("m", 64, sampling{}, resolved, nil, ToolChoiceAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req struct {
+		Messages []struct {
+			Content []json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		t.Fatalf("decode %s: %v", payload, err)
+	}
+	if len(req.Messages) != 1 || len(req.Messages[0].Content) != 3 {
+		t.Fatalf("unexpected messages: %s", payload)
+	}
+	want := []string{
+		`{"type":"text","text":"what is this?"}`,
+		`{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}`,
+		`{"type":"file","file":{"filename":"report.pdf","file_data":"https://example.com/files/report.pdf?v=2"}}`,
+	}
+	for i, w := range want {
+		if got := string(req.Messages[0].Content[i]); got != w {
+			t.Fatalf("content[%d] = %s, want %s", i, got, w)
+		}
+	}
+}
+
+func TestDownloadedMediaIsSentAsDataURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png; charset=binary")
+		_, _ = w.Write([]byte("png"))
+	}))
+	defer srv.Close()
+
+	msgs := mediaMessages(model.ImageURLPart{URL: srv.URL + "/cat"})
+	resolved, err := resolveMediaURLs(context.Background(), srv.Client(), msgs, passMediaURLs("download", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, ok := resolved[0].Parts[1].(model.BinaryPart)
+	if !ok || bin.MimeType != "image/png" || string(bin.Data) != "png" {
+		t.Fatalf("unexpected downloaded part: %#v", resolved[0].Parts[1])
+	}
+	if _, ok := msgs[0].Parts[1].(model.ImageURLPart); !ok {
+		t.Fatal("download modified the caller's messages")
+	}
+	payload, err := marshalRequest("m", 64, sampling{}, resolved, nil, ToolChoiceAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(payload), `{"type":"image_url","image_url":{"url":"data:image/png;base64,cG5n"}}`) {
+		t.Fatalf("missing data URL block: %s", payload)
+	}
+	payload, err = marshalCodexResponsesRequest("m", 0, "", sampling{}, resolved, nil, ToolChoiceAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(payload), `"content":[{"type":"input_text","text":"what is this?"},{"type":"input_image","image_url":"data:image/png;base64,cG5n"}]`) {
+		t.Fatalf("missing responses image block: %s", payload)
+	}
+
+	_, err = resolveMediaURLs(context.Background(), srv.Client(), mediaMessages(model.ImageURLPart{URL: srv.URL + "/missing"}), false)
+	if err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Fatalf("expected download error, got %v", err)
+	}
+}
+
+func TestResponsesEncodesDocumentURLAsInputFile(t *testing.T) {
+	payload, err := marshalCodexResponsesRequest("m", 0, "", sampling{}, mediaMessages(
+		model.ImageURLPart{URL: "https://example.com/report.pdf", MimeType: "application/pdf"},
+	), nil, ToolChoiceAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(payload), `{"type":"input_file","filename":"report.pdf","file_url":"https://example.com/report.pdf"}`) {
+		t.Fatalf("missing input_file block: %s", payload)
+	}
+}
+
+func pngBytes(t *testing.T, w, h int, noise bool) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+		if noise {
+			img.Pix[i] = byte(rng.Uint32())
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEncodersSendPNGAlongsideText(t *testing.T) {
+	data := pngBytes(t, 4, 4, false)
+	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{
+		model.TextPart{Text: "what is in"},
+		model.BinaryPart{MimeType: "image/png", Data: data},
+		model.TextPart{Text: " this picture?"},
+		model.BinaryPart{MimeType: "audio/ogg", Data: []byte("ogg")},
+	}}}
+	dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(data)
+
+	payload, err := marshalRequest("m", 64, sampling{}, msgs, nil, ToolChoiceAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chat struct {
+		Messages []struct {
+			Content []openRouterContent `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(payload, &chat); err != nil {
+		t.Fatalf("decode %s: %v", payload, err)
+	}
+	content := chat.Messages[0].Content
+	if len(content) != 2 || content[0].Type != "text" || content[1].Type != "image_url" || content[1].ImageURL.URL != dataURL {
+		t.Fatalf("unexpected chat content: %s", payload)
+	}
+	if content[0].Text != "what is in this picture?\n[audio/ogg attachment omitted: not supported by this backend]" {
+		t.Fatalf("unexpected chat text: %q", content[0].Text)
+	}
+
+	payload, err = marshalCodexResponsesRequest("m", 0, "", sampling{}, msgs, nil, ToolChoiceAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var responses codexResponsesRequest
+	if err := json.Unmarshal(payload, &responses); err != nil {
+		t.Fatalf("decode %s: %v", payload, err)
+	}
+	got := responses.Input[0].Content
+	if len(got) != 3 || got[1].Type != "input_image" || got[1].ImageURL != dataURL || !strings.Contains(got[2].Text, "audio/ogg attachment omitted") {
+		t.Fatalf("unexpected responses content: %s", payload)
+	}
+}
+
+func TestResolveMediaShrinksLargeImages(t *testing.T) {
+	big := pngBytes(t, 2600, 1300, true)
+	if len(big) <= mediaInlineMaxBytes {
+		t.Fatalf("fixture is only %d bytes", len(big))
+	}
+	msgs := mediaMessages(
+		model.BinaryPart{MimeType: "image/png", Data: big},
+		model.BinaryPart{MimeType: "image/webp", Data: make([]byte, mediaInlineMaxBytes+1)},
+		model.BinaryPart{MimeType: "application/pdf", Data: make([]byte, mediaInlineMaxBytes+1)},
+	)
+	resolved, err := resolveMediaURLs(context.Background(), http.DefaultClient, msgs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, ok := resolved[0].Parts[1].(model.BinaryPart)
+	if !ok || small.MimeType != "image/jpeg" || len(small.Data) > mediaInlineMaxBytes {
+		t.Fatalf("image not shrunk: %T", resolved[0].Parts[1])
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(small.Data))
+	if err != nil || cfg.Width != mediaImageMaxSide || cfg.Height != mediaImageMaxSide/2 {
+		t.Fatalf("unexpected shrunk image: %+v %v", cfg, err)
+	}
+	for i, mimeType := range []string{"image/webp", "application/pdf"} {
+		note, ok := resolved[0].Parts[2+i].(model.TextPart)
+		if !ok || !strings.HasPrefix(note.Text, "["+mimeType+" attachment omitted:") {
+			t.Fatalf("oversized %s not replaced by a note: %#v", mimeType, resolved[0].Parts[2+i])
+		}
+	}
+	if _, ok := msgs[0].Parts[1].(model.BinaryPart); !ok || len(msgs[0].Parts[1].(model.BinaryPart).Data) != len(big) {
+		t.Fatal("shrinking modified the caller's messages")
+	}
+}
+
+func TestFitMediaRejectsHugeDimensionsWithoutDecoding(t *testing.T) {
+	data := pngBytes(t, 4, 4, false)
+	// Declare 20000x20000 pixels in the IHDR chunk and fix its checksum;
+	// the padding takes the file over the inline limit.
+	binary.BigEndian.PutUint32(data[16:], 20000)
+	binary.BigEndian.PutUint32(data[20:], 20000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	data = append(data, make([]byte, mediaInlineMaxBytes)...)
+
+	got := PrepareMedia(context.Background(), http.DefaultClient, []model.MessagePart{model.TextPart{Text: "look"}, model.BinaryPart{MimeType: "image/png", Data: data}}, false)
+	note, ok := got[1].(model.TextPart)
+	if !ok || !strings.Contains(note.Text, "20000x20000 pixels") {
+		t.Fatalf("huge image not replaced by a note: %#v", got[1])
+	}
+	if got[0] != (model.TextPart{Text: "look"}) {
+		t.Fatalf("text part changed: %#v", got[0])
+	}
+}
+
+func TestPrepareMediaDownloadsOnceAndKeepsBytes(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("png"))
+	}))
+	defer srv.Close()
+
+	parts := []model.MessagePart{model.ImageURLPart{URL: srv.URL + "/cat"}, model.ImageURLPart{URL: srv.URL + "/missing"}}
+	if got := PrepareMedia(context.Background(), srv.Client(), parts, false); hits.Load() != 0 || len(got[0].(model.ImageURLPart).Data) != 0 {
+		t.Fatalf("fetched media without download: %#v", got)
+	}
+	got := PrepareMedia(context.Background(), srv.Client(), parts, true)
+	if u := got[0].(model.ImageURLPart); u.URL != srv.URL+"/cat" || u.MimeType != "image/png" || string(u.Data) != "png" {
+		t.Fatalf("downloaded part = %#v", u)
+	}
+	if u := got[1].(model.ImageURLPart); len(u.Data) != 0 {
+		t.Fatalf("failed download kept data: %#v", u)
+	}
+
+	hits.Store(0)
+	resolved, err := resolveMediaURLs(context.Background(), srv.Client(), mediaMessages(got[0]), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bin, ok := resolved[0].Parts[1].(model.BinaryPart); !ok || string(bin.Data) != "png" || hits.Load() != 0 {
+		t.Fatalf("stored bytes not reused: %#v (%d requests)", resolved[0].Parts[1], hits.Load())
+	}
+	resolved, err = resolveMediaURLs(context.Background(), srv.Client(), mediaMessages(got[0]), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, ok := resolved[0].Parts[1].(model.ImageURLPart); !ok || u.URL != srv.URL+"/cat" {
+		t.Fatalf("pass mode did not keep the URL: %#v", resolved[0].Parts[1])
+	}
+}
+
+func TestDownloadsMedia(t *testing.T) {
+	cases := []struct {
+		cfg  config.ProviderConfig
+		want bool
+	}{
+		{config.ProviderConfig{Backend: "openrouter"}, false},
+		{config.ProviderConfig{Backend: "lmstudio"}, true},
+		{config.ProviderConfig{Backend: "ollama", MediaURLs: "pass"}, true},
+		{config.ProviderConfig{Backend: "codex", MediaURLs: "download"}, true},
+		{config.ProviderConfig{Backend: "lmstudio", MediaURLs: "pass"}, false},
+		{config.ProviderConfig{Backend: "anthropic", Fallbacks: []config.ProviderConfig{{Backend: "lmstudio"}}}, true},
+	}
+	for _, c := range cases {
+		if got := DownloadsMedia(c.cfg); got != c.want {
+			t.Fatalf("DownloadsMedia(%+v) = %v, want %v", c.cfg, got, c.want)
+		}
+	}
+}
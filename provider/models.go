@@ -18,6 +18,12 @@ type modelsResponse struct {
 	} `json:"data"`
 }
 
+type ollamaModelsResponse struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
+
 type codexModelsResponse struct {
 	Models []struct {
 		Slug string `json:"slug"`
@@ -78,10 +84,17 @@ func modelsURL(cfg config.ProviderConfig) (string, error) {
 			base = openRouterDefaultBaseURL
 		case "codex":
 			base = codexDefaultBaseURL
+		case "anthropic":
+			base = anthropicDefaultBaseURL
+		case "ollama":
+			base = ollamaDefaultBaseURL
 		default:
 			return "", fmt.Errorf("unknown backend %q", cfg.Backend)
 		}
 	}
+	if cfg.Backend == "ollama" {
+		return base + "/api/tags", nil
+	}
 	return base + "/models", nil
 }
 
@@ -89,6 +102,10 @@ func applyModelHeaders(req *http.Request, cfg config.ProviderConfig) {
 	if cfg.Backend == "openrouter" || cfg.Backend == "codex" {
 		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
 	}
+	if cfg.Backend == "anthropic" {
+		req.Header.Set("x-api-key", cfg.APIKey)
+		req.Header.Set("anthropic-version", anthropicVersion)
+	}
 	if cfg.Backend == "openrouter" {
 		req.Header.Set("HTTP-Referer", openRouterReferer)
 		req.Header.Set("X-Title", openRouterTitle)
@@ -111,6 +128,19 @@ func decodeModelIDs(data []byte, backend string) ([]string, error) {
 			return uniqueSortedCodexModelIDs(codexBody), nil
 		}
 	}
+	if backend == "ollama" {
+		var ollamaBody ollamaModelsResponse
+		if err := json.Unmarshal(data, &ollamaBody); err != nil {
+			return nil, err
+		}
+		var body modelsResponse
+		for _, m := range ollamaBody.Models {
+			body.Data = append(body.Data, struct {
+				ID string `json:"id"`
+			}{m.Name})
+		}
+		return uniqueSortedModelIDs(body), nil
+	}
 	var body modelsResponse
 	if err := json.Unmarshal(data, &body); err != nil {
 		return nil, err
@@ -132,3 +132,21 @@ func TestDiscoverModelIDsCodexResponseShape(t *testing.T) {
 		t.Fatalf("unexpected models: %#v", models)
 	}
 }
+
+func TestDiscoverModelIDsOllama(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"models":[{"name":"qwen3:8b"},{"name":"llama3.2:latest"}]}`))
+	}))
+	defer srv.Close()
+
+	models, err := DiscoverModelIDs(context.Background(), config.ProviderConfig{Backend: "ollama", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("discover models: %v", err)
+	}
+	if len(models) != 2 || models[0] != "llama3.2:latest" || models[1] != "qwen3:8b" {
+		t.Fatalf("unexpected models: %#v", models)
+	}
+}
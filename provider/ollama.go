@@ -0,0 +1,297 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+)
+
+const ollamaDefaultBaseURL = "http://127.0.0.1:11434"
+
+// Ollama speaks the native /api/chat NDJSON stream. Its OpenAI-compatible
+// layer drops tool call details while streaming; the native one delivers
+// each tool call whole in a single chunk.
+type Ollama struct {
+	baseURL   string
+	apiKey    string
+	model     string
+	maxTokens int
+	window    int
+	prices    modelPrices
+	reasoning bool
+	sampling  sampling
+	retry     retryPolicy
+	idle      time.Duration
+	client    *http.Client
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []ollamaTool    `json:"tools,omitempty"`
+	Stream   bool            `json:"stream"`
+	Options  ollamaOptions   `json:"options"`
+}
+
+type ollamaOptions struct {
+	NumPredict int `json:"num_predict,omitempty"`
+	NumCtx     int `json:"num_ctx,omitempty"`
+	sampling
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	ID       string             `json:"id,omitempty"`
+	Function ollamaToolFunction `json:"function"`
+}
+
+type ollamaToolFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type ollamaTool struct {
+	Type     string                 `json:"type"`
+	Function ollamaToolFunctionSpec `json:"function"`
+}
+
+type ollamaToolFunctionSpec struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+type ollamaChunk struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func NewOllama(cfg config.ProviderConfig) *Ollama {
+	base := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if base == "" {
+		base = ollamaDefaultBaseURL
+	}
+	maxTokens := cfg.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+	return &Ollama{
+		baseURL:   base,
+		apiKey:    cfg.APIKey,
+		model:     cfg.Model,
+		maxTokens: maxTokens,
+		window:    cfg.ContextWindow,
+		prices:    configPrices(cfg),
+		reasoning: cfg.SendReasoning,
+		sampling:  samplingFromConfig(cfg),
+		retry:     newRetryPolicy(cfg),
+		idle:      streamIdleTimeout(cfg),
+		client:    &http.Client{},
+	}
+}
+
+func (o *Ollama) Model() ModelInfo {
+	return o.prices.modelInfo(ModelInfo{
+		ID:            o.model,
+		Name:          o.model,
+		ContextWindow: o.window,
+		MaxOutput:     o.maxTokens,
+	})
+}
+
+func (o *Ollama) Stream(ctx context.Context, messages []model.Message, tools []ToolDef, choice ToolChoice) <-chan ProviderEvent {
+	out := make(chan ProviderEvent, 16)
+	go o.stream(ctx, messages, tools, choice, out)
+	return out
+}
+
+func (o *Ollama) stream(ctx context.Context, messages []model.Message, tools []ToolDef, choice ToolChoice, out chan<- ProviderEvent) {
+	defer close(out)
+	// Ollama only takes inline base64 images, so URLs are always fetched.
+	messages, err := resolveMediaURLs(ctx, o.client, messages, false)
+	if err != nil {
+		out <- errorEvent(err)
+		return
+	}
+	// /api/chat has no tool_choice; none is kept by not offering any tools.
+	switch effectiveToolChoice("ollama", choice, tools) {
+	case ToolChoiceAuto:
+	case ToolChoiceNone:
+		tools = nil
+	default:
+		unsupportedToolChoice("ollama", choice)
+	}
+	body := ollamaRequest{
+		Model:    o.model,
+		Messages: encodeOllamaMessages(outgoingHistory(messages, o.reasoning)),
+		Tools:    encodeOllamaTools(tools),
+		Stream:   true,
+		Options:  ollamaOptions{NumPredict: requestMaxTokens(ctx, o.maxTokens), NumCtx: o.window, sampling: o.sampling},
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		out <- errorEvent(err)
+		return
+	}
+	resp, err := withRetry(ctx, o.retry, func() (*http.Response, error) {
+		return o.doPost(ctx, payload)
+	})
+	if err != nil {
+		out <- errorEvent(err)
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		out <- errorEvent(readStatusError("ollama", resp))
+		return
+	}
+	parseOllamaStream(watchIdle(resp.Body, o.idle), out)
+}
+
+func (o *Ollama) doPost(ctx context.Context, payload []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/api/chat", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-ndjson")
+	return o.client.Do(req)
+}
+
+// encodeOllamaMessages maps the thread to Ollama chat messages. The system
+// prompt keeps the system role, and each tool result becomes its own tool
+// message named after the call it answers, since Ollama has no call IDs.
+func encodeOllamaMessages(messages []model.Message) []ollamaMessage {
+	names := map[string]string{}
+	out := make([]ollamaMessage, 0, len(messages))
+	for i, m := range messages {
+		msg := ollamaMessage{Role: string(m.Role)}
+		if i == 0 && strings.HasPrefix(m.ID, "system-") {
+			msg.Role = "system"
+		}
+		for _, part := range m.Parts {
+			switch p := part.(type) {
+			case model.TextPart:
+				msg.Content += p.Text
+			case model.ReasoningPart:
+				msg.Thinking += p.Text
+			case model.ToolCallPart:
+				names[p.ID] = p.Name
+				msg.ToolCalls = append(msg.ToolCalls, encodeOllamaToolCall(p))
+			case model.ToolResultPart:
+				out = append(out, ollamaMessage{Role: string(model.RoleTool), Content: p.Content, ToolName: names[p.ToolCallID]})
+			case model.BinaryPart:
+				if isImageType(p.MimeType) {
+					msg.Images = append(msg.Images, base64.StdEncoding.EncodeToString(p.Data))
+				} else {
+					msg.Content += "\n" + omittedMedia(p.MimeType, "not supported by this backend")
+				}
+			case model.ImageURLPart, model.FinishPart:
+			default:
+				panic(fmt.Sprintf("unknown message part type: %T", part))
+			}
+		}
+		if msg.Content != "" || msg.Thinking != "" || len(msg.Images) > 0 || len(msg.ToolCalls) > 0 {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+func encodeOllamaToolCall(p model.ToolCallPart) ollamaToolCall {
+	args := p.Parameters
+	if len(bytes.TrimSpace(args)) == 0 {
+		args = json.RawMessage("{}")
+	}
+	return ollamaToolCall{Function: ollamaToolFunction{Name: p.Name, Arguments: args}}
+}
+
+func encodeOllamaTools(tools []ToolDef) []ollamaTool {
+	out := make([]ollamaTool, 0, len(tools))
+	for _, t := range tools {
+		out = append(out, ollamaTool{
+			Type:     "function",
+			Function: ollamaToolFunctionSpec{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+		})
+	}
+	return out
+}
+
+// parseOllamaStream translates the NDJSON chunks into provider events. A
+// tool call arrives whole, so it is sent as a start, one delta with all of
+// its arguments, and a stop; calls without an ID are numbered in order.
+func parseOllamaStream(body io.ReadCloser, out chan<- ProviderEvent) {
+	defer body.Close()
+	s := bufio.NewScanner(body)
+	s.Buffer(make([]byte, 0, 64*1024), 8*1024*1024)
+	calls := 0
+	for s.Scan() {
+		line := bytes.TrimSpace(s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			continue
+		}
+		if chunk.Error != "" {
+			out <- errorEvent(&responseError{message: chunk.Error})
+			return
+		}
+		if chunk.Message.Thinking != "" {
+			out <- ProviderEvent{Type: EventThinkingDelta, Delta: chunk.Message.Thinking}
+		}
+		if chunk.Message.Content != "" {
+			out <- ProviderEvent{Type: EventContentDelta, Delta: chunk.Message.Content}
+		}
+		for _, c := range chunk.Message.ToolCalls {
+			id := c.ID
+			if id == "" {
+				id = fmt.Sprintf("call_%d", calls)
+			}
+			calls++
+			args := string(bytes.TrimSpace(c.Function.Arguments))
+			if args == "" || args == "null" {
+				args = "{}"
+			}
+			out <- ProviderEvent{Type: EventToolUseStart, ToolCallID: id, ToolName: c.Function.Name}
+			out <- ProviderEvent{Type: EventToolUseDelta, ToolCallID: id, ToolName: c.Function.Name, Delta: args}
+			out <- ProviderEvent{Type: EventToolUseStop, ToolCallID: id, ToolName: c.Function.Name}
+		}
+		if chunk.Done {
+			out <- ProviderEvent{Type: EventComplete, Usage: &UsageInfo{
+				PromptTokens:     chunk.PromptEvalCount,
+				CompletionTokens: chunk.EvalCount,
+			}}
+			return
+		}
+	}
+	if err := s.Err(); err != nil {
+		out <- errorEvent(err)
+		return
+	}
+	out <- errorEvent(fmt.Errorf("ollama stream ended before done"))
+}
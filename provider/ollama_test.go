@@ -0,0 +1,183 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+)
+
+func ollamaFixture(t *testing.T, name string) []ProviderEvent {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(chan ProviderEvent, 64)
+	parseOllamaStream(f, out)
+	close(out)
+	return collectProviderEvents(t, out)
+}
+
+func TestOllamaStreamFixtureText(t *testing.T) {
+	ev := ollamaFixture(t, "ollama_text.ndjson")
+	if len(ev) != 4 {
+		t.Fatalf("expected 4 events, got %#v", ev)
+	}
+	if ev[0].Type != EventThinkingDelta || ev[0].Delta != "The user greets me." {
+		t.Fatalf("unexpected thinking event: %#v", ev[0])
+	}
+	if ev[1].Delta+ev[2].Delta != "Hello!" {
+		t.Fatalf("unexpected content: %#v %#v", ev[1], ev[2])
+	}
+	if ev[3].Type != EventComplete || ev[3].Usage == nil || ev[3].Usage.PromptTokens != 26 || ev[3].Usage.CompletionTokens != 9 {
+		t.Fatalf("unexpected completion: %#v", ev[3])
+	}
+}
+
+func TestOllamaStreamFixtureToolCalls(t *testing.T) {
+	ev := ollamaFixture(t, "ollama_tool_calls.ndjson")
+	want := []struct {
+		typ   ProviderEventType
+		id    string
+		delta string
+	}{
+		{EventToolUseStart, "call_0", ""},
+		{EventToolUseDelta, "call_0", `{"path":"a.txt"}`},
+		{EventToolUseStop, "call_0", ""},
+		{EventToolUseStart, "call_1", ""},
+		{EventToolUseDelta, "call_1", `{}`},
+		{EventToolUseStop, "call_1", ""},
+		{EventComplete, "", ""},
+	}
+	if len(ev) != len(want) {
+		t.Fatalf("expected %d events, got %#v", len(want), ev)
+	}
+	for i, w := range want {
+		if ev[i].Type != w.typ || ev[i].ToolCallID != w.id || ev[i].Delta != w.delta {
+			t.Fatalf("event %d = %#v, want %v", i, ev[i], w)
+		}
+	}
+	if ev[0].ToolName != "read" || ev[3].ToolName != "ls" {
+		t.Fatalf("unexpected tool names: %q %q", ev[0].ToolName, ev[3].ToolName)
+	}
+	if u := ev[6].Usage; u.PromptTokens != 120 || u.CompletionTokens != 31 {
+		t.Fatalf("unexpected usage: %#v", u)
+	}
+}
+
+func TestOllamaStreamErrorAndTruncation(t *testing.T) {
+	out := make(chan ProviderEvent, 8)
+	parseOllamaStream(io.NopCloser(strings.NewReader(`{"error":"model \"x\" not found"}`+"\n")), out)
+	close(out)
+	ev := collectProviderEvents(t, out)
+	if len(ev) != 1 || ev[0].Type != EventError || !strings.Contains(ev[0].Error.Error(), "not found") {
+		t.Fatalf("unexpected events: %#v", ev)
+	}
+
+	out = make(chan ProviderEvent, 8)
+	parseOllamaStream(io.NopCloser(strings.NewReader(`{"message":{"content":"par"},"done":false}`+"\n")), out)
+	close(out)
+	ev = collectProviderEvents(t, out)
+	if len(ev) != 2 || ev[1].Type != EventError || !strings.Contains(ev[1].Error.Error(), "before done") {
+		t.Fatalf("unexpected events: %#v", ev)
+	}
+}
+
+func TestOllamaEncodesHistory(t *testing.T) {
+	msgs := []model.Message{
+		{ID: "system-1", Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "be brief"}}},
+		{Role: model.RoleUser, Parts: []model.MessagePart{
+			model.TextPart{Text: "what is this?"},
+			model.BinaryPart{MimeType: "image/png", Data: []byte{1, 2}},
+		}},
+		{Role: model.RoleAssistant, Parts: []model.MessagePart{
+			model.ReasoningPart{Text: "look it up"},
+			model.ToolCallPart{ID: "abcd1234_call_0", Name: "read", Parameters: json.RawMessage(`{"path":"a.txt"}`)},
+		}},
+		{Role: model.RoleTool, Parts: []model.MessagePart{model.ToolResultPart{ToolCallID: "abcd1234_call_0", Content: "a cat"}}},
+	}
+	got := encodeOllamaMessages(msgs)
+	if len(got) != 4 {
+		t.Fatalf("expected 4 messages, got %#v", got)
+	}
+	if got[0].Role != "system" || got[0].Content != "be brief" {
+		t.Fatalf("unexpected system message: %#v", got[0])
+	}
+	if got[1].Role != "user" || len(got[1].Images) != 1 || got[1].Images[0] != "AQI=" {
+		t.Fatalf("unexpected user message: %#v", got[1])
+	}
+	if got[2].Thinking != "look it up" || len(got[2].ToolCalls) != 1 || string(got[2].ToolCalls[0].Function.Arguments) != `{"path":"a.txt"}` {
+		t.Fatalf("unexpected assistant message: %#v", got[2])
+	}
+	if got[3].Role != "tool" || got[3].ToolName != "read" || got[3].Content != "a cat" {
+		t.Fatalf("unexpected tool message: %#v", got[3])
+	}
+}
+
+func TestOllamaStreamRequest(t *testing.T) {
+	var req ollamaRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" || r.Header.Get("Authorization") != "" {
+			t.Fatalf("unexpected request: %s auth=%q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		b, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(b, &req); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		f, err := os.ReadFile("testdata/ollama_text.ndjson")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write(f)
+	}))
+	defer srv.Close()
+
+	p := NewOllama(config.ProviderConfig{Backend: "ollama", BaseURL: srv.URL, Model: "qwen3:8b", MaxTokens: 256, ContextWindow: 32768})
+	tools := []ToolDef{{Name: "read", Parameters: json.RawMessage(`{"type":"object"}`)}}
+	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hi"}}}}
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, tools, ToolChoiceAuto))
+	if len(ev) != 4 || ev[3].Type != EventComplete {
+		t.Fatalf("unexpected events: %#v", ev)
+	}
+	if req.Model != "qwen3:8b" || !req.Stream || req.Options.NumPredict != 256 || req.Options.NumCtx != 32768 {
+		t.Fatalf("unexpected request: %#v", req)
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Type != "function" || req.Tools[0].Function.Name != "read" {
+		t.Fatalf("unexpected tools: %#v", req.Tools)
+	}
+}
+
+func TestOllamaToolChoiceNoneDropsTools(t *testing.T) {
+	var req ollamaRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(b, &req); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		f, err := os.ReadFile("testdata/ollama_text.ndjson")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write(f)
+	}))
+	defer srv.Close()
+
+	p := NewOllama(config.ProviderConfig{Backend: "ollama", BaseURL: srv.URL, Model: "qwen3:8b"})
+	tools := []ToolDef{{Name: "read", Parameters: json.RawMessage(`{"type":"object"}`)}}
+	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hi"}}}}
+	for _, c := range []ToolChoice{ToolChoiceNone, "read"} {
+		req = ollamaRequest{}
+		_ = collectProviderEvents(t, p.Stream(context.Background(), msgs, tools, c))
+		if want := c != ToolChoiceNone; (len(req.Tools) == 1) != want {
+			t.Fatalf("choice %q: tools sent = %#v", c, req.Tools)
+		}
+	}
+}
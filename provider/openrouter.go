@@ -5,9 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/agusx1211/miclaw/config"
 	"github.com/agusx1211/miclaw/model"
@@ -25,15 +26,50 @@ type OpenRouter struct {
 	apiKey    string
 	model     string
 	maxTokens int
+	window    int
+	reasoning bool
+	responses bool
+	// effort is the reasoning effort sent with the responses API.
+	effort    string
+	mediaURLs bool
+	sampling  sampling
+	retry     retryPolicy
+	idle      time.Duration
 	client    *http.Client
+	// mu guards prices, which watchPrices completes in the background from
+	// the model list kept in pricesCache, retrying after pricesRetry.
+	mu          sync.Mutex
+	prices      modelPrices
+	pricesCache string
+	pricesRetry time.Duration
 }
 
 type openRouterRequest struct {
-	Model     string              `json:"model"`
-	Messages  []openRouterMessage `json:"messages"`
-	Tools     []openRouterTool    `json:"tools,omitempty"`
-	Stream    bool                `json:"stream"`
-	MaxTokens int                 `json:"max_tokens"`
+	Model         string              `json:"model"`
+	Messages      []openRouterMessage `json:"messages"`
+	Tools         []openRouterTool    `json:"tools,omitempty"`
+	Stream        bool                `json:"stream"`
+	StreamOptions openAIStreamOptions `json:"stream_options"`
+	MaxTokens     int                 `json:"max_tokens"`
+	ToolChoice    any                 `json:"tool_choice,omitempty"`
+	sampling
+}
+
+// openAIFunctionChoice is the chat-completions tool_choice that forces one
+// function.
+type openAIFunctionChoice struct {
+	Type     string             `json:"type"`
+	Function openAIFunctionName `json:"function"`
+}
+
+type openAIFunctionName struct {
+	Name string `json:"name"`
+}
+
+// openAIStreamOptions asks for a final chunk carrying the request's usage,
+// sent after the finish_reason chunk with an empty choices array.
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type openRouterMessage struct {
@@ -41,6 +77,25 @@ type openRouterMessage struct {
 	Content    string               `json:"content,omitempty"`
 	ToolCalls  []openRouterToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string               `json:"tool_call_id,omitempty"`
+	// Media holds image and document blocks. When set, content is sent as
+	// an array: the text first, then the media.
+	Media []openRouterContent `json:"-"`
+}
+
+type openRouterContent struct {
+	Type     string              `json:"type"`
+	Text     string              `json:"text,omitempty"`
+	ImageURL *openRouterImageURL `json:"image_url,omitempty"`
+	File     *openRouterFile     `json:"file,omitempty"`
+}
+
+type openRouterImageURL struct {
+	URL string `json:"url"`
+}
+
+type openRouterFile struct {
+	Filename string `json:"filename"`
+	FileData string `json:"file_data"`
 }
 
 type openRouterToolCall struct {
@@ -80,7 +135,19 @@ func NewOpenRouter(cfg config.ProviderConfig) *OpenRouter {
 		apiKey:    cfg.APIKey,
 		model:     cfg.Model,
 		maxTokens: maxTokens,
+		window:    cfg.ContextWindow,
+		prices:    configPrices(cfg),
+		reasoning: cfg.SendReasoning,
+		responses: responsesStyle(cfg.APIStyle, false),
+		effort:    strings.TrimSpace(cfg.ThinkingEffort),
+		mediaURLs: passMediaURLs(cfg.MediaURLs, true),
+		sampling:  samplingFromConfig(cfg),
+		retry:     newRetryPolicy(cfg),
+		idle:      streamIdleTimeout(cfg),
 		client:    &http.Client{},
+		// pricesRetry is the first delay before a failed price lookup is
+		// tried again; it doubles up to openRouterPricesTTL.
+		pricesRetry: openRouterPricesRetry,
 	}
 
 	return p
@@ -88,32 +155,41 @@ func NewOpenRouter(cfg config.ProviderConfig) *OpenRouter {
 
 func (o *OpenRouter) Model() ModelInfo {
 
-	info := ModelInfo{
-		ID:        o.model,
-		Name:      o.model,
-		MaxOutput: o.maxTokens,
-	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	info := o.prices.modelInfo(ModelInfo{
+		ID:            o.model,
+		Name:          o.model,
+		ContextWindow: o.window,
+		MaxOutput:     o.maxTokens,
+	})
 
 	return info
 }
 
-func (o *OpenRouter) Stream(ctx context.Context, messages []model.Message, tools []ToolDef) <-chan ProviderEvent {
+func (o *OpenRouter) Stream(ctx context.Context, messages []model.Message, tools []ToolDef, choice ToolChoice) <-chan ProviderEvent {
 
 	out := make(chan ProviderEvent, 16)
 
-	go o.stream(ctx, messages, tools, out)
+	go o.stream(ctx, messages, tools, choice, out)
 	return out
 }
 
-func (o *OpenRouter) stream(ctx context.Context, messages []model.Message, tools []ToolDef, out chan<- ProviderEvent) {
+func (o *OpenRouter) stream(ctx context.Context, messages []model.Message, tools []ToolDef, choice ToolChoice, out chan<- ProviderEvent) {
 
 	defer close(out)
-	payload, err := marshalRequest(o.model, o.maxTokens, messages, tools)
+	messages, err := resolveMediaURLs(ctx, o.client, messages, o.mediaURLs)
+	if err != nil {
+		out <- errorEvent(err)
+		return
+	}
+	choice = effectiveToolChoice("openrouter", choice, tools)
+	payload, path, err := marshalStyledRequest(o.responses, o.model, requestMaxTokens(ctx, o.maxTokens), o.effort, o.sampling, outgoingHistory(messages, o.reasoning), tools, choice)
 	if err != nil {
 		out <- errorEvent(err)
 		return
 	}
-	resp, err := o.post(ctx, payload)
+	resp, err := o.post(ctx, path, payload)
 	if err != nil {
 		out <- errorEvent(err)
 		return
@@ -122,24 +198,40 @@ func (o *OpenRouter) stream(ctx context.Context, messages []model.Message, tools
 		out <- errorEvent(readStatusError("openrouter", resp))
 		return
 	}
-	for e := range parseSSEStream(resp.Body) {
+	for e := range parseSSEStream(watchIdle(resp.Body, o.idle)) {
 		out <- e
 	}
 }
 
-func marshalRequest(modelID string, maxTokens int, messages []model.Message, tools []ToolDef) ([]byte, error) {
+func marshalRequest(modelID string, maxTokens int, sp sampling, messages []model.Message, tools []ToolDef, choice ToolChoice) ([]byte, error) {
 
 	body := openRouterRequest{
-		Model:     modelID,
-		Messages:  encodeMessages(messages),
-		Tools:     encodeTools(tools),
-		Stream:    true,
-		MaxTokens: maxTokens,
+		Model:         modelID,
+		Messages:      encodeMessages(messages),
+		Tools:         encodeTools(tools),
+		Stream:        true,
+		StreamOptions: openAIStreamOptions{IncludeUsage: true},
+		MaxTokens:     maxTokens,
+		ToolChoice:    chatToolChoice(choice),
+		sampling:      sp,
 	}
 
 	return json.Marshal(body)
 }
 
+// chatToolChoice encodes choice as chat-completions tool_choice; auto is
+// left out, which is the API's default.
+func chatToolChoice(choice ToolChoice) any {
+
+	switch choice {
+	case ToolChoiceAuto:
+		return nil
+	case ToolChoiceNone, ToolChoiceRequired:
+		return string(choice)
+	}
+	return openAIFunctionChoice{Type: "function", Function: openAIFunctionName{Name: choice.Tool()}}
+}
+
 func encodeMessages(messages []model.Message) []openRouterMessage {
 
 	out := make([]openRouterMessage, 0, len(messages))
@@ -157,7 +249,7 @@ func encodeMessage(m model.Message) []openRouterMessage {
 	for _, p := range m.Parts {
 		out, msg = encodePart(out, msg, p)
 	}
-	if msg.Content != "" || len(msg.ToolCalls) > 0 {
+	if msg.Content != "" || len(msg.ToolCalls) > 0 || len(msg.Media) > 0 {
 		out = append(out, msg)
 	}
 
@@ -175,14 +267,21 @@ func encodePart(out []openRouterMessage, msg openRouterMessage, part model.Messa
 		msg.ToolCalls = append(msg.ToolCalls, encodeToolCall(p))
 	case model.ToolResultPart:
 		role := msg.Role
-		if msg.Content != "" || len(msg.ToolCalls) > 0 {
+		if msg.Content != "" || len(msg.ToolCalls) > 0 || len(msg.Media) > 0 {
 			out = append(out, msg)
 		}
 		out = append(out, openRouterMessage{Role: string(model.RoleTool), ToolCallID: p.ToolCallID, Content: p.Content})
 		msg = openRouterMessage{Role: role}
 	case model.FinishPart:
-	case model.BinaryPart:
-		panic("binary part is not supported for openrouter")
+	case model.BinaryPart, model.ImageURLPart:
+		if !sendableMedia(p) {
+			if msg.Content != "" {
+				msg.Content += "\n"
+			}
+			msg.Content += omittedMedia(mediaType(p), "not supported by this backend")
+			break
+		}
+		msg.Media = append(msg.Media, encodeMedia(p))
 	default:
 		panic(fmt.Sprintf("unknown message part type: %T", part))
 	}
@@ -190,6 +289,33 @@ func encodePart(out []openRouterMessage, msg openRouterMessage, part model.Messa
 	return out, msg
 }
 
+func (m openRouterMessage) MarshalJSON() ([]byte, error) {
+
+	type plain openRouterMessage
+	if len(m.Media) == 0 {
+		return json.Marshal(plain(m))
+	}
+	content := make([]openRouterContent, 0, len(m.Media)+1)
+	if m.Content != "" {
+		content = append(content, openRouterContent{Type: "text", Text: m.Content})
+	}
+	return json.Marshal(struct {
+		plain
+		Content []openRouterContent `json:"content"`
+	}{plain(m), append(content, m.Media...)})
+}
+
+// encodeMedia turns a binary or URL part into an image_url block, or a file
+// block for documents.
+func encodeMedia(part model.MessagePart) openRouterContent {
+
+	url, mimeType := mediaSource(part)
+	if isImageType(mimeType) {
+		return openRouterContent{Type: "image_url", ImageURL: &openRouterImageURL{URL: url}}
+	}
+	return openRouterContent{Type: "file", File: &openRouterFile{Filename: mediaFilename(url, mimeType), FileData: url}}
+}
+
 func encodeToolCall(p model.ToolCallPart) openRouterToolCall {
 
 	c := openRouterToolCall{
@@ -218,17 +344,17 @@ func encodeTools(tools []ToolDef) []openRouterTool {
 	return out
 }
 
-func (o *OpenRouter) post(ctx context.Context, payload []byte) (*http.Response, error) {
+func (o *OpenRouter) post(ctx context.Context, path string, payload []byte) (*http.Response, error) {
 
-	return withRetry(ctx, 0, func() (*http.Response, error) {
+	return withRetry(ctx, o.retry, func() (*http.Response, error) {
 
-		return o.doPost(ctx, payload)
+		return o.doPost(ctx, path, payload)
 	})
 }
 
-func (o *OpenRouter) doPost(ctx context.Context, payload []byte) (*http.Response, error) {
+func (o *OpenRouter) doPost(ctx context.Context, path string, payload []byte) (*http.Response, error) {
 
-	u := o.baseURL + "/chat/completions"
+	u := o.baseURL + path
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(payload))
 	if err != nil {
 		return nil, err
@@ -252,17 +378,6 @@ func applyHeaders(req *http.Request, apiKey string) {
 
 }
 
-func readStatusError(name string, resp *http.Response) error {
-
-	defer resp.Body.Close()
-	b, _ := io.ReadAll(io.LimitReader(resp.Body, 16*1024))
-	msg := strings.TrimSpace(string(b))
-	if msg == "" {
-		return fmt.Errorf("%s stream failed: status %d", name, resp.StatusCode)
-	}
-	return fmt.Errorf("%s stream failed: status %d: %s", name, resp.StatusCode, msg)
-}
-
 func errorEvent(err error) ProviderEvent {
 
 	return ProviderEvent{Type: EventError, Error: err}
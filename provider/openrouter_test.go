@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"sync"
 	"testing"
@@ -21,12 +22,17 @@ type chatRequest struct {
 	Messages        []chatMessageReq `json:"messages"`
 	Tools           []chatToolReq    `json:"tools"`
 	Stream          bool             `json:"stream"`
+	StreamOptions   *chatStreamOpts  `json:"stream_options"`
 	MaxTokens       int              `json:"max_tokens"`
 	MaxOutputTokens int              `json:"max_output_tokens"`
 	Store           bool             `json:"store"`
 	Reasoning       *chatReasoning   `json:"reasoning"`
 }
 
+type chatStreamOpts struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
 type chatReasoning struct {
 	Effort string `json:"effort"`
 }
@@ -145,10 +151,11 @@ func collectProviderEvents(t *testing.T, ch <-chan ProviderEvent) []ProviderEven
 
 func openRouterProvider(baseURL, apiKey string) *OpenRouter {
 	cfg := config.ProviderConfig{
-		BaseURL:   baseURL,
-		APIKey:    apiKey,
-		Model:     "anthropic/claude-sonnet-4-5",
-		MaxTokens: 128,
+		BaseURL:         baseURL,
+		APIKey:          apiKey,
+		Model:           "anthropic/claude-sonnet-4-5",
+		MaxTokens:       128,
+		RetryBaseMillis: 1,
 	}
 	return NewOpenRouter(cfg)
 }
@@ -166,7 +173,7 @@ func TestOpenRouterStreamSuccessContent(t *testing.T) {
 
 	p := openRouterProvider(srv.URL, "sk-or-test")
 	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hello"}}}}
-	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	if len(ev) != 3 {
 		t.Fatalf("expected 3 events, got %d", len(ev))
 	}
@@ -188,6 +195,59 @@ func TestOpenRouterStreamSuccessContent(t *testing.T) {
 	}
 }
 
+func TestOpenRouterStreamRaisedMaxTokens(t *testing.T) {
+	c := &streamCapture{}
+	srv := openRouterServer(t, c, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+	defer srv.Close()
+
+	p := openRouterProvider(srv.URL, "sk-or-test")
+	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hello"}}}}
+	collectProviderEvents(t, p.Stream(WithMaxTokens(context.Background(), 64), msgs, nil, ToolChoiceAuto))
+	collectProviderEvents(t, p.Stream(WithMaxTokens(context.Background(), 256), msgs, nil, ToolChoiceAuto))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.requests) != 2 || c.requests[0].MaxTokens != 128 || c.requests[1].MaxTokens != 256 {
+		t.Fatalf("expected only a larger limit to apply, got %#v", c.requests)
+	}
+}
+
+func TestOpenRouterStreamReasoningHistory(t *testing.T) {
+	msgs := []model.Message{
+		{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hi"}}},
+		{Role: model.RoleAssistant, Parts: []model.MessagePart{
+			model.ReasoningPart{Text: "thinking. "},
+			model.TextPart{Text: "hello"},
+		}},
+	}
+	for _, tc := range []struct {
+		send bool
+		want string
+	}{
+		{send: true, want: "thinking. hello"},
+		{send: false, want: "hello"},
+	} {
+		c := &streamCapture{}
+		srv := openRouterServer(t, c, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		})
+		p := openRouterProvider(srv.URL, "sk-or-test")
+		p.reasoning = tc.send
+		_ = collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
+		srv.Close()
+		req := c.firstRequest()
+		if len(req.Messages) != 2 || req.Messages[1].Content != tc.want {
+			t.Fatalf("send_reasoning=%v: unexpected messages: %#v", tc.send, req.Messages)
+		}
+	}
+	if _, ok := msgs[1].Parts[0].(model.ReasoningPart); !ok {
+		t.Fatalf("stored history was modified: %#v", msgs[1].Parts)
+	}
+}
+
 func TestOpenRouterStreamAttributionHeaders(t *testing.T) {
 	c := &streamCapture{}
 	srv := openRouterServer(t, c, func(w http.ResponseWriter, _ *http.Request) {
@@ -198,7 +258,7 @@ func TestOpenRouterStreamAttributionHeaders(t *testing.T) {
 
 	p := openRouterProvider(srv.URL, "sk-or-test")
 	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "ping"}}}}
-	_ = collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	_ = collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	if got := c.firstHeader("HTTP-Referer"); got != "https://github.com/agusx1211/miclaw" {
 		t.Fatalf("unexpected HTTP-Referer header: %q", got)
 	}
@@ -217,7 +277,7 @@ func TestOpenRouterStreamAuthorizationHeader(t *testing.T) {
 
 	p := openRouterProvider(srv.URL, "sk-or-abc123")
 	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "ping"}}}}
-	_ = collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+	_ = collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 	if got := c.firstHeader("Authorization"); got != "Bearer sk-or-abc123" {
 		t.Fatalf("unexpected Authorization header: %q", got)
 	}
@@ -249,7 +309,7 @@ func TestOpenRouterStreamToolCall(t *testing.T) {
 		Description: "read a file",
 		Parameters:  json.RawMessage(`{"type":"object","properties":{"path":{"type":"string"}},"required":["path"]}`),
 	}}
-	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, tools))
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, tools, ToolChoiceAuto))
 	if len(ev) != 4 {
 		t.Fatalf("expected 4 events, got %d", len(ev))
 	}
@@ -280,6 +340,36 @@ func TestOpenRouterStreamToolCall(t *testing.T) {
 	}
 }
 
+func TestOpenRouterStreamUsageChunkAfterFinish(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/openrouter_usage.sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &streamCapture{}
+	srv := openRouterServer(t, c, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write(fixture)
+	})
+	defer srv.Close()
+
+	p := openRouterProvider(srv.URL, "sk-or-test")
+	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hello"}}}}
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
+	if len(ev) != 3 || ev[0].Delta != "Hi" || ev[1].Delta != " there" {
+		t.Fatalf("unexpected events: %#v", ev)
+	}
+	u := ev[2].Usage
+	if ev[2].Type != EventComplete || u == nil {
+		t.Fatalf("completion has no usage: %#v", ev[2])
+	}
+	if u.PromptTokens != 1843 || u.CompletionTokens != 4 || u.CacheReadTokens != 1536 {
+		t.Fatalf("unexpected usage: %#v", u)
+	}
+	if req := c.firstRequest(); req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+		t.Fatalf("stream_options.include_usage not requested: %#v", req.StreamOptions)
+	}
+}
+
 func TestOpenRouterStreamErrorResponses(t *testing.T) {
 	cases := []struct {
 		name         string
@@ -289,7 +379,9 @@ func TestOpenRouterStreamErrorResponses(t *testing.T) {
 	}{
 		{name: "unauthorized", status: 401, wantAttempts: 1},
 		{name: "rate-limited", status: 429, retryAfter: "0", wantAttempts: 9},
-		{name: "internal", status: 500, wantAttempts: 1},
+		{name: "bad-request", status: 400, wantAttempts: 1},
+		{name: "internal", status: 500, retryAfter: "0", wantAttempts: defaultRetryAttempts},
+		{name: "unavailable", status: 503, retryAfter: "0", wantAttempts: defaultRetryAttempts},
 	}
 	for _, tc := range cases {
 		tc := tc
@@ -306,7 +398,7 @@ func TestOpenRouterStreamErrorResponses(t *testing.T) {
 
 			p := openRouterProvider(srv.URL, "sk-or-test")
 			msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "ping"}}}}
-			ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil))
+			ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
 			if len(ev) != 1 || ev[0].Type != EventError || ev[0].Error == nil {
 				t.Fatalf("unexpected events: %#v", ev)
 			}
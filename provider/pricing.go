@@ -0,0 +1,167 @@
+package provider
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/agusx1211/miclaw/logging"
+)
+
+const (
+	// openRouterPricesTTL is how long a cached model list is used before
+	// it is fetched again.
+	openRouterPricesTTL     = 24 * time.Hour
+	openRouterPricesTimeout = 10 * time.Second
+	openRouterPricesRetry   = time.Minute
+)
+
+// openRouterModels is the part of GET /models that carries prices, given
+// in dollars per token as decimal strings.
+type openRouterModels struct {
+	Data []struct {
+		ID      string `json:"id"`
+		Pricing struct {
+			Prompt         string `json:"prompt"`
+			Completion     string `json:"completion"`
+			InputCacheRead string `json:"input_cache_read"`
+		} `json:"pricing"`
+	} `json:"data"`
+}
+
+// pricesCacheFile is the cached model list: per-token rates by model ID.
+type pricesCacheFile struct {
+	FetchedAt time.Time                   `json:"fetched_at"`
+	Models    map[string]pricesCacheEntry `json:"models"`
+}
+
+type pricesCacheEntry struct {
+	Input     float64 `json:"input"`
+	Output    float64 `json:"output"`
+	CacheRead float64 `json:"cache_read"`
+}
+
+// SetPricesCache starts looking up the model's prices in OpenRouter's model
+// list in the background, keeping the list in path for a day. A failed
+// lookup is retried with a growing delay. Configured prices win over
+// fetched ones; until the list is had, unconfigured prices stay 0.
+func (o *OpenRouter) SetPricesCache(path string) {
+
+	o.pricesCache = path
+	go o.watchPrices()
+}
+
+// watchPrices runs loadPrices until it succeeds.
+func (o *OpenRouter) watchPrices() {
+
+	for delay := o.pricesRetry; ; delay = min(2*delay, openRouterPricesTTL) {
+		err := o.loadPrices(context.Background())
+		if err == nil {
+			return
+		}
+		logging.Errorf("[provider] openrouter: model prices unavailable: %v (retry in %s)", err, delay)
+		time.Sleep(delay)
+	}
+}
+
+// loadPrices fills in the prices not set in the config.
+func (o *OpenRouter) loadPrices(ctx context.Context) error {
+
+	models, err := o.modelPrices(ctx)
+	if err != nil {
+		return err
+	}
+	e, ok := models[o.model]
+	if !ok {
+		logging.Infof("[provider] openrouter: no prices listed for %s", o.model)
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.prices.input = cmp.Or(o.prices.input, e.Input)
+	o.prices.output = cmp.Or(o.prices.output, e.Output)
+	o.prices.cacheRead = cmp.Or(o.prices.cacheRead, e.CacheRead)
+	return nil
+}
+
+// modelPrices returns the cached model list when it is fresh and lists the
+// model, and otherwise fetches it, falling back to a stale cache.
+func (o *OpenRouter) modelPrices(ctx context.Context) (map[string]pricesCacheEntry, error) {
+
+	var cached pricesCacheFile
+	if b, err := os.ReadFile(o.pricesCache); err == nil {
+		_ = json.Unmarshal(b, &cached)
+	}
+	if _, ok := cached.Models[o.model]; ok && time.Since(cached.FetchedAt) < openRouterPricesTTL {
+		return cached.Models, nil
+	}
+	models, err := o.fetchModelPrices(ctx)
+	if err != nil {
+		if cached.Models != nil {
+			return cached.Models, nil
+		}
+		return nil, err
+	}
+	b, err := json.Marshal(pricesCacheFile{FetchedAt: time.Now().UTC(), Models: models})
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(o.pricesCache), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(o.pricesCache, b, 0o644)
+	}
+	if err != nil {
+		logging.Errorf("[provider] openrouter: cache model prices: %v", err)
+	}
+	return models, nil
+}
+
+func (o *OpenRouter) fetchModelPrices(ctx context.Context) (map[string]pricesCacheEntry, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, openRouterPricesTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.baseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET /models: status %d", resp.StatusCode)
+	}
+	var list openRouterModels
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("GET /models: %v", err)
+	}
+	models := make(map[string]pricesCacheEntry, len(list.Data))
+	for _, m := range list.Data {
+		models[m.ID] = pricesCacheEntry{
+			Input:     perTokenPrice(m.Pricing.Prompt),
+			Output:    perTokenPrice(m.Pricing.Completion),
+			CacheRead: perTokenPrice(m.Pricing.InputCacheRead),
+		}
+	}
+	return models, nil
+}
+
+// perTokenPrice parses a listed price; missing, malformed and negative
+// values (OpenRouter lists -1 for variable pricing) count as unknown.
+func perTokenPrice(s string) float64 {
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+)
+
+// pricesServer serves the model list, failing the first failFirst requests
+// with 503.
+func pricesServer(t *testing.T, listed *atomic.Int32, failFirst int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/models" {
+			if listed.Add(1) <= failFirst {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `{"data":[
+				{"id":"anthropic/claude-sonnet-4-5","pricing":{"prompt":"0.000003","completion":"0.000015","input_cache_read":"0.0000003"}},
+				{"id":"openrouter/auto","pricing":{"prompt":"-1","completion":"-1"}}
+			]}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func streamOnce(t *testing.T, p *OpenRouter) {
+	t.Helper()
+	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hi"}}}}
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
+	if len(ev) == 0 || ev[len(ev)-1].Type != EventComplete {
+		t.Fatalf("stream failed: %#v", ev)
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-15
+}
+
+// waitOutputPrice waits for the background price lookup to set the output
+// price to want.
+func waitOutputPrice(t *testing.T, p *OpenRouter, want float64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !near(p.Model().CostPerOutputToken, want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("output price never became %g: %+v", want, p.Model())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestOpenRouterFetchesAndCachesModelPrices(t *testing.T) {
+	var listed atomic.Int32
+	srv := pricesServer(t, &listed, 0)
+	defer srv.Close()
+	cache := filepath.Join(t.TempDir(), "state", "openrouter-models.json")
+	cfg := config.ProviderConfig{BaseURL: srv.URL, APIKey: "k", Model: "anthropic/claude-sonnet-4-5", InputCostPerMTok: 1}
+
+	p := NewOpenRouter(cfg)
+	p.SetPricesCache(cache)
+	waitOutputPrice(t, p, 15e-6)
+	info := p.Model()
+	if !near(info.CostPerInputToken, 1e-6) || !near(info.CostPerOutputToken, 15e-6) || !near(info.CostPerCacheReadToken, 0.3e-6) {
+		t.Fatalf("configured input price must win over fetched ones: %+v", info)
+	}
+
+	again := NewOpenRouter(cfg)
+	again.SetPricesCache(cache)
+	waitOutputPrice(t, again, 15e-6)
+	streamOnce(t, again)
+	if n := listed.Load(); n != 1 {
+		t.Fatalf("model list fetched %d times, want 1", n)
+	}
+}
+
+func TestOpenRouterRetriesFailedPriceLookup(t *testing.T) {
+	var listed atomic.Int32
+	srv := pricesServer(t, &listed, 2)
+	defer srv.Close()
+	p := NewOpenRouter(config.ProviderConfig{BaseURL: srv.URL, APIKey: "k", Model: "anthropic/claude-sonnet-4-5"})
+	p.pricesRetry = time.Millisecond
+	p.SetPricesCache(filepath.Join(t.TempDir(), "openrouter-models.json"))
+	waitOutputPrice(t, p, 15e-6)
+	if n := listed.Load(); n != 3 {
+		t.Fatalf("model list fetched %d times, want 2 failures and a success", n)
+	}
+}
+
+func TestOpenRouterPricesFallBackToZero(t *testing.T) {
+	var listed atomic.Int32
+	srv := pricesServer(t, &listed, math.MaxInt32)
+	defer srv.Close()
+	p := NewOpenRouter(config.ProviderConfig{BaseURL: srv.URL, APIKey: "k", Model: "anthropic/claude-sonnet-4-5"})
+	p.SetPricesCache(filepath.Join(t.TempDir(), "openrouter-models.json"))
+	for listed.Load() == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	streamOnce(t, p)
+	if info := p.Model(); info.CostPerInputToken != 0 || info.CostPerOutputToken != 0 {
+		t.Fatalf("unexpected prices after a failed fetch: %+v", info)
+	}
+}
+
+func TestCostPricesCacheReadsAtDiscount(t *testing.T) {
+	info := ModelInfo{CostPerInputToken: 3e-6, CostPerOutputToken: 15e-6, CostPerCacheReadToken: 0.3e-6}
+	u := UsageInfo{PromptTokens: 1000, CacheReadTokens: 800, CompletionTokens: 100}
+	if got, want := info.Cost(u), 200*3e-6+800*0.3e-6+100*15e-6; !near(got, want) {
+		t.Fatalf("cost = %g, want %g", got, want)
+	}
+	info.CostPerCacheReadToken = 0
+	if got, want := info.Cost(u), 1000*3e-6+100*15e-6; !near(got, want) {
+		t.Fatalf("cost without a cache price = %g, want %g", got, want)
+	}
+}
@@ -3,15 +3,70 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
 
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/logging"
 	"github.com/agusx1211/miclaw/model"
 )
 
 type LLMProvider interface {
-	Stream(ctx context.Context, messages []model.Message, tools []ToolDef) <-chan ProviderEvent
+	Stream(ctx context.Context, messages []model.Message, tools []ToolDef, choice ToolChoice) <-chan ProviderEvent
 	Model() ModelInfo
 }
 
+// ToolChoice tells the model whether it may call tools. The zero value,
+// ToolChoiceAuto, lets it decide; ToolChoiceNone forbids calls;
+// ToolChoiceRequired asks for at least one; any other value is the name of
+// the one tool it must call.
+type ToolChoice string
+
+const (
+	ToolChoiceAuto     ToolChoice = ""
+	ToolChoiceNone     ToolChoice = "none"
+	ToolChoiceRequired ToolChoice = "required"
+)
+
+// Tool returns the name of the tool the choice forces, or "" when it is
+// one of the keyword choices.
+func (c ToolChoice) Tool() string {
+
+	switch c {
+	case ToolChoiceAuto, ToolChoiceNone, ToolChoiceRequired:
+		return ""
+	}
+	return string(c)
+}
+
+// effectiveToolChoice returns the choice to send with tools. Without tools
+// there is nothing to choose, and a choice naming a tool that is not among
+// them cannot be honoured; both become auto, the second with a log line.
+func effectiveToolChoice(backend string, choice ToolChoice, tools []ToolDef) ToolChoice {
+
+	if len(tools) == 0 {
+		return ToolChoiceAuto
+	}
+	name := choice.Tool()
+	if name == "" || slices.ContainsFunc(tools, func(t ToolDef) bool { return t.Name == name }) {
+		return choice
+	}
+	logging.Infof("[provider] %s: tool_choice names %q, which is not offered; using auto", backend, name)
+	return ToolChoiceAuto
+}
+
+// unsupportedToolChoice logs that backend cannot honour choice, which is
+// then sent as auto.
+func unsupportedToolChoice(backend string, choice ToolChoice) ToolChoice {
+
+	logging.Infof("[provider] %s: tool_choice %q is not supported; using auto", backend, string(choice))
+	return ToolChoiceAuto
+}
+
 type ProviderEventType string
 
 const (
@@ -31,6 +86,49 @@ type ProviderEvent struct {
 	ToolName   string
 	Usage      *UsageInfo
 	Error      error
+	// Backend names the backend that produced the event when the provider
+	// is a Fallback chain; empty otherwise.
+	Backend string
+}
+
+// StatusError is a non-200 response from a backend. Retryable is set for
+// server errors, rate limits and request timeouts, which may succeed if the
+// request is sent again.
+type StatusError struct {
+	Backend   string
+	Status    int
+	Body      string
+	Retryable bool
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s stream failed: status %d", e.Backend, e.Status)
+	}
+	return fmt.Sprintf("%s stream failed: status %d: %s", e.Backend, e.Status, e.Body)
+}
+
+func readStatusError(name string, resp *http.Response) error {
+
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 16*1024))
+	return &StatusError{Backend: name, Status: resp.StatusCode, Body: strings.TrimSpace(string(b)), Retryable: retryableStatus(resp.StatusCode)}
+}
+
+// IsRateLimited reports whether err is a 429 or 529 response from a backend.
+func IsRateLimited(err error) bool {
+
+	var status *StatusError
+	return errors.As(err, &status) && isRateLimitStatus(status.Status)
+}
+
+// IsAuthError reports whether err is a 401 or 403 response from a backend:
+// the key is missing, wrong or not allowed to use the model, so sending the
+// request again cannot help.
+func IsAuthError(err error) bool {
+
+	var status *StatusError
+	return errors.As(err, &status) && (status.Status == http.StatusUnauthorized || status.Status == http.StatusForbidden)
 }
 
 type UsageInfo struct {
@@ -38,6 +136,9 @@ type UsageInfo struct {
 	CompletionTokens int
 	CacheReadTokens  int
 	CacheWriteTokens int
+	// Cost is the price of the request in dollars at the serving model's
+	// rates; 0 when the rates are unknown.
+	Cost float64
 }
 
 type ModelInfo struct {
@@ -47,6 +148,20 @@ type ModelInfo struct {
 	MaxOutput          int
 	CostPerInputToken  float64
 	CostPerOutputToken float64
+	// CostPerCacheReadToken is the discounted rate of prompt tokens read
+	// from the cache; 0 prices them as ordinary input.
+	CostPerCacheReadToken float64
+}
+
+// Cost prices usage at the model's per-token rates. Cached prompt tokens,
+// which PromptTokens includes, are priced at CostPerCacheReadToken when it
+// is known.
+func (m ModelInfo) Cost(u UsageInfo) float64 {
+	input := float64(u.PromptTokens) * m.CostPerInputToken
+	if m.CostPerCacheReadToken > 0 {
+		input += float64(u.CacheReadTokens) * (m.CostPerCacheReadToken - m.CostPerInputToken)
+	}
+	return input + float64(u.CompletionTokens)*m.CostPerOutputToken
 }
 
 type ToolDef struct {
@@ -54,3 +169,64 @@ type ToolDef struct {
 	Description string          `json:"description"`
 	Parameters  json.RawMessage `json:"parameters"`
 }
+
+// outgoingHistory returns messages as they should be sent upstream. When
+// sendReasoning is false, reasoning parts are dropped; stored history is
+// never modified.
+func outgoingHistory(messages []model.Message, sendReasoning bool) []model.Message {
+
+	if sendReasoning {
+		return messages
+	}
+	out := make([]model.Message, len(messages))
+	for i, m := range messages {
+		parts := make([]model.MessagePart, 0, len(m.Parts))
+		for _, p := range m.Parts {
+			if _, ok := p.(model.ReasoningPart); !ok {
+				parts = append(parts, p)
+			}
+		}
+		m.Parts = parts
+		out[i] = m
+	}
+
+	return out
+}
+
+// modelPrices holds per-token rates in dollars; 0 means unknown.
+type modelPrices struct {
+	input     float64
+	output    float64
+	cacheRead float64
+}
+
+// configPrices converts the configured per-million prices to per-token
+// rates.
+func configPrices(cfg config.ProviderConfig) modelPrices {
+	return modelPrices{
+		input:     cfg.InputCostPerMTok / 1_000_000,
+		output:    cfg.OutputCostPerMTok / 1_000_000,
+		cacheRead: cfg.CacheReadCostPerMTok / 1_000_000,
+	}
+}
+
+// modelInfo fills in the rates of info.
+func (p modelPrices) modelInfo(info ModelInfo) ModelInfo {
+	info.CostPerInputToken = p.input
+	info.CostPerOutputToken = p.output
+	info.CostPerCacheReadToken = p.cacheRead
+	return info
+}
+
+// sampling carries the optional temperature, top_p and stop settings. It is
+// embedded in chat-completions requests; nil and empty values are omitted so
+// the backend applies its own defaults.
+type sampling struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+func samplingFromConfig(cfg config.ProviderConfig) sampling {
+	return sampling{Temperature: cfg.Temperature, TopP: cfg.TopP, Stop: cfg.Stop}
+}
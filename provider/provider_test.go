@@ -2,11 +2,15 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/agusx1211/miclaw/model"
 )
 
 func collectEvents(t *testing.T, s string) []ProviderEvent {
@@ -254,7 +258,7 @@ func TestParseSSEResponsesFailed(t *testing.T) {
 func TestRetryOn429(t *testing.T) {
 	n := 0
 	ctx := context.Background()
-	r, err := withRetry(ctx, 8, func() (*http.Response, error) {
+	r, err := withRetry(ctx, retryPolicy{base: time.Millisecond}, func() (*http.Response, error) {
 		n++
 		if n < 3 {
 			return response(429, "0"), nil
@@ -275,21 +279,76 @@ func TestRetryOn429(t *testing.T) {
 func TestRetryPassesThroughNonRetriable(t *testing.T) {
 	n := 0
 	ctx := context.Background()
-	r, err := withRetry(ctx, 8, func() (*http.Response, error) {
+	r, err := withRetry(ctx, retryPolicy{base: time.Millisecond}, func() (*http.Response, error) {
 		n++
-		return response(500, ""), nil
+		return response(400, ""), nil
 	})
 	if err != nil {
 		t.Fatalf("withRetry returned error: %v", err)
 	}
-	if r.StatusCode != 500 {
-		t.Fatalf("expected 500, got %d", r.StatusCode)
+	if r.StatusCode != 400 {
+		t.Fatalf("expected 400, got %d", r.StatusCode)
 	}
 	if n != 1 {
 		t.Fatalf("expected 1 attempt, got %d", n)
 	}
 }
 
+func TestRetryServerErrorsUpToAttempts(t *testing.T) {
+	for _, code := range []int{500, 502, 503, 504} {
+		n := 0
+		r, err := withRetry(context.Background(), retryPolicy{attempts: 3, base: time.Millisecond}, func() (*http.Response, error) {
+			n++
+			return response(code, ""), nil
+		})
+		if err != nil {
+			t.Fatalf("withRetry returned error: %v", err)
+		}
+		if r.StatusCode != code || n != 3 {
+			t.Fatalf("status %d: got %d after %d attempts, want 3", code, r.StatusCode, n)
+		}
+	}
+}
+
+func TestRetryTransportErrorsThenSucceeds(t *testing.T) {
+	n := 0
+	r, err := withRetry(context.Background(), retryPolicy{attempts: 4, base: time.Millisecond}, func() (*http.Response, error) {
+		n++
+		if n == 1 {
+			return nil, errors.New("connection reset by peer")
+		}
+		if n == 2 {
+			return response(502, ""), nil
+		}
+		return response(200, ""), nil
+	})
+	if err != nil || r.StatusCode != 200 || n != 3 {
+		t.Fatalf("got status=%v err=%v after %d attempts", r, err, n)
+	}
+
+	n = 0
+	_, err = withRetry(context.Background(), retryPolicy{attempts: 2, base: time.Millisecond}, func() (*http.Response, error) {
+		n++
+		return nil, errors.New("connection refused")
+	})
+	if err == nil || n != 2 {
+		t.Fatalf("expected the transport error after 2 attempts, got err=%v attempts=%d", err, n)
+	}
+}
+
+func TestRetryDelayHonorsRetryAfter(t *testing.T) {
+	if d := retryDelay(time.Second, 5, "3"); d != 3*time.Second {
+		t.Fatalf("seconds form: %v", d)
+	}
+	when := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	if d := retryDelay(time.Second, 0, when); d < 8*time.Second || d > 10*time.Second {
+		t.Fatalf("HTTP-date form: %v", d)
+	}
+	if d := retryDelay(10*time.Millisecond, 2, ""); d < 40*time.Millisecond || d > 48*time.Millisecond {
+		t.Fatalf("backoff: %v", d)
+	}
+}
+
 func TestRetryRespectsContext(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -298,7 +357,7 @@ func TestRetryRespectsContext(t *testing.T) {
 		time.Sleep(20 * time.Millisecond)
 		cancel()
 	}()
-	_, err := withRetry(ctx, 8, func() (*http.Response, error) {
+	_, err := withRetry(ctx, retryPolicy{base: time.Millisecond}, func() (*http.Response, error) {
 		n++
 		return response(429, "5"), nil
 	})
@@ -309,3 +368,115 @@ func TestRetryRespectsContext(t *testing.T) {
 		t.Fatalf("expected 1 attempt before cancellation, got %d", n)
 	}
 }
+
+func TestRequestsCarrySamplingOnlyWhenSet(t *testing.T) {
+	temp, topP := 0.0, 0.5
+	set := sampling{Temperature: &temp, TopP: &topP, Stop: []string{"END"}}
+	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hi"}}}}
+	marshalers := map[string]struct {
+		marshal func(sampling) ([]byte, error)
+		stop    string
+	}{
+		"chat": {func(sp sampling) ([]byte, error) {
+			return marshalRequest("m", 64, sp, msgs, nil, ToolChoiceAuto)
+		}, "stop"},
+		"codex": {func(sp sampling) ([]byte, error) {
+			return marshalCodexRequest("m", 64, "", false, sp, msgs, nil, ToolChoiceAuto)
+		}, "stop"},
+		"responses": {func(sp sampling) ([]byte, error) {
+			return marshalCodexResponsesRequest("m", 0, "", sp, msgs, nil, ToolChoiceAuto)
+		}, ""},
+		"anthropic": {func(sp sampling) ([]byte, error) {
+			return marshalAnthropicRequest("m", 64, sp, msgs, nil, ToolChoiceAuto)
+		}, "stop_sequences"},
+		"ollama": {func(sp sampling) ([]byte, error) {
+			return json.Marshal(ollamaOptions{NumPredict: 64, sampling: sp})
+		}, "stop"},
+	}
+	for name, m := range marshalers {
+		keys := []string{"temperature", "top_p"}
+		if m.stop != "" {
+			keys = append(keys, m.stop)
+		}
+		for _, sp := range []sampling{{}, set} {
+			b, err := m.marshal(sp)
+			if err != nil {
+				t.Fatalf("%s: marshal: %v", name, err)
+			}
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(b, &body); err != nil {
+				t.Fatalf("%s: decode: %v", name, err)
+			}
+			for _, k := range keys {
+				if _, ok := body[k]; ok != (sp.Temperature != nil) {
+					t.Fatalf("%s: key %q present=%v in %s", name, k, ok, b)
+				}
+			}
+			if _, ok := body["stop"]; ok && m.stop != "stop" {
+				t.Fatalf("%s: unexpected stop key in %s", name, b)
+			}
+		}
+		if b, _ := m.marshal(set); !strings.Contains(string(b), `"temperature":0`) {
+			t.Fatalf("%s: zero temperature dropped: %s", name, b)
+		}
+	}
+}
+
+func TestRequestsEncodeToolChoice(t *testing.T) {
+	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hi"}}}}
+	tools := []ToolDef{{Name: "message", Parameters: json.RawMessage(`{"type":"object"}`)}}
+	marshalers := map[string]func(ToolChoice) ([]byte, error){
+		"chat": func(c ToolChoice) ([]byte, error) {
+			return marshalRequest("m", 64, sampling{}, msgs, tools, c)
+		},
+		"codex": func(c ToolChoice) ([]byte, error) {
+			return marshalCodexRequest("m", 64, "", false, sampling{}, msgs, tools, c)
+		},
+		"responses": func(c ToolChoice) ([]byte, error) {
+			return marshalCodexResponsesRequest("m", 0, "", sampling{}, msgs, tools, c)
+		},
+		"anthropic": func(c ToolChoice) ([]byte, error) {
+			return marshalAnthropicRequest("m", 64, sampling{}, msgs, tools, c)
+		},
+	}
+	want := map[string][4]string{
+		"chat":      {"", `"none"`, `"required"`, `{"type":"function","function":{"name":"message"}}`},
+		"codex":     {"", `"none"`, `"required"`, `{"type":"function","function":{"name":"message"}}`},
+		"responses": {`"auto"`, `"none"`, `"required"`, `{"type":"function","name":"message"}`},
+		"anthropic": {"", `{"type":"none"}`, `{"type":"any"}`, `{"type":"tool","name":"message"}`},
+	}
+	for name, marshal := range marshalers {
+		for i, c := range []ToolChoice{ToolChoiceAuto, ToolChoiceNone, ToolChoiceRequired, "message"} {
+			b, err := marshal(c)
+			if err != nil {
+				t.Fatalf("%s: marshal: %v", name, err)
+			}
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(b, &body); err != nil {
+				t.Fatalf("%s: decode: %v", name, err)
+			}
+			if got := string(body["tool_choice"]); got != want[name][i] {
+				t.Fatalf("%s: tool_choice for %q = %s, want %s", name, c, got, want[name][i])
+			}
+		}
+	}
+}
+
+func TestEffectiveToolChoiceDegradesToAuto(t *testing.T) {
+	tools := []ToolDef{{Name: "message"}}
+	cases := []struct {
+		choice ToolChoice
+		tools  []ToolDef
+		want   ToolChoice
+	}{
+		{ToolChoiceRequired, tools, ToolChoiceRequired},
+		{"message", tools, "message"},
+		{"exec", tools, ToolChoiceAuto},
+		{ToolChoiceNone, nil, ToolChoiceAuto},
+	}
+	for _, tc := range cases {
+		if got := effectiveToolChoice("test", tc.choice, tc.tools); got != tc.want {
+			t.Fatalf("effectiveToolChoice(%q) = %q, want %q", tc.choice, got, tc.want)
+		}
+	}
+}
@@ -2,66 +2,120 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/agusx1211/miclaw/config"
 )
 
 const (
-	defaultMaxRetries = 8
-	backoffBase       = time.Second
-	backoffCap        = 32 * time.Second
-	backoffJitter     = 0.2
+	defaultMaxRetries    = 8
+	defaultRetryAttempts = 4
+	backoffBase          = time.Second
+	backoffCap           = 32 * time.Second
+	backoffJitter        = 0.2
 )
 
-func withRetry(ctx context.Context, maxRetries int, fn func() (*http.Response, error)) (*http.Response, error) {
+// retryPolicy bounds how a provider post is retried. Rate limits (429, 529)
+// are retried up to rateLimited times; server errors (500, 502, 503, 504)
+// and transport errors share attempts, counting the first try. Other
+// statuses are returned as they are.
+type retryPolicy struct {
+	rateLimited int
+	attempts    int
+	base        time.Duration
+}
+
+func newRetryPolicy(cfg config.ProviderConfig) retryPolicy {
+
+	return retryPolicy{attempts: cfg.RetryAttempts, base: time.Duration(cfg.RetryBaseMillis) * time.Millisecond}
+}
+
+// withRetry runs fn until it returns a response that should not be retried
+// or policy runs out. Zero fields in policy take the defaults.
+func withRetry(ctx context.Context, policy retryPolicy, fn func() (*http.Response, error)) (*http.Response, error) {
 
-	if maxRetries <= 0 {
-		maxRetries = defaultMaxRetries
+	if policy.rateLimited <= 0 {
+		policy.rateLimited = defaultMaxRetries
+	}
+	if policy.attempts <= 0 {
+		policy.attempts = defaultRetryAttempts
+	}
+	if policy.base <= 0 {
+		policy.base = backoffBase
 	}
 
-	attempt := 0
+	attempt, limited, transient := 0, 0, 1
 	for {
 		if err := ctx.Err(); err != nil {
 			return nil, err
 		}
 		r, err := fn()
-		if err != nil {
-			return nil, err
-		}
-
-		if !isRetriableStatus(r.StatusCode) {
-			return r, nil
-		}
-		if attempt >= maxRetries {
+		header := ""
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) || transient >= policy.attempts {
+				return nil, err
+			}
+			transient++
+		case isRateLimitStatus(r.StatusCode):
+			if limited >= policy.rateLimited {
+				return r, nil
+			}
+			limited++
+			header = r.Header.Get("Retry-After")
+		case isTransientStatus(r.StatusCode):
+			if transient >= policy.attempts {
+				return r, nil
+			}
+			transient++
+			header = r.Header.Get("Retry-After")
+		default:
 			return r, nil
 		}
-		d := retryDelay(attempt, r.Header.Get("Retry-After"))
-		if r.Body != nil {
+		if r != nil && r.Body != nil {
 			_ = r.Body.Close()
 		}
-		if err := waitForRetry(ctx, d); err != nil {
+		if err := waitForRetry(ctx, retryDelay(policy.base, attempt, header)); err != nil {
 			return nil, err
 		}
 		attempt++
 	}
 }
 
-func isRetriableStatus(code int) bool {
+func isRateLimitStatus(code int) bool {
 
 	return code == http.StatusTooManyRequests || code == 529
 }
 
-func retryDelay(attempt int, header string) time.Duration {
+func retryableStatus(code int) bool {
+
+	return isTransientStatus(code) || isRateLimitStatus(code) || code == http.StatusRequestTimeout
+}
+
+func isTransientStatus(code int) bool {
+
+	switch code {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay honors a Retry-After header in seconds or HTTP-date form, and
+// otherwise backs off exponentially from base with some jitter.
+func retryDelay(base time.Duration, attempt int, header string) time.Duration {
 
 	h := strings.TrimSpace(header)
 	if d, ok := parseRetryAfter(h); ok {
 		return d
 	}
-	d := backoffBase << attempt
-	if d > backoffCap {
+	d := base << attempt
+	if d <= 0 || d > backoffCap {
 		d = backoffCap
 	}
 	j := time.Duration(float64(d) * backoffJitter * rand.Float64())
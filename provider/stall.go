@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+)
+
+const defaultStreamIdleTimeout = 120 * time.Second
+
+// ErrStreamStalled is returned in an EventError when a response stream sends
+// nothing for the idle timeout. It counts as retryable for failover.
+var ErrStreamStalled = errors.New("stream stalled")
+
+func streamIdleTimeout(cfg config.ProviderConfig) time.Duration {
+
+	if cfg.StreamIdleTimeoutSeconds <= 0 {
+		return defaultStreamIdleTimeout
+	}
+	return time.Duration(cfg.StreamIdleTimeoutSeconds) * time.Second
+}
+
+// idleReader closes a response body that goes quiet for longer than timeout,
+// so a parser blocked in Scan wakes up with ErrStreamStalled instead of
+// waiting on a hung upstream forever. Every read restarts the timer.
+type idleReader struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	stalled atomic.Bool
+}
+
+func watchIdle(body io.ReadCloser, timeout time.Duration) io.ReadCloser {
+
+	r := &idleReader{body: body, timeout: timeout}
+	r.timer = time.AfterFunc(timeout, func() {
+		r.stalled.Store(true)
+		body.Close()
+	})
+	return r
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+
+	n, err := r.body.Read(p)
+	if r.stalled.Load() {
+		return n, fmt.Errorf("%w: no data for %s", ErrStreamStalled, r.timeout)
+	}
+	r.timer.Reset(r.timeout)
+	return n, err
+}
+
+func (r *idleReader) Close() error {
+
+	r.timer.Stop()
+	return r.body.Close()
+}
@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+)
+
+func TestStreamFailsWhenUpstreamStalls(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"half a\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	p := NewOpenRouter(config.ProviderConfig{BaseURL: srv.URL, APIKey: "k", Model: "m"})
+	p.idle = 100 * time.Millisecond
+	msgs := []model.Message{{Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "hi"}}}}
+	ev := collectProviderEvents(t, p.Stream(context.Background(), msgs, nil, ToolChoiceAuto))
+	if len(ev) != 2 || ev[0].Type != EventContentDelta || ev[0].Delta != "half a" {
+		t.Fatalf("unexpected events: %#v", ev)
+	}
+	if ev[1].Type != EventError || !errors.Is(ev[1].Error, ErrStreamStalled) {
+		t.Fatalf("want a stalled stream error, got %#v", ev[1])
+	}
+}
+
+func TestStreamIdleTimeoutDefault(t *testing.T) {
+	if got := streamIdleTimeout(config.ProviderConfig{}); got != 120*time.Second {
+		t.Fatalf("default idle timeout = %s", got)
+	}
+	if got := streamIdleTimeout(config.ProviderConfig{StreamIdleTimeoutSeconds: 5}); got != 5*time.Second {
+		t.Fatalf("configured idle timeout = %s", got)
+	}
+}
@@ -38,10 +38,31 @@ type openAIFunctionDelta struct {
 }
 
 type openAIUsage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	CacheReadTokens  int `json:"cache_read_tokens"`
-	CacheWriteTokens int `json:"cache_write_tokens"`
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	CacheReadTokens     int `json:"cache_read_tokens"`
+	CacheWriteTokens    int `json:"cache_write_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+}
+
+// chatCompletion tracks the end of a chat-completions stream. The finish
+// chunk marks it done, but its EventComplete waits for [DONE] or the end of
+// the body so the usage chunk that follows can be included.
+type chatCompletion struct {
+	done  bool
+	sent  bool
+	usage *openAIUsage
+}
+
+func (c *chatCompletion) complete(out chan<- ProviderEvent) {
+
+	if !c.done || c.sent {
+		return
+	}
+	c.sent = true
+	out <- ProviderEvent{Type: EventComplete, Usage: usageInfo(c.usage)}
 }
 
 type responseChunk struct {
@@ -113,32 +134,36 @@ func parseSSE(body io.ReadCloser, out chan<- ProviderEvent) {
 	s.Buffer(make([]byte, 0, 64*1024), 8*1024*1024)
 	p := make(map[int]toolState)
 	rp := make(map[string]responseToolState)
+	turn := &chatCompletion{}
 	for s.Scan() {
 		line := strings.TrimSpace(s.Text())
 		if line == "" || !strings.HasPrefix(line, "data:") {
 			continue
 		}
-		if flushData(strings.TrimSpace(strings.TrimPrefix(line, "data:")), p, rp, out) {
+		if flushData(strings.TrimSpace(strings.TrimPrefix(line, "data:")), p, rp, turn, out) {
 			return
 		}
 	}
 	if err := s.Err(); err != nil {
 		out <- ProviderEvent{Type: EventError, Error: err}
+		return
 	}
+	turn.complete(out)
 }
 
-func flushData(data string, p map[int]toolState, rp map[string]responseToolState, out chan<- ProviderEvent) bool {
+func flushData(data string, p map[int]toolState, rp map[string]responseToolState, turn *chatCompletion, out chan<- ProviderEvent) bool {
 
 	d := strings.TrimSpace(data)
 	if d == "" {
 		return false
 	}
 	if d == "[DONE]" {
+		turn.complete(out)
 		return true
 	}
 	chunk, ok := parseChunk(d)
 	if ok && (len(chunk.Choices) > 0 || chunk.Usage != nil) {
-		emitChunk(chunk, p, out)
+		emitChunk(chunk, p, turn, out)
 		return false
 	}
 	rc, rok := parseResponseChunk(d)
@@ -169,8 +194,11 @@ func parseResponseChunk(data string) (responseChunk, bool) {
 	return chunk, true
 }
 
-func emitChunk(chunk openAIChunk, p map[int]toolState, out chan<- ProviderEvent) {
+func emitChunk(chunk openAIChunk, p map[int]toolState, turn *chatCompletion, out chan<- ProviderEvent) {
 
+	if chunk.Usage != nil {
+		turn.usage = chunk.Usage
+	}
 	for _, c := range chunk.Choices {
 		if c.Delta.Content != "" {
 			out <- ProviderEvent{Type: EventContentDelta, Delta: c.Delta.Content}
@@ -187,7 +215,7 @@ func emitChunk(chunk openAIChunk, p map[int]toolState, out chan<- ProviderEvent)
 		emitToolCalls(c.Delta.ToolCalls, p, out)
 		if c.FinishReason != "" {
 			emitToolStops(p, out)
-			out <- ProviderEvent{Type: EventComplete, Usage: usageInfo(chunk.Usage)}
+			turn.done = true
 		}
 	}
 }
@@ -377,10 +405,14 @@ func usageInfo(u *openAIUsage) *UsageInfo {
 	if u == nil {
 		return nil
 	}
-	return &UsageInfo{
+	info := &UsageInfo{
 		PromptTokens:     u.PromptTokens,
 		CompletionTokens: u.CompletionTokens,
 		CacheReadTokens:  u.CacheReadTokens,
 		CacheWriteTokens: u.CacheWriteTokens,
 	}
+	if info.CacheReadTokens == 0 && u.PromptTokensDetails != nil {
+		info.CacheReadTokens = u.PromptTokensDetails.CachedTokens
+	}
+	return info
 }
@@ -0,0 +1,694 @@
+// Package miclaw wires the agent, stores, scheduler, and transports into one
+// Runtime so it can be embedded in other Go programs. cmd/miclaw is a thin
+// CLI around it.
+package miclaw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/agusx1211/miclaw/agent"
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/logging"
+	"github.com/agusx1211/miclaw/memory"
+	"github.com/agusx1211/miclaw/prompt"
+	"github.com/agusx1211/miclaw/provider"
+	signalpipe "github.com/agusx1211/miclaw/signal"
+	"github.com/agusx1211/miclaw/store"
+	"github.com/agusx1211/miclaw/tools"
+	"github.com/agusx1211/miclaw/webhook"
+	"github.com/google/uuid"
+)
+
+const runtimeLogTextLimit = 180
+
+// Options selects the optional parts of a Runtime.
+type Options struct {
+	// Provider replaces the backend built from cfg.Provider.
+	Provider provider.LLMProvider
+	// Signal starts the Signal pipeline using cfg.Signal.
+	Signal bool
+	// Webhook starts the webhook server using cfg.Webhook.
+	Webhook bool
+	// WrapTools rewrites the main agent tool list before the agent is built.
+	WrapTools func([]tools.Tool) []tools.Tool
+}
+
+// Runtime is a running agent with its stores, scheduler, and transports.
+type Runtime struct {
+	cfg         *config.Config
+	sqlStore    store.Backend
+	messages    store.MessageStore
+	memStore    *memory.Store
+	embedClient *memory.EmbedClient
+	scheduler   *tools.Scheduler
+	agent       *agent.Agent
+	signal      *signalpipe.Client
+	typing      *typingState
+	overload    overloadNotices
+	// commands holds a token while /new or /compact runs, so lifecycle
+	// commands never interleave.
+	commands    chan struct{}
+	cronDropped atomic.Int64
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	errCh       chan error
+	once        sync.Once
+	grace       time.Duration
+	startedAt   time.Time
+}
+
+// New opens the stores under cfg.StatePath, builds the agent, and starts
+// memory sync, the scheduler, and the transports selected in opts.
+// cfg must already have defaults applied and paths expanded.
+func New(cfg *config.Config, opts Options) (*Runtime, error) {
+
+	r, err := build(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.startMemorySync(ctx)
+	if err := r.startScheduler(ctx); err != nil {
+		cancel()
+		r.close()
+		return nil, err
+	}
+	if opts.Signal {
+		r.startSignalEvents(ctx)
+	}
+	r.restoreQueue()
+	r.injectStartupPrompt()
+	if opts.Signal {
+		r.startSignalPipeline(ctx)
+	}
+	if opts.Webhook {
+		r.startWebhookServer(ctx)
+	}
+	if cfg.Webhook.Outbound.URL != "" {
+		r.startOutboundWebhook(ctx)
+	}
+	return r, nil
+}
+
+// build opens the stores and assembles the runtime. On error it closes
+// whatever it had opened.
+func build(cfg *config.Config, opts Options) (_ *Runtime, err error) {
+
+	if err := os.MkdirAll(cfg.Workspace, 0o755); err != nil {
+		return nil, err
+	}
+	sqlStore, memStore, embedClient, err := openStores(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r := &Runtime{
+		cfg:         cfg,
+		sqlStore:    sqlStore,
+		messages:    newMediaStore(sqlStore.MessageStore(), provider.DownloadsMedia(cfg.Provider)),
+		memStore:    memStore,
+		embedClient: embedClient,
+		typing:      newTypingState(),
+		commands:    make(chan struct{}, 1),
+		errCh:       make(chan error, 2),
+		startedAt:   time.Now(),
+		grace:       time.Duration(cfg.Shutdown.GraceSeconds) * time.Second,
+	}
+	defer func() {
+		if err != nil {
+			r.close()
+		}
+	}()
+	prov := opts.Provider
+	if prov == nil {
+		if prov, err = newProvider(cfg.Provider, cfg.StatePath); err != nil {
+			return nil, err
+		}
+	}
+	if r.scheduler, err = newScheduler(cfg); err != nil {
+		return nil, err
+	}
+	if err := r.SetLogLevel(cfg.LogLevel); err != nil {
+		return nil, err
+	}
+	if opts.Signal {
+		baseURL := fmt.Sprintf("http://%s:%d", cfg.Signal.HTTPHost, cfg.Signal.HTTPPort)
+		r.signal = signalpipe.NewClient(baseURL, cfg.Signal.Account)
+	}
+	if err := r.buildAgent(opts, prov); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Runtime) buildAgent(opts Options, prov provider.LLMProvider) error {
+
+	workspace, skills, glossary, err := loadPromptData(r.cfg.Workspace)
+	if err != nil {
+		return err
+	}
+	toolList := r.mainTools(opts, prov.Model())
+	if err := checkToolPolicyNames(toolList, r.cfg.Tools); err != nil {
+		return err
+	}
+	r.agent = agent.NewAgent(r.messages, toolList, prov)
+	if err := r.configureAgent(); err != nil {
+		return err
+	}
+	r.agent.SetWorkspace(workspace)
+	r.agent.SetSkills(skills)
+	r.agent.SetGlossary(glossary)
+	r.agent.SetTrace(r.trace)
+	r.agent.SetQueueStore(r.sqlStore.Queue())
+	return nil
+}
+
+// mainTools builds the agent's tool list, applying opts.WrapTools and the
+// configured concurrency limits.
+func (r *Runtime) mainTools(opts Options, info provider.ModelInfo) []tools.Tool {
+
+	toolList := tools.MainAgentTools(tools.MainToolDeps{
+		Workspace:     r.cfg.Workspace,
+		Sandbox:       r.cfg.Sandbox,
+		Memory:        r.memStore,
+		MemoryCfg:     r.cfg.Memory,
+		Embed:         r.embedClient,
+		Scheduler:     r.scheduler,
+		SendMessage:   r.sendMessage,
+		AddGlossary:   func(e prompt.GlossaryEntry) { r.agent.AddGlossaryEntry(e) },
+		Messages:      r.messages,
+		Model:         info,
+		Fetch:         r.cfg.Tools.Fetch,
+		MaxFilesPerOp: r.cfg.Tools.MaxFilesPerOp,
+		SnapshotDir:   filepath.Join(r.cfg.StatePath, "snapshots"),
+		Snapshot:      r.cfg.Tools.Snapshot,
+		LogLevel:      r.LogLevel,
+		SetLogLevel:   r.SetLogLevel,
+		ExecOutput:    func(id, chunk string) { r.agent.PublishToolOutput(id, "exec", chunk) },
+		SecretsFile:   filepath.Join(r.cfg.StatePath, tools.SecretsFile),
+	})
+	if opts.WrapTools != nil {
+		toolList = opts.WrapTools(toolList)
+	}
+	return tools.LimitConcurrency(toolList, r.cfg.Tools.Concurrency)
+}
+
+func newScheduler(cfg *config.Config) (*tools.Scheduler, error) {
+
+	scheduler, err := tools.NewScheduler(filepath.Join(cfg.StatePath, "cron.sqlite"))
+	if err != nil {
+		return nil, err
+	}
+	scheduler.SetRefreshInterval(time.Duration(cfg.Tools.CronRefreshSeconds) * time.Second)
+	if err := scheduler.SetDefaultTimezone(cfg.Tools.CronTimezone); err != nil {
+		_ = scheduler.Close()
+		return nil, fmt.Errorf("tools.cron_timezone: %v", err)
+	}
+	if cfg.Heartbeat.Enabled {
+		if err := scheduler.SetHeartbeat(cfg.Heartbeat.Schedule, cfg.Heartbeat.Prompt); err != nil {
+			_ = scheduler.Close()
+			return nil, fmt.Errorf("heartbeat.schedule: %v", err)
+		}
+	}
+	return scheduler, nil
+}
+
+// configureAgent applies the agent settings from the config.
+func (r *Runtime) configureAgent() error {
+
+	cfg := r.cfg
+	r.agent.SetNoToolSleepRounds(cfg.NoToolSleepRounds)
+	r.agent.SetMaxToolRounds(cfg.Agent.MaxToolRounds)
+	r.agent.SetMaxParallelTools(cfg.Agent.MaxParallelTools)
+	r.agent.SetCoalesceWindow(time.Duration(cfg.Agent.CoalesceWindowMS) * time.Millisecond)
+	r.agent.SetGenerationTimeout(time.Duration(cfg.Agent.GenerationTimeoutMS) * time.Millisecond)
+	r.agent.SetToolTimeouts(toolTimeouts(cfg.Tools))
+	r.agent.SetToolPolicies(toolPolicies(cfg.Tools))
+	summarizer, err := summaryProvider(cfg.Provider, cfg.StatePath)
+	if err != nil {
+		return err
+	}
+	r.agent.SetSummaryProvider(summarizer)
+	r.agent.SetToolResultSummary(toolResultSummary(cfg))
+	loc, err := time.LoadLocation(cfg.Agent.Rotation.Timezone)
+	if err != nil {
+		return fmt.Errorf("agent.rotation.timezone: %v", err)
+	}
+	r.agent.SetRotation(cfg.Agent.Rotation.Period, loc)
+	r.agent.SetToolCallIDs(cfg.Agent.ToolCallIDs)
+	r.agent.SetTruncatedCalls(cfg.Agent.TruncatedToolCalls)
+	r.agent.SetCompactThreshold(cfg.Agent.CompactThreshold)
+	r.agent.SetCompactKeepMessages(cfg.Agent.CompactKeepMessages)
+	r.agent.SetCostLimits(r.sqlStore.Costs(), cfg.Limits.MaxCostPerThread, cfg.Limits.MaxCostPerDay)
+	if cfg.Store.EventLog {
+		r.agent.SetEventLog(r.sqlStore.Events(), cfg.Store.EventLogMaxRows)
+	}
+	r.agent.SetQueueLimits(agent.QueueLimits{
+		MaxDepth:     cfg.Agent.Queue.MaxDepth,
+		MaxPerSource: cfg.Agent.Queue.MaxPerSource,
+		Sources:      cfg.Agent.Queue.Sources,
+	})
+	return nil
+}
+
+func toolPolicies(cfg config.ToolsConfig) agent.ToolPolicies {
+
+	sources := make(map[string]agent.ToolPolicy, len(cfg.Sources))
+	for source, p := range cfg.Sources {
+		sources[source] = agent.ToolPolicy{Enabled: p.Enabled, Disabled: p.Disabled}
+	}
+	return agent.ToolPolicies{
+		Default: agent.ToolPolicy{Enabled: cfg.Enabled, Disabled: cfg.Disabled},
+		Sources: sources,
+	}
+}
+
+// checkToolPolicyNames rejects tool policies naming a tool the agent does
+// not have, so a misspelt entry cannot leave a tool enabled unnoticed.
+func checkToolPolicyNames(toolList []tools.Tool, cfg config.ToolsConfig) error {
+
+	known := make(map[string]bool, len(toolList))
+	for _, t := range toolList {
+		known[t.Name()] = true
+	}
+	check := func(field string, names []string) error {
+		for _, name := range names {
+			if !known[name] {
+				return fmt.Errorf("%s: unknown tool %q", field, name)
+			}
+		}
+		return nil
+	}
+	if err := check("tools.enabled", cfg.Enabled); err != nil {
+		return err
+	}
+	if err := check("tools.disabled", cfg.Disabled); err != nil {
+		return err
+	}
+	for source, p := range cfg.Sources {
+		if err := check(fmt.Sprintf("tools.sources[%q].enabled", source), p.Enabled); err != nil {
+			return err
+		}
+		if err := check(fmt.Sprintf("tools.sources[%q].disabled", source), p.Disabled); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toolTimeouts(cfg config.ToolsConfig) agent.ToolTimeouts {
+
+	perTool := make(map[string]time.Duration, len(cfg.Timeouts))
+	for name, secs := range cfg.Timeouts {
+		perTool[name] = time.Duration(secs) * time.Second
+	}
+	return agent.ToolTimeouts{Default: time.Duration(cfg.DefaultTimeoutSeconds) * time.Second, PerTool: perTool}
+}
+
+// summaryProvider builds the backend for provider.summary_model, which
+// writes every summary. It is priced at its own summary_*_cost_per_mtok
+// settings; none of the main model's prices carry over. It returns nil when
+// no summary model is set, so summaries use the main provider.
+func summaryProvider(cfg config.ProviderConfig, statePath string) (provider.LLMProvider, error) {
+
+	if cfg.SummaryModel == "" {
+		return nil, nil
+	}
+	pc := cfg
+	pc.Model = cfg.SummaryModel
+	pc.InputCostPerMTok = cfg.SummaryInputCostPerMTok
+	pc.OutputCostPerMTok = cfg.SummaryOutputCostPerMTok
+	pc.CacheReadCostPerMTok = 0
+	pc.Fallbacks = nil
+	p, err := newBackend(pc, statePath)
+	if err != nil {
+		return nil, fmt.Errorf("provider.summary_model: %v", err)
+	}
+	return p, nil
+}
+
+// toolResultSummary builds the agent's tool result summarizing settings.
+// Full results are saved in the workspace, where the file tools and the
+// sandbox can read them back.
+func toolResultSummary(cfg *config.Config) agent.ToolResultSummary {
+
+	return agent.ToolResultSummary{Over: cfg.Tools.AutoSummarizeOver, Dir: filepath.Join(cfg.Workspace, "tool-results")}
+}
+
+// newProvider builds the configured backend, wrapped in a Fallback chain
+// when fallbacks are configured. OpenRouter backends cache their model
+// prices under statePath; an empty statePath leaves prices as configured.
+func newProvider(cfg config.ProviderConfig, statePath string) (provider.LLMProvider, error) {
+
+	primary, err := newBackend(cfg, statePath)
+	if err != nil || len(cfg.Fallbacks) == 0 {
+		return primary, err
+	}
+	chain := []provider.FallbackBackend{{Name: backendName(cfg), Provider: primary}}
+	for _, fb := range cfg.Fallbacks {
+		p, err := newBackend(fb, statePath)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, provider.FallbackBackend{Name: backendName(fb), Provider: p})
+	}
+	return provider.NewFallback(chain...), nil
+}
+
+func backendName(cfg config.ProviderConfig) string {
+	return cfg.Backend + "/" + cfg.Model
+}
+
+func newBackend(cfg config.ProviderConfig, statePath string) (provider.LLMProvider, error) {
+
+	switch cfg.Backend {
+	case "openrouter":
+		p := provider.NewOpenRouter(cfg)
+		if statePath != "" {
+			p.SetPricesCache(filepath.Join(statePath, "openrouter-models.json"))
+		}
+		return p, nil
+	case "lmstudio":
+		return provider.NewLMStudio(cfg), nil
+	case "codex":
+		return provider.NewCodex(cfg), nil
+	case "anthropic":
+		return provider.NewAnthropic(cfg), nil
+	case "ollama":
+		return provider.NewOllama(cfg), nil
+	}
+	return nil, fmt.Errorf("unsupported provider backend %q", cfg.Backend)
+}
+
+// openThreadStore opens the store.backend that holds the thread and the
+// input queue.
+func openThreadStore(cfg *config.Config) (store.Backend, error) {
+
+	if cfg.Store.Backend == "postgres" {
+		s, err := store.OpenPostgres(cfg.Store.PostgresDSN)
+		if err != nil {
+			return nil, fmt.Errorf("open postgres store: %w", err)
+		}
+		return s, nil
+	}
+	sq := cfg.Store.SQLite
+	return store.OpenSQLiteWith(filepath.Join(cfg.StatePath, "sessions.sqlite"), store.SQLiteOptions{
+		JournalMode:   sq.JournalMode,
+		BusyTimeoutMS: sq.BusyTimeoutMS,
+		Synchronous:   sq.Synchronous,
+		MaxOpenConns:  sq.MaxOpenConns,
+	})
+}
+
+func openStores(cfg *config.Config) (store.Backend, *memory.Store, *memory.EmbedClient, error) {
+
+	if err := os.MkdirAll(cfg.StatePath, 0o755); err != nil {
+		return nil, nil, nil, err
+	}
+	sqlStore, err := openThreadStore(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !cfg.Memory.Enabled {
+		return sqlStore, nil, nil, nil
+	}
+	if err := os.MkdirAll(filepath.Join(cfg.StatePath, "memory"), 0o755); err != nil {
+		_ = sqlStore.Close()
+		return nil, nil, nil, err
+	}
+	memStore, err := memory.Open(filepath.Join(cfg.StatePath, "memory", "agent.sqlite"))
+	if err != nil {
+		_ = sqlStore.Close()
+		return nil, nil, nil, err
+	}
+	embedClient := memory.NewEmbedClient(cfg.Memory.EmbeddingURL, cfg.Memory.EmbeddingAPIKey, cfg.Memory.EmbeddingModel)
+	embedClient.SetBatchSize(cfg.Memory.EmbeddingBatchSize)
+	embedClient.SetLimits(memory.EmbedLimits{
+		Timeout:          time.Duration(max(cfg.Memory.EmbedTimeoutSeconds, 0)) * time.Second,
+		Retries:          max(cfg.Memory.EmbedRetries, 0),
+		Backoff:          time.Duration(cfg.Memory.EmbedBackoffMS) * time.Millisecond,
+		BreakerThreshold: max(cfg.Memory.EmbedBreakerThreshold, 0),
+		BreakerCooldown:  time.Duration(cfg.Memory.EmbedBreakerCooldownSeconds) * time.Second,
+	})
+	return sqlStore, memStore, embedClient, nil
+}
+
+func loadPromptData(workspacePath string) (*prompt.Workspace, []prompt.SkillSummary, []prompt.GlossaryEntry, error) {
+
+	workspace, err := prompt.LoadWorkspace(workspacePath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	skills, err := prompt.LoadSkills(workspacePath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	glossary, err := prompt.LoadGlossary(workspacePath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return workspace, skills, glossary, nil
+}
+
+func (r *Runtime) startMemorySync(ctx context.Context) {
+
+	if !r.cfg.Memory.Enabled {
+		return
+	}
+	indexer := memory.NewIndexer(r.memStore, r.embedClient)
+	indexer.SetChunkTokens(r.cfg.Memory.ChunkSizeTokens, r.cfg.Memory.ChunkOverlapTokens)
+	go func() {
+		if err := indexer.Sync(ctx, r.cfg.Workspace); err != nil {
+			logging.Errorf("[memory] sync error: %v", err)
+		}
+	}()
+}
+
+func (r *Runtime) startScheduler(ctx context.Context) error {
+
+	if _, err := r.scheduler.ListJobs(); err != nil {
+		return err
+	}
+	r.scheduler.Start(ctx, r.handleCronInput)
+	return nil
+}
+
+// handleCronInput queues a scheduled prompt in the low-priority lane, behind
+// anything a person sent. A heartbeat is skipped while the agent is busy,
+// since a running turn already shows it is alive. Inputs that hit the queue
+// limits are dropped without a reply and counted in CronDropped.
+func (r *Runtime) handleCronInput(source, content string) {
+
+	if source == tools.HeartbeatSource && r.agent.IsActive() {
+		logging.Infof("[cron] skip source=%s active=true msg=%q", source, compactRuntimeText(content))
+		return
+	}
+	logging.Infof("[cron] in source=%s msg=%q", source, compactRuntimeText(content))
+	if err := r.agent.Inject(agent.Input{Source: source, Content: content, Priority: agent.PriorityLow}); err != nil {
+		r.cronDropped.Add(1)
+	}
+}
+
+// restoreQueue re-queues the inputs the previous process accepted but did
+// not finish, ahead of anything new.
+func (r *Runtime) restoreQueue() {
+
+	n, err := r.agent.RestoreQueue()
+	if err != nil {
+		logging.Errorf("[queue] restore err=%v", err)
+		return
+	}
+	if n > 0 {
+		logging.Infof("[queue] restored inputs=%d", n)
+	}
+}
+
+// injectStartupPrompt queues the configured briefing before any transport
+// starts, so it is the first new input the agent handles after boot.
+func (r *Runtime) injectStartupPrompt() {
+
+	content := strings.TrimSpace(r.cfg.Agent.StartupPrompt)
+	if content == "" {
+		return
+	}
+	logging.Infof("[startup] in msg=%q", compactRuntimeText(content))
+	if err := r.agent.Inject(agent.Input{Source: "startup", Content: content}); err != nil {
+		logging.Infof("[startup] rejected err=%v", err)
+	}
+}
+
+func (r *Runtime) startWebhookServer(ctx context.Context) {
+
+	srv := webhook.New(r.cfg.Webhook, func(source, content string, metadata map[string]string) error {
+		logging.Infof("[webhook] in source=%s msg=%q", source, compactRuntimeText(content))
+		return r.agent.Inject(agent.Input{Source: source, Content: content, Metadata: metadata})
+	})
+	srv.HandleSync(r.runWebhookSync)
+	if r.cfg.Webhook.HealthEnabled {
+		srv.HandleHealth(r.health)
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if err := srv.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			r.errCh <- fmt.Errorf("webhook server: %v", err)
+		}
+	}()
+}
+
+// runWebhookSync queues input from a sync hook and returns a wait for the
+// text the message tool sent to its source in the turn that handled it.
+func (r *Runtime) runWebhookSync(source, content string, metadata map[string]string) (func(context.Context) (string, error), error) {
+
+	logging.Infof("[webhook] in source=%s sync=true msg=%q", source, compactRuntimeText(content))
+	input := agent.Input{ID: uuid.NewString(), Source: source, Content: content, Metadata: metadata}
+	done, cancel := r.agent.AwaitTurn(input)
+	if err := r.agent.Inject(input); err != nil {
+		cancel()
+		return nil, err
+	}
+	return func(ctx context.Context) (string, error) {
+		defer cancel()
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case res := <-done:
+			return res.Reply, res.Err
+		}
+	}, nil
+}
+
+func (r *Runtime) startOutboundWebhook(ctx context.Context) {
+
+	out := webhook.NewOutbound(r.cfg.Webhook.Outbound)
+	events, unsub := r.agent.Events().Subscribe()
+	r.wg.Add(2)
+	go func() {
+		defer r.wg.Done()
+		out.Run(ctx)
+	}()
+	go func() {
+		defer r.wg.Done()
+		defer unsub()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-events:
+				if !out.Wants(string(ev.Type)) {
+					continue
+				}
+				if !out.Enqueue(outboundEvent(ev)) {
+					logging.Infof("[webhook] outbound_drop type=%s dropped=%d", ev.Type, out.Dropped())
+				}
+			}
+		}
+	}()
+}
+
+func outboundEvent(ev agent.AgentEvent) webhook.OutboundEvent {
+
+	out := webhook.OutboundEvent{
+		Type:       string(ev.Type),
+		Source:     ev.Source,
+		Text:       ev.Text,
+		Time:       time.Now().UTC(),
+		ToolCallID: ev.ToolCallID,
+		ToolName:   ev.ToolName,
+		DurationMs: ev.DurationMs,
+		IsError:    ev.IsError,
+	}
+	// Arguments the model streamed are not guaranteed to be valid JSON and
+	// would fail the whole document.
+	if json.Valid(ev.Params) {
+		out.Params = ev.Params
+	}
+	if ev.Error != nil {
+		out.Error = ev.Error.Error()
+	}
+	if ev.Usage != nil {
+		out.Usage = &webhook.OutboundUsage{
+			PromptTokens:     ev.Usage.PromptTokens,
+			CompletionTokens: ev.Usage.CompletionTokens,
+			CacheReadTokens:  ev.Usage.CacheReadTokens,
+			CacheWriteTokens: ev.Usage.CacheWriteTokens,
+			Cost:             ev.Usage.Cost,
+		}
+	}
+	return out
+}
+
+func (r *Runtime) trace(level logging.Level, format string, args ...any) {
+
+	if r.signal != nil {
+		switch format {
+		case "wake":
+			if err := r.typing.StartAuto(r.sendTyping); err != nil {
+				logging.Errorf("[signal] typing_auto_error err=%v", err)
+			}
+		case "sleep":
+			if err := r.typing.StopAll(r.sendTypingStop); err != nil {
+				logging.Errorf("[signal] typing_auto_error err=%v", err)
+			}
+		}
+	}
+	logging.Printf(level, "[agent] "+format, args...)
+}
+
+// Inject queues input for the agent and wakes it if it is idle. It returns an
+// error wrapping agent.ErrQueueFull when the configured queue limits are hit.
+func (r *Runtime) Inject(input agent.Input) error {
+	return r.agent.Inject(input)
+}
+
+// Steer adds input to the running turn before its next round; see
+// agent.Agent.Steer.
+func (r *Runtime) Steer(input agent.Input) error {
+	return r.agent.Steer(input)
+}
+
+// RunOnce processes input synchronously. It fails if the agent is active.
+func (r *Runtime) RunOnce(ctx context.Context, input agent.Input) error {
+	return r.agent.RunOnce(ctx, input)
+}
+
+// Events returns the agent event broker.
+func (r *Runtime) Events() *agent.Broker[agent.AgentEvent] {
+	return r.agent.Events()
+}
+
+// Err reports fatal transport errors. The runtime keeps running; callers
+// decide whether to shut down.
+func (r *Runtime) Err() <-chan error {
+	return r.errCh
+}
+
+func (r *Runtime) Config() *config.Config       { return r.cfg }
+func (r *Runtime) Agent() *agent.Agent          { return r.agent }
+func (r *Runtime) Messages() store.MessageStore { return r.messages }
+func (r *Runtime) Scheduler() *tools.Scheduler  { return r.scheduler }
+
+// CronDropped reports how many cron and heartbeat inputs were dropped because
+// the agent queue was full.
+func (r *Runtime) CronDropped() int64 { return r.cronDropped.Load() }
+
+// Memory returns the memory store, or nil when memory is disabled.
+func (r *Runtime) Memory() *memory.Store { return r.memStore }
+
+func compactRuntimeText(raw string) string {
+
+	clean := strings.Join(strings.Fields(strings.TrimSpace(raw)), " ")
+	if len(clean) <= runtimeLogTextLimit {
+		return clean
+	}
+	return clean[:runtimeLogTextLimit-3] + "..."
+}
@@ -0,0 +1,607 @@
+package miclaw
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/agusx1211/miclaw/agent"
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/store"
+	"github.com/agusx1211/miclaw/tools"
+	"github.com/agusx1211/miclaw/webhook"
+)
+
+func TestStartSchedulerInjectsCronMessage(t *testing.T) {
+	root := t.TempDir()
+	scheduler, err := tools.NewScheduler(filepath.Join(root, "cron.db"))
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := scheduler.Close(); err != nil {
+			t.Fatalf("close scheduler: %v", err)
+		}
+	})
+
+	sqlStore, err := store.OpenSQLite(filepath.Join(root, "messages.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := sqlStore.Close(); err != nil {
+			t.Fatalf("close sqlite: %v", err)
+		}
+	})
+
+	var now atomic.Int64
+	base := time.Date(2026, 2, 21, 10, 0, 0, 0, time.UTC)
+	now.Store(base.UnixNano())
+	setSchedulerField(scheduler, "tick", 10*time.Millisecond)
+	setSchedulerField(scheduler, "now", func() time.Time { return time.Unix(0, now.Load()).UTC() })
+
+	ag := agent.NewAgent(sqlStore.MessageStore(), nil, cronStubProvider{})
+	t.Cleanup(ag.Cancel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &Runtime{cfg: testConfig(t), scheduler: scheduler, agent: ag}
+	if err := r.startScheduler(ctx); err != nil {
+		t.Fatalf("start scheduler: %v", err)
+	}
+	t.Cleanup(scheduler.Stop)
+
+	if _, err := scheduler.AddJob("*/1 * * * *", "", "ping"); err != nil {
+		t.Fatalf("add job: %v", err)
+	}
+	now.Store(base.Add(time.Minute).UnixNano())
+
+	waitMessageCount(t, sqlStore.MessageStore(), 3, 2*time.Second)
+	msgs, err := sqlStore.MessageStore().List(10, 0)
+	if err != nil {
+		t.Fatalf("list messages: %v", err)
+	}
+	if textPart(msgs[0]) != "[cron] ping" {
+		t.Fatalf("unexpected first message: %#v", msgs[0])
+	}
+}
+
+func TestCronSkipsOnlyHeartbeatsWhileBusy(t *testing.T) {
+	rt, prov := newBusyRuntime(t, testConfig(t), Options{})
+	defer close(prov.release)
+	rt.handleCronInput("cron", "run the health check script")
+	rt.handleCronInput(tools.HeartbeatSource, "heartbeat check")
+	if n := rt.Agent().QueueDepth(); n != 1 || rt.CronDropped() != 0 {
+		t.Fatalf("want only the cron prompt queued, depth=%d dropped=%d", n, rt.CronDropped())
+	}
+}
+
+func TestNewRejectsBadHeartbeatSchedule(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Heartbeat = config.HeartbeatConfig{Enabled: true, Schedule: "nope * * * *", Prompt: "ping"}
+	if _, err := New(cfg, Options{}); err == nil || !strings.Contains(err.Error(), "heartbeat.schedule") {
+		t.Fatalf("expected heartbeat.schedule error, got %v", err)
+	}
+}
+
+func TestNewClosesStoresWhenBuildFails(t *testing.T) {
+	reset := setShutdownHooksForTest()
+	defer reset()
+	var closed []string
+	shutdownSchedulerClose = func(s *tools.Scheduler) error { closed = append(closed, "scheduler"); return s.Close() }
+	shutdownSQLStoreClose = func(s store.Backend) error { closed = append(closed, "sqlStore"); return s.Close() }
+
+	cfg := testConfig(t)
+	cfg.Agent.Rotation.Timezone = "Nowhere/Atlantis"
+	if _, err := New(cfg, Options{}); err == nil || !strings.Contains(err.Error(), "agent.rotation.timezone") {
+		t.Fatalf("expected agent.rotation.timezone error, got %v", err)
+	}
+	if got := strings.Join(closed, ","); got != "scheduler,sqlStore" {
+		t.Fatalf("closed = %q", got)
+	}
+}
+
+type cronStubProvider struct{}
+
+func setSchedulerField(s *tools.Scheduler, field string, value any) {
+	v := reflect.ValueOf(s).Elem().FieldByName(field)
+	reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem().Set(reflect.ValueOf(value))
+}
+
+func waitMessageCount(t *testing.T, ms store.MessageStore, want int, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		n, err := ms.Count()
+		if err != nil {
+			t.Fatalf("count messages: %v", err)
+		}
+		if n >= want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	n, err := ms.Count()
+	if err != nil {
+		t.Fatalf("count messages: %v", err)
+	}
+	t.Fatalf("timed out waiting for %d messages (got %d)", want, n)
+}
+
+func textPart(msg *model.Message) string {
+	for _, part := range msg.Parts {
+		if txt, ok := part.(model.TextPart); ok {
+			return txt.Text
+		}
+	}
+	return ""
+}
+
+func (cronStubProvider) Model() provider.ModelInfo {
+	return provider.ModelInfo{}
+}
+
+func (cronStubProvider) Stream(context.Context, []model.Message, []provider.ToolDef, provider.ToolChoice) <-chan provider.ProviderEvent {
+	ch := make(chan provider.ProviderEvent, 4)
+	ch <- provider.ProviderEvent{Type: provider.EventContentDelta, Delta: "ok"}
+	ch <- provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "sleep-1", ToolName: "sleep"}
+	ch <- provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: "sleep-1"}
+	ch <- provider.ProviderEvent{Type: provider.EventComplete}
+	close(ch)
+	return ch
+}
+
+type scriptedProvider struct {
+	reply string
+}
+
+func (p scriptedProvider) Stream(context.Context, []model.Message, []provider.ToolDef, provider.ToolChoice) <-chan provider.ProviderEvent {
+	ch := make(chan provider.ProviderEvent, 4)
+	ch <- provider.ProviderEvent{Type: provider.EventContentDelta, Delta: p.reply}
+	ch <- provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "sleep-1", ToolName: "sleep"}
+	ch <- provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: "sleep-1"}
+	ch <- provider.ProviderEvent{Type: provider.EventComplete}
+	close(ch)
+	return ch
+}
+
+func (scriptedProvider) Model() provider.ModelInfo {
+	return provider.ModelInfo{}
+}
+
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	root := t.TempDir()
+	cfg := config.Default()
+	cfg.Provider = config.ProviderConfig{Backend: "lmstudio", Model: "test-model"}
+	cfg.Workspace = filepath.Join(root, "workspace")
+	cfg.StatePath = filepath.Join(root, "state")
+	return &cfg
+}
+
+func newTestRuntime(t *testing.T, cfg *config.Config, opts Options) *Runtime {
+	t.Helper()
+	rt, err := New(cfg, opts)
+	if err != nil {
+		t.Fatalf("new runtime: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := rt.Shutdown(context.Background()); err != nil {
+			t.Fatalf("shutdown: %v", err)
+		}
+	})
+	return rt
+}
+
+func TestNewRestoresPersistedQueue(t *testing.T) {
+	cfg := testConfig(t)
+	if err := os.MkdirAll(cfg.StatePath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.OpenSQLite(filepath.Join(cfg.StatePath, "sessions.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []string{"first", "second"} {
+		if err := s.Queue().Add(store.QueuedInput{ID: "in-" + c, Source: "signal:dm:+1", Content: c, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rt := newTestRuntime(t, cfg, Options{Provider: scriptedProvider{reply: "pong"}})
+	waitMessageCount(t, rt.Messages(), 4, 2*time.Second)
+	msgs, err := rt.Messages().List(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if textPart(msgs[0]) != "[signal:dm:+1] first" || textPart(msgs[1]) != "[signal:dm:+1] second" {
+		t.Fatalf("unexpected restored inputs: %q %q", textPart(msgs[0]), textPart(msgs[1]))
+	}
+}
+
+func TestNewRunOnceStoresExchange(t *testing.T) {
+	rt := newTestRuntime(t, testConfig(t), Options{Provider: scriptedProvider{reply: "pong"}})
+	if err := rt.RunOnce(context.Background(), agent.Input{Source: "test", Content: "ping"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	msgs, err := rt.Messages().List(10, 0)
+	if err != nil {
+		t.Fatalf("list messages: %v", err)
+	}
+	if len(msgs) != 3 {
+		t.Fatalf("want 3 messages, got %d", len(msgs))
+	}
+	if textPart(msgs[0]) != "[test] ping" || textPart(msgs[1]) != "pong" {
+		t.Fatalf("unexpected exchange: %q, %q", textPart(msgs[0]), textPart(msgs[1]))
+	}
+}
+
+func TestNewInjectWakesAgent(t *testing.T) {
+	rt := newTestRuntime(t, testConfig(t), Options{Provider: scriptedProvider{reply: "pong"}})
+	rt.Inject(agent.Input{Source: "test", Content: "ping"})
+	waitMessageCount(t, rt.Messages(), 3, 2*time.Second)
+}
+
+func TestNewCreatesWorkspaceAndState(t *testing.T) {
+	cfg := testConfig(t)
+	newTestRuntime(t, cfg, Options{Provider: scriptedProvider{}})
+	for _, dir := range []string{cfg.Workspace, cfg.StatePath} {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			t.Fatalf("missing directory %s: %v", dir, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(cfg.StatePath, "sessions.sqlite")); err != nil {
+		t.Fatalf("missing sessions store: %v", err)
+	}
+}
+
+func TestNewRejectsUnknownBackend(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Provider.Backend = "nope"
+	if _, err := New(cfg, Options{}); err == nil {
+		t.Fatal("expected unsupported backend error")
+	}
+}
+
+func TestNewProviderWrapsFallbacks(t *testing.T) {
+	cfg := config.ProviderConfig{Backend: "openrouter", APIKey: "k", Model: "remote", Fallbacks: []config.ProviderConfig{
+		{Backend: "lmstudio", Model: "local"},
+	}}
+	p, err := newProvider(cfg, "")
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	if _, ok := p.(*provider.Fallback); !ok {
+		t.Fatalf("want fallback chain, got %T", p)
+	}
+	if p.Model().ID != "remote" {
+		t.Fatalf("chain does not start on the primary: %+v", p.Model())
+	}
+	cfg.Fallbacks = nil
+	if p, _ := newProvider(cfg, ""); p.Model().ID != "remote" {
+		t.Fatalf("unexpected provider %T", p)
+	}
+	cfg.Fallbacks = []config.ProviderConfig{{Backend: "nope"}}
+	if _, err := newProvider(cfg, ""); err == nil {
+		t.Fatal("expected unsupported fallback backend error")
+	}
+}
+
+func TestSummaryProviderUsesSummaryModel(t *testing.T) {
+	cfg := config.ProviderConfig{Backend: "lmstudio", Model: "main", InputCostPerMTok: 3, OutputCostPerMTok: 15, CacheReadCostPerMTok: 0.3}
+	if p, err := summaryProvider(cfg, ""); err != nil || p != nil {
+		t.Fatalf("expected no summary provider without summary_model, got %v, %v", p, err)
+	}
+	cfg.SummaryModel = "cheap"
+	cfg.SummaryInputCostPerMTok = 0.5
+	cfg.SummaryOutputCostPerMTok = 2
+	cfg.Fallbacks = []config.ProviderConfig{{Backend: "lmstudio", Model: "other"}}
+	p, err := summaryProvider(cfg, "")
+	if err != nil {
+		t.Fatalf("summary provider: %v", err)
+	}
+	if _, ok := p.(*provider.Fallback); ok {
+		t.Fatal("summary provider should not use the fallback chain")
+	}
+	info := p.Model()
+	if info.ID != "cheap" || info.CostPerInputToken != 0.5/1_000_000 || info.CostPerOutputToken != 2.0/1_000_000 || info.CostPerCacheReadToken != 0 {
+		t.Fatalf("unexpected summary model: %+v", info)
+	}
+}
+
+func TestToolResultSummarySavesInWorkspace(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Tools.AutoSummarizeOver = 1000
+	if s := toolResultSummary(cfg); s.Over != 1000 || s.Dir != filepath.Join(cfg.Workspace, "tool-results") {
+		t.Fatalf("full results must be saved in the workspace, got %+v", s)
+	}
+}
+
+func TestToolTimeoutsFromConfig(t *testing.T) {
+	got := toolTimeouts(config.ToolsConfig{DefaultTimeoutSeconds: 1800, Timeouts: map[string]int{"fetch": 60}})
+	if got.Default != 30*time.Minute || got.PerTool["fetch"] != time.Minute || len(got.PerTool) != 1 {
+		t.Fatalf("unexpected tool timeouts: %+v", got)
+	}
+}
+
+func TestToolPolicyNamesMustMatchTools(t *testing.T) {
+	toolList := tools.MainAgentTools(tools.MainToolDeps{})
+	ok := config.ToolsConfig{
+		Disabled: []string{"exec"},
+		Sources:  map[string]config.ToolPolicy{"signal:dm:": {Enabled: []string{"read", "message"}}},
+	}
+	if err := checkToolPolicyNames(toolList, ok); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bad := config.ToolsConfig{Sources: map[string]config.ToolPolicy{"signal:dm:": {Disabled: []string{"exce"}}}}
+	err := checkToolPolicyNames(toolList, bad)
+	if err == nil || !strings.Contains(err.Error(), `tools.sources["signal:dm:"].disabled: unknown tool "exce"`) {
+		t.Fatalf("expected unknown tool error, got: %v", err)
+	}
+	got := toolPolicies(ok)
+	if got.Default.Disabled[0] != "exec" || len(got.Sources["signal:dm:"].Enabled) != 2 {
+		t.Fatalf("unexpected tool policies: %+v", got)
+	}
+}
+
+func TestNewWrapToolsReplacesToolList(t *testing.T) {
+	var names []string
+	newTestRuntime(t, testConfig(t), Options{
+		Provider: scriptedProvider{},
+		WrapTools: func(toolList []tools.Tool) []tools.Tool {
+			for _, tl := range toolList {
+				names = append(names, tl.Name())
+			}
+			return toolList[:1]
+		},
+	})
+	if len(names) != len(tools.MainAgentTools(tools.MainToolDeps{})) {
+		t.Fatalf("wrap saw %d tools", len(names))
+	}
+}
+
+func TestNewStartsWebhookOnlyWhenRequested(t *testing.T) {
+	listen := reserveListenAddr(t)
+	hooks := config.WebhookConfig{Listen: listen, Hooks: []config.WebhookDef{{ID: "t", Path: "/t", Format: "text"}}}
+	cfg := testConfig(t)
+	cfg.Webhook = hooks
+	newTestRuntime(t, cfg, Options{Provider: scriptedProvider{}})
+	if _, err := http.Get("http://" + listen + "/health"); err == nil {
+		t.Fatal("webhook server started without Options.Webhook")
+	}
+
+	cfg = testConfig(t)
+	cfg.Webhook = hooks
+	rt := newTestRuntime(t, cfg, Options{Provider: scriptedProvider{reply: "ok"}, Webhook: true})
+	waitHTTP(t, "http://"+listen+"/health")
+	res, err := http.Post("http://"+listen+"/t", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("post webhook: %v", err)
+	}
+	res.Body.Close()
+	waitMessageCount(t, rt.Messages(), 3, 2*time.Second)
+}
+
+func TestNewPostsResponsesToOutboundWebhook(t *testing.T) {
+	got := make(chan webhook.OutboundEvent, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev webhook.OutboundEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Errorf("decode outbound event: %v", err)
+		}
+		got <- ev
+	}))
+	defer srv.Close()
+	cfg := testConfig(t)
+	cfg.Webhook.Outbound.URL = srv.URL
+	rt := newTestRuntime(t, cfg, Options{Provider: scriptedProvider{reply: "pong"}})
+
+	if err := rt.RunOnce(context.Background(), agent.Input{Source: "test", Content: "ping"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	select {
+	case ev := <-got:
+		if ev.Type != "response" || ev.Source != "test" || ev.Text != "pong" {
+			t.Fatalf("unexpected outbound event: %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("outbound webhook was not called")
+	}
+}
+
+func TestOutboundEventCarriesToolFields(t *testing.T) {
+	ev := outboundEvent(agent.AgentEvent{
+		Type:       agent.EventToolResult,
+		Source:     "webhook:ci",
+		Text:       "ok",
+		ToolCallID: "c1",
+		ToolName:   "exec",
+		Params:     json.RawMessage(`{"command":"npm test"}`),
+		DurationMs: 1500,
+	})
+	data, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, want := range []string{`"type":"tool_result"`, `"tool_call_id":"c1"`, `"tool_name":"exec"`, `"params":{"command":"npm test"}`, `"duration_ms":1500`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("%s missing from %s", want, data)
+		}
+	}
+	broken := outboundEvent(agent.AgentEvent{Type: agent.EventToolStart, Params: json.RawMessage(`{"command":`)})
+	if _, err := json.Marshal(broken); err != nil || broken.Params != nil {
+		t.Fatalf("invalid params not dropped: %v %s", err, broken.Params)
+	}
+}
+
+func reserveListenAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	if err := ln.Close(); err != nil {
+		t.Fatalf("close listener: %v", err)
+	}
+	return addr
+}
+
+func waitHTTP(t *testing.T, url string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		res, err := http.Get(url)
+		if err == nil {
+			res.Body.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", url)
+}
+
+// execSecretProvider calls exec with env_from on the first turn and sleeps
+// on the next one.
+type execSecretProvider struct {
+	calls *atomic.Int32
+}
+
+func (p execSecretProvider) Stream(context.Context, []model.Message, []provider.ToolDef, provider.ToolChoice) <-chan provider.ProviderEvent {
+	ch := make(chan provider.ProviderEvent, 4)
+	if p.calls.Add(1) == 1 {
+		ch <- provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "exec-1", ToolName: "exec"}
+		ch <- provider.ProviderEvent{Type: provider.EventToolUseDelta, ToolCallID: "exec-1", Delta: `{"command":"echo \"$API_TOKEN\"","env_from":["API_TOKEN"]}`}
+		ch <- provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: "exec-1"}
+	} else {
+		ch <- provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "sleep-1", ToolName: "sleep"}
+		ch <- provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCallID: "sleep-1"}
+	}
+	ch <- provider.ProviderEvent{Type: provider.EventComplete}
+	close(ch)
+	return ch
+}
+
+func (execSecretProvider) Model() provider.ModelInfo {
+	return provider.ModelInfo{}
+}
+
+func TestExecSecretsStayOutOfThreadAndLogs(t *testing.T) {
+	const secret = "tok-9f8e7d6c5b4a"
+	cfg := testConfig(t)
+	if err := os.MkdirAll(cfg.StatePath, 0o755); err != nil {
+		t.Fatalf("create state path: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(cfg.StatePath, tools.SecretsFile), []byte("API_TOKEN="+secret+"\n"), 0o600); err != nil {
+		t.Fatalf("write secrets: %v", err)
+	}
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	rt := newTestRuntime(t, cfg, Options{Provider: execSecretProvider{calls: &atomic.Int32{}}})
+
+	if err := rt.RunOnce(context.Background(), agent.Input{Source: "test", Content: "use the token"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	msgs, err := rt.Messages().List(10, 0)
+	if err != nil {
+		t.Fatalf("list messages: %v", err)
+	}
+	raw, err := json.Marshal(msgs)
+	if err != nil {
+		t.Fatalf("marshal messages: %v", err)
+	}
+	if !strings.Contains(string(raw), "[secret:API_TOKEN]") {
+		t.Fatalf("exec result missing redacted output: %s", raw)
+	}
+	if strings.Contains(string(raw), secret) {
+		t.Fatalf("secret leaked into thread: %s", raw)
+	}
+	if strings.Contains(logs.String(), secret) {
+		t.Fatalf("secret leaked into logs: %s", logs.String())
+	}
+}
+
+func TestNewInjectsStartupPromptOnce(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Agent.StartupPrompt = "Briefing: check reminders"
+	rt := newTestRuntime(t, cfg, Options{Provider: scriptedProvider{reply: "ok"}})
+
+	waitMessageCount(t, rt.Messages(), 3, 2*time.Second)
+	rt.Inject(agent.Input{Source: "test", Content: "hello"})
+	waitMessageCount(t, rt.Messages(), 6, 2*time.Second)
+	msgs, err := rt.Messages().List(10, 0)
+	if err != nil {
+		t.Fatalf("list messages: %v", err)
+	}
+	if textPart(msgs[0]) != "[startup] Briefing: check reminders" {
+		t.Fatalf("startup prompt is not the first message: %#v", msgs[0])
+	}
+	startups := 0
+	for _, m := range msgs {
+		if strings.HasPrefix(textPart(m), "[startup]") {
+			startups++
+		}
+	}
+	if startups != 1 {
+		t.Fatalf("want 1 startup message, got %d", startups)
+	}
+}
+
+func TestNewSkipsEmptyStartupPrompt(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Agent.StartupPrompt = "  "
+	rt := newTestRuntime(t, cfg, Options{Provider: scriptedProvider{reply: "ok"}})
+	time.Sleep(50 * time.Millisecond)
+	if n, err := rt.Messages().Count(); err != nil || n != 0 {
+		t.Fatalf("want empty thread, got %d messages (err=%v)", n, err)
+	}
+}
+
+func TestWebhookSyncWaitsForTurnReply(t *testing.T) {
+	p := &messageCallProvider{args: []string{`{"to":"webhook:deploy","content":"deployed v2"}`}}
+	rt := newTestRuntime(t, testConfig(t), Options{Provider: p})
+	wait, err := rt.runWebhookSync("webhook:deploy", "ship it", map[string]string{"hook_id": "deploy"})
+	if err != nil {
+		t.Fatalf("run sync: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reply, err := wait(ctx)
+	if err != nil || reply != "deployed v2" {
+		t.Fatalf("reply = %q, %v", reply, err)
+	}
+}
+
+func TestWebhookSyncReplyIsEmptyWithoutMessage(t *testing.T) {
+	rt := newTestRuntime(t, testConfig(t), Options{Provider: scriptedProvider{reply: "private notes"}})
+	wait, err := rt.runWebhookSync("webhook:deploy", "ship it", nil)
+	if err != nil {
+		t.Fatalf("run sync: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if reply, err := wait(ctx); err != nil || reply != "" {
+		t.Fatalf("reply = %q, %v", reply, err)
+	}
+	if err := rt.sendMessage(ctx, "webhook:deploy", "late"); err == nil {
+		t.Fatal("reply accepted with no request waiting")
+	}
+}
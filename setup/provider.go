@@ -14,7 +14,7 @@ import (
 func configureProvider(u *ui, p *config.ProviderConfig) error {
 	u.section("Provider")
 	prevBackend := p.Backend
-	backend, err := u.chooseOne("Provider backend", []string{"lmstudio", "openrouter", "codex"}, p.Backend)
+	backend, err := u.chooseOne("Provider backend", []string{"lmstudio", "openrouter", "codex", "anthropic", "ollama"}, p.Backend)
 	if err != nil {
 		return err
 	}
@@ -45,6 +45,10 @@ func configureProviderAuth(u *ui, p *config.ProviderConfig) error {
 		p.APIKey = "lmstudio"
 		return nil
 	}
+	if p.Backend == "ollama" {
+		p.APIKey = ""
+		return nil
+	}
 	if p.Backend != "codex" {
 		secret, err := u.askRequiredSecret("Provider API key", p.APIKey)
 		if err != nil {
@@ -0,0 +1,103 @@
+package miclaw
+
+import (
+	"context"
+	"time"
+
+	"github.com/agusx1211/miclaw/agent"
+	"github.com/agusx1211/miclaw/logging"
+	"github.com/agusx1211/miclaw/memory"
+	"github.com/agusx1211/miclaw/store"
+	"github.com/agusx1211/miclaw/tools"
+)
+
+var (
+	shutdownPollInterval = 10 * time.Millisecond
+
+	shutdownAgentStop      = func(a *agent.Agent) { a.StopAccepting() }
+	shutdownAgentCancel    = func(a *agent.Agent) { a.Cancel() }
+	shutdownAgentIsActive  = func(a *agent.Agent) bool { return a.IsActive() }
+	shutdownAgentPending   = func(a *agent.Agent) []agent.Input { return a.Pending() }
+	shutdownSchedulerStop  = func(s *tools.Scheduler) { s.Stop() }
+	shutdownSchedulerClose = func(s *tools.Scheduler) error {
+		return s.Close()
+	}
+	shutdownMemStoreClose = func(s *memory.Store) error {
+		return s.Close()
+	}
+	shutdownSQLStoreClose = func(s store.Backend) error {
+		return s.Close()
+	}
+)
+
+// Shutdown refuses new input, stops the scheduler, and gives the running
+// turn up to shutdown.grace_seconds to finish before cancelling it. It then
+// stops the transports, waits for the agent to go idle, logs the inputs
+// left queued for the next start, and closes the stores. It returns
+// ctx.Err() if ctx ends first; the drain keeps running in the background.
+// Calling Shutdown again waits for the first call's drain.
+func (r *Runtime) Shutdown(ctx context.Context) error {
+
+	done := make(chan struct{})
+	go func() {
+		r.once.Do(r.drain)
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Runtime) drain() {
+
+	shutdownAgentStop(r.agent)
+	shutdownSchedulerStop(r.scheduler)
+	if !r.waitIdle(r.grace) {
+		logging.Infof("[shutdown] grace=%s expired, cancelling turn", r.grace)
+	}
+	shutdownAgentCancel(r.agent)
+	r.cancel()
+	r.wg.Wait()
+	for shutdownAgentIsActive(r.agent) {
+		time.Sleep(shutdownPollInterval)
+	}
+	r.logPending()
+	r.close()
+}
+
+// waitIdle polls until the agent is idle or grace has passed, and reports
+// whether it went idle.
+func (r *Runtime) waitIdle(grace time.Duration) bool {
+
+	deadline := time.Now().Add(grace)
+	for shutdownAgentIsActive(r.agent) {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(shutdownPollInterval)
+	}
+	return true
+}
+
+// logPending records the inputs that were accepted but not handled. They
+// stay in the queue store and run after the next start.
+func (r *Runtime) logPending() {
+
+	for _, in := range shutdownAgentPending(r.agent) {
+		logging.Infof("[shutdown] queued source=%s msg=%q", in.Source, compactRuntimeText(in.Content))
+	}
+}
+
+func (r *Runtime) close() {
+
+	if r.scheduler != nil {
+		_ = shutdownSchedulerClose(r.scheduler)
+	}
+	if r.memStore != nil {
+		_ = shutdownMemStoreClose(r.memStore)
+	}
+	_ = shutdownSQLStoreClose(r.sqlStore)
+}
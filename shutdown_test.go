@@ -0,0 +1,170 @@
+package miclaw
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/agent"
+	"github.com/agusx1211/miclaw/memory"
+	"github.com/agusx1211/miclaw/store"
+	"github.com/agusx1211/miclaw/tools"
+)
+
+func TestShutdownSequenceOrder(t *testing.T) {
+	reset := setShutdownHooksForTest()
+	defer reset()
+	var mu sync.Mutex
+	order := []string{}
+	add := func(v string) { mu.Lock(); order = append(order, v); mu.Unlock() }
+
+	release := make(chan struct{})
+	r := &Runtime{agent: new(agent.Agent), scheduler: new(tools.Scheduler), memStore: new(memory.Store), sqlStore: new(store.SQLiteStore)}
+	r.wg.Add(1)
+	go func() { <-release; add("wg.Wait"); r.wg.Done() }()
+
+	shutdownAgentStop = func(*agent.Agent) { add("agent.Stop") }
+	shutdownAgentCancel = func(*agent.Agent) { add("agent.Cancel") }
+	shutdownSchedulerStop = func(*tools.Scheduler) { add("scheduler.Stop") }
+	shutdownAgentIsActive = func(*agent.Agent) bool { return false }
+	shutdownAgentPending = func(*agent.Agent) []agent.Input { add("pending"); return nil }
+	shutdownSchedulerClose = func(*tools.Scheduler) error { add("scheduler.Close"); return nil }
+	shutdownMemStoreClose = func(*memory.Store) error { add("memStore.Close"); return nil }
+	shutdownSQLStoreClose = func(store.Backend) error { add("sqlStore.Close"); return nil }
+	r.cancel = func() { add("cancel"); close(release) }
+
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	got := strings.Join(order, ",")
+	want := "agent.Stop,scheduler.Stop,agent.Cancel,cancel,wg.Wait,pending,scheduler.Close,memStore.Close,sqlStore.Close"
+	if got != want {
+		t.Fatalf("order mismatch\nwant: %s\ngot:  %s", want, got)
+	}
+}
+
+func TestShutdownReturnsContextErrorWhenDrainBlocks(t *testing.T) {
+	reset := setShutdownHooksForTest()
+	defer reset()
+	release := make(chan struct{})
+	closed := make(chan struct{})
+
+	shutdownAgentCancel = func(*agent.Agent) {}
+	shutdownSchedulerStop = func(*tools.Scheduler) {}
+	shutdownAgentIsActive = func(*agent.Agent) bool { return false }
+	shutdownAgentPending = func(*agent.Agent) []agent.Input { return nil }
+	shutdownSchedulerClose = func(*tools.Scheduler) error { <-release; return nil }
+	shutdownMemStoreClose = func(*memory.Store) error { return nil }
+	shutdownSQLStoreClose = func(store.Backend) error { close(closed); return nil }
+
+	r := &Runtime{agent: new(agent.Agent), scheduler: new(tools.Scheduler), sqlStore: new(store.SQLiteStore), cancel: func() {}}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want deadline exceeded, got %v", err)
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("drain did not finish after release")
+	}
+}
+
+func TestShutdownTwiceDrainsOnce(t *testing.T) {
+	reset := setShutdownHooksForTest()
+	defer reset()
+	calls := 0
+	shutdownAgentCancel = func(*agent.Agent) {}
+	shutdownSchedulerStop = func(*tools.Scheduler) {}
+	shutdownAgentIsActive = func(*agent.Agent) bool { return false }
+	shutdownAgentPending = func(*agent.Agent) []agent.Input { return nil }
+	shutdownSchedulerClose = func(*tools.Scheduler) error { calls++; return nil }
+	shutdownSQLStoreClose = func(store.Backend) error { return nil }
+
+	r := &Runtime{agent: new(agent.Agent), scheduler: new(tools.Scheduler), sqlStore: new(store.SQLiteStore), cancel: func() {}}
+	for i := 0; i < 2; i++ {
+		if err := r.Shutdown(context.Background()); err != nil {
+			t.Fatalf("shutdown %d: %v", i, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("scheduler closed %d times", calls)
+	}
+}
+
+func TestShutdownLetsTurnFinishWithinGrace(t *testing.T) {
+	reset := setShutdownHooksForTest()
+	defer reset()
+	var mu sync.Mutex
+	order := []string{}
+	add := func(v string) { mu.Lock(); order = append(order, v); mu.Unlock() }
+	polls := 0
+
+	shutdownAgentStop = func(*agent.Agent) { add("agent.Stop") }
+	shutdownAgentCancel = func(*agent.Agent) { add("agent.Cancel") }
+	shutdownSchedulerStop = func(*tools.Scheduler) {}
+	shutdownAgentIsActive = func(*agent.Agent) bool {
+		polls++
+		if polls == 3 {
+			add("idle")
+		}
+		return polls < 3
+	}
+	shutdownAgentPending = func(*agent.Agent) []agent.Input {
+		return []agent.Input{{Source: "signal:dm:+1", Content: "later"}}
+	}
+	shutdownSchedulerClose = func(*tools.Scheduler) error { return nil }
+	shutdownSQLStoreClose = func(store.Backend) error { return nil }
+
+	r := &Runtime{agent: new(agent.Agent), scheduler: new(tools.Scheduler), sqlStore: new(store.SQLiteStore), cancel: func() {}, grace: time.Second}
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if got := strings.Join(order, ","); got != "agent.Stop,idle,agent.Cancel" {
+		t.Fatalf("turn was cancelled before it finished: %s", got)
+	}
+}
+
+func TestShutdownCancelsTurnAfterGrace(t *testing.T) {
+	reset := setShutdownHooksForTest()
+	defer reset()
+	var cancelled atomic.Bool
+
+	shutdownAgentStop = func(*agent.Agent) {}
+	shutdownAgentCancel = func(*agent.Agent) { cancelled.Store(true) }
+	shutdownSchedulerStop = func(*tools.Scheduler) {}
+	shutdownAgentIsActive = func(*agent.Agent) bool { return !cancelled.Load() }
+	shutdownAgentPending = func(*agent.Agent) []agent.Input { return nil }
+	shutdownSchedulerClose = func(*tools.Scheduler) error { return nil }
+	shutdownSQLStoreClose = func(store.Backend) error { return nil }
+
+	r := &Runtime{agent: new(agent.Agent), scheduler: new(tools.Scheduler), sqlStore: new(store.SQLiteStore), cancel: func() {}, grace: 50 * time.Millisecond}
+	start := time.Now()
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if !cancelled.Load() {
+		t.Fatal("turn was not cancelled after the grace period")
+	}
+	if elapsed := time.Since(start); elapsed < r.grace {
+		t.Fatalf("cancelled after %s, before the %s grace period", elapsed, r.grace)
+	}
+}
+
+func setShutdownHooksForTest() func() {
+	oldAgentStop, oldAgentPending := shutdownAgentStop, shutdownAgentPending
+	oldAgentCancel, oldAgentIsActive := shutdownAgentCancel, shutdownAgentIsActive
+	oldSchedulerStop, oldSchedulerClose := shutdownSchedulerStop, shutdownSchedulerClose
+	oldMemClose, oldSQLClose := shutdownMemStoreClose, shutdownSQLStoreClose
+	return func() {
+		shutdownAgentStop, shutdownAgentPending = oldAgentStop, oldAgentPending
+		shutdownAgentCancel, shutdownAgentIsActive = oldAgentCancel, oldAgentIsActive
+		shutdownSchedulerStop, shutdownSchedulerClose = oldSchedulerStop, oldSchedulerClose
+		shutdownMemStoreClose, shutdownSQLStoreClose = oldMemClose, oldSQLClose
+	}
+}
@@ -0,0 +1,399 @@
+package miclaw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/agusx1211/miclaw/agent"
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/logging"
+	signalpipe "github.com/agusx1211/miclaw/signal"
+	"github.com/agusx1211/miclaw/store"
+)
+
+func (r *Runtime) startSignalPipeline(ctx context.Context) {
+
+	pipeline := signalpipe.NewPipeline(
+		r.signal,
+		r.cfg.Signal,
+		func(source, content string, metadata map[string]string) {
+			r.handleSignalInput(ctx, source, content, metadata)
+		},
+	)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for {
+			err := pipeline.Start(ctx)
+			if err == nil || errors.Is(err, context.Canceled) {
+				return
+			}
+			if strings.Contains(err.Error(), "signal events stream closed") {
+				logging.Infof("[signal] pipeline closed; retrying in 1s")
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Second):
+					continue
+				}
+			}
+			r.errCh <- fmt.Errorf("signal pipeline: %v", err)
+			return
+		}
+	}()
+}
+
+// startSignalEvents relays agent events of Signal-triggered turns to their
+// sender: automatic compaction notices and turns stopped by a cost limit, the
+// generation timeout or a rejected API key. With signal.stream_responses,
+// message tool calls to Signal targets are delivered paragraph by paragraph
+// while they are generated. Sends are not cut off by shutdown: a reply
+// already in flight completes, and events published before the stop are
+// flushed before the goroutine exits.
+func (r *Runtime) startSignalEvents(ctx context.Context) {
+
+	if r.cfg.Signal.StreamResponses {
+		r.agent.SetMessageStream(r.streamMessage)
+	}
+	events, unsub := r.agent.Events().SubscribeQueued(forwardsToSignal)
+	context.AfterFunc(ctx, unsub)
+	sendCtx := context.WithoutCancel(ctx)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for ev := range events {
+			r.forwardEvent(sendCtx, ev)
+		}
+	}()
+}
+
+func (r *Runtime) forwardEvent(ctx context.Context, ev agent.AgentEvent) {
+
+	text := ev.Text
+	if ev.Type == agent.EventError {
+		text = turnErrorReply(ev.Error)
+	}
+	if err := sendSignalMessage(ctx, r.signal, r.cfg.Signal, ev.Source, text); err != nil {
+		logging.Errorf("[signal] event_error type=%s to=%s err=%v", ev.Type, ev.Source, err)
+	}
+}
+
+// forwardsToSignal picks the events startSignalEvents relays.
+func forwardsToSignal(ev agent.AgentEvent) bool {
+
+	if !strings.HasPrefix(ev.Source, "signal:") {
+		return false
+	}
+	if ev.Type == agent.EventError {
+		return errors.Is(ev.Error, agent.ErrBudgetExceeded) || errors.Is(ev.Error, agent.ErrGenerationTimeout) || errors.Is(ev.Error, agent.ErrProviderAuth)
+	}
+	return ev.Type == agent.EventCompaction
+}
+
+// providerAuthReply leaves out the backend's response, which may echo part
+// of the key.
+const providerAuthReply = "I can't reach my model right now: the provider rejected my API key. Ask my operator to check provider.api_key; your message was not answered."
+
+// turnErrorReply tells a Signal sender why their turn stopped.
+func turnErrorReply(err error) string {
+
+	if errors.Is(err, agent.ErrGenerationTimeout) {
+		return fmt.Sprintf("%v and was stopped. Send a message to continue where it left off.", err)
+	}
+	if errors.Is(err, agent.ErrProviderAuth) {
+		return providerAuthReply
+	}
+	return budgetExceededReply(err)
+}
+
+func budgetExceededReply(err error) string {
+
+	return fmt.Sprintf("%v. Send /unlock to reset today's total, or /new to start a new thread.", err)
+}
+
+func (r *Runtime) handleSignalInput(ctx context.Context, source, content string, metadata map[string]string) {
+
+	logging.Infof("[signal] in source=%s msg=%q", source, compactRuntimeText(content))
+	if r.handleSignalCommand(ctx, source, content) {
+		return
+	}
+	r.typing.SetAutoTarget(source)
+	active := r.agent.IsActive()
+	if err := r.agent.Inject(agent.Input{Source: source, Content: content, Metadata: metadata, Priority: signalPriority(source)}); err != nil {
+		logging.Infof("[signal] rejected source=%s err=%v", source, err)
+		if errors.Is(err, agent.ErrShuttingDown) {
+			_ = sendSignalMessage(ctx, r.signal, r.cfg.Signal, source, shuttingDownReply)
+			return
+		}
+		if r.overload.first(source) {
+			_ = sendSignalMessage(ctx, r.signal, r.cfg.Signal, source, overloadedReply)
+		}
+		return
+	}
+	r.overload.clear(source)
+	if active {
+		if err := r.typing.StartAuto(r.sendTyping); err != nil {
+			logging.Errorf("[signal] typing_auto_error err=%v", err)
+		}
+	}
+}
+
+// signalPriority puts direct messages in the high-priority lane, ahead of
+// group chatter, webhooks and cron.
+func signalPriority(source string) agent.Priority {
+
+	if strings.HasPrefix(source, "signal:dm:") {
+		return agent.PriorityHigh
+	}
+	return agent.PriorityNormal
+}
+
+func parseSignalCommand(content string) string {
+	switch strings.ToLower(strings.TrimSpace(content)) {
+	case "/new":
+		return "/new"
+	case "/purge":
+		return "/purge"
+	case "/compact":
+		return "/compact"
+	case "/unlock":
+		return "/unlock"
+	default:
+		return ""
+	}
+}
+
+func (r *Runtime) handleSignalCommand(ctx context.Context, source, content string) bool {
+	cmd := parseSignalCommand(content)
+	if cmd == "" {
+		return false
+	}
+	if cmd == "/unlock" {
+		r.unlockBudget(ctx, source)
+		return true
+	}
+	deadline := time.Now().Add(time.Duration(r.cfg.Signal.CommandWaitSeconds) * time.Second)
+	if !r.acquireCommand(ctx, deadline) {
+		_ = sendSignalMessage(ctx, r.signal, r.cfg.Signal, source, "another command is running; try "+cmd+" again in a few seconds")
+		return true
+	}
+	if cmd == "/new" || cmd == "/purge" {
+		r.agent.Cancel()
+	}
+	for r.agent.IsActive() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if r.agent.IsActive() {
+		r.releaseCommand()
+		_ = sendSignalMessage(ctx, r.signal, r.cfg.Signal, source, "agent is busy; try "+cmd+" again in a few seconds")
+		return true
+	}
+	if cmd == "/new" || cmd == "/purge" {
+		r.resetThread(ctx, source, cmd == "/purge")
+		return true
+	}
+	_ = sendSignalMessage(ctx, r.signal, r.cfg.Signal, source, "compacting context")
+	go r.compactThread(source)
+	return true
+}
+
+// acquireCommand takes the lifecycle command token, waiting until deadline
+// for a running command to finish. The caller must call releaseCommand.
+func (r *Runtime) acquireCommand(ctx context.Context, deadline time.Time) bool {
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case r.commands <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (r *Runtime) releaseCommand() {
+
+	<-r.commands
+}
+
+// resetThread starts an empty thread. /new archives the old one under a
+// "new-" period key, where transcript and history search still find it;
+// /purge deletes it.
+func (r *Runtime) resetThread(ctx context.Context, source string, purge bool) {
+
+	defer r.releaseCommand()
+	_ = r.typing.StopAll(r.sendTypingStop)
+	cmd, reply := "/new", "thread reset"
+	var err error
+	if purge {
+		cmd, reply = "/purge", "thread deleted"
+		err = r.messages.DeleteAll()
+	} else {
+		var period string
+		period, err = archiveThread(r.messages, time.Now())
+		if period != "" {
+			reply = "thread reset; previous thread archived as " + period
+		}
+	}
+	if err != nil {
+		logging.Errorf("[signal] command=%s err=%v", cmd, err)
+		_ = sendSignalMessage(ctx, r.signal, r.cfg.Signal, source, "failed to reset thread")
+		return
+	}
+	if err := r.agent.ResetThreadCost(); err != nil {
+		logging.Errorf("[signal] command=%s err=%v", cmd, err)
+	}
+	_ = sendSignalMessage(ctx, r.signal, r.cfg.Signal, source, reply)
+}
+
+// archiveThread moves the thread into the archive under a period key named
+// after now and returns the key, or "" when the thread was already empty.
+func archiveThread(messages store.MessageStore, now time.Time) (string, error) {
+
+	n, err := messages.Count()
+	if err != nil || n == 0 {
+		return "", err
+	}
+	period := "new-" + now.UTC().Format("20060102T150405Z")
+	return period, messages.Archive(period, nil)
+}
+
+// unlockBudget clears today's cost total so turns stopped by
+// limits.max_cost_per_day can run again.
+func (r *Runtime) unlockBudget(ctx context.Context, source string) {
+
+	if err := r.agent.UnlockBudget(); err != nil {
+		logging.Errorf("[signal] command=/unlock err=%v", err)
+		_ = sendSignalMessage(ctx, r.signal, r.cfg.Signal, source, "failed to unlock budget")
+		return
+	}
+	_ = sendSignalMessage(ctx, r.signal, r.cfg.Signal, source, "budget unlocked for today")
+}
+
+func (r *Runtime) compactThread(source string) {
+
+	defer r.releaseCommand()
+	compactCtx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if err := r.agent.Compact(compactCtx); err != nil {
+		logging.Errorf("[signal] command=/compact err=%v", err)
+		_ = sendSignalMessage(context.Background(), r.signal, r.cfg.Signal, source, "compaction failed")
+		return
+	}
+	_ = sendSignalMessage(context.Background(), r.signal, r.cfg.Signal, source, "compaction complete")
+}
+
+// sendMessage delivers a message tool call: to a Signal chat, or as the
+// answer to a sync webhook request waiting on a webhook source.
+func (r *Runtime) sendMessage(ctx context.Context, to, content string) error {
+	if strings.HasPrefix(to, "webhook:") {
+		if !r.agent.Reply(to, content) {
+			return fmt.Errorf("no sync webhook request from %s is waiting for a reply", to)
+		}
+		return nil
+	}
+	if r.signal == nil {
+		return fmt.Errorf("signal is disabled")
+	}
+	r.typing.Clear(to)
+	return sendSignalMessage(ctx, r.signal, r.cfg.Signal, to, content)
+}
+
+// streamMessage delivers one paragraph of a message call that is still being
+// generated. Only Signal targets are streamed; the tool sends others whole.
+func (r *Runtime) streamMessage(ctx context.Context, to, text string) error {
+
+	if !strings.HasPrefix(to, "signal:") {
+		return fmt.Errorf("%s is not streamed", to)
+	}
+	return r.sendMessage(ctx, to, text)
+}
+
+func (r *Runtime) sendTyping(ctx context.Context, to string) error {
+	return sendSignalTyping(ctx, r.signal, to)
+}
+
+func (r *Runtime) sendTypingStop(ctx context.Context, to string) error {
+	return sendSignalTypingStop(ctx, r.signal, to)
+}
+
+func sendSignalMessage(ctx context.Context, client *signalpipe.Client, cfg config.SignalConfig, to, content string) error {
+	logging.Infof("[signal] out to=%s msg=%q", to, compactRuntimeText(content))
+	kind, target, err := parseSignalTarget(to)
+	if err != nil {
+		return err
+	}
+	text, styles := signalpipe.MarkdownToSignal(content)
+	limit := cfg.TextChunkLimit
+	if limit <= 0 {
+		limit = len(text)
+	}
+	if kind == "group" {
+		for _, chunk := range signalpipe.ChunkText(text, limit) {
+			if err := client.SendGroup(ctx, target, chunk, styles); err != nil {
+				logging.Errorf("[signal] out_error to=%s err=%v", to, err)
+				return err
+			}
+		}
+		logging.Debugf("[signal] out_ok to=%s", to)
+		return nil
+	}
+	for _, chunk := range signalpipe.ChunkText(text, limit) {
+		if err := client.Send(ctx, target, chunk, styles); err != nil {
+			logging.Errorf("[signal] out_error to=%s err=%v", to, err)
+			return err
+		}
+	}
+	logging.Debugf("[signal] out_ok to=%s", to)
+	return nil
+}
+
+func sendSignalTyping(ctx context.Context, client *signalpipe.Client, to string) error {
+	logging.Debugf("[signal] typing to=%s", to)
+	kind, target, err := parseSignalTarget(to)
+	if err != nil {
+		return err
+	}
+	if kind != "dm" {
+		return fmt.Errorf("typing target must be signal:dm:<recipient>")
+	}
+	if err := client.SendTyping(ctx, target); err != nil {
+		logging.Errorf("[signal] typing_error to=%s err=%v", to, err)
+		return err
+	}
+	logging.Debugf("[signal] typing_ok to=%s", to)
+	return nil
+}
+
+func sendSignalTypingStop(ctx context.Context, client *signalpipe.Client, to string) error {
+	logging.Debugf("[signal] typing_stop to=%s", to)
+	kind, target, err := parseSignalTarget(to)
+	if err != nil {
+		return err
+	}
+	if kind != "dm" {
+		return fmt.Errorf("typing target must be signal:dm:<recipient>")
+	}
+	if err := client.SendTypingStop(ctx, target); err != nil {
+		logging.Errorf("[signal] typing_stop_error to=%s err=%v", to, err)
+		return err
+	}
+	logging.Debugf("[signal] typing_stop_ok to=%s", to)
+	return nil
+}
+
+func parseSignalTarget(to string) (string, string, error) {
+	parts := strings.SplitN(to, ":", 3)
+	if len(parts) != 3 || parts[0] != "signal" || strings.TrimSpace(parts[2]) == "" {
+		return "", "", fmt.Errorf("invalid signal target %q", to)
+	}
+	if parts[1] != "dm" && parts[1] != "group" {
+		return "", "", fmt.Errorf("invalid signal target %q", to)
+	}
+	return parts[1], strings.TrimSpace(parts[2]), nil
+}
@@ -3,26 +3,33 @@ package signal
 import (
 	"context"
 	"fmt"
-	"log"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/logging"
 )
 
 type EnqueueFunc func(sessionID, content string, metadata map[string]string)
 
 type Pipeline struct {
-	client  *Client
-	cfg     config.SignalConfig
-	enqueue EnqueueFunc
+	client     *Client
+	cfg        config.SignalConfig
+	enqueue    EnqueueFunc
+	preprocess []PreprocessFunc
+	presence   groupPresence
+	now        func() time.Time
 }
 
 func NewPipeline(client *Client, cfg config.SignalConfig, enqueue EnqueueFunc) *Pipeline {
 	return &Pipeline{
-		client:  client,
-		cfg:     cfg,
-		enqueue: enqueue,
+		client:     client,
+		cfg:        cfg,
+		enqueue:    enqueue,
+		preprocess: NewPreprocessors(cfg.Preprocess),
+		presence:   newGroupPresence(cfg),
+		now:        time.Now,
 	}
 }
 
@@ -38,7 +45,7 @@ func (p *Pipeline) Start(ctx context.Context) error {
 				}
 				return fmt.Errorf("signal events stream closed")
 			}
-			log.Printf(
+			logging.Debugf(
 				"[signal] event from=%s uuid=%s has_data=%t session=%s",
 				env.SourceNumber,
 				env.SourceUUID,
@@ -46,24 +53,35 @@ func (p *Pipeline) Start(ctx context.Context) error {
 				SessionKey(env),
 			)
 			if IsSelfMessage(env, p.cfg.Account) {
-				log.Printf("[signal] drop reason=self_message from=%s", env.SourceNumber)
+				logging.Debugf("[signal] drop reason=self_message from=%s", env.SourceNumber)
 				continue
 			}
 			if env.DataMessage == nil {
-				log.Printf("[signal] drop reason=no_data from=%s", env.SourceNumber)
+				logging.Debugf("[signal] drop reason=no_data from=%s", env.SourceNumber)
 				continue
 			}
 			if !allowSignalAccess(p.cfg, env) {
-				log.Printf("[signal] drop reason=access from=%s dm_policy=%s group_policy=%s", env.SourceNumber, p.cfg.DMPolicy, p.cfg.GroupPolicy)
+				logging.Debugf("[signal] drop reason=access from=%s dm_policy=%s group_policy=%s", env.SourceNumber, p.cfg.DMPolicy, p.cfg.GroupPolicy)
+				continue
+			}
+			if env.DataMessage.GroupInfo != nil && p.presence.mentionRequired(p.now()) && !addressesAccount(env.DataMessage, p.cfg.Account) {
+				logging.Debugf("[signal] drop reason=not_addressed session=%s", SessionKey(env))
 				continue
 			}
 			content := renderMentions(env.DataMessage.Message, env.DataMessage.Mentions)
-			log.Printf("[signal] accept session=%s msg=%q", SessionKey(env), compactSignalLogText(content))
-			p.enqueue(SessionKey(env), content, map[string]string{
+			for _, fn := range p.preprocess {
+				content = fn(content)
+			}
+			logging.Infof("[signal] accept session=%s msg=%q", SessionKey(env), compactSignalLogText(content))
+			metadata := map[string]string{
 				"source_name":   env.SourceName,
 				"source_number": env.SourceNumber,
 				"source_uuid":   env.SourceUUID,
-			})
+			}
+			if env.DataMessage.GroupInfo != nil {
+				metadata["group_id"] = env.DataMessage.GroupInfo.GroupID
+			}
+			p.enqueue(SessionKey(env), content, metadata)
 		}
 	}
 }
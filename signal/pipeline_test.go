@@ -204,3 +204,150 @@ func TestPipelineReturnsErrorWhenEventStreamCloses(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestPipelineStripsWakeWord(t *testing.T) {
+	inbox := make(chan capturedInput, 1)
+	env := &Envelope{
+		SourceNumber: "+15559990000",
+		SourceUUID:   "user-1",
+		DataMessage:  &DataMessage{Message: "  Hey Bot,  what's on today?  "},
+	}
+	srv := newSignalServer(t, env)
+	defer srv.Close()
+	cfg := config.SignalConfig{Account: "+1000", DMPolicy: "open", TextChunkLimit: 100, Preprocess: []config.PreprocessStep{
+		{Kind: "wake_word", Words: []string{"hey bot", "bot"}},
+		{Kind: "trim"},
+	}}
+	p := NewPipeline(NewClient(srv.URL, "+1000"), cfg, func(sessionID, content string, metadata map[string]string) {
+		inbox <- capturedInput{sessionID: sessionID, content: content, metadata: metadata}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Start(ctx) }()
+	input := waitInput(t, inbox)
+	cancel()
+	<-done
+	if input.content != "what's on today?" {
+		t.Fatalf("content = %q", input.content)
+	}
+}
+
+func TestStripWakeWord(t *testing.T) {
+	strip := stripWakeWord([]string{"hey bot", "bot"})
+	cases := map[string]string{
+		"hey bot, hi":       "hi",
+		"BOT: status":       "status",
+		"hey bot":           "",
+		"hey botany rocks":  "hey botany rocks",
+		"bot @alice hello":  "@alice hello",
+		"ask the bot later": "ask the bot later",
+	}
+	for in, want := range cases {
+		if got := strip(in); got != want {
+			t.Fatalf("stripWakeWord(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func groupMentionPipeline(t *testing.T, env *Envelope, inbox chan capturedInput) (*Pipeline, *httptest.Server) {
+	t.Helper()
+	srv := newSignalServer(t, env)
+	cfg := config.SignalConfig{Account: "+1000", GroupPolicy: "open", TextChunkLimit: 100, GroupMentionRequired: true}
+	p := NewPipeline(NewClient(srv.URL, "+1000"), cfg, func(sessionID, content string, metadata map[string]string) {
+		inbox <- capturedInput{sessionID: sessionID, content: content, metadata: metadata}
+	})
+	return p, srv
+}
+
+func TestPipelineIgnoresUnaddressedGroupMessage(t *testing.T) {
+	inbox := make(chan capturedInput, 1)
+	env := &Envelope{
+		SourceNumber: "+15559990000",
+		SourceUUID:   "user-1",
+		DataMessage:  &DataMessage{Message: "anyone up for lunch?", GroupInfo: &GroupInfo{GroupID: "g1"}},
+	}
+	p, srv := groupMentionPipeline(t, env, inbox)
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Start(ctx) }()
+	select {
+	case got := <-inbox:
+		cancel()
+		<-done
+		t.Fatalf("unexpected enqueue: %+v", got)
+	case <-time.After(200 * time.Millisecond):
+		cancel()
+		<-done
+	}
+}
+
+func TestPipelineTakesGroupReplyToAccount(t *testing.T) {
+	inbox := make(chan capturedInput, 1)
+	env := &Envelope{
+		SourceNumber: "+15559990000",
+		SourceUUID:   "user-1",
+		DataMessage: &DataMessage{
+			Message:   "and tomorrow?",
+			GroupInfo: &GroupInfo{GroupID: "g1"},
+			Quote:     &Quote{AuthorNumber: "+1000", Text: "sunny today"},
+		},
+	}
+	p, srv := groupMentionPipeline(t, env, inbox)
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Start(ctx) }()
+	input := waitInput(t, inbox)
+	cancel()
+	<-done
+	if input.sessionID != "signal:group:g1" || input.content != "and tomorrow?" {
+		t.Fatalf("unexpected input: %+v", input)
+	}
+	if input.metadata["group_id"] != "g1" || input.metadata["source_uuid"] != "user-1" {
+		t.Fatalf("unexpected metadata: %+v", input.metadata)
+	}
+}
+
+func TestGroupPresenceOpenHours(t *testing.T) {
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		hours    string
+		at       time.Duration
+		required bool
+	}{
+		{"", 12 * time.Hour, true},
+		{"09:00-18:00", 8*time.Hour + 59*time.Minute, true},
+		{"09:00-18:00", 9 * time.Hour, false},
+		{"09:00-18:00", 18 * time.Hour, true},
+		{"22:00-02:00", 23 * time.Hour, false},
+		{"22:00-02:00", time.Hour, false},
+		{"22:00-02:00", 12 * time.Hour, true},
+	}
+	for _, c := range cases {
+		g := newGroupPresence(config.SignalConfig{GroupMentionRequired: true, GroupOpenHours: c.hours})
+		if got := g.mentionRequired(day.Add(c.at)); got != c.required {
+			t.Fatalf("%q at %s: required = %t, want %t", c.hours, c.at, got, c.required)
+		}
+	}
+	if newGroupPresence(config.SignalConfig{}).mentionRequired(day) {
+		t.Fatal("mentions required with group_mention_required off")
+	}
+	ba := newGroupPresence(config.SignalConfig{GroupMentionRequired: true, GroupOpenHours: "09:00-18:00", GroupTimezone: "America/Argentina/Buenos_Aires"})
+	if ba.mentionRequired(day.Add(13 * time.Hour)) {
+		t.Fatal("13:00 UTC is 10:00 in Buenos Aires, inside open hours")
+	}
+}
+
+func TestAddressesAccount(t *testing.T) {
+	if !addressesAccount(&DataMessage{Mentions: []Mention{{Number: "+1000", Name: "bot"}}}, "+1000") {
+		t.Fatal("mention of the account not detected")
+	}
+	if addressesAccount(&DataMessage{Mentions: []Mention{{Number: "+2000", Name: "Alice"}}}, "+1000") {
+		t.Fatal("mention of someone else treated as addressed")
+	}
+	if addressesAccount(&DataMessage{Quote: &Quote{AuthorNumber: "+2000"}}, "+1000") {
+		t.Fatal("reply to someone else treated as addressed")
+	}
+}
@@ -0,0 +1,55 @@
+package signal
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/agusx1211/miclaw/config"
+)
+
+// wakeWordSeparators may follow a wake word ("hey bot, ...", "bot: ...") and
+// are stripped along with it.
+const wakeWordSeparators = ",:;.!?-"
+
+// PreprocessFunc rewrites inbound message text before it is enqueued.
+type PreprocessFunc func(string) string
+
+// NewPreprocessors builds the hook chain configured in signal.preprocess, in
+// order.
+func NewPreprocessors(steps []config.PreprocessStep) []PreprocessFunc {
+	out := make([]PreprocessFunc, 0, len(steps))
+	for _, step := range steps {
+		switch step.Kind {
+		case "wake_word":
+			out = append(out, stripWakeWord(step.Words))
+		case "trim":
+			out = append(out, strings.TrimSpace)
+		}
+	}
+	return out
+}
+
+// stripWakeWord removes the first of words found at the start of the text
+// when it stands alone, so "hey bot, hi" becomes "hi" but "hey botany" is
+// kept.
+func stripWakeWord(words []string) PreprocessFunc {
+	return func(text string) string {
+		rest := strings.TrimLeftFunc(text, unicode.IsSpace)
+		for _, w := range words {
+			w = strings.TrimSpace(w)
+			if len(rest) < len(w) || !strings.EqualFold(rest[:len(w)], w) {
+				continue
+			}
+			after := rest[len(w):]
+			if after != "" && !isWakeWordSeparator([]rune(after)[0]) {
+				continue
+			}
+			return strings.TrimLeftFunc(after, isWakeWordSeparator)
+		}
+		return text
+	}
+}
+
+func isWakeWordSeparator(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(wakeWordSeparators, r)
+}
@@ -0,0 +1,57 @@
+package signal
+
+import (
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+)
+
+// groupPresence decides whether a group message needs to address the
+// account, per signal.group_mention_required and signal.group_open_hours.
+type groupPresence struct {
+	required   bool
+	start, end time.Duration
+	open       bool
+	loc        *time.Location
+}
+
+func newGroupPresence(cfg config.SignalConfig) groupPresence {
+	g := groupPresence{required: cfg.GroupMentionRequired, loc: time.UTC}
+	start, end, err := config.ParseDailyWindow(cfg.GroupOpenHours)
+	if err == nil && cfg.GroupOpenHours != "" {
+		g.start, g.end, g.open = start, end, true
+	}
+	if loc, err := time.LoadLocation(cfg.GroupTimezone); err == nil {
+		g.loc = loc
+	}
+	return g
+}
+
+// mentionRequired reports whether a group message received at now is
+// dropped unless it addresses the account.
+func (g groupPresence) mentionRequired(now time.Time) bool {
+	if !g.required {
+		return false
+	}
+	if !g.open {
+		return true
+	}
+	local := now.In(g.loc)
+	y, m, d := local.Date()
+	offset := local.Sub(time.Date(y, m, d, 0, 0, 0, 0, g.loc))
+	if g.start < g.end {
+		return offset < g.start || offset >= g.end
+	}
+	return offset < g.start && offset >= g.end
+}
+
+// addressesAccount reports whether msg @mentions account or quotes one of
+// its messages.
+func addressesAccount(msg *DataMessage, account string) bool {
+	for _, m := range msg.Mentions {
+		if m.Number == account || m.Name == account {
+			return true
+		}
+	}
+	return msg.Quote != nil && (msg.Quote.AuthorNumber == account || msg.Quote.Author == account)
+}
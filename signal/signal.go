@@ -12,6 +12,9 @@ import (
 	"regexp"
 	"slices"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 type Envelope struct {
@@ -27,6 +30,7 @@ type DataMessage struct {
 	Attachments []Attachment `json:"attachments"`
 	Mentions    []Mention    `json:"mentions"`
 	GroupInfo   *GroupInfo   `json:"groupInfo"`
+	Quote       *Quote       `json:"quote"`
 	Reaction    *Reaction    `json:"reaction"`
 	Timestamp   int64        `json:"timestamp"`
 }
@@ -42,9 +46,19 @@ type Mention struct {
 	Start  int    `json:"start"`
 	Length int    `json:"length"`
 	UUID   string `json:"uuid"`
+	Number string `json:"number"`
 	Name   string `json:"name"`
 }
 
+// Quote is the message a reply quotes.
+type Quote struct {
+	ID           int64  `json:"id"`
+	Author       string `json:"author"`
+	AuthorNumber string `json:"authorNumber"`
+	AuthorUUID   string `json:"authorUuid"`
+	Text         string `json:"text"`
+}
+
 type GroupInfo struct {
 	GroupID string `json:"groupId"`
 	Type    string `json:"type"`
@@ -71,10 +85,30 @@ type Client struct {
 	baseURL string
 	account string
 	http    *http.Client
+
+	mu        sync.Mutex
+	connected bool
+	since     time.Time
 }
 
 func NewClient(baseURL, account string) *Client {
-	return &Client{baseURL: baseURL, account: account, http: &http.Client{}}
+	return &Client{baseURL: baseURL, account: account, http: &http.Client{}, since: time.Now()}
+}
+
+// StreamStatus reports whether the events stream is connected and since when
+// it has been in that state.
+func (c *Client) StreamStatus() (bool, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected, c.since
+}
+
+func (c *Client) setConnected(connected bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.connected != connected {
+		c.connected, c.since = connected, time.Now()
+	}
 }
 
 func ParseEnvelope(data []byte) (*Envelope, error) {
@@ -128,6 +162,11 @@ func (c *Client) listenSSE(ctx context.Context, out chan<- *Envelope) {
 		return
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+	c.setConnected(true)
+	defer c.setConnected(false)
 
 	deliver := func(data string) bool {
 		env, err := ParseEnvelope([]byte(data))
@@ -257,7 +296,12 @@ func findSingleStar(s string) int {
 	return -1
 }
 
+// MaxTextChunkLimit is the largest chunk ChunkText emits, whatever limit the
+// caller asks for; signal-cli rejects longer messages.
+const MaxTextChunkLimit = 4000
+
 func ChunkText(text string, limit int) []string {
+	limit = min(limit, MaxTextChunkLimit)
 	if len(text) <= limit {
 		return []string{text}
 	}
@@ -271,6 +315,9 @@ func ChunkText(text string, limit int) []string {
 		if nl := strings.LastIndex(text[:limit], "\n"); nl > 0 {
 			cut = nl + 1
 		}
+		for cut > 1 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
 		chunks = append(chunks, text[:cut])
 		text = text[cut:]
 	}
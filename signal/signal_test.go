@@ -10,6 +10,7 @@ import (
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
 func TestParseEnvelope(t *testing.T) {
@@ -251,6 +252,38 @@ func TestChunkTextLongLine(t *testing.T) {
 	}
 }
 
+func TestChunkTextClampsOverConfiguredLimit(t *testing.T) {
+	text := strings.Repeat("y", 3*MaxTextChunkLimit)
+	chunks := ChunkText(text, 10*MaxTextChunkLimit)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	for i, c := range chunks {
+		if len(c) > MaxTextChunkLimit {
+			t.Fatalf("chunk %d has %d bytes, max %d", i, len(c), MaxTextChunkLimit)
+		}
+	}
+	if strings.Join(chunks, "") != text {
+		t.Fatal("chunks don't reconstruct original")
+	}
+}
+
+func TestChunkTextHardSplitKeepsRunesWhole(t *testing.T) {
+	text := strings.Repeat("é", 15)
+	chunks := ChunkText(text, 7)
+	for i, c := range chunks {
+		if !utf8.ValidString(c) {
+			t.Fatalf("chunk %d splits a rune: %q", i, c)
+		}
+		if len(c) > 7 {
+			t.Fatalf("chunk %d has %d bytes", i, len(c))
+		}
+	}
+	if strings.Join(chunks, "") != text {
+		t.Fatal("chunks don't reconstruct original")
+	}
+}
+
 func TestRPCSend(t *testing.T) {
 	var gotBody []byte
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -443,3 +476,34 @@ func TestTextStyleEncode(t *testing.T) {
 		t.Fatalf("got %q", s.Encode())
 	}
 }
+
+func TestClientStreamStatusTracksConnection(t *testing.T) {
+	hold := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		<-hold
+	}))
+	defer srv.Close()
+	defer close(hold)
+
+	c := NewClient(srv.URL, "+15551234567")
+	if connected, _ := c.StreamStatus(); connected {
+		t.Fatal("connected before Listen")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := c.Listen(ctx)
+	deadline := time.Now().Add(2 * time.Second)
+	for connected, _ := c.StreamStatus(); !connected; connected, _ = c.StreamStatus() {
+		if time.Now().After(deadline) {
+			t.Fatal("stream never reported connected")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	for range ch {
+	}
+	if connected, since := c.StreamStatus(); connected || time.Since(since) > time.Second {
+		t.Fatalf("want disconnected just now, got connected=%v since=%v", connected, since)
+	}
+}
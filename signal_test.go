@@ -0,0 +1,395 @@
+package miclaw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/agent"
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/signal"
+	"github.com/agusx1211/miclaw/store"
+)
+
+func TestSendSignalMessageRoutesDMAndGroup(t *testing.T) {
+	cfg := config.SignalConfig{TextChunkLimit: 0}
+	c := signal.NewClient("http://127.0.0.1:1", "+10000000000")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := sendSignalMessage(ctx, c, cfg, "signal:dm:user-1", "hello"); err == nil {
+		t.Fatal("expected send failure on fake client")
+	}
+	if err := sendSignalMessage(ctx, c, cfg, "signal:group:group-1", "hello"); err == nil {
+		t.Fatal("expected send failure on fake client")
+	}
+	if err := sendSignalMessage(ctx, c, cfg, "invalid", "hello"); err == nil {
+		t.Fatal("expected invalid target error")
+	}
+}
+
+func TestParseSignalTarget(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantK   string
+		wantID  string
+		wantErr bool
+	}{
+		{in: "signal:dm:user-1", wantK: "dm", wantID: "user-1"},
+		{in: "signal:group:group-1", wantK: "group", wantID: "group-1"},
+		{in: "signal:dm:", wantErr: true},
+		{in: "signal:foo:bar", wantErr: true},
+		{in: "bad", wantErr: true},
+	}
+	for _, tt := range tests {
+		kind, id, err := parseSignalTarget(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("parseSignalTarget(%q): expected error", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("parseSignalTarget(%q): %v", tt.in, err)
+		}
+		if kind != tt.wantK || id != tt.wantID {
+			t.Fatalf("parseSignalTarget(%q) = (%q, %q), want (%q, %q)", tt.in, kind, id, tt.wantK, tt.wantID)
+		}
+	}
+}
+
+func TestParseSignalCommand(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "/new", want: "/new"},
+		{in: "  /compact  ", want: "/compact"},
+		{in: "/NEW", want: "/new"},
+		{in: "/unlock", want: "/unlock"},
+		{in: "/purge", want: "/purge"},
+		{in: "/noop", want: ""},
+		{in: "hello", want: ""},
+	}
+	for _, tt := range tests {
+		got := parseSignalCommand(tt.in)
+		if got != tt.want {
+			t.Fatalf("parseSignalCommand(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSignalPriorityFavorsDirectMessages(t *testing.T) {
+	if got := signalPriority("signal:dm:user-1"); got != agent.PriorityHigh {
+		t.Fatalf("dm priority = %v, want high", got)
+	}
+	if got := signalPriority("signal:group:g1"); got != agent.PriorityNormal {
+		t.Fatalf("group priority = %v, want normal", got)
+	}
+}
+
+// messageCallProvider answers the first request with reply text and a
+// message tool call whose arguments arrive as args, and every later one with
+// a sleep call.
+type messageCallProvider struct {
+	args  []string
+	calls atomic.Int32
+}
+
+func (p *messageCallProvider) Stream(context.Context, []model.Message, []provider.ToolDef, provider.ToolChoice) <-chan provider.ProviderEvent {
+	ch := make(chan provider.ProviderEvent, 3+len(p.args))
+	name, args := "sleep", []string{"{}"}
+	if p.calls.Add(1) == 1 {
+		name, args = "message", p.args
+		ch <- provider.ProviderEvent{Type: provider.EventContentDelta, Delta: "Thinking it over.\n\n"}
+	}
+	ch <- provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCallID: "c", ToolName: name}
+	for _, d := range args {
+		ch <- provider.ProviderEvent{Type: provider.EventToolUseDelta, ToolCallID: "c", Delta: d}
+	}
+	ch <- provider.ProviderEvent{Type: provider.EventComplete}
+	close(ch)
+	return ch
+}
+
+func (*messageCallProvider) Model() provider.ModelInfo {
+	return provider.ModelInfo{}
+}
+
+func TestSignalStreamingSendsMessageParagraphs(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+			Params struct {
+				Message string `json:"message"`
+			} `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Method == "send" {
+			mu.Lock()
+			sent = append(sent, req.Params.Message)
+			mu.Unlock()
+		}
+	}))
+	defer srv.Close()
+	cfg := testConfig(t)
+	cfg.Signal.StreamResponses = true
+	p := &messageCallProvider{args: []string{`{"to":"signal:dm:u1","content":"First.\n\nSec`, `ond.\n\nLast."}`}}
+	rt := newTestRuntime(t, cfg, Options{Provider: p})
+	rt.signal = signal.NewClient(srv.URL, "+10000000000")
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	rt.startSignalEvents(ctx)
+
+	if err := rt.RunOnce(ctx, agent.Input{Source: "signal:dm:u1", Content: "hi"}); err != nil {
+		t.Fatalf("run once: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"First.", "Second.", "Last."}; !reflect.DeepEqual(sent, want) {
+		t.Fatalf("sent %q, want %q", sent, want)
+	}
+}
+
+func TestForwardsToSignal(t *testing.T) {
+	cases := []struct {
+		ev   agent.AgentEvent
+		want bool
+	}{
+		{agent.AgentEvent{Type: agent.EventCompaction, Source: "signal:group:g1"}, true},
+		{agent.AgentEvent{Type: agent.EventCompaction, Source: "webhook:ci"}, false},
+		{agent.AgentEvent{Type: agent.EventResponse, Source: "signal:dm:u1"}, false},
+		{agent.AgentEvent{Type: agent.EventError, Source: "signal:dm:u1", Error: fmt.Errorf("%w: spent $1.00", agent.ErrBudgetExceeded)}, true},
+		{agent.AgentEvent{Type: agent.EventError, Source: "signal:dm:u1", Error: errors.New("upstream 502")}, false},
+		{agent.AgentEvent{Type: agent.EventError, Source: "signal:dm:u1", Error: fmt.Errorf("%w: status 401", agent.ErrProviderAuth)}, true},
+	}
+	for _, tc := range cases {
+		if got := forwardsToSignal(tc.ev); got != tc.want {
+			t.Fatalf("forwardsToSignal(%s from %s) = %v", tc.ev.Type, tc.ev.Source, got)
+		}
+	}
+}
+
+// compactGateProvider holds every summary stream until release is closed.
+type compactGateProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p compactGateProvider) Stream(ctx context.Context, _ []model.Message, _ []provider.ToolDef, _ provider.ToolChoice) <-chan provider.ProviderEvent {
+	ch := make(chan provider.ProviderEvent, 2)
+	go func() {
+		defer close(ch)
+		p.started <- struct{}{}
+		select {
+		case <-p.release:
+		case <-ctx.Done():
+			return
+		}
+		ch <- provider.ProviderEvent{Type: provider.EventContentDelta, Delta: "summary"}
+		ch <- provider.ProviderEvent{Type: provider.EventComplete}
+	}()
+	return ch
+}
+
+func (compactGateProvider) Model() provider.ModelInfo {
+	return provider.ModelInfo{}
+}
+
+// newCommandRuntime returns a runtime with a seeded two-message thread whose
+// Signal replies are recorded by the returned function.
+func newCommandRuntime(t *testing.T, waitSeconds int) (*Runtime, compactGateProvider, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+			Params struct {
+				Message string `json:"message"`
+			} `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Method == "send" {
+			mu.Lock()
+			sent = append(sent, req.Params.Message)
+			mu.Unlock()
+		}
+	}))
+	t.Cleanup(srv.Close)
+	cfg := testConfig(t)
+	cfg.Signal.CommandWaitSeconds = waitSeconds
+	prov := compactGateProvider{started: make(chan struct{}, 1), release: make(chan struct{})}
+	rt := newTestRuntime(t, cfg, Options{Provider: prov})
+	rt.signal = signal.NewClient(srv.URL, "+10000000000")
+	for i, role := range []model.Role{model.RoleUser, model.RoleAssistant} {
+		msg := &model.Message{ID: string(role), Role: role, Parts: []model.MessagePart{model.TextPart{Text: "hi"}}, CreatedAt: time.Now().Add(time.Duration(i) * time.Second)}
+		if err := rt.sqlStore.MessageStore().Create(msg); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+	}
+	return rt, prov, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), sent...)
+	}
+}
+
+func TestSignalUnlockAndNewResetCostTotals(t *testing.T) {
+	rt, _, sent := newCommandRuntime(t, 1)
+	ctx := context.Background()
+	costs := rt.sqlStore.Costs()
+	today := time.Now().UTC().Format("2006-01-02")
+	if err := costs.Add(2, today, store.CostThread); err != nil {
+		t.Fatalf("add cost: %v", err)
+	}
+
+	rt.handleSignalInput(ctx, "signal:dm:u1", "/unlock", nil)
+	if spent, _ := costs.Total(today); spent != 0 {
+		t.Fatalf("/unlock left today's total at %v", spent)
+	}
+	if spent, _ := costs.Total(store.CostThread); spent != 2 {
+		t.Fatalf("/unlock changed the thread total: %v", spent)
+	}
+	rt.handleSignalInput(ctx, "signal:dm:u1", "/new", nil)
+	if spent, _ := costs.Total(store.CostThread); spent != 0 {
+		t.Fatalf("/new left the thread total at %v", spent)
+	}
+	if got := sent(); len(got) != 2 || got[0] != "budget unlocked for today" || !strings.HasPrefix(got[1], "thread reset; previous thread archived as new-") {
+		t.Fatalf("unexpected replies: %q", got)
+	}
+	timeout := fmt.Errorf("%w after 5m0s", agent.ErrGenerationTimeout)
+	if !forwardsToSignal(agent.AgentEvent{Type: agent.EventError, Source: "signal:dm:u1", Error: timeout}) {
+		t.Fatal("generation timeout not forwarded to the sender")
+	}
+	if got := turnErrorReply(timeout); got != "generation timed out after 5m0s and was stopped. Send a message to continue where it left off." {
+		t.Fatalf("unexpected timeout reply: %q", got)
+	}
+	if got := turnErrorReply(fmt.Errorf("%w: openrouter stream failed: status 401: sk-or-v1-abc", agent.ErrProviderAuth)); got != providerAuthReply {
+		t.Fatalf("unexpected auth reply: %q", got)
+	}
+	reply := budgetExceededReply(fmt.Errorf("%w: spent $1.00 of limits.max_cost_per_day $1.00", agent.ErrBudgetExceeded))
+	if reply != "budget exceeded: spent $1.00 of limits.max_cost_per_day $1.00. Send /unlock to reset today's total, or /new to start a new thread." {
+		t.Fatalf("unexpected budget reply: %q", reply)
+	}
+}
+
+func countMessages(t *testing.T, rt *Runtime) int {
+	t.Helper()
+	n, err := rt.sqlStore.MessageStore().Count()
+	if err != nil {
+		t.Fatalf("count messages: %v", err)
+	}
+	return n
+}
+
+func TestSignalNewWaitsForRunningCompact(t *testing.T) {
+	rt, prov, sent := newCommandRuntime(t, 5)
+	ctx := context.Background()
+
+	rt.handleSignalInput(ctx, "signal:dm:u1", "/compact", nil)
+	<-prov.started
+	done := make(chan struct{})
+	go func() {
+		rt.handleSignalInput(ctx, "signal:dm:u1", "/new", nil)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if got := countMessages(t, rt); got != 2 {
+		t.Fatalf("/new ran during compaction: %d messages", got)
+	}
+	close(prov.release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("/new did not finish after compaction")
+	}
+	if got := countMessages(t, rt); got != 0 {
+		t.Fatalf("compaction summary survived /new: %d messages", got)
+	}
+	got := sent()
+	if len(got) != 3 || !reflect.DeepEqual(got[:2], []string{"compacting context", "compaction complete"}) || !strings.HasPrefix(got[2], "thread reset; previous thread archived as") {
+		t.Fatalf("unexpected replies: %q", got)
+	}
+}
+
+func TestSignalNewArchivesThread(t *testing.T) {
+	rt, _, sent := newCommandRuntime(t, 1)
+	ctx := context.Background()
+
+	rt.handleSignalInput(ctx, "signal:dm:u1", "/new", nil)
+	if got := countMessages(t, rt); got != 0 {
+		t.Fatalf("/new left %d messages in the thread", got)
+	}
+	replies := sent()
+	if len(replies) != 1 {
+		t.Fatalf("replies = %q", replies)
+	}
+	period := strings.TrimPrefix(replies[0], "thread reset; previous thread archived as ")
+	archived, err := rt.sqlStore.MessageStore().ListArchive(period)
+	if err != nil || len(archived) != 2 {
+		t.Fatalf("archive %q = %d messages, %v; want the old thread", period, len(archived), err)
+	}
+	hits, err := rt.sqlStore.MessageStore().Search("hi", 5)
+	if err != nil || len(hits) != 2 {
+		t.Fatalf("search after /new = %d hits, %v", len(hits), err)
+	}
+
+	rt.handleSignalInput(ctx, "signal:dm:u1", "/new", nil)
+	if got := sent(); len(got) != 2 || got[1] != "thread reset" {
+		t.Fatalf("/new on an empty thread replied %q", got)
+	}
+}
+
+func TestSignalPurgeDeletesThread(t *testing.T) {
+	rt, _, sent := newCommandRuntime(t, 1)
+
+	rt.handleSignalInput(context.Background(), "signal:dm:u1", "/purge", nil)
+	if got := countMessages(t, rt); got != 0 {
+		t.Fatalf("/purge left %d messages in the thread", got)
+	}
+	if hits, err := rt.sqlStore.MessageStore().Search("hi", 5); err != nil || len(hits) != 0 {
+		t.Fatalf("purged messages still searchable: %d %v", len(hits), err)
+	}
+	if got := sent(); !reflect.DeepEqual(got, []string{"thread deleted"}) {
+		t.Fatalf("replies = %q", got)
+	}
+}
+
+func TestSignalCommandRepliesBusyWhileAnotherRuns(t *testing.T) {
+	rt, prov, sent := newCommandRuntime(t, 1)
+	ctx := context.Background()
+
+	rt.handleSignalInput(ctx, "signal:dm:u1", "/compact", nil)
+	<-prov.started
+	rt.handleSignalInput(ctx, "signal:dm:u1", "/new", nil)
+	rt.handleSignalInput(ctx, "signal:dm:u1", "/compact", nil)
+	want := []string{
+		"compacting context",
+		"another command is running; try /new again in a few seconds",
+		"another command is running; try /compact again in a few seconds",
+	}
+	if got := sent(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("replies = %q, want %q", got, want)
+	}
+	close(prov.release)
+	deadline := time.Now().Add(2 * time.Second)
+	for countMessages(t, rt) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("compaction did not finish: %d messages", countMessages(t, rt))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
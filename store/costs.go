@@ -0,0 +1,73 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// CostThread is the CostStore key for the cost of the current thread. Daily
+// totals are kept under their date.
+const CostThread = "thread"
+
+// CostStore keeps running totals of generation cost in dollars, by key, so
+// spending limits hold across restarts.
+type CostStore struct {
+	db *sql.DB
+	// postgres numbers query placeholders as $1, $2, ... instead of ?.
+	postgres bool
+}
+
+const schemaCosts = `
+CREATE TABLE IF NOT EXISTS costs (
+	key TEXT PRIMARY KEY,
+	cost REAL
+)`
+
+func (s *SQLiteStore) Costs() *CostStore {
+
+	return s.costs
+}
+
+func (s *PostgresStore) Costs() *CostStore {
+
+	return s.costs
+}
+
+// Add adds cost to the total of every key, atomically.
+func (c *CostStore) Add(cost float64, keys ...string) error {
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		_, err := tx.Exec(bindQuery(c.postgres,
+			`INSERT INTO costs (key, cost) VALUES (?, ?)
+			 ON CONFLICT (key) DO UPDATE SET cost = costs.cost + excluded.cost`),
+			key, cost,
+		)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Total returns the cost recorded under key, or 0 when there is none.
+func (c *CostStore) Total(key string) (float64, error) {
+
+	var cost float64
+	err := c.db.QueryRow(bindQuery(c.postgres, `SELECT cost FROM costs WHERE key = ?`), key).Scan(&cost)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return cost, err
+}
+
+// Reset clears the total of key.
+func (c *CostStore) Reset(key string) error {
+
+	_, err := c.db.Exec(bindQuery(c.postgres, `DELETE FROM costs WHERE key = ?`), key)
+	return err
+}
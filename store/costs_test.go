@@ -0,0 +1,35 @@
+package store
+
+import "testing"
+
+func TestCostStoreAccumulatesAndResets(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Backend) {
+		c := s.Costs()
+		if got, err := c.Total("2026-10-16"); err != nil || got != 0 {
+			t.Fatalf("empty total = %v (%v)", got, err)
+		}
+		if err := c.Add(0.25, "2026-10-16", CostThread); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+		if err := c.Add(0.5, "2026-10-16", CostThread); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+		if err := c.Add(1, "2026-10-17"); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+		for key, want := range map[string]float64{"2026-10-16": 0.75, CostThread: 0.75, "2026-10-17": 1} {
+			if got, err := c.Total(key); err != nil || got != want {
+				t.Fatalf("total %s = %v (%v), want %v", key, got, err, want)
+			}
+		}
+		if err := c.Reset("2026-10-16"); err != nil {
+			t.Fatalf("reset: %v", err)
+		}
+		if got, _ := c.Total("2026-10-16"); got != 0 {
+			t.Fatalf("reset total = %v", got)
+		}
+		if got, _ := c.Total(CostThread); got != 0.75 {
+			t.Fatalf("reset cleared another key: %v", got)
+		}
+	})
+}
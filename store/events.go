@@ -0,0 +1,99 @@
+package store
+
+import (
+	"database/sql"
+	"slices"
+	"time"
+)
+
+// EventRecord is an agent event kept for auditing: its type, the source of
+// the turn it belongs to, and its text, error or tool call, truncated.
+type EventRecord struct {
+	Seq       int64
+	Type      string
+	Source    string
+	Content   string
+	CreatedAt time.Time
+}
+
+// EventStore is the agent's event log, kept next to the thread.
+type EventStore struct {
+	db *sql.DB
+	// postgres numbers query placeholders as $1, $2, ... instead of ?.
+	postgres bool
+}
+
+const schemaEvents = `
+CREATE TABLE IF NOT EXISTS events (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	type TEXT,
+	source TEXT,
+	content TEXT,
+	created_at DATETIME
+)`
+
+func (s *SQLiteStore) Events() *EventStore {
+
+	return s.events
+}
+
+func (s *PostgresStore) Events() *EventStore {
+
+	return s.events
+}
+
+// Add appends ev to the log. Seq is assigned by the store.
+func (e *EventStore) Add(ev EventRecord) error {
+
+	_, err := e.db.Exec(bindQuery(e.postgres,
+		`INSERT INTO events (type, source, content, created_at) VALUES (?, ?, ?, ?)`),
+		ev.Type,
+		ev.Source,
+		ev.Content,
+		timeToDB(ev.CreatedAt),
+	)
+	return err
+}
+
+// Prune deletes all but the newest keep events.
+func (e *EventStore) Prune(keep int) error {
+
+	_, err := e.db.Exec(bindQuery(e.postgres,
+		`DELETE FROM events WHERE seq <= (SELECT MAX(seq) FROM events) - ?`),
+		keep,
+	)
+	return err
+}
+
+// Tail returns the newest limit events, oldest first.
+func (e *EventStore) Tail(limit int) ([]EventRecord, error) {
+
+	rows, err := e.db.Query(bindQuery(e.postgres,
+		`SELECT seq, type, source, content, created_at
+		 FROM events ORDER BY seq DESC LIMIT ?`),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]EventRecord, 0)
+	for rows.Next() {
+		var v EventRecord
+		var createdAt string
+		if err := rows.Scan(&v.Seq, &v.Type, &v.Source, &v.Content, &createdAt); err != nil {
+			return nil, err
+		}
+		if v.CreatedAt, err = timeFromDB(createdAt); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.Reverse(out)
+
+	return out, nil
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEventStoreTailsNewestInOrder(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Backend) {
+		e := s.Events()
+		if got, err := e.Tail(10); err != nil || len(got) != 0 {
+			t.Fatalf("empty tail = %+v (%v)", got, err)
+		}
+		at := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+		for i := range 5 {
+			ev := EventRecord{Type: "response", Source: "api", Content: fmt.Sprintf("reply %d", i), CreatedAt: at.Add(time.Duration(i) * time.Second)}
+			if err := e.Add(ev); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+		}
+		got, err := e.Tail(3)
+		if err != nil || len(got) != 3 {
+			t.Fatalf("tail = %+v (%v)", got, err)
+		}
+		for i, ev := range got {
+			if ev.Content != fmt.Sprintf("reply %d", i+2) || ev.Source != "api" || !ev.CreatedAt.Equal(at.Add(time.Duration(i+2)*time.Second)) {
+				t.Fatalf("event %d = %+v", i, ev)
+			}
+		}
+		if got[0].Seq >= got[1].Seq {
+			t.Fatalf("events out of order: %+v", got)
+		}
+	})
+}
+
+func TestEventStorePruneKeepsNewest(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Backend) {
+		e := s.Events()
+		at := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+		for i := range 5 {
+			if err := e.Add(EventRecord{Type: "response", Content: fmt.Sprintf("reply %d", i), CreatedAt: at}); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+		}
+		if err := e.Prune(2); err != nil {
+			t.Fatalf("prune: %v", err)
+		}
+		got, err := e.Tail(10)
+		if err != nil || len(got) != 2 || got[0].Content != "reply 3" || got[1].Content != "reply 4" {
+			t.Fatalf("tail after prune = %+v (%v)", got, err)
+		}
+	})
+}
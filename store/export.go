@@ -0,0 +1,75 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+const threadBundleVersion = 1
+
+// exportPageSize is how many thread messages ExportThread reads at a time.
+const exportPageSize = 500
+
+// ThreadBundle is an exported thread: every message with all its parts, in
+// order. Period is the archive period it was exported from, or "" for the
+// current thread.
+type ThreadBundle struct {
+	Version    int              `json:"version"`
+	Period     string           `json:"period"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Messages   []*model.Message `json:"messages"`
+}
+
+// ExportThread returns the current thread, or the archived thread of period
+// when it is set, as a JSON ThreadBundle.
+func ExportThread(ms MessageStore, period string, now time.Time) ([]byte, error) {
+
+	b := ThreadBundle{Version: threadBundleVersion, Period: period, ExportedAt: now.UTC()}
+	if period != "" {
+		msgs, err := ms.ListArchive(period)
+		if err != nil {
+			return nil, fmt.Errorf("list archive %s: %v", period, err)
+		}
+		b.Messages = msgs
+		return json.MarshalIndent(b, "", "  ")
+	}
+	b.Messages = []*model.Message{}
+	for {
+		page, err := ms.List(exportPageSize, len(b.Messages))
+		if err != nil {
+			return nil, fmt.Errorf("list messages: %v", err)
+		}
+		b.Messages = append(b.Messages, page...)
+		if len(page) < exportPageSize {
+			return json.MarshalIndent(b, "", "  ")
+		}
+	}
+}
+
+// ImportThread makes the messages of a ThreadBundle the current thread and
+// returns how many there were. The thread must be empty, so an import never
+// mixes two conversations; clear it with /new first.
+func ImportThread(ms MessageStore, data []byte) (int, error) {
+
+	var b ThreadBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return 0, fmt.Errorf("decode thread bundle: %v", err)
+	}
+	if b.Version != threadBundleVersion {
+		return 0, fmt.Errorf("unsupported thread bundle version %d", b.Version)
+	}
+	n, err := ms.Count()
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		return 0, fmt.Errorf("thread has %d messages; import needs an empty thread", n)
+	}
+	if err := ms.ReplaceAll(b.Messages); err != nil {
+		return 0, fmt.Errorf("import messages: %v", err)
+	}
+	return len(b.Messages), nil
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+func exportFixture() []*model.Message {
+	at := time.Date(2026, 3, 1, 9, 30, 15, 123456789, time.FixedZone("ART", -3*3600))
+	return []*model.Message{
+		{ID: "u1", Role: model.RoleUser, CreatedAt: at, Parts: []model.MessagePart{model.TextPart{Text: "disk usage?"}}},
+		{ID: "a1", Role: model.RoleAssistant, CreatedAt: at.Add(time.Second), Parts: []model.MessagePart{
+			model.ReasoningPart{Text: "run df"},
+			model.ToolCallPart{ID: "c1", Name: "exec", Parameters: json.RawMessage(`{"command":"df -h"}`)},
+		}},
+		{ID: "t1", Role: model.RoleTool, CreatedAt: at.Add(2 * time.Second), Parts: []model.MessagePart{
+			model.ToolResultPart{ToolCallID: "c1", Content: "/dev/sda1 42%", IsError: true},
+		}},
+	}
+}
+
+func TestExportImportThreadRoundTrip(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Backend) {
+		src := s.MessageStore()
+		want := exportFixture()
+		for _, m := range want {
+			if err := src.Create(m); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+		}
+		data, err := ExportThread(src, "", time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("export: %v", err)
+		}
+		if _, err := ImportThread(src, data); err == nil || !strings.Contains(err.Error(), "empty thread") {
+			t.Fatalf("expected non-empty thread error, got %v", err)
+		}
+		if err := src.DeleteAll(); err != nil {
+			t.Fatalf("delete all: %v", err)
+		}
+
+		n, err := ImportThread(src, data)
+		if err != nil || n != 3 {
+			t.Fatalf("import = %d (%v)", n, err)
+		}
+		got, err := src.List(10, 0)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("imported %d messages, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if got[i].ID != want[i].ID || got[i].Role != want[i].Role || !got[i].CreatedAt.Equal(want[i].CreatedAt) {
+				t.Fatalf("message %d: got %#v want %#v", i, got[i], want[i])
+			}
+			if !reflect.DeepEqual(got[i].Parts, want[i].Parts) {
+				t.Fatalf("message %d parts: got %#v want %#v", i, got[i].Parts, want[i].Parts)
+			}
+		}
+	})
+}
+
+func TestExportArchivedPeriod(t *testing.T) {
+	s := openTestStore(t)
+	for _, m := range exportFixture() {
+		if err := s.Messages.Create(m); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	if err := s.Messages.Archive("2026-02", nil); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	data, err := ExportThread(s.Messages, "2026-02", time.Now())
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	var b ThreadBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if b.Version != 1 || b.Period != "2026-02" || len(b.Messages) != 3 || b.Messages[2].ID != "t1" {
+		t.Fatalf("unexpected bundle: %+v", b)
+	}
+	if _, err := ImportThread(s.Messages, []byte(`{"version": 9, "messages": []}`)); err == nil || !strings.Contains(err.Error(), "version 9") {
+		t.Fatalf("expected version error, got %v", err)
+	}
+}
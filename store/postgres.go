@@ -0,0 +1,283 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/agusx1211/miclaw/model"
+	_ "github.com/lib/pq"
+)
+
+// PostgresStore keeps the thread and the input queue in a shared Postgres
+// database. Messages use the same encoding as SQLiteStore, with parts_json
+// stored as jsonb and timestamps as RFC3339Nano text.
+type PostgresStore struct {
+	db       *sql.DB
+	Messages MessageStore
+	queue    *QueueStore
+	costs    *CostStore
+	events   *EventStore
+}
+
+type postgresMessageStore struct {
+	db *sql.DB
+}
+
+var _ MessageStore = (*postgresMessageStore)(nil)
+var _ Backend = (*PostgresStore)(nil)
+
+var postgresSchema = []string{
+	`CREATE TABLE IF NOT EXISTS messages (
+		id TEXT PRIMARY KEY,
+		role TEXT,
+		parts_json JSONB,
+		created_at TEXT,
+		metadata_json TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_messages_created ON messages(created_at, id)`,
+	`CREATE TABLE IF NOT EXISTS archived_messages (
+		period TEXT,
+		id TEXT,
+		role TEXT,
+		parts_json JSONB,
+		created_at TEXT,
+		metadata_json TEXT,
+		PRIMARY KEY (period, id)
+	)`,
+	`ALTER TABLE messages ADD COLUMN IF NOT EXISTS metadata_json TEXT`,
+	`ALTER TABLE archived_messages ADD COLUMN IF NOT EXISTS metadata_json TEXT`,
+	`CREATE TABLE IF NOT EXISTS queue (
+		seq BIGSERIAL PRIMARY KEY,
+		id TEXT UNIQUE,
+		session TEXT,
+		source TEXT,
+		content TEXT,
+		metadata TEXT,
+		created_at TEXT,
+		state TEXT,
+		priority INTEGER NOT NULL DEFAULT 0
+	)`,
+	`ALTER TABLE queue ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0`,
+	`CREATE TABLE IF NOT EXISTS costs (
+		key TEXT PRIMARY KEY,
+		cost DOUBLE PRECISION
+	)`,
+	`CREATE TABLE IF NOT EXISTS events (
+		seq BIGSERIAL PRIMARY KEY,
+		type TEXT,
+		source TEXT,
+		content TEXT,
+		created_at TEXT
+	)`,
+}
+
+func OpenPostgres(dsn string) (*PostgresStore, error) {
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range postgresSchema {
+		if _, err := db.Exec(q); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+	s := &PostgresStore{db: db}
+	s.Messages = &postgresMessageStore{db: db}
+	s.queue = &QueueStore{db: db, postgres: true}
+	s.costs = &CostStore{db: db, postgres: true}
+	s.events = &EventStore{db: db, postgres: true}
+
+	return s, nil
+}
+
+func (s *PostgresStore) Close() error {
+
+	return s.db.Close()
+}
+
+func (s *PostgresStore) MessageStore() MessageStore {
+
+	return s.Messages
+}
+
+func (s *PostgresStore) Queue() *QueueStore {
+
+	return s.queue
+}
+
+func (s *postgresMessageStore) Create(msg *model.Message) error {
+
+	raw, meta, err := encodeMessage(msg)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO messages (id, role, parts_json, created_at, metadata_json)
+		 VALUES ($1, $2, $3::jsonb, $4, $5)`,
+		msg.ID,
+		string(msg.Role),
+		raw,
+		timeToDB(msg.CreatedAt),
+		meta,
+	)
+	return err
+}
+
+func (s *postgresMessageStore) Get(id string) (*model.Message, error) {
+
+	row := s.db.QueryRow(
+		`SELECT id, role, parts_json::text, created_at, metadata_json
+		 FROM messages WHERE id = $1`,
+		id,
+	)
+	return scanMessage(row)
+}
+
+func (s *postgresMessageStore) List(limit, offset int) ([]*model.Message, error) {
+
+	rows, err := s.db.Query(
+		`SELECT id, role, parts_json::text, created_at, metadata_json
+		 FROM messages
+		 ORDER BY created_at, id LIMIT $1 OFFSET $2`,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+func (s *postgresMessageStore) DeleteAll() error {
+
+	_, err := s.db.Exec(`DELETE FROM messages`)
+	return err
+}
+
+func (s *postgresMessageStore) Count() (int, error) {
+
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM messages`).Scan(&n)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func (s *postgresMessageStore) ReplaceAll(msgs []*model.Message) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := replacePostgresMessages(tx, msgs); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *postgresMessageStore) Archive(period string, msgs []*model.Message) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`INSERT INTO archived_messages (period, id, role, parts_json, created_at, metadata_json)
+		 SELECT $1, id, role, parts_json, created_at, metadata_json FROM messages
+		 ON CONFLICT (period, id) DO UPDATE SET
+		   role = EXCLUDED.role, parts_json = EXCLUDED.parts_json, created_at = EXCLUDED.created_at,
+		   metadata_json = EXCLUDED.metadata_json`,
+		period,
+	)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := replacePostgresMessages(tx, msgs); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *postgresMessageStore) ListArchive(period string) ([]*model.Message, error) {
+
+	rows, err := s.db.Query(
+		`SELECT id, role, parts_json::text, created_at, metadata_json
+		 FROM archived_messages WHERE period = $1
+		 ORDER BY created_at, id`,
+		period,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+func replacePostgresMessages(tx *sql.Tx, msgs []*model.Message) error {
+
+	if _, err := tx.Exec(`DELETE FROM messages`); err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		raw, meta, err := encodeMessage(msg)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			`INSERT INTO messages (id, role, parts_json, created_at, metadata_json)
+			 VALUES ($1, $2, $3::jsonb, $4, $5)`,
+			msg.ID,
+			string(msg.Role),
+			raw,
+			timeToDB(msg.CreatedAt),
+			meta,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Search matches the words of query against the text parts of the thread
+// and the archive with Postgres full-text search. There is no index behind
+// it, so every search scans all messages.
+func (s *postgresMessageStore) Search(query string, limit int) ([]MessageHit, error) {
+
+	if strings.TrimSpace(query) == "" {
+		return []MessageHit{}, nil
+	}
+	rows, err := s.db.Query(
+		`WITH m AS (
+		   SELECT '' AS period, id, role, created_at, parts_json FROM messages
+		   UNION ALL
+		   SELECT period, id, role, created_at, parts_json FROM archived_messages
+		 ), t AS (
+		   SELECT period, id, role, created_at,
+		     coalesce((SELECT string_agg(p->>'text', E'\n') FROM jsonb_array_elements(parts_json->'parts') p
+		               WHERE p->>'type' = 'text'), '') AS body
+		   FROM m
+		 )
+		 SELECT period, id, role, created_at,
+		   ts_headline('simple', body, q, 'StartSel=[, StopSel=], MaxWords=24, MinWords=6')
+		 FROM t, plainto_tsquery('simple', $1) q
+		 WHERE to_tsvector('simple', body) @@ q
+		 ORDER BY ts_rank(to_tsvector('simple', body), q) DESC, created_at DESC
+		 LIMIT $2`,
+		query,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanHits(rows)
+}
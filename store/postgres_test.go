@@ -0,0 +1,59 @@
+package store
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+)
+
+// postgresDSNEnv names the database the store tests also run against. The
+// tests clear its tables, so point it at a throwaway database.
+const postgresDSNEnv = "MICLAW_TEST_POSTGRES_DSN"
+
+func openTestPostgres(t *testing.T) *PostgresStore {
+	t.Helper()
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", postgresDSNEnv)
+	}
+	s, err := OpenPostgres(dsn)
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	for _, table := range []string{"messages", "archived_messages", "queue", "costs", "events"} {
+		if _, err := s.db.Exec(`DELETE FROM ` + table); err != nil {
+			t.Fatalf("clear %s: %v", table, err)
+		}
+	}
+	t.Cleanup(func() {
+		if err := s.Close(); err != nil {
+			t.Fatalf("close postgres: %v", err)
+		}
+	})
+	return s
+}
+
+// eachBackend runs fn against a fresh SQLite store and, when
+// MICLAW_TEST_POSTGRES_DSN is set, against Postgres.
+func eachBackend(t *testing.T, fn func(t *testing.T, s Backend)) {
+	t.Run("sqlite", func(t *testing.T) { fn(t, openTestStore(t)) })
+	t.Run("postgres", func(t *testing.T) { fn(t, openTestPostgres(t)) })
+}
+
+func backendDB(s Backend) *sql.DB {
+	if pg, ok := s.(*PostgresStore); ok {
+		return pg.db
+	}
+	return s.(*SQLiteStore).db
+}
+
+func TestQueueBindNumbersPostgresPlaceholders(t *testing.T) {
+	q := &QueueStore{postgres: true}
+	got := q.bind(`UPDATE queue SET state = ? WHERE id = ?`)
+	if got != `UPDATE queue SET state = $1 WHERE id = $2` {
+		t.Fatalf("unexpected query: %s", got)
+	}
+	if sqlite := (&QueueStore{}).bind(`id = ?`); sqlite != `id = ?` {
+		t.Fatalf("sqlite query rewritten: %s", sqlite)
+	}
+}
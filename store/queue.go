@@ -0,0 +1,170 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Queue states. An input is pending until the agent picks it up, running
+// while its generation is in flight, and done once the generation has
+// committed.
+const (
+	QueuePending = "pending"
+	QueueRunning = "running"
+	QueueDone    = "done"
+)
+
+// QueuedInput is an agent input persisted so it survives a restart.
+type QueuedInput struct {
+	ID        string
+	Session   string
+	Source    string
+	Content   string
+	Metadata  map[string]string
+	CreatedAt time.Time
+	State     string
+	// Priority is the agent's input lane; higher runs first.
+	Priority int
+}
+
+// QueueStore persists the agent's input queue next to the thread.
+type QueueStore struct {
+	db *sql.DB
+	// postgres numbers query placeholders as $1, $2, ... instead of ?.
+	postgres bool
+}
+
+const schemaQueue = `
+CREATE TABLE IF NOT EXISTS queue (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	id TEXT UNIQUE,
+	session TEXT,
+	source TEXT,
+	content TEXT,
+	metadata TEXT,
+	created_at DATETIME,
+	state TEXT,
+	priority INTEGER NOT NULL DEFAULT 0
+)`
+
+// bind rewrites the ? placeholders of query for the store's database.
+func (q *QueueStore) bind(query string) string {
+
+	return bindQuery(q.postgres, query)
+}
+
+// bindQuery numbers the ? placeholders of query as $1, $2, ... when postgres
+// is set, and returns it unchanged otherwise.
+func bindQuery(postgres bool, query string) string {
+
+	if !postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		fmt.Fprintf(&b, "$%d", n)
+	}
+	return b.String()
+}
+
+func (s *SQLiteStore) Queue() *QueueStore {
+
+	return s.queue
+}
+
+// Add stores in as pending.
+func (q *QueueStore) Add(in QueuedInput) error {
+
+	meta, err := json.Marshal(in.Metadata)
+	if err != nil {
+		return err
+	}
+	_, err = q.db.Exec(q.bind(
+		`INSERT INTO queue (id, session, source, content, metadata, created_at, state, priority)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		in.ID,
+		in.Session,
+		in.Source,
+		in.Content,
+		string(meta),
+		timeToDB(in.CreatedAt),
+		QueuePending,
+		in.Priority,
+	)
+	return err
+}
+
+// SetState moves the inputs with the given IDs to state.
+func (q *QueueStore) SetState(ids []string, state string) error {
+
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := tx.Exec(q.bind(`UPDATE queue SET state = ? WHERE id = ?`), state, id); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Unfinished deletes done inputs and returns the pending and running ones
+// in the order they were added.
+func (q *QueueStore) Unfinished() ([]QueuedInput, error) {
+
+	if _, err := q.db.Exec(q.bind(`DELETE FROM queue WHERE state = ?`), QueueDone); err != nil {
+		return nil, err
+	}
+	rows, err := q.db.Query(
+		`SELECT id, session, source, content, metadata, created_at, state, priority
+		 FROM queue ORDER BY seq`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]QueuedInput, 0)
+	for rows.Next() {
+		v, err := scanQueuedInput(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func scanQueuedInput(r rowScanner) (QueuedInput, error) {
+
+	var v QueuedInput
+	var meta, createdAt string
+	if err := r.Scan(&v.ID, &v.Session, &v.Source, &v.Content, &meta, &createdAt, &v.State, &v.Priority); err != nil {
+		return QueuedInput{}, err
+	}
+	if err := json.Unmarshal([]byte(meta), &v.Metadata); err != nil {
+		return QueuedInput{}, err
+	}
+	created, err := timeFromDB(createdAt)
+	if err != nil {
+		return QueuedInput{}, err
+	}
+	v.CreatedAt = created
+
+	return v, nil
+}
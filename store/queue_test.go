@@ -0,0 +1,65 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueueUnfinishedKeepsFIFOAndDropsDone(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Backend) {
+		q := s.Queue()
+		at := time.Date(2026, 2, 21, 10, 0, 0, 0, time.UTC)
+		for _, id := range []string{"q1", "q2", "q3", "q4"} {
+			in := QueuedInput{ID: id, Source: "signal:dm:+1", Content: "msg " + id, CreatedAt: at}
+			if err := q.Add(in); err != nil {
+				t.Fatalf("add %s: %v", id, err)
+			}
+		}
+		if err := q.SetState([]string{"q1"}, QueueDone); err != nil {
+			t.Fatal(err)
+		}
+		if err := q.SetState([]string{"q2"}, QueueRunning); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := q.Unfinished()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, len(got))
+		for i, in := range got {
+			ids[i] = in.ID
+		}
+		if !reflect.DeepEqual(ids, []string{"q2", "q3", "q4"}) {
+			t.Fatalf("unexpected unfinished inputs: %v", ids)
+		}
+		if got[0].State != QueueRunning || got[1].State != QueuePending {
+			t.Fatalf("unexpected states: %q %q", got[0].State, got[1].State)
+		}
+		if got[1].Content != "msg q3" || !got[1].CreatedAt.Equal(at) {
+			t.Fatalf("unexpected input: %+v", got[1])
+		}
+		var n int
+		if err := backendDB(s).QueryRow(`SELECT COUNT(*) FROM queue`).Scan(&n); err != nil || n != 3 {
+			t.Fatalf("expected done input deleted, %d rows left (%v)", n, err)
+		}
+	})
+}
+
+func TestQueueRoundTripsMetadata(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Backend) {
+		q := s.Queue()
+		meta := map[string]string{"session_id": "abc", "sender": "+1"}
+		if err := q.Add(QueuedInput{ID: "q1", Session: "abc", Source: "webhook:abc", Metadata: meta}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := q.Unfinished()
+		if err != nil || len(got) != 1 {
+			t.Fatalf("expected 1 input, got %d (%v)", len(got), err)
+		}
+		if got[0].Session != "abc" || !reflect.DeepEqual(got[0].Metadata, meta) {
+			t.Fatalf("unexpected input: %+v", got[0])
+		}
+	})
+}
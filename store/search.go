@@ -0,0 +1,175 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+// MessageHit is a message matched by Search. Period is the archive period
+// the message was filed under, or "" for the current thread.
+type MessageHit struct {
+	Period    string
+	ID        string
+	Role      model.Role
+	CreatedAt time.Time
+	Snippet   string
+}
+
+// messages_fts indexes the text parts of every message in the thread
+// (empty period) and the archive. It holds its own copy of the text because
+// the text lives inside parts_json.
+const schemaMessagesFTS = `
+CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+	text,
+	period UNINDEXED,
+	id UNINDEXED,
+	role UNINDEXED,
+	created_at UNINDEXED
+)`
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// searchText joins the text parts of msg; other parts are not searchable.
+func searchText(msg *model.Message) string {
+
+	var texts []string
+	for _, p := range msg.Parts {
+		if t, ok := p.(model.TextPart); ok {
+			texts = append(texts, t.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// archiveSearch files the thread's index entries under period, replacing
+// entries a previous archive under the same period left for the same IDs.
+func archiveSearch(tx *sql.Tx, period string) error {
+
+	if _, err := tx.Exec(
+		`DELETE FROM messages_fts WHERE period = ? AND id IN (SELECT id FROM messages)`,
+		period,
+	); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`UPDATE messages_fts SET period = ? WHERE period = ''`, period)
+	return err
+}
+
+func indexMessage(db execer, period string, msg *model.Message) error {
+
+	text := searchText(msg)
+	if text == "" {
+		return nil
+	}
+	_, err := db.Exec(
+		`INSERT INTO messages_fts (text, period, id, role, created_at) VALUES (?, ?, ?, ?, ?)`,
+		text, period, msg.ID, string(msg.Role), timeToDB(msg.CreatedAt),
+	)
+	return err
+}
+
+// ftsQuery quotes every word of query, so punctuation and FTS5 operators
+// in it match literally and every word must appear.
+func ftsQuery(query string) string {
+
+	words := strings.Fields(query)
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+func (s *sqliteMessageStore) Search(query string, limit int) ([]MessageHit, error) {
+
+	q := ftsQuery(query)
+	if q == "" {
+		return []MessageHit{}, nil
+	}
+	rows, err := s.db.Query(
+		`SELECT period, id, role, created_at, snippet(messages_fts, 0, '[', ']', '…', 12)
+		 FROM messages_fts WHERE messages_fts MATCH ?
+		 ORDER BY rank LIMIT ?`,
+		q,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanHits(rows)
+}
+
+func scanHits(rows *sql.Rows) ([]MessageHit, error) {
+
+	defer rows.Close()
+	out := make([]MessageHit, 0)
+	for rows.Next() {
+		var h MessageHit
+		var role, createdAt string
+		if err := rows.Scan(&h.Period, &h.ID, &role, &createdAt, &h.Snippet); err != nil {
+			return nil, err
+		}
+		created, err := timeFromDB(createdAt)
+		if err != nil {
+			return nil, err
+		}
+		h.Role, h.CreatedAt = model.Role(role), created
+		out = append(out, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// backfillSearch indexes the thread and the archive when the search index
+// is empty, as it is the first time a store from before search is opened.
+func backfillSearch(db *sql.DB) error {
+
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM messages_fts`).Scan(&n); err != nil || n > 0 {
+		return err
+	}
+	var periods []string
+	rows, err := db.Query(`SELECT DISTINCT period FROM archived_messages`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			rows.Close()
+			return err
+		}
+		periods = append(periods, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, period := range append([]string{""}, periods...) {
+		q, args := `SELECT id, role, parts_json, created_at, metadata_json FROM messages`, []any{}
+		if period != "" {
+			q, args = `SELECT id, role, parts_json, created_at, metadata_json FROM archived_messages WHERE period = ?`, []any{period}
+		}
+		rows, err := db.Query(q, args...)
+		if err != nil {
+			return err
+		}
+		msgs, err := scanMessages(rows)
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			if err := indexMessage(db, period, msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+func hitKeys(hits []MessageHit) []string {
+	out := make([]string, 0, len(hits))
+	for _, h := range hits {
+		out = append(out, h.Period+"/"+h.ID)
+	}
+	return out
+}
+
+func TestSearchMatchesEveryWordAcrossThreadAndArchive(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Backend) {
+		ms := s.MessageStore()
+		base := time.Date(2026, 1, 31, 10, 0, 0, 0, time.UTC)
+		for i, m := range [][2]string{{"jan-a", "the boiler needs a new filter"}, {"jan-b", "ordered the filter online"}} {
+			if err := ms.Create(makeMessage(m[0], m[1], base.Add(time.Duration(i)*time.Minute))); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+		}
+		if err := ms.Archive("2026-01", nil); err != nil {
+			t.Fatalf("archive: %v", err)
+		}
+		if err := ms.Create(makeMessage("feb-a", "boiler filter arrived", base.Add(24*time.Hour))); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+
+		hits, err := ms.Search("boiler filter", 10)
+		if err != nil {
+			t.Fatalf("search: %v", err)
+		}
+		got := strings.Join(hitKeys(hits), ",")
+		if len(hits) != 2 || !strings.Contains(got, "2026-01/jan-a") || !strings.Contains(got, "/feb-a") {
+			t.Fatalf("unexpected hits: %s", got)
+		}
+		for _, h := range hits {
+			if h.ID == "feb-a" && (h.Period != "" || h.Role != model.RoleUser || !h.CreatedAt.Equal(base.Add(24*time.Hour))) {
+				t.Fatalf("unexpected current-thread hit: %#v", h)
+			}
+			if !strings.Contains(h.Snippet, "[boiler]") {
+				t.Fatalf("snippet does not mark the match: %q", h.Snippet)
+			}
+		}
+		if hits, err := ms.Search("filter", 1); err != nil || len(hits) != 1 {
+			t.Fatalf("limit not applied: %v (%v)", hitKeys(hits), err)
+		}
+		if hits, err := ms.Search(`"(filter`, 10); err != nil || len(hits) != 3 {
+			t.Fatalf("punctuation in query: %v (%v)", hitKeys(hits), err)
+		}
+	})
+}
+
+func TestSearchFollowsReplaceAndDelete(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Backend) {
+		ms := s.MessageStore()
+		at := time.Date(2026, 2, 21, 10, 0, 0, 0, time.UTC)
+		if err := ms.Create(makeMessage("m1", "passport renewal", at)); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if err := ms.ReplaceAll([]*model.Message{makeMessage("sum", "summary: renewal booked", at)}); err != nil {
+			t.Fatalf("replace: %v", err)
+		}
+		hits, err := ms.Search("renewal", 10)
+		if err != nil || len(hits) != 1 || hits[0].ID != "sum" {
+			t.Fatalf("index not replaced: %v (%v)", hitKeys(hits), err)
+		}
+		if err := ms.DeleteAll(); err != nil {
+			t.Fatalf("delete all: %v", err)
+		}
+		if hits, err := ms.Search("renewal", 10); err != nil || len(hits) != 0 {
+			t.Fatalf("deleted messages still found: %v (%v)", hitKeys(hits), err)
+		}
+	})
+}
+
+func TestSearchBackfillsExistingStore(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "store.db")
+	s, err := OpenSQLite(p)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	at := time.Date(2026, 1, 10, 10, 0, 0, 0, time.UTC)
+	if err := s.Messages.Create(makeMessage("old", "dentist on tuesday", at)); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := s.Messages.Archive("2026-01", []*model.Message{makeMessage("new", "dentist moved", at.Add(time.Hour))}); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if _, err := s.db.Exec(`DELETE FROM messages_fts`); err != nil {
+		t.Fatalf("clear index: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	s, err = OpenSQLite(p)
+	if err != nil {
+		t.Fatalf("reopen sqlite: %v", err)
+	}
+	defer s.Close()
+	hits, err := s.Messages.Search("dentist", 10)
+	got := strings.Join(hitKeys(hits), ",")
+	if err != nil || len(hits) != 2 || !strings.Contains(got, "2026-01/old") || !strings.Contains(got, "/new") {
+		t.Fatalf("unexpected hits after backfill: %s (%v)", got, err)
+	}
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/agusx1211/miclaw/model"
@@ -12,6 +13,9 @@ import (
 type SQLiteStore struct {
 	db       *sql.DB
 	Messages MessageStore
+	queue    *QueueStore
+	costs    *CostStore
+	events   *EventStore
 }
 
 type sqliteMessageStore struct {
@@ -19,24 +23,68 @@ type sqliteMessageStore struct {
 }
 
 var _ MessageStore = (*sqliteMessageStore)(nil)
+var _ Backend = (*SQLiteStore)(nil)
 
 type rowScanner interface {
 	Scan(dest ...any) error
 }
 
+// SQLiteOptions tunes the connections to the database file. Zero fields
+// take the defaults: WAL journal, a 5 second busy timeout, NORMAL sync and
+// one open connection.
+type SQLiteOptions struct {
+	JournalMode   string
+	BusyTimeoutMS int
+	Synchronous   string
+	MaxOpenConns  int
+}
+
+const (
+	defaultJournalMode   = "WAL"
+	defaultBusyTimeoutMS = 5000
+	defaultSynchronous   = "NORMAL"
+)
+
+// dsn returns path with the pragmas every new connection runs. busy_timeout
+// comes first so switching the journal mode waits out other writers.
+func (o SQLiteOptions) dsn(path string) string {
+
+	if o.JournalMode == "" {
+		o.JournalMode = defaultJournalMode
+	}
+	if o.BusyTimeoutMS == 0 {
+		o.BusyTimeoutMS = defaultBusyTimeoutMS
+	}
+	if o.Synchronous == "" {
+		o.Synchronous = defaultSynchronous
+	}
+	return fmt.Sprintf("%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(%s)&_pragma=synchronous(%s)",
+		path, o.BusyTimeoutMS, o.JournalMode, o.Synchronous)
+}
+
 func OpenSQLite(path string) (*SQLiteStore, error) {
 
-	db, err := sql.Open("sqlite", path)
+	return OpenSQLiteWith(path, SQLiteOptions{})
+}
+
+// OpenSQLiteWith opens the store at path with opts applied to every
+// connection.
+func OpenSQLiteWith(path string, opts SQLiteOptions) (*SQLiteStore, error) {
+
+	db, err := sql.Open("sqlite", opts.dsn(path))
 	if err != nil {
 		return nil, err
 	}
-	db.SetMaxOpenConns(1)
+	db.SetMaxOpenConns(max(opts.MaxOpenConns, 1))
 	if err := initSchema(db); err != nil {
 		_ = db.Close()
 		return nil, err
 	}
 	s := &SQLiteStore{db: db}
 	s.Messages = &sqliteMessageStore{db: db}
+	s.queue = &QueueStore{db: db}
+	s.costs = &CostStore{db: db}
+	s.events = &EventStore{db: db}
 
 	return s, nil
 }
@@ -59,8 +107,31 @@ func initSchema(db *sql.DB) error {
 	if _, err := db.Exec(schemaMessagesIndex); err != nil {
 		return err
 	}
+	if _, err := db.Exec(schemaArchivedMessages); err != nil {
+		return err
+	}
+	for _, table := range []string{"messages", "archived_messages"} {
+		if err := addSQLiteColumn(db, table, "metadata_json", "TEXT"); err != nil {
+			return err
+		}
+	}
+	if _, err := db.Exec(schemaQueue); err != nil {
+		return err
+	}
+	if err := addSQLiteColumn(db, "queue", "priority", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if _, err := db.Exec(schemaCosts); err != nil {
+		return err
+	}
+	if _, err := db.Exec(schemaEvents); err != nil {
+		return err
+	}
+	if _, err := db.Exec(schemaMessagesFTS); err != nil {
+		return err
+	}
 
-	return nil
+	return backfillSearch(db)
 }
 
 const schemaMessages = `
@@ -68,33 +139,70 @@ CREATE TABLE IF NOT EXISTS messages (
 	id TEXT PRIMARY KEY,
 	role TEXT,
 	parts_json TEXT,
-	created_at DATETIME
+	created_at DATETIME,
+	metadata_json TEXT
 )`
 
 const schemaMessagesIndex = `
 CREATE INDEX IF NOT EXISTS idx_messages_created ON messages(created_at, id)`
 
+const schemaArchivedMessages = `
+CREATE TABLE IF NOT EXISTS archived_messages (
+	period TEXT,
+	id TEXT,
+	role TEXT,
+	parts_json TEXT,
+	created_at DATETIME,
+	metadata_json TEXT,
+	PRIMARY KEY (period, id)
+)`
+
+// addSQLiteColumn adds column to table in databases created before the
+// column existed.
+func addSQLiteColumn(db *sql.DB, table, column, decl string) error {
+
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, decl))
+	return err
+}
+
 func (s *sqliteMessageStore) Create(msg *model.Message) error {
 
-	raw, err := encodeMessage(msg)
+	raw, meta, err := encodeMessage(msg)
 	if err != nil {
 		return err
 	}
-	_, err = s.db.Exec(
-		`INSERT INTO messages (id, role, parts_json, created_at)
-		 VALUES (?, ?, ?, ?)`,
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`INSERT INTO messages (id, role, parts_json, created_at, metadata_json)
+		 VALUES (?, ?, ?, ?, ?)`,
 		msg.ID,
 		string(msg.Role),
 		raw,
 		timeToDB(msg.CreatedAt),
+		meta,
 	)
-	return err
+	if err == nil {
+		err = indexMessage(tx, "", msg)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *sqliteMessageStore) Get(id string) (*model.Message, error) {
 
 	row := s.db.QueryRow(
-		`SELECT id, role, parts_json, created_at
+		`SELECT id, role, parts_json, created_at, metadata_json
 		 FROM messages WHERE id = ?`,
 		id,
 	)
@@ -104,7 +212,7 @@ func (s *sqliteMessageStore) Get(id string) (*model.Message, error) {
 func (s *sqliteMessageStore) List(limit, offset int) ([]*model.Message, error) {
 
 	rows, err := s.db.Query(
-		`SELECT id, role, parts_json, created_at
+		`SELECT id, role, parts_json, created_at, metadata_json
 		 FROM messages
 		 ORDER BY created_at, id LIMIT ? OFFSET ?`,
 		limit,
@@ -132,6 +240,9 @@ func (s *sqliteMessageStore) List(limit, offset int) ([]*model.Message, error) {
 
 func (s *sqliteMessageStore) DeleteAll() error {
 
+	if _, err := s.db.Exec(`DELETE FROM messages_fts WHERE period = ''`); err != nil {
+		return err
+	}
 	_, err := s.db.Exec(`DELETE FROM messages`)
 	return err
 }
@@ -153,33 +264,104 @@ func (s *sqliteMessageStore) ReplaceAll(msgs []*model.Message) error {
 	if err != nil {
 		return err
 	}
-	if _, err := tx.Exec(`DELETE FROM messages`); err != nil {
+	if err := replaceMessages(tx, msgs); err != nil {
 		_ = tx.Rollback()
 		return err
 	}
-	for _, msg := range msgs {
+	if err := tx.Commit(); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+func (s *sqliteMessageStore) Archive(period string, msgs []*model.Message) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`INSERT OR REPLACE INTO archived_messages (period, id, role, parts_json, created_at, metadata_json)
+		 SELECT ?, id, role, parts_json, created_at, metadata_json FROM messages`,
+		period,
+	)
+	if err == nil {
+		err = archiveSearch(tx, period)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := replaceMessages(tx, msgs); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return nil
+}
+
+func (s *sqliteMessageStore) ListArchive(period string) ([]*model.Message, error) {
+
+	rows, err := s.db.Query(
+		`SELECT id, role, parts_json, created_at, metadata_json
+		 FROM archived_messages WHERE period = ?
+		 ORDER BY created_at, id`,
+		period,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-		raw, err := encodeMessage(msg)
+	out := make([]*model.Message, 0)
+	for rows.Next() {
+		v, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func replaceMessages(tx *sql.Tx, msgs []*model.Message) error {
+
+	if _, err := tx.Exec(`DELETE FROM messages`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM messages_fts WHERE period = ''`); err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		raw, meta, err := encodeMessage(msg)
 		if err != nil {
-			_ = tx.Rollback()
 			return err
 		}
 		_, err = tx.Exec(
-			`INSERT INTO messages (id, role, parts_json, created_at)
-			 VALUES (?, ?, ?, ?)`,
+			`INSERT INTO messages (id, role, parts_json, created_at, metadata_json)
+			 VALUES (?, ?, ?, ?, ?)`,
 			msg.ID,
 			string(msg.Role),
 			raw,
 			timeToDB(msg.CreatedAt),
+			meta,
 		)
 		if err != nil {
-			_ = tx.Rollback()
 			return err
 		}
-	}
-	if err := tx.Commit(); err != nil {
-		_ = tx.Rollback()
-		return err
+		if err := indexMessage(tx, "", msg); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -191,13 +373,19 @@ func scanMessage(r rowScanner) (*model.Message, error) {
 	var role string
 	var raw string
 	var createdAt string
-	if err := r.Scan(&id, &role, &raw, &createdAt); err != nil {
+	var meta sql.NullString
+	if err := r.Scan(&id, &role, &raw, &createdAt, &meta); err != nil {
 		return nil, err
 	}
 	v, err := decodeMessage(raw)
 	if err != nil {
 		return nil, err
 	}
+	if meta.Valid && meta.String != "" {
+		if err := json.Unmarshal([]byte(meta.String), &v.Metadata); err != nil {
+			return nil, err
+		}
+	}
 	created, err := timeFromDB(createdAt)
 	if err != nil {
 		return nil, err
@@ -207,14 +395,43 @@ func scanMessage(r rowScanner) (*model.Message, error) {
 	return v, nil
 }
 
-func encodeMessage(msg *model.Message) (string, error) {
+func scanMessages(rows *sql.Rows) ([]*model.Message, error) {
 
-	b, err := json.Marshal(msg)
+	defer rows.Close()
+	out := make([]*model.Message, 0)
+	for rows.Next() {
+		v, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// encodeMessage returns the parts_json and metadata_json columns of msg.
+// Metadata lives only in its own column, which is NULL when there is none.
+func encodeMessage(msg *model.Message) (string, any, error) {
+
+	v := *msg
+	v.Metadata = nil
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(msg.Metadata) == 0 {
+		return string(b), nil, nil
+	}
+	meta, err := json.Marshal(msg.Metadata)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return string(b), nil
+	return string(b), string(meta), nil
 }
 
 func decodeMessage(raw string) (*model.Message, error) {
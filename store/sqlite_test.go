@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -36,112 +38,282 @@ func makeMessage(id, text string, at time.Time) *model.Message {
 }
 
 func TestCreateAndGetMessage(t *testing.T) {
-	s := openTestStore(t)
-	want := makeMessage("m1", "one", time.Date(2026, 2, 21, 10, 0, 0, 0, time.UTC))
-	if err := s.Messages.Create(want); err != nil {
-		t.Fatalf("create message: %v", err)
-	}
-	got, err := s.Messages.Get("m1")
-	if err != nil {
-		t.Fatalf("get message: %v", err)
-	}
-	if got.ID != want.ID || got.Role != want.Role || got.CreatedAt != want.CreatedAt {
-		t.Fatalf("message mismatch: want %#v got %#v", want, got)
-	}
-	part := got.Parts[0].(model.TextPart)
-	if part.Text != "one" {
-		t.Fatalf("unexpected text part: %#v", got.Parts[0])
-	}
+	eachBackend(t, func(t *testing.T, s Backend) {
+		want := makeMessage("m1", "one", time.Date(2026, 2, 21, 10, 0, 0, 0, time.UTC))
+		if err := s.MessageStore().Create(want); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+		got, err := s.MessageStore().Get("m1")
+		if err != nil {
+			t.Fatalf("get message: %v", err)
+		}
+		if got.ID != want.ID || got.Role != want.Role || got.CreatedAt != want.CreatedAt {
+			t.Fatalf("message mismatch: want %#v got %#v", want, got)
+		}
+		part := got.Parts[0].(model.TextPart)
+		if part.Text != "one" {
+			t.Fatalf("unexpected text part: %#v", got.Parts[0])
+		}
+	})
 }
 
 func TestListMessages(t *testing.T) {
-	s := openTestStore(t)
-	base := time.Date(2026, 2, 21, 11, 0, 0, 0, time.UTC)
-	for i, text := range []string{"one", "two", "three"} {
-		if err := s.Messages.Create(makeMessage(fmt.Sprintf("m%d", i+1), text, base.Add(time.Duration(i)*time.Minute))); err != nil {
-			t.Fatalf("create message %d: %v", i, err)
+	eachBackend(t, func(t *testing.T, s Backend) {
+		base := time.Date(2026, 2, 21, 11, 0, 0, 0, time.UTC)
+		for i, text := range []string{"one", "two", "three"} {
+			if err := s.MessageStore().Create(makeMessage(fmt.Sprintf("m%d", i+1), text, base.Add(time.Duration(i)*time.Minute))); err != nil {
+				t.Fatalf("create message %d: %v", i, err)
+			}
 		}
-	}
-	got, err := s.Messages.List(10, 0)
-	if err != nil {
-		t.Fatalf("list messages: %v", err)
-	}
-	if len(got) != 3 {
-		t.Fatalf("expected 3 messages, got %d", len(got))
-	}
-	if got[0].ID != "m1" || got[1].ID != "m2" || got[2].ID != "m3" {
-		t.Fatalf("unexpected order: %q %q %q", got[0].ID, got[1].ID, got[2].ID)
-	}
-	paged, err := s.Messages.List(1, 1)
-	if err != nil {
-		t.Fatalf("list paged: %v", err)
-	}
-	if len(paged) != 1 || paged[0].ID != "m2" {
-		t.Fatalf("unexpected paged result: %#v", paged)
-	}
+		got, err := s.MessageStore().List(10, 0)
+		if err != nil {
+			t.Fatalf("list messages: %v", err)
+		}
+		if len(got) != 3 {
+			t.Fatalf("expected 3 messages, got %d", len(got))
+		}
+		if got[0].ID != "m1" || got[1].ID != "m2" || got[2].ID != "m3" {
+			t.Fatalf("unexpected order: %q %q %q", got[0].ID, got[1].ID, got[2].ID)
+		}
+		paged, err := s.MessageStore().List(1, 1)
+		if err != nil {
+			t.Fatalf("list paged: %v", err)
+		}
+		if len(paged) != 1 || paged[0].ID != "m2" {
+			t.Fatalf("unexpected paged result: %#v", paged)
+		}
+	})
 }
 
 func TestCountMessages(t *testing.T) {
-	s := openTestStore(t)
-	for i := range 3 {
-		if err := s.Messages.Create(makeMessage(fmt.Sprintf("m%d", i+1), "x", time.Date(2026, 2, 21, 12, i, 0, 0, time.UTC))); err != nil {
-			t.Fatalf("create message %d: %v", i, err)
+	eachBackend(t, func(t *testing.T, s Backend) {
+		for i := range 3 {
+			if err := s.MessageStore().Create(makeMessage(fmt.Sprintf("m%d", i+1), "x", time.Date(2026, 2, 21, 12, i, 0, 0, time.UTC))); err != nil {
+				t.Fatalf("create message %d: %v", i, err)
+			}
 		}
-	}
-	n, err := s.Messages.Count()
-	if err != nil {
-		t.Fatalf("count messages: %v", err)
-	}
-	if n != 3 {
-		t.Fatalf("expected count 3, got %d", n)
-	}
+		n, err := s.MessageStore().Count()
+		if err != nil {
+			t.Fatalf("count messages: %v", err)
+		}
+		if n != 3 {
+			t.Fatalf("expected count 3, got %d", n)
+		}
+	})
 }
 
 func TestReplaceAllMessages(t *testing.T) {
-	s := openTestStore(t)
-	if err := s.Messages.Create(makeMessage("old", "old", time.Date(2026, 2, 21, 13, 0, 0, 0, time.UTC))); err != nil {
-		t.Fatalf("create old message: %v", err)
+	eachBackend(t, func(t *testing.T, s Backend) {
+		if err := s.MessageStore().Create(makeMessage("old", "old", time.Date(2026, 2, 21, 13, 0, 0, 0, time.UTC))); err != nil {
+			t.Fatalf("create old message: %v", err)
+		}
+		repl := []*model.Message{
+			makeMessage("new-1", "new one", time.Date(2026, 2, 21, 13, 1, 0, 0, time.UTC)),
+			makeMessage("new-2", "new two", time.Date(2026, 2, 21, 13, 2, 0, 0, time.UTC)),
+		}
+		if err := s.MessageStore().ReplaceAll(repl); err != nil {
+			t.Fatalf("replace all: %v", err)
+		}
+		got, err := s.MessageStore().List(10, 0)
+		if err != nil {
+			t.Fatalf("list messages: %v", err)
+		}
+		if len(got) != 2 || got[0].ID != "new-1" || got[1].ID != "new-2" {
+			t.Fatalf("unexpected replace result: %#v", got)
+		}
+	})
+}
+
+func TestArchiveMovesThreadUnderPeriod(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Backend) {
+		for i, id := range []string{"jan-1", "jan-2"} {
+			if err := s.MessageStore().Create(makeMessage(id, id, time.Date(2026, 1, 31, 10, i, 0, 0, time.UTC))); err != nil {
+				t.Fatalf("create %s: %v", id, err)
+			}
+		}
+		summary := makeMessage("summary", "summary", time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC))
+		if err := s.MessageStore().Archive("2026-01", []*model.Message{summary}); err != nil {
+			t.Fatalf("archive: %v", err)
+		}
+		got, err := s.MessageStore().List(10, 0)
+		if err != nil {
+			t.Fatalf("list messages: %v", err)
+		}
+		if len(got) != 1 || got[0].ID != "summary" {
+			t.Fatalf("unexpected thread after archive: %#v", got)
+		}
+		old, err := s.MessageStore().ListArchive("2026-01")
+		if err != nil {
+			t.Fatalf("list archive: %v", err)
+		}
+		if len(old) != 2 || old[0].ID != "jan-1" || old[1].ID != "jan-2" {
+			t.Fatalf("unexpected archive: %#v", old)
+		}
+		if other, _ := s.MessageStore().ListArchive("2026-02"); len(other) != 0 {
+			t.Fatalf("unexpected messages in other period: %#v", other)
+		}
+	})
+}
+
+func TestMessageMetadataRoundTrips(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Backend) {
+		msg := makeMessage("m1", "hi", time.Date(2026, 2, 21, 10, 0, 0, 0, time.UTC))
+		msg.Metadata = map[string]string{"source_name": "Alice", "group_id": "g1"}
+		if err := s.MessageStore().Create(msg); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+		if err := s.MessageStore().Create(makeMessage("m2", "plain", time.Date(2026, 2, 21, 10, 1, 0, 0, time.UTC))); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+		var raw string
+		if err := backendDB(s).QueryRow(`SELECT parts_json FROM messages WHERE id = 'm1'`).Scan(&raw); err != nil {
+			t.Fatalf("read parts_json: %v", err)
+		}
+		if strings.Contains(raw, "Alice") {
+			t.Fatalf("metadata stored in parts_json: %s", raw)
+		}
+		got, err := s.MessageStore().Get("m1")
+		if err != nil || got.Metadata["source_name"] != "Alice" || got.Metadata["group_id"] != "g1" {
+			t.Fatalf("metadata not restored: %#v, %v", got, err)
+		}
+		if plain, _ := s.MessageStore().Get("m2"); plain.Metadata != nil {
+			t.Fatalf("message without metadata got %#v", plain.Metadata)
+		}
+		if err := s.MessageStore().Archive("2026-02", nil); err != nil {
+			t.Fatalf("archive: %v", err)
+		}
+		old, err := s.MessageStore().ListArchive("2026-02")
+		if err != nil || len(old) != 2 || old[0].Metadata["source_name"] != "Alice" {
+			t.Fatalf("archive lost metadata: %#v, %v", old, err)
+		}
+	})
+}
+
+func TestOpenSQLiteAddsMetadataColumn(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite", p)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
 	}
-	repl := []*model.Message{
-		makeMessage("new-1", "new one", time.Date(2026, 2, 21, 13, 1, 0, 0, time.UTC)),
-		makeMessage("new-2", "new two", time.Date(2026, 2, 21, 13, 2, 0, 0, time.UTC)),
+	for _, q := range []string{
+		`CREATE TABLE messages (id TEXT PRIMARY KEY, role TEXT, parts_json TEXT, created_at DATETIME)`,
+		`CREATE TABLE archived_messages (period TEXT, id TEXT, role TEXT, parts_json TEXT, created_at DATETIME, PRIMARY KEY (period, id))`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("create old schema: %v", err)
+		}
+	}
+	raw, _, err := encodeMessage(makeMessage("old", "before metadata", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
 	}
-	if err := s.Messages.ReplaceAll(repl); err != nil {
-		t.Fatalf("replace all: %v", err)
+	if _, err := db.Exec(`INSERT INTO messages (id, role, parts_json, created_at) VALUES ('old', 'user', ?, '2026-01-01T00:00:00Z')`, raw); err != nil {
+		t.Fatalf("insert old row: %v", err)
 	}
-	got, err := s.Messages.List(10, 0)
+	_ = db.Close()
+
+	s, err := OpenSQLite(p)
 	if err != nil {
-		t.Fatalf("list messages: %v", err)
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer s.Close()
+	got, err := s.MessageStore().Get("old")
+	if err != nil || got.Metadata != nil {
+		t.Fatalf("old row: %#v, %v", got, err)
+	}
+	msg := makeMessage("new", "after", time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC))
+	msg.Metadata = map[string]string{"source_name": "Bob"}
+	if err := s.MessageStore().Create(msg); err != nil {
+		t.Fatalf("create after migration: %v", err)
 	}
-	if len(got) != 2 || got[0].ID != "new-1" || got[1].ID != "new-2" {
-		t.Fatalf("unexpected replace result: %#v", got)
+	if got, _ := s.MessageStore().Get("new"); got.Metadata["source_name"] != "Bob" {
+		t.Fatalf("metadata lost after migration: %#v", got)
 	}
 }
 
 func TestDeleteAllMessages(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Backend) {
+		if err := s.MessageStore().Create(makeMessage("m1", "one", time.Date(2026, 2, 21, 14, 0, 0, 0, time.UTC))); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+		if err := s.MessageStore().DeleteAll(); err != nil {
+			t.Fatalf("delete all: %v", err)
+		}
+		n, err := s.MessageStore().Count()
+		if err != nil {
+			t.Fatalf("count messages: %v", err)
+		}
+		if n != 0 {
+			t.Fatalf("expected 0 messages, got %d", n)
+		}
+	})
+}
+
+func TestGetNonexistentMessage(t *testing.T) {
+	eachBackend(t, func(t *testing.T, s Backend) {
+		_, err := s.MessageStore().Get("missing")
+		if err == nil {
+			t.Fatal("expected error for missing message")
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("expected sql.ErrNoRows, got %v", err)
+		}
+	})
+}
+
+func TestOpenSQLiteAppliesPragmas(t *testing.T) {
 	s := openTestStore(t)
-	if err := s.Messages.Create(makeMessage("m1", "one", time.Date(2026, 2, 21, 14, 0, 0, 0, time.UTC))); err != nil {
-		t.Fatalf("create message: %v", err)
+	var mode string
+	var timeout, sync int
+	if err := s.db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil {
+		t.Fatalf("journal_mode: %v", err)
 	}
-	if err := s.Messages.DeleteAll(); err != nil {
-		t.Fatalf("delete all: %v", err)
+	if err := s.db.QueryRow(`PRAGMA busy_timeout`).Scan(&timeout); err != nil {
+		t.Fatalf("busy_timeout: %v", err)
 	}
-	n, err := s.Messages.Count()
-	if err != nil {
-		t.Fatalf("count messages: %v", err)
+	if err := s.db.QueryRow(`PRAGMA synchronous`).Scan(&sync); err != nil {
+		t.Fatalf("synchronous: %v", err)
 	}
-	if n != 0 {
-		t.Fatalf("expected 0 messages, got %d", n)
+	if mode != "wal" || timeout != 5000 || sync != 1 {
+		t.Fatalf("unexpected pragmas: journal_mode=%s busy_timeout=%d synchronous=%d", mode, timeout, sync)
 	}
 }
 
-func TestGetNonexistentMessage(t *testing.T) {
-	s := openTestStore(t)
-	_, err := s.Messages.Get("missing")
-	if err == nil {
-		t.Fatal("expected error for missing message")
+func TestConcurrentCreatesDoNotLock(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "store.db")
+	stores := make([]*SQLiteStore, 2)
+	for i := range stores {
+		s, err := OpenSQLiteWith(p, SQLiteOptions{MaxOpenConns: 4})
+		if err != nil {
+			t.Fatalf("open sqlite: %v", err)
+		}
+		defer s.Close()
+		stores[i] = s
+	}
+	const workers, perWorker = 8, 25
+	errs := make(chan error, workers*perWorker)
+	var wg sync.WaitGroup
+	at := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := stores[w%len(stores)]
+			for i := range perWorker {
+				id := fmt.Sprintf("w%d-%d", w, i)
+				if err := s.Messages.Create(makeMessage(id, id, at.Add(time.Duration(i)*time.Second))); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("create: %v", err)
 	}
-	if !errors.Is(err, sql.ErrNoRows) {
-		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	n, err := stores[0].Messages.Count()
+	if err != nil || n != workers*perWorker {
+		t.Fatalf("count = %d (%v), want %d", n, err, workers*perWorker)
 	}
 }
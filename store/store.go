@@ -9,4 +9,22 @@ type MessageStore interface {
 	DeleteAll() error
 	Count() (int, error)
 	ReplaceAll(msgs []*model.Message) error
+	// Archive copies the thread into the archive under period and replaces
+	// the thread with msgs, atomically.
+	Archive(period string, msgs []*model.Message) error
+	ListArchive(period string) ([]*model.Message, error)
+	// Search returns up to limit messages from the thread and the archive
+	// whose text contains every word of query, best matches first.
+	Search(query string, limit int) ([]MessageHit, error)
+}
+
+// Backend is an open thread store: the message thread, the persisted
+// input queue, the cost totals and the event log. SQLiteStore and
+// PostgresStore implement it.
+type Backend interface {
+	MessageStore() MessageStore
+	Queue() *QueueStore
+	Costs() *CostStore
+	Events() *EventStore
+	Close() error
 }
@@ -0,0 +1,53 @@
+package miclaw
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/store"
+)
+
+// ExportThread writes the current thread, or the archived thread of period
+// when it is set, from the configured store to w as a JSON bundle.
+func ExportThread(cfg *config.Config, period string, w io.Writer) error {
+
+	s, err := openCLIStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	data, err := store.ExportThread(s.MessageStore(), period, time.Now())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ImportThread reads a bundle written by ExportThread from r into the
+// configured store's thread, which must be empty, and returns how many
+// messages it restored.
+func ImportThread(cfg *config.Config, r io.Reader) (int, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, fmt.Errorf("read thread bundle: %v", err)
+	}
+	s, err := openCLIStore(cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	return store.ImportThread(s.MessageStore(), data)
+}
+
+func openCLIStore(cfg *config.Config) (store.Backend, error) {
+
+	if err := os.MkdirAll(cfg.StatePath, 0o755); err != nil {
+		return nil, err
+	}
+	return openThreadStore(cfg)
+}
@@ -14,6 +14,19 @@ type Tool interface {
 	Run(ctx context.Context, call model.ToolCallPart) (ToolResult, error)
 }
 
+// SerialTool is implemented by tools that can say their calls must not
+// overlap with other calls of the same turn, such as tools that write files
+// or send messages. Tools without it may run in parallel.
+type SerialTool interface {
+	Serial() bool
+}
+
+// IsSerial reports whether calls of t must run on their own.
+func IsSerial(t Tool) bool {
+	s, ok := t.(SerialTool)
+	return ok && s.Serial()
+}
+
 type JSONSchema struct {
 	Type       string                `json:"type"`
 	Properties map[string]JSONSchema `json:"properties,omitempty"`
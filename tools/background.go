@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+const bgKillGrace = 5 * time.Second
+
+func bgListTool() Tool {
+	return tool{
+		name: "bg_list",
+		desc: "List background processes started by exec with their state and elapsed time",
+		params: JSONSchema{
+			Type:       "object",
+			Properties: map[string]JSONSchema{},
+		},
+		runFn: runBgList,
+	}
+}
+
+func bgKillTool() Tool {
+	return tool{
+		name:   "bg_kill",
+		serial: true,
+		desc:   "Stop a background process (SIGTERM, then SIGKILL after a grace period) and return its output",
+		params: JSONSchema{
+			Type: "object",
+			Properties: map[string]JSONSchema{
+				"pid": {
+					Type: "integer",
+					Desc: "Process ID returned by exec",
+				},
+			},
+			Required: []string{"pid"},
+		},
+		runFn: runBgKill,
+	}
+}
+
+func runBgList(_ context.Context, _ model.ToolCallPart) (ToolResult, error) {
+	procs := execProcessManager.List()
+	if len(procs) == 0 {
+		return ToolResult{Content: "no background processes"}, nil
+	}
+	lines := make([]string, 0, len(procs))
+	for _, p := range procs {
+		state := "completed"
+		if p.Running {
+			state = "running"
+		}
+		elapsed := p.Elapsed.Round(time.Millisecond)
+		lines = append(lines, fmt.Sprintf("%d\t%s\t%s\t%s", p.PID, state, elapsed, p.Command))
+	}
+	return ToolResult{Content: strings.Join(lines, "\n")}, nil
+}
+
+func runBgKill(_ context.Context, call model.ToolCallPart) (ToolResult, error) {
+	var input struct {
+		PID *int `json:"pid"`
+	}
+	if err := json.Unmarshal(call.Parameters, &input); err != nil {
+		return ToolResult{Content: fmt.Sprintf("parse bg_kill parameters: %v", err), IsError: true}, nil
+	}
+	if input.PID == nil {
+		return ToolResult{Content: "bg_kill parameter pid is required", IsError: true}, nil
+	}
+	output, err := execProcessManager.Kill(*input.PID, bgKillGrace)
+	if err != nil {
+		return ToolResult{Content: err.Error(), IsError: true}, nil
+	}
+	return ToolResult{Content: fmt.Sprintf("killed process %d\noutput:\n%s", *input.PID, output)}, nil
+}
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+func TestBgListAndKillSleep(t *testing.T) {
+	pid := startBackgroundProcess(t, "echo started; sleep 30")
+	waitProcessOutput(t, pid, "started")
+
+	got := runBgCall(t, bgListTool(), nil)
+	if got.IsError {
+		t.Fatalf("unexpected bg_list error: %s", got.Content)
+	}
+	line := bgListLine(got.Content, pid)
+	if line == "" {
+		t.Fatalf("pid %d missing from bg_list: %q", pid, got.Content)
+	}
+	if !strings.Contains(line, "\trunning\t") || !strings.HasSuffix(line, "echo started; sleep 30") {
+		t.Fatalf("unexpected bg_list line: %q", line)
+	}
+
+	start := time.Now()
+	got = runBgCall(t, bgKillTool(), map[string]any{"pid": pid})
+	if got.IsError {
+		t.Fatalf("unexpected bg_kill error: %s", got.Content)
+	}
+	if time.Since(start) >= bgKillGrace {
+		t.Fatal("bg_kill waited for the grace period on a SIGTERM-able process")
+	}
+	if !strings.Contains(got.Content, "killed process "+strconv.Itoa(pid)) || !strings.Contains(got.Content, "started") {
+		t.Fatalf("unexpected bg_kill result: %q", got.Content)
+	}
+	if _, _, _, err := execProcessManager.Status(pid); err == nil {
+		t.Fatalf("expected process %d to be removed from the manager", pid)
+	}
+	if line := bgListLine(runBgCall(t, bgListTool(), nil).Content, pid); line != "" {
+		t.Fatalf("killed process still listed: %q", line)
+	}
+}
+
+func TestBgKillUnknownPID(t *testing.T) {
+	got := runBgCall(t, bgKillTool(), map[string]any{"pid": -1})
+	if !got.IsError || !strings.Contains(got.Content, "not found") {
+		t.Fatalf("expected not found error, got %q", got.Content)
+	}
+}
+
+func TestBgKillRequiresPID(t *testing.T) {
+	got := runBgCall(t, bgKillTool(), map[string]any{})
+	if !got.IsError || !strings.Contains(got.Content, "pid is required") {
+		t.Fatalf("expected missing pid error, got %q", got.Content)
+	}
+}
+
+func runBgCall(t *testing.T, tl Tool, params map[string]any) ToolResult {
+	t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("marshal params: %v", err)
+	}
+	got, err := tl.Run(context.Background(), model.ToolCallPart{ID: "1", Name: tl.Name(), Parameters: raw})
+	if err != nil {
+		t.Fatalf("%s: %v", tl.Name(), err)
+	}
+	return got
+}
+
+func bgListLine(content string, pid int) string {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, strconv.Itoa(pid)+"\t") {
+			return line
+		}
+	}
+	return ""
+}
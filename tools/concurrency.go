@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/tooling"
+)
+
+// LimitConcurrency caps how many calls of each tool named in limits run at
+// once. Extra calls wait for a slot or for their context to end. Tools
+// without a limit are returned unchanged.
+func LimitConcurrency(toolList []Tool, limits map[string]int) []Tool {
+	out := make([]Tool, 0, len(toolList))
+	for _, t := range toolList {
+		limit, ok := limits[t.Name()]
+		if !ok {
+			out = append(out, t)
+			continue
+		}
+		out = append(out, limitedTool(t, limit))
+	}
+	return out
+}
+
+func limitedTool(base Tool, limit int) Tool {
+	sem := make(chan struct{}, limit)
+	return tool{
+		name:   base.Name(),
+		desc:   base.Description(),
+		params: base.Parameters(),
+		serial: tooling.IsSerial(base),
+		runFn: func(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ToolResult{}, ctx.Err()
+			}
+			defer func() { <-sem }()
+			return base.Run(ctx, call)
+		},
+	}
+}
@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+func countingTool(name string, running, peak *atomic.Int32) Tool {
+	return tool{
+		name: name,
+		runFn: func(context.Context, model.ToolCallPart) (ToolResult, error) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			running.Add(-1)
+			return ToolResult{Content: "ok"}, nil
+		},
+	}
+}
+
+func runConcurrently(t *testing.T, tl Tool, n int) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tl.Run(context.Background(), model.ToolCallPart{Name: tl.Name()}); err != nil {
+				t.Errorf("run %s: %v", tl.Name(), err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLimitConcurrencyCapsParallelCalls(t *testing.T) {
+	var running, peak atomic.Int32
+	limited := LimitConcurrency([]Tool{countingTool("fetch", &running, &peak)}, map[string]int{"fetch": 2})
+	runConcurrently(t, limited[0], 8)
+	if got := peak.Load(); got != 2 {
+		t.Fatalf("want peak 2 concurrent calls, got %d", got)
+	}
+}
+
+func TestLimitConcurrencyLeavesOtherToolsAlone(t *testing.T) {
+	var running, peak atomic.Int32
+	base := countingTool("read", &running, &peak)
+	limited := LimitConcurrency([]Tool{base}, map[string]int{"fetch": 1})
+	runConcurrently(t, limited[0], 4)
+	if got := peak.Load(); got < 2 {
+		t.Fatalf("uncapped tool was serialized: peak %d", got)
+	}
+}
+
+func TestLimitConcurrencyWaitRespectsContext(t *testing.T) {
+	block := make(chan struct{})
+	slow := tool{name: "fetch", runFn: func(context.Context, model.ToolCallPart) (ToolResult, error) {
+		<-block
+		return ToolResult{}, nil
+	}}
+	limited := LimitConcurrency([]Tool{slow}, map[string]int{"fetch": 1})[0]
+	go limited.Run(context.Background(), model.ToolCallPart{})
+	defer close(block)
+	time.Sleep(10 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := limited.Run(ctx, model.ToolCallPart{}); err != context.DeadlineExceeded {
+		t.Fatalf("want deadline exceeded while waiting, got %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/agusx1211/miclaw/model"
 )
@@ -14,6 +15,8 @@ const (
 	cronActionList   = "list"
 	cronActionAdd    = "add"
 	cronActionRemove = "remove"
+	cronActionPause  = "pause"
+	cronActionResume = "resume"
 )
 
 type cronParams struct {
@@ -21,6 +24,8 @@ type cronParams struct {
 	ID         string
 	Expression string
 	Prompt     string
+	Timezone   string
+	RunAt      time.Time
 }
 
 type cronRawParams struct {
@@ -28,60 +33,95 @@ type cronRawParams struct {
 	ID         *string `json:"id"`
 	Expression *string `json:"expression"`
 	Prompt     *string `json:"prompt"`
+	RunAt      *string `json:"run_at"`
+	Timezone   *string `json:"timezone"`
 }
 
 func CronTool(scheduler *Scheduler) Tool {
 	return tool{
-		name: "cron",
-		desc: "Schedule recurring prompts",
-		params: JSONSchema{
-			Type:     "object",
-			Required: []string{"action"},
-			Properties: map[string]JSONSchema{
-				"action": {
-					Type: "string",
-					Enum: []string{cronActionList, cronActionAdd, cronActionRemove},
-					Desc: "Action to perform: list, add, remove",
-				},
-				"id":         {Type: "string", Desc: "Cron job ID for remove"},
-				"expression": {Type: "string", Desc: "Cron expression"},
-				"prompt":     {Type: "string", Desc: "Prompt text to inject"},
-			},
-		},
+		name:   "cron",
+		serial: true,
+		desc:   "Schedule recurring prompts, or one-shot prompts with run_at",
+		params: cronSchema(),
 		runFn: func(_ context.Context, call model.ToolCallPart) (ToolResult, error) {
-			params, err := parseCronParams(call.Parameters)
-			if err != nil {
-				return ToolResult{IsError: true, Content: err.Error()}, nil
-			}
-			switch params.Action {
-			case cronActionList:
-				jobs, err := scheduler.ListJobs()
-				if err != nil {
-					return ToolResult{IsError: true, Content: err.Error()}, nil
-				}
-				raw, err := json.Marshal(jobs)
-				if err != nil {
-					return ToolResult{IsError: true, Content: err.Error()}, nil
-				}
-				return ToolResult{Content: string(raw)}, nil
-			case cronActionAdd:
-				id, err := scheduler.AddJob(params.Expression, params.Prompt)
-				if err != nil {
-					return ToolResult{IsError: true, Content: err.Error()}, nil
-				}
-				raw, _ := json.Marshal(map[string]string{"id": id})
-				return ToolResult{Content: string(raw)}, nil
-			default:
-				err := scheduler.RemoveJob(params.ID)
-				if err != nil {
-					return ToolResult{IsError: true, Content: err.Error()}, nil
-				}
-				return ToolResult{Content: fmt.Sprintf("removed cron job %s", params.ID)}, nil
-			}
+			return runCron(scheduler, call)
+		},
+	}
+}
+
+func cronSchema() JSONSchema {
+	return JSONSchema{
+		Type:     "object",
+		Required: []string{"action"},
+		Properties: map[string]JSONSchema{
+			"action": {
+				Type: "string",
+				Enum: []string{cronActionList, cronActionAdd, cronActionRemove, cronActionPause, cronActionResume},
+				Desc: "Action to perform: list, add, remove, pause, resume",
+			},
+			"id":         {Type: "string", Desc: "Cron job ID for remove, pause or resume"},
+			"expression": {Type: "string", Desc: "Cron expression for a recurring job"},
+			"run_at":     {Type: "string", Desc: "RFC 3339 time for a one-shot job, instead of expression"},
+			"timezone":   {Type: "string", Desc: "IANA timezone the expression is evaluated in (for example: Europe/Madrid); default from config"},
+			"prompt":     {Type: "string", Desc: "Prompt text to inject"},
 		},
 	}
 }
 
+func runCron(scheduler *Scheduler, call model.ToolCallPart) (ToolResult, error) {
+	params, err := parseCronParams(call.Parameters)
+	if err != nil {
+		return ToolResult{IsError: true, Content: err.Error()}, nil
+	}
+	var content string
+	switch params.Action {
+	case cronActionList:
+		content, err = listCronJobs(scheduler)
+	case cronActionAdd:
+		content, err = addCronJob(scheduler, params)
+	case cronActionPause:
+		err = scheduler.PauseJob(params.ID)
+		content = fmt.Sprintf("paused cron job %s", params.ID)
+	case cronActionResume:
+		err = scheduler.ResumeJob(params.ID)
+		content = fmt.Sprintf("resumed cron job %s", params.ID)
+	default:
+		err = scheduler.RemoveJob(params.ID)
+		content = fmt.Sprintf("removed cron job %s", params.ID)
+	}
+	if err != nil {
+		return ToolResult{IsError: true, Content: err.Error()}, nil
+	}
+	return ToolResult{Content: content}, nil
+}
+
+func listCronJobs(scheduler *Scheduler) (string, error) {
+	jobs, err := scheduler.ListJobs()
+	if err != nil {
+		return "", err
+	}
+	raw, err := json.Marshal(jobs)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+func addCronJob(scheduler *Scheduler, params cronParams) (string, error) {
+	var id string
+	var err error
+	if params.RunAt.IsZero() {
+		id, err = scheduler.AddJob(params.Expression, params.Timezone, params.Prompt)
+	} else {
+		id, err = scheduler.AddOnceJob(params.RunAt, params.Prompt)
+	}
+	if err != nil {
+		return "", err
+	}
+	raw, _ := json.Marshal(map[string]string{"id": id})
+	return string(raw), nil
+}
+
 func parseCronParams(raw json.RawMessage) (cronParams, error) {
 	var input cronRawParams
 	if err := unmarshalObject(raw, &input); err != nil {
@@ -91,29 +131,44 @@ func parseCronParams(raw json.RawMessage) (cronParams, error) {
 		return cronParams{}, errors.New("action is required")
 	}
 	action := strings.TrimSpace(*input.Action)
-	if action != cronActionList && action != cronActionAdd && action != cronActionRemove {
+	switch action {
+	case cronActionList, cronActionAdd, cronActionRemove, cronActionPause, cronActionResume:
+	default:
 		return cronParams{}, errors.New("invalid action")
 	}
 	if action == cronActionAdd {
-		if input.Expression == nil {
-			return cronParams{}, errors.New("expression is required")
-		}
-		if input.Prompt == nil {
-			return cronParams{}, errors.New("prompt is required")
-		}
+		return parseCronAddParams(input)
 	}
-	if action == cronActionRemove && input.ID == nil {
+	if action != cronActionList && input.ID == nil {
 		return cronParams{}, errors.New("id is required")
 	}
 	p := cronParams{Action: action}
 	if input.ID != nil {
 		p.ID = *input.ID
 	}
+	return p, nil
+}
+
+func parseCronAddParams(input cronRawParams) (cronParams, error) {
+	if (input.Expression == nil) == (input.RunAt == nil) {
+		return cronParams{}, errors.New("exactly one of expression or run_at is required")
+	}
+	if input.Prompt == nil {
+		return cronParams{}, errors.New("prompt is required")
+	}
+	p := cronParams{Action: cronActionAdd, Prompt: *input.Prompt}
 	if input.Expression != nil {
 		p.Expression = *input.Expression
 	}
-	if input.Prompt != nil {
-		p.Prompt = *input.Prompt
+	if input.Timezone != nil {
+		p.Timezone = strings.TrimSpace(*input.Timezone)
+	}
+	if input.RunAt != nil {
+		runAt, err := time.Parse(time.RFC3339, strings.TrimSpace(*input.RunAt))
+		if err != nil {
+			return cronParams{}, fmt.Errorf("invalid run_at: %v", err)
+		}
+		p.RunAt = runAt
 	}
 	return p, nil
 }
@@ -53,8 +53,9 @@ func ParseCronExpr(expr string) (CronExpr, error) {
 	}, nil
 }
 
+// Matches reports whether the wall clock of t, in t's location, matches
+// the expression.
 func (c CronExpr) Matches(t time.Time) bool {
-	t = t.UTC()
 	if _, ok := c.minute[t.Minute()]; !ok {
 		return false
 	}
@@ -73,8 +74,11 @@ func (c CronExpr) Matches(t time.Time) bool {
 	return true
 }
 
+// NextAfter returns the first minute after t whose wall clock in t's
+// location matches. Wall-clock times skipped by a daylight saving change
+// never match.
 func (c CronExpr) NextAfter(t time.Time) time.Time {
-	next := t.UTC().Truncate(time.Minute).Add(time.Minute)
+	next := t.Truncate(time.Minute).Add(time.Minute)
 	for i := 0; i < cronSearchLimit; i++ {
 		if c.Matches(next) {
 			return next
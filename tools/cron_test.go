@@ -2,9 +2,11 @@ package tools
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"github.com/agusx1211/miclaw/model"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -103,7 +105,7 @@ func TestCronAddListRemove(t *testing.T) {
 	}
 	defer s.Close()
 
-	id, err := s.AddJob("30 14 * * *", "ping")
+	id, err := s.AddJob("30 14 * * *", "", "ping")
 	if err != nil {
 		t.Fatalf("add job: %v", err)
 	}
@@ -146,7 +148,7 @@ func TestCronJobFires(t *testing.T) {
 	})
 	defer s.Stop()
 
-	if _, err := s.AddJob("*/1 * * * *", "ping"); err != nil {
+	if _, err := s.AddJob("*/1 * * * *", "", "ping"); err != nil {
 		t.Fatalf("add job: %v", err)
 	}
 	now.Store(base.Add(time.Minute).UnixNano())
@@ -167,7 +169,7 @@ func TestCronPersistence(t *testing.T) {
 	if err != nil {
 		t.Fatalf("new scheduler: %v", err)
 	}
-	id, err := s.AddJob("*/5 * * * *", "pulse")
+	id, err := s.AddJob("*/5 * * * *", "", "pulse")
 	if err != nil {
 		t.Fatalf("add job: %v", err)
 	}
@@ -219,7 +221,562 @@ func TestCronToolListAndRemoveInTool(t *testing.T) {
 	}
 }
 
+func TestCronToolAddListRemoveWithLastRun(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cron.db")
+	s, err := NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	base := time.Date(2026, 2, 21, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return base }
+	tool := CronTool(s)
+
+	run := func(params map[string]any) ToolResult {
+		raw, _ := json.Marshal(params)
+		res, err := tool.Run(context.Background(), rawToolCall(t, raw))
+		if err != nil || res.IsError {
+			t.Fatalf("cron %v: %v %q", params, err, res.Content)
+		}
+		return res
+	}
+	var hourly, daily struct{ ID string }
+	_ = json.Unmarshal([]byte(run(map[string]any{"action": "add", "expression": "0 18 * * *", "prompt": "daily"}).Content), &daily)
+	_ = json.Unmarshal([]byte(run(map[string]any{"action": "add", "expression": "0 * * * *", "prompt": "hourly"}).Content), &hourly)
+
+	s.now = func() time.Time { return base.Add(time.Hour) }
+	s.enqueueDue(func(string, string) {})
+
+	var jobs []CronJob
+	if err := json.Unmarshal([]byte(run(map[string]any{"action": "list"}).Content), &jobs); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != hourly.ID || jobs[1].ID != daily.ID {
+		t.Fatalf("want hourly then daily, got %#v", jobs)
+	}
+	if jobs[0].LastRun == nil || !jobs[0].LastRun.Equal(base.Add(time.Hour)) || jobs[1].LastRun != nil {
+		t.Fatalf("unexpected last runs: %v %v", jobs[0].LastRun, jobs[1].LastRun)
+	}
+	if !jobs[0].NextRun.Equal(base.Add(2 * time.Hour)) {
+		t.Fatalf("hourly next run = %s", jobs[0].NextRun)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close scheduler: %v", err)
+	}
+
+	s, err = NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("reopen scheduler: %v", err)
+	}
+	defer s.Close()
+	tool = CronTool(s)
+	jobs, err = s.ListJobs()
+	if err != nil || len(jobs) != 2 {
+		t.Fatalf("list after restart: %#v %v", jobs, err)
+	}
+	for _, j := range jobs {
+		if (j.ID == hourly.ID) != (j.LastRun != nil && j.LastRun.Equal(base.Add(time.Hour))) {
+			t.Fatalf("last run not persisted: %#v", j)
+		}
+	}
+	if got := run(map[string]any{"action": "remove", "id": hourly.ID}).Content; !strings.Contains(got, hourly.ID) {
+		t.Fatalf("unexpected remove result: %q", got)
+	}
+	jobs, err = s.ListJobs()
+	if err != nil {
+		t.Fatalf("list jobs: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != daily.ID {
+		t.Fatalf("want only the daily job after remove, got %#v", jobs)
+	}
+}
+
 func rawToolCall(t *testing.T, raw json.RawMessage) model.ToolCallPart {
 	t.Helper()
 	return model.ToolCallPart{Parameters: raw}
 }
+
+func TestCronPicksUpExternallyAddedJob(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cron.db")
+	s, err := NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	defer s.Close()
+	var now atomic.Int64
+	base := time.Date(2026, 2, 21, 10, 0, 0, 0, time.UTC)
+	now.Store(base.UnixNano())
+	s.now = func() time.Time { return time.Unix(0, now.Load()).UTC() }
+	s.tick = 10 * time.Millisecond
+	s.refresh = 20 * time.Millisecond
+	calls := make(chan string, 4)
+	s.Start(context.Background(), func(_, content string) { calls <- content })
+	defer s.Stop()
+
+	other, err := NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("open second scheduler: %v", err)
+	}
+	defer other.Close()
+	if _, err := other.AddJob("* * * * *", "", "external"); err != nil {
+		t.Fatalf("add external job: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for step := 1; ; step++ {
+		now.Store(base.Add(time.Duration(step) * time.Minute).UnixNano())
+		select {
+		case got := <-calls:
+			if got != "external" {
+				t.Fatalf("unexpected prompt: %q", got)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("externally added job never fired")
+		}
+	}
+}
+
+func TestCronReloadsOnItsOwnInterval(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cron.db")
+	s, err := NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	defer s.Close()
+	s.tick = time.Hour
+	s.SetRefreshInterval(10 * time.Millisecond)
+	s.Start(context.Background(), func(string, string) {})
+	defer s.Stop()
+
+	other, err := NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("open second scheduler: %v", err)
+	}
+	defer other.Close()
+	if _, err := other.AddJob("0 9 * * *", "", "external"); err != nil {
+		t.Fatalf("add external job: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if jobs, _ := s.ListJobs(); len(jobs) == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("external job not loaded before the first tick")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCronRefreshSyncsExternalChanges(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cron.db")
+	s, err := NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	defer s.Close()
+	keep, err := s.AddJob("*/5 * * * *", "", "pulse")
+	if err != nil {
+		t.Fatalf("add job: %v", err)
+	}
+	gone, err := s.AddJob("0 * * * *", "", "hourly")
+	if err != nil {
+		t.Fatalf("add job: %v", err)
+	}
+	pinned := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.mu.Lock()
+	job := s.jobs[keep]
+	job.nextRun = pinned
+	s.jobs[keep] = job
+	s.mu.Unlock()
+
+	other, err := NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("open second scheduler: %v", err)
+	}
+	defer other.Close()
+	added, err := other.AddJob("0 0 * * *", "", "daily")
+	if err != nil {
+		t.Fatalf("add external job: %v", err)
+	}
+	if err := other.RemoveJob(gone); err != nil {
+		t.Fatalf("remove job: %v", err)
+	}
+
+	if err := s.refreshJobs(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	jobs, _ := s.ListJobs()
+	got := map[string]time.Time{}
+	for _, j := range jobs {
+		got[j.ID] = j.NextRun
+	}
+	if len(got) != 2 || !got[keep].Equal(pinned) {
+		t.Fatalf("unexpected jobs after refresh: %#v", jobs)
+	}
+	if _, ok := got[added]; !ok {
+		t.Fatalf("external job missing after refresh: %#v", jobs)
+	}
+}
+
+func TestCronOnceJobFiresOnceAndIsRemoved(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cron.db")
+	s, err := NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	defer s.Close()
+	var now atomic.Int64
+	base := time.Date(2026, 2, 21, 10, 0, 0, 0, time.UTC)
+	now.Store(base.UnixNano())
+	s.now = func() time.Time { return time.Unix(0, now.Load()).UTC() }
+	s.tick = 10 * time.Millisecond
+	calls := make(chan string, 4)
+	s.Start(context.Background(), func(_, content string) { calls <- content })
+	defer s.Stop()
+
+	if _, err := s.AddOnceJob(base.Add(90*time.Minute), "remind"); err != nil {
+		t.Fatalf("add once job: %v", err)
+	}
+	select {
+	case got := <-calls:
+		t.Fatalf("one-shot job fired early: %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+	now.Store(base.Add(2 * time.Hour).UnixNano())
+	select {
+	case got := <-calls:
+		if got != "remind" {
+			t.Fatalf("unexpected prompt: %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected one-shot job to fire")
+	}
+	select {
+	case got := <-calls:
+		t.Fatalf("one-shot job fired twice: %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if jobs, _ := s.ListJobs(); len(jobs) != 0 {
+		t.Fatalf("one-shot job still listed: %#v", jobs)
+	}
+	other, err := NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("reopen scheduler: %v", err)
+	}
+	defer other.Close()
+	if jobs, _ := other.ListJobs(); len(jobs) != 0 {
+		t.Fatalf("one-shot job still stored: %#v", jobs)
+	}
+}
+
+func TestCronOverdueOnceJobFiresAfterRestart(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cron.db")
+	s, err := NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	runAt := time.Date(2026, 2, 21, 15, 0, 0, 0, time.UTC)
+	if _, err := s.AddOnceJob(runAt, "missed"); err != nil {
+		t.Fatalf("add once job: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close scheduler: %v", err)
+	}
+
+	s, err = NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("reopen scheduler: %v", err)
+	}
+	defer s.Close()
+	jobs, _ := s.ListJobs()
+	if len(jobs) != 1 || !jobs[0].Once || !jobs[0].NextRun.Equal(runAt) {
+		t.Fatalf("unexpected persisted one-shot job: %#v", jobs)
+	}
+	s.now = func() time.Time { return runAt.Add(24 * time.Hour) }
+	var got []string
+	s.enqueueDue(func(_, content string) { got = append(got, content) })
+	s.enqueueDue(func(_, content string) { got = append(got, content) })
+	if len(got) != 1 || got[0] != "missed" {
+		t.Fatalf("overdue one-shot job fired %v, want once", got)
+	}
+}
+
+func TestCronMigratesTableWithoutRunAt(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cron.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE cron_jobs (id TEXT PRIMARY KEY, expression TEXT NOT NULL, prompt TEXT NOT NULL, created_at DATETIME)`); err != nil {
+		t.Fatalf("create old table: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO cron_jobs (id, expression, prompt) VALUES ('old', '0 * * * *', 'hourly')`); err != nil {
+		t.Fatalf("insert old job: %v", err)
+	}
+	_ = db.Close()
+
+	s, err := NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.AddOnceJob(time.Now().Add(time.Hour), "later"); err != nil {
+		t.Fatalf("add once job: %v", err)
+	}
+	jobs, _ := s.ListJobs()
+	if len(jobs) != 2 {
+		t.Fatalf("expected old and new job, got %#v", jobs)
+	}
+}
+
+func TestCronToolAddsOnceJob(t *testing.T) {
+	s, err := NewScheduler(filepath.Join(t.TempDir(), "cron.db"))
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	defer s.Close()
+	tool := CronTool(s)
+
+	addRaw, _ := json.Marshal(map[string]any{"action": "add", "run_at": "2026-02-22T15:00:00+01:00", "prompt": "call mom"})
+	add, err := tool.Run(context.Background(), rawToolCall(t, addRaw))
+	if err != nil || add.IsError {
+		t.Fatalf("run add: %v %q", err, add.Content)
+	}
+	jobs, _ := s.ListJobs()
+	if len(jobs) != 1 || !jobs[0].Once || !jobs[0].NextRun.Equal(time.Date(2026, 2, 22, 14, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected one-shot job: %#v", jobs)
+	}
+
+	bothRaw, _ := json.Marshal(map[string]any{"action": "add", "run_at": "2026-02-22T15:00:00Z", "expression": "* * * * *", "prompt": "x"})
+	both, _ := tool.Run(context.Background(), rawToolCall(t, bothRaw))
+	if !both.IsError {
+		t.Fatal("expected error when both expression and run_at are given")
+	}
+}
+
+func TestCronJobFollowsTimezoneAcrossSpringForward(t *testing.T) {
+	s, err := NewScheduler(filepath.Join(t.TempDir(), "cron.db"))
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	defer s.Close()
+	now := time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	if err := s.SetDefaultTimezone("America/New_York"); err != nil {
+		t.Fatalf("set default timezone: %v", err)
+	}
+	daily, err := s.AddJob("0 9 * * *", "", "morning")
+	if err != nil {
+		t.Fatalf("add job: %v", err)
+	}
+	early, err := s.AddJob("30 2 * * *", "America/New_York", "night")
+	if err != nil {
+		t.Fatalf("add job: %v", err)
+	}
+	next := func(id string) time.Time {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.jobs[id].nextRun
+	}
+	if got, want := next(daily), time.Date(2026, 3, 7, 14, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("9am EST next run = %s, want %s", got, want)
+	}
+
+	var fired []string
+	inject := func(_, content string) { fired = append(fired, content) }
+	now = time.Date(2026, 3, 7, 14, 0, 0, 0, time.UTC)
+	s.enqueueDue(inject)
+	if len(fired) != 1 || fired[0] != "morning" {
+		t.Fatalf("fired %v, want morning", fired)
+	}
+	if got, want := next(daily), time.Date(2026, 3, 8, 13, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("9am EDT next run = %s, want %s", got, want)
+	}
+
+	now = time.Date(2026, 3, 7, 7, 30, 0, 0, time.UTC)
+	s.mu.Lock()
+	job := s.jobs[early]
+	job.nextRun = now
+	s.jobs[early] = job
+	s.mu.Unlock()
+	s.enqueueDue(inject)
+	if got, want := next(early), time.Date(2026, 3, 9, 6, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("2:30am skipped by spring-forward: next run = %s, want %s", got, want)
+	}
+}
+
+func TestCronAddJobRejectsUnknownTimezone(t *testing.T) {
+	s, err := NewScheduler(filepath.Join(t.TempDir(), "cron.db"))
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.AddJob("0 9 * * *", "Mars/Olympus", "x"); err == nil || !strings.Contains(err.Error(), `unknown timezone "Mars/Olympus"`) {
+		t.Fatalf("expected unknown timezone error, got %v", err)
+	}
+	if jobs, _ := s.ListJobs(); len(jobs) != 0 {
+		t.Fatalf("job added despite bad timezone: %#v", jobs)
+	}
+}
+
+func TestCronTimezonePersists(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cron.db")
+	s, err := NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	if _, err := s.AddJob("0 9 * * *", "Europe/Madrid", "buenos dias"); err != nil {
+		t.Fatalf("add job: %v", err)
+	}
+	_ = s.Close()
+
+	s, err = NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("reopen scheduler: %v", err)
+	}
+	defer s.Close()
+	jobs, _ := s.ListJobs()
+	if len(jobs) != 1 || jobs[0].Timezone != "Europe/Madrid" {
+		t.Fatalf("timezone not persisted: %#v", jobs)
+	}
+	madrid, _ := time.LoadLocation("Europe/Madrid")
+	if local := jobs[0].NextRun.In(madrid); local.Hour() != 9 || local.Minute() != 0 {
+		t.Fatalf("next run is not 9am in Madrid: %s", local)
+	}
+}
+
+func TestCronPausedJobDoesNotFire(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cron.db")
+	s, err := NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	defer s.Close()
+	base := time.Date(2026, 2, 21, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return base }
+	id, err := s.AddJob("0 * * * *", "", "noisy")
+	if err != nil {
+		t.Fatalf("add job: %v", err)
+	}
+	if err := s.PauseJob(id); err != nil {
+		t.Fatalf("pause job: %v", err)
+	}
+
+	var fired []string
+	inject := func(_, content string) { fired = append(fired, content) }
+	s.now = func() time.Time { return base.Add(3 * time.Hour) }
+	s.enqueueDue(inject)
+	if len(fired) != 0 {
+		t.Fatalf("paused job fired: %q", fired)
+	}
+	if jobs, _ := s.ListJobs(); len(jobs) != 1 || jobs[0].Enabled {
+		t.Fatalf("list does not report the job paused: %#v", jobs)
+	}
+
+	other, err := NewScheduler(dbPath)
+	if err != nil {
+		t.Fatalf("open second scheduler: %v", err)
+	}
+	defer other.Close()
+	if jobs, _ := other.ListJobs(); len(jobs) != 1 || jobs[0].Enabled {
+		t.Fatalf("pause not persisted: %#v", jobs)
+	}
+	if err := other.ResumeJob(id); err != nil {
+		t.Fatalf("resume job: %v", err)
+	}
+	if err := s.refreshJobs(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	s.enqueueDue(inject)
+	if len(fired) != 0 {
+		t.Fatalf("resumed job replayed missed runs: %q", fired)
+	}
+	jobs, _ := s.ListJobs()
+	if len(jobs) != 1 || !jobs[0].Enabled || !jobs[0].NextRun.Equal(base.Add(4*time.Hour)) {
+		t.Fatalf("unexpected job after resume: %#v", jobs)
+	}
+	s.now = func() time.Time { return base.Add(4 * time.Hour) }
+	s.enqueueDue(inject)
+	if len(fired) != 1 {
+		t.Fatalf("resumed job did not fire: %q", fired)
+	}
+}
+
+func TestCronToolPausesAndResumes(t *testing.T) {
+	s, err := NewScheduler(filepath.Join(t.TempDir(), "cron.db"))
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	defer s.Close()
+	id, err := s.AddJob("*/5 * * * *", "", "pulse")
+	if err != nil {
+		t.Fatalf("add job: %v", err)
+	}
+	tool := CronTool(s)
+	run := func(params map[string]any) ToolResult {
+		raw, _ := json.Marshal(params)
+		res, err := tool.Run(context.Background(), rawToolCall(t, raw))
+		if err != nil {
+			t.Fatalf("run %v: %v", params, err)
+		}
+		return res
+	}
+
+	if got := run(map[string]any{"action": "pause", "id": id}); got.IsError || got.Content != "paused cron job "+id {
+		t.Fatalf("unexpected pause result: %#v", got)
+	}
+	if got := run(map[string]any{"action": "list"}); !strings.Contains(got.Content, `"enabled":false`) {
+		t.Fatalf("list does not show the job paused: %q", got.Content)
+	}
+	if got := run(map[string]any{"action": "resume", "id": id}); got.IsError || got.Content != "resumed cron job "+id {
+		t.Fatalf("unexpected resume result: %#v", got)
+	}
+	if got := run(map[string]any{"action": "pause", "id": "missing"}); !got.IsError || !strings.Contains(got.Content, "not found") {
+		t.Fatalf("expected not found error, got %#v", got)
+	}
+	if got := run(map[string]any{"action": "resume"}); !got.IsError || got.Content != "id is required" {
+		t.Fatalf("expected id error, got %#v", got)
+	}
+}
+
+func TestCronHeartbeatFiresWithItsOwnSource(t *testing.T) {
+	s, err := NewScheduler(filepath.Join(t.TempDir(), "cron.db"))
+	if err != nil {
+		t.Fatalf("new scheduler: %v", err)
+	}
+	defer s.Close()
+	base := time.Date(2026, 2, 21, 10, 5, 0, 0, time.UTC)
+	s.now = func() time.Time { return base }
+	if err := s.SetHeartbeat("*/30 * * * *", "heartbeat check"); err != nil {
+		t.Fatalf("set heartbeat: %v", err)
+	}
+	if err := s.SetHeartbeat("bad", "x"); err == nil {
+		t.Fatal("expected an invalid expression to be rejected")
+	}
+	if jobs, _ := s.ListJobs(); len(jobs) != 0 {
+		t.Fatalf("heartbeat listed as a cron job: %#v", jobs)
+	}
+
+	type fired struct{ source, content string }
+	var got []fired
+	inject := func(source, content string) { got = append(got, fired{source, content}) }
+	s.enqueueDue(inject)
+	s.now = func() time.Time { return base.Add(25 * time.Minute) }
+	s.enqueueDue(inject)
+	s.enqueueDue(inject)
+	if want := []fired{{HeartbeatSource, "heartbeat check"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fired %v, want %v", got, want)
+	}
+
+	if err := s.SetHeartbeat("", ""); err != nil {
+		t.Fatalf("clear heartbeat: %v", err)
+	}
+	s.now = func() time.Time { return base.Add(time.Hour) }
+	s.enqueueDue(inject)
+	if len(got) != 1 {
+		t.Fatalf("cleared heartbeat fired: %v", got)
+	}
+}
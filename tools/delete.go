@@ -0,0 +1,188 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+type deleteParams struct {
+	Path      string
+	Recursive bool
+	Force     bool
+	DryRun    bool
+}
+
+// deleteTool removes files and directories inside workspace. A recursive
+// delete of more than maxFiles entries is refused unless forced; 0 means no
+// limit. With autoSnapshot, a snapshot of the workspace is taken before any
+// directory is deleted.
+func deleteTool(workspace string, maxFiles int, snaps *snapshots, autoSnapshot bool) Tool {
+	params := JSONSchema{
+		Type: "object",
+		Properties: map[string]JSONSchema{
+			"path": {
+				Type: "string",
+				Desc: "File or directory to delete, relative to the workspace or absolute inside it",
+			},
+			"recursive": {
+				Type: "boolean",
+				Desc: "Delete a directory and everything under it (default: false)",
+			},
+			"force": {
+				Type: "boolean",
+				Desc: "Delete a directory even if it holds more entries than the per-operation limit (default: false)",
+			},
+			"dry_run": {
+				Type: "boolean",
+				Desc: "Report what would be deleted, and how many entries, without deleting anything (default: false)",
+			},
+		},
+		Required: []string{"path"},
+	}
+
+	return tool{
+		name:   "delete",
+		serial: true,
+		desc:   "Delete a file or directory inside the workspace",
+		params: params,
+		runFn: func(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
+			res, err := runDelete(workspace, maxFiles, call, func() (string, error) {
+				if !autoSnapshot {
+					return "", nil
+				}
+				id, _, _, err := snaps.take()
+				return fmt.Sprintf(" (snapshot %s taken first)", id), err
+			})
+			return res, err
+		},
+	}
+}
+
+// runDelete deletes the target of call. For a directory, snapshot runs just
+// before the delete and its note is appended to the result.
+func runDelete(workspace string, maxFiles int, call model.ToolCallPart, snapshot func() (string, error)) (ToolResult, error) {
+
+	args, err := parseDeleteParams(call.Parameters)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	target, err := resolveWorkspacePath(workspace, args.Path)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	info, err := os.Lstat(target)
+	if err != nil {
+		return ToolResult{}, fmt.Errorf("path %q: %v", args.Path, err)
+	}
+	if !info.IsDir() {
+		if args.DryRun {
+			return ToolResult{Content: fmt.Sprintf("would delete file %s", target)}, nil
+		}
+		if err := os.Remove(target); err != nil {
+			return ToolResult{}, fmt.Errorf("delete %q: %v", target, err)
+		}
+		return ToolResult{Content: fmt.Sprintf("deleted file %s", target)}, nil
+	}
+	if !args.Recursive {
+		return ToolResult{}, fmt.Errorf("%q is a directory (set recursive to delete it)", target)
+	}
+	n, err := countEntries(target)
+	if err != nil {
+		return ToolResult{}, fmt.Errorf("scan %q: %v", target, err)
+	}
+	if args.DryRun {
+		return ToolResult{Content: fmt.Sprintf("would delete directory %s (%d entries)", target, n)}, nil
+	}
+	if maxFiles > 0 && n > maxFiles && !args.Force {
+		return ToolResult{}, fmt.Errorf("deleting %q would remove %d entries, over the limit of %d per operation (set force to delete anyway)", target, n, maxFiles)
+	}
+	note, err := snapshot()
+	if err != nil {
+		return ToolResult{}, fmt.Errorf("snapshot before deleting %q: %v", target, err)
+	}
+	if err := os.RemoveAll(target); err != nil {
+		return ToolResult{}, fmt.Errorf("delete %q: %v", target, err)
+	}
+
+	return ToolResult{Content: fmt.Sprintf("deleted directory %s (%d entries)%s", target, n, note)}, nil
+}
+
+func parseDeleteParams(raw json.RawMessage) (deleteParams, error) {
+
+	var input struct {
+		Path      *string `json:"path"`
+		Recursive bool    `json:"recursive"`
+		Force     bool    `json:"force"`
+		DryRun    bool    `json:"dry_run"`
+	}
+	if err := json.Unmarshal(raw, &input); err != nil {
+		return deleteParams{}, fmt.Errorf("parse delete parameters: %v", err)
+	}
+	if input.Path == nil || *input.Path == "" {
+		return deleteParams{}, errors.New("delete parameter path is required")
+	}
+
+	return deleteParams{Path: *input.Path, Recursive: input.Recursive, Force: input.Force, DryRun: input.DryRun}, nil
+}
+
+// resolveWorkspacePath maps path onto workspace and rejects anything that
+// is the workspace itself or escapes it, including through symlinked parents.
+func resolveWorkspacePath(workspace, path string) (string, error) {
+
+	if workspace == "" {
+		return "", errors.New("workspace is not configured")
+	}
+	root, err := filepath.Abs(workspace)
+	if err != nil {
+		return "", err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return "", fmt.Errorf("resolve workspace %q: %v", workspace, err)
+	}
+	target := path
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(workspace, target)
+	}
+	target, err = filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return "", fmt.Errorf("path %q: %v", path, err)
+	}
+	target = filepath.Join(parent, filepath.Base(target))
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside the workspace", path)
+	}
+	if rel == "." {
+		return "", errors.New("refusing to delete the workspace root")
+	}
+
+	return target, nil
+}
+
+func countEntries(dir string) (int, error) {
+
+	n := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir {
+			n++
+		}
+		return nil
+	})
+
+	return n, err
+}
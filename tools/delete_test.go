@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+func TestDeleteRemovesSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	got, err := runDeleteCall(t, dir, deleteArgs{Path: "a.txt"})
+	if err != nil {
+		t.Fatalf("run delete: %v", err)
+	}
+	if !strings.Contains(got.Content, "deleted file") || !strings.Contains(got.Content, "a.txt") {
+		t.Fatalf("unexpected result: %q", got.Content)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("file still exists: %v", err)
+	}
+}
+
+func TestDeleteRefusesDirectoryWithoutRecursive(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	if err := os.MkdirAll(filepath.Join(sub, "nested"), 0o755); err != nil {
+		t.Fatalf("seed dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, "nested", "f.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	_, err := runDeleteCall(t, dir, deleteArgs{Path: "sub"})
+	if err == nil || !strings.Contains(err.Error(), "recursive") {
+		t.Fatalf("want recursive error, got %v", err)
+	}
+	if _, err := os.Stat(sub); err != nil {
+		t.Fatalf("dir removed: %v", err)
+	}
+	got, err := runDeleteCall(t, dir, deleteArgs{Path: sub, Recursive: true})
+	if err != nil {
+		t.Fatalf("run recursive delete: %v", err)
+	}
+	if !strings.Contains(got.Content, "(2 entries)") {
+		t.Fatalf("unexpected result: %q", got.Content)
+	}
+	if _, err := os.Stat(sub); !os.IsNotExist(err) {
+		t.Fatalf("dir still exists: %v", err)
+	}
+}
+
+func TestDeleteRefusesPathTraversal(t *testing.T) {
+	root := t.TempDir()
+	workspace := filepath.Join(root, "ws", "inner")
+	if err := os.MkdirAll(workspace, 0o755); err != nil {
+		t.Fatalf("seed workspace: %v", err)
+	}
+	outside := filepath.Join(root, "etc")
+	if err := os.MkdirAll(outside, 0o755); err != nil {
+		t.Fatalf("seed outside: %v", err)
+	}
+	for _, p := range []string{"../../etc", outside} {
+		_, err := runDeleteCall(t, workspace, deleteArgs{Path: p, Recursive: true})
+		if err == nil || !strings.Contains(err.Error(), "outside the workspace") {
+			t.Fatalf("path %q: want outside error, got %v", p, err)
+		}
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Fatalf("outside dir removed: %v", err)
+	}
+}
+
+func TestDeleteRefusesWorkspaceRoot(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{".", dir, "sub/.."} {
+		_, err := runDeleteCall(t, dir, deleteArgs{Path: p, Recursive: true})
+		if err == nil || !strings.Contains(err.Error(), "workspace root") {
+			t.Fatalf("path %q: want root error, got %v", p, err)
+		}
+	}
+}
+
+func TestDeleteRefusesSymlinkedParentEscape(t *testing.T) {
+	root := t.TempDir()
+	workspace := filepath.Join(root, "ws")
+	outside := filepath.Join(root, "outside")
+	if err := os.MkdirAll(workspace, 0o755); err != nil {
+		t.Fatalf("seed workspace: %v", err)
+	}
+	if err := os.MkdirAll(outside, 0o755); err != nil {
+		t.Fatalf("seed outside: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "f.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(workspace, "link")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	_, err := runDeleteCall(t, workspace, deleteArgs{Path: "link/f.txt"})
+	if err == nil || !strings.Contains(err.Error(), "outside the workspace") {
+		t.Fatalf("want outside error, got %v", err)
+	}
+}
+
+func TestDeleteGuardsBulkDirectoryDelete(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "logs")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatalf("seed dir: %v", err)
+	}
+	for _, name := range []string{"a.log", "b.log", "c.log", "d.log"} {
+		if err := os.WriteFile(filepath.Join(sub, name), []byte("x"), 0o644); err != nil {
+			t.Fatalf("seed file: %v", err)
+		}
+	}
+	_, err := runLimitedDeleteCall(t, dir, 3, deleteArgs{Path: "logs", Recursive: true})
+	if err == nil || !strings.Contains(err.Error(), "would remove 4 entries, over the limit of 3") {
+		t.Fatalf("want limit error, got %v", err)
+	}
+	got, err := runLimitedDeleteCall(t, dir, 3, deleteArgs{Path: "logs", Recursive: true, DryRun: true})
+	if err != nil || !strings.Contains(got.Content, "would delete directory") || !strings.Contains(got.Content, "(4 entries)") {
+		t.Fatalf("unexpected dry run: %q %v", got.Content, err)
+	}
+	if _, err := os.Stat(filepath.Join(sub, "a.log")); err != nil {
+		t.Fatalf("guarded delete removed files: %v", err)
+	}
+	if _, err := runLimitedDeleteCall(t, dir, 3, deleteArgs{Path: "logs", Recursive: true, Force: true}); err != nil {
+		t.Fatalf("forced delete: %v", err)
+	}
+	if _, err := os.Stat(sub); !os.IsNotExist(err) {
+		t.Fatalf("dir still exists: %v", err)
+	}
+}
+
+func TestDeleteDryRunKeepsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	got, err := runDeleteCall(t, dir, deleteArgs{Path: "a.txt", DryRun: true})
+	if err != nil || !strings.Contains(got.Content, "would delete file") {
+		t.Fatalf("unexpected dry run: %q %v", got.Content, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("dry run removed file: %v", err)
+	}
+}
+
+type deleteArgs struct {
+	Path      string `json:"path"`
+	Recursive bool   `json:"recursive,omitempty"`
+	Force     bool   `json:"force,omitempty"`
+	DryRun    bool   `json:"dry_run,omitempty"`
+}
+
+func runDeleteCall(t *testing.T, workspace string, args deleteArgs) (ToolResult, error) {
+	t.Helper()
+	return runLimitedDeleteCall(t, workspace, 0, args)
+}
+
+func runLimitedDeleteCall(t *testing.T, workspace string, maxFiles int, args deleteArgs) (ToolResult, error) {
+	t.Helper()
+	b, err := json.Marshal(args)
+	if err != nil {
+		t.Fatalf("marshal args: %v", err)
+	}
+	return deleteTool(workspace, maxFiles, nil, false).Run(context.Background(), model.ToolCallPart{Name: "delete", Parameters: b})
+}
@@ -44,6 +44,7 @@ func editTool() Tool {
 
 	return tool{
 		name:   "edit",
+		serial: true,
 		desc:   "Replace text in an existing file",
 		params: params,
 		runFn:  runEdit,
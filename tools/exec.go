@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
@@ -23,9 +25,25 @@ const (
 	execKillGraceTimeout = 5 * time.Second
 )
 
+// HostExecForwardEnv lists, comma-separated, the variables exec set for a
+// command. Inside the sandbox the host-exec client forwards them to the
+// host command it runs, which accepts those in sandbox.host_command_env.
+const HostExecForwardEnv = "MICLAW_HOST_EXECUTOR_ENV"
+
 var execProcessManager = NewProcManager()
 
-type execRunner struct{}
+// execOutputInterval is how often a running foreground command's new output
+// is passed to the exec output callback.
+var execOutputInterval = time.Second
+
+type execRunner struct {
+	// secrets is the path of the env_from secrets file; empty disables
+	// env_from.
+	secrets string
+	// onOutput, when set, receives foreground output as it is produced,
+	// keyed by the tool call ID.
+	onOutput func(callID, chunk string)
+}
 
 type execParams struct {
 	Command    string
@@ -33,17 +51,22 @@ type execParams struct {
 	WorkingDir string
 	Input      string
 	Background bool
+	Env        map[string]string
+	EnvFrom    []string
 }
 
+// execTool runs in sandbox bridge children, which cannot see the secrets
+// file; the host resolves env_from before the call reaches them.
 func execTool() Tool {
-	return execToolWithSandbox(config.SandboxConfig{})
+	return execToolWithSandbox(config.SandboxConfig{}, "", nil)
 }
 
-func execToolWithSandbox(_ config.SandboxConfig) Tool {
-	runner := execRunner{}
+func execToolWithSandbox(_ config.SandboxConfig, secrets string, onOutput func(callID, chunk string)) Tool {
+	runner := execRunner{secrets: secrets, onOutput: onOutput}
 	return tool{
-		name: "exec",
-		desc: "Execute a shell command and return combined stdout/stderr output",
+		name:   "exec",
+		serial: true,
+		desc:   "Execute a shell command and return combined stdout/stderr output",
 		params: JSONSchema{
 			Type: "object",
 			Properties: map[string]JSONSchema{
@@ -67,6 +90,15 @@ func execToolWithSandbox(_ config.SandboxConfig) Tool {
 					Type: "boolean",
 					Desc: "Run in background and return process ID",
 				},
+				"env": {
+					Type: "object",
+					Desc: "Environment variables to set for the command, as name/value pairs",
+				},
+				"env_from": {
+					Type:  "array",
+					Desc:  "Names of secrets to set as environment variables; values are redacted from output",
+					Items: &JSONSchema{Type: "string"},
+				},
 			},
 			Required: []string{"command"},
 		},
@@ -80,23 +112,40 @@ func (r execRunner) run(ctx context.Context, call model.ToolCallPart) (ToolResul
 		return ToolResult{Content: err.Error(), IsError: true}, nil
 	}
 	if params.Background {
+		if len(params.EnvFrom) > 0 {
+			return ToolResult{Content: "exec env_from is not supported in background mode", IsError: true}, nil
+		}
 		return runExecBackground(params), nil
 	}
-	return runExecLocal(ctx, params), nil
+	secrets, err := secretEnv(r.secrets, params.EnvFrom)
+	if err != nil {
+		return ToolResult{Content: err.Error(), IsError: true}, nil
+	}
+	// A secret split across two chunks could slip past redaction, so
+	// commands run with env_from are not streamed.
+	var progress func(string)
+	if r.onOutput != nil && len(secrets) == 0 {
+		progress = func(chunk string) { r.onOutput(call.ID, chunk) }
+	}
+	result := runExecLocal(ctx, params, secrets, progress)
+	result.Content = RedactSecrets(result.Content, secrets)
+	return result, nil
 }
 
 func runExecBackground(params execParams) ToolResult {
 	cmd := localExecCommand(params)
+	cmd.Env = commandEnv(params.Env)
 	pid := execProcessManager.Start(cmd)
 	return ToolResult{Content: fmt.Sprintf("started background process %d", pid)}
 }
 
-func runExecLocal(ctx context.Context, params execParams) ToolResult {
+func runExecLocal(ctx context.Context, params execParams, secrets map[string]string, progress func(string)) ToolResult {
 	cmd := localExecCommand(params)
+	cmd.Env = commandEnv(params.Env, secrets)
 	if params.Input != "" {
 		cmd.Stdin = strings.NewReader(params.Input)
 	}
-	exitCode, output, status := runForegroundCommand(ctx, cmd, params.Timeout)
+	exitCode, output, status := runForegroundCommand(ctx, cmd, params.Timeout, progress)
 	return asExecResult(exitCode, status, output)
 }
 
@@ -109,6 +158,31 @@ func localExecCommand(params execParams) *exec.Cmd {
 	return cmd
 }
 
+// commandEnv returns the process environment with vars added, later maps
+// winning, or nil to inherit it unchanged when vars are empty. The names
+// are also listed in HostExecForwardEnv.
+func commandEnv(vars ...map[string]string) []string {
+	var names []string
+	merged := map[string]string{}
+	for _, m := range vars {
+		for name, value := range m {
+			if _, ok := merged[name]; !ok {
+				names = append(names, name)
+			}
+			merged[name] = value
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	slices.Sort(names)
+	env := os.Environ()
+	for _, name := range names {
+		env = append(env, name+"="+merged[name])
+	}
+	return append(env, HostExecForwardEnv+"="+strings.Join(names, ","))
+}
+
 func asExecResult(exitCode int, status, output string) ToolResult {
 	content := formatExecResult(exitCode, status, output)
 	if strings.HasPrefix(status, "failed to start command") {
@@ -119,11 +193,13 @@ func asExecResult(exitCode int, status, output string) ToolResult {
 
 func parseExecParams(raw json.RawMessage) (execParams, error) {
 	var input struct {
-		Command    *string `json:"command"`
-		Timeout    *int    `json:"timeout"`
-		WorkingDir *string `json:"working_dir"`
-		Input      *string `json:"input"`
-		Background *bool   `json:"background"`
+		Command    *string           `json:"command"`
+		Timeout    *int              `json:"timeout"`
+		WorkingDir *string           `json:"working_dir"`
+		Input      *string           `json:"input"`
+		Background *bool             `json:"background"`
+		Env        map[string]string `json:"env"`
+		EnvFrom    []string          `json:"env_from"`
 	}
 	if err := json.Unmarshal(raw, &input); err != nil {
 		return execParams{}, fmt.Errorf("parse exec parameters: %v", err)
@@ -151,11 +227,22 @@ func parseExecParams(raw json.RawMessage) (execParams, error) {
 	if input.Background != nil {
 		params.Background = *input.Background
 	}
+	for name := range input.Env {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return execParams{}, fmt.Errorf("exec env name %q is invalid", name)
+		}
+	}
+	params.Env = input.Env
+	params.EnvFrom = input.EnvFrom
 
 	return params, nil
 }
 
-func runForegroundCommand(ctx context.Context, cmd *exec.Cmd, timeout int) (int, string, string) {
+// runForegroundCommand runs cmd until it exits, ctx is cancelled or the
+// timeout passes. When progress is set, output is passed to it every
+// execOutputInterval, and what is left once the command stops is passed
+// before returning.
+func runForegroundCommand(ctx context.Context, cmd *exec.Cmd, timeout int, progress func(string)) (int, string, string) {
 	output := &bytes.Buffer{}
 	outputMu := sync.Mutex{}
 	cmd.Stdout = &outputWriter{buf: output, mu: &outputMu}
@@ -174,15 +261,41 @@ func runForegroundCommand(ctx context.Context, cmd *exec.Cmd, timeout int) (int,
 
 	timer := time.NewTimer(time.Duration(timeout) * time.Second)
 	defer timer.Stop()
-	select {
-	case <-ctx.Done():
-		exitCode := terminateCommand(cmd, done)
-		return exitCode, truncateExecOutput(safeOutput(output, &outputMu)), "canceled"
-	case <-timer.C:
-		exitCode := terminateCommand(cmd, done)
-		return exitCode, truncateExecOutput(safeOutput(output, &outputMu)), "timeout"
-	case exitCode := <-done:
-		return exitCode, truncateExecOutput(safeOutput(output, &outputMu)), ""
+	var tick <-chan time.Time
+	sent := 0
+	flush := func() {
+		if progress == nil {
+			return
+		}
+		outputMu.Lock()
+		chunk := string(output.Bytes()[sent:])
+		sent = output.Len()
+		outputMu.Unlock()
+		if chunk != "" {
+			progress(truncateExecOutput(chunk))
+		}
+	}
+	if progress != nil {
+		ticker := time.NewTicker(execOutputInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-tick:
+			flush()
+		case <-ctx.Done():
+			exitCode := terminateCommand(cmd, done)
+			flush()
+			return exitCode, truncateExecOutput(safeOutput(output, &outputMu)), "canceled"
+		case <-timer.C:
+			exitCode := terminateCommand(cmd, done)
+			flush()
+			return exitCode, truncateExecOutput(safeOutput(output, &outputMu)), "timeout"
+		case exitCode := <-done:
+			flush()
+			return exitCode, truncateExecOutput(safeOutput(output, &outputMu)), ""
+		}
 	}
 }
 
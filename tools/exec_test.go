@@ -120,6 +120,33 @@ func TestExecInput(t *testing.T) {
 	}
 }
 
+func TestExecEnv(t *testing.T) {
+	got, err := runExecCall(t, context.Background(), map[string]any{
+		"command": `echo "token=$DEPLOY_TOKEN"; echo "$MICLAW_HOST_EXECUTOR_ENV"; cat`,
+		"env":     map[string]string{"DEPLOY_TOKEN": "abc 123"},
+		"input":   "from stdin\n",
+	})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	if got.IsError {
+		t.Fatalf("unexpected tool error: %s", got.Content)
+	}
+	if out := execResultOutput(got.Content); out != "token=abc 123\nDEPLOY_TOKEN\nfrom stdin" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	got, err = runExecCall(t, context.Background(), map[string]any{
+		"command": "true",
+		"env":     map[string]string{"A=B": "x"},
+	})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	if !got.IsError || !strings.Contains(got.Content, "exec env name") {
+		t.Fatalf("expected invalid env name error, got %q", got.Content)
+	}
+}
+
 func TestExecBackground(t *testing.T) {
 	got, err := runExecCall(t, context.Background(), map[string]any{
 		"command":    "echo bg-output; sleep 0.5; echo bg-done",
@@ -189,6 +216,68 @@ func TestExecContextCancellation(t *testing.T) {
 	}
 }
 
+func TestExecStreamsOutputBeforeCompletion(t *testing.T) {
+	old := execOutputInterval
+	execOutputInterval = 20 * time.Millisecond
+	t.Cleanup(func() { execOutputInterval = old })
+	chunks := make(chan string, 16)
+	tool := execToolWithSandbox(config.SandboxConfig{}, t.TempDir(), func(callID, chunk string) {
+		if callID != "call-1" {
+			t.Errorf("chunk for call %q, want call-1", callID)
+		}
+		chunks <- chunk
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan ToolResult, 1)
+	go func() {
+		got, _ := tool.Run(ctx, model.ToolCallPart{ID: "call-1", Name: "exec", Parameters: json.RawMessage(`{"command":"echo first; sleep 1; echo second; sleep 10"}`)})
+		done <- got
+	}()
+
+	select {
+	case chunk := <-chunks:
+		if chunk != "first\n" {
+			t.Fatalf("first chunk = %q", chunk)
+		}
+	case <-done:
+		t.Fatal("command finished before any output was streamed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no output streamed")
+	}
+	select {
+	case chunk := <-chunks:
+		if chunk != "second\n" {
+			t.Fatalf("second chunk = %q", chunk)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second chunk not streamed")
+	}
+	cancel()
+	select {
+	case got := <-done:
+		if out := execResultOutput(got.Content); out != "first\nsecond" {
+			t.Fatalf("final output = %q", out)
+		}
+		if !strings.Contains(got.Content, "canceled") {
+			t.Fatalf("expected cancellation status: %q", got.Content)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("cancellation did not stop a streaming command")
+	}
+}
+
+func TestExecBackgroundIgnoresOutputCallback(t *testing.T) {
+	tool := execToolWithSandbox(config.SandboxConfig{}, t.TempDir(), func(string, string) {
+		t.Error("background exec streamed output")
+	})
+	got, err := tool.Run(context.Background(), model.ToolCallPart{ID: "1", Name: "exec", Parameters: json.RawMessage(`{"command":"echo hi","background":true}`)})
+	if err != nil || got.IsError {
+		t.Fatalf("background exec: %v %q", err, got.Content)
+	}
+	time.Sleep(100 * time.Millisecond)
+}
+
 func TestExecSandboxAllowlistedCommandsRunLocally(t *testing.T) {
 	raw, err := json.Marshal(map[string]any{"command": "git status"})
 	if err != nil {
@@ -197,7 +286,7 @@ func TestExecSandboxAllowlistedCommandsRunLocally(t *testing.T) {
 	got, err := execToolWithSandbox(config.SandboxConfig{
 		Enabled:  true,
 		HostUser: "runner",
-	}).Run(context.Background(), model.ToolCallPart{
+	}, t.TempDir(), nil).Run(context.Background(), model.ToolCallPart{
 		ID:         "1",
 		Name:       "exec",
 		Parameters: raw,
@@ -0,0 +1,151 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+const (
+	fetchDefaultTimeout = 30
+	fetchMaxTimeout     = 300
+	// fetchMaxBodyBytes caps how much of a response is read; the result is
+	// then truncated like exec output.
+	fetchMaxBodyBytes = 5 << 20
+)
+
+type fetchParams struct {
+	URL     string
+	Method  string
+	Headers http.Header
+	Body    string
+	Timeout int
+}
+
+func fetchTool() Tool {
+	return tool{
+		name: "fetch",
+		desc: "Fetch a URL over HTTP(S) and return status, headers, and body",
+		params: JSONSchema{
+			Type:     "object",
+			Required: []string{"url"},
+			Properties: map[string]JSONSchema{
+				"url": {
+					Type: "string",
+					Desc: "http:// or https:// URL",
+				},
+				"method": {
+					Type: "string",
+					Desc: "HTTP method (default: GET)",
+					Enum: []string{"GET", "POST"},
+				},
+				"headers": {
+					Type:  "array",
+					Desc:  "Request headers as \"Name: value\" strings",
+					Items: &JSONSchema{Type: "string"},
+				},
+				"body": {
+					Type: "string",
+					Desc: "Request body for POST",
+				},
+				"timeout": {
+					Type: "integer",
+					Desc: "Timeout in seconds (default: 30, max: 300)",
+				},
+			},
+		},
+		runFn: runFetch,
+	}
+}
+
+func runFetch(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
+	params, err := parseFetchParams(call)
+	if err != nil {
+		return ToolResult{IsError: true, Content: err.Error()}, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(params.Timeout)*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, params.Method, params.URL, strings.NewReader(params.Body))
+	if err != nil {
+		return ToolResult{IsError: true, Content: fmt.Sprintf("build request: %v", err)}, nil
+	}
+	req.Header = params.Headers
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ToolResult{IsError: true, Content: fmt.Sprintf("fetch %s: %v", params.URL, err)}, nil
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, fetchMaxBodyBytes))
+	if err != nil {
+		return ToolResult{IsError: true, Content: fmt.Sprintf("read body: %v", err)}, nil
+	}
+	return ToolResult{Content: formatFetchResult(resp, body)}, nil
+}
+
+func parseFetchParams(call model.ToolCallPart) (fetchParams, error) {
+	var input struct {
+		URL     *string  `json:"url"`
+		Method  *string  `json:"method"`
+		Headers []string `json:"headers"`
+		Body    *string  `json:"body"`
+		Timeout *int     `json:"timeout"`
+	}
+	if err := unmarshalObject(call.Parameters, &input); err != nil {
+		return fetchParams{}, fmt.Errorf("parse fetch parameters: %v", err)
+	}
+	if input.URL == nil || strings.TrimSpace(*input.URL) == "" {
+		return fetchParams{}, errors.New("url is required")
+	}
+	u, err := url.Parse(strings.TrimSpace(*input.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fetchParams{}, fmt.Errorf("url must be an absolute http or https URL, got %q", *input.URL)
+	}
+	params := fetchParams{URL: u.String(), Method: http.MethodGet, Headers: http.Header{}, Timeout: fetchDefaultTimeout}
+	if input.Method != nil && *input.Method != "" {
+		params.Method = strings.ToUpper(*input.Method)
+	}
+	if params.Method != http.MethodGet && params.Method != http.MethodPost {
+		return fetchParams{}, fmt.Errorf("method must be GET or POST, got %q", params.Method)
+	}
+	for _, h := range input.Headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fetchParams{}, fmt.Errorf("header %q must be \"Name: value\"", h)
+		}
+		params.Headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	if input.Body != nil {
+		params.Body = *input.Body
+	}
+	if input.Timeout != nil {
+		params.Timeout = *input.Timeout
+	}
+	if params.Timeout <= 0 || params.Timeout > fetchMaxTimeout {
+		return fetchParams{}, fmt.Errorf("fetch timeout must be between 1 and %d", fetchMaxTimeout)
+	}
+	return params, nil
+}
+
+func formatFetchResult(resp *http.Response, body []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "status: %s\n", resp.Status)
+	names := make([]string, 0, len(resp.Header))
+	for name := range resp.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", name, strings.Join(resp.Header[name], ", "))
+	}
+	b.WriteString("\n")
+	b.Write(body)
+	return truncateExecOutput(b.String())
+}
@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+func runFetchCall(t *testing.T, ctx context.Context, params map[string]any) ToolResult {
+	t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("marshal params: %v", err)
+	}
+	got, err := fetchTool().Run(ctx, model.ToolCallPart{Name: "fetch", Parameters: raw})
+	if err != nil {
+		t.Fatalf("run fetch: %v", err)
+	}
+	return got
+}
+
+func TestFetchGetReturnsStatusHeadersAndBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = io.WriteString(w, `{"ok":true}`)
+	}))
+	defer srv.Close()
+
+	got := runFetchCall(t, context.Background(), map[string]any{"url": srv.URL})
+	if got.IsError {
+		t.Fatalf("unexpected error: %s", got.Content)
+	}
+	for _, want := range []string{"status: 418 I'm a teapot", "Content-Type: application/json", `{"ok":true}`} {
+		if !strings.Contains(got.Content, want) {
+			t.Fatalf("result missing %q:\n%s", want, got.Content)
+		}
+	}
+}
+
+func TestFetchPostSendsHeadersAndBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		_, _ = io.WriteString(w, r.Method+" "+r.Header.Get("X-Api-Key")+" "+string(b))
+	}))
+	defer srv.Close()
+
+	got := runFetchCall(t, context.Background(), map[string]any{
+		"url":     srv.URL,
+		"method":  "post",
+		"headers": []string{"X-Api-Key: k1"},
+		"body":    "payload",
+	})
+	if got.IsError || !strings.HasSuffix(got.Content, "POST k1 payload") {
+		t.Fatalf("unexpected result: %q", got.Content)
+	}
+}
+
+func TestFetchRejectsNonHTTPSchemes(t *testing.T) {
+	for _, u := range []string{"file:///etc/passwd", "ftp://example.com/x", "example.com"} {
+		got := runFetchCall(t, context.Background(), map[string]any{"url": u})
+		if !got.IsError || !strings.Contains(got.Content, "http or https") {
+			t.Fatalf("%s: want scheme error, got %q", u, got.Content)
+		}
+	}
+}
+
+func TestFetchHonorsContextCancellation(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	got := runFetchCall(t, ctx, map[string]any{"url": srv.URL})
+	if !got.IsError || time.Since(start) > 2*time.Second {
+		t.Fatalf("fetch ignored cancellation: %q after %v", got.Content, time.Since(start))
+	}
+}
+
+func TestFetchTruncatesLargeBodies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, strings.Repeat("x", fetchMaxBodyBytes+1024))
+	}))
+	defer srv.Close()
+
+	got := runFetchCall(t, context.Background(), map[string]any{"url": srv.URL})
+	if got.IsError || len(got.Content) > execMaxOutputChars || !strings.HasSuffix(got.Content, execOutputTruncated) {
+		t.Fatalf("want truncated result, got %d chars", len(got.Content))
+	}
+}
+
+func TestMainAgentToolsRegistersFetchOnlyWhenEnabled(t *testing.T) {
+	has := func(list []Tool) bool {
+		for _, tl := range list {
+			if tl.Name() == "fetch" {
+				return true
+			}
+		}
+		return false
+	}
+	deps := mainDeps()
+	if has(MainAgentTools(deps)) {
+		t.Fatal("fetch registered without deps.Fetch")
+	}
+	deps.Fetch = true
+	if !has(MainAgentTools(deps)) {
+		t.Fatal("fetch missing with deps.Fetch")
+	}
+}
@@ -4,20 +4,36 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/agusx1211/miclaw/model"
 )
 
 var errGlobResultLimit = errors.New("glob result limit reached")
 
+const (
+	globSortName  = "name"
+	globSortMtime = "mtime"
+	globSortSize  = "size"
+)
+
 type globParams struct {
 	Pattern string `json:"pattern"`
 	Path    string `json:"path"`
+	Sort    string `json:"sort"`
+	Limit   int    `json:"limit"`
+}
+
+type globMatch struct {
+	path    string
+	modTime time.Time
+	size    int64
 }
 
 func globTool() Tool {
@@ -30,6 +46,12 @@ func globTool() Tool {
 			Properties: map[string]JSONSchema{
 				"pattern": {Type: "string", Desc: "glob pattern to match"},
 				"path":    {Type: "string", Desc: "directory to search"},
+				"sort": {
+					Type: "string",
+					Desc: "result order: name (default), mtime (newest first), or size (largest first)",
+					Enum: []string{globSortName, globSortMtime, globSortSize},
+				},
+				"limit": {Type: "integer", Desc: "maximum number of paths to return after sorting"},
 			},
 			Required: []string{"pattern"},
 		},
@@ -48,7 +70,26 @@ func runGlob(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
 	if _, err := os.Stat(root); err != nil {
 		return ToolResult{Content: err.Error(), IsError: true}, nil
 	}
-	paths := make([]string, 0)
+	matches, err := collectGlobMatches(root, params.Pattern)
+	if err != nil {
+		return ToolResult{Content: err.Error(), IsError: true}, nil
+	}
+	sortGlobMatches(matches, params.Sort)
+	if params.Limit > 0 && len(matches) > params.Limit {
+		matches = matches[:params.Limit]
+	}
+	paths := make([]string, len(matches))
+	for i, m := range matches {
+		paths[i] = m.path
+	}
+	return ToolResult{Content: strings.Join(paths, "\n"), IsError: false}, nil
+}
+
+// collectGlobMatches walks root and stats every file matching pattern, up to
+// 1000 files.
+func collectGlobMatches(root, pattern string) ([]globMatch, error) {
+
+	matches := make([]globMatch, 0)
 	walkErr := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -60,22 +101,38 @@ func runGlob(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
 		if root != "." {
 			relative = strings.TrimPrefix(relative, filepath.ToSlash(root)+"/")
 		}
-		if matchPathPattern(params.Pattern, relative) {
-			paths = append(paths, relative)
-			if len(paths) >= 1000 {
-				return errGlobResultLimit
-			}
+		if !matchPathPattern(pattern, relative) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		matches = append(matches, globMatch{path: relative, modTime: info.ModTime(), size: info.Size()})
+		if len(matches) >= 1000 {
+			return errGlobResultLimit
 		}
 		return nil
 	})
 	if errors.Is(walkErr, errGlobResultLimit) {
 		walkErr = nil
 	}
-	if walkErr != nil {
-		return ToolResult{Content: walkErr.Error(), IsError: true}, nil
-	}
-	sort.Strings(paths)
-	return ToolResult{Content: strings.Join(paths, "\n"), IsError: false}, nil
+	return matches, walkErr
+}
+
+// sortGlobMatches orders matches by mode; ties fall back to the path.
+func sortGlobMatches(matches []globMatch, mode string) {
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		switch {
+		case mode == globSortMtime && !a.modTime.Equal(b.modTime):
+			return a.modTime.After(b.modTime)
+		case mode == globSortSize && a.size != b.size:
+			return a.size > b.size
+		}
+		return a.path < b.path
+	})
 }
 
 func parseGlobParams(raw json.RawMessage) (globParams, error) {
@@ -88,5 +145,14 @@ func parseGlobParams(raw json.RawMessage) (globParams, error) {
 	if params.Path == "" {
 		params.Path = "."
 	}
+	if params.Sort == "" {
+		params.Sort = globSortName
+	}
+	if params.Sort != globSortName && params.Sort != globSortMtime && params.Sort != globSortSize {
+		return globParams{}, fmt.Errorf("sort must be one of name, mtime, size")
+	}
+	if params.Limit < 0 {
+		return globParams{}, fmt.Errorf("limit must be non-negative")
+	}
 	return params, nil
 }
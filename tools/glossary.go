@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/prompt"
+)
+
+func glossaryAddTool(workspace string, onAdd func(prompt.GlossaryEntry)) Tool {
+	return tool{
+		name:   "glossary_add",
+		serial: true,
+		desc:   "Pin how a term should be rendered; the entry is shown to you whenever new input mentions the term",
+		params: JSONSchema{
+			Type:     "object",
+			Required: []string{"term", "rendering"},
+			Properties: map[string]JSONSchema{
+				"term": {
+					Type: "string",
+					Desc: "Term as it appears in messages (for example: expediente)",
+				},
+				"rendering": {
+					Type: "string",
+					Desc: "Preferred rendering and notes (for example: case file; keep the Spanish word in Spanish replies)",
+				},
+			},
+		},
+		runFn: func(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
+			entry, err := parseGlossaryParams(call)
+			if err != nil {
+				return ToolResult{IsError: true, Content: err.Error()}, nil
+			}
+			if err := prompt.AppendGlossary(workspace, entry); err != nil {
+				return ToolResult{IsError: true, Content: fmt.Sprintf("append glossary: %v", err)}, nil
+			}
+			onAdd(entry)
+			return ToolResult{Content: fmt.Sprintf("added %s → %s to %s", entry.Term, entry.Rendering, prompt.GlossaryFile)}, nil
+		},
+	}
+}
+
+func parseGlossaryParams(call model.ToolCallPart) (prompt.GlossaryEntry, error) {
+	var input struct {
+		Term      *string `json:"term"`
+		Rendering *string `json:"rendering"`
+	}
+	if err := unmarshalObject(call.Parameters, &input); err != nil {
+		return prompt.GlossaryEntry{}, fmt.Errorf("parse glossary_add parameters: %v", err)
+	}
+	if input.Term == nil || strings.TrimSpace(*input.Term) == "" {
+		return prompt.GlossaryEntry{}, errors.New("term is required")
+	}
+	if input.Rendering == nil || strings.TrimSpace(*input.Rendering) == "" {
+		return prompt.GlossaryEntry{}, errors.New("rendering is required")
+	}
+	term := strings.TrimSpace(*input.Term)
+	rendering := strings.TrimSpace(*input.Rendering)
+	if strings.ContainsAny(term, "\n:→") {
+		return prompt.GlossaryEntry{}, errors.New("term must be a single line without ':' or '→'")
+	}
+	if strings.Contains(rendering, "\n") {
+		return prompt.GlossaryEntry{}, errors.New("rendering must be a single line")
+	}
+	return prompt.GlossaryEntry{Term: term, Rendering: rendering}, nil
+}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/prompt"
+)
+
+func runGlossaryAdd(t *testing.T, workspace string, onAdd func(prompt.GlossaryEntry), params map[string]any) ToolResult {
+	t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("marshal params: %v", err)
+	}
+	got, err := glossaryAddTool(workspace, onAdd).Run(context.Background(), model.ToolCallPart{Name: "glossary_add", Parameters: raw})
+	if err != nil {
+		t.Fatalf("run glossary_add: %v", err)
+	}
+	return got
+}
+
+func TestGlossaryAddAppendsEntries(t *testing.T) {
+	dir := t.TempDir()
+	var added []prompt.GlossaryEntry
+	onAdd := func(e prompt.GlossaryEntry) { added = append(added, e) }
+
+	for _, p := range []map[string]any{
+		{"term": "expediente", "rendering": "case file"},
+		{"term": "ANSES", "rendering": "social security agency"},
+	} {
+		if got := runGlossaryAdd(t, dir, onAdd, p); got.IsError {
+			t.Fatalf("unexpected error: %s", got.Content)
+		}
+	}
+	b, err := os.ReadFile(filepath.Join(dir, prompt.GlossaryFile))
+	if err != nil {
+		t.Fatalf("read glossary: %v", err)
+	}
+	if string(b) != "- expediente → case file\n- ANSES → social security agency\n" {
+		t.Fatalf("unexpected glossary file: %q", string(b))
+	}
+	if len(added) != 2 || added[1].Term != "ANSES" {
+		t.Fatalf("onAdd not called for each entry: %+v", added)
+	}
+}
+
+func TestGlossaryAddRejectsInvalidTerm(t *testing.T) {
+	dir := t.TempDir()
+	got := runGlossaryAdd(t, dir, func(prompt.GlossaryEntry) { t.Fatal("onAdd called") }, map[string]any{"term": "a: b", "rendering": "x"})
+	if !got.IsError || !strings.Contains(got.Content, "term") {
+		t.Fatalf("want term error, got %q", got.Content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, prompt.GlossaryFile)); !os.IsNotExist(err) {
+		t.Fatalf("glossary file written on error: %v", err)
+	}
+}
@@ -23,6 +23,11 @@ type grepParams struct {
 	Exclude      string `json:"exclude"`
 	ContextLines int    `json:"context_lines"`
 	MaxResults   int    `json:"max_results"`
+	IgnoreCase   bool   `json:"ignore_case"`
+	Word         bool   `json:"word"`
+
+	includes []string
+	excludes []string
 }
 
 func grepTool() Tool {
@@ -35,8 +40,8 @@ func grepTool() Tool {
 			Properties: map[string]JSONSchema{
 				"pattern": {Type: "string", Desc: "regular expression pattern to search"},
 				"path":    {Type: "string", Desc: "directory to search"},
-				"include": {Type: "string", Desc: "glob for files to include"},
-				"exclude": {Type: "string", Desc: "glob for files to exclude"},
+				"include": {Type: "string", Desc: "comma-separated globs; only matching files are searched (e.g. *.go,*.md)"},
+				"exclude": {Type: "string", Desc: "comma-separated globs for files or directories to skip (e.g. vendor,*.min.js)"},
 				"context_lines": {
 					Type: "integer",
 					Desc: "lines of context before and after each match",
@@ -45,6 +50,8 @@ func grepTool() Tool {
 					Type: "integer",
 					Desc: "maximum number of output lines",
 				},
+				"ignore_case": {Type: "boolean", Desc: "match case-insensitively"},
+				"word":        {Type: "boolean", Desc: "match the pattern only as a whole word"},
 			},
 			Required: []string{"pattern"},
 		},
@@ -63,7 +70,7 @@ func runGrep(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
 	if _, err := os.Stat(root); err != nil {
 		return ToolResult{Content: err.Error(), IsError: true}, nil
 	}
-	re, err := regexp.Compile(params.Pattern)
+	re, err := compileGrepPattern(params)
 	if err != nil {
 		return ToolResult{Content: err.Error(), IsError: true}, nil
 	}
@@ -99,9 +106,49 @@ func parseGrepParams(raw json.RawMessage) (grepParams, error) {
 	if params.MaxResults <= 0 {
 		params.MaxResults = 100
 	}
+	params.includes = splitGlobs(params.Include)
+	params.excludes = splitGlobs(params.Exclude)
 	return params, nil
 }
 
+// compileGrepPattern applies the ignore_case and word flags to the pattern.
+func compileGrepPattern(params grepParams) (*regexp.Regexp, error) {
+
+	pattern := params.Pattern
+	if params.Word {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+	if params.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	return re, nil
+}
+
+func splitGlobs(raw string) []string {
+
+	var globs []string
+	for _, g := range strings.Split(raw, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			globs = append(globs, g)
+		}
+	}
+	return globs
+}
+
+func matchAnyPattern(patterns []string, path string) bool {
+
+	for _, p := range patterns {
+		if matchPathPattern(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
 func grepWalkFunc(params grepParams, root string, re *regexp.Regexp, ignorePatterns []string, out *[]string, got *int) fs.WalkDirFunc {
 	return func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -112,18 +159,15 @@ func grepWalkFunc(params grepParams, root string, re *regexp.Regexp, ignorePatte
 			relative = strings.TrimPrefix(relative, filepath.ToSlash(root)+"/")
 		}
 		if d.IsDir() {
-			if relative != "" && isIgnored(relative, ignorePatterns) {
+			if path != root && (matchAnyPattern(params.excludes, relative) || isIgnored(relative, ignorePatterns)) {
 				return filepath.SkipDir
 			}
 			return nil
 		}
-		if isIgnored(relative, ignorePatterns) {
-			return nil
-		}
-		if params.Include != "" && !matchPathPattern(params.Include, relative) {
+		if len(params.includes) > 0 && !matchAnyPattern(params.includes, relative) {
 			return nil
 		}
-		if params.Exclude != "" && matchPathPattern(params.Exclude, relative) {
+		if matchAnyPattern(params.excludes, relative) || isIgnored(relative, ignorePatterns) {
 			return nil
 		}
 		matches, err := searchMatchesInFile(relative, path, re, params.ContextLines)
@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/store"
+)
+
+const historySearchDefaultLimit = 10
+
+// historySearchTool finds past messages by the words in their text, in the
+// current thread and in every archived period.
+func historySearchTool(messages store.MessageStore) Tool {
+	return tool{
+		name: "history_search",
+		desc: "Full-text search over the text of past messages in the current thread and the archived threads; every word must match",
+		params: JSONSchema{
+			Type:     "object",
+			Required: []string{"query"},
+			Properties: map[string]JSONSchema{
+				"query": {Type: "string", Desc: "Words to search for"},
+				"limit": {Type: "integer", Desc: fmt.Sprintf("Maximum number of results (default: %d)", historySearchDefaultLimit)},
+			},
+		},
+		runFn: func(_ context.Context, call model.ToolCallPart) (ToolResult, error) {
+			var input struct {
+				Query *string `json:"query"`
+				Limit int     `json:"limit"`
+			}
+			if err := unmarshalObject(call.Parameters, &input); err != nil {
+				return ToolResult{Content: err.Error(), IsError: true}, nil
+			}
+			if input.Query == nil || strings.TrimSpace(*input.Query) == "" {
+				return ToolResult{Content: "query is required", IsError: true}, nil
+			}
+			if input.Limit <= 0 {
+				input.Limit = historySearchDefaultLimit
+			}
+			hits, err := messages.Search(*input.Query, input.Limit)
+			if err != nil {
+				return ToolResult{}, fmt.Errorf("search history: %v", err)
+			}
+			return ToolResult{Content: formatHistoryHits(hits)}, nil
+		},
+	}
+}
+
+func formatHistoryHits(hits []store.MessageHit) string {
+	if len(hits) == 0 {
+		return "no matching messages"
+	}
+	var b strings.Builder
+	for _, h := range hits {
+		period := h.Period
+		if period == "" {
+			period = "current"
+		}
+		fmt.Fprintf(&b, "[%s] %s %s (%s)\n%s\n",
+			period, h.CreatedAt.UTC().Format(time.RFC3339), h.Role, h.ID, h.Snippet)
+	}
+	return b.String()
+}
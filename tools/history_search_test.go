@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/store"
+)
+
+func runHistorySearch(t *testing.T, messages store.MessageStore, params map[string]any) ToolResult {
+	t.Helper()
+	res, err := runTool(t, historySearchTool(messages), params)
+	if err != nil {
+		t.Fatalf("history_search: %v", err)
+	}
+	return res
+}
+
+func TestHistorySearchFindsCurrentAndArchivedMessages(t *testing.T) {
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer st.Close()
+	messages := st.MessageStore()
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	old := &model.Message{ID: "a1", Role: model.RoleUser, CreatedAt: base,
+		Parts: []model.MessagePart{model.TextPart{Text: "the wifi password is on the router"}}}
+	if err := messages.Create(old); err != nil {
+		t.Fatalf("create message: %v", err)
+	}
+	if err := messages.Archive("2026-02", nil); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	cur := &model.Message{ID: "b1", Role: model.RoleAssistant, CreatedAt: base.Add(time.Hour),
+		Parts: []model.MessagePart{model.TextPart{Text: "I reset the wifi"}}}
+	if err := messages.Create(cur); err != nil {
+		t.Fatalf("create message: %v", err)
+	}
+
+	res := runHistorySearch(t, messages, map[string]any{"query": "wifi"})
+	if res.IsError {
+		t.Fatalf("history_search failed: %s", res.Content)
+	}
+	for _, want := range []string{
+		"[2026-02] 2026-03-01T09:00:00Z user (a1)",
+		"[current] 2026-03-01T10:00:00Z assistant (b1)",
+		"[wifi]",
+	} {
+		if !strings.Contains(res.Content, want) {
+			t.Fatalf("result missing %q:\n%s", want, res.Content)
+		}
+	}
+
+	res = runHistorySearch(t, messages, map[string]any{"query": "wifi router", "limit": 5})
+	if strings.Contains(res.Content, "(b1)") || !strings.Contains(res.Content, "(a1)") {
+		t.Fatalf("expected only the message with both words:\n%s", res.Content)
+	}
+	res = runHistorySearch(t, messages, map[string]any{"query": "printer"})
+	if res.Content != "no matching messages" {
+		t.Fatalf("unexpected empty result: %q", res.Content)
+	}
+	res = runHistorySearch(t, messages, map[string]any{"query": " "})
+	if !res.IsError {
+		t.Fatalf("expected error for blank query, got %q", res.Content)
+	}
+}
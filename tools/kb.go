@@ -0,0 +1,141 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/agusx1211/miclaw/memory"
+	"github.com/agusx1211/miclaw/model"
+)
+
+const kbSearchDefaultLimit = 10
+
+// KBSetTool stores an answer under an exact key in the knowledge base,
+// which is kept apart from the indexed workspace files.
+func KBSetTool(store *memory.Store) Tool {
+	return tool{
+		name:   "kb_set",
+		serial: true,
+		desc:   "Store an answer under an exact key in the knowledge base, replacing any earlier answer",
+		params: JSONSchema{
+			Type:     "object",
+			Required: []string{"key", "value"},
+			Properties: map[string]JSONSchema{
+				"key":   {Type: "string", Desc: "Exact lookup key (for example: office wifi password)"},
+				"value": {Type: "string", Desc: "Answer to store"},
+			},
+		},
+		runFn: func(_ context.Context, call model.ToolCallPart) (ToolResult, error) {
+			var input struct {
+				Key   *string `json:"key"`
+				Value *string `json:"value"`
+			}
+			if err := unmarshalObject(call.Parameters, &input); err != nil {
+				return ToolResult{IsError: true, Content: err.Error()}, nil
+			}
+			key, err := kbKey(input.Key)
+			if err != nil {
+				return ToolResult{IsError: true, Content: err.Error()}, nil
+			}
+			if input.Value == nil || strings.TrimSpace(*input.Value) == "" {
+				return ToolResult{IsError: true, Content: "value is required"}, nil
+			}
+			if err := store.PutKB(key, *input.Value); err != nil {
+				return ToolResult{IsError: true, Content: err.Error()}, nil
+			}
+			return ToolResult{Content: fmt.Sprintf("stored %s", key)}, nil
+		},
+	}
+}
+
+// KBGetTool returns the knowledge base answer stored under an exact key.
+func KBGetTool(store *memory.Store) Tool {
+	return tool{
+		name: "kb_get",
+		desc: "Get the knowledge base answer stored under an exact key",
+		params: JSONSchema{
+			Type:     "object",
+			Required: []string{"key"},
+			Properties: map[string]JSONSchema{
+				"key": {Type: "string", Desc: "Exact key used with kb_set"},
+			},
+		},
+		runFn: func(_ context.Context, call model.ToolCallPart) (ToolResult, error) {
+			var input struct {
+				Key *string `json:"key"`
+			}
+			if err := unmarshalObject(call.Parameters, &input); err != nil {
+				return ToolResult{IsError: true, Content: err.Error()}, nil
+			}
+			key, err := kbKey(input.Key)
+			if err != nil {
+				return ToolResult{IsError: true, Content: err.Error()}, nil
+			}
+			entry, err := store.GetKB(key)
+			if err != nil {
+				return ToolResult{IsError: true, Content: err.Error()}, nil
+			}
+			if entry == nil {
+				return ToolResult{IsError: true, Content: fmt.Sprintf("no entry for %s; try kb_search", key)}, nil
+			}
+			return ToolResult{Content: entry.Value}, nil
+		},
+	}
+}
+
+// KBSearchTool runs a full-text search over knowledge base keys and answers.
+func KBSearchTool(store *memory.Store) Tool {
+	return tool{
+		name: "kb_search",
+		desc: "Full-text search over knowledge base keys and answers",
+		params: JSONSchema{
+			Type:     "object",
+			Required: []string{"query"},
+			Properties: map[string]JSONSchema{
+				"query": {Type: "string", Desc: "Words to look for; an entry matching any of them is returned"},
+				"limit": {Type: "integer", Desc: "Maximum entries to return (default 10)"},
+			},
+		},
+		runFn: func(_ context.Context, call model.ToolCallPart) (ToolResult, error) {
+			var input struct {
+				Query *string `json:"query"`
+				Limit *int    `json:"limit"`
+			}
+			if err := unmarshalObject(call.Parameters, &input); err != nil {
+				return ToolResult{IsError: true, Content: err.Error()}, nil
+			}
+			if input.Query == nil || strings.TrimSpace(*input.Query) == "" {
+				return ToolResult{IsError: true, Content: "query is required"}, nil
+			}
+			limit := kbSearchDefaultLimit
+			if input.Limit != nil && *input.Limit > 0 {
+				limit = *input.Limit
+			}
+			q := memoryFTSQuery(*input.Query)
+			if q == "" {
+				return ToolResult{Content: "no matches"}, nil
+			}
+			entries, err := store.SearchKB(q, limit)
+			if err != nil {
+				return ToolResult{IsError: true, Content: err.Error()}, nil
+			}
+			if len(entries) == 0 {
+				return ToolResult{Content: "no matches"}, nil
+			}
+			var b strings.Builder
+			for _, e := range entries {
+				fmt.Fprintf(&b, "[%s]\n%s\n", e.Key, e.Value)
+			}
+			return ToolResult{Content: b.String()}, nil
+		},
+	}
+}
+
+func kbKey(raw *string) (string, error) {
+	if raw == nil || strings.TrimSpace(*raw) == "" {
+		return "", errors.New("key is required")
+	}
+	return strings.TrimSpace(*raw), nil
+}
@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKBToolsSetGetAndSearch(t *testing.T) {
+	s := openMemoryToolsStore(t)
+	set, get, search := KBSetTool(s), KBGetTool(s), KBSearchTool(s)
+
+	for key, value := range map[string]string{
+		"office wifi password": "hunter2",
+		"dentist":              "Dr. Molar, Tuesdays at 10",
+	} {
+		if res := runMemoryTool(t, set, map[string]any{"key": key, "value": value}); res.IsError {
+			t.Fatalf("kb_set %q: %s", key, res.Content)
+		}
+	}
+
+	res := runMemoryTool(t, get, map[string]any{"key": " office wifi password "})
+	if res.IsError || res.Content != "hunter2" {
+		t.Fatalf("kb_get by exact key: %+v", res)
+	}
+	if res := runMemoryTool(t, get, map[string]any{"key": "wifi"}); !res.IsError {
+		t.Fatalf("kb_get matched a partial key: %+v", res)
+	}
+
+	res = runMemoryTool(t, search, map[string]any{"query": "when is the dentist?"})
+	if res.IsError || !strings.Contains(res.Content, "[dentist]\nDr. Molar") || strings.Contains(res.Content, "hunter2") {
+		t.Fatalf("kb_search: %+v", res)
+	}
+	res = runMemoryTool(t, search, map[string]any{"query": "tuesdays"})
+	if !strings.Contains(res.Content, "[dentist]") {
+		t.Fatalf("kb_search did not match the answer text: %+v", res)
+	}
+	if res := runMemoryTool(t, search, map[string]any{"query": "parking"}); res.Content != "no matches" {
+		t.Fatalf("kb_search without matches: %+v", res)
+	}
+}
+
+func TestKBSetRequiresKeyAndValue(t *testing.T) {
+	set := KBSetTool(openMemoryToolsStore(t))
+	if res := runMemoryTool(t, set, map[string]any{"value": "x"}); !res.IsError {
+		t.Fatal("expected missing key error")
+	}
+	if res := runMemoryTool(t, set, map[string]any{"key": "k", "value": " "}); !res.IsError {
+		t.Fatal("expected missing value error")
+	}
+}
@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+func logLevelTool(get func() string, set func(string) error) Tool {
+	return tool{
+		name:   "log_level",
+		serial: true,
+		desc:   "Show the runtime log verbosity, or change it to debug, info or error; the change applies to every later log line until restart",
+		params: JSONSchema{
+			Type: "object",
+			Properties: map[string]JSONSchema{
+				"level": {
+					Type: "string",
+					Desc: "New level: debug logs everything including the agent trace, info keeps inputs and replies, error keeps only failures. Omit to read the current level",
+					Enum: []string{"debug", "info", "error"},
+				},
+			},
+		},
+		runFn: func(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
+			var input struct {
+				Level string `json:"level"`
+			}
+			if err := unmarshalObject(call.Parameters, &input); err != nil {
+				return ToolResult{IsError: true, Content: fmt.Sprintf("parse log_level parameters: %v", err)}, nil
+			}
+			before := get()
+			if strings.TrimSpace(input.Level) == "" {
+				return ToolResult{Content: "log level: " + before}, nil
+			}
+			if err := set(input.Level); err != nil {
+				return ToolResult{IsError: true, Content: err.Error()}, nil
+			}
+			return ToolResult{Content: fmt.Sprintf("log level: %s (was %s)", get(), before)}, nil
+		},
+	}
+}
@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+func TestLogLevelToolReadsAndSetsLevel(t *testing.T) {
+	level := "debug"
+	set := func(l string) error {
+		if l != "info" {
+			return errors.New("log level must be one of debug, info, error")
+		}
+		level = l
+		return nil
+	}
+	tl := logLevelTool(func() string { return level }, set)
+	run := func(params string) ToolResult {
+		t.Helper()
+		res, err := tl.Run(context.Background(), model.ToolCallPart{Name: "log_level", Parameters: []byte(params)})
+		if err != nil {
+			t.Fatalf("run log_level: %v", err)
+		}
+		return res
+	}
+
+	if got := run(`{}`); got.IsError || got.Content != "log level: debug" {
+		t.Fatalf("unexpected read: %+v", got)
+	}
+	if got := run(`{"level":"info"}`); got.IsError || got.Content != "log level: info (was debug)" || level != "info" {
+		t.Fatalf("unexpected set: %+v (level %q)", got, level)
+	}
+	if got := run(`{"level":"loud"}`); !got.IsError || level != "info" {
+		t.Fatalf("invalid level accepted: %+v", got)
+	}
+}
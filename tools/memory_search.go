@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"unicode"
 
+	"github.com/agusx1211/miclaw/config"
 	"github.com/agusx1211/miclaw/memory"
 	"github.com/agusx1211/miclaw/model"
 )
@@ -25,12 +27,33 @@ type memorySearchParams struct {
 	MinScore float64
 }
 
+// memorySearchWeights are the shares of the normalized vector and FTS scores
+// in the fused score.
+type memorySearchWeights struct {
+	vector float64
+	fts    float64
+}
+
 type memoryScoredChunk struct {
 	chunk memory.Chunk
 	score float64
 }
 
-func MemorySearchTool(store *memory.Store, embedClient *memory.EmbedClient) Tool {
+// MemorySearchTool ranks chunks by a weighted fusion of vector and full-text
+// scores. cfg supplies the default limit and min_score and the weights;
+// zero values fall back to the built-in defaults.
+func MemorySearchTool(store *memory.Store, embedClient *memory.EmbedClient, cfg config.MemoryConfig) Tool {
+	defaults := memorySearchParams{Limit: cfg.DefaultResults, MinScore: cfg.MinScore}
+	if defaults.Limit <= 0 {
+		defaults.Limit = memorySearchDefaultLimit
+	}
+	if defaults.MinScore <= 0 {
+		defaults.MinScore = memorySearchDefaultMinScore
+	}
+	weights := memorySearchWeights{vector: cfg.VectorWeight, fts: cfg.FTSWeight}
+	if weights.vector <= 0 || weights.fts <= 0 {
+		weights = memorySearchWeights{vector: memorySearchVectorWeight, fts: memorySearchFTSWeight}
+	}
 	return tool{
 		name: "memory_search",
 		desc: "Search memory chunks with hybrid vector and full-text scoring",
@@ -39,12 +62,12 @@ func MemorySearchTool(store *memory.Store, embedClient *memory.EmbedClient) Tool
 			Required: []string{"query"},
 			Properties: map[string]JSONSchema{
 				"query":     {Type: "string", Desc: "Search query"},
-				"limit":     {Type: "integer", Desc: "Maximum number of results (default: 6)"},
-				"min_score": {Type: "number", Desc: "Minimum score threshold (default: 0.35)"},
+				"limit":     {Type: "integer", Desc: fmt.Sprintf("Maximum number of results (default: %d)", defaults.Limit)},
+				"min_score": {Type: "number", Desc: fmt.Sprintf("Minimum fused score threshold (default: %.2f)", defaults.MinScore)},
 			},
 		},
 		runFn: func(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
-			return runMemorySearch(ctx, store, embedClient, call)
+			return runMemorySearch(ctx, store, embedClient, defaults, weights, call)
 		},
 	}
 }
@@ -53,15 +76,30 @@ func runMemorySearch(
 	ctx context.Context,
 	store *memory.Store,
 	embedClient *memory.EmbedClient,
+	defaults memorySearchParams,
+	weights memorySearchWeights,
 	call model.ToolCallPart,
 ) (ToolResult, error) {
-	p, err := parseMemorySearchParams(call.Parameters)
+	p, err := parseMemorySearchParams(call.Parameters, defaults)
 	if err != nil {
 		return ToolResult{Content: err.Error(), IsError: true}, nil
 	}
+	var ftsResults []memory.SearchResult
+	if q := memoryFTSQuery(p.Query); q != "" {
+		if ftsResults, err = store.SearchFTS(q, p.Limit*2); err != nil {
+			return ToolResult{Content: err.Error(), IsError: true}, nil
+		}
+	}
 	vecs, err := embedClient.Embed(ctx, []string{p.Query})
 	if err != nil {
-		return ToolResult{Content: err.Error(), IsError: true}, nil
+		if ctx.Err() != nil {
+			return ToolResult{Content: err.Error(), IsError: true}, nil
+		}
+		// Degraded mode: rank by full-text score alone while the embedding
+		// endpoint is down.
+		scored := mergeMemorySearchResults(nil, ftsResults, memorySearchWeights{fts: 1}, p.MinScore, p.Limit)
+		note := fmt.Sprintf("[vector search unavailable (%v); full-text matches only]\n", err)
+		return ToolResult{Content: note + formatMemorySearchResult(scored)}, nil
 	}
 	if len(vecs) != 1 {
 		return ToolResult{Content: "embedding count mismatch", IsError: true}, nil
@@ -70,15 +108,23 @@ func runMemorySearch(
 	if err != nil {
 		return ToolResult{Content: err.Error(), IsError: true}, nil
 	}
-	ftsResults, err := store.SearchFTS(p.Query, p.Limit*2)
-	if err != nil {
-		return ToolResult{Content: err.Error(), IsError: true}, nil
-	}
-	scored := mergeMemorySearchResults(vectorResults, ftsResults, p.MinScore, p.Limit)
+	scored := mergeMemorySearchResults(vectorResults, ftsResults, weights, p.MinScore, p.Limit)
 	return ToolResult{Content: formatMemorySearchResult(scored)}, nil
 }
 
-func parseMemorySearchParams(raw json.RawMessage) (memorySearchParams, error) {
+// memoryFTSQuery turns free text into an FTS5 query matching any of its
+// words, each quoted so punctuation and FTS operators in the text are inert.
+func memoryFTSQuery(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = `"` + w + `"`
+	}
+	return strings.Join(words, " OR ")
+}
+
+func parseMemorySearchParams(raw json.RawMessage, defaults memorySearchParams) (memorySearchParams, error) {
 	var input struct {
 		Query    *string  `json:"query"`
 		Limit    *int     `json:"limit"`
@@ -90,11 +136,8 @@ func parseMemorySearchParams(raw json.RawMessage) (memorySearchParams, error) {
 	if input.Query == nil || strings.TrimSpace(*input.Query) == "" {
 		return memorySearchParams{}, errors.New("query is required")
 	}
-	out := memorySearchParams{
-		Query:    strings.TrimSpace(*input.Query),
-		Limit:    memorySearchDefaultLimit,
-		MinScore: memorySearchDefaultMinScore,
-	}
+	out := defaults
+	out.Query = strings.TrimSpace(*input.Query)
 	if input.Limit != nil {
 		out.Limit = *input.Limit
 	}
@@ -102,7 +145,7 @@ func parseMemorySearchParams(raw json.RawMessage) (memorySearchParams, error) {
 		out.MinScore = *input.MinScore
 	}
 	if out.Limit <= 0 {
-		out.Limit = memorySearchDefaultLimit
+		out.Limit = defaults.Limit
 	}
 	return out, nil
 }
@@ -110,6 +153,7 @@ func parseMemorySearchParams(raw json.RawMessage) (memorySearchParams, error) {
 func mergeMemorySearchResults(
 	vectorResults []memory.SearchResult,
 	ftsResults []memory.SearchResult,
+	weights memorySearchWeights,
 	minScore float64,
 	limit int,
 ) []memoryScoredChunk {
@@ -124,7 +168,7 @@ func mergeMemorySearchResults(
 	}
 	out := make([]memoryScoredChunk, 0, len(byID))
 	for id, chunk := range byID {
-		score := memorySearchVectorWeight*vectorScores[id] + memorySearchFTSWeight*ftsScores[id]
+		score := weights.vector*vectorScores[id] + weights.fts*ftsScores[id]
 		if score >= minScore {
 			out = append(out, memoryScoredChunk{chunk: chunk, score: score})
 		}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/agusx1211/miclaw/config"
 	"github.com/agusx1211/miclaw/memory"
 	"github.com/agusx1211/miclaw/model"
 )
@@ -73,7 +74,7 @@ func TestMemorySearchFindsRelevantChunks(t *testing.T) {
 	putChunk(t, s, "notes.md:0", "notes.md", 1, 4, "fox memory detail", []float32{1, 0, 0})
 	putChunk(t, s, "notes.md:1", "notes.md", 5, 8, "database migration", []float32{0, 1, 0})
 
-	tool := MemorySearchTool(s, newMemoryEmbedClient(t, map[string][]float32{"fox": {1, 0, 0}}))
+	tool := MemorySearchTool(s, newMemoryEmbedClient(t, map[string][]float32{"fox": {1, 0, 0}}), config.MemoryConfig{})
 	got := runMemoryTool(t, tool, map[string]any{"query": "fox"})
 	if got.IsError {
 		t.Fatalf("unexpected error: %s", got.Content)
@@ -91,7 +92,7 @@ func TestMemorySearchHybridScoring(t *testing.T) {
 	putChunk(t, s, "a.md:0", "a.md", 1, 1, "no keyword here", []float32{1, 0})
 	putChunk(t, s, "b.md:0", "b.md", 1, 1, "fox keyword", []float32{0.6, 0.8})
 
-	tool := MemorySearchTool(s, newMemoryEmbedClient(t, map[string][]float32{"fox": {1, 0}}))
+	tool := MemorySearchTool(s, newMemoryEmbedClient(t, map[string][]float32{"fox": {1, 0}}), config.MemoryConfig{})
 	got := runMemoryTool(t, tool, map[string]any{"query": "fox", "limit": 2, "min_score": 0.0})
 	if got.IsError {
 		t.Fatalf("unexpected error: %s", got.Content)
@@ -109,7 +110,7 @@ func TestMemorySearchMinScoreFilters(t *testing.T) {
 	s := openMemoryToolsStore(t)
 	putChunk(t, s, "a.md:0", "a.md", 1, 1, "unrelated text", []float32{1, 0})
 
-	tool := MemorySearchTool(s, newMemoryEmbedClient(t, map[string][]float32{"query": {1, 0}}))
+	tool := MemorySearchTool(s, newMemoryEmbedClient(t, map[string][]float32{"query": {1, 0}}), config.MemoryConfig{})
 	got := runMemoryTool(t, tool, map[string]any{"query": "query", "min_score": 0.9})
 	if got.IsError {
 		t.Fatalf("unexpected error: %s", got.Content)
@@ -126,7 +127,7 @@ func TestMemorySearchLimitRespected(t *testing.T) {
 		putChunk(t, s, id, "notes.md", i+1, i+1, "alpha token", []float32{1, 0})
 	}
 
-	tool := MemorySearchTool(s, newMemoryEmbedClient(t, map[string][]float32{"alpha": {1, 0}}))
+	tool := MemorySearchTool(s, newMemoryEmbedClient(t, map[string][]float32{"alpha": {1, 0}}), config.MemoryConfig{})
 	got := runMemoryTool(t, tool, map[string]any{"query": "alpha", "limit": 2, "min_score": 0.0})
 	if got.IsError {
 		t.Fatalf("unexpected error: %s", got.Content)
@@ -177,3 +178,88 @@ func TestMemoryGetSurroundingContext(t *testing.T) {
 		t.Fatalf("surrounding context missing or out of order: %q", got.Content)
 	}
 }
+
+func TestMemoryWriteStoresSearchableNote(t *testing.T) {
+	s := openMemoryToolsStore(t)
+	embed := newMemoryEmbedClient(t, map[string][]float32{
+		"tags: home\n\nthe wifi password lives in the blue notebook\n": {1, 0, 0},
+		"wifi": {1, 0, 0},
+	})
+	workspace := t.TempDir()
+	write := MemoryWriteTool(s, embed, config.MemoryConfig{}, workspace)
+
+	got := runMemoryTool(t, write, map[string]any{"text": "the wifi password lives in the blue notebook", "tags": []string{"home"}})
+	if got.IsError || !strings.HasPrefix(got.Content, "remembered: memory/notes/") {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	id := strings.TrimPrefix(got.Content, "remembered: ")
+	again := runMemoryTool(t, write, map[string]any{"text": "the wifi password lives in the blue notebook"})
+	if again.Content != "already remembered: "+id {
+		t.Fatalf("write was not idempotent: %q", again.Content)
+	}
+	found := runMemoryTool(t, MemorySearchTool(s, embed, config.MemoryConfig{}), map[string]any{"query": "wifi"})
+	if !strings.Contains(found.Content, "blue notebook") {
+		t.Fatalf("note not searchable: %q", found.Content)
+	}
+	if bad := runMemoryTool(t, write, map[string]any{"text": "  "}); !bad.IsError {
+		t.Fatalf("expected error for empty text, got %q", bad.Content)
+	}
+}
+
+func TestMemorySearchSurfacesStrongFTSWeakVectorChunk(t *testing.T) {
+	s := openMemoryToolsStore(t)
+	putChunk(t, s, "a.md:0", "a.md", 1, 1, "general notes about cooking", []float32{1, 0})
+	putChunk(t, s, "b.md:0", "b.md", 1, 1, "zebra migration schedule", []float32{0.2, 0.98})
+	putChunk(t, s, "c.md:0", "c.md", 1, 1, "unrelated", []float32{0, 1})
+	query := "when is the zebra migration?"
+	embed := newMemoryEmbedClient(t, map[string][]float32{query: {1, 0}})
+
+	got := runMemoryTool(t, MemorySearchTool(s, embed, config.MemoryConfig{}), map[string]any{"query": query})
+	if got.IsError {
+		t.Fatalf("unexpected error: %s", got.Content)
+	}
+	if !strings.Contains(got.Content, "[b.md:1-1]") {
+		t.Fatalf("expected FTS match to surface, got %q", got.Content)
+	}
+	if strings.Contains(got.Content, "[c.md:1-1]") {
+		t.Fatalf("expected unrelated chunk below min_score, got %q", got.Content)
+	}
+
+	ftsHeavy := config.MemoryConfig{VectorWeight: 0.2, FTSWeight: 0.8}
+	got = runMemoryTool(t, MemorySearchTool(s, embed, ftsHeavy), map[string]any{"query": query})
+	if !strings.HasPrefix(got.Content, "[b.md:1-1]") {
+		t.Fatalf("expected configured weights to rank FTS match first, got %q", got.Content)
+	}
+}
+
+func TestMemorySearchFallsBackToFTSWhenEmbeddingFails(t *testing.T) {
+	s := openMemoryToolsStore(t)
+	putChunk(t, s, "a.md:0", "a.md", 1, 1, "zebra migration schedule", []float32{1, 0})
+	putChunk(t, s, "b.md:0", "b.md", 1, 1, "general notes about cooking", []float32{0, 1})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+	embed := memory.NewEmbedClient(srv.URL, "", "test-model")
+	embed.SetLimits(memory.EmbedLimits{})
+
+	got := runMemoryTool(t, MemorySearchTool(s, embed, config.MemoryConfig{}), map[string]any{"query": "zebra migration"})
+	if got.IsError {
+		t.Fatalf("expected degraded results, got error %q", got.Content)
+	}
+	if !strings.HasPrefix(got.Content, "[vector search unavailable") || !strings.Contains(got.Content, "[a.md:1-1]") {
+		t.Fatalf("expected FTS-only results with a note, got %q", got.Content)
+	}
+	if strings.Contains(got.Content, "[b.md:1-1]") {
+		t.Fatalf("unmatched chunk in FTS-only results: %q", got.Content)
+	}
+}
+
+func TestMemoryFTSQueryQuotesWords(t *testing.T) {
+	if got := memoryFTSQuery(`what's "NEAR" the fox?`); got != `"what" OR "s" OR "NEAR" OR "the" OR "fox"` {
+		t.Fatalf("memoryFTSQuery = %q", got)
+	}
+	if got := memoryFTSQuery("?!"); got != "" {
+		t.Fatalf("memoryFTSQuery = %q, want empty", got)
+	}
+}
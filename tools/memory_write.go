@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/memory"
+	"github.com/agusx1211/miclaw/model"
+)
+
+type memoryWriteParams struct {
+	Text string
+	Tags []string
+}
+
+// MemoryWriteTool stores durable facts as notes under memory/notes in the
+// workspace and indexes them right away, chunked as cfg sets for Sync.
+func MemoryWriteTool(store *memory.Store, embedClient *memory.EmbedClient, cfg config.MemoryConfig, workspace string) Tool {
+	return tool{
+		name:   "memory_write",
+		serial: true,
+		desc:   "Remember a durable fact: save text as a memory note and index it for memory_search",
+		params: JSONSchema{
+			Type:     "object",
+			Required: []string{"text"},
+			Properties: map[string]JSONSchema{
+				"text": {Type: "string", Desc: "Fact to remember, written to stand on its own"},
+				"tags": {Type: "array", Desc: "Optional tags stored with the note", Items: &JSONSchema{Type: "string"}},
+			},
+		},
+		runFn: func(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
+			indexer := memory.NewIndexer(store, embedClient)
+			indexer.SetChunkTokens(cfg.ChunkSizeTokens, cfg.ChunkOverlapTokens)
+			return runMemoryWrite(ctx, indexer, workspace, call)
+		},
+	}
+}
+
+func runMemoryWrite(ctx context.Context, indexer *memory.Indexer, workspace string, call model.ToolCallPart) (ToolResult, error) {
+	p, err := parseMemoryWriteParams(call.Parameters)
+	if err != nil {
+		return ToolResult{Content: err.Error(), IsError: true}, nil
+	}
+	id, created, err := indexer.Remember(ctx, workspace, p.Text, p.Tags)
+	if err != nil {
+		return ToolResult{Content: err.Error(), IsError: true}, nil
+	}
+	if !created {
+		return ToolResult{Content: fmt.Sprintf("already remembered: %s", id)}, nil
+	}
+	return ToolResult{Content: fmt.Sprintf("remembered: %s", id)}, nil
+}
+
+func parseMemoryWriteParams(raw json.RawMessage) (memoryWriteParams, error) {
+	var input struct {
+		Text *string  `json:"text"`
+		Tags []string `json:"tags"`
+	}
+	if err := unmarshalObject(raw, &input); err != nil {
+		return memoryWriteParams{}, err
+	}
+	if input.Text == nil || strings.TrimSpace(*input.Text) == "" {
+		return memoryWriteParams{}, errors.New("text is required")
+	}
+	out := memoryWriteParams{Text: strings.TrimSpace(*input.Text)}
+	for _, tag := range input.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			out.Tags = append(out.Tags, tag)
+		}
+	}
+	return out, nil
+}
@@ -17,15 +17,16 @@ type messageParams struct {
 
 func messageTool(sendMessage func(ctx context.Context, to, content string) error) Tool {
 	return tool{
-		name: "message",
-		desc: "Send a message to a recipient",
+		name:   "message",
+		serial: true,
+		desc:   "Send a message to a recipient",
 		params: JSONSchema{
 			Type:     "object",
 			Required: []string{"to", "content"},
 			Properties: map[string]JSONSchema{
 				"to": {
 					Type: "string",
-					Desc: "Message target (for example: signal:dm:user-uuid or signal:group:group-id)",
+					Desc: "Message target (for example: signal:dm:user-uuid, signal:group:group-id, or webhook:deploy to answer a sync webhook request from that source)",
 				},
 				"content": {
 					Type: "string",
@@ -42,11 +43,13 @@ func messageTool(sendMessage func(ctx context.Context, to, content string) error
 			if err != nil {
 				return ToolResult{IsError: true, Content: err.Error()}, nil
 			}
-			if channel != "signal" {
+			if channel != "signal" && channel != "webhook" {
 				return ToolResult{IsError: true, Content: fmt.Sprintf("unsupported channel: %s", channel)}, nil
 			}
-			if err := sendMessage(ctx, params.To, params.Content); err != nil {
-				return ToolResult{IsError: true, Content: err.Error()}, nil
+			if rest := unsent(params.Content, call.Streamed); rest != "" {
+				if err := sendMessage(ctx, params.To, rest); err != nil {
+					return ToolResult{IsError: true, Content: err.Error()}, nil
+				}
 			}
 			return ToolResult{Content: fmt.Sprintf("message sent to %s", params.To)}, nil
 		},
@@ -73,6 +76,12 @@ func parseMessageParams(raw json.RawMessage) (messageParams, error) {
 	}, nil
 }
 
+// unsent returns the part of content that was not delivered while the call
+// streamed.
+func unsent(content, streamed string) string {
+	return strings.TrimSpace(strings.TrimPrefix(content, strings.TrimSpace(streamed)))
+}
+
 func parseMessageTarget(raw string) (string, string, error) {
 	parts := strings.SplitN(raw, ":", 2)
 	if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
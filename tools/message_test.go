@@ -48,6 +48,27 @@ func TestMessageToolSendsSignalMessage(t *testing.T) {
 	}
 }
 
+func TestMessageToolSendsOnlyWhatWasNotStreamed(t *testing.T) {
+	var sent []string
+	tool := messageTool(func(_ context.Context, _, content string) error {
+		sent = append(sent, content)
+		return nil
+	})
+	for _, streamed := range []string{"One.\n\nTwo.\n\n", "One.\n\nTwo.\n\nThree.\n\n"} {
+		got, err := tool.Run(context.Background(), model.ToolCallPart{
+			Name:       "message",
+			Parameters: json.RawMessage(`{"to":"signal:dm:user-1","content":"One.\n\nTwo.\n\nThree."}`),
+			Streamed:   streamed,
+		})
+		if err != nil || got.IsError {
+			t.Fatalf("tool call: %v %q", err, got.Content)
+		}
+	}
+	if len(sent) != 1 || sent[0] != "Three." {
+		t.Fatalf("want only the unstreamed tail sent once, got %q", sent)
+	}
+}
+
 func TestMessageToolRejectsInvalidTarget(t *testing.T) {
 	called := false
 	tool := messageTool(func(context.Context, string, string) error {
@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+type moveParams struct {
+	Source    string
+	Dest      string
+	Overwrite bool
+}
+
+func moveTool() Tool {
+	params := JSONSchema{
+		Type: "object",
+		Properties: map[string]JSONSchema{
+			"source": {
+				Type: "string",
+				Desc: "Path to the file or directory to move",
+			},
+			"dest": {
+				Type: "string",
+				Desc: "Destination path",
+			},
+			"overwrite": {
+				Type: "boolean",
+				Desc: "Replace an existing destination (default: false)",
+			},
+		},
+		Required: []string{"source", "dest"},
+	}
+
+	return tool{
+		name:   "move",
+		serial: true,
+		desc:   "Move or rename a file or directory",
+		params: params,
+		runFn:  runMove,
+	}
+}
+
+func runMove(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
+
+	args, err := parseMoveParams(call.Parameters)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	if _, err := os.Lstat(args.Source); err != nil {
+		return ToolResult{}, fmt.Errorf("source %q: %v", args.Source, err)
+	}
+	if _, err := os.Lstat(args.Dest); err == nil && !args.Overwrite {
+		return ToolResult{}, fmt.Errorf("destination %q already exists (set overwrite to replace it)", args.Dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(args.Dest), 0o755); err != nil {
+		return ToolResult{}, fmt.Errorf("create parent directories for %q: %v", args.Dest, err)
+	}
+	if err := movePath(args.Source, args.Dest); err != nil {
+		return ToolResult{}, err
+	}
+
+	return ToolResult{Content: fmt.Sprintf("moved %s to %s", args.Source, args.Dest)}, nil
+}
+
+func parseMoveParams(raw json.RawMessage) (moveParams, error) {
+
+	var input struct {
+		Source    *string `json:"source"`
+		Dest      *string `json:"dest"`
+		Overwrite bool    `json:"overwrite"`
+	}
+	if err := json.Unmarshal(raw, &input); err != nil {
+		return moveParams{}, fmt.Errorf("parse move parameters: %v", err)
+	}
+	if input.Source == nil || *input.Source == "" {
+		return moveParams{}, errors.New("move parameter source is required")
+	}
+	if input.Dest == nil || *input.Dest == "" {
+		return moveParams{}, errors.New("move parameter dest is required")
+	}
+
+	return moveParams{Source: *input.Source, Dest: *input.Dest, Overwrite: input.Overwrite}, nil
+}
+
+func movePath(src, dst string) error {
+
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		return fmt.Errorf("move %q to %q: %v", src, dst, err)
+	}
+	if err := copyFile(src, dst); err != nil {
+		return fmt.Errorf("copy %q to %q: %v", src, dst, err)
+	}
+	if err := os.Remove(src); err != nil {
+		return fmt.Errorf("remove %q after copy: %v", src, err)
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errors.New("cannot move a directory across filesystems")
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Chmod(dst, info.Mode().Perm())
+}
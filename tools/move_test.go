@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/model"
+)
+
+func TestMoveRenamesWithinDirectory(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.txt")
+	dst := filepath.Join(dir, "b.txt")
+	if err := os.WriteFile(src, []byte("hello"), 0o640); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	got, err := runMoveCall(t, moveArgs{Source: src, Dest: dst})
+	if err != nil {
+		t.Fatalf("run move: %v", err)
+	}
+	if !strings.Contains(got.Content, src) || !strings.Contains(got.Content, dst) {
+		t.Fatalf("result missing paths: %q", got.Content)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Fatalf("source still exists: %v", err)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("stat dest: %v", err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Fatalf("permissions changed: %v", info.Mode().Perm())
+	}
+}
+
+func TestMoveAcrossDirectoriesCreatesParents(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.txt")
+	dst := filepath.Join(dir, "x", "y", "a.txt")
+	if err := os.WriteFile(src, []byte("data"), 0o644); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	if _, err := runMoveCall(t, moveArgs{Source: src, Dest: dst}); err != nil {
+		t.Fatalf("run move: %v", err)
+	}
+	b, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("read dest: %v", err)
+	}
+	if string(b) != "data" {
+		t.Fatalf("want data, got %q", string(b))
+	}
+}
+
+func TestMoveRefusesToOverwriteByDefault(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.txt")
+	dst := filepath.Join(dir, "b.txt")
+	if err := os.WriteFile(src, []byte("new"), 0o644); err != nil {
+		t.Fatalf("seed src: %v", err)
+	}
+	if err := os.WriteFile(dst, []byte("old"), 0o644); err != nil {
+		t.Fatalf("seed dest: %v", err)
+	}
+	_, err := runMoveCall(t, moveArgs{Source: src, Dest: dst})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("want overwrite error, got %v", err)
+	}
+	b, _ := os.ReadFile(dst)
+	if string(b) != "old" {
+		t.Fatalf("dest changed: %q", string(b))
+	}
+	if _, err := runMoveCall(t, moveArgs{Source: src, Dest: dst, Overwrite: true}); err != nil {
+		t.Fatalf("run move with overwrite: %v", err)
+	}
+	b, _ = os.ReadFile(dst)
+	if string(b) != "new" {
+		t.Fatalf("want new, got %q", string(b))
+	}
+}
+
+func TestMoveFailsForMissingSource(t *testing.T) {
+	dir := t.TempDir()
+	_, err := runMoveCall(t, moveArgs{Source: filepath.Join(dir, "nope"), Dest: filepath.Join(dir, "b")})
+	if err == nil {
+		t.Fatal("want error for missing source")
+	}
+}
+
+func TestCopyFileKeepsContentAndMode(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "run.sh")
+	dst := filepath.Join(dir, "copy.sh")
+	if err := os.WriteFile(src, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	if err := copyFile(src, dst); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm() != 0o755 {
+		t.Fatalf("want 0755, got %v", info.Mode().Perm())
+	}
+}
+
+type moveArgs struct {
+	Source    string `json:"source"`
+	Dest      string `json:"dest"`
+	Overwrite bool   `json:"overwrite,omitempty"`
+}
+
+func runMoveCall(t *testing.T, args moveArgs) (ToolResult, error) {
+	t.Helper()
+	b, err := json.Marshal(args)
+	if err != nil {
+		t.Fatalf("marshal args: %v", err)
+	}
+	return moveTool().Run(context.Background(), model.ToolCallPart{Name: "move", Parameters: b})
+}
@@ -49,6 +49,7 @@ func patchTool() Tool {
 
 	return tool{
 		name:   "apply_patch",
+		serial: true,
 		desc:   "Apply a unified diff patch to an existing file",
 		params: params,
 		runFn:  runPatch,
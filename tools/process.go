@@ -27,8 +27,9 @@ type processParams struct {
 
 func processTool() Tool {
 	return tool{
-		name: "process",
-		desc: "Manage background processes started by exec",
+		name:   "process",
+		serial: true,
+		desc:   "Manage background processes started by exec",
 		params: JSONSchema{
 			Type: "object",
 			Properties: map[string]JSONSchema{
@@ -6,6 +6,8 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"sort"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -29,6 +31,14 @@ type managedProc struct {
 	finished  bool
 }
 
+// ProcInfo describes a background process for listing.
+type ProcInfo struct {
+	PID     int
+	Command string
+	Running bool
+	Elapsed time.Duration
+}
+
 type procOutputWriter struct {
 	mgr  *ProcManager
 	proc *managedProc
@@ -112,6 +122,49 @@ func (m *ProcManager) Poll(pid int) (string, error) {
 	return proc.output.String(), nil
 }
 
+// List returns every tracked process ordered by PID.
+func (m *ProcManager) List() []ProcInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]ProcInfo, 0, len(m.procs))
+	for pid, proc := range m.procs {
+		end := time.Now()
+		if proc.finished {
+			end = proc.endTime
+		}
+		out = append(out, ProcInfo{
+			PID:     pid,
+			Command: procCommand(proc.cmd),
+			Running: !proc.finished,
+			Elapsed: end.Sub(proc.startTime),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PID < out[j].PID })
+	return out
+}
+
+// Kill sends SIGTERM to the process group, escalates to SIGKILL after grace,
+// removes the entry, and returns the output captured so far.
+func (m *ProcManager) Kill(pid int, grace time.Duration) (string, error) {
+	proc, ok := m.getProc(pid)
+	if !ok {
+		return "", fmt.Errorf("process %d not found", pid)
+	}
+	if !procDone(proc) {
+		_ = syscall.Kill(-pid, syscall.SIGTERM)
+		select {
+		case <-proc.done:
+		case <-time.After(grace):
+			_ = syscall.Kill(-pid, syscall.SIGKILL)
+			<-proc.done
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.procs, pid)
+	return proc.output.String(), nil
+}
+
 func (m *ProcManager) SendInput(pid int, data string) error {
 	m.mu.Lock()
 	proc, ok := m.procs[pid]
@@ -161,6 +214,22 @@ func (m *ProcManager) getProc(pid int) (*managedProc, bool) {
 	return proc, ok
 }
 
+func procDone(proc *managedProc) bool {
+	select {
+	case <-proc.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func procCommand(cmd *exec.Cmd) string {
+	if len(cmd.Args) == 3 && cmd.Args[1] == "-c" {
+		return cmd.Args[2]
+	}
+	return strings.Join(cmd.Args, " ")
+}
+
 func (w *procOutputWriter) Write(p []byte) (int, error) {
 	w.mgr.mu.Lock()
 	defer w.mgr.mu.Unlock()
@@ -66,3 +66,28 @@ func waitProcDone(t *testing.T, mgr *ProcManager, pid int) {
 		time.Sleep(10 * time.Millisecond)
 	}
 }
+
+func TestProcManagerKillEscalatesToSIGKILL(t *testing.T) {
+	mgr := NewProcManager()
+	cmd := exec.Command("sh", "-c", "trap '' TERM; echo ready; while :; do sleep 0.05; done")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	pid := mgr.Start(cmd)
+	deadline := time.Now().Add(2 * time.Second)
+	for out, _ := mgr.Poll(pid); out == ""; out, _ = mgr.Poll(pid) {
+		if time.Now().After(deadline) {
+			t.Fatal("process did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	out, err := mgr.Kill(pid, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	if out != "ready\n" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	if len(mgr.List()) != 0 {
+		t.Fatalf("expected no tracked processes, got %+v", mgr.List())
+	}
+}
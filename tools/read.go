@@ -21,21 +21,30 @@ const (
 
 const readTruncationMessage = "[read output truncated at 512KB]"
 
+// readHeadMarkerRoom keeps space under readMaxOutputBytes for the
+// "[truncated, N more lines]" marker of an unranged read.
+const readHeadMarkerRoom = 64
+
 type readParams struct {
 	Path   string
 	Offset int
 	Limit  int
+	Tail   int
+	Ranged bool
 }
 
 type rawReadParams struct {
-	Path   string `json:"path"`
-	Offset *int   `json:"offset"`
-	Limit  *int   `json:"limit"`
+	Path      string `json:"path"`
+	Offset    *int   `json:"offset"`
+	Limit     *int   `json:"limit"`
+	StartLine *int   `json:"start_line"`
+	EndLine   *int   `json:"end_line"`
+	Tail      *int   `json:"tail"`
 }
 
 func ReadTool() Tool {
 	name := "read"
-	desc := "Read file contents with line numbers"
+	desc := "Read file contents with line numbers. Large files without a range return the head and a truncation marker; use start_line/end_line or tail for a slice."
 
 	return tool{
 		name: name,
@@ -56,6 +65,18 @@ func ReadTool() Tool {
 					Type: "integer",
 					Desc: "Maximum number of lines to return",
 				},
+				"start_line": {
+					Type: "integer",
+					Desc: "First line to return (1-based, inclusive)",
+				},
+				"end_line": {
+					Type: "integer",
+					Desc: "Last line to return (1-based, inclusive)",
+				},
+				"tail": {
+					Type: "integer",
+					Desc: "Return only the last N lines",
+				},
 			},
 		},
 		runFn: func(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
@@ -65,7 +86,7 @@ func ReadTool() Tool {
 				return ToolResult{IsError: true, Content: err.Error()}, nil
 			}
 
-			content, err := readFileContent(params.Path, params.Offset, params.Limit)
+			content, err := readFileContent(params)
 			if err != nil {
 				return ToolResult{IsError: true, Content: err.Error()}, nil
 			}
@@ -80,6 +101,23 @@ func parseReadParams(raw json.RawMessage) (readParams, error) {
 	if err := json.Unmarshal(raw, &params); err != nil {
 		return readParams{}, fmt.Errorf("invalid read parameters: %w", err)
 	}
+	paged := params.Offset != nil || params.Limit != nil
+	lined := params.StartLine != nil || params.EndLine != nil
+	if params.Tail != nil {
+		if paged || lined {
+			return readParams{}, fmt.Errorf("tail cannot be combined with offset, limit, start_line, or end_line")
+		}
+		if *params.Tail <= 0 {
+			return readParams{}, fmt.Errorf("tail must be greater than zero")
+		}
+		return readParams{Path: params.Path, Tail: *params.Tail, Ranged: true}, nil
+	}
+	if lined {
+		if paged {
+			return readParams{}, fmt.Errorf("use either start_line/end_line or offset/limit")
+		}
+		return parseLineRange(params)
+	}
 	offset := 0
 	if params.Offset != nil {
 		offset = *params.Offset
@@ -89,48 +127,142 @@ func parseReadParams(raw json.RawMessage) (readParams, error) {
 		limit = *params.Limit
 	}
 
-	return readParams{Path: params.Path, Offset: offset, Limit: limit}, nil
+	return readParams{Path: params.Path, Offset: offset, Limit: limit, Ranged: paged}, nil
 }
 
-func readFileContent(path string, offset, limit int) (string, error) {
+func parseLineRange(params rawReadParams) (readParams, error) {
 
-	if limit == 0 {
-		return "", nil
+	start := 1
+	if params.StartLine != nil {
+		start = *params.StartLine
+	}
+	if start < 1 {
+		return readParams{}, fmt.Errorf("start_line must be at least 1")
+	}
+	limit := readDefaultLimit
+	if params.EndLine != nil {
+		if *params.EndLine < start {
+			return readParams{}, fmt.Errorf("end_line must not be before start_line")
+		}
+		limit = *params.EndLine - start + 1
+	}
+	return readParams{Path: params.Path, Offset: start - 1, Limit: limit, Ranged: true}, nil
+}
+
+func readFileContent(params readParams) (string, error) {
+
+	switch {
+	case params.Tail > 0:
+		return readTail(params.Path, params.Tail)
+	case params.Ranged:
+		return readRange(params.Path, params.Offset, params.Limit)
 	}
+	return readHead(params.Path)
+}
+
+// eachReadLine calls fn with every line of path (1-based numbers, newline
+// stripped) until fn returns false. It fails on binary content it reaches.
+func eachReadLine(path string, fn func(lineNo int, text string) bool) error {
+
 	file, err := os.Open(path)
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer file.Close()
 
 	reader := bufio.NewReader(file)
-	output := &bytes.Buffer{}
-	readLines := 0
-	for lineNo := 0; ; lineNo++ {
+	for lineNo := 1; ; lineNo++ {
 		line, readErr := reader.ReadString('\n')
 		if len(line) == 0 && errors.Is(readErr, io.EOF) {
-			break
+			return nil
 		}
 		if readErr != nil && !errors.Is(readErr, io.EOF) {
-			return "", readErr
+			return readErr
 		}
 		if strings.IndexByte(line, 0) >= 0 {
-			return "", fmt.Errorf("binary file")
+			return fmt.Errorf("binary file")
 		}
-		if lineNo < offset {
-			if errors.Is(readErr, io.EOF) {
-				break
-			}
-			continue
+		if !fn(lineNo, strings.TrimSuffix(line, "\n")) || errors.Is(readErr, io.EOF) {
+			return nil
 		}
-		if readLines >= limit {
-			break
+	}
+}
+
+func readRange(path string, offset, limit int) (string, error) {
+
+	if limit == 0 {
+		return "", nil
+	}
+	output := &bytes.Buffer{}
+	readLines := 0
+	err := eachReadLine(path, func(lineNo int, text string) bool {
+		if lineNo <= offset {
+			return true
 		}
-		if !appendReadLine(output, lineNo+1, strings.TrimSuffix(line, "\n")) {
-			return output.String(), nil
+		if readLines >= limit || !appendReadLine(output, lineNo, text) {
+			return false
 		}
 		readLines++
-		if errors.Is(readErr, io.EOF) {
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// readHead returns whole lines up to the default line and byte caps, then a
+// marker counting the lines left out.
+func readHead(path string) (string, error) {
+
+	output := &bytes.Buffer{}
+	more := 0
+	err := eachReadLine(path, func(lineNo int, text string) bool {
+		if more > 0 {
+			more++
+			return true
+		}
+		line := fmt.Sprintf("%6d\t%s\n", lineNo, text)
+		if lineNo > readDefaultLimit || output.Len()+len(line) > readMaxOutputBytes-readHeadMarkerRoom {
+			more = 1
+			return true
+		}
+		output.WriteString(line)
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	if more > 0 {
+		fmt.Fprintf(output, "[truncated, %d more lines]", more)
+	}
+	return output.String(), nil
+}
+
+func readTail(path string, n int) (string, error) {
+
+	type numbered struct {
+		no   int
+		text string
+	}
+	ring := make([]numbered, 0, min(n, readDefaultLimit))
+	next := 0
+	err := eachReadLine(path, func(lineNo int, text string) bool {
+		if len(ring) < n {
+			ring = append(ring, numbered{lineNo, text})
+			return true
+		}
+		ring[next] = numbered{lineNo, text}
+		next = (next + 1) % n
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	output := &bytes.Buffer{}
+	for i := range ring {
+		l := ring[(next+i)%len(ring)]
+		if !appendReadLine(output, l.no, l.text) {
 			break
 		}
 	}
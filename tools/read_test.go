@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -163,3 +164,98 @@ func TestReadToolReadsEmptyFile(t *testing.T) {
 	}
 
 }
+
+func runReadToolParams(t *testing.T, params map[string]any) (string, bool) {
+	t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("failed to marshal read parameters: %v", err)
+	}
+	result, err := ReadTool().Run(context.Background(), model.ToolCallPart{Parameters: raw})
+	if err != nil {
+		t.Fatalf("read tool run returned error: %v", err)
+	}
+	return result.Content, result.IsError
+}
+
+func writeNumberedLines(t *testing.T, n int) string {
+	t.Helper()
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	path := filepath.Join(t.TempDir(), "lines.txt")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	return path
+}
+
+func TestReadToolReturnsStartEndLineRange(t *testing.T) {
+	path := writeNumberedLines(t, 10)
+
+	got, isErr := runReadToolParams(t, map[string]any{"path": path, "start_line": 3, "end_line": 5})
+	if isErr {
+		t.Fatalf("range read should succeed: %s", got)
+	}
+	if got != "     3\tline 3\n     4\tline 4\n     5\tline 5\n" {
+		t.Fatalf("range output incorrect: %q", got)
+	}
+	got, _ = runReadToolParams(t, map[string]any{"path": path, "end_line": 2})
+	if got != "     1\tline 1\n     2\tline 2\n" {
+		t.Fatalf("end_line only output incorrect: %q", got)
+	}
+	got, _ = runReadToolParams(t, map[string]any{"path": path, "start_line": 9})
+	if got != "     9\tline 9\n    10\tline 10\n" {
+		t.Fatalf("start_line only output incorrect: %q", got)
+	}
+}
+
+func TestReadToolReturnsTail(t *testing.T) {
+	path := writeNumberedLines(t, 10)
+
+	got, isErr := runReadToolParams(t, map[string]any{"path": path, "tail": 2})
+	if isErr {
+		t.Fatalf("tail read should succeed: %s", got)
+	}
+	if got != "     9\tline 9\n    10\tline 10\n" {
+		t.Fatalf("tail output incorrect: %q", got)
+	}
+	got, _ = runReadToolParams(t, map[string]any{"path": path, "tail": 50})
+	if !strings.HasPrefix(got, "     1\tline 1\n") || !strings.HasSuffix(got, "    10\tline 10\n") {
+		t.Fatalf("tail beyond file length incorrect: %q", got)
+	}
+}
+
+func TestReadToolRejectsInvalidRanges(t *testing.T) {
+	path := writeNumberedLines(t, 3)
+	cases := []map[string]any{
+		{"path": path, "start_line": 0},
+		{"path": path, "start_line": 3, "end_line": 2},
+		{"path": path, "start_line": 1, "offset": 1},
+		{"path": path, "tail": 0},
+		{"path": path, "tail": 1, "start_line": 1},
+	}
+	for _, params := range cases {
+		if got, isErr := runReadToolParams(t, params); !isErr {
+			t.Fatalf("%v should fail, got %q", params, got)
+		}
+	}
+}
+
+func TestReadToolMarksTruncatedHead(t *testing.T) {
+	path := writeNumberedLines(t, readDefaultLimit+25)
+
+	got, isErr := runReadTool(t, path, nil, nil)
+	if isErr {
+		t.Fatalf("large read should succeed")
+	}
+	if !strings.HasSuffix(got, fmt.Sprintf("%6d\tline %d\n[truncated, 25 more lines]", readDefaultLimit, readDefaultLimit)) {
+		t.Fatalf("missing truncation marker: %q", got[len(got)-80:])
+	}
+	lim := 1500
+	got, _ = runReadTool(t, path, nil, &lim)
+	if strings.Contains(got, "truncated") {
+		t.Fatalf("explicit range should not be marked: %q", got[len(got)-80:])
+	}
+}
@@ -5,17 +5,45 @@ import (
 
 	"github.com/agusx1211/miclaw/config"
 	"github.com/agusx1211/miclaw/memory"
+	"github.com/agusx1211/miclaw/prompt"
+	"github.com/agusx1211/miclaw/provider"
+	"github.com/agusx1211/miclaw/store"
 )
 
 type MainToolDeps struct {
+	Workspace   string
 	Sandbox     config.SandboxConfig
 	Memory      *memory.Store
+	MemoryCfg   config.MemoryConfig
 	Embed       *memory.EmbedClient
 	Scheduler   *Scheduler
 	SendMessage func(ctx context.Context, to, content string) error
+	AddGlossary func(prompt.GlossaryEntry)
+	// Messages backs the transcript and history_search tools.
+	Messages store.MessageStore
+	// Model supplies the input price for token_estimate.
+	Model provider.ModelInfo
+	// Fetch registers the fetch tool, giving the agent outbound HTTP.
+	Fetch bool
+	// MaxFilesPerOp caps the entries a single bulk operation may touch
+	// without force; 0 means no limit.
+	MaxFilesPerOp int
+	// SnapshotDir holds the workspace snapshots taken by the snapshot tool
+	// and restored by rollback.
+	SnapshotDir string
+	Snapshot    config.SnapshotConfig
+	// LogLevel and SetLogLevel back the log_level tool.
+	LogLevel    func() string
+	SetLogLevel func(string) error
+	// ExecOutput, when set, receives a foreground exec call's output while
+	// the command runs, keyed by tool call ID.
+	ExecOutput func(callID, chunk string)
+	// SecretsFile is the path exec env_from names are read from.
+	SecretsFile string
 }
 
 func MainAgentTools(deps MainToolDeps) []Tool {
+	snaps := newSnapshots(deps.Workspace, deps.SnapshotDir, deps.Snapshot)
 	tools := []Tool{
 		ReadTool(),
 		writeTool(),
@@ -24,13 +52,31 @@ func MainAgentTools(deps MainToolDeps) []Tool {
 		grepTool(),
 		globTool(),
 		lsTool(),
-		execToolWithSandbox(deps.Sandbox),
+		moveTool(),
+		deleteTool(deps.Workspace, deps.MaxFilesPerOp, snaps, deps.Snapshot.Auto),
+		execToolWithSandbox(deps.Sandbox, deps.SecretsFile, deps.ExecOutput),
 		processTool(),
+		bgListTool(),
+		bgKillTool(),
 		CronTool(deps.Scheduler),
 		messageTool(deps.SendMessage),
 		sleepTool(),
-		MemorySearchTool(deps.Memory, deps.Embed),
+		MemorySearchTool(deps.Memory, deps.Embed, deps.MemoryCfg),
 		MemoryGetTool(deps.Memory),
+		MemoryWriteTool(deps.Memory, deps.Embed, deps.MemoryCfg, deps.Workspace),
+		KBSetTool(deps.Memory),
+		KBGetTool(deps.Memory),
+		KBSearchTool(deps.Memory),
+		glossaryAddTool(deps.Workspace, deps.AddGlossary),
+		transcriptTool(deps.Workspace, deps.Messages),
+		historySearchTool(deps.Messages),
+		tokenEstimateTool(deps.Workspace, deps.Model),
+		logLevelTool(deps.LogLevel, deps.SetLogLevel),
+		snapshotTool(snaps),
+		rollbackTool(snaps),
+	}
+	if deps.Fetch {
+		tools = append(tools, fetchTool())
 	}
 
 	return tools
@@ -45,7 +91,9 @@ func BridgeableToolNames() map[string]bool {
 		"grep":        true,
 		"glob":        true,
 		"ls":          true,
+		"move":        true,
 		"exec":        true,
+		"fetch":       true,
 	}
 }
 
@@ -58,6 +106,8 @@ func BridgeTools() []Tool {
 		grepTool(),
 		globTool(),
 		lsTool(),
+		moveTool(),
 		execTool(),
+		fetchTool(),
 	}
 }
@@ -4,23 +4,37 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/agusx1211/miclaw/logging"
 	"github.com/google/uuid"
 	_ "modernc.org/sqlite"
 )
 
+// HeartbeatSource is the input source of the heartbeat set with
+// SetHeartbeat, so callers can tell it from ordinary cron prompts.
+const HeartbeatSource = "heartbeat"
+
 const (
-	cronSource      = "cron"
-	defaultCronTick = time.Minute
-	cronTableSQL    = `CREATE TABLE IF NOT EXISTS cron_jobs (
+	cronSource         = "cron"
+	defaultCronTick    = time.Minute
+	defaultCronRefresh = 30 * time.Second
+	// cronRetryMin is the first delay before retrying a failed reload; it
+	// doubles up to the refresh interval.
+	cronRetryMin = time.Second
+	cronTableSQL = `CREATE TABLE IF NOT EXISTS cron_jobs (
 		id TEXT PRIMARY KEY,
 		expression TEXT NOT NULL,
 		prompt TEXT NOT NULL,
-		created_at DATETIME
+		created_at DATETIME,
+		run_at DATETIME,
+		timezone TEXT,
+		last_run DATETIME,
+		enabled INTEGER NOT NULL DEFAULT 1
 	)`
-	cronInsertSQL = `INSERT INTO cron_jobs (id, expression, prompt, created_at) VALUES (?, ?, ?, ?)`
+	cronInsertSQL = `INSERT INTO cron_jobs (id, expression, prompt, created_at, run_at, timezone) VALUES (?, ?, ?, ?, ?, ?)`
 )
 
 // Scheduler runs cron jobs and injects prompts through an inject callback.
@@ -31,22 +45,43 @@ type Scheduler struct {
 	stop context.CancelFunc
 	now  func() time.Time
 	tick time.Duration
+	// refresh is how often jobs are re-read from the database, picking up
+	// jobs added or removed by other processes.
+	refresh time.Duration
+	// defaultLoc evaluates jobs stored without a timezone.
+	defaultLoc *time.Location
+	// heartbeat is the configured heartbeat; it is kept out of the database
+	// and of ListJobs. nil when there is none.
+	heartbeat *scheduledJob
 }
 
+// scheduledJob is one recurring or one-shot job. A one-shot job has no
+// expression and is deleted once it fires. timezone is the IANA name the
+// job was added with, loaded into loc; empty follows the scheduler's
+// default and leaves loc nil. A paused job stays stored but never fires.
 type scheduledJob struct {
 	id         string
 	expression string
+	timezone   string
+	loc        *time.Location
 	prompt     string
 	expr       CronExpr
 	nextRun    time.Time
+	lastRun    time.Time
+	once       bool
+	paused     bool
 }
 
 // CronJob is a persisted cron job entry used by tool responses.
 type CronJob struct {
-	ID         string    `json:"id"`
-	Expression string    `json:"expression"`
-	Prompt     string    `json:"prompt"`
-	NextRun    time.Time `json:"next_run"`
+	ID         string     `json:"id"`
+	Expression string     `json:"expression"`
+	Timezone   string     `json:"timezone,omitempty"`
+	Prompt     string     `json:"prompt"`
+	NextRun    time.Time  `json:"next_run"`
+	LastRun    *time.Time `json:"last_run,omitempty"`
+	Once       bool       `json:"once,omitempty"`
+	Enabled    bool       `json:"enabled"`
 }
 
 func NewScheduler(dbPath string) (*Scheduler, error) {
@@ -58,7 +93,11 @@ func NewScheduler(dbPath string) (*Scheduler, error) {
 		_ = db.Close()
 		return nil, err
 	}
-	s := &Scheduler{db: db, jobs: map[string]scheduledJob{}, now: time.Now, tick: defaultCronTick}
+	if err := migrateCronTable(db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	s := &Scheduler{db: db, jobs: map[string]scheduledJob{}, now: time.Now, tick: defaultCronTick, refresh: defaultCronRefresh, defaultLoc: time.UTC}
 	if err := s.refreshJobs(); err != nil {
 		_ = s.Close()
 		return nil, err
@@ -70,6 +109,56 @@ func (s *Scheduler) Close() error {
 	return s.db.Close()
 }
 
+// SetRefreshInterval sets how often Start re-reads jobs from the database.
+func (s *Scheduler) SetRefreshInterval(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh = d
+}
+
+// SetHeartbeat fires prompt with source HeartbeatSource on expression,
+// evaluated in the default timezone. The heartbeat is not stored, listed or
+// removable through the cron tool. An empty expression turns it off.
+func (s *Scheduler) SetHeartbeat(expression, prompt string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if expression == "" {
+		s.heartbeat = nil
+		return nil
+	}
+	expr, err := ParseCronExpr(expression)
+	if err != nil {
+		return err
+	}
+	s.heartbeat = &scheduledJob{id: HeartbeatSource, expression: expression, prompt: prompt, expr: expr, nextRun: expr.NextAfter(s.now().In(s.defaultLoc))}
+	return nil
+}
+
+// SetDefaultTimezone sets the IANA zone for jobs added without one; empty
+// means UTC. Their next runs, and the heartbeat's, are recomputed in the
+// new zone.
+func (s *Scheduler) SetDefaultTimezone(name string) error {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("unknown timezone %q", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultLoc = loc
+	now := s.now()
+	for id, job := range s.jobs {
+		if job.once || job.loc != nil {
+			continue
+		}
+		job.nextRun = job.expr.NextAfter(now.In(loc))
+		s.jobs[id] = job
+	}
+	if s.heartbeat != nil {
+		s.heartbeat.nextRun = s.heartbeat.expr.NextAfter(now.In(loc))
+	}
+	return nil
+}
+
 func (s *Scheduler) Start(ctx context.Context, inject func(source, content string)) {
 	s.mu.Lock()
 	runCtx, cancel := context.WithCancel(ctx)
@@ -78,22 +167,46 @@ func (s *Scheduler) Start(ctx context.Context, inject func(source, content strin
 	if tick <= 0 {
 		tick = defaultCronTick
 	}
+	refresh := s.refresh
+	if refresh <= 0 {
+		refresh = defaultCronRefresh
+	}
 	s.mu.Unlock()
 
+	// Reloads run on their own timer, so a refresh interval shorter than
+	// the tick is honoured.
 	ticker := time.NewTicker(tick)
+	reload := time.NewTimer(refresh)
 	go func() {
 		defer ticker.Stop()
+		defer reload.Stop()
+		retry := cronRetryMin
 		for {
 			s.enqueueDue(inject)
 			select {
 			case <-runCtx.Done():
 				return
 			case <-ticker.C:
+			case <-reload.C:
+				var wait time.Duration
+				wait, retry = s.reloadStep(refresh, retry)
+				reload.Reset(wait)
 			}
 		}
 	}()
 }
 
+// reloadStep re-reads jobs and returns how long to wait before the next
+// reload. A failure is logged and retried with a doubling delay capped at
+// refresh.
+func (s *Scheduler) reloadStep(refresh, retry time.Duration) (time.Duration, time.Duration) {
+	if err := s.refreshJobs(); err != nil {
+		logging.Errorf("[cron] reload failed: %v (retry in %s)", err, retry)
+		return retry, min(retry*2, refresh)
+	}
+	return refresh, cronRetryMin
+}
+
 func (s *Scheduler) Stop() {
 	s.mu.Lock()
 	stop := s.stop
@@ -104,22 +217,109 @@ func (s *Scheduler) Stop() {
 	}
 }
 
-func (s *Scheduler) AddJob(expression, prompt string) (string, error) {
+// AddJob schedules prompt on a cron expression evaluated in timezone, an
+// IANA name; empty uses the scheduler's default timezone.
+func (s *Scheduler) AddJob(expression, timezone, prompt string) (string, error) {
 	expr, err := ParseCronExpr(expression)
 	if err != nil {
 		return "", err
 	}
+	loc, err := location(timezone)
+	if err != nil {
+		return "", err
+	}
+	id := uuid.NewString()
+	if _, err := s.db.Exec(cronInsertSQL, id, expression, prompt, s.now().UTC(), nil, timezone); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	job := scheduledJob{id: id, expression: expression, timezone: timezone, loc: loc, prompt: prompt, expr: expr}
+	job.nextRun = expr.NextAfter(s.now().In(s.jobLocation(job)))
+	s.jobs[id] = job
+	s.mu.Unlock()
+	return id, nil
+}
+
+// location loads an IANA timezone name; empty returns nil, meaning the
+// scheduler's default.
+func location(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", timezone)
+	}
+	return loc, nil
+}
+
+// jobLocation is the zone job is evaluated in. Callers hold s.mu.
+func (s *Scheduler) jobLocation(job scheduledJob) *time.Location {
+	if job.loc != nil {
+		return job.loc
+	}
+	return s.defaultLoc
+}
+
+// AddOnceJob schedules content to be injected once at runAt. The job is
+// deleted after it fires; a runAt already in the past fires on the next
+// tick.
+func (s *Scheduler) AddOnceJob(runAt time.Time, content string) (string, error) {
+	if runAt.IsZero() {
+		return "", fmt.Errorf("run_at is required")
+	}
 	id := uuid.NewString()
-	nextRun := expr.NextAfter(s.now().UTC())
-	if _, err := s.db.Exec(cronInsertSQL, id, expression, prompt, s.now().UTC()); err != nil {
+	runAt = runAt.UTC()
+	if _, err := s.db.Exec(cronInsertSQL, id, "", content, s.now().UTC(), runAt, ""); err != nil {
 		return "", err
 	}
 	s.mu.Lock()
-	s.jobs[id] = scheduledJob{id: id, expression: expression, prompt: prompt, expr: expr, nextRun: nextRun}
+	s.jobs[id] = scheduledJob{id: id, prompt: content, nextRun: runAt, once: true}
 	s.mu.Unlock()
 	return id, nil
 }
 
+// PauseJob stops job id from firing until ResumeJob, keeping its
+// definition.
+func (s *Scheduler) PauseJob(id string) error {
+	return s.setPaused(id, true)
+}
+
+// ResumeJob lets a paused job fire again. A recurring job resumes at its
+// next run after now, skipping the runs it missed while paused; an overdue
+// one-shot job fires on the next tick.
+func (s *Scheduler) ResumeJob(id string) error {
+	return s.setPaused(id, false)
+}
+
+func (s *Scheduler) setPaused(id string, paused bool) error {
+	res, err := s.db.Exec(`UPDATE cron_jobs SET enabled = ? WHERE id = ?`, !paused, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("cron job %s not found", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.jobs[id]; ok {
+		s.jobs[id] = s.withPaused(job, paused)
+	}
+	return nil
+}
+
+// withPaused returns job with paused set, moving a resumed recurring job's
+// next run past now. Callers hold s.mu.
+func (s *Scheduler) withPaused(job scheduledJob, paused bool) scheduledJob {
+	if job.paused && !paused && !job.once {
+		job.nextRun = job.expr.NextAfter(s.now().In(s.jobLocation(job)))
+	}
+	job.paused = paused
+	return job
+}
+
 func (s *Scheduler) RemoveJob(id string) error {
 	if _, err := s.db.Exec(`DELETE FROM cron_jobs WHERE id = ?`, id); err != nil {
 		return err
@@ -130,13 +330,25 @@ func (s *Scheduler) RemoveJob(id string) error {
 	return nil
 }
 
+// ListJobs returns the jobs ordered by next run.
 func (s *Scheduler) ListJobs() ([]CronJob, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	jobs := make([]CronJob, 0, len(s.jobs))
 	for _, job := range s.jobs {
-		jobs = append(jobs, CronJob{ID: job.id, Expression: job.expression, Prompt: job.prompt, NextRun: job.nextRun})
+		j := CronJob{ID: job.id, Expression: job.expression, Timezone: job.timezone, Prompt: job.prompt, NextRun: job.nextRun, Once: job.once, Enabled: !job.paused}
+		if !job.lastRun.IsZero() {
+			last := job.lastRun
+			j.LastRun = &last
+		}
+		jobs = append(jobs, j)
 	}
+	sort.Slice(jobs, func(i, k int) bool {
+		if !jobs[i].NextRun.Equal(jobs[k].NextRun) {
+			return jobs[i].NextRun.Before(jobs[k].NextRun)
+		}
+		return jobs[i].ID < jobs[k].ID
+	})
 	return jobs, nil
 }
 
@@ -145,7 +357,10 @@ func (s *Scheduler) NextRun(expression string) (time.Time, error) {
 	if err != nil {
 		return time.Time{}, err
 	}
-	return expr.NextAfter(s.now()), nil
+	s.mu.Lock()
+	loc := s.defaultLoc
+	s.mu.Unlock()
+	return expr.NextAfter(s.now().In(loc)), nil
 }
 
 func (s *Scheduler) enqueueDue(inject func(source, content string)) {
@@ -153,35 +368,115 @@ func (s *Scheduler) enqueueDue(inject func(source, content string)) {
 	defer s.mu.Unlock()
 	now := s.now().UTC()
 	for id, job := range s.jobs {
-		if now.Before(job.nextRun) {
+		if job.paused || now.Before(job.nextRun) {
+			continue
+		}
+		if job.once {
+			// Delete before injecting so a failed delete retries on the next
+			// tick instead of firing twice.
+			if _, err := s.db.Exec(`DELETE FROM cron_jobs WHERE id = ?`, id); err != nil {
+				logging.Errorf("[cron] remove one-shot job %s failed: %v", id, err)
+				continue
+			}
+			delete(s.jobs, id)
+			inject(cronSource, job.prompt)
 			continue
 		}
 		inject(cronSource, job.prompt)
-		job.nextRun = job.expr.NextAfter(now)
+		if _, err := s.db.Exec(`UPDATE cron_jobs SET last_run = ? WHERE id = ?`, now, id); err != nil {
+			logging.Errorf("[cron] record last run of job %s failed: %v", id, err)
+		}
+		job.lastRun = now
+		job.nextRun = job.expr.NextAfter(now.In(s.jobLocation(job)))
 		s.jobs[id] = job
 	}
+	if hb := s.heartbeat; hb != nil && !now.Before(hb.nextRun) {
+		inject(HeartbeatSource, hb.prompt)
+		hb.lastRun = now
+		hb.nextRun = hb.expr.NextAfter(now.In(s.defaultLoc))
+	}
 }
 
+// refreshJobs syncs the in-memory jobs with the database. Known jobs keep
+// their next run so a reload never re-fires or skips them.
 func (s *Scheduler) refreshJobs() error {
-	rows, err := s.db.Query(`SELECT id, expression, prompt FROM cron_jobs ORDER BY id`)
+	stored, err := s.loadJobs()
 	if err != nil {
 		return err
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, job := range stored {
+		if cur, ok := s.jobs[id]; ok {
+			job.nextRun = cur.nextRun
+			if cur.lastRun.After(job.lastRun) {
+				job.lastRun = cur.lastRun
+			}
+			paused := job.paused
+			job.paused = cur.paused
+			job = s.withPaused(job, paused)
+		} else if !job.once {
+			job.nextRun = job.expr.NextAfter(s.now().In(s.jobLocation(job)))
+		}
+		s.jobs[id] = job
+	}
+	for id := range s.jobs {
+		if _, ok := stored[id]; !ok {
+			delete(s.jobs, id)
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) loadJobs() (map[string]scheduledJob, error) {
+	rows, err := s.db.Query(`SELECT id, expression, prompt, run_at, coalesce(timezone, ''), last_run, enabled FROM cron_jobs ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
+	jobs := map[string]scheduledJob{}
 	for rows.Next() {
-		var id, expression, prompt string
-		if err := rows.Scan(&id, &expression, &prompt); err != nil {
-			return err
+		var id, expression, prompt, timezone string
+		var runAt, lastRun sql.NullTime
+		var enabled bool
+		if err := rows.Scan(&id, &expression, &prompt, &runAt, &timezone, &lastRun, &enabled); err != nil {
+			return nil, err
+		}
+		if runAt.Valid {
+			jobs[id] = scheduledJob{id: id, prompt: prompt, nextRun: runAt.Time.UTC(), once: true, paused: !enabled}
+			continue
 		}
 		expr, err := ParseCronExpr(expression)
 		if err != nil {
-			return fmt.Errorf("invalid cron expression %q: %w", expression, err)
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expression, err)
 		}
-		s.jobs[id] = scheduledJob{id: id, expression: expression, prompt: prompt, expr: expr, nextRun: expr.NextAfter(s.now().UTC())}
+		loc, err := location(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("cron job %s: %w", id, err)
+		}
+		jobs[id] = scheduledJob{id: id, expression: expression, timezone: timezone, loc: loc, prompt: prompt, expr: expr, lastRun: lastRun.Time.UTC(), paused: !enabled}
 	}
 	if err := rows.Err(); err != nil {
-		return err
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// migrateCronTable adds the columns missing from databases created before
+// one-shot jobs, per-job timezones, last-run tracking and pausing existed.
+func migrateCronTable(db *sql.DB) error {
+	for _, col := range []struct{ name, decl string }{{"run_at", "DATETIME"}, {"timezone", "TEXT"}, {"last_run", "DATETIME"}, {"enabled", "INTEGER NOT NULL DEFAULT 1"}} {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('cron_jobs') WHERE name = ?`, col.name).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE cron_jobs ADD COLUMN ` + col.name + ` ` + col.decl); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SecretsFile is the file under state_path that exec env_from names are read
+// from. It sits outside the workspace so the file tools and the sandbox can
+// never read it. It uses .env syntax: KEY=VALUE lines, # comments, optional
+// export prefix and optional matching quotes around the value.
+const SecretsFile = "secrets.env"
+
+func loadSecrets(path string) (map[string]string, error) {
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	secrets := map[string]string{}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("%s line %d: want KEY=VALUE", path, n)
+		}
+		secrets[key] = unquoteSecret(strings.TrimSpace(value))
+	}
+
+	return secrets, sc.Err()
+}
+
+func unquoteSecret(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// secretEnv resolves names against the secrets file at path. Errors list the
+// available names but never values.
+func secretEnv(path string, names []string) (map[string]string, error) {
+
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if path == "" {
+		return nil, errors.New("exec env_from is not available here")
+	}
+	all, err := loadSecrets(path)
+	if err != nil {
+		return nil, fmt.Errorf("load secrets: %v", err)
+	}
+	env := make(map[string]string, len(names))
+	for _, name := range names {
+		value, ok := all[name]
+		if !ok {
+			known := make([]string, 0, len(all))
+			for k := range all {
+				known = append(known, k)
+			}
+			slices.Sort(known)
+			return nil, fmt.Errorf("secret %q is not defined in %s (available: %s)", name, path, strings.Join(known, ", "))
+		}
+		env[name] = value
+	}
+
+	return env, nil
+}
+
+// ResolveExecSecrets moves the env_from names of an exec call into its env,
+// read from the secrets file at path, and returns the rewritten parameters
+// with the values used. It lets the sandbox bridge resolve secrets on the
+// host, where the file lives, before the call enters the container; the
+// caller redacts the result with RedactSecrets.
+func ResolveExecSecrets(path string, raw json.RawMessage) (json.RawMessage, map[string]string, error) {
+
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, nil, fmt.Errorf("parse exec parameters: %v", err)
+	}
+	var names []string
+	if v, ok := params["env_from"]; ok {
+		if err := json.Unmarshal(v, &names); err != nil {
+			return nil, nil, fmt.Errorf("parse exec env_from: %v", err)
+		}
+	}
+	if len(names) == 0 {
+		return raw, nil, nil
+	}
+	secrets, err := secretEnv(path, names)
+	if err != nil {
+		return nil, nil, err
+	}
+	env := map[string]string{}
+	if v, ok := params["env"]; ok {
+		if err := json.Unmarshal(v, &env); err != nil {
+			return nil, nil, fmt.Errorf("parse exec env: %v", err)
+		}
+	}
+	maps.Copy(env, secrets)
+	encoded, err := json.Marshal(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	params["env"] = encoded
+	delete(params, "env_from")
+	out, err := json.Marshal(params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out, secrets, nil
+}
+
+// RedactSecrets replaces every secret value in text with [secret:NAME],
+// longest values first so overlapping values are fully hidden.
+func RedactSecrets(text string, secrets map[string]string) string {
+
+	names := make([]string, 0, len(secrets))
+	for name, value := range secrets {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return len(secrets[names[i]]) > len(secrets[names[j]]) })
+	for _, name := range names {
+		text = strings.ReplaceAll(text, secrets[name], "[secret:"+name+"]")
+	}
+
+	return text
+}
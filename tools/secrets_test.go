@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+)
+
+func writeSecrets(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), SecretsFile)
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("write secrets: %v", err)
+	}
+	return path
+}
+
+func runExecWithSecrets(t *testing.T, secrets string, params map[string]any) ToolResult {
+	t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("marshal exec params: %v", err)
+	}
+	got, err := execToolWithSandbox(config.SandboxConfig{}, secrets, nil).Run(context.Background(), model.ToolCallPart{
+		ID:         "1",
+		Name:       "exec",
+		Parameters: raw,
+	})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	return got
+}
+
+func TestExecEnvFromInjectsSecretWithoutLeakingIt(t *testing.T) {
+	const secret = "s3cr3t-value-123"
+	secrets := writeSecrets(t, "# tokens\nexport MY_TOKEN=\""+secret+"\"\nOTHER=x\n")
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	got := runExecWithSecrets(t, secrets, map[string]any{
+		"command":  `test "$MY_TOKEN" = "` + secret + `" && echo matched && echo "$MY_TOKEN"`,
+		"env_from": []string{"MY_TOKEN"},
+	})
+	if got.IsError {
+		t.Fatalf("unexpected tool error: %s", got.Content)
+	}
+	if !strings.Contains(got.Content, "matched") {
+		t.Fatalf("secret was not available to the process: %q", got.Content)
+	}
+	if strings.Contains(got.Content, secret) {
+		t.Fatalf("secret leaked into tool result: %q", got.Content)
+	}
+	if !strings.Contains(got.Content, "[secret:MY_TOKEN]") {
+		t.Fatalf("missing redaction marker: %q", got.Content)
+	}
+	if strings.Contains(logs.String(), secret) {
+		t.Fatalf("secret leaked into logs: %q", logs.String())
+	}
+}
+
+func TestExecEnvFromUnknownNameListsOnlyNames(t *testing.T) {
+	secrets := writeSecrets(t, "A_KEY=alpha-value\nB_KEY=beta-value\n")
+	got := runExecWithSecrets(t, secrets, map[string]any{
+		"command":  "true",
+		"env_from": []string{"MISSING"},
+	})
+	if !got.IsError || !strings.Contains(got.Content, "A_KEY, B_KEY") {
+		t.Fatalf("want missing-secret error with names, got %q", got.Content)
+	}
+	if strings.Contains(got.Content, "alpha-value") || strings.Contains(got.Content, "beta-value") {
+		t.Fatalf("error leaked secret values: %q", got.Content)
+	}
+}
+
+func TestExecEnvFromRejectsBackground(t *testing.T) {
+	secrets := writeSecrets(t, "A=1\n")
+	got := runExecWithSecrets(t, secrets, map[string]any{
+		"command":    "true",
+		"env_from":   []string{"A"},
+		"background": true,
+	})
+	if !got.IsError {
+		t.Fatalf("want background rejection, got %q", got.Content)
+	}
+}
+
+func TestLoadSecretsParsesEnvSyntax(t *testing.T) {
+	got, err := loadSecrets(writeSecrets(t, "\n# c\nA=1\nexport B='two words'\nC = \"q\"\nD=\n"))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := map[string]string{"A": "1", "B": "two words", "C": "q", "D": ""}
+	if len(got) != len(want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("%s: want %q, got %q", k, v, got[k])
+		}
+	}
+	if _, err := loadSecrets(filepath.Join(t.TempDir(), SecretsFile)); err != nil {
+		t.Fatalf("missing file should load empty: %v", err)
+	}
+	if _, err := loadSecrets(writeSecrets(t, "NOEQUALS\n")); err == nil {
+		t.Fatal("want parse error")
+	}
+}
+
+func TestRedactSecretsPrefersLongestValue(t *testing.T) {
+	got := RedactSecrets("tok=abcdef", map[string]string{"SHORT": "abc", "LONG": "abcdef", "EMPTY": ""})
+	if got != "tok=[secret:LONG]" {
+		t.Fatalf("unexpected redaction: %q", got)
+	}
+}
+
+func TestExecEnvFromIgnoresWorkspaceFile(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workspace, ".env"), []byte("A=1\n"), 0o600); err != nil {
+		t.Fatalf("write workspace env: %v", err)
+	}
+	t.Chdir(workspace)
+	got := runExecWithSecrets(t, "", map[string]any{
+		"command":  "true",
+		"env_from": []string{"A"},
+	})
+	if !got.IsError {
+		t.Fatalf("want env_from to be unavailable without a secrets file, got %q", got.Content)
+	}
+}
+
+func TestResolveExecSecretsMovesNamesIntoEnv(t *testing.T) {
+	path := writeSecrets(t, "TOKEN=abc123\n")
+	raw, secrets, err := ResolveExecSecrets(path, json.RawMessage(`{"command":"env","env":{"X":"1"},"env_from":["TOKEN"]}`))
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	var got struct {
+		Env     map[string]string `json:"env"`
+		EnvFrom []string          `json:"env_from"`
+	}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Env["X"] != "1" || got.Env["TOKEN"] != "abc123" || got.EnvFrom != nil {
+		t.Fatalf("unexpected parameters: %s", raw)
+	}
+	if secrets["TOKEN"] != "abc123" {
+		t.Fatalf("unexpected secrets: %v", secrets)
+	}
+	if _, _, err := ResolveExecSecrets(path, json.RawMessage(`{"command":"env","env_from":["NOPE"]}`)); err == nil {
+		t.Fatal("want error for an undefined name")
+	}
+}
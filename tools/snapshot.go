@@ -0,0 +1,294 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+)
+
+const snapshotIDLayout = "20060102-150405.000000"
+
+// snapshots copies the workspace into timestamped directories under dir and
+// restores it from them. Only the newest keep snapshots are kept, and a
+// workspace larger than maxBytes is not copied.
+type snapshots struct {
+	workspace string
+	dir       string
+	maxBytes  int64
+	keep      int
+	now       func() time.Time
+}
+
+func newSnapshots(workspace, dir string, cfg config.SnapshotConfig) *snapshots {
+	return &snapshots{
+		workspace: workspace,
+		dir:       dir,
+		maxBytes:  int64(cfg.MaxMB) << 20,
+		keep:      cfg.Keep,
+		now:       time.Now,
+	}
+}
+
+// take copies the workspace into a new snapshot and returns its ID and how
+// many files and bytes it holds. The copy is written under a temporary name
+// so a failed snapshot never shows up in list.
+func (s *snapshots) take() (string, int, int64, error) {
+
+	files, size, err := treeSize(s.workspace, s.dir)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("scan workspace: %v", err)
+	}
+	if s.maxBytes > 0 && size > s.maxBytes {
+		return "", 0, 0, fmt.Errorf("workspace is %d bytes, over the snapshot limit of %d", size, s.maxBytes)
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return "", 0, 0, err
+	}
+	id := s.now().UTC().Format(snapshotIDLayout)
+	tmp, err := os.MkdirTemp(s.dir, ".tmp-")
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if err := copyTree(s.workspace, tmp, s.dir); err != nil {
+		_ = os.RemoveAll(tmp)
+		return "", 0, 0, fmt.Errorf("copy workspace: %v", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, id)); err != nil {
+		_ = os.RemoveAll(tmp)
+		return "", 0, 0, err
+	}
+	return id, files, size, s.prune()
+}
+
+// list returns the snapshot IDs, oldest first.
+func (s *snapshots) list() ([]string, error) {
+
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			ids = append(ids, e.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *snapshots) prune() error {
+
+	ids, err := s.list()
+	if err != nil || len(ids) <= s.keep {
+		return err
+	}
+	for _, id := range ids[:len(ids)-s.keep] {
+		if err := os.RemoveAll(filepath.Join(s.dir, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore replaces the workspace contents with snapshot id, or with the
+// newest snapshot when id is empty, and returns the ID it restored.
+func (s *snapshots) restore(id string) (string, error) {
+
+	ids, err := s.list()
+	if err != nil {
+		return "", err
+	}
+	if len(ids) == 0 {
+		return "", errors.New("no snapshots to roll back to")
+	}
+	if id == "" {
+		id = ids[len(ids)-1]
+	}
+	if i := sort.SearchStrings(ids, id); i == len(ids) || ids[i] != id {
+		return "", fmt.Errorf("unknown snapshot %q (have %s)", id, strings.Join(ids, ", "))
+	}
+	entries, err := os.ReadDir(s.workspace)
+	if err != nil {
+		return "", err
+	}
+	var current []string
+	for _, e := range entries {
+		if filepath.Join(s.workspace, e.Name()) != s.dir {
+			current = append(current, e.Name())
+		}
+	}
+	// The snapshot is copied next to the workspace entries first, so a
+	// failed copy leaves the workspace untouched; the swap is then only
+	// renames on one filesystem.
+	staged, err := os.MkdirTemp(s.workspace, ".rollback-new-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(staged)
+	if err := copyTree(filepath.Join(s.dir, id), staged, ""); err != nil {
+		return "", fmt.Errorf("restore snapshot %s: %v", id, err)
+	}
+	aside, err := os.MkdirTemp(s.workspace, ".rollback-old-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(aside)
+	if err := swapEntries(s.workspace, staged, aside, current); err != nil {
+		return "", fmt.Errorf("restore snapshot %s: %v", id, err)
+	}
+	return id, nil
+}
+
+// swapEntries moves the names entries of dir into aside and every entry of
+// staged into dir. If a rename fails, the moves done so far are undone, so
+// dir keeps its old contents.
+func swapEntries(dir, staged, aside string, names []string) error {
+
+	incoming, err := os.ReadDir(staged)
+	if err != nil {
+		return err
+	}
+	var movedOut, movedIn []string
+	undo := func() {
+		for _, name := range movedIn {
+			_ = os.Rename(filepath.Join(dir, name), filepath.Join(staged, name))
+		}
+		for _, name := range movedOut {
+			_ = os.Rename(filepath.Join(aside, name), filepath.Join(dir, name))
+		}
+	}
+	for _, name := range names {
+		if err := os.Rename(filepath.Join(dir, name), filepath.Join(aside, name)); err != nil {
+			undo()
+			return err
+		}
+		movedOut = append(movedOut, name)
+	}
+	for _, e := range incoming {
+		if err := os.Rename(filepath.Join(staged, e.Name()), filepath.Join(dir, e.Name())); err != nil {
+			undo()
+			return err
+		}
+		movedIn = append(movedIn, e.Name())
+	}
+	return nil
+}
+
+// treeSize counts the regular files under root and their total size,
+// skipping the skip directory.
+func treeSize(root, skip string) (int, int64, error) {
+
+	files, size := 0, int64(0)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path == skip {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files++
+		size += info.Size()
+		return nil
+	})
+	return files, size, err
+}
+
+// copyTree copies the files, directories and symlinks under src into dst,
+// skipping the skip directory.
+func copyTree(src, dst, skip string) error {
+
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path == skip {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyFile(path, target)
+		}
+		return nil
+	})
+}
+
+func snapshotTool(s *snapshots) Tool {
+	return tool{
+		name:   "snapshot",
+		serial: true,
+		desc:   "Copy the whole workspace to a timestamped snapshot you can restore with rollback; take one before risky or destructive changes",
+		params: JSONSchema{Type: "object", Properties: map[string]JSONSchema{}},
+		runFn: func(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
+			id, files, size, err := s.take()
+			if err != nil {
+				return ToolResult{}, fmt.Errorf("snapshot: %v", err)
+			}
+			return ToolResult{Content: fmt.Sprintf("snapshot %s (%d files, %d bytes)", id, files, size)}, nil
+		},
+	}
+}
+
+func rollbackTool(s *snapshots) Tool {
+	return tool{
+		name:   "rollback",
+		serial: true,
+		desc:   "Replace the whole workspace with a snapshot, discarding every change made since it was taken",
+		params: JSONSchema{
+			Type: "object",
+			Properties: map[string]JSONSchema{
+				"id": {Type: "string", Desc: "Snapshot ID returned by snapshot; omit for the newest"},
+			},
+		},
+		runFn: func(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
+			var input struct {
+				ID string `json:"id"`
+			}
+			if err := json.Unmarshal(call.Parameters, &input); err != nil {
+				return ToolResult{}, fmt.Errorf("parse rollback parameters: %v", err)
+			}
+			id, err := s.restore(strings.TrimSpace(input.ID))
+			if err != nil {
+				return ToolResult{}, fmt.Errorf("rollback: %v", err)
+			}
+			return ToolResult{Content: "restored snapshot " + id}, nil
+		},
+	}
+}
@@ -0,0 +1,158 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/model"
+)
+
+func newTestSnapshots(t *testing.T, cfg config.SnapshotConfig) (*snapshots, string) {
+	t.Helper()
+	ws := t.TempDir()
+	s := newSnapshots(ws, filepath.Join(t.TempDir(), "snapshots"), cfg)
+	at := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { at = at.Add(time.Second); return at }
+	return s, ws
+}
+
+func runSnapshotTool(t *testing.T, tl Tool, params map[string]any) ToolResult {
+	t.Helper()
+	raw, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := tl.Run(context.Background(), model.ToolCallPart{Name: tl.Name(), Parameters: raw})
+	if err != nil {
+		t.Fatalf("%s: %v", tl.Name(), err)
+	}
+	return res
+}
+
+func writeWorkspaceFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readWorkspaceFile(t *testing.T, ws, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(ws, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSnapshotRollbackRestoresWorkspace(t *testing.T) {
+	s, ws := newTestSnapshots(t, config.SnapshotConfig{MaxMB: 1, Keep: 5})
+	writeWorkspaceFile(t, filepath.Join(ws, "notes.md"), "original")
+	writeWorkspaceFile(t, filepath.Join(ws, "src", "main.go"), "package main")
+	if err := os.Symlink("notes.md", filepath.Join(ws, "link.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	res := runSnapshotTool(t, snapshotTool(s), map[string]any{})
+	if res.Content != "snapshot 20261016-090001.000000 (2 files, 20 bytes)" {
+		t.Fatalf("unexpected snapshot result: %q", res.Content)
+	}
+	writeWorkspaceFile(t, filepath.Join(ws, "notes.md"), "edited")
+	writeWorkspaceFile(t, filepath.Join(ws, "scratch.txt"), "new")
+	if err := os.RemoveAll(filepath.Join(ws, "src")); err != nil {
+		t.Fatal(err)
+	}
+
+	res = runSnapshotTool(t, rollbackTool(s), map[string]any{})
+	if res.Content != "restored snapshot 20261016-090001.000000" {
+		t.Fatalf("unexpected rollback result: %q", res.Content)
+	}
+	if got := readWorkspaceFile(t, ws, "notes.md"); got != "original" {
+		t.Fatalf("modified file not restored: %q", got)
+	}
+	if got := readWorkspaceFile(t, ws, "src/main.go"); got != "package main" {
+		t.Fatalf("deleted file not restored: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(ws, "scratch.txt")); !os.IsNotExist(err) {
+		t.Fatalf("file added after the snapshot survived rollback: %v", err)
+	}
+	if link, err := os.Readlink(filepath.Join(ws, "link.md")); err != nil || link != "notes.md" {
+		t.Fatalf("symlink not restored: %q (%v)", link, err)
+	}
+	entries, err := os.ReadDir(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".rollback-") {
+			t.Fatalf("rollback left %s behind", e.Name())
+		}
+	}
+}
+
+func TestSwapEntriesUndoesFailedSwap(t *testing.T) {
+	dir, staged, aside := t.TempDir(), t.TempDir(), t.TempDir()
+	writeWorkspaceFile(t, filepath.Join(dir, "notes.md"), "current")
+	writeWorkspaceFile(t, filepath.Join(dir, "zbusy", "file"), "in the way")
+	writeWorkspaceFile(t, filepath.Join(staged, "notes.md"), "snapshot")
+	// A file cannot replace a non-empty directory, so this rename fails.
+	writeWorkspaceFile(t, filepath.Join(staged, "zbusy"), "snapshot")
+
+	if err := swapEntries(dir, staged, aside, []string{"notes.md"}); err == nil {
+		t.Fatal("expected the swap to fail")
+	}
+	if got := readWorkspaceFile(t, dir, "notes.md"); got != "current" {
+		t.Fatalf("failed swap changed the workspace: %q", got)
+	}
+	if got := readWorkspaceFile(t, staged, "notes.md"); got != "snapshot" {
+		t.Fatalf("staged copy not put back: %q", got)
+	}
+}
+
+func TestSnapshotRespectsSizeLimitAndKeep(t *testing.T) {
+	s, ws := newTestSnapshots(t, config.SnapshotConfig{MaxMB: 1, Keep: 2})
+	writeWorkspaceFile(t, filepath.Join(ws, "a.txt"), "a")
+	for range 3 {
+		if _, _, _, err := s.take(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids, err := s.list()
+	if err != nil || len(ids) != 2 || ids[0] != "20261016-090002.000000" {
+		t.Fatalf("expected the newest 2 snapshots kept, got %v (%v)", ids, err)
+	}
+
+	writeWorkspaceFile(t, filepath.Join(ws, "big.bin"), strings.Repeat("x", 1<<20))
+	if _, _, _, err := s.take(); err == nil || !strings.Contains(err.Error(), "over the snapshot limit") {
+		t.Fatalf("expected size limit error, got %v", err)
+	}
+	if _, err := s.restore("20261016-000000.000000"); err == nil || !strings.Contains(err.Error(), "unknown snapshot") {
+		t.Fatalf("expected unknown snapshot error, got %v", err)
+	}
+}
+
+func TestDeleteAutoSnapshotAllowsRollback(t *testing.T) {
+	s, ws := newTestSnapshots(t, config.SnapshotConfig{MaxMB: 1, Keep: 5, Auto: true})
+	writeWorkspaceFile(t, filepath.Join(ws, "build", "out.txt"), "artifact")
+
+	del := deleteTool(ws, 0, s, true)
+	res := runSnapshotTool(t, del, map[string]any{"path": "build", "recursive": true})
+	if !strings.Contains(res.Content, "(snapshot 20261016-090001.000000 taken first)") {
+		t.Fatalf("expected snapshot note, got %q", res.Content)
+	}
+	if _, err := s.restore(""); err != nil {
+		t.Fatal(err)
+	}
+	if got := readWorkspaceFile(t, ws, "build/out.txt"); got != "artifact" {
+		t.Fatalf("deleted directory not restored: %q", got)
+	}
+}
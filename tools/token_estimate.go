@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/provider"
+)
+
+// tokenEstimateCharsPerToken is the rough ratio used without a tokenizer.
+const tokenEstimateCharsPerToken = 4
+
+type tokenEstimateParams struct {
+	Text string `json:"text"`
+	Path string `json:"path"`
+}
+
+func tokenEstimateTool(workspace string, info provider.ModelInfo) Tool {
+
+	return tool{
+		name: "token_estimate",
+		desc: "Estimate the token count and input cost of text or a file before sending it to the model",
+		params: JSONSchema{
+			Type: "object",
+			Properties: map[string]JSONSchema{
+				"text": {Type: "string", Desc: "text to measure"},
+				"path": {Type: "string", Desc: "workspace file to measure instead of text"},
+			},
+		},
+		runFn: func(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
+			return runTokenEstimate(workspace, info, call)
+		},
+	}
+}
+
+func runTokenEstimate(workspace string, info provider.ModelInfo, call model.ToolCallPart) (ToolResult, error) {
+
+	text, err := tokenEstimateInput(workspace, call.Parameters)
+	if err != nil {
+		return ToolResult{Content: err.Error(), IsError: true}, nil
+	}
+	tokens := (len(text) + tokenEstimateCharsPerToken - 1) / tokenEstimateCharsPerToken
+	lines := []string{
+		fmt.Sprintf("chars: %d", len(text)),
+		fmt.Sprintf("tokens: ~%d", tokens),
+	}
+	if info.CostPerInputToken > 0 {
+		lines = append(lines, fmt.Sprintf(
+			"cost: ~$%.6f at $%.2f per million input tokens (%s)",
+			float64(tokens)*info.CostPerInputToken,
+			info.CostPerInputToken*1_000_000,
+			info.Name,
+		))
+	} else {
+		lines = append(lines, "cost: unknown (set provider.input_cost_per_mtok)")
+	}
+	return ToolResult{Content: strings.Join(lines, "\n")}, nil
+}
+
+// tokenEstimateInput returns the text to measure: the text parameter or the
+// contents of path, exactly one of which must be set. The tool runs on the
+// host even when the file tools run in the sandbox, so path is confined to
+// workspace, symlinks included.
+func tokenEstimateInput(workspace string, raw json.RawMessage) (string, error) {
+
+	var params tokenEstimateParams
+	if err := unmarshalObject(raw, &params); err != nil {
+		return "", err
+	}
+	if (params.Text == "") == (params.Path == "") {
+		return "", errors.New("provide exactly one of text or path")
+	}
+	if params.Text != "" {
+		return params.Text, nil
+	}
+	path, err := resolveWorkspacePath(workspace, params.Path)
+	if err != nil {
+		return "", err
+	}
+	if path, err = filepath.EvalSymlinks(path); err != nil {
+		return "", err
+	}
+	if path, err = resolveWorkspacePath(workspace, path); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/provider"
+)
+
+func TestTokenEstimateScalesWithLength(t *testing.T) {
+	tl := tokenEstimateTool("", provider.ModelInfo{Name: "m", CostPerInputToken: 3.0 / 1_000_000})
+	short, err := runTool(t, tl, map[string]any{"text": strings.Repeat("a", 400)})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	long, err := runTool(t, tl, map[string]any{"text": strings.Repeat("a", 4000)})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	if !strings.Contains(short.Content, "tokens: ~100") || !strings.Contains(long.Content, "tokens: ~1000") {
+		t.Fatalf("unexpected estimates: %q / %q", short.Content, long.Content)
+	}
+	if !strings.Contains(short.Content, "cost: ~$0.000300 at $3.00 per million input tokens (m)") {
+		t.Fatalf("unexpected short cost: %q", short.Content)
+	}
+	if !strings.Contains(long.Content, "cost: ~$0.003000") {
+		t.Fatalf("unexpected long cost: %q", long.Content)
+	}
+}
+
+func TestTokenEstimateReadsPath(t *testing.T) {
+	ws := t.TempDir()
+	if err := os.WriteFile(filepath.Join(ws, "doc.md"), []byte(strings.Repeat("b", 81)), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	got, err := runTool(t, tokenEstimateTool(ws, provider.ModelInfo{}), map[string]any{"path": "doc.md"})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	if got.IsError || got.Content != "chars: 81\ntokens: ~21\ncost: unknown (set provider.input_cost_per_mtok)" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+}
+
+func TestTokenEstimateNeedsExactlyOneInput(t *testing.T) {
+	for _, args := range []map[string]any{{}, {"text": "x", "path": "y"}} {
+		got, err := runTool(t, tokenEstimateTool(t.TempDir(), provider.ModelInfo{}), args)
+		if err != nil {
+			t.Fatalf("tool call: %v", err)
+		}
+		if !got.IsError {
+			t.Fatalf("expected error for %v, got %q", args, got.Content)
+		}
+	}
+}
+
+func TestTokenEstimateStaysInWorkspace(t *testing.T) {
+	ws, outside := t.TempDir(), t.TempDir()
+	secret := filepath.Join(outside, "secret.txt")
+	if err := os.WriteFile(secret, []byte("hunter2"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := os.Symlink(secret, filepath.Join(ws, "link.txt")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	for _, path := range []string{secret, "../" + filepath.Base(outside) + "/secret.txt", "link.txt"} {
+		got, err := runTool(t, tokenEstimateTool(ws, provider.ModelInfo{}), map[string]any{"path": path})
+		if err != nil {
+			t.Fatalf("tool call: %v", err)
+		}
+		if !got.IsError || !strings.Contains(got.Content, "outside the workspace") {
+			t.Fatalf("%s: expected an outside-the-workspace error, got %+v", path, got)
+		}
+	}
+}
@@ -17,11 +17,15 @@ type tool struct {
 	desc   string
 	params JSONSchema
 	runFn  func(ctx context.Context, call model.ToolCallPart) (ToolResult, error)
+	// serial keeps calls of this tool from overlapping with other calls of
+	// the same turn; set it on tools that change state.
+	serial bool
 }
 
 func (t tool) Name() string           { return t.name }
 func (t tool) Description() string    { return t.desc }
 func (t tool) Parameters() JSONSchema { return t.params }
+func (t tool) Serial() bool           { return t.serial }
 func (t tool) Run(ctx context.Context, call model.ToolCallPart) (ToolResult, error) {
 	if t.runFn == nil {
 		panic(fmt.Sprintf("tool %q missing run function", t.name))
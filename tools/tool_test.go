@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"reflect"
 	"testing"
+
+	"github.com/agusx1211/miclaw/tooling"
 )
 
 func mainDeps() MainToolDeps {
@@ -13,10 +15,10 @@ func mainDeps() MainToolDeps {
 	}
 }
 
-func TestMainAgentToolsReturns14UniqueTools(t *testing.T) {
+func TestMainAgentToolsReturns23UniqueTools(t *testing.T) {
 	got := MainAgentTools(mainDeps())
-	if len(got) != 14 {
-		t.Fatalf("want 14 tools, got %d", len(got))
+	if len(got) != 29 {
+		t.Fatalf("want 29 tools, got %d", len(got))
 	}
 	seen := make(map[string]struct{}, len(got))
 	for _, g := range got {
@@ -26,7 +28,7 @@ func TestMainAgentToolsReturns14UniqueTools(t *testing.T) {
 		name := g.Name()
 		seen[name] = struct{}{}
 	}
-	if len(seen) != 14 {
+	if len(seen) != 29 {
 		t.Fatalf("tool names are not unique: got %d", len(seen))
 	}
 	if _, ok := seen["sleep"]; !ok {
@@ -34,10 +36,27 @@ func TestMainAgentToolsReturns14UniqueTools(t *testing.T) {
 	}
 }
 
+func TestMainAgentToolsMarkSideEffectsSerial(t *testing.T) {
+	serial := map[string]bool{
+		"write": true, "edit": true, "apply_patch": true, "move": true, "delete": true,
+		"exec": true, "process": true, "bg_kill": true, "cron": true, "message": true,
+		"glossary_add": true, "memory_write": true, "kb_set": true, "log_level": true, "snapshot": true, "rollback": true,
+	}
+	for _, g := range MainAgentTools(mainDeps()) {
+		if got := tooling.IsSerial(g); got != serial[g.Name()] {
+			t.Fatalf("%s: serial = %v, want %v", g.Name(), got, serial[g.Name()])
+		}
+	}
+	limited := LimitConcurrency([]Tool{writeTool()}, map[string]int{"write": 1})
+	if !tooling.IsSerial(limited[0]) {
+		t.Fatal("concurrency limit dropped the serial flag")
+	}
+}
+
 func TestToProviderDefsProducesValidJSON(t *testing.T) {
 	defs := ToProviderDefs(MainAgentTools(mainDeps()))
-	if len(defs) != 14 {
-		t.Fatalf("want 14 defs, got %d", len(defs))
+	if len(defs) != 29 {
+		t.Fatalf("want 29 defs, got %d", len(defs))
 	}
 	for _, def := range defs {
 		if !json.Valid(def.Parameters) {
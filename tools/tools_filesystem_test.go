@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/agusx1211/miclaw/model"
 )
@@ -214,6 +215,70 @@ func TestGlobToolNoMatches(t *testing.T) {
 	}
 }
 
+func TestGlobToolSortsByMtimeWithLimit(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"old.txt", "newest.txt", "mid.txt", "older.txt"} {
+		p := filepath.Join(root, name)
+		if err := os.WriteFile(p, []byte(name), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+		mtime := base.Add(time.Duration([]int{1, 4, 3, 0}[i]) * time.Hour)
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	got, err := runTool(t, globTool(), map[string]any{"pattern": "*.txt", "path": root, "sort": "mtime"})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	if got.Content != "newest.txt\nmid.txt\nold.txt\nolder.txt" {
+		t.Fatalf("unexpected mtime order: %q", got.Content)
+	}
+	got, err = runTool(t, globTool(), map[string]any{"pattern": "*.txt", "path": root, "sort": "mtime", "limit": 2})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	if got.Content != "newest.txt\nmid.txt" {
+		t.Fatalf("unexpected limited results: %q", got.Content)
+	}
+}
+
+func TestGlobToolSortsBySize(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	for name, size := range map[string]int{"small.txt": 1, "large.txt": 30, "medium.txt": 10} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(strings.Repeat("x", size)), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	got, err := runTool(t, globTool(), map[string]any{"pattern": "*.txt", "path": root, "sort": "size"})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	if got.Content != "large.txt\nmedium.txt\nsmall.txt" {
+		t.Fatalf("unexpected size order: %q", got.Content)
+	}
+}
+
+func TestGlobToolRejectsBadSortAndLimit(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	for _, args := range []map[string]any{
+		{"pattern": "*", "path": root, "sort": "random"},
+		{"pattern": "*", "path": root, "limit": -1},
+	} {
+		got, err := runTool(t, globTool(), args)
+		if err != nil {
+			t.Fatalf("tool call: %v", err)
+		}
+		if !got.IsError {
+			t.Fatalf("expected error for %v, got %q", args, got.Content)
+		}
+	}
+}
+
 func TestLSToolFlatListing(t *testing.T) {
 	t.Parallel()
 	root := t.TempDir()
@@ -312,3 +377,98 @@ func runTool(t *testing.T, tool Tool, params map[string]any) (ToolResult, error)
 		Parameters: raw,
 	})
 }
+
+func TestGrepToolIncludeOnlyTxt(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	for name, body := range map[string]string{"a.txt": "needle", "b.go": "needle", "c.md": "needle"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	got, err := runTool(t, grepTool(), map[string]any{"pattern": "needle", "path": root, "include": "*.txt"})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	if got.Content != "a.txt:1:needle" {
+		t.Fatalf("expected only the .txt match, got %q", got.Content)
+	}
+	got, err = runTool(t, grepTool(), map[string]any{"pattern": "needle", "path": root, "include": "*.txt, *.md"})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	if !strings.Contains(got.Content, "a.txt:1") || !strings.Contains(got.Content, "c.md:1") || strings.Contains(got.Content, "b.go") {
+		t.Fatalf("unexpected comma-separated include result: %q", got.Content)
+	}
+}
+
+func TestGrepToolExcludeSubdirectory(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "vendor", "dep"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "vendor", "dep", "x.txt"), []byte("needle"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "main.txt"), []byte("needle"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	for _, exclude := range []string{"vendor", "vendor/**", "*.log,vendor"} {
+		got, err := runTool(t, grepTool(), map[string]any{"pattern": "needle", "path": root, "exclude": exclude})
+		if err != nil {
+			t.Fatalf("tool call: %v", err)
+		}
+		if got.Content != "main.txt:1:needle" {
+			t.Fatalf("exclude %q: expected vendor to be skipped, got %q", exclude, got.Content)
+		}
+	}
+}
+
+func TestGrepToolIgnoreCase(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "log.txt"), []byte("Error: disk\nerror: net\nok"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	got, err := runTool(t, grepTool(), map[string]any{"pattern": "ERROR", "path": root, "ignore_case": true})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	if got.Content != "log.txt:1:Error: disk\nlog.txt:2:error: net" {
+		t.Fatalf("unexpected case-insensitive result: %q", got.Content)
+	}
+	got, err = runTool(t, grepTool(), map[string]any{"pattern": "ERROR", "path": root})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	if got.Content != "" {
+		t.Fatalf("expected no case-sensitive match, got %q", got.Content)
+	}
+}
+
+func TestGrepToolWordRejectsSubstringHits(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("errors pile up\nan error here\nterror"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	got, err := runTool(t, grepTool(), map[string]any{"pattern": "error|pile", "path": root, "word": true})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	if got.Content != "a.txt:1:errors pile up\na.txt:2:an error here" {
+		t.Fatalf("unexpected whole-word result: %q", got.Content)
+	}
+}
+
+func TestGrepToolInvalidPatternWithFlags(t *testing.T) {
+	t.Parallel()
+	got, err := runTool(t, grepTool(), map[string]any{"pattern": "(", "path": t.TempDir(), "word": true, "ignore_case": true})
+	if err != nil {
+		t.Fatalf("tool call: %v", err)
+	}
+	if !got.IsError || !strings.Contains(got.Content, "invalid pattern") {
+		t.Fatalf("expected invalid pattern error, got %q", got.Content)
+	}
+}
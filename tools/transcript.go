@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/store"
+)
+
+const (
+	transcriptDir          = "transcripts"
+	transcriptMessageLimit = 1_000_000
+)
+
+type transcriptPart struct {
+	Kind    string
+	Title   string
+	Text    string
+	IsError bool
+}
+
+type transcriptMessage struct {
+	Role  string
+	Time  string
+	From  string
+	Parts []transcriptPart
+}
+
+var transcriptTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>miclaw transcript {{.Generated}}</title>
+<style>
+body{font-family:system-ui,sans-serif;max-width:56rem;margin:2rem auto;padding:0 1rem;background:#fafafa;color:#222}
+.msg{border-radius:8px;padding:.75rem 1rem;margin:.75rem 0;background:#fff;border-left:4px solid #999}
+.user{border-color:#2b6cb0}.assistant{border-color:#2f855a}.tool{border-color:#b7791f}
+.meta{font-size:.8rem;color:#666;margin-bottom:.25rem}
+pre{white-space:pre-wrap;word-break:break-word;margin:.25rem 0;font-size:.85rem}
+.text{white-space:pre-wrap;margin:.25rem 0}
+details{margin:.25rem 0}summary{cursor:pointer;color:#444}
+.error summary{color:#c53030}
+</style>
+</head>
+<body>
+<h1>Transcript</h1>
+<p class="meta">{{len .Messages}} messages, generated {{.Generated}}</p>
+{{range .Messages}}<div class="msg {{.Role}}">
+<div class="meta">{{.Role}} · {{.Time}}{{if .From}} (from: {{.From}}){{end}}</div>
+{{range .Parts}}{{if eq .Kind "text"}}<div class="text">{{.Text}}</div>
+{{else}}<details{{if .IsError}} class="error"{{end}}><summary>{{.Title}}</summary><pre>{{.Text}}</pre></details>
+{{end}}{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+func transcriptTool(workspace string, messages store.MessageStore) Tool {
+	return tool{
+		name: "transcript",
+		desc: "Render the current thread, or an archived one, to a self-contained HTML or Markdown file in the workspace and return its path and size",
+		params: JSONSchema{
+			Type: "object",
+			Properties: map[string]JSONSchema{
+				"format": {Type: "string", Desc: "html (default) or markdown", Enum: []string{"html", "markdown"}},
+				"period": {Type: "string", Desc: "Archived thread to render, by rotation period key such as 2026-02 or 2026-W07; omit for the current thread"},
+				"path":   {Type: "string", Desc: "File to write, relative to the workspace or absolute inside it; defaults to transcripts/transcript-<time>.<ext>"},
+			},
+		},
+		runFn: func(_ context.Context, call model.ToolCallPart) (ToolResult, error) {
+			var input struct {
+				Format string `json:"format"`
+				Period string `json:"period"`
+				Path   string `json:"path"`
+			}
+			if err := unmarshalObject(call.Parameters, &input); err != nil {
+				return ToolResult{IsError: true, Content: fmt.Sprintf("parse transcript parameters: %v", err)}, nil
+			}
+			n, size, path, err := renderTranscript(workspace, messages, input.Format, input.Period, input.Path, time.Now().UTC())
+			if err != nil {
+				return ToolResult{IsError: true, Content: err.Error()}, nil
+			}
+			return ToolResult{Content: fmt.Sprintf("wrote transcript of %d messages (%d bytes) to %s", n, size, path)}, nil
+		},
+	}
+}
+
+// renderTranscript writes the thread, or the archive of period, in format to
+// path and returns the message count, file size and the path written.
+func renderTranscript(workspace string, messages store.MessageStore, format, period, path string, now time.Time) (int, int64, string, error) {
+	ext := ".html"
+	if format == "markdown" {
+		ext = ".md"
+	} else if format != "" && format != "html" {
+		return 0, 0, "", fmt.Errorf("format must be html or markdown")
+	}
+	path, err := transcriptPath(workspace, path, ext, now)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	if ext == ".md" {
+		n, size, err := writeMarkdownTranscript(path, messages, period, now)
+		return n, size, path, err
+	}
+	var msgs []*model.Message
+	if period != "" {
+		msgs, err = messages.ListArchive(period)
+	} else {
+		msgs, err = messages.List(transcriptMessageLimit, 0)
+	}
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("list messages: %v", err)
+	}
+	size, err := writeTranscript(path, msgs, now)
+	return len(msgs), size, path, err
+}
+
+func writeTranscript(path string, msgs []*model.Message, now time.Time) (int64, error) {
+	view := make([]transcriptMessage, 0, len(msgs))
+	for _, m := range msgs {
+		tm := transcriptMessage{Role: string(m.Role), Time: m.CreatedAt.UTC().Format(time.RFC3339), From: messageFrom(m)}
+		for _, p := range m.Parts {
+			if part, ok := summarizePart(p); ok {
+				tm.Parts = append(tm.Parts, part)
+			}
+		}
+		view = append(view, tm)
+	}
+	var buf bytes.Buffer
+	data := struct {
+		Generated string
+		Messages  []transcriptMessage
+	}{Generated: now.Format(time.RFC3339), Messages: view}
+	if err := transcriptTemplate.Execute(&buf, data); err != nil {
+		return 0, fmt.Errorf("render transcript: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return 0, fmt.Errorf("write transcript: %v", err)
+	}
+	return int64(buf.Len()), nil
+}
+
+// messageFrom names the sender recorded in a message's metadata, or returns
+// "" when there is none.
+func messageFrom(m *model.Message) string {
+	for _, key := range []string{"source_name", "source_number", "source_uuid"} {
+		if v := strings.TrimSpace(m.Metadata[key]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// summarizePart turns a message part into its transcript form. Finish parts
+// carry no content and are skipped.
+func summarizePart(p model.MessagePart) (transcriptPart, bool) {
+	switch v := p.(type) {
+	case model.TextPart:
+		return transcriptPart{Kind: "text", Text: v.Text}, true
+	case model.ReasoningPart:
+		return transcriptPart{Kind: "reasoning", Title: "reasoning", Text: v.Text}, true
+	case model.ToolCallPart:
+		return transcriptPart{Kind: "tool_call", Title: "tool call: " + v.Name, Text: indentJSON(v.Parameters)}, true
+	case model.ToolResultPart:
+		title := "tool result"
+		if v.IsError {
+			title = "tool error"
+		}
+		return transcriptPart{Kind: "tool_result", Title: title, Text: v.Content, IsError: v.IsError}, true
+	case model.BinaryPart:
+		return transcriptPart{Kind: "binary", Title: "attachment", Text: fmt.Sprintf("%s, %d bytes", v.MimeType, len(v.Data))}, true
+	case model.ImageURLPart:
+		return transcriptPart{Kind: "binary", Title: "attachment", Text: v.URL}, true
+	default:
+		return transcriptPart{}, false
+	}
+}
+
+func indentJSON(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
@@ -0,0 +1,148 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/store"
+)
+
+// transcriptPageSize is how many thread messages are read and rendered at a
+// time, so a long thread is written out without holding all of it.
+const transcriptPageSize = 500
+
+// transcriptPath returns where a transcript goes: path inside workspace when
+// given, otherwise a timestamped file under the transcripts directory. The
+// parent directory is created.
+func transcriptPath(workspace, path, ext string, now time.Time) (string, error) {
+	if path == "" {
+		path = filepath.Join(transcriptDir, "transcript-"+now.Format("20060102-150405")+ext)
+	}
+	if filepath.IsAbs(path) {
+		root, err := filepath.Abs(workspace)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return "", fmt.Errorf("path %q is outside the workspace", path)
+		}
+		path = rel
+	}
+	path = filepath.Clean(path)
+	if path == "." || path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside the workspace", path)
+	}
+	if err := os.MkdirAll(filepath.Join(workspace, filepath.Dir(path)), 0o755); err != nil {
+		return "", fmt.Errorf("create transcript directory: %v", err)
+	}
+	return resolveWorkspacePath(workspace, path)
+}
+
+// writeMarkdownTranscript streams the thread, or the archived thread of
+// period when it is set, to path as Markdown, and returns how many messages
+// it wrote and the file size.
+func writeMarkdownTranscript(path string, messages store.MessageStore, period string, now time.Time) (int, int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("write transcript: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "# Transcript\n\nGenerated %s", now.Format(time.RFC3339))
+	if period != "" {
+		fmt.Fprintf(w, " from the thread archived as %s", period)
+	}
+	fmt.Fprint(w, ".\n")
+	n, err := streamTranscript(w, messages, period)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("write transcript: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	return n, info.Size(), nil
+}
+
+func streamTranscript(w io.Writer, messages store.MessageStore, period string) (int, error) {
+	if period != "" {
+		msgs, err := messages.ListArchive(period)
+		if err != nil {
+			return 0, fmt.Errorf("list archive %s: %v", period, err)
+		}
+		for _, m := range msgs {
+			writeMarkdownMessage(w, m)
+		}
+		return len(msgs), nil
+	}
+	n := 0
+	for {
+		msgs, err := messages.List(transcriptPageSize, n)
+		if err != nil {
+			return n, fmt.Errorf("list messages: %v", err)
+		}
+		for _, m := range msgs {
+			writeMarkdownMessage(w, m)
+		}
+		n += len(msgs)
+		if len(msgs) < transcriptPageSize {
+			return n, nil
+		}
+	}
+}
+
+// writeMarkdownMessage renders one message under a role, timestamp and
+// sender heading. Reasoning is collapsed, and tool calls and results are
+// fenced.
+func writeMarkdownMessage(w io.Writer, m *model.Message) {
+	from := ""
+	if name := messageFrom(m); name != "" {
+		from = " (from: " + name + ")"
+	}
+	fmt.Fprintf(w, "\n## %s · %s%s\n", m.Role, m.CreatedAt.UTC().Format(time.RFC3339), from)
+	for _, p := range m.Parts {
+		part, ok := summarizePart(p)
+		if !ok {
+			continue
+		}
+		switch part.Kind {
+		case "text":
+			fmt.Fprintf(w, "\n%s\n", part.Text)
+		case "reasoning":
+			fmt.Fprintf(w, "\n<details><summary>reasoning</summary>\n\n%s\n\n</details>\n", part.Text)
+		case "tool_call":
+			fence := markdownFence(part.Text)
+			fmt.Fprintf(w, "\n**%s**\n\n%sjson\n%s\n%s\n", part.Title, fence, part.Text, fence)
+		default:
+			fence := markdownFence(part.Text)
+			fmt.Fprintf(w, "\n**%s**\n\n%s\n%s\n%s\n", part.Title, fence, part.Text, fence)
+		}
+	}
+}
+
+// markdownFence returns a backtick fence longer than any backtick run in
+// text, so the text cannot close it early.
+func markdownFence(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r != '`' {
+			run = 0
+			continue
+		}
+		run++
+		longest = max(longest, run)
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
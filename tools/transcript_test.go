@@ -0,0 +1,162 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/model"
+	"github.com/agusx1211/miclaw/store"
+)
+
+func TestTranscriptWritesHTMLWithMessages(t *testing.T) {
+	workspace := t.TempDir()
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer st.Close()
+	messages := st.MessageStore()
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, m := range []*model.Message{
+		{ID: "1", Role: model.RoleUser, Parts: []model.MessagePart{model.TextPart{Text: "check <disk> usage"}}},
+		{ID: "2", Role: model.RoleAssistant, Parts: []model.MessagePart{
+			model.ReasoningPart{Text: "run df"},
+			model.ToolCallPart{ID: "c1", Name: "exec", Parameters: json.RawMessage(`{"command":"df -h"}`)},
+		}},
+		{ID: "3", Role: model.RoleTool, Parts: []model.MessagePart{model.ToolResultPart{ToolCallID: "c1", Content: "/dev/sda1 42%"}}},
+		{ID: "4", Role: model.RoleAssistant, Parts: []model.MessagePart{model.TextPart{Text: "Disk is 42% full."}}},
+	} {
+		m.CreatedAt = base.Add(time.Duration(i) * time.Second)
+		if err := messages.Create(m); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+	}
+
+	got, err := transcriptTool(workspace, messages).Run(context.Background(), model.ToolCallPart{Name: "transcript", Parameters: json.RawMessage(`{}`)})
+	if err != nil {
+		t.Fatalf("run transcript: %v", err)
+	}
+	if got.IsError {
+		t.Fatalf("unexpected tool error: %s", got.Content)
+	}
+	i := strings.Index(got.Content, filepath.Join(workspace, transcriptDir))
+	if i < 0 || !strings.HasSuffix(got.Content, ".html") {
+		t.Fatalf("expected transcript path in result: %q", got.Content)
+	}
+	raw, err := os.ReadFile(got.Content[i:])
+	if err != nil {
+		t.Fatalf("read transcript: %v", err)
+	}
+	html := string(raw)
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"check &lt;disk&gt; usage",
+		"Disk is 42% full.",
+		"<summary>tool call: exec</summary>",
+		"&#34;command&#34;: &#34;df -h&#34;",
+		"/dev/sda1 42%",
+		`class="msg tool"`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("transcript missing %q:\n%s", want, html)
+		}
+	}
+}
+
+func TestTranscriptStreamsMarkdownAcrossPages(t *testing.T) {
+	workspace := t.TempDir()
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer st.Close()
+	messages := st.MessageStore()
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	total := transcriptPageSize + 20
+	for i := range total {
+		m := &model.Message{ID: fmt.Sprintf("m%04d", i), Role: model.RoleUser, CreatedAt: base.Add(time.Duration(i) * time.Second),
+			Parts: []model.MessagePart{model.TextPart{Text: fmt.Sprintf("message %d", i)}}}
+		if i == total-1 {
+			m.Role = model.RoleTool
+			m.Parts = []model.MessagePart{model.ToolResultPart{ToolCallID: "c1", Content: "```\nnested fence\n```"}}
+		}
+		if i == 1 {
+			m.Metadata = map[string]string{"source_name": "Alice", "source_uuid": "u-1"}
+		}
+		if i == total-2 {
+			m.Role = model.RoleAssistant
+			m.Parts = []model.MessagePart{model.ReasoningPart{Text: "think first"}}
+		}
+		if err := messages.Create(m); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+	}
+
+	params := json.RawMessage(`{"format": "markdown", "path": "exports/thread.md"}`)
+	got, err := transcriptTool(workspace, messages).Run(context.Background(), model.ToolCallPart{Name: "transcript", Parameters: params})
+	if err != nil || got.IsError {
+		t.Fatalf("run transcript: %v %s", err, got.Content)
+	}
+	path := filepath.Join(workspace, "exports", "thread.md")
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read transcript: %v", err)
+	}
+	want := fmt.Sprintf("wrote transcript of %d messages (%d bytes) to %s", total, len(raw), path)
+	if got.Content != want {
+		t.Fatalf("unexpected result:\n got %q\nwant %q", got.Content, want)
+	}
+	md := string(raw)
+	for _, want := range []string{
+		"## user · 2026-03-01T09:00:00Z\n\nmessage 0\n",
+		"## user · 2026-03-01T09:00:01Z (from: Alice)\n\nmessage 1\n",
+		fmt.Sprintf("message %d\n", total-3),
+		"<details><summary>reasoning</summary>\n\nthink first\n\n</details>",
+		"**tool result**\n\n````\n```\nnested fence\n```\n````\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("transcript missing %q", want)
+		}
+	}
+}
+
+func TestTranscriptExportsArchivedPeriodAndRefusesOutsidePaths(t *testing.T) {
+	workspace := t.TempDir()
+	st, err := store.OpenSQLite(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer st.Close()
+	messages := st.MessageStore()
+	old := &model.Message{ID: "jan", Role: model.RoleUser, CreatedAt: time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC),
+		Parts: []model.MessagePart{model.TextPart{Text: "january note"}}}
+	if err := messages.Create(old); err != nil {
+		t.Fatal(err)
+	}
+	if err := messages.Archive("2026-01", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tl := transcriptTool(workspace, messages)
+	got, err := tl.Run(context.Background(), model.ToolCallPart{Name: "transcript", Parameters: json.RawMessage(`{"format": "markdown", "period": "2026-01", "path": "jan.md"}`)})
+	if err != nil || got.IsError || !strings.Contains(got.Content, "transcript of 1 messages") {
+		t.Fatalf("unexpected archive export: %v %q", err, got.Content)
+	}
+	raw, err := os.ReadFile(filepath.Join(workspace, "jan.md"))
+	if err != nil || !strings.Contains(string(raw), "archived as 2026-01") || !strings.Contains(string(raw), "january note") {
+		t.Fatalf("unexpected archive transcript: %q (%v)", raw, err)
+	}
+	for _, path := range []string{"../escape.md", filepath.Join(t.TempDir(), "abs.md")} {
+		params, _ := json.Marshal(map[string]string{"format": "markdown", "path": path})
+		got, err := tl.Run(context.Background(), model.ToolCallPart{Name: "transcript", Parameters: params})
+		if err != nil || !got.IsError || !strings.Contains(got.Content, "outside the workspace") {
+			t.Fatalf("expected %s refused, got %v %q", path, err, got.Content)
+		}
+	}
+}
@@ -15,6 +15,7 @@ type writeParams struct {
 	Path       string
 	Content    string
 	CreateDirs bool
+	Append     bool
 }
 
 func writeTool() Tool {
@@ -33,13 +34,18 @@ func writeTool() Tool {
 				Type: "boolean",
 				Desc: "Create parent directories when missing (default: true)",
 			},
+			"append": {
+				Type: "boolean",
+				Desc: "Append content to the end of the file instead of replacing it (default: false)",
+			},
 		},
 		Required: []string{"path", "content"},
 	}
 
 	return tool{
 		name:   "write",
-		desc:   "Write content to a file, replacing existing content",
+		serial: true,
+		desc:   "Write content to a file, replacing existing content, or append to it with append=true",
 		params: params,
 		runFn:  runWrite,
 	}
@@ -54,6 +60,13 @@ func runWrite(ctx context.Context, call model.ToolCallPart) (ToolResult, error)
 	if err := ensureWriteParent(args.Path, args.CreateDirs); err != nil {
 		return ToolResult{}, err
 	}
+	if args.Append {
+		n, size, err := appendContent(args.Path, args.Content)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		return ToolResult{Content: fmt.Sprintf("appended %d bytes to %s (%d bytes total)", n, args.Path, size)}, nil
+	}
 	n, err := writeContent(args.Path, args.Content)
 	if err != nil {
 		return ToolResult{}, err
@@ -70,6 +83,7 @@ func parseWriteParams(raw json.RawMessage) (writeParams, error) {
 		Path       *string `json:"path"`
 		Content    *string `json:"content"`
 		CreateDirs *bool   `json:"create_dirs"`
+		Append     bool    `json:"append"`
 	}
 	if err := json.Unmarshal(raw, &input); err != nil {
 		return writeParams{}, fmt.Errorf("parse write parameters: %v", err)
@@ -80,7 +94,7 @@ func parseWriteParams(raw json.RawMessage) (writeParams, error) {
 	if input.Content == nil {
 		return writeParams{}, errors.New("write parameter content is required")
 	}
-	out := writeParams{Path: *input.Path, Content: *input.Content, CreateDirs: true}
+	out := writeParams{Path: *input.Path, Content: *input.Content, CreateDirs: true, Append: input.Append}
 	if input.CreateDirs != nil {
 		out.CreateDirs = *input.CreateDirs
 	}
@@ -113,3 +127,28 @@ func writeContent(path, content string) (int, error) {
 
 	return n, nil
 }
+
+// appendContent writes content at the end of path, creating it if needed, and
+// returns the bytes written and the resulting file size.
+func appendContent(path, content string) (int, int64, error) {
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, 0, fmt.Errorf("open file %q: %v", path, err)
+	}
+	n, err := f.WriteString(content)
+	if err != nil {
+		_ = f.Close()
+		return 0, 0, fmt.Errorf("append file %q: %v", path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return 0, 0, fmt.Errorf("stat file %q: %v", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return 0, 0, fmt.Errorf("close file %q: %v", path, err)
+	}
+
+	return n, info.Size(), nil
+}
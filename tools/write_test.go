@@ -76,10 +76,32 @@ func TestWriteFailsWhenParentMissingAndCreateDirsFalse(t *testing.T) {
 	}
 }
 
+func TestWriteAppendKeepsOrderAndReportsSize(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "logs", "run.log")
+	if _, err := runWriteCall(t, writeArgs{Path: p, Content: "first\n", Append: true}); err != nil {
+		t.Fatalf("first append: %v", err)
+	}
+	got, err := runWriteCall(t, writeArgs{Path: p, Content: "second\n", Append: true})
+	if err != nil {
+		t.Fatalf("second append: %v", err)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if string(b) != "first\nsecond\n" {
+		t.Fatalf("want appended content in order, got %q", string(b))
+	}
+	if !strings.Contains(got.Content, "appended 7 bytes") || !strings.Contains(got.Content, "13 bytes total") {
+		t.Fatalf("unexpected result: %q", got.Content)
+	}
+}
+
 type writeArgs struct {
 	Path       string `json:"path"`
 	Content    string `json:"content"`
 	CreateDirs *bool  `json:"create_dirs,omitempty"`
+	Append     bool   `json:"append,omitempty"`
 }
 
 func runWriteCall(t *testing.T, args writeArgs) (ToolResult, error) {
@@ -0,0 +1,154 @@
+package miclaw
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const typingKeepaliveInterval = 8 * time.Second
+
+type typingState struct {
+	mu          sync.Mutex
+	active      map[string]chan struct{}
+	autoTarget  string
+	autoPending bool
+}
+
+func newTypingState() *typingState {
+	return &typingState{active: map[string]chan struct{}{}}
+}
+
+func (s *typingState) Start(to string, duration time.Duration, send func(context.Context, string) error) error {
+	if err := send(context.Background(), to); err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	s.mu.Lock()
+	if prev, ok := s.active[to]; ok {
+		close(prev)
+	}
+	s.active[to] = stop
+	s.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(typingKeepaliveInterval)
+		defer ticker.Stop()
+		var timeout *time.Timer
+		var timeoutCh <-chan time.Time
+		if duration > 0 {
+			timeout = time.NewTimer(duration)
+			timeoutCh = timeout.C
+		}
+		if timeout != nil {
+			defer timeout.Stop()
+		}
+		defer func() {
+			s.mu.Lock()
+			if s.active[to] == stop {
+				delete(s.active, to)
+			}
+			s.mu.Unlock()
+		}()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-timeoutCh:
+				return
+			case <-ticker.C:
+				s.mu.Lock()
+				live := s.active[to] == stop
+				s.mu.Unlock()
+				if !live {
+					return
+				}
+				_ = send(context.Background(), to)
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *typingState) Clear(to string) {
+	stop, ok := s.take(to)
+	if ok {
+		close(stop)
+	}
+}
+
+func (s *typingState) ClearAll() {
+	stops := s.takeAll()
+	for _, stop := range stops {
+		close(stop)
+	}
+}
+
+func (s *typingState) Stop(to string, stopTyping func(context.Context, string) error) error {
+	s.Clear(to)
+	return stopTyping(context.Background(), to)
+}
+
+func (s *typingState) StopAll(stopTyping func(context.Context, string) error) error {
+	targets := s.activeTargets()
+	s.ClearAll()
+	for _, to := range targets {
+		if err := stopTyping(context.Background(), to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *typingState) take(to string) (chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stop, ok := s.active[to]
+	if ok {
+		delete(s.active, to)
+	}
+	return stop, ok
+}
+
+func (s *typingState) takeAll() []chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stops := make([]chan struct{}, 0, len(s.active))
+	for to, stop := range s.active {
+		delete(s.active, to)
+		stops = append(stops, stop)
+	}
+	return stops
+}
+
+func (s *typingState) activeTargets() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	targets := make([]string, 0, len(s.active))
+	for to := range s.active {
+		targets = append(targets, to)
+	}
+	return targets
+}
+
+func (s *typingState) SetAutoTarget(source string) {
+	kind, id, err := parseSignalTarget(source)
+	if err != nil || kind != "dm" {
+		return
+	}
+	s.mu.Lock()
+	s.autoTarget = "signal:dm:" + id
+	s.autoPending = true
+	s.mu.Unlock()
+}
+
+func (s *typingState) StartAuto(send func(context.Context, string) error) error {
+	s.mu.Lock()
+	to := s.autoTarget
+	pending := s.autoPending
+	s.autoPending = false
+	s.mu.Unlock()
+	if to == "" || !pending {
+		return nil
+	}
+	return s.Start(to, 30*time.Second, send)
+}
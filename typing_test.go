@@ -0,0 +1,230 @@
+package miclaw
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTypingStateClearStopsTypingLoop(t *testing.T) {
+	st := newTypingState()
+	var mu sync.Mutex
+	calls := 0
+	send := func(context.Context, string) error {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		return nil
+	}
+	if err := st.Start("signal:dm:user-1", 500*time.Millisecond, send); err != nil {
+		t.Fatalf("start typing: %v", err)
+	}
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for {
+		mu.Lock()
+		n := calls
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("typing did not send initial indicator")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	st.Clear("signal:dm:user-1")
+	mu.Lock()
+	afterClear := calls
+	mu.Unlock()
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	afterWait := calls
+	mu.Unlock()
+	if afterWait != afterClear {
+		t.Fatalf("typing calls increased after clear: %d -> %d", afterClear, afterWait)
+	}
+}
+
+func TestTypingStateTimeoutRemovesActiveEntry(t *testing.T) {
+	st := newTypingState()
+	send := func(context.Context, string) error { return nil }
+	if err := st.Start("signal:dm:user-1", 60*time.Millisecond, send); err != nil {
+		t.Fatalf("start typing: %v", err)
+	}
+	time.Sleep(120 * time.Millisecond)
+	st.mu.Lock()
+	_, ok := st.active["signal:dm:user-1"]
+	st.mu.Unlock()
+	if ok {
+		t.Fatal("typing entry should expire after timeout")
+	}
+}
+
+func TestTypingStateNoTimeoutKeepsEntryUntilClear(t *testing.T) {
+	st := newTypingState()
+	send := func(context.Context, string) error { return nil }
+	if err := st.Start("signal:dm:user-1", 0, send); err != nil {
+		t.Fatalf("start typing: %v", err)
+	}
+	time.Sleep(120 * time.Millisecond)
+	st.mu.Lock()
+	_, ok := st.active["signal:dm:user-1"]
+	st.mu.Unlock()
+	if !ok {
+		t.Fatal("typing entry should stay active without timeout")
+	}
+	st.Clear("signal:dm:user-1")
+}
+
+func TestTypingStateAutoStartUsesLatestSignalDMSource(t *testing.T) {
+	st := newTypingState()
+	st.SetAutoTarget("signal:group:abc")
+	st.SetAutoTarget("signal:dm:user-1")
+	var mu sync.Mutex
+	calls := 0
+	lastTo := ""
+	send := func(_ context.Context, to string) error {
+		mu.Lock()
+		calls++
+		lastTo = to
+		mu.Unlock()
+		return nil
+	}
+	if err := st.StartAuto(send); err != nil {
+		t.Fatalf("start auto typing: %v", err)
+	}
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for {
+		mu.Lock()
+		n := calls
+		to := lastTo
+		mu.Unlock()
+		if n > 0 {
+			if to != "signal:dm:user-1" {
+				t.Fatalf("auto typing target = %q", to)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("auto typing did not start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	st.ClearAll()
+}
+
+func TestTypingStateAutoStartRunsOncePerSignalTargetSet(t *testing.T) {
+	st := newTypingState()
+	st.SetAutoTarget("signal:dm:user-1")
+	calls := 0
+	send := func(context.Context, string) error {
+		calls++
+		return nil
+	}
+	if err := st.StartAuto(send); err != nil {
+		t.Fatalf("start auto typing: %v", err)
+	}
+	if err := st.StartAuto(send); err != nil {
+		t.Fatalf("start auto typing second call: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("auto typing calls = %d", calls)
+	}
+	st.SetAutoTarget("signal:dm:user-1")
+	if err := st.StartAuto(send); err != nil {
+		t.Fatalf("start auto typing after reset: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("auto typing calls after reset = %d", calls)
+	}
+	st.ClearAll()
+}
+
+func TestTypingStateClearAllStopsAllTargets(t *testing.T) {
+	st := newTypingState()
+	var mu sync.Mutex
+	calls := 0
+	send := func(context.Context, string) error {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		return nil
+	}
+	if err := st.Start("signal:dm:user-1", 0, send); err != nil {
+		t.Fatalf("start typing user-1: %v", err)
+	}
+	if err := st.Start("signal:dm:user-2", 0, send); err != nil {
+		t.Fatalf("start typing user-2: %v", err)
+	}
+	st.ClearAll()
+	mu.Lock()
+	afterClear := calls
+	mu.Unlock()
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	afterWait := calls
+	mu.Unlock()
+	if afterWait != afterClear {
+		t.Fatalf("typing calls increased after clear all: %d -> %d", afterClear, afterWait)
+	}
+	st.mu.Lock()
+	n := len(st.active)
+	st.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("active entries = %d", n)
+	}
+}
+
+func TestTypingStateStopCallsStopCallback(t *testing.T) {
+	st := newTypingState()
+	send := func(context.Context, string) error { return nil }
+	stopped := ""
+	stop := func(_ context.Context, to string) error {
+		stopped = to
+		return nil
+	}
+	if err := st.Start("signal:dm:user-1", 0, send); err != nil {
+		t.Fatalf("start typing: %v", err)
+	}
+	if err := st.Stop("signal:dm:user-1", stop); err != nil {
+		t.Fatalf("stop typing: %v", err)
+	}
+	if stopped != "signal:dm:user-1" {
+		t.Fatalf("stopped target = %q", stopped)
+	}
+	st.mu.Lock()
+	_, ok := st.active["signal:dm:user-1"]
+	st.mu.Unlock()
+	if ok {
+		t.Fatal("typing entry should be removed after stop")
+	}
+}
+
+func TestTypingStateStopAllCallsStopCallback(t *testing.T) {
+	st := newTypingState()
+	send := func(context.Context, string) error { return nil }
+	stopped := map[string]int{}
+	stop := func(_ context.Context, to string) error {
+		stopped[to]++
+		return nil
+	}
+	if err := st.Start("signal:dm:user-1", 0, send); err != nil {
+		t.Fatalf("start typing user-1: %v", err)
+	}
+	if err := st.Start("signal:dm:user-2", 0, send); err != nil {
+		t.Fatalf("start typing user-2: %v", err)
+	}
+	if err := st.StopAll(stop); err != nil {
+		t.Fatalf("stop all typing: %v", err)
+	}
+	if stopped["signal:dm:user-1"] != 1 || stopped["signal:dm:user-2"] != 1 {
+		t.Fatalf("stop callbacks = %#v", stopped)
+	}
+	st.mu.Lock()
+	n := len(st.active)
+	st.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("active entries = %d", n)
+	}
+}
@@ -0,0 +1,27 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/agusx1211/miclaw/config"
+)
+
+// ValidateBearer reports whether header is "Bearer <token>". The token is
+// compared in constant time.
+func ValidateBearer(header, token string) bool {
+	got, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// hookToken returns the token a hook requires: its own auth_token, or the
+// server-level one. Empty means no bearer check.
+func hookToken(cfg config.WebhookConfig, hook config.WebhookDef) string {
+	if hook.AuthToken != "" {
+		return hook.AuthToken
+	}
+	return cfg.AuthToken
+}
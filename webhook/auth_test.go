@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/config"
+)
+
+type trackingBody struct {
+	read bool
+}
+
+func (b *trackingBody) Read(p []byte) (int, error) {
+	b.read = true
+	return 0, io.EOF
+}
+
+func (b *trackingBody) Close() error { return nil }
+
+func serveHook(t *testing.T, cfg config.WebhookConfig, auth string) (int, bool, int) {
+	t.Helper()
+	calls := 0
+	server := New(cfg, func(string, string, map[string]string) error { calls++; return nil })
+	body := &trackingBody{}
+	req := httptest.NewRequest(http.MethodPost, "/hook", nil)
+	req.Body = body
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	rec := httptest.NewRecorder()
+	server.server.Handler.ServeHTTP(rec, req)
+	return rec.Code, body.read, calls
+}
+
+func TestWebhookBearerTokenServerLevel(t *testing.T) {
+	cfg := config.WebhookConfig{
+		AuthToken: "server-token",
+		Hooks:     []config.WebhookDef{{ID: "a", Path: "/hook", Format: "text"}},
+	}
+	code, read, calls := serveHook(t, cfg, "Bearer server-token")
+	if code != http.StatusAccepted || calls != 1 {
+		t.Fatalf("valid token: status=%d calls=%d", code, calls)
+	}
+	for _, auth := range []string{"", "Bearer wrong", "server-token", "Basic server-token"} {
+		code, read, calls = serveHook(t, cfg, auth)
+		if code != http.StatusUnauthorized || read || calls != 0 {
+			t.Fatalf("auth %q: status=%d read=%v calls=%d", auth, code, read, calls)
+		}
+	}
+}
+
+func TestWebhookBearerTokenHookOverridesServer(t *testing.T) {
+	cfg := config.WebhookConfig{
+		AuthToken: "server-token",
+		Hooks:     []config.WebhookDef{{ID: "a", Path: "/hook", Format: "text", AuthToken: "hook-token"}},
+	}
+	if code, _, _ := serveHook(t, cfg, "Bearer server-token"); code != http.StatusUnauthorized {
+		t.Fatalf("server token accepted on hook with its own token: %d", code)
+	}
+	if code, _, _ := serveHook(t, cfg, "Bearer hook-token"); code != http.StatusAccepted {
+		t.Fatalf("hook token rejected: %d", code)
+	}
+}
+
+func TestWebhookWithoutTokenStaysOpen(t *testing.T) {
+	cfg := config.WebhookConfig{Hooks: []config.WebhookDef{{ID: "a", Path: "/hook", Format: "text"}}}
+	if code, _, calls := serveHook(t, cfg, ""); code != http.StatusAccepted || calls != 1 {
+		t.Fatalf("status=%d calls=%d", code, calls)
+	}
+}
+
+func TestWebhookUnauthorizedLogOmitsTokens(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	cfg := config.WebhookConfig{
+		AuthToken: "server-token",
+		Hooks:     []config.WebhookDef{{ID: "a", Path: "/hook", Format: "text"}},
+	}
+	serveHook(t, cfg, "Bearer guessed-token")
+	if !strings.Contains(logs.String(), "unauthorized hook=a") {
+		t.Fatalf("missing rejection log: %q", logs.String())
+	}
+	if strings.Contains(logs.String(), "server-token") || strings.Contains(logs.String(), "guessed-token") {
+		t.Fatalf("token leaked into logs: %q", logs.String())
+	}
+}
+
+func TestValidateBearer(t *testing.T) {
+	if !ValidateBearer("Bearer abc", "abc") {
+		t.Fatal("valid header rejected")
+	}
+	if ValidateBearer("Bearer abcd", "abc") || ValidateBearer("bearer abc", "abc") || ValidateBearer("Bearer ", "abc") {
+		t.Fatal("invalid header accepted")
+	}
+}
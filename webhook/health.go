@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	HealthOK          = "ok"
+	HealthUnavailable = "unavailable"
+)
+
+// Health is the /healthz payload. Status is HealthOK or HealthUnavailable;
+// the latter is served with 503.
+type Health struct {
+	Status              string        `json:"status"`
+	UptimeSeconds       int64         `json:"uptime_seconds"`
+	AgentActive         bool          `json:"agent_active"`
+	QueueDepth          int           `json:"queue_depth"`
+	Signal              *SignalHealth `json:"signal"`
+	LastProviderError   string        `json:"last_provider_error"`
+	LastProviderErrorAt *time.Time    `json:"last_provider_error_at"`
+}
+
+// SignalHealth describes the Signal events stream. It is nil in Health when
+// Signal is disabled.
+type SignalHealth struct {
+	Connected bool      `json:"connected"`
+	Since     time.Time `json:"since"`
+}
+
+// HandleHealth serves GET /healthz from report. It must be called before
+// Start.
+func (s *Server) HandleHealth(report func() Health) {
+	s.mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		h := report()
+		w.Header().Set("Content-Type", "application/json")
+		if h.Status != HealthOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(h)
+	})
+}
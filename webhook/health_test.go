@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agusx1211/miclaw/config"
+)
+
+func serveHealth(t *testing.T, h Health, method string) (*httptest.ResponseRecorder, Health) {
+	t.Helper()
+	server := New(config.WebhookConfig{Listen: ":0"}, func(string, string, map[string]string) error { return nil })
+	server.HandleHealth(func() Health { return h })
+	rec := httptest.NewRecorder()
+	server.server.Handler.ServeHTTP(rec, httptest.NewRequest(method, "/healthz", nil))
+	var got Health
+	if rec.Code != http.StatusMethodNotAllowed {
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decode health: %v", err)
+		}
+	}
+	return rec, got
+}
+
+func TestHealthzReportsOK(t *testing.T) {
+	rec, got := serveHealth(t, Health{Status: HealthOK, UptimeSeconds: 5, QueueDepth: 2}, http.MethodGet)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d", rec.Code)
+	}
+	if got.UptimeSeconds != 5 || got.QueueDepth != 2 || got.Signal != nil {
+		t.Fatalf("unexpected health: %+v", got)
+	}
+}
+
+func TestHealthzReportsUnavailableWith503(t *testing.T) {
+	rec, got := serveHealth(t, Health{Status: HealthUnavailable, Signal: &SignalHealth{}}, http.MethodGet)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status=%d", rec.Code)
+	}
+	if got.Status != HealthUnavailable || got.Signal == nil || got.Signal.Connected {
+		t.Fatalf("unexpected health: %+v", got)
+	}
+}
+
+func TestHealthzRejectsPost(t *testing.T) {
+	rec, _ := serveHealth(t, Health{Status: HealthOK}, http.MethodPost)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status=%d", rec.Code)
+	}
+}
+
+func TestHealthzNotServedByDefault(t *testing.T) {
+	server := New(config.WebhookConfig{Listen: ":0"}, func(string, string, map[string]string) error { return nil })
+	rec := httptest.NewRecorder()
+	server.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status=%d", rec.Code)
+	}
+}
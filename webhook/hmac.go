@@ -8,13 +8,16 @@ import (
 	"strings"
 )
 
+// ValidateHMAC reports whether signature is "sha256=" followed by the hex
+// HMAC-SHA256 of body keyed by secret. The digests are compared in constant
+// time.
 func ValidateHMAC(body []byte, signature, secret string) bool {
-	if !strings.HasPrefix(signature, "sha256=") {
+	gotHex, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
 		return false
 	}
-	gotHex := signature[len("sha256="):]
 	got, err := hex.DecodeString(gotHex)
-	if err != nil {
+	if err != nil || len(got) == 0 {
 		return false
 	}
 	want := hmac.New(sha256.New, []byte(secret))
@@ -30,10 +30,11 @@ type enqueueCapture struct {
 	calls []enqueueCall
 }
 
-func (e *enqueueCapture) add(source, content string, metadata map[string]string) {
+func (e *enqueueCapture) add(source, content string, metadata map[string]string) error {
 	e.mu.Lock()
 	e.calls = append(e.calls, enqueueCall{source: source, content: content, metadata: metadata})
 	e.mu.Unlock()
+	return nil
 }
 
 func (e *enqueueCapture) snapshot() []enqueueCall {
@@ -135,7 +136,7 @@ func TestWebhookIntegrationSignedValidPostEnqueues(t *testing.T) {
 	server := startWebhookServer(t, config.WebhookConfig{
 		Listen: "127.0.0.1:0",
 		Hooks: []config.WebhookDef{
-			{ID: "signed", Path: "/hook", Format: "text", Secret: "secret"},
+			{ID: "signed", Path: "/hook", Format: "text", Secret: "secret", SignatureHeader: "X-Webhook-Signature"},
 		},
 	})
 	defer server.stop(t)
@@ -163,7 +164,7 @@ func TestWebhookIntegrationSignedInvalidPostRejected(t *testing.T) {
 	server := startWebhookServer(t, config.WebhookConfig{
 		Listen: "127.0.0.1:0",
 		Hooks: []config.WebhookDef{
-			{ID: "signed", Path: "/hook", Secret: "secret", Format: "text"},
+			{ID: "signed", Path: "/hook", Secret: "secret", SignatureHeader: "X-Webhook-Signature", Format: "text"},
 		},
 	})
 	defer server.stop(t)
@@ -189,7 +190,7 @@ func TestWebhookIntegrationSignedMissingSignatureRejected(t *testing.T) {
 	server := startWebhookServer(t, config.WebhookConfig{
 		Listen: "127.0.0.1:0",
 		Hooks: []config.WebhookDef{
-			{ID: "signed", Path: "/hook", Secret: "secret", Format: "text"},
+			{ID: "signed", Path: "/hook", Secret: "secret", SignatureHeader: "X-Webhook-Signature", Format: "text"},
 		},
 	})
 	defer server.stop(t)
@@ -243,6 +244,57 @@ func TestWebhookIntegrationUnknownPathReturns404(t *testing.T) {
 	}
 }
 
+func TestWebhookIntegrationConcurrentHooksRouteToSessions(t *testing.T) {
+	server := startWebhookServer(t, config.WebhookConfig{
+		Listen: "127.0.0.1:0",
+		Hooks: []config.WebhookDef{
+			{ID: "uptime-a", Path: "/uptime-a", Format: "text", SessionID: "uptime", Metadata: map[string]string{"system": "uptime-robot"}},
+			{ID: "uptime-b", Path: "/uptime-b", Format: "text", SessionID: "uptime", Metadata: map[string]string{"system": "uptime-robot"}},
+			{ID: "github", Path: "/github", Format: "json", SessionID: "github-{{.repository}}", Metadata: map[string]string{"system": "github"}},
+		},
+	})
+	defer server.stop(t)
+
+	posts := map[string]string{"/uptime-a": "down", "/uptime-b": "up", "/github": `{"repository":"miclaw"}`}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		for path, body := range posts {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ct := "text/plain"
+				if path == "/github" {
+					ct = "application/json"
+				}
+				res, err := http.Post(server.base+path, ct, strings.NewReader(body))
+				if err != nil {
+					t.Errorf("post %s: %v", path, err)
+					return
+				}
+				res.Body.Close()
+			}()
+		}
+	}
+	wg.Wait()
+
+	got := server.calls.snapshot()
+	if len(got) != 15 {
+		t.Fatalf("want 15 calls, got %d", len(got))
+	}
+	for _, c := range got {
+		want := map[string]string{"uptime-a": "webhook:uptime", "uptime-b": "webhook:uptime", "github": "webhook:github-miclaw"}[c.metadata["hook_id"]]
+		if c.source != want {
+			t.Fatalf("hook %s routed to %q, want %q", c.metadata["hook_id"], c.source, want)
+		}
+		if c.metadata["remote_addr"] == "" || c.metadata["system"] == "" {
+			t.Fatalf("missing metadata: %v", c.metadata)
+		}
+		if (c.metadata["system"] == "github") != (c.metadata["hook_id"] == "github") {
+			t.Fatalf("metadata leaked between hooks: %v", c.metadata)
+		}
+	}
+}
+
 func TestWebhookIntegrationMultipleRapidPostsPreserveOrder(t *testing.T) {
 	server := startWebhookServer(t, config.WebhookConfig{
 		Listen: "127.0.0.1:0",
@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/logging"
+)
+
+const (
+	outboundTimeout = 10 * time.Second
+	outboundBackoff = 500 * time.Millisecond
+)
+
+// OutboundEvent is the JSON document posted for each agent event.
+type OutboundEvent struct {
+	Type   string         `json:"type"`
+	Source string         `json:"source"`
+	Text   string         `json:"text"`
+	Error  string         `json:"error"`
+	Usage  *OutboundUsage `json:"usage"`
+	Time   time.Time      `json:"time"`
+	// Tool fields are set on tool_start, tool_output and tool_result events.
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+	ToolName   string          `json:"tool_name,omitempty"`
+	Params     json.RawMessage `json:"params,omitempty"`
+	DurationMs int64           `json:"duration_ms,omitempty"`
+	IsError    bool            `json:"is_error,omitempty"`
+}
+
+type OutboundUsage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CacheReadTokens  int     `json:"cache_read_tokens"`
+	CacheWriteTokens int     `json:"cache_write_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Outbound posts events to a configured URL from a bounded in-memory queue.
+// Enqueue never blocks; events that do not fit are dropped and counted.
+type Outbound struct {
+	cfg     config.OutboundWebhookConfig
+	client  *http.Client
+	queue   chan OutboundEvent
+	dropped atomic.Int64
+	// backoff is the delay before the first retry; it doubles per attempt.
+	backoff time.Duration
+}
+
+func NewOutbound(cfg config.OutboundWebhookConfig) *Outbound {
+	return &Outbound{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: outboundTimeout},
+		queue:   make(chan OutboundEvent, cfg.QueueSize),
+		backoff: outboundBackoff,
+	}
+}
+
+// Wants reports whether eventType passes the configured event filter.
+func (o *Outbound) Wants(eventType string) bool {
+	return slices.Contains(o.cfg.Events, eventType)
+}
+
+func (o *Outbound) Enqueue(ev OutboundEvent) bool {
+	select {
+	case o.queue <- ev:
+		return true
+	default:
+		o.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped returns how many events were discarded because the queue was full.
+func (o *Outbound) Dropped() int64 {
+	return o.dropped.Load()
+}
+
+// Run delivers queued events until ctx ends. Events still queued at that
+// point are discarded.
+func (o *Outbound) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-o.queue:
+			if err := o.deliver(ctx, ev); err != nil {
+				logging.Errorf("[webhook] outbound_error type=%s err=%v", ev.Type, err)
+			}
+		}
+	}
+}
+
+func (o *Outbound) deliver(ctx context.Context, ev OutboundEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		err = o.post(ctx, body)
+		if err == nil || attempt >= o.cfg.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(o.backoff << attempt):
+		}
+	}
+}
+
+func (o *Outbound) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+o.cfg.Token)
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("outbound webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+)
+
+func outboundConfig(url string) config.OutboundWebhookConfig {
+	return config.OutboundWebhookConfig{URL: url, Events: []string{"response"}, MaxRetries: 2, QueueSize: 4}
+}
+
+func TestOutboundPostsJSONWithBearerToken(t *testing.T) {
+	got := make(chan OutboundEvent, 1)
+	var auth atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Authorization"))
+		var ev OutboundEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		got <- ev
+	}))
+	defer srv.Close()
+	cfg := outboundConfig(srv.URL)
+	cfg.Token = "tok"
+	o := NewOutbound(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go o.Run(ctx)
+
+	o.Enqueue(OutboundEvent{Type: "response", Source: "api", Text: "hi", Usage: &OutboundUsage{PromptTokens: 3}})
+	select {
+	case ev := <-got:
+		if ev.Type != "response" || ev.Source != "api" || ev.Text != "hi" || ev.Usage.PromptTokens != 3 {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+	if auth.Load() != "Bearer tok" {
+		t.Fatalf("unexpected authorization header: %v", auth.Load())
+	}
+}
+
+func TestOutboundRetriesUntilSuccess(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	o := NewOutbound(outboundConfig(srv.URL))
+	o.backoff = time.Millisecond
+
+	if err := o.deliver(context.Background(), OutboundEvent{Type: "response"}); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("want 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestOutboundGivesUpAfterMaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	o := NewOutbound(outboundConfig(srv.URL))
+	o.backoff = time.Millisecond
+
+	if err := o.deliver(context.Background(), OutboundEvent{Type: "response"}); err == nil {
+		t.Fatal("want error after retries")
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("want 1 attempt + 2 retries, got %d", calls.Load())
+	}
+}
+
+func TestOutboundEnqueueDropsWhenFull(t *testing.T) {
+	o := NewOutbound(outboundConfig("http://127.0.0.1:1"))
+	for range 4 {
+		if !o.Enqueue(OutboundEvent{Type: "response"}) {
+			t.Fatal("enqueue failed before queue was full")
+		}
+	}
+	if o.Enqueue(OutboundEvent{Type: "response"}) || o.Enqueue(OutboundEvent{Type: "response"}) {
+		t.Fatal("enqueue succeeded on a full queue")
+	}
+	if o.Dropped() != 2 {
+		t.Fatalf("want 2 dropped, got %d", o.Dropped())
+	}
+}
+
+func TestOutboundWantsFiltersEvents(t *testing.T) {
+	o := NewOutbound(outboundConfig("http://example.com"))
+	if !o.Wants("response") || o.Wants("delta") || o.Wants("error") {
+		t.Fatal("event filter mismatch")
+	}
+}
@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/agusx1211/miclaw/config"
+)
+
+// compiledHook is a hook with its content and session templates parsed
+// once, when the server is built.
+type compiledHook struct {
+	config.WebhookDef
+	content *template.Template
+	session *template.Template
+}
+
+// compileHook parses the hook's templates. Config validation has already
+// rejected templates that do not parse.
+func compileHook(def config.WebhookDef) compiledHook {
+	hook := compiledHook{WebhookDef: def}
+	if def.ContentTemplate != "" {
+		hook.content = parseTemplate("content", def.ContentTemplate)
+	}
+	if strings.Contains(def.SessionID, "{{") {
+		hook.session = parseTemplate("session", def.SessionID)
+	}
+	return hook
+}
+
+func parseTemplate(name, text string) *template.Template {
+	return template.Must(template.New(name).Option("missingkey=error").Parse(text))
+}
+
+func jsonContent(hook compiledHook, body []byte) string {
+	if hook.content == nil && hook.ContentPath == "" {
+		return string(body)
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+	if out, ok := extractContent(hook, v); ok {
+		return out
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return string(body)
+	}
+	return string(b)
+}
+
+// hookSession returns the hook's session ID, rendering it against the JSON
+// payload when it is a template. It falls back to the hook ID.
+func hookSession(hook compiledHook, body []byte) string {
+	if hook.SessionID == "" {
+		return hook.ID
+	}
+	if hook.session == nil {
+		return hook.SessionID
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return hook.ID
+	}
+	if out, ok := renderTemplate(hook.session, v); ok {
+		return out
+	}
+	return hook.ID
+}
+
+// hookMetadata builds an input's metadata: the hook's static metadata, then
+// its metadata_fields read from the JSON payload, then the built-in keys,
+// which neither can override. Fields absent from the payload are left out.
+func hookMetadata(hook config.WebhookDef, body []byte, session, remoteAddr string) map[string]string {
+	out := make(map[string]string, len(hook.Metadata)+len(hook.MetadataFields)+4)
+	for k, v := range hook.Metadata {
+		out[k] = v
+	}
+	var payload any
+	if len(hook.MetadataFields) > 0 && json.Unmarshal(body, &payload) == nil {
+		for _, path := range hook.MetadataFields {
+			leaf, ok := lookupPath(payload, path)
+			if !ok {
+				continue
+			}
+			if v, ok := formatValue(leaf); ok {
+				out[path] = v
+			}
+		}
+	}
+	out["id"] = hook.ID
+	out["hook_id"] = hook.ID
+	out["session_id"] = session
+	out["remote_addr"] = remoteAddr
+	return out
+}
+
+func extractContent(hook compiledHook, v any) (string, bool) {
+	if hook.content != nil {
+		return renderTemplate(hook.content, v)
+	}
+	leaf, ok := lookupPath(v, hook.ContentPath)
+	if !ok {
+		return "", false
+	}
+	return formatValue(leaf)
+}
+
+func renderTemplate(tmpl *template.Template, v any) (string, bool) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, v); err != nil {
+		return "", false
+	}
+	out := strings.TrimSpace(b.String())
+	return out, out != ""
+}
+
+func lookupPath(v any, path string) (any, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func formatValue(v any) (string, bool) {
+	switch x := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return x, strings.TrimSpace(x) != ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
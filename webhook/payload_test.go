@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agusx1211/miclaw/config"
+)
+
+const grafanaPayload = `{
+	"status": "firing",
+	"alerts": [
+		{"labels": {"alertname": "HighCPU"}, "annotations": {"summary": "cpu at 97%"}},
+		{"labels": {"alertname": "DiskFull"}, "annotations": {}}
+	]
+}`
+
+func TestJSONContentPathReadsNestedArray(t *testing.T) {
+	hook := config.WebhookDef{Format: "json", ContentPath: "alerts.0.annotations.summary"}
+	got := jsonContent(compileHook(hook), []byte(grafanaPayload))
+	if got != "cpu at 97%" {
+		t.Fatalf("got=%q", got)
+	}
+}
+
+func TestJSONContentPathMarshalsNonStringLeaf(t *testing.T) {
+	hook := config.WebhookDef{Format: "json", ContentPath: "alerts.1.labels"}
+	got := jsonContent(compileHook(hook), []byte(grafanaPayload))
+	if got != `{"alertname":"DiskFull"}` {
+		t.Fatalf("got=%q", got)
+	}
+}
+
+func TestJSONContentPathFallsBackOnAbsentField(t *testing.T) {
+	for _, path := range []string{
+		"alerts.1.annotations.summary",
+		"alerts.5.labels",
+		"alerts.x",
+		"status.deeper",
+	} {
+		hook := config.WebhookDef{Format: "json", ContentPath: path}
+		got := jsonContent(compileHook(hook), []byte(grafanaPayload))
+		if !strings.Contains(got, "\n  \"alerts\": [") {
+			t.Fatalf("path %q: want pretty JSON fallback, got %q", path, got)
+		}
+	}
+}
+
+func TestJSONContentTemplateRendersNestedValues(t *testing.T) {
+	hook := config.WebhookDef{
+		Format:          "json",
+		ContentTemplate: `Alert {{(index .alerts 0).labels.alertname}} is {{.status}}`,
+	}
+	got := jsonContent(compileHook(hook), []byte(grafanaPayload))
+	if got != "Alert HighCPU is firing" {
+		t.Fatalf("got=%q", got)
+	}
+}
+
+func TestJSONContentTemplateFallsBackOnMissingKey(t *testing.T) {
+	for _, text := range []string{
+		`{{(index .alerts 1).annotations.summary}}`,
+		`{{(index .alerts 9).labels}}`,
+		`{{.missing.deeper}}`,
+	} {
+		hook := config.WebhookDef{Format: "json", ContentTemplate: text}
+		got := jsonContent(compileHook(hook), []byte(grafanaPayload))
+		if !strings.Contains(got, "\"status\": \"firing\"") {
+			t.Fatalf("template %q: want pretty JSON fallback, got %q", text, got)
+		}
+	}
+}
+
+func TestJSONContentKeepsRawBodyWhenNotJSON(t *testing.T) {
+	hook := config.WebhookDef{Format: "json", ContentPath: "a.b"}
+	got := jsonContent(compileHook(hook), []byte("not json {"))
+	if got != "not json {" {
+		t.Fatalf("got=%q", got)
+	}
+}
+
+func TestCompileHookParsesTemplatesOnce(t *testing.T) {
+	hook := compileHook(config.WebhookDef{ContentTemplate: `{{.status}}`, SessionID: "grafana-{{.status}}"})
+	if hook.content == nil || hook.session == nil {
+		t.Fatalf("templates not parsed: %+v", hook)
+	}
+	if static := compileHook(config.WebhookDef{SessionID: "alerts"}); static.content != nil || static.session != nil {
+		t.Fatalf("static hook got templates: %+v", static)
+	}
+}
+
+func TestHookSessionStaticTemplatedAndFallback(t *testing.T) {
+	cases := []struct {
+		hook config.WebhookDef
+		body string
+		want string
+	}{
+		{config.WebhookDef{ID: "h"}, "x", "h"},
+		{config.WebhookDef{ID: "h", SessionID: "alerts"}, "x", "alerts"},
+		{config.WebhookDef{ID: "h", SessionID: "grafana-{{.status}}"}, grafanaPayload, "grafana-firing"},
+		{config.WebhookDef{ID: "h", SessionID: "{{.missing}}"}, grafanaPayload, "h"},
+		{config.WebhookDef{ID: "h", SessionID: "{{.status}}"}, "not json", "h"},
+	}
+	for _, c := range cases {
+		if got := hookSession(compileHook(c.hook), []byte(c.body)); got != c.want {
+			t.Fatalf("hookSession(%q)=%q want %q", c.hook.SessionID, got, c.want)
+		}
+	}
+}
+
+func TestHookMetadataMergesConfiguredAndBuiltinKeys(t *testing.T) {
+	hook := config.WebhookDef{ID: "gh", Metadata: map[string]string{"team": "infra", "hook_id": "spoofed"}}
+	got := hookMetadata(hook, nil, "shared", "10.0.0.1:5000")
+	want := map[string]string{"team": "infra", "id": "gh", "hook_id": "gh", "session_id": "shared", "remote_addr": "10.0.0.1:5000"}
+	if len(got) != len(want) {
+		t.Fatalf("got=%v", got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("metadata[%s]=%q want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestHookMetadataPromotesPayloadFields(t *testing.T) {
+	hook := config.WebhookDef{ID: "grafana", MetadataFields: []string{"status", "alerts.1.labels", "alerts.5.labels", "session_id"}}
+	got := hookMetadata(hook, []byte(`{"status": "firing", "session_id": "spoofed", "alerts": [{}, {"labels": {"a": "b"}}]}`), "grafana", "")
+	if got["status"] != "firing" || got["alerts.1.labels"] != `{"a":"b"}` {
+		t.Fatalf("fields not promoted: %v", got)
+	}
+	if _, ok := got["alerts.5.labels"]; ok {
+		t.Fatalf("absent field promoted: %v", got)
+	}
+	if got["session_id"] != "grafana" {
+		t.Fatalf("payload overrode a built-in key: %v", got)
+	}
+}
+
+func TestWebhookJSONTemplateAndMetadataFields(t *testing.T) {
+	cfg := config.WebhookConfig{
+		Listen: ":0",
+		Hooks: []config.WebhookDef{{
+			ID:              "grafana",
+			Path:            "/grafana",
+			Format:          "json",
+			ContentTemplate: `{{.status}}: {{(index .alerts 0).annotations.summary}}`,
+			MetadataFields:  []string{"status", "alerts.0.labels.alertname"},
+		}},
+	}
+	var content string
+	var metadata map[string]string
+	server := New(cfg, func(_ string, c string, m map[string]string) error {
+		content, metadata = c, m
+		return nil
+	})
+	ts := httptest.NewServer(server.server.Handler)
+	defer ts.Close()
+
+	res, err := ts.Client().Post(ts.URL+"/grafana", "application/json", strings.NewReader(grafanaPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatalf("status=%d", res.StatusCode)
+	}
+	if content != "firing: cpu at 97%" {
+		t.Fatalf("content=%q", content)
+	}
+	if metadata["status"] != "firing" || metadata["alerts.0.labels.alertname"] != "HighCPU" || metadata["hook_id"] != "grafana" {
+		t.Fatalf("metadata=%v", metadata)
+	}
+}
+
+func TestWebhookJSONFormatAppliesContentPath(t *testing.T) {
+	cfg := config.WebhookConfig{
+		Listen: ":0",
+		Hooks: []config.WebhookDef{
+			{ID: "grafana", Path: "/grafana", Format: "json", ContentPath: "alerts.0.labels.alertname"},
+		},
+	}
+	var got string
+	server := New(cfg, func(_ string, content string, _ map[string]string) error {
+		got = content
+		return nil
+	})
+	ts := httptest.NewServer(server.server.Handler)
+	defer ts.Close()
+
+	res, err := ts.Client().Post(ts.URL+"/grafana", "application/json", strings.NewReader(grafanaPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatalf("status=%d", res.StatusCode)
+	}
+	if got != "HighCPU" {
+		t.Fatalf("got=%q", got)
+	}
+}
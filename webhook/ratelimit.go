@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+)
+
+// hookLimiter keeps a token bucket per hook ID for hooks with
+// max_requests_per_minute set. A bucket holds up to a minute's worth of
+// requests and refills continuously.
+type hookLimiter struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	rates   map[string]float64
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	at     time.Time
+}
+
+func newHookLimiter(hooks []config.WebhookDef) *hookLimiter {
+	l := &hookLimiter{now: time.Now, rates: map[string]float64{}, buckets: map[string]*bucket{}}
+	for _, h := range hooks {
+		if h.MaxRequestsPerMinute > 0 {
+			l.rates[h.ID] = float64(h.MaxRequestsPerMinute)
+		}
+	}
+	return l
+}
+
+// allow takes a token for hook and reports whether one was available. When
+// not, it returns how long until the next one is.
+func (l *hookLimiter) allow(hook string) (bool, time.Duration) {
+	perMinute, ok := l.rates[hook]
+	if !ok {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b, ok := l.buckets[hook]
+	if !ok {
+		b = &bucket{tokens: perMinute, at: now}
+		l.buckets[hook] = b
+	}
+	b.tokens = math.Min(perMinute, b.tokens+now.Sub(b.at).Minutes()*perMinute)
+	b.at = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / perMinute * float64(time.Minute))
+}
@@ -0,0 +1,117 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+)
+
+func TestWebhookRateLimitBurstAndRefill(t *testing.T) {
+	cfg := config.WebhookConfig{
+		Listen: ":0",
+		Hooks: []config.WebhookDef{
+			{ID: "noisy", Path: "/noisy", Format: "text", MaxRequestsPerMinute: 3},
+			{ID: "quiet", Path: "/quiet", Format: "text"},
+		},
+	}
+	enqueued := 0
+	server := New(cfg, func(string, string, map[string]string) error {
+		enqueued++
+		return nil
+	})
+	now := time.Unix(1_700_000_000, 0)
+	server.limits.now = func() time.Time { return now }
+	post := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader("x")))
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		if rec := post("/noisy"); rec.Code != http.StatusAccepted {
+			t.Fatalf("request %d status=%d", i, rec.Code)
+		}
+	}
+	rec := post("/noisy")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "20" {
+		t.Fatalf("over limit: status=%d retry-after=%q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := post("/quiet"); rec.Code != http.StatusAccepted {
+		t.Fatalf("unlimited hook status=%d", rec.Code)
+	}
+	if enqueued != 4 {
+		t.Fatalf("enqueued=%d, want 4", enqueued)
+	}
+
+	now = now.Add(20 * time.Second)
+	if rec := post("/noisy"); rec.Code != http.StatusAccepted {
+		t.Fatalf("after refill status=%d", rec.Code)
+	}
+	if rec := post("/noisy"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("refill gave more than one token: status=%d", rec.Code)
+	}
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if rec := post("/noisy"); rec.Code != http.StatusAccepted {
+			t.Fatalf("burst after idle %d status=%d", i, rec.Code)
+		}
+	}
+	if rec := post("/noisy"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("bucket exceeded its capacity: status=%d", rec.Code)
+	}
+}
+
+func TestWebhookMaxInFlightRejectsExtraRequests(t *testing.T) {
+	cfg := config.WebhookConfig{
+		Listen:      ":0",
+		MaxInFlight: 1,
+		Hooks:       []config.WebhookDef{{ID: "alpha", Path: "/webhook", Format: "text"}},
+	}
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	server := New(cfg, func(_ string, content string, _ map[string]string) error {
+		if content == "slow" {
+			close(entered)
+			<-release
+		}
+		return nil
+	})
+	ts := httptest.NewServer(server.server.Handler)
+	defer ts.Close()
+
+	done := make(chan int)
+	go func() {
+		res, err := ts.Client().Post(ts.URL+"/webhook", "text/plain", strings.NewReader("slow"))
+		if err != nil {
+			done <- 0
+			return
+		}
+		res.Body.Close()
+		done <- res.StatusCode
+	}()
+	<-entered
+	res, err := ts.Client().Post(ts.URL+"/webhook", "text/plain", strings.NewReader("fast"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests || res.Header.Get("Retry-After") != "1" {
+		t.Fatalf("status=%d retry-after=%q", res.StatusCode, res.Header.Get("Retry-After"))
+	}
+	close(release)
+	if code := <-done; code != http.StatusAccepted {
+		t.Fatalf("slow request status=%d", code)
+	}
+	res, err = ts.Client().Post(ts.URL+"/webhook", "text/plain", strings.NewReader("fast"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatalf("status after release=%d", res.StatusCode)
+	}
+}
@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/logging"
+)
+
+// SyncFunc queues a payload from a sync hook and returns a wait that blocks
+// until the agent's turn for it ends, yielding the text the agent sent back
+// to the hook's source.
+// A non-nil error from SyncFunc itself means the input was not accepted,
+// as with EnqueueFunc.
+type SyncFunc func(source, content string, metadata map[string]string) (func(ctx context.Context) (string, error), error)
+
+// HandleSync lets hooks with sync set answer with the agent's reply. Without
+// it they behave like async hooks. It must be called before Start.
+func (s *Server) HandleSync(run SyncFunc) {
+	s.sync = run
+}
+
+// respondSync runs the input and writes the reply as the response body:
+// 200 with the text, 504 when the timeout passes first or the turn sent no
+// reply, and 500 when the turn fails. A timed-out input stays queued and
+// still runs.
+func (s *Server) respondSync(w http.ResponseWriter, r *http.Request, hook config.WebhookDef, source, content string, metadata map[string]string) {
+	wait, err := s.sync(source, content, metadata)
+	if err != nil {
+		logging.Errorf("[webhook] rejected hook=%s err=%v", hook.ID, err)
+		tooManyRequests(w, retryAfterSeconds*time.Second)
+		return
+	}
+	ctx := r.Context()
+	if hook.SyncTimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(hook.SyncTimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+	reply, err := wait(ctx)
+	if ctx.Err() != nil {
+		logging.Errorf("[webhook] sync_timeout hook=%s", hook.ID)
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return
+	}
+	if err != nil {
+		logging.Errorf("[webhook] sync_error hook=%s err=%v", hook.ID, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if reply == "" {
+		logging.Errorf("[webhook] sync_no_reply hook=%s", hook.ID)
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(reply))
+}
@@ -5,27 +5,47 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/agusx1211/miclaw/config"
+	"github.com/agusx1211/miclaw/logging"
 )
 
+// retryAfterSeconds is sent with 429 responses when the agent is backlogged.
+const retryAfterSeconds = 30
+
+// EnqueueFunc hands a webhook payload to the agent. A non-nil error means the
+// input was not accepted (the agent queue is full) and the caller should retry.
+type EnqueueFunc func(source, content string, metadata map[string]string) error
+
 type Server struct {
-	server  *http.Server
-	cfg     config.WebhookConfig
-	enqueue func(source, content string, metadata map[string]string)
+	server   *http.Server
+	mux      *http.ServeMux
+	cfg      config.WebhookConfig
+	enqueue  EnqueueFunc
+	sync     SyncFunc
+	limits   *hookLimiter
+	inFlight chan struct{}
 }
 
-func New(cfg config.WebhookConfig, enqueue func(source, content string, metadata map[string]string)) *Server {
+func New(cfg config.WebhookConfig, enqueue EnqueueFunc) *Server {
 	s := &Server{
 		cfg:     cfg,
 		enqueue: enqueue,
+		limits:  newHookLimiter(cfg.Hooks),
+	}
+	if cfg.MaxInFlight > 0 {
+		s.inFlight = make(chan struct{}, cfg.MaxInFlight)
 	}
 	mux := http.NewServeMux()
+	s.mux = mux
 	mux.HandleFunc("/health", s.health)
-	for _, hook := range cfg.Hooks {
-		hook := hook
-		mux.HandleFunc(hook.Path, s.webhookHandler(hook))
+	for _, def := range cfg.Hooks {
+		mux.HandleFunc(def.Path, s.webhookHandler(compileHook(def)))
 	}
 	s.server = &http.Server{
 		Addr:    cfg.Listen,
@@ -66,26 +86,110 @@ func (s *Server) health(w http.ResponseWriter, _ *http.Request) {
 	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
-func (s *Server) webhookHandler(hook config.WebhookDef) http.HandlerFunc {
+func (s *Server) webhookHandler(hook compiledHook) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+		if !s.enter() {
+			logging.Infof("[webhook] busy hook=%s in_flight=%d", hook.ID, cap(s.inFlight))
+			tooManyRequests(w, time.Second)
 			return
 		}
-		if hook.Secret != "" && !ValidateHMAC(body, r.Header.Get("X-Webhook-Signature"), hook.Secret) {
+		defer s.leave()
+		if token := hookToken(s.cfg, hook.WebhookDef); token != "" && !ValidateBearer(r.Header.Get("Authorization"), token) {
+			logging.Errorf("[webhook] unauthorized hook=%s", hook.ID)
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
+		if hook.Format == "json" && !isJSONContentType(r.Header.Get("Content-Type")) {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		body, status := s.readBody(w, r)
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		if hook.Secret != "" && !ValidateHMAC(body, r.Header.Get(hook.SignatureHeader), hook.Secret) {
+			logging.Errorf("[webhook] bad signature hook=%s", hook.ID)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if ok, wait := s.limits.allow(hook.ID); !ok {
+			logging.Infof("[webhook] rate_limited hook=%s", hook.ID)
+			tooManyRequests(w, wait)
+			return
+		}
 		content := string(body)
 		if hook.Format == "json" {
-			content = string(body)
+			content = jsonContent(hook, body)
+		}
+		session := hookSession(hook, body)
+		metadata := hookMetadata(hook.WebhookDef, body, session, r.RemoteAddr)
+		if hook.Sync && s.sync != nil {
+			s.respondSync(w, r, hook.WebhookDef, "webhook:"+session, content, metadata)
+			return
+		}
+		if err := s.enqueue("webhook:"+session, content, metadata); err != nil {
+			logging.Errorf("[webhook] rejected hook=%s err=%v", hook.ID, err)
+			tooManyRequests(w, retryAfterSeconds*time.Second)
+			return
 		}
-		s.enqueue("webhook:"+hook.ID, content, map[string]string{"id": hook.ID})
 		w.WriteHeader(http.StatusAccepted)
 	}
 }
+
+// enter takes an in-flight slot, or reports false when webhook.max_in_flight
+// requests are already being handled.
+func (s *Server) enter() bool {
+	if s.inFlight == nil {
+		return true
+	}
+	select {
+	case s.inFlight <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Server) leave() {
+	if s.inFlight != nil {
+		<-s.inFlight
+	}
+}
+
+// tooManyRequests answers 429 with a Retry-After of wait, rounded up to
+// whole seconds.
+func tooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	secs := int((wait + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(max(secs, 1)))
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
+// readBody reads the request body under the configured size cap and returns a
+// non-zero status when it cannot be accepted.
+func (s *Server) readBody(w http.ResponseWriter, r *http.Request) ([]byte, int) {
+	if s.cfg.MaxBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes)
+	}
+	body, err := io.ReadAll(r.Body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, http.StatusRequestEntityTooLarge
+	}
+	if err != nil {
+		return nil, http.StatusBadRequest
+	}
+	return body, 0
+}
+
+func isJSONContentType(v string) bool {
+	mt, _, err := mime.ParseMediaType(v)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
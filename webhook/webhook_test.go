@@ -1,9 +1,11 @@
 package webhook
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -24,10 +26,11 @@ func TestWebhookEnqueuesMessage(t *testing.T) {
 	var gotSource string
 	var gotContent string
 	var gotMetadata map[string]string
-	server := New(cfg, func(source, content string, metadata map[string]string) {
+	server := New(cfg, func(source, content string, metadata map[string]string) error {
 		gotSource = source
 		gotContent = content
 		gotMetadata = metadata
+		return nil
 	})
 	ts := httptest.NewServer(server.server.Handler)
 	defer ts.Close()
@@ -52,16 +55,50 @@ func TestWebhookEnqueuesMessage(t *testing.T) {
 	}
 }
 
+func TestWebhookReturns429WhenEnqueueRejects(t *testing.T) {
+	cfg := config.WebhookConfig{
+		Listen: ":0",
+		Hooks:  []config.WebhookDef{{ID: "alpha", Path: "/webhook", Format: "text"}},
+	}
+	full := true
+	server := New(cfg, func(string, string, map[string]string) error {
+		if full {
+			return errors.New("input queue is full")
+		}
+		return nil
+	})
+	ts := httptest.NewServer(server.server.Handler)
+	defer ts.Close()
+
+	res, err := ts.Client().Post(ts.URL+"/webhook", "text/plain", strings.NewReader("spam"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests || res.Header.Get("Retry-After") != "30" {
+		t.Fatalf("status=%d retry-after=%q", res.StatusCode, res.Header.Get("Retry-After"))
+	}
+	full = false
+	res, err = ts.Client().Post(ts.URL+"/webhook", "text/plain", strings.NewReader("ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatalf("status after drain=%d", res.StatusCode)
+	}
+}
+
 func TestWebhookHMACValid(t *testing.T) {
 	t.Helper()
 	cfg := config.WebhookConfig{
 		Listen: ":0",
 		Hooks: []config.WebhookDef{
-			{ID: "signed", Path: "/webhook", Secret: "secret", Format: "text"},
+			{ID: "signed", Path: "/webhook", Secret: "secret", SignatureHeader: "X-Webhook-Signature", Format: "text"},
 		},
 	}
 	body := "secret payload"
-	server := New(cfg, func(string, string, map[string]string) {})
+	server := New(cfg, func(string, string, map[string]string) error { return nil })
 	ts := httptest.NewServer(server.server.Handler)
 	defer ts.Close()
 
@@ -85,10 +122,10 @@ func TestWebhookHMACInvalid(t *testing.T) {
 	cfg := config.WebhookConfig{
 		Listen: ":0",
 		Hooks: []config.WebhookDef{
-			{ID: "signed", Path: "/webhook", Secret: "secret", Format: "text"},
+			{ID: "signed", Path: "/webhook", Secret: "secret", SignatureHeader: "X-Webhook-Signature", Format: "text"},
 		},
 	}
-	server := New(cfg, func(string, string, map[string]string) {})
+	server := New(cfg, func(string, string, map[string]string) error { return nil })
 	ts := httptest.NewServer(server.server.Handler)
 	defer ts.Close()
 
@@ -112,10 +149,10 @@ func TestWebhookHMACMissing(t *testing.T) {
 	cfg := config.WebhookConfig{
 		Listen: ":0",
 		Hooks: []config.WebhookDef{
-			{ID: "signed", Path: "/webhook", Secret: "secret", Format: "text"},
+			{ID: "signed", Path: "/webhook", Secret: "secret", SignatureHeader: "X-Webhook-Signature", Format: "text"},
 		},
 	}
-	server := New(cfg, func(string, string, map[string]string) {})
+	server := New(cfg, func(string, string, map[string]string) error { return nil })
 	ts := httptest.NewServer(server.server.Handler)
 	defer ts.Close()
 
@@ -137,7 +174,7 @@ func TestWebhookUnsigned(t *testing.T) {
 			{ID: "open", Path: "/webhook", Secret: "", Format: "text"},
 		},
 	}
-	server := New(cfg, func(string, string, map[string]string) {})
+	server := New(cfg, func(string, string, map[string]string) error { return nil })
 	ts := httptest.NewServer(server.server.Handler)
 	defer ts.Close()
 
@@ -151,6 +188,99 @@ func TestWebhookUnsigned(t *testing.T) {
 	}
 }
 
+func TestWebhookHMACCustomHeader(t *testing.T) {
+	cfg := config.WebhookConfig{
+		Listen: ":0",
+		Hooks: []config.WebhookDef{
+			{ID: "gh", Path: "/webhook", Secret: "secret", SignatureHeader: "X-Hub-Signature-256", Format: "text"},
+		},
+	}
+	calls := 0
+	server := New(cfg, func(string, string, map[string]string) error { calls++; return nil })
+	body := "push event"
+	for _, tc := range []struct {
+		name, header, value string
+		want                int
+	}{
+		{"valid", "X-Hub-Signature-256", sign(body, "secret"), http.StatusAccepted},
+		{"bare hex", "X-Hub-Signature-256", strings.TrimPrefix(sign(body, "secret"), "sha256="), http.StatusUnauthorized},
+		{"invalid", "X-Hub-Signature-256", sign(body, "other"), http.StatusUnauthorized},
+		{"missing", "X-Hub-Signature-256", "", http.StatusUnauthorized},
+		{"default header ignored", "X-Webhook-Signature", sign(body, "secret"), http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+		if tc.value != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		rec := httptest.NewRecorder()
+		server.server.Handler.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s: status=%d, want %d", tc.name, rec.Code, tc.want)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("enqueued %d inputs, want 1", calls)
+	}
+}
+
+func serveSync(t *testing.T, hook config.WebhookDef, run SyncFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	calls := 0
+	server := New(config.WebhookConfig{Hooks: []config.WebhookDef{hook}}, func(string, string, map[string]string) error { calls++; return nil })
+	server.HandleSync(run)
+	rec := httptest.NewRecorder()
+	server.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, hook.Path, strings.NewReader("status?")))
+	if calls != 0 {
+		t.Fatalf("sync hook used the async enqueue")
+	}
+	return rec
+}
+
+func TestWebhookSyncRespondsWithReply(t *testing.T) {
+	var gotSource, gotContent string
+	rec := serveSync(t, config.WebhookDef{ID: "ask", Path: "/ask", Format: "text", Sync: true, SyncTimeoutSeconds: 5},
+		func(source, content string, _ map[string]string) (func(context.Context) (string, error), error) {
+			gotSource, gotContent = source, content
+			return func(context.Context) (string, error) { return "all green", nil }, nil
+		})
+	if rec.Code != http.StatusOK || rec.Body.String() != "all green" {
+		t.Fatalf("status=%d body=%q", rec.Code, rec.Body.String())
+	}
+	if gotSource != "webhook:ask" || gotContent != "status?" {
+		t.Fatalf("source=%q content=%q", gotSource, gotContent)
+	}
+}
+
+func TestWebhookSyncTimesOutWith504(t *testing.T) {
+	rec := serveSync(t, config.WebhookDef{ID: "ask", Path: "/ask", Format: "text", Sync: true, SyncTimeoutSeconds: 1},
+		func(string, string, map[string]string) (func(context.Context) (string, error), error) {
+			return func(ctx context.Context) (string, error) { <-ctx.Done(); return "", ctx.Err() }, nil
+		})
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status=%d", rec.Code)
+	}
+}
+
+func TestWebhookSyncWithoutReplyGets504(t *testing.T) {
+	rec := serveSync(t, config.WebhookDef{ID: "ask", Path: "/ask", Format: "text", Sync: true, SyncTimeoutSeconds: 5},
+		func(string, string, map[string]string) (func(context.Context) (string, error), error) {
+			return func(context.Context) (string, error) { return "", nil }, nil
+		})
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status=%d", rec.Code)
+	}
+}
+
+func TestWebhookSyncRejectedWith429(t *testing.T) {
+	rec := serveSync(t, config.WebhookDef{ID: "ask", Path: "/ask", Format: "text", Sync: true, SyncTimeoutSeconds: 1},
+		func(string, string, map[string]string) (func(context.Context) (string, error), error) {
+			return nil, errors.New("input queue is full")
+		})
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status=%d", rec.Code)
+	}
+}
+
 func TestWebhookTextFormat(t *testing.T) {
 	t.Helper()
 	cfg := config.WebhookConfig{
@@ -160,8 +290,9 @@ func TestWebhookTextFormat(t *testing.T) {
 		},
 	}
 	var got string
-	server := New(cfg, func(_ string, content string, _ map[string]string) {
+	server := New(cfg, func(_ string, content string, _ map[string]string) error {
 		got = content
+		return nil
 	})
 	ts := httptest.NewServer(server.server.Handler)
 	defer ts.Close()
@@ -188,8 +319,9 @@ func TestWebhookJSONFormat(t *testing.T) {
 		},
 	}
 	var got string
-	server := New(cfg, func(_ string, content string, _ map[string]string) {
+	server := New(cfg, func(_ string, content string, _ map[string]string) error {
 		got = content
+		return nil
 	})
 	ts := httptest.NewServer(server.server.Handler)
 	defer ts.Close()
@@ -207,9 +339,68 @@ func TestWebhookJSONFormat(t *testing.T) {
 	}
 }
 
+func TestWebhookRejectsOversizedBody(t *testing.T) {
+	cfg := config.WebhookConfig{
+		Listen:       ":0",
+		MaxBodyBytes: 16,
+		Hooks:        []config.WebhookDef{{ID: "alpha", Path: "/webhook", Format: "text", Secret: "s3cret", SignatureHeader: "X-Webhook-Signature"}},
+	}
+	called := false
+	server := New(cfg, func(string, string, map[string]string) error {
+		called = true
+		return nil
+	})
+	ts := httptest.NewServer(server.server.Handler)
+	defer ts.Close()
+
+	body := strings.Repeat("x", 17)
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/webhook", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Webhook-Signature", sign(body, "s3cret"))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status=%d", res.StatusCode)
+	}
+	if called {
+		t.Fatal("oversized body was enqueued")
+	}
+}
+
+func TestWebhookJSONFormatRejectsWrongContentType(t *testing.T) {
+	cfg := config.WebhookConfig{
+		Listen: ":0",
+		Hooks:  []config.WebhookDef{{ID: "json", Path: "/webhook", Format: "json"}},
+	}
+	server := New(cfg, func(string, string, map[string]string) error { return nil })
+	ts := httptest.NewServer(server.server.Handler)
+	defer ts.Close()
+
+	for ct, want := range map[string]int{
+		"text/plain":                      http.StatusUnsupportedMediaType,
+		"":                                http.StatusUnsupportedMediaType,
+		"application/json; charset=utf-8": http.StatusAccepted,
+		"application/vnd.github.v3+json":  http.StatusAccepted,
+	} {
+		res, err := ts.Client().Post(ts.URL+"/webhook", ct, strings.NewReader(`{"x":1}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != want {
+			t.Fatalf("content-type %q: status=%d want %d", ct, res.StatusCode, want)
+		}
+	}
+}
+
 func TestHealthEndpoint(t *testing.T) {
 	t.Helper()
-	server := New(config.WebhookConfig{Listen: ":0"}, func(string, string, map[string]string) {})
+	server := New(config.WebhookConfig{Listen: ":0"}, func(string, string, map[string]string) error { return nil })
 	ts := httptest.NewServer(server.server.Handler)
 	defer ts.Close()
 
@@ -233,7 +424,7 @@ func TestHealthEndpoint(t *testing.T) {
 
 func TestUnknownPath(t *testing.T) {
 	t.Helper()
-	server := New(config.WebhookConfig{Listen: ":0"}, func(string, string, map[string]string) {})
+	server := New(config.WebhookConfig{Listen: ":0"}, func(string, string, map[string]string) error { return nil })
 	ts := httptest.NewServer(server.server.Handler)
 	defer ts.Close()
 
@@ -255,7 +446,7 @@ func TestNonPostMethod(t *testing.T) {
 			{ID: "x", Path: "/webhook", Format: "text"},
 		},
 	}
-	server := New(cfg, func(string, string, map[string]string) {})
+	server := New(cfg, func(string, string, map[string]string) error { return nil })
 	ts := httptest.NewServer(server.server.Handler)
 	defer ts.Close()
 